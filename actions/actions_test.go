@@ -1,6 +1,7 @@
 package actions
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"os"
@@ -105,7 +106,7 @@ func addCitiesDataset(t *testing.T, node *p2p.QriNode) repo.DatasetRef {
 	dsp.Name = tc.Name
 	dsp.BodyBytes = tc.Body
 
-	ref, _, err := SaveDataset(node, dsp, nil, nil, false, true, false)
+	ref, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -121,7 +122,7 @@ func addFlourinatedCompoundsDataset(t *testing.T, node *p2p.QriNode) repo.Datase
 	dsp.Name = tc.Name
 	dsp.BodyBytes = tc.Body
 
-	ref, _, err := SaveDataset(node, dsp, nil, nil, false, true, false)
+	ref, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -137,7 +138,7 @@ func addNowTransformDataset(t *testing.T, node *p2p.QriNode) repo.DatasetRef {
 	dsp.Name = tc.Name
 	dsp.Transform.ScriptPath = "testdata/now_tf/transform.star"
 
-	ref, _, err := SaveDataset(node, dsp, nil, nil, false, true, false)
+	ref, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
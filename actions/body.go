@@ -1,17 +1,19 @@
 package actions
 
 import (
-	"fmt"
+	"io"
 
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/dataset/dsfs"
-	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
 )
 
-// LookupBody grabs a subset of a dataset's body
-func LookupBody(node *p2p.QriNode, path string, format dataset.DataFormat, fcfg dataset.FormatConfig, limit, offset int, all bool) (bodyPath string, data []byte, err error) {
+// LookupBody grabs a subset of a dataset's body. fields optionally limits
+// each entry to a set of named fields
+func LookupBody(node *p2p.QriNode, path string, format dataset.DataFormat, fcfg dataset.FormatConfig, limit, offset int, all bool, fields []string) (bodyPath string, data []byte, err error) {
 	var (
 		file  cafs.File
 		store = node.Repo.Store()
@@ -36,7 +38,7 @@ func LookupBody(node *p2p.QriNode, path string, format dataset.DataFormat, fcfg
 		Schema:       ds.Structure.Schema,
 	})
 
-	data, err = ConvertBodyFile(file, ds.Structure, st, limit, offset, all)
+	data, err = base.ConvertBodyPage(file, ds.Structure, st, limit, offset, all, fields)
 	if err != nil {
 		log.Debug(err.Error())
 		return "", nil, err
@@ -45,32 +47,74 @@ func LookupBody(node *p2p.QriNode, path string, format dataset.DataFormat, fcfg
 	return ds.BodyPath, data, nil
 }
 
-// ConvertBodyFile takes an input file & structure, and converts a specified selection
-// to the structure specified by out
-func ConvertBodyFile(file cafs.File, in, out *dataset.Structure, limit, offset int, all bool) (data []byte, err error) {
-	buf, err := dsio.NewEntryBuffer(out)
+// WriteBody streams a window of a dataset's body to w, returning the path of
+// the body. if all is true every entry from offset onward is written.
+// encoding optionally writes the body in a base body encoding like
+// base.NDJSONEncoding instead of format
+func WriteBody(node *p2p.QriNode, w io.Writer, path string, format dataset.DataFormat, fcfg dataset.FormatConfig, limit, offset int, all bool, encoding string, fields []string) (bodyPath string, err error) {
+	store := node.Repo.Store()
+
+	ds, err := dsfs.LoadDataset(store, path)
 	if err != nil {
-		err = fmt.Errorf("error allocating result buffer: %s", err)
-		return
+		log.Debug(err.Error())
+		return "", err
 	}
-	rr, err := dsio.NewEntryReader(in, file)
+
+	file, err := dsfs.LoadBody(store, ds)
 	if err != nil {
-		err = fmt.Errorf("error allocating data reader: %s", err)
-		return
+		log.Debug(err.Error())
+		return "", err
 	}
+	defer file.Close()
+
+	st := &dataset.Structure{}
+	st.Assign(ds.Structure, &dataset.Structure{
+		Format:       format,
+		FormatConfig: fcfg,
+		Schema:       ds.Structure.Schema,
+	})
 
-	if !all {
-		rr = &dsio.PagedReader{
-			Reader: rr,
-			Limit:  limit,
-			Offset: offset,
-		}
+	if err = base.WriteBody(w, file, ds.Structure, st, limit, offset, all, encoding, fields); err != nil {
+		log.Debug(err.Error())
+		return ds.BodyPath, err
+	}
+	return ds.BodyPath, nil
+}
+
+// LookupRemoteBody asks peers for a page of a dataset body that isn't
+// available locally. The peer that owns the dataset must have published it
+func LookupRemoteBody(node *p2p.QriNode, ref repo.DatasetRef, format dataset.DataFormat, fcfg dataset.FormatConfig, limit, offset int, fields []string) (bodyPath string, data []byte, err error) {
+	if !node.Online {
+		return "", nil, p2p.ErrNotConnected
+	}
+
+	p := p2p.DatasetBodyRequest{
+		// only send identifiers, ref.Dataset may be populated
+		Ref: repo.DatasetRef{
+			Peername:  ref.Peername,
+			ProfileID: ref.ProfileID,
+			Name:      ref.Name,
+			Path:      ref.Path,
+		},
+		Format: format.String(),
+		Limit:  limit,
+		Offset: offset,
+		Fields: fields,
+	}
+	if fcfg != nil {
+		p.FormatConfig = fcfg.Map()
 	}
-	err = dsio.Copy(rr, buf)
 
-	if err := buf.Close(); err != nil {
-		return nil, fmt.Errorf("error closing row buffer: %s", err.Error())
+	res, err := node.RequestDatasetBody(p)
+	if err != nil {
+		log.Debug(err.Error())
+		return "", nil, err
 	}
+	return res.Path, res.Data, nil
+}
 
-	return buf.Bytes(), nil
+// ConvertBodyFile takes an input file & structure, and converts a specified selection
+// to the structure specified by out
+func ConvertBodyFile(file cafs.File, in, out *dataset.Structure, limit, offset int, all bool) (data []byte, err error) {
+	return base.ConvertBodyPage(file, in, out, limit, offset, all, nil)
 }
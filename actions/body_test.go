@@ -11,7 +11,7 @@ func TestLookupBody(t *testing.T) {
 	node := newTestNode(t)
 	ref := addCitiesDataset(t, node)
 
-	bodyPath, data, err := LookupBody(node, ref.Path, dataset.JSONDataFormat, nil, 1, 1, false)
+	bodyPath, data, err := LookupBody(node, ref.Path, dataset.JSONDataFormat, nil, 1, 1, false, nil)
 	if err != nil {
 		t.Error(err.Error())
 	}
@@ -1,6 +1,7 @@
 package actions
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -11,13 +12,34 @@ import (
 	"github.com/qri-io/dataset/dsfs"
 	"github.com/qri-io/dataset/validate"
 	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/p2p"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/repo/profile"
 )
 
-// SaveDataset initializes a dataset from a dataset pointer and data file
-func SaveDataset(node *p2p.QriNode, changesPod *dataset.DatasetPod, secrets map[string]string, scriptOut io.Writer, dryRun, pin, convertFormatToPrev bool) (ref repo.DatasetRef, body cafs.File, err error) {
+// SaveDatasetOptions are optional settings for SaveDataset
+type SaveDatasetOptions struct {
+	// DryRun saves don't write the dataset to the repo
+	DryRun bool
+	// Pin saved data to the store
+	Pin bool
+	// ConvertFormatToPrev converts the body to the format of the previous
+	// version
+	ConvertFormatToPrev bool
+	// Rules are stored with the saved version, nil rules keep the rules of the
+	// previous version
+	Rules []*base.Rule
+	// Strict saves refuse versions that fail any rule. all saves refuse schema
+	// changes that break the policy of a schema rule
+	Strict bool
+	// Limits bound the resources a transform can use
+	Limits *config.Transform
+}
+
+// SaveDataset initializes a dataset from a dataset pointer and data file.
+// opts may be nil
+func SaveDataset(ctx context.Context, node *p2p.QriNode, changesPod *dataset.DatasetPod, secrets map[string]string, scriptOut io.Writer, opts *SaveDatasetOptions) (ref repo.DatasetRef, body cafs.File, err error) {
 	var (
 		changes                                = &dataset.Dataset{}
 		prevBodyFile, bodyFile, changeBodyFile cafs.File
@@ -25,6 +47,11 @@ func SaveDataset(node *p2p.QriNode, changesPod *dataset.DatasetPod, secrets map[
 		pro                                    *profile.Profile
 		r                                      = node.Repo
 	)
+	if opts == nil {
+		opts = &SaveDatasetOptions{}
+	}
+	rules := opts.Rules
+	dryRun := opts.DryRun
 
 	prev, mutable, prevBodyFile, prevPath, err := base.PrepareDatasetSave(r, changesPod.Peername, changesPod.Name)
 	if err != nil {
@@ -36,6 +63,12 @@ func SaveDataset(node *p2p.QriNode, changesPod *dataset.DatasetPod, secrets map[
 		return
 	}
 
+	if rules == nil {
+		if rules, err = base.DatasetRules(r, prevPath); err != nil {
+			return
+		}
+	}
+
 	if dryRun {
 		node.LocalStreams.Print("🏃🏽‍♀️ dry run\n")
 		// dry-runs store to an in-memory repo
@@ -53,12 +86,15 @@ func SaveDataset(node *p2p.QriNode, changesPod *dataset.DatasetPod, secrets map[
 		return
 	}
 
-	if changes.Transform != nil {
+	// sources are pulled by DatasetPodBodyFile, only scripts need executing
+	if changes.Transform != nil && changes.Transform.Syntax != base.SourceSyntax {
 		// create a check func from a record of all the parts that the datasetPod is changing,
 		// the startf package will use this function to ensure the same components aren't modified
 		mutateCheck := mutatedComponentsFunc(changesPod)
 		if changes.Transform.Script == nil {
 			if strings.HasPrefix(changes.Transform.ScriptPath, "/ipfs") || strings.HasPrefix(changes.Transform.ScriptPath, "/map") || strings.HasPrefix(changes.Transform.ScriptPath, "/cafs") {
+				// stored scripts can come from other peers, never execute them
+				ctx = RefuseExec(ctx)
 				var f cafs.File
 				f, err = node.Repo.Store().Get(changes.Transform.ScriptPath)
 				if err != nil {
@@ -84,8 +120,15 @@ func SaveDataset(node *p2p.QriNode, changesPod *dataset.DatasetPod, secrets map[
 			config = changesPod.Transform.Config
 		}
 
-		bodyFile, err = ExecTransform(node, mutable, script, prevBodyFile, secrets, config, scriptOut, mutateCheck)
+		// the previous transform is cleared by PrepareDatasetSave, runtimes are
+		// picked by the syntax of the change
+		if changes.Transform.Syntax == "" {
+			changes.Transform.Syntax = ScriptSyntax(changes.Transform.ScriptPath)
+		}
+		mutable.Transform = &dataset.Transform{Syntax: changes.Transform.Syntax}
+		bodyFile, err = ExecTransform(ctx, node, mutable, script, prevBodyFile, secrets, config, opts.Limits, scriptOut, mutateCheck)
 		if err != nil {
+			logTransformLimit(node, repo.DatasetRef{Peername: pro.Peername, Name: changesPod.Name}, err)
 			return
 		}
 		node.LocalStreams.Print("✅ transform complete\n")
@@ -98,9 +141,9 @@ func SaveDataset(node *p2p.QriNode, changesPod *dataset.DatasetPod, secrets map[
 	}
 
 	if prev.Structure != nil && changes.Structure != nil && prev.Structure.Format != changes.Structure.Format {
-		if convertFormatToPrev {
+		if opts.ConvertFormatToPrev {
 			changeBodyFile, err = base.ConvertBodyFormat(changeBodyFile, changes.Structure,
-				prev.Structure)
+				prev.Structure, "")
 			if err != nil {
 				return
 			}
@@ -121,9 +164,26 @@ func SaveDataset(node *p2p.QriNode, changesPod *dataset.DatasetPod, secrets map[
 		changes.BodyPath = ""
 		bodyFile = changeBodyFile
 	}
+	// schema rules protect consumers of a dataset from changes they can't read,
+	// so they're enforced on every save
+	if err = base.CheckSchemaRules(rules, changes, prev); err != nil {
+		return
+	}
+	if opts.Strict && len(rules) > 0 {
+		if bodyFile, err = enforceRules(node, rules, changes, prev, bodyFile); err != nil {
+			return
+		}
+	}
+
 	// let's make history, if it exists:
 	changes.PreviousPath = prevPath
-	return base.CreateDataset(r, node.LocalStreams, changesPod.Name, changes, prev, bodyFile, prevBodyFile, dryRun, pin)
+	if ref, body, err = base.CreateDataset(r, node.LocalStreams, changesPod.Name, changes, prev, bodyFile, prevBodyFile, dryRun, opts.Pin); err != nil {
+		return
+	}
+	if rules != nil {
+		err = base.PutDatasetRules(r, ref.Path, rules)
+	}
+	return
 }
 
 // for now it's very important we remove any path references before saving
@@ -146,7 +206,7 @@ func clearPaths(ds *dataset.Dataset) {
 
 // UpdateDataset brings a reference to the latest version, syncing over p2p if the reference is
 // in a peer's namespace, re-running a transform if the reference is owned by this profile
-func UpdateDataset(node *p2p.QriNode, ref *repo.DatasetRef, secrets map[string]string, scriptOut io.Writer, dryRun, pin bool) (res repo.DatasetRef, body cafs.File, err error) {
+func UpdateDataset(ctx context.Context, node *p2p.QriNode, ref *repo.DatasetRef, secrets map[string]string, limits *config.Transform, scriptOut io.Writer, dryRun, pin bool) (res repo.DatasetRef, body cafs.File, err error) {
 	if dryRun {
 		node.LocalStreams.Print("🏃🏽‍♀️ dry run\n")
 	}
@@ -177,7 +237,7 @@ func UpdateDataset(node *p2p.QriNode, ref *repo.DatasetRef, secrets map[string]s
 		return
 	}
 
-	return localUpdate(node, ref, secrets, scriptOut, dryRun, pin)
+	return localUpdate(ctx, node, ref, secrets, limits, scriptOut, dryRun, pin)
 }
 
 // localUpdate runs a transform on a local dataset and returns the new dataset ref and body
@@ -187,7 +247,7 @@ func UpdateDataset(node *p2p.QriNode, ref *repo.DatasetRef, secrets map[string]s
 // However, once we get down here, that ref actually get's written over when we
 // call base.ReadDataset. Which means if our last dataset did not have a transform, when we called
 // Update, we will error, even though we just "recalled" the transform
-func localUpdate(node *p2p.QriNode, ref *repo.DatasetRef, secrets map[string]string, scriptOut io.Writer, dryRun, pin bool) (res repo.DatasetRef, body cafs.File, err error) {
+func localUpdate(ctx context.Context, node *p2p.QriNode, ref *repo.DatasetRef, secrets map[string]string, limits *config.Transform, scriptOut io.Writer, dryRun, pin bool) (res repo.DatasetRef, body cafs.File, err error) {
 	var (
 		bodyFile, prevBodyFile cafs.File
 		commit                 = &dataset.CommitPod{}
@@ -224,30 +284,74 @@ func localUpdate(node *p2p.QriNode, ref *repo.DatasetRef, secrets map[string]str
 		return
 	}
 
-	script, err := node.Repo.Store().Get(ds.Transform.ScriptPath)
-	if err != nil {
-		log.Error(err)
-		return
-	}
-	ds.Transform.ScriptPath = script.FileName()
+	if ds.Transform.Syntax == base.SourceSyntax {
+		// datasets pulled from a source re-pull the source instead of running a script
+		source, _ := ds.Transform.Config["source"].(string)
+		node.LocalStreams.Print("🔌 pulling source\n")
+		if bodyFile, err = base.PullSource(source, secrets); err != nil {
+			log.Error(err)
+			return
+		}
+		node.LocalStreams.Print("✅ source pulled\n")
+	} else {
+		var script cafs.File
+		script, err = node.Repo.Store().Get(ds.Transform.ScriptPath)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		ds.Transform.ScriptPath = script.FileName()
 
-	node.LocalStreams.Print("🤖 executing transform\n")
+		node.LocalStreams.Print("🤖 executing transform\n")
 
-	var config map[string]interface{}
-	if ref.Dataset.Transform == nil {
-		config = make(map[string]interface{})
-	} else {
-		config = ref.Dataset.Transform.Config
+		var config map[string]interface{}
+		if ref.Dataset.Transform == nil {
+			config = make(map[string]interface{})
+		} else {
+			config = ref.Dataset.Transform.Config
+		}
+		// exec scripts only run for updates of versions this profile committed,
+		// which were checked when they were saved. callers running updates for
+		// someone else, like the API & jobs, refuse exec themselves
+		tctx := ctx
+		var pro *profile.Profile
+		if pro, err = node.Repo.Profile(); err != nil {
+			return
+		}
+		if ds.Commit == nil || ds.Commit.Author == nil || ds.Commit.Author.ID != pro.ID.String() {
+			tctx = RefuseExec(ctx)
+		}
+		bodyFile, err = ExecTransform(tctx, node, ds, script, prevBodyFile, secrets, config, limits, scriptOut, nil)
+		if err != nil {
+			logTransformLimit(node, *ref, err)
+			log.Error(err)
+			return
+		}
+		node.LocalStreams.Print("✅ transform complete\n")
 	}
-	bodyFile, err = ExecTransform(node, ds, script, prevBodyFile, secrets, config, scriptOut, nil)
+	ds.PreviousPath = ref.Path
+
+	// updates keep the rules of the previous version. no one reviews an
+	// update before it's saved, so all rules are enforced
+	rules, err := base.DatasetRules(node.Repo, ref.Path)
 	if err != nil {
-		log.Error(err)
 		return
 	}
-	node.LocalStreams.Print("✅ transform complete\n")
-	ds.PreviousPath = ref.Path
-
-	return base.CreateDataset(node.Repo, node.LocalStreams, ref.Name, ds, prev, bodyFile, prevBodyFile, dryRun, pin)
+	if err = base.CheckSchemaRules(rules, ds, prev); err != nil {
+		return
+	}
+	if len(rules) > 0 {
+		if bodyFile, err = enforceRules(node, rules, ds, prev, bodyFile); err != nil {
+			return
+		}
+	}
+	if res, body, err = base.CreateDataset(node.Repo, node.LocalStreams, ref.Name, ds, prev, bodyFile, prevBodyFile, dryRun, pin); err != nil {
+		return
+	}
+	if rules != nil && !dryRun {
+		err = base.PutDatasetRules(node.Repo, res.Path, rules)
+	}
+	return
 }
 
 // AddDataset fetches & pins a dataset to the store, adding it to the list of stored refs
@@ -2,6 +2,10 @@ package actions
 
 import (
 	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/qri-io/cafs"
@@ -20,7 +24,7 @@ func TestUpdateDatasetLocal(t *testing.T) {
 	cities := addCitiesDataset(t, node)
 
 	expect := "transform script is required to automate updates to your own datasets"
-	if _, _, err := UpdateDataset(node, &cities, nil, nil, false, true); err == nil {
+	if _, _, err := UpdateDataset(context.Background(), node, &cities, nil, nil, nil, false, true); err == nil {
 		t.Error("expected update without transform to error")
 	} else if err.Error() != expect {
 		t.Errorf("error mismatch. %s != %s", expect, err.Error())
@@ -28,7 +32,7 @@ func TestUpdateDatasetLocal(t *testing.T) {
 
 	now := addNowTransformDataset(t, node)
 	prevPath := now.Path
-	now, _, err := UpdateDataset(node, &now, nil, nil, false, false)
+	now, _, err := UpdateDataset(context.Background(), node, &now, nil, nil, nil, false, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -38,6 +42,82 @@ func TestUpdateDatasetLocal(t *testing.T) {
 	}
 }
 
+func TestUpdateDatasetSource(t *testing.T) {
+	node := newTestNode(t)
+
+	dir, err := ioutil.TempDir("", "qri_test_update_source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbPath := filepath.Join(dir, "cities.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE cities (city TEXT, pop INTEGER); INSERT INTO cities VALUES ('toronto', 40000000);`); err != nil {
+		t.Fatal(err)
+	}
+
+	dsp := &dataset.DatasetPod{
+		Name:     "source_cities",
+		BodyPath: "sqlite://" + dbPath + "?table=cities",
+	}
+	ref, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Dataset.Transform == nil || ref.Dataset.Transform.Syntax != base.SourceSyntax {
+		t.Fatalf("expected source to be recorded as the dataset transform, got: %#v", ref.Dataset.Transform)
+	}
+	if ref.Dataset.Structure.Entries != 1 {
+		t.Errorf("expected 1 entry, got: %d", ref.Dataset.Structure.Entries)
+	}
+
+	if _, err := db.Exec(`INSERT INTO cities VALUES ('chicago', 300000)`); err != nil {
+		t.Fatal(err)
+	}
+	res, _, err := UpdateDataset(context.Background(), node, &repo.DatasetRef{Peername: ref.Peername, Name: ref.Name}, nil, nil, nil, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Dataset.Structure.Entries != 2 {
+		t.Errorf("expected update to re-pull source with 2 entries, got: %d", res.Dataset.Structure.Entries)
+	}
+	if res.Dataset.PreviousPath != ref.Path {
+		t.Errorf("PreviousPath mismatch. expected: %s, got: %s", ref.Path, res.Dataset.PreviousPath)
+	}
+}
+
+func TestUpdateDatasetExec(t *testing.T) {
+	node := newTestNode(t)
+	limits := &config.Transform{AllowExec: true}
+
+	dsp := &dataset.DatasetPod{
+		Name:      "exec_update",
+		Structure: &dataset.StructurePod{Format: dataset.JSONDataFormat.String(), Schema: map[string]interface{}{"type": "array"}},
+		Transform: &dataset.TransformPod{
+			Syntax:      "exec",
+			ScriptBytes: []byte("#!/bin/sh\necho '{}'\necho '[1]'\n"),
+		},
+	}
+	ref, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true, Limits: limits})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// updates the local user runs can execute versions they committed
+	if _, _, err := UpdateDataset(context.Background(), node, &repo.DatasetRef{Peername: ref.Peername, Name: ref.Name}, nil, limits, nil, true, false); err != nil {
+		t.Errorf("expected update of an exec transform to run, got: %s", err)
+	}
+
+	expect := "exec transforms only run for scripts saved from your filesystem with transform.allowExec set in your config"
+	if _, _, err := UpdateDataset(RefuseExec(context.Background()), node, &repo.DatasetRef{Peername: ref.Peername, Name: ref.Name}, nil, limits, nil, true, false); err == nil || err.Error() != expect {
+		t.Errorf("refused update error mismatch. expected: %s, got: %v", expect, err)
+	}
+}
+
 func TestUpdateDatasetRemote(t *testing.T) {
 	ctx := context.Background()
 	factory := p2ptest.NewTestNodeFactory(p2p.NewTestableQriNode)
@@ -58,12 +138,12 @@ func TestUpdateDatasetRemote(t *testing.T) {
 	}
 
 	// run a local update to advance history
-	now0, _, err := UpdateDataset(peers[0], &now, nil, nil, false, false)
+	now0, _, err := UpdateDataset(context.Background(), peers[0], &now, nil, nil, nil, false, false)
 	if err != nil {
 		t.Error(err)
 	}
 
-	now1, _, err := UpdateDataset(peers[1], &now, nil, nil, false, false)
+	now1, _, err := UpdateDataset(context.Background(), peers[1], &now, nil, nil, nil, false, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -126,7 +206,7 @@ func TestSaveDataset(t *testing.T) {
 		BodyBytes: []byte("[]"),
 	}
 
-	ref, _, err := SaveDataset(n, ds, nil, nil, true, false, false)
+	ref, _, err := SaveDataset(context.Background(), n, ds, nil, nil, &SaveDatasetOptions{DryRun: true})
 	if err != nil {
 		t.Errorf("dry run error: %s", err.Error())
 	}
@@ -148,7 +228,7 @@ func TestSaveDataset(t *testing.T) {
 		BodyBytes: []byte("[]"),
 	}
 	// test save
-	ref, _, err = SaveDataset(n, ds, nil, nil, false, true, false)
+	ref, _, err = SaveDataset(context.Background(), n, ds, nil, nil, &SaveDatasetOptions{Pin: true})
 	if err != nil {
 		t.Error(err)
 	}
@@ -178,13 +258,13 @@ func TestSaveDataset(t *testing.T) {
 		},
 	}
 	// dryrun should work
-	ref, _, err = SaveDataset(n, ds, secrets, nil, true, false, false)
+	ref, _, err = SaveDataset(context.Background(), n, ds, secrets, nil, &SaveDatasetOptions{DryRun: true})
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// test save with transform
-	ref, _, err = SaveDataset(n, ds, secrets, nil, false, true, false)
+	ref, _, err = SaveDataset(context.Background(), n, ds, secrets, nil, &SaveDatasetOptions{Pin: true})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -203,7 +283,7 @@ func TestSaveDataset(t *testing.T) {
 		},
 	}
 
-	ref, _, err = SaveDataset(n, ds, nil, nil, false, true, false)
+	ref, _, err = SaveDataset(context.Background(), n, ds, nil, nil, &SaveDatasetOptions{Pin: true})
 	if err != nil {
 		t.Error(err)
 	}
@@ -228,7 +308,7 @@ func TestSaveDataset(t *testing.T) {
 		Transform: tfds.Transform,
 	}
 
-	ref, _, err = SaveDataset(n, ds, secrets, nil, false, true, false)
+	ref, _, err = SaveDataset(context.Background(), n, ds, secrets, nil, &SaveDatasetOptions{Pin: true})
 	if err != nil {
 		t.Error(err)
 	}
@@ -8,6 +8,18 @@ import (
 	"github.com/qri-io/qri/repo"
 )
 
+// StatsUnavailableError is returned by DiffDatasets alongside the diffs of
+// every other component when stats of either version can't be compared.
+// stats are stored locally, and can only be computed for versions who's body
+// is available
+type StatsUnavailableError struct {
+	Reason string
+}
+
+func (e StatsUnavailableError) Error() string {
+	return fmt.Sprintf("stats aren't included in the diff, they're unavailable: %s", e.Reason)
+}
+
 // DiffDatasets calculates the difference between two dataset references
 func DiffDatasets(node *p2p.QriNode, leftRef, rightRef repo.DatasetRef, all bool, components map[string]bool) (diffs map[string]*dsdiff.SubDiff, err error) {
 	if leftRef.IsEmpty() || rightRef.IsEmpty() {
@@ -41,6 +53,13 @@ func DiffDatasets(node *p2p.QriNode, leftRef, rightRef repo.DatasetRef, all bool
 			err = fmt.Errorf("error diffing datasets: %s", err.Error())
 			return
 		}
+		// stats are only stored locally, skip them if either side's body isn't
+		// available to profile, reporting why
+		if statsDiffs, e := DiffStats(node, leftRef, rightRef); e == nil {
+			diffs["stats"] = statsDiffs
+		} else {
+			err = StatsUnavailableError{Reason: e.Error()}
+		}
 		// TODO: remove this temporary hack
 		if diffs["data"] == nil || len(diffs["data"].Deltas()) == 0 {
 			// dereference data paths
@@ -88,6 +107,13 @@ func DiffDatasets(node *p2p.QriNode, leftRef, rightRef repo.DatasetRef, all bool
 						}
 						diffs[k] = metaDiffs
 					}
+				case "stats":
+					statsDiffs, e := DiffStats(node, leftRef, rightRef)
+					if e != nil {
+						err = fmt.Errorf("error diffing %s: %s", k, e.Error())
+						return
+					}
+					diffs[k] = statsDiffs
 				case "viz":
 					if dsLeft.Viz != nil && dsRight.Viz != nil {
 						vizDiffs, e := dsdiff.DiffViz(dsLeft.Viz, dsRight.Viz)
@@ -2,6 +2,8 @@ package actions
 
 import (
 	"testing"
+
+	"github.com/qri-io/qri/base"
 )
 
 func TestDiffDatasets(t *testing.T) {
@@ -17,3 +19,42 @@ func TestDiffDatasets(t *testing.T) {
 		t.Error("expected some diffs")
 	}
 }
+
+func TestDiffDatasetsStats(t *testing.T) {
+	node := newTestNode(t)
+	cities := addCitiesDataset(t, node)
+	fc := addFlourinatedCompoundsDataset(t, node)
+
+	diffs, err := DiffDatasets(node, cities, fc, false, map[string]bool{"stats": true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if diffs["stats"] == nil {
+		t.Error("expected a stats diff")
+	}
+}
+
+func TestDiffDatasetsStatsUnavailable(t *testing.T) {
+	node := newTestNode(t)
+	cities := addCitiesDataset(t, node)
+	fc := addFlourinatedCompoundsDataset(t, node)
+
+	// without a local body, stats can't be computed
+	if err := base.ReadDataset(node.Repo, &fc); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.Repo.Store().Delete(fc.Dataset.BodyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := DiffDatasets(node, cities, fc, true, nil)
+	if _, ok := err.(StatsUnavailableError); !ok {
+		t.Fatalf("expected a StatsUnavailableError, got: %v", err)
+	}
+	if diffs["stats"] != nil {
+		t.Error("expected no stats diff")
+	}
+	if len(diffs) == 0 {
+		t.Error("expected other components to be diffed")
+	}
+}
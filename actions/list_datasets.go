@@ -10,7 +10,7 @@ import (
 )
 
 // ListDatasets lists a peer's datasets
-func ListDatasets(node *p2p.QriNode, ds *repo.DatasetRef, limit, offset int, RPC, publishedOnly bool) (res []repo.DatasetRef, err error) {
+func ListDatasets(node *p2p.QriNode, ds *repo.DatasetRef, limit, offset int, publishedOnly bool) (res []repo.DatasetRef, err error) {
 	r := node.Repo
 	pro, err := r.Profile()
 	if err != nil {
@@ -63,16 +63,8 @@ func ListDatasets(node *p2p.QriNode, ds *repo.DatasetRef, limit, offset int, RPC
 		if err != nil {
 			return nil, fmt.Errorf("error requesting dataset list: %s", err.Error())
 		}
-		// TODO - for now we're removing schemas b/c they don't serialize properly over RPC
-		if RPC {
-			for _, rep := range res {
-				if rep.Dataset.Structure != nil {
-					rep.Dataset.Structure.Schema = nil
-				}
-			}
-		}
 		return
 	}
 
-	return base.ListDatasets(node.Repo, limit, offset, RPC, publishedOnly)
+	return base.ListDatasets(node.Repo, limit, offset, publishedOnly)
 }
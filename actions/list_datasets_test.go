@@ -10,7 +10,7 @@ func TestListDatasets(t *testing.T) {
 	node := newTestNode(t)
 	addCitiesDataset(t, node)
 
-	res, err := ListDatasets(node, &repo.DatasetRef{Peername: "me"}, 1, 0, false, false)
+	res, err := ListDatasets(node, &repo.DatasetRef{Peername: "me"}, 1, 0, false)
 	if err != nil {
 		t.Error(err.Error())
 	}
@@ -24,7 +24,7 @@ func TestListDatasetsNotFound(t *testing.T) {
 	node := newTestNode(t)
 	addCitiesDataset(t, node)
 
-	_, err := ListDatasets(node, &repo.DatasetRef{Peername: "not_found"}, 1, 0, false, false)
+	_, err := ListDatasets(node, &repo.DatasetRef{Peername: "not_found"}, 1, 0, false)
 	if err == nil {
 		t.Error("expected to get error")
 	}
@@ -0,0 +1,18 @@
+package actions
+
+import "github.com/qri-io/qri/metrics"
+
+var (
+	transformDuration = metrics.NewHistogramVec("qri_transform_duration_seconds",
+		"time taken to execute transform scripts, by result. result is succeeded or failed",
+		[]float64{.1, .5, 1, 5, 10, 30, 60, 300}, "result")
+	transformFailures = metrics.NewCounterVec("qri_transform_failures_total",
+		"transform scripts that returned an error")
+	transformsAbandoned = metrics.NewCounterVec("qri_transforms_abandoned_total",
+		"transforms given up on by a timeout or cancel, by state. state is abandoned when given up on, finished when the script later returns. the difference is scripts still running",
+		"state")
+)
+
+func init() {
+	metrics.MustRegister(transformDuration, transformFailures, transformsAbandoned)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/dataset/dsfs"
 	"github.com/qri-io/dataset/subset"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/p2p"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/repo/profile"
@@ -26,6 +27,9 @@ func Publish(node *p2p.QriNode, ref repo.DatasetRef) (err error) {
 	if err = permission(r, ref); err != nil {
 		return
 	}
+	if err = base.CheckPublishable(r, ref); err != nil {
+		return
+	}
 
 	enc := ds.Encode()
 	enc.Name = ref.Name
@@ -1,10 +1,14 @@
 package actions
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/qri-io/dataset"
 	"github.com/qri-io/dataset/dsfs"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/repo"
 	regmock "github.com/qri-io/registry/regserver/mock"
 )
@@ -74,3 +78,34 @@ func TestRegistry(t *testing.T) {
 		t.Errorf("RegistryList should return one dataset, currently returns %d", len(refs))
 	}
 }
+
+func TestPublishFailingRules(t *testing.T) {
+	reg := regmock.NewMemRegistry()
+	regClient, regServer := regmock.NewMockServerRegistry(reg)
+	defer regServer.Close()
+
+	node := newTestNodeRegClient(t, regClient)
+	dsp := &dataset.DatasetPod{
+		Name:      "dupes",
+		Structure: &dataset.StructurePod{Format: dataset.JSONDataFormat.String(), Schema: map[string]interface{}{"type": "array"}},
+		BodyBytes: []byte(`[{"city":"toronto"},{"city":"toronto"}]`),
+	}
+	rules := []*base.Rule{{Type: base.RuleUnique, Columns: []string{"city"}}}
+	ref, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true, Rules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// registry publishing is held to the same rules as setting publish status
+	err = Publish(node, ref)
+	if err == nil || !strings.HasPrefix(err.Error(), "can't publish") {
+		t.Errorf("expected publishing a version that fails it's rules to error, got: %v", err)
+	}
+	refs, err := RegistryList(node, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 0 {
+		t.Errorf("expected no published datasets, got: %d", len(refs))
+	}
+}
@@ -0,0 +1,54 @@
+package actions
+
+import (
+	"io/ioutil"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsfs"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+)
+
+// CheckRules checks a dataset version against data quality rules, resolving
+// the reference with DatasetHead. nil rules check the rules stored with the
+// version
+func CheckRules(node *p2p.QriNode, ref *repo.DatasetRef, rules []*base.Rule) ([]*base.RuleResult, error) {
+	if err := DatasetHead(node, ref); err != nil {
+		return nil, err
+	}
+	return base.CheckDatasetRules(node.Repo, *ref, rules)
+}
+
+// enforceRules checks the body a version is about to be saved with against
+// rules, erroring if any rule fails. a nil body keeps the body of prev.
+// checking reads the body, so enforceRules returns a copy of it to save
+func enforceRules(node *p2p.QriNode, rules []*base.Rule, ds, prev *dataset.Dataset, body cafs.File) (cafs.File, error) {
+	var check cafs.File
+	if body == nil {
+		f, err := dsfs.LoadBody(node.Repo.Store(), prev)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		check = f
+	} else {
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		body = cafs.NewMemfileBytes(body.FileName(), data)
+		check = cafs.NewMemfileBytes(body.FileName(), data)
+	}
+
+	results, err := base.CheckRules(node.Repo, rules, ds, prev, check)
+	if err != nil {
+		return nil, err
+	}
+	if err := base.RulesError(results); err != nil {
+		return nil, err
+	}
+	node.LocalStreams.Print("✅ quality rules passed\n")
+	return body, nil
+}
@@ -0,0 +1,150 @@
+package actions
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/repo"
+)
+
+func TestSaveDatasetRules(t *testing.T) {
+	node := newTestNode(t)
+	rules := []*base.Rule{{Type: base.RuleUnique, Columns: []string{"city"}}}
+
+	dsp := &dataset.DatasetPod{
+		Name:      "rules_test",
+		Structure: &dataset.StructurePod{Format: dataset.JSONDataFormat.String(), Schema: map[string]interface{}{"type": "array"}},
+		BodyBytes: []byte(`[{"city":"toronto"},{"city":"chicago"}]`),
+	}
+	ref, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true, Rules: rules, Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// new versions keep the rules of the previous version
+	dsp = &dataset.DatasetPod{
+		Peername:  ref.Peername,
+		Name:      ref.Name,
+		BodyBytes: []byte(`[{"city":"toronto"},{"city":"toronto"}]`),
+	}
+	expect := "1 of 1 quality rules failed:\n  unique(city): 1 duplicate values, first at entry 1: [\"toronto\"]"
+	if _, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true, Strict: true}); err == nil || err.Error() != expect {
+		t.Errorf("strict save error mismatch. expected: %s, got: %v", expect, err)
+	}
+
+	// saves that aren't strict store versions that break rules
+	dsp = &dataset.DatasetPod{
+		Peername:  ref.Peername,
+		Name:      ref.Name,
+		BodyBytes: []byte(`[{"city":"toronto"},{"city":"toronto"}]`),
+	}
+	if ref, _, err = SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := CheckRules(node, &ref, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Passed {
+		t.Errorf("expected carried over rule to fail, got: %v", results)
+	}
+
+	// checking other rules doesn't need them to be stored
+	results, err = CheckRules(node, &ref, []*base.Rule{{Type: base.RuleUnique, Columns: []string{"missing"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Passed {
+		t.Errorf("expected rule to pass, got: %v", results)
+	}
+}
+
+func TestSaveDatasetSchemaRules(t *testing.T) {
+	node := newTestNode(t)
+	rules := []*base.Rule{{Type: base.RuleSchema, Policy: base.SchemaPolicyForward}}
+	schema := func(cols ...string) map[string]interface{} {
+		items := []interface{}{}
+		for _, col := range cols {
+			items = append(items, map[string]interface{}{"title": col, "type": "string"})
+		}
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "array", "items": items}}
+	}
+
+	dsp := &dataset.DatasetPod{
+		Name:      "schema_rules_test",
+		Structure: &dataset.StructurePod{Format: dataset.JSONDataFormat.String(), Schema: schema("city", "country")},
+		BodyBytes: []byte(`[["toronto","canada"]]`),
+	}
+	ref, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true, Rules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// schema rules are enforced without a strict save
+	dsp = &dataset.DatasetPod{
+		Peername:  ref.Peername,
+		Name:      ref.Name,
+		Structure: &dataset.StructurePod{Format: dataset.JSONDataFormat.String(), Schema: schema("city")},
+		BodyBytes: []byte(`[["toronto"]]`),
+	}
+	expect := "schema changes break forward compatibility:\n  removed column country"
+	if _, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true}); err == nil || err.Error() != expect {
+		t.Errorf("save error mismatch. expected: %s, got: %v", expect, err)
+	}
+
+	dsp = &dataset.DatasetPod{
+		Peername:  ref.Peername,
+		Name:      ref.Name,
+		Structure: &dataset.StructurePod{Format: dataset.JSONDataFormat.String(), Schema: schema("city", "country", "pop")},
+		BodyBytes: []byte(`[["toronto","canada","100"]]`),
+	}
+	if _, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true}); err != nil {
+		t.Errorf("expected adding a column to keep forward compatibility, got: %s", err)
+	}
+}
+
+func TestUpdateDatasetRules(t *testing.T) {
+	node := newTestNode(t)
+
+	dir, err := ioutil.TempDir("", "qri_test_update_rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbPath := filepath.Join(dir, "cities.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE cities (city TEXT, pop INTEGER); INSERT INTO cities VALUES ('toronto', 40000000);`); err != nil {
+		t.Fatal(err)
+	}
+
+	dsp := &dataset.DatasetPod{
+		Name:     "update_rules_test",
+		BodyPath: "sqlite://" + dbPath + "?table=cities",
+	}
+	rules := []*base.Rule{{Type: base.RuleUnique, Columns: []string{"city"}}}
+	ref, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true, Rules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// updates enforce stored rules without a strict flag
+	if _, err := db.Exec(`INSERT INTO cities VALUES ('toronto', 300000)`); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = UpdateDataset(context.Background(), node, &repo.DatasetRef{Peername: ref.Peername, Name: ref.Name}, nil, nil, nil, false, false)
+	if err == nil || !strings.HasPrefix(err.Error(), "1 of 1 quality rules failed") {
+		t.Errorf("expected update breaking a rule to fail, got: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package actions
+
+import (
+	"encoding/json"
+
+	"github.com/qri-io/dsdiff"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+)
+
+// DatasetStats gets the column statistics of a dataset version, resolving the
+// reference with DatasetHead
+func DatasetStats(node *p2p.QriNode, ref *repo.DatasetRef) (*base.Stats, error) {
+	if err := DatasetHead(node, ref); err != nil {
+		return nil, err
+	}
+	return base.DatasetStats(node.Repo, *ref)
+}
+
+// DiffStats compares the column statistics of two dataset versions, which
+// shows how the data of a dataset has drifted between versions
+func DiffStats(node *p2p.QriNode, left, right repo.DatasetRef) (*dsdiff.SubDiff, error) {
+	ls, err := base.DatasetStats(node.Repo, left)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := base.DatasetStats(node.Repo, right)
+	if err != nil {
+		return nil, err
+	}
+
+	ldata, err := json.Marshal(ls)
+	if err != nil {
+		return nil, err
+	}
+	rdata, err := json.Marshal(rs)
+	if err != nil {
+		return nil, err
+	}
+	return dsdiff.DiffJSON(ldata, rdata, "stats")
+}
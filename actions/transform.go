@@ -1,13 +1,15 @@
 package actions
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/p2p"
-	"github.com/qri-io/startf"
 )
 
 func mutatedComponentsFunc(dsp *dataset.DatasetPod) func(path ...string) error {
@@ -39,40 +41,78 @@ please adjust either the transform script or remove the supplied '%s'`, path[0],
 	}
 }
 
-// ExecTransform executes a designated transformation
-func ExecTransform(node *p2p.QriNode, ds *dataset.Dataset, script, bodyFile cafs.File, secrets map[string]string, config map[string]interface{}, scriptOut io.Writer, mutateCheck func(...string) error) (file cafs.File, err error) {
+// ExecTransform executes a designated transformation with the runtime
+// registered for the syntax of ds.Transform, see TransformRuntime. limits bound the
+// resources the transform can use, exceeding one returns a
+// *TransformLimitError. exec transforms only run if limits allow them and ctx
+// doesn't refuse them, see RefuseExec
+func ExecTransform(ctx context.Context, node *p2p.QriNode, ds *dataset.Dataset, script, bodyFile cafs.File, secrets map[string]string, config map[string]interface{}, limits *config.Transform, scriptOut io.Writer, mutateCheck func(...string) error) (file cafs.File, err error) {
 	// filepath := ds.Transform.ScriptPath
 
 	// TODO - consider making this a standard method on dataset.Transform:
 	// script := cafs.NewMemfileReader(ds.Transform.ScriptPath, ds.Transform.Script)
 
+	limiter, err := newTransformLimiter(limits)
+	if err != nil {
+		return nil, err
+	}
+	return execTransform(ctx, node, ds, script, bodyFile, secrets, config, limiter, scriptOut, mutateCheck)
+}
+
+// execTransform executes a transform with the runtime for it's syntax,
+// enforcing the limits of limiter. every runtime's component changes are
+// checked with mutateCheck
+func execTransform(ctx context.Context, node *p2p.QriNode, ds *dataset.Dataset, script, bodyFile cafs.File, secrets map[string]string, config map[string]interface{}, limiter *transformLimiter, scriptOut io.Writer, mutateCheck func(...string) error) (file cafs.File, err error) {
 	if ds.Transform == nil {
 		ds.Transform = &dataset.Transform{}
 	}
 	ds.Transform.Config = config
 
-	setSecrets := func(o *startf.ExecOpts) {
-		if secrets != nil {
-			// convert to map[string]interface{}, which the lower-level startf supports
-			// until we're sure map[string]string is going to work in the majority of use cases
-			s := map[string]interface{}{}
-			for key, val := range secrets {
-				s[key] = val
+	rt, err := transformRuntime(ds.Transform.Syntax)
+	if err != nil {
+		return nil, err
+	}
+	in := &TransformInput{
+		Node:           node,
+		Dataset:        ds,
+		Script:         script,
+		PrevBody:       bodyFile,
+		Config:         config,
+		Secrets:        secrets,
+		AllowedHosts:   limiter.hostNames,
+		Recorded:       limiter.cassette != nil,
+		AllowExec:      limiter.allowExec && !execRefused(ctx),
+		MaxOutputBytes: limiter.maxBytes,
+		Output:         scriptOut,
+	}
+
+	start := time.Now()
+	var changed []string
+	file, err = limiter.exec(ctx, func(ctx context.Context) (cafs.File, error) {
+		in.Ctx = ctx
+		out, err := rt.ExecTransform(in)
+		if err != nil {
+			return nil, err
+		}
+		changed = out.Changed
+		return out.Body, nil
+	})
+	if err == nil && mutateCheck != nil {
+		for _, component := range changed {
+			if err = mutateCheck(component); err != nil {
+				break
 			}
-			o.Secrets = s
 		}
 	}
-
-	configs := []func(*startf.ExecOpts){
-		startf.AddQriNodeOpt(node),
-		startf.AddMutateFieldCheck(mutateCheck),
-		startf.SetOutWriter(scriptOut),
-		setSecrets,
+	if err == nil {
+		file, err = limiter.checkBody(ds.Structure, file)
 	}
-
-	if file, err = startf.ExecScript(ds, script, bodyFile, configs...); err != nil {
+	if err != nil {
+		transformDuration.With("failed").ObserveSince(start)
+		transformFailures.With().Inc()
 		return nil, err
 	}
+	transformDuration.With("succeeded").ObserveSince(start)
 
 	return
 }
@@ -0,0 +1,421 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dsdiff"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+)
+
+// TransformFixture holds the inputs of a transform test, and assertions on
+// it's output
+type TransformFixture struct {
+	// PrevBody replaces the body of the previous version given to the transform
+	PrevBody interface{} `json:"prevBody,omitempty"`
+	// Config replaces the config of the previous version's transform
+	Config map[string]interface{} `json:"config,omitempty"`
+	// Secrets are given to the transform in place of real secrets
+	Secrets map[string]string `json:"secrets,omitempty"`
+	// Cassette is the path to a file of recorded http responses. transforms
+	// tested with a cassette file that doesn't exist yet make real requests,
+	// which are recorded to the file
+	Cassette string `json:"cassette,omitempty"`
+	// Expect asserts on the output of the transform
+	Expect *TransformExpectation `json:"expect,omitempty"`
+}
+
+// TransformExpectation is the expected output of a transform. empty
+// expectations aren't checked
+type TransformExpectation struct {
+	// Body is the exact body the transform should output
+	Body interface{} `json:"body,omitempty"`
+	// Structure is structure fields the output should have, fields that aren't
+	// listed can have any value
+	Structure map[string]interface{} `json:"structure,omitempty"`
+}
+
+// ParseTransformFixture reads a transform fixture from JSON or YAML
+func ParseTransformFixture(data []byte) (*TransformFixture, error) {
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading transform fixture: %s", err.Error())
+	}
+	fixture := &TransformFixture{}
+	if err := json.Unmarshal(data, fixture); err != nil {
+		return nil, fmt.Errorf("error reading transform fixture: %s", err.Error())
+	}
+	return fixture, nil
+}
+
+// TransformTestResult is the outcome of running a transform against a fixture
+type TransformTestResult struct {
+	// Body is the body the transform output, decoded from JSON
+	Body      interface{}        `json:"body"`
+	Structure *dataset.Structure `json:"structure"`
+	// Failures describes each expectation the output didn't meet, a test
+	// passes when there are no failures
+	Failures []string `json:"failures,omitempty"`
+	// ExpectDiffs compare the expected output to the actual output of
+	// expectations that failed
+	ExpectDiffs map[string]*dsdiff.SubDiff `json:"expectDiffs,omitempty"`
+	// Diffs compare the output to the previous version the transform ran on
+	Diffs map[string]*dsdiff.SubDiff `json:"diffs,omitempty"`
+	// Recorded is the number of requests recorded to a new cassette
+	Recorded int `json:"recorded,omitempty"`
+}
+
+// TestTransform runs a transform script against the inputs of a fixture
+// without saving. the transform runs on the head of ref, which is optional.
+// output is checked against the fixture's expectations & diffed against the
+// previous version. an empty syntax uses the syntax of ref's transform,
+// falling back to the script's file extension, see ScriptSyntax
+func TestTransform(ctx context.Context, node *p2p.QriNode, script cafs.File, syntax string, fixture *TransformFixture, ref *repo.DatasetRef, limits *config.Transform, scriptOut io.Writer) (*TransformTestResult, error) {
+	if fixture == nil {
+		fixture = &TransformFixture{}
+	}
+	limiter, err := newTransformLimiter(limits)
+	if err != nil {
+		return nil, err
+	}
+	if fixture.Cassette != "" {
+		if limiter.cassette, err = loadCassette(fixture.Cassette); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		prev     = &dataset.Dataset{}
+		ds       = &dataset.Dataset{}
+		prevBody cafs.File
+		prevPath string
+	)
+	if ref != nil && !ref.IsEmpty() {
+		if err = repo.CanonicalizeDatasetRef(node.Repo, ref); err == repo.ErrNotFound {
+			return nil, fmt.Errorf("unknown dataset '%s'", ref.AliasString())
+		} else if err != nil {
+			return nil, err
+		}
+		if prev, ds, prevBody, prevPath, err = base.PrepareDatasetSave(node.Repo, ref.Peername, ref.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if fixture.PrevBody != nil {
+		if ds.Structure == nil {
+			ds.Structure = &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray}
+			if _, ok := fixture.PrevBody.(map[string]interface{}); ok {
+				ds.Structure.Schema = dataset.BaseSchemaObject
+			}
+		}
+		if prevBody, err = fixtureBodyFile(ds.Structure, fixture.PrevBody); err != nil {
+			return nil, err
+		}
+	}
+
+	// the previous body is read by both the transform & the diff
+	var prevValue interface{}
+	if prevBody != nil {
+		data, err := ioutil.ReadAll(prevBody)
+		if err != nil {
+			return nil, err
+		}
+		if prevValue, err = bodyValue(ds.Structure, cafs.NewMemfileBytes(prevBody.FileName(), data)); err != nil {
+			return nil, fmt.Errorf("error reading previous body: %s", err.Error())
+		}
+		prevBody = cafs.NewMemfileBytes(prevBody.FileName(), data)
+	}
+
+	config := fixture.Config
+	if config == nil && prev.Transform != nil {
+		config = prev.Transform.Config
+	}
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+
+	if syntax == "" && prev.Transform != nil && prev.Transform.Syntax != base.SourceSyntax {
+		syntax = prev.Transform.Syntax
+	}
+	if syntax == "" {
+		syntax = ScriptSyntax(script.FileName())
+	}
+	ds.Transform = &dataset.Transform{Syntax: syntax, ScriptPath: script.FileName()}
+	mutateCheck := mutatedComponentsFunc(&dataset.DatasetPod{})
+	file, err := execTransform(ctx, node, ds, script, prevBody, fixture.Secrets, config, limiter, scriptOut, mutateCheck)
+	if c := limiter.cassette; c != nil {
+		// transforms often fail on a response that's missing, so misses are
+		// reported instead of the transform's error
+		if missed := c.missedRequest(); missed != "" {
+			return nil, fmt.Errorf("cassette %s has no response for %s", c.path, missed)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &TransformTestResult{Structure: ds.Structure, Body: prevValue}
+	if file != nil {
+		if res.Body, err = bodyValue(ds.Structure, file); err != nil {
+			return nil, fmt.Errorf("error reading transform body: %s", err.Error())
+		}
+	}
+	if fixture.Expect != nil {
+		if err = checkTransformExpectation(fixture.Expect, res); err != nil {
+			return nil, err
+		}
+	}
+
+	res.Diffs = map[string]*dsdiff.SubDiff{}
+	if prevPath != "" {
+		clearPaths(prev)
+		clearPaths(ds)
+		if prev.Structure != nil && ds.Structure != nil {
+			if res.Diffs["structure"], err = dsdiff.DiffStructure(prev.Structure, ds.Structure); err != nil {
+				return nil, fmt.Errorf("error diffing structure: %s", err.Error())
+			}
+		}
+		if prev.Meta != nil && ds.Meta != nil {
+			if res.Diffs["meta"], err = dsdiff.DiffMeta(prev.Meta, ds.Meta); err != nil {
+				return nil, fmt.Errorf("error diffing meta: %s", err.Error())
+			}
+		}
+	}
+	if prevValue != nil && res.Body != nil {
+		if res.Diffs["data"], err = diffBodies(prevValue, res.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	if c := limiter.cassette; c != nil && c.recording {
+		if res.Recorded, err = c.save(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// checkTransformExpectation adds a failure to res for each expectation res
+// doesn't meet
+func checkTransformExpectation(expect *TransformExpectation, res *TransformTestResult) error {
+	if expect.Body != nil && !reflect.DeepEqual(expect.Body, res.Body) {
+		res.Failures = append(res.Failures, "body doesn't match the expected body")
+		diff, err := diffBodies(expect.Body, res.Body)
+		if err != nil {
+			return err
+		}
+		res.ExpectDiffs = map[string]*dsdiff.SubDiff{"data": diff}
+	}
+
+	if len(expect.Structure) > 0 {
+		got := map[string]interface{}{}
+		if res.Structure != nil {
+			data, err := json.Marshal(res.Structure)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(data, &got); err != nil {
+				return err
+			}
+		}
+
+		fields := make([]string, 0, len(expect.Structure))
+		for field := range expect.Structure {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if !reflect.DeepEqual(expect.Structure[field], got[field]) {
+				exp, _ := json.Marshal(expect.Structure[field])
+				act, _ := json.Marshal(got[field])
+				res.Failures = append(res.Failures, fmt.Sprintf("structure %s: expected %s, got %s", field, exp, act))
+			}
+		}
+	}
+	return nil
+}
+
+// diffBodies compares two bodies decoded from JSON. dsdiff compares objects,
+// so bodies are diffed as the "data" field of an object
+func diffBodies(a, b interface{}) (*dsdiff.SubDiff, error) {
+	adata, err := json.Marshal(map[string]interface{}{"data": a})
+	if err != nil {
+		return nil, err
+	}
+	bdata, err := json.Marshal(map[string]interface{}{"data": b})
+	if err != nil {
+		return nil, err
+	}
+	diff, err := dsdiff.DiffJSON(adata, bdata, "data")
+	if err != nil {
+		return nil, fmt.Errorf("error diffing data: %s", err.Error())
+	}
+	return diff, nil
+}
+
+// fixtureBodyFile encodes a body decoded from JSON in the format of st
+func fixtureBodyFile(st *dataset.Structure, body interface{}) (cafs.File, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	file := cafs.NewMemfileBytes("body.json", data)
+	if st.Format == dataset.JSONDataFormat {
+		return file, nil
+	}
+	return base.ConvertBodyFormat(file, &dataset.Structure{Format: dataset.JSONDataFormat, Schema: st.Schema}, st, "")
+}
+
+// bodyValue decodes a body with structure st into JSON values
+func bodyValue(st *dataset.Structure, file cafs.File) (interface{}, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if st == nil || st.Format == dataset.JSONDataFormat {
+		data, err = ioutil.ReadAll(file)
+	} else {
+		data, err = base.ConvertBodyPage(file, st, &dataset.Structure{Format: dataset.JSONDataFormat, Schema: st.Schema}, 0, 0, true, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// cassette records the http requests a transform makes, replaying the
+// recorded responses on later runs so transforms can be tested without the
+// network. requests are matched to responses by method & url, in the order
+// they were recorded
+type cassette struct {
+	path string
+	// recording is true for cassettes that don't have a file yet
+	recording bool
+
+	lock         sync.Mutex
+	interactions []*cassetteInteraction
+	played       map[int]bool
+	missed       string
+}
+
+// cassetteInteraction is a request & it's recorded response
+type cassetteInteraction struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// loadCassette reads a cassette file, a file that doesn't exist starts a new
+// recording
+func loadCassette(path string) (*cassette, error) {
+	c := &cassette{path: path, played: map[int]bool{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		c.recording = true
+		return c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &c.interactions); err != nil {
+		return nil, fmt.Errorf("error reading cassette %s: %s", path, err.Error())
+	}
+	return c, nil
+}
+
+// roundTrip records requests sent to next, or replays a recorded response
+func (c *cassette) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if c.recording {
+		res, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		c.lock.Lock()
+		c.interactions = append(c.interactions, &cassetteInteraction{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Status: res.StatusCode,
+			Header: res.Header,
+			Body:   string(body),
+		})
+		c.lock.Unlock()
+		return res, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, in := range c.interactions {
+		if c.played[i] || in.Method != req.Method || in.URL != req.URL.String() {
+			continue
+		}
+		c.played[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Header,
+			Body:          ioutil.NopCloser(bytes.NewBufferString(in.Body)),
+			ContentLength: int64(len(in.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	missed := fmt.Sprintf("%s %s", req.Method, req.URL.String())
+	if c.missed == "" {
+		c.missed = missed
+	}
+	return nil, fmt.Errorf("cassette %s has no response for %s", c.path, missed)
+}
+
+// missedRequest gives the first request the cassette had no response for
+func (c *cassette) missedRequest() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.missed
+}
+
+// save writes recorded requests to the cassette's file, returning the number
+// of requests recorded
+func (c *cassette) save() (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.interactions == nil {
+		c.interactions = []*cassetteInteraction{}
+	}
+	data, err := json.MarshalIndent(c.interactions, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := ioutil.WriteFile(c.path, data, 0644); err != nil {
+		return 0, fmt.Errorf("error writing cassette: %s", err.Error())
+	}
+	return len(c.interactions), nil
+}
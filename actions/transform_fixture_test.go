@@ -0,0 +1,140 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/repo"
+)
+
+func TestParseTransformFixture(t *testing.T) {
+	data := []byte(`
+prevBody: [1, 2]
+config:
+  page: 3
+secrets:
+  token: abc
+cassette: requests.json
+expect:
+  body: [1, 2, 3]
+  structure:
+    format: json
+`)
+	fixture, err := ParseTransformFixture(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fixture.PrevBody, []interface{}{1.0, 2.0}) {
+		t.Errorf("prevBody mismatch, got: %v", fixture.PrevBody)
+	}
+	if fixture.Secrets["token"] != "abc" || fixture.Config["page"] != 3.0 || fixture.Cassette != "requests.json" {
+		t.Errorf("fixture input mismatch, got: %#v", fixture)
+	}
+	if fixture.Expect == nil || fixture.Expect.Structure["format"] != "json" {
+		t.Errorf("expected structure format expectation, got: %#v", fixture.Expect)
+	}
+
+	if _, err := ParseTransformFixture([]byte("secrets: [")); err == nil {
+		t.Error("expected invalid fixture to error")
+	}
+}
+
+func TestTestTransform(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[3]`))
+	}))
+
+	dir, err := ioutil.TempDir("", "transform_fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	node := newTestNode(t)
+	script := fmt.Sprintf(`load("http.star", "http")
+def download(ctx):
+  return http.get("%s/values").json()
+
+def transform(ds, ctx):
+  ds.set_body(ds.get_body() + ctx.download)
+`, s.URL)
+	fixture := &TransformFixture{
+		PrevBody: []interface{}{1.0, 2.0},
+		Cassette: filepath.Join(dir, "cassette.json"),
+		Expect:   &TransformExpectation{Body: []interface{}{1.0, 2.0, 3.0}},
+	}
+
+	// a cassette that doesn't exist records requests
+	res, err := TestTransform(context.Background(), node, cafs.NewMemfileBytes("transform.star", []byte(script)), "", fixture, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Recorded != 1 {
+		t.Errorf("expected 1 request recorded, got: %d", res.Recorded)
+	}
+	if len(res.Failures) > 0 {
+		t.Errorf("expected no failures, got: %v", res.Failures)
+	}
+	if res.Diffs["data"] == nil {
+		t.Error("expected a diff against the previous body")
+	}
+
+	// recorded cassettes replay without the server
+	s.Close()
+	fixture.Expect.Body = []interface{}{1.0, 2.0}
+	res, err = TestTransform(context.Background(), node, cafs.NewMemfileBytes("transform.star", []byte(script)), "", fixture, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Recorded != 0 {
+		t.Errorf("expected the cassette to be replayed, got %d recorded", res.Recorded)
+	}
+	if len(res.Failures) != 1 || res.ExpectDiffs["data"] == nil {
+		t.Errorf("expected a body failure with a diff, got: %v", res.Failures)
+	}
+
+	if err := ioutil.WriteFile(fixture.Cassette, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = TestTransform(context.Background(), node, cafs.NewMemfileBytes("transform.star", []byte(script)), "", fixture, nil, nil, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), fmt.Sprintf("cassette %s has no response for GET %s/values", fixture.Cassette, s.URL)) {
+		t.Errorf("expected a missing cassette response error, got: %v", err)
+	}
+}
+
+func TestTestTransformHead(t *testing.T) {
+	node := newTestNode(t)
+	ref := addCitiesDataset(t, node)
+
+	script := []byte("def transform(ds, ctx):\n  ds.set_body([[\"toronto\", 40000000, 55.5, False]])\n")
+	fixture := &TransformFixture{
+		Expect: &TransformExpectation{Structure: map[string]interface{}{"format": "json", "depth": 2.0}},
+	}
+	res, err := TestTransform(context.Background(), node, cafs.NewMemfileBytes("transform.star", script), "", fixture, &repo.DatasetRef{Peername: ref.Peername, Name: ref.Name}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Structure == nil || res.Structure.Format != dataset.CSVDataFormat {
+		t.Errorf("expected the transform to run on the head structure, got: %v", res.Structure)
+	}
+	if res.Diffs["data"] == nil {
+		t.Error("expected a diff against the head body")
+	}
+	if len(res.Failures) != 1 || res.Failures[0] != `structure format: expected "json", got "csv"` {
+		t.Errorf("expected a structure format failure, got: %v", res.Failures)
+	}
+
+	if _, err := TestTransform(context.Background(), node, cafs.NewMemfileBytes("transform.star", script), "", nil, &repo.DatasetRef{Peername: "me", Name: "unknown"}, nil, nil); err == nil {
+		t.Error("expected testing against an unknown dataset to error")
+	}
+}
@@ -0,0 +1,340 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+)
+
+// TransformLimitError is the error of a transform stopped for exceeding one
+// of it's limits
+type TransformLimitError struct {
+	// Limit is the name of the exceeded config.Transform field
+	Limit   string `json:"limit"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e *TransformLimitError) Error() string {
+	return e.Message
+}
+
+// logTransformLimit records transforms stopped by a limit in the event log
+func logTransformLimit(node *p2p.QriNode, ref repo.DatasetRef, err error) {
+	le, ok := err.(*TransformLimitError)
+	if !ok {
+		return
+	}
+	if el, ok := node.Repo.(repo.DetailedEventLog); ok {
+		err = el.LogEventDetails(repo.ETTransformLimited, time.Now().Unix(), node.ID, ref, le)
+	} else {
+		err = node.Repo.LogEvent(repo.ETTransformLimited, ref)
+	}
+	if err != nil {
+		log.Debugf("error logging transform limit: %s", err.Error())
+	}
+}
+
+// transformLimiter enforces the limits of a config.Transform
+type transformLimiter struct {
+	timeout  time.Duration
+	maxRows  int
+	maxBytes int
+	hosts    map[string]bool
+	// hostNames are the allowed hosts as configured, for errors
+	hostNames []string
+	// cassette optionally records & replays the transform's http requests
+	cassette *cassette
+	// allowExec lets exec transforms run
+	allowExec bool
+}
+
+func newTransformLimiter(limits *config.Transform) (*transformLimiter, error) {
+	l := &transformLimiter{}
+	if limits == nil {
+		return l, nil
+	}
+	if limits.Timeout != "" {
+		d, err := time.ParseDuration(limits.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform timeout: %s", err.Error())
+		}
+		l.timeout = d
+	}
+	l.maxRows = limits.MaxOutputRows
+	l.maxBytes = limits.MaxOutputBytes
+	l.allowExec = limits.AllowExec
+	if len(limits.AllowedHosts) > 0 {
+		l.hostNames = limits.AllowedHosts
+		l.hosts = map[string]bool{}
+		for _, host := range limits.AllowedHosts {
+			l.hosts[strings.ToLower(host)] = true
+		}
+	}
+	return l, nil
+}
+
+// exec calls fn, failing if fn requests a host that isn't allowed, and giving
+// up once the timeout passes or ctx is cancelled. fn is given a context that
+// is done when exec gives up. exec returns without waiting for fn to finish,
+// requests fn makes after that are refused.
+// starlark scripts can't be stopped mid-run: startf creates & owns the
+// interpreter thread, and doesn't expose it's step counter or cancel hook.
+// abandoned scripts are counted in qri_transforms_abandoned_total so
+// operators can see when they pile up
+func (l *transformLimiter) exec(ctx context.Context, fn func(ctx context.Context) (cafs.File, error)) (cafs.File, error) {
+	parent := ctx
+	var cancel context.CancelFunc
+	if l.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	type result struct {
+		file cafs.File
+		err  error
+	}
+	done := make(chan result, 1)
+	bound := make(chan *hostSession, 1)
+	// state records if fn is given up on before it finishes
+	var state struct {
+		sync.Mutex
+		finished, abandoned bool
+	}
+
+	go func() {
+		bound <- transformHosts.bind(l.hosts, l.cassette)
+		file, err := fn(ctx)
+		state.Lock()
+		state.finished = true
+		if state.abandoned {
+			transformsAbandoned.With("finished").Inc()
+		}
+		state.Unlock()
+		if host := transformHosts.unbind(); host != "" && ctx.Err() == nil {
+			err = &TransformLimitError{
+				Limit:   "allowedHosts",
+				Message: fmt.Sprintf("transform requested host '%s', which isn't an allowed host. allowed hosts: %s", host, strings.Join(l.hostNames, ", ")),
+			}
+		}
+		done <- result{file, err}
+	}()
+	session := <-bound
+
+	select {
+	case res := <-done:
+		return res.file, res.err
+	case <-ctx.Done():
+		transformHosts.cancel(session)
+		state.Lock()
+		if !state.finished {
+			state.abandoned = true
+			transformsAbandoned.With("abandoned").Inc()
+		}
+		state.Unlock()
+		if err := parent.Err(); err != nil {
+			return nil, err
+		}
+		return nil, &TransformLimitError{
+			Limit:   "timeout",
+			Message: fmt.Sprintf("transform ran longer than the time limit of %s", l.timeout),
+		}
+	}
+}
+
+// checkBody enforces output limits on the body a transform produced.
+// checking reads the body, so checkBody returns a copy of it
+func (l *transformLimiter) checkBody(st *dataset.Structure, body cafs.File) (cafs.File, error) {
+	if body == nil || (l.maxRows == 0 && l.maxBytes == 0) {
+		return body, nil
+	}
+
+	var r io.Reader = body
+	if l.maxBytes > 0 {
+		r = io.LimitReader(body, int64(l.maxBytes)+1)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if l.maxBytes > 0 && len(data) > l.maxBytes {
+		return nil, &TransformLimitError{
+			Limit:   "maxOutputBytes",
+			Message: fmt.Sprintf("transform body is larger than the limit of %d bytes", l.maxBytes),
+		}
+	}
+
+	if l.maxRows > 0 {
+		entries, err := countEntries(st, data)
+		if err != nil {
+			return nil, fmt.Errorf("error counting transform body entries: %s", err.Error())
+		}
+		if entries > l.maxRows {
+			return nil, &TransformLimitError{
+				Limit:   "maxOutputRows",
+				Message: fmt.Sprintf("transform body has %d entries, more than the limit of %d", entries, l.maxRows),
+			}
+		}
+	}
+	return cafs.NewMemfileBytes(body.FileName(), data), nil
+}
+
+// countEntries counts the entries of a body. bodies without a schema are read
+// as JSON
+func countEntries(st *dataset.Structure, data []byte) (int, error) {
+	if st == nil || st.Schema == nil {
+		var body interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return 0, err
+		}
+		switch b := body.(type) {
+		case []interface{}:
+			return len(b), nil
+		case map[string]interface{}:
+			return len(b), nil
+		}
+		return 1, nil
+	}
+
+	rr, err := dsio.NewEntryReader(st, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	entries := 0
+	for {
+		if _, err := rr.ReadEntry(); err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return entries, err
+		}
+		entries++
+	}
+}
+
+// transformHosts holds transforms to their allowed hosts. transforms make
+// http requests with go's default http client, so hosts are guarded by
+// wrapping it's transport. net/http calls RoundTrip on the goroutine making
+// the request, so limits are held in sessions bound to the goroutine a
+// transform runs on. requests from goroutines without a session, like
+// other users of the default client, aren't limited
+var transformHosts = &hostGuard{sessions: map[uint64]*hostSession{}}
+
+// hostGuard is an http.RoundTripper that refuses requests to hosts that
+// aren't allowed, sending allowed requests to a cassette if there is one
+type hostGuard struct {
+	install sync.Once
+	next    http.RoundTripper
+
+	lock     sync.Mutex
+	sessions map[uint64]*hostSession
+}
+
+// hostSession holds the requests of one transform
+type hostSession struct {
+	// allowed hosts, nil allows any host
+	allowed  map[string]bool
+	denied   string
+	cassette *cassette
+	// cancelled sessions belong to transforms that were given up on, all of
+	// their requests are refused
+	cancelled bool
+}
+
+// bind limits the requests of the calling goroutine to hosts, sending them to
+// c if it isn't nil, until unbind is called. nil hosts allow any host
+func (g *hostGuard) bind(hosts map[string]bool, c *cassette) *hostSession {
+	g.install.Do(func() {
+		g.next = http.DefaultClient.Transport
+		if g.next == nil {
+			g.next = http.DefaultTransport
+		}
+		http.DefaultClient.Transport = g
+	})
+	s := &hostSession{allowed: hosts, cassette: c}
+	g.lock.Lock()
+	g.sessions[goroutineID()] = s
+	g.lock.Unlock()
+	return s
+}
+
+// unbind lifts the limits of the calling goroutine, returning the first host
+// a request was refused for
+func (g *hostGuard) unbind() string {
+	id := goroutineID()
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	s := g.sessions[id]
+	delete(g.sessions, id)
+	if s == nil {
+		return ""
+	}
+	return s.denied
+}
+
+// cancel refuses every later request of a session
+func (g *hostGuard) cancel(s *hostSession) {
+	g.lock.Lock()
+	s.cancelled = true
+	g.lock.Unlock()
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (g *hostGuard) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	g.lock.Lock()
+	s := g.sessions[goroutineID()]
+	if s == nil {
+		g.lock.Unlock()
+		return g.next.RoundTrip(req)
+	}
+	cancelled := s.cancelled
+	refused := s.allowed != nil && !s.allowed[host]
+	if refused && s.denied == "" {
+		s.denied = host
+	}
+	c := s.cassette
+	g.lock.Unlock()
+
+	if cancelled {
+		return nil, fmt.Errorf("transform was stopped, refusing request to host '%s'", host)
+	}
+	if refused {
+		return nil, fmt.Errorf("transforms aren't allowed to request host '%s'", host)
+	}
+	if c != nil {
+		return c.roundTrip(req, g.next)
+	}
+	return g.next.RoundTrip(req)
+}
+
+// goroutineID parses the id of the calling goroutine from it's stack trace,
+// which starts with "goroutine N ["
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
@@ -0,0 +1,134 @@
+package actions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/repo"
+)
+
+func TestExecTransformLimits(t *testing.T) {
+	node := newTestNode(t)
+
+	cases := []struct {
+		script string
+		limits *config.Transform
+		err    string
+	}{
+		{"def transform(ds,ctx):\n\tds.set_body([1,2,3])\n", &config.Transform{MaxOutputRows: 3, MaxOutputBytes: 100}, ""},
+		{"def transform(ds,ctx):\n\tds.set_body([1,2,3])\n", &config.Transform{MaxOutputRows: 2}, "transform body has 3 entries, more than the limit of 2"},
+		{"def transform(ds,ctx):\n\tds.set_body([1,2,3])\n", &config.Transform{MaxOutputBytes: 4}, "transform body is larger than the limit of 4 bytes"},
+		{"def transform(ds,ctx):\n\tfor i in range(10000000):\n\t\tpass\n\tds.set_body([])\n", &config.Transform{Timeout: "10ms"}, "transform ran longer than the time limit of 10ms"},
+		{"def transform(ds,ctx):\n\tds.set_body([])\n", &config.Transform{Timeout: "soon"}, "invalid transform timeout: time: "},
+	}
+
+	for i, c := range cases {
+		ds := &dataset.Dataset{
+			Structure: &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray},
+			Transform: &dataset.Transform{Syntax: "starlark"},
+		}
+		script := cafs.NewMemfileBytes("transform.star", []byte(c.script))
+		_, err := ExecTransform(context.Background(), node, ds, script, nil, nil, nil, c.limits, nil, nil)
+		// error messages of the time package vary between go versions
+		if !(err == nil && c.err == "" || err != nil && c.err != "" && strings.HasPrefix(err.Error(), c.err)) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+		}
+	}
+}
+
+func TestHostGuard(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	transformHosts.bind(map[string]bool{"example.com": true}, nil)
+	if _, err := http.Get(s.URL); err == nil {
+		t.Error("expected a request to a host that isn't allowed to error")
+	}
+	// requests of other goroutines aren't held to the session's hosts
+	errs := make(chan error)
+	go func() {
+		_, err := http.Get(s.URL)
+		errs <- err
+	}()
+	if err := <-errs; err != nil {
+		t.Errorf("expected a request from another goroutine to succeed, got: %s", err)
+	}
+	if host := transformHosts.unbind(); host != "127.0.0.1" {
+		t.Errorf("expected refused host to be 127.0.0.1, got: '%s'", host)
+	}
+
+	session := transformHosts.bind(map[string]bool{"127.0.0.1": true}, nil)
+	if _, err := http.Get(s.URL); err != nil {
+		t.Errorf("expected a request to an allowed host to succeed, got: %s", err)
+	}
+	transformHosts.cancel(session)
+	if _, err := http.Get(s.URL); err == nil {
+		t.Error("expected a request of a cancelled session to error")
+	}
+	if host := transformHosts.unbind(); host != "" {
+		t.Errorf("expected no refused host, got: '%s'", host)
+	}
+
+	// requests are only held to allowed hosts while a transform runs
+	if _, err := http.Get(s.URL); err != nil {
+		t.Errorf("expected a request without limits to succeed, got: %s", err)
+	}
+}
+
+func TestTransformLimiterCancel(t *testing.T) {
+	l := &transformLimiter{}
+	block := make(chan struct{})
+	defer close(block)
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := transformsAbandoned.With("abandoned").Value()
+	stopped := make(chan error, 1)
+	go func() {
+		_, err := l.exec(ctx, func(ctx context.Context) (cafs.File, error) {
+			// a transform that doesn't check it's context
+			<-block
+			return nil, nil
+		})
+		stopped <- err
+	}()
+	cancel()
+	if err := <-stopped; err != context.Canceled {
+		t.Errorf("expected cancelling to stop exec with context.Canceled, got: %v", err)
+	}
+	if got := transformsAbandoned.With("abandoned").Value(); got != abandoned+1 {
+		t.Errorf("expected abandoned transform to be counted. expected: %v, got: %v", abandoned+1, got)
+	}
+}
+
+func TestSaveDatasetTransformLimit(t *testing.T) {
+	node := newTestNode(t)
+	dsp := &dataset.DatasetPod{
+		Name:      "limited",
+		Structure: &dataset.StructurePod{Format: dataset.JSONDataFormat.String(), Schema: map[string]interface{}{"type": "array"}},
+		Transform: &dataset.TransformPod{
+			Syntax:      "starlark",
+			ScriptBytes: []byte("def transform(ds,ctx):\n  ds.set_body([1,2,3])\n"),
+		},
+	}
+
+	_, _, err := SaveDataset(context.Background(), node, dsp, nil, nil, &SaveDatasetOptions{Pin: true, Limits: &config.Transform{MaxOutputRows: 1}})
+	if _, ok := err.(*TransformLimitError); !ok {
+		t.Fatalf("expected a transform limit error, got: %v", err)
+	}
+
+	events, err := node.Repo.Events(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != repo.ETTransformLimited || events[0].Ref.Name != "limited" {
+		t.Fatalf("expected a transform limited event, got: %v", events)
+	}
+	if le, ok := events[0].Params.(*TransformLimitError); !ok || le.Limit != "maxOutputRows" {
+		t.Errorf("expected event params to record the limit, got: %v", events[0].Params)
+	}
+}
@@ -0,0 +1,439 @@
+package actions
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/startf"
+)
+
+// TransformRuntime runs transform scripts of a syntax. runtimes are given the
+// previous version of a dataset, it's body, config & secrets, and give back a
+// new body & the components they changed
+type TransformRuntime interface {
+	ExecTransform(in *TransformInput) (*TransformOutput, error)
+}
+
+// TransformInput is the input to a transform runtime
+type TransformInput struct {
+	// Ctx is done when the transform is cancelled or runs out of time.
+	// runtimes should stop once it's done, the transform's result is ignored
+	Ctx  context.Context
+	Node *p2p.QriNode
+	// Dataset is the previous version of the dataset. runtimes change
+	// components in place
+	Dataset *dataset.Dataset
+	Script  cafs.File
+	// PrevBody is the body of the previous version, nil for new datasets
+	PrevBody cafs.File
+	Config   map[string]interface{}
+	Secrets  map[string]string
+	// AllowedHosts are the only hosts the transform can request. requests made
+	// with go's default http client are held to them for every runtime,
+	// runtimes that make requests any other way must refuse to run
+	AllowedHosts []string
+	// Recorded is true for transforms who's requests are recorded to or
+	// replayed from a cassette, which only sees requests made with go's
+	// default http client
+	Recorded bool
+	// AllowExec is true for transforms that can run local executables
+	AllowExec bool
+	// MaxOutputBytes is the most output runtimes should read from a script,
+	// 0 doesn't limit
+	MaxOutputBytes int
+	// Output receives anything the script prints
+	Output io.Writer
+}
+
+// execRefusedKey is the context key of RefuseExec
+type execRefusedKey struct{}
+
+// RefuseExec gives a context that stops transforms from running local
+// executables, for transforms run on behalf of someone other than the local
+// user, like API requests & jobs
+func RefuseExec(ctx context.Context) context.Context {
+	return context.WithValue(ctx, execRefusedKey{}, true)
+}
+
+// execRefused checks if ctx refuses to run local executables
+func execRefused(ctx context.Context) bool {
+	refused, _ := ctx.Value(execRefusedKey{}).(bool)
+	return refused
+}
+
+// ctx gets the context of a transform, which is never nil
+func (in *TransformInput) ctx() context.Context {
+	if in.Ctx == nil {
+		return context.Background()
+	}
+	return in.Ctx
+}
+
+// ctxWriter fails writes once it's context is done. writes to a nil writer
+// are discarded
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// Write implements the io.Writer interface
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if w.w == nil {
+		return len(p), nil
+	}
+	return w.w.Write(p)
+}
+
+// TransformOutput is the result of a transform runtime
+type TransformOutput struct {
+	// Body is the new body, in the format of the dataset's structure. a nil
+	// body keeps the previous body
+	Body cafs.File
+	// Changed names each component the transform changed
+	Changed []string
+}
+
+// transformRuntimes maps transform syntaxes to the runtime that runs them
+var transformRuntimes = map[string]TransformRuntime{
+	"starlark": starlarkRuntime{},
+	"sql":      sqlRuntime{},
+	"exec":     execRuntime{},
+}
+
+// RegisterTransformRuntime sets the runtime for transforms of a syntax,
+// replacing any runtime the syntax had. runtimes must be registered before
+// any transforms run
+func RegisterTransformRuntime(syntax string, rt TransformRuntime) {
+	transformRuntimes[syntax] = rt
+}
+
+// transformRuntime gets the runtime for a syntax. transforms without a syntax
+// are starlark
+func transformRuntime(syntax string) (TransformRuntime, error) {
+	if syntax == "" {
+		syntax = "starlark"
+	}
+	rt, ok := transformRuntimes[syntax]
+	if !ok {
+		syntaxes := make([]string, 0, len(transformRuntimes))
+		for s := range transformRuntimes {
+			syntaxes = append(syntaxes, s)
+		}
+		sort.Strings(syntaxes)
+		return nil, fmt.Errorf("unsupported transform syntax '%s'. supported syntaxes: %v", syntax, syntaxes)
+	}
+	return rt, nil
+}
+
+// ScriptSyntax guesses the transform syntax of a script from it's file
+// extension. scripts that aren't sql are starlark, exec transforms can't be
+// told apart from their name
+func ScriptSyntax(filename string) string {
+	if strings.ToLower(filepath.Ext(filename)) == ".sql" {
+		return "sql"
+	}
+	return "starlark"
+}
+
+// starlarkRuntime runs starlark scripts with startf
+type starlarkRuntime struct{}
+
+// ExecTransform implements the TransformRuntime interface
+func (starlarkRuntime) ExecTransform(in *TransformInput) (*TransformOutput, error) {
+	out := &TransformOutput{}
+	// startf checks components as the script sets them, record them so they're
+	// checked the same way as other runtimes
+	changed := map[string]bool{}
+	// starlark can't be interrupted, stop scripts of cancelled transforms the
+	// next time they set a component or print
+	recordChange := func(path ...string) error {
+		if err := in.ctx().Err(); err != nil {
+			return err
+		}
+		if len(path) > 0 && !changed[path[0]] {
+			changed[path[0]] = true
+			out.Changed = append(out.Changed, path[0])
+		}
+		return nil
+	}
+
+	setSecrets := func(o *startf.ExecOpts) {
+		if in.Secrets != nil {
+			// convert to map[string]interface{}, which the lower-level startf supports
+			// until we're sure map[string]string is going to work in the majority of use cases
+			s := map[string]interface{}{}
+			for key, val := range in.Secrets {
+				s[key] = val
+			}
+			o.Secrets = s
+		}
+	}
+
+	configs := []func(*startf.ExecOpts){
+		startf.AddQriNodeOpt(in.Node),
+		startf.AddMutateFieldCheck(recordChange),
+		startf.SetOutWriter(ctxWriter{ctx: in.ctx(), w: in.Output}),
+		setSecrets,
+	}
+
+	body, err := startf.ExecScript(in.Dataset, in.Script, in.PrevBody, configs...)
+	if err != nil {
+		return nil, err
+	}
+	out.Body = body
+	return out, nil
+}
+
+// sqlRuntime runs a sqlite query against the previous body, which is a table
+// named "body". config values are bound to named parameters of the query.
+// the query result becomes the body, the structure keeps it's format with a
+// schema of the result's columns
+type sqlRuntime struct{}
+
+// ExecTransform implements the TransformRuntime interface
+func (sqlRuntime) ExecTransform(in *TransformInput) (*TransformOutput, error) {
+	query, err := ioutil.ReadAll(in.Script)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sql transform: %s", err.Error())
+	}
+	res, err := base.QueryBody(in.Dataset.Structure, in.PrevBody, string(query), in.Config)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(res)
+	if err != nil {
+		return nil, err
+	}
+	body, err := jsonTransformBody(in.Dataset, data, res.Structure.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return &TransformOutput{Body: body, Changed: []string{"body", "structure"}}, nil
+}
+
+// execRuntime runs a script as a local executable, which reads it's input from
+// stdin & writes it's output to stdout as newline-delimited JSON. the first
+// line of input is an object with "dataset", "config" & "secrets" keys, each
+// following line is an entry of the previous body. entries of object bodies
+// are [key, value] arrays. the first line of output is an object of the
+// components the script changes, which can have "meta" & "structure" keys,
+// each following line is an entry of the new body. anything written to stderr
+// is script output. scripts run directly, so interpreted scripts need a #!
+// line, like "#!/usr/bin/env python3"
+type execRuntime struct{}
+
+// ExecTransform implements the TransformRuntime interface
+func (execRuntime) ExecTransform(in *TransformInput) (*TransformOutput, error) {
+	if !in.AllowExec {
+		return nil, fmt.Errorf("exec transforms only run for scripts saved from your filesystem with transform.allowExec set in your config")
+	}
+	if len(in.AllowedHosts) > 0 {
+		return nil, fmt.Errorf("exec transforms can't be held to allowed hosts, remove transform.allowedHosts from your config to run them")
+	}
+	if in.Recorded {
+		return nil, fmt.Errorf("exec transforms can't be recorded to a cassette, test them without one")
+	}
+
+	input, err := execTransformInput(in)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "qri_transform")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	script, err := ioutil.ReadAll(in.Script)
+	if err != nil {
+		return nil, fmt.Errorf("error reading transform: %s", err.Error())
+	}
+	name := filepath.Base(in.Script.FileName())
+	if name == "." || name == "/" {
+		name = "transform"
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, script, 0700); err != nil {
+		return nil, err
+	}
+
+	// the executable is killed once the transform is cancelled or times out
+	stdout := &cappedBuffer{max: in.MaxOutputBytes}
+	cmd := exec.CommandContext(in.ctx(), path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = in.Output
+	if err := cmd.Run(); err != nil {
+		if stdout.over {
+			return nil, &TransformLimitError{
+				Limit:   "maxOutputBytes",
+				Message: fmt.Sprintf("transform output is larger than the limit of %d bytes", stdout.max),
+			}
+		}
+		return nil, fmt.Errorf("transform executable failed: %s", err.Error())
+	}
+
+	return execTransformOutput(in.Dataset, &stdout.buf)
+}
+
+// cappedBuffer is a buffer that fails writes past max bytes. a max of 0
+// doesn't limit
+type cappedBuffer struct {
+	buf  bytes.Buffer
+	max  int
+	over bool
+}
+
+// Write implements the io.Writer interface
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.max > 0 && b.buf.Len()+len(p) > b.max {
+		b.over = true
+		return 0, fmt.Errorf("transform output is larger than the limit of %d bytes", b.max)
+	}
+	return b.buf.Write(p)
+}
+
+// execTransformInput encodes the input of an exec transform
+func execTransformInput(in *TransformInput) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	header := map[string]interface{}{
+		"dataset": in.Dataset,
+		"config":  in.Config,
+		"secrets": in.Secrets,
+	}
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+	if in.PrevBody == nil {
+		return buf.Bytes(), nil
+	}
+
+	rr, err := base.NewBodyReader(in.Dataset.Structure, base.BodyFileEncoding(in.PrevBody.FileName()), in.PrevBody)
+	if err != nil {
+		return nil, fmt.Errorf("error allocating data reader: %s", err)
+	}
+	for {
+		ent, err := rr.ReadEntry()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading body: %s", err.Error())
+		}
+		var v interface{} = ent.Value
+		if ent.Key != "" {
+			v = []interface{}{ent.Key, ent.Value}
+		}
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// execTransformOutput decodes the output of an exec transform, applying
+// component changes to ds
+func execTransformOutput(ds *dataset.Dataset, r io.Reader) (*TransformOutput, error) {
+	out := &TransformOutput{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("error reading transform output: %s", err.Error())
+		}
+		return nil, fmt.Errorf("transform executable didn't write any output")
+	}
+	header := map[string]json.RawMessage{}
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("the first line of transform output must be an object of changed components: %s", err.Error())
+	}
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch key {
+		case "meta":
+			md := &dataset.Meta{}
+			if err := md.UnmarshalJSON(header[key]); err != nil {
+				return nil, fmt.Errorf("invalid transform meta: %s", err.Error())
+			}
+			ds.Meta = md
+		case "structure":
+			st := &dataset.Structure{}
+			if err := st.UnmarshalJSON(header[key]); err != nil {
+				return nil, fmt.Errorf("invalid transform structure: %s", err.Error())
+			}
+			ds.Structure = st
+		default:
+			return nil, fmt.Errorf("exec transforms can only change meta & structure, got: %s", key)
+		}
+		out.Changed = append(out.Changed, key)
+	}
+
+	entries := []json.RawMessage{}
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, fmt.Errorf("invalid transform body entry %d: %s", len(entries), string(line))
+		}
+		entries = append(entries, json.RawMessage(append([]byte{}, line...)))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading transform output: %s", err.Error())
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	if out.Body, err = jsonTransformBody(ds, data, nil); err != nil {
+		return nil, err
+	}
+	out.Changed = append(out.Changed, "body")
+	return out, nil
+}
+
+// jsonTransformBody encodes a JSON array body in the format of ds's
+// structure, replacing the structure's schema if schema isn't nil. datasets
+// without a structure get a JSON structure
+func jsonTransformBody(ds *dataset.Dataset, data []byte, schema *jsonschema.RootSchema) (cafs.File, error) {
+	if ds.Structure == nil {
+		ds.Structure = &dataset.Structure{Format: dataset.JSONDataFormat}
+	}
+	if schema != nil {
+		ds.Structure.Schema = schema
+	}
+	if ds.Structure.Schema == nil {
+		ds.Structure.Schema = dataset.BaseSchemaArray
+	}
+
+	file := cafs.NewMemfileBytes("body.json", data)
+	if ds.Structure.Format == dataset.JSONDataFormat {
+		return file, nil
+	}
+	return base.ConvertBodyFormat(file, &dataset.Structure{Format: dataset.JSONDataFormat, Schema: ds.Structure.Schema}, ds.Structure, "")
+}
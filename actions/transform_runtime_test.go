@@ -0,0 +1,181 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/config"
+)
+
+func TestTransformRuntimeSyntax(t *testing.T) {
+	cases := []struct {
+		syntax string
+		err    string
+	}{
+		{"", ""},
+		{"starlark", ""},
+		{"sql", ""},
+		{"exec", ""},
+		{"cobol", "unsupported transform syntax 'cobol'. supported syntaxes: [exec sql starlark]"},
+	}
+	for i, c := range cases {
+		_, err := transformRuntime(c.syntax)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+		}
+	}
+}
+
+func TestExecTransformSQL(t *testing.T) {
+	node := newTestNode(t)
+	ref := addCitiesDataset(t, node)
+	if err := DatasetHead(node, &ref); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := ref.DecodeDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevBody, err := node.Repo.Store().Get(ds.BodyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds.Transform = &dataset.Transform{Syntax: "sql"}
+	script := cafs.NewMemfileBytes("transform.sql", []byte("SELECT city FROM body WHERE pop > :min ORDER BY city"))
+	body, err := ExecTransform(context.Background(), node, ds, script, prevBody, nil, map[string]interface{}{"min": 1000000.0}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sql transforms keep the format of the previous version
+	if ds.Structure.Format != dataset.CSVDataFormat {
+		t.Errorf("expected csv format, got: %s", ds.Structure.Format)
+	}
+	if string(data) != "city\nnew york\ntoronto\n" {
+		t.Errorf("body mismatch, got: %q", string(data))
+	}
+}
+
+func TestExecTransformExec(t *testing.T) {
+	node := newTestNode(t)
+	script := []byte(`#!/bin/sh
+cat > /dev/null
+echo "transforming" >&2
+echo '{"meta":{"title":"from exec"}}'
+echo '[1,"a"]'
+echo '[2,"b"]'
+`)
+
+	allow := &config.Transform{AllowExec: true}
+	ds := &dataset.Dataset{Transform: &dataset.Transform{Syntax: "exec"}}
+	out := &bytes.Buffer{}
+	body, err := ExecTransform(context.Background(), node, ds, cafs.NewMemfileBytes("transform.sh", script), nil, nil, nil, allow, out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[[1,"a"],[2,"b"]]` {
+		t.Errorf("body mismatch, got: %s", string(data))
+	}
+	if ds.Meta == nil || ds.Meta.Title != "from exec" {
+		t.Errorf("expected exec transform to set meta title, got: %v", ds.Meta)
+	}
+	if out.String() != "transforming\n" {
+		t.Errorf("expected stderr to be script output, got: %q", out.String())
+	}
+
+	// component changes are checked the same way for every runtime
+	ds = &dataset.Dataset{Transform: &dataset.Transform{Syntax: "exec"}}
+	mutateCheck := mutatedComponentsFunc(&dataset.DatasetPod{Meta: &dataset.MetaPod{Title: "mine"}})
+	_, err = ExecTransform(context.Background(), node, ds, cafs.NewMemfileBytes("transform.sh", script), nil, nil, nil, allow, nil, mutateCheck)
+	if err == nil || !strings.Contains(err.Error(), "trying to set:\n  meta") {
+		t.Errorf("expected a mutated component error, got: %v", err)
+	}
+
+	ds = &dataset.Dataset{Transform: &dataset.Transform{Syntax: "exec"}}
+	_, err = ExecTransform(context.Background(), node, ds, cafs.NewMemfileBytes("transform.sh", script), nil, nil, nil, &config.Transform{AllowExec: true, AllowedHosts: []string{"example.com"}}, nil, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "exec transforms can't be held to allowed hosts") {
+		t.Errorf("expected exec transforms to refuse allowed hosts, got: %v", err)
+	}
+}
+
+func TestExecTransformExecRefused(t *testing.T) {
+	node := newTestNode(t)
+	script := []byte("#!/bin/sh\necho '{}'\necho '[1]'\n")
+
+	cases := []struct {
+		ctx      context.Context
+		limits   *config.Transform
+		cassette bool
+		err      string
+	}{
+		{context.Background(), nil, false, "exec transforms only run for scripts saved from your filesystem with transform.allowExec set in your config"},
+		{RefuseExec(context.Background()), &config.Transform{AllowExec: true}, false, "exec transforms only run for scripts saved from your filesystem with transform.allowExec set in your config"},
+		{context.Background(), &config.Transform{AllowExec: true}, true, "exec transforms can't be recorded to a cassette, test them without one"},
+		{context.Background(), &config.Transform{AllowExec: true}, false, ""},
+	}
+	for i, c := range cases {
+		limiter, err := newTransformLimiter(c.limits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.cassette {
+			limiter.cassette = &cassette{}
+		}
+		ds := &dataset.Dataset{Transform: &dataset.Transform{Syntax: "exec"}}
+		_, err = execTransform(c.ctx, node, ds, cafs.NewMemfileBytes("transform.sh", script), nil, nil, nil, limiter, nil, nil)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+		}
+	}
+}
+
+func TestExecTransformExecLimits(t *testing.T) {
+	node := newTestNode(t)
+
+	// output is capped while the script runs, not just once it's done
+	script := []byte("#!/bin/sh\necho '{}'\nwhile true; do echo '[1,2,3,4,5,6,7,8]'; done\n")
+	ds := &dataset.Dataset{Transform: &dataset.Transform{Syntax: "exec"}}
+	_, err := ExecTransform(context.Background(), node, ds, cafs.NewMemfileBytes("transform.sh", script), nil, nil, nil, &config.Transform{AllowExec: true, MaxOutputBytes: 1024}, nil, nil)
+	if le, ok := err.(*TransformLimitError); !ok || le.Limit != "maxOutputBytes" {
+		t.Errorf("expected a maxOutputBytes limit error, got: %v", err)
+	}
+
+	script = []byte("#!/bin/sh\nexec sleep 10\n")
+	ds = &dataset.Dataset{Transform: &dataset.Transform{Syntax: "exec"}}
+	_, err = ExecTransform(context.Background(), node, ds, cafs.NewMemfileBytes("transform.sh", script), nil, nil, nil, &config.Transform{AllowExec: true, Timeout: "50ms"}, nil, nil)
+	if le, ok := err.(*TransformLimitError); !ok || le.Limit != "timeout" {
+		t.Errorf("expected a timeout limit error, got: %v", err)
+	}
+}
+
+func TestExecTransformOutput(t *testing.T) {
+	cases := []struct {
+		output string
+		err    string
+	}{
+		{"", "transform executable didn't write any output"},
+		{"[1]\n", "the first line of transform output must be an object of changed components: json: cannot unmarshal array into Go value of type map[string]json.RawMessage"},
+		{`{"viz":{}}` + "\n", "exec transforms can only change meta & structure, got: viz"},
+		{"{}\n{nope\n", "invalid transform body entry 0: {nope"},
+		{"{}\n\n1\n", ""},
+	}
+	for i, c := range cases {
+		_, err := execTransformOutput(&dataset.Dataset{}, strings.NewReader(c.output))
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+		}
+	}
+}
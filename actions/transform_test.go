@@ -1,6 +1,7 @@
 package actions
 
 import (
+	"context"
 	"testing"
 
 	"github.com/qri-io/cafs"
@@ -43,7 +44,7 @@ def transform(ds,ctx):
 		},
 	}
 
-	if _, err := ExecTransform(node, ds, script, nil, map[string]string{"foo": "config"}, map[string]interface{}{"bar": "secret"}, nil, nil); err != nil {
+	if _, err := ExecTransform(context.Background(), node, ds, script, nil, map[string]string{"foo": "config"}, map[string]interface{}{"bar": "secret"}, nil, nil, nil); err != nil {
 		t.Error(err.Error())
 	}
 }
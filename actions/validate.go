@@ -12,6 +12,7 @@ import (
 	"github.com/qri-io/dataset/dsio"
 	"github.com/qri-io/dataset/validate"
 	"github.com/qri-io/jsonschema"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/p2p"
 	"github.com/qri-io/qri/repo"
 )
@@ -53,6 +54,19 @@ func Validate(node *p2p.QriNode, ref repo.DatasetRef, body, schema cafs.File) (e
 	}
 
 	if body != nil {
+		// bodies in encodings like ndjson & parquet are validated as json
+		var encoded *dataset.Structure
+		if body, encoded, err = base.DecodeBody(body); err != nil {
+			log.Debug(err.Error())
+			return
+		}
+		if encoded != nil {
+			if st.Schema == nil {
+				st.Schema = encoded.Schema
+			}
+			st = &dataset.Structure{Format: dataset.JSONDataFormat, Schema: st.Schema}
+		}
+
 		data, err = ioutil.ReadAll(body)
 		if err != nil {
 			log.Debug(err.Error())
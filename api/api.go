@@ -5,11 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
-	stdlog "log"
 	"net"
 	"net/http"
-	"net/rpc"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/datatogether/api/apiutil"
@@ -18,46 +17,83 @@ import (
 	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
+	"github.com/qri-io/qri/repo"
 )
 
 var log = golog.Logger("qriapi")
 
 func init() {
-	// We don't use the log package, and the net/rpc package spits out some complaints b/c
-	// a few methods don't conform to the proper signature (comment this out & run 'qri connect' to see errors)
-	// so we're disabling the log package for now. This is potentially very stupid.
-	// TODO (b5): remove dep on net/rpc package entirely
-	stdlog.SetOutput(ioutil.Discard)
-
 	golog.SetLogLevel("qriapi", "info")
 }
 
+// ShutdownTimeout is the longest Close will wait for in-flight HTTP & RPC
+// requests to finish before forcing connections closed
+var ShutdownTimeout = time.Second * 10
+
 // Server wraps a qri p2p node, providing traditional access via http
 // Create one with New, start it up with Serve
 type Server struct {
 	// configuration options
 	cfg     *config.Config
 	qriNode *p2p.QriNode
+
+	// servers & listeners, populated by Start
+	lock      sync.Mutex
+	http      *http.Server
+	webapp    *http.Server
+	rpc       net.Listener
+	rpcConns  map[net.Conn]struct{}
+	limiter   *limiter
+	rpcWg     sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+	done      chan struct{}
+	// closing is closed when shutdown starts, ending long-lived streams
+	closing chan struct{}
 }
 
 // New creates a new qri server from a p2p node & configuration
 func New(node *p2p.QriNode, cfg *config.Config) (s *Server) {
 	return &Server{
-		qriNode: node,
-		cfg:     cfg,
+		qriNode:  node,
+		cfg:      cfg,
+		rpcConns: map[net.Conn]struct{}{},
+		limiter:  newLimiter(),
+		done:     make(chan struct{}),
+		closing:  make(chan struct{}),
 	}
 }
 
 // Serve starts the server. It will block while the server is running
 func (s *Server) Serve() (err error) {
-	if err = s.qriNode.GoOnline(); err != nil {
+	return s.Start(context.Background())
+}
+
+// Start brings the qri node online & starts the server. It blocks until the
+// server is shut down, either by cancelling ctx or calling Close
+func (s *Server) Start(ctx context.Context) (err error) {
+	if err = s.qriNode.Start(ctx); err != nil {
 		fmt.Println("serving error", s.cfg.P2P.Enabled)
 		return
 	}
+	if err = lib.StartJobs(s.qriNode); err != nil {
+		// tear down the node that was just started
+		if e := s.qriNode.Close(); e != nil {
+			log.Errorf("error closing qri node: %s", e.Error())
+		}
+		return
+	}
 
 	server := &http.Server{}
 	mux := NewServerRoutes(s)
-	server.Handler = mux
+	server.Handler = instrument(mux)
+
+	if !s.register(func() { s.http = server }) {
+		// closed while starting
+		<-s.done
+		return s.closeErr
+	}
 
 	go s.ServeRPC()
 	go s.ServeWebapp()
@@ -110,38 +146,184 @@ func (s *Server) Serve() (err error) {
 
 	if s.cfg.API.DisconnectAfter != 0 {
 		log.Infof("disconnecting after %d seconds", s.cfg.API.DisconnectAfter)
-		go func(s *http.Server, t int) {
-			<-time.After(time.Second * time.Duration(t))
-			log.Infof("disconnecting")
+		go func(t int) {
+			select {
+			case <-time.After(time.Second * time.Duration(t)):
+				log.Infof("disconnecting")
+				s.Close()
+			case <-s.done:
+			}
+		}(s.cfg.API.DisconnectAfter)
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
 			s.Close()
-		}(server, s.cfg.API.DisconnectAfter)
+		case <-s.done:
+		}
+	}()
+
+	// StartServer will not return until the server is shut down or errors
+	if err = StartServer(s.cfg.API, server); err != nil && err != http.ErrServerClosed {
+		s.Close()
+		return err
 	}
 
-	// http.ListenAndServe will not return unless there's an error
-	return StartServer(s.cfg.API, server)
+	// block until shutdown is complete
+	<-s.done
+	return s.closeErr
+}
+
+// Close gracefully shuts down the server, waiting up to ShutdownTimeout for
+// in-flight HTTP & RPC requests to finish, then closes the qri node. Close
+// is safe to call more than once
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		defer close(s.done)
+		log.Info("shutting down")
+		close(s.closing)
+
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+
+		s.lock.Lock()
+		httpServer, webapp, rpcListener := s.http, s.webapp, s.rpc
+		s.lock.Unlock()
+
+		// stop accepting new requests, draining in-flight ones
+		if httpServer != nil {
+			if err := httpServer.Shutdown(ctx); err != nil {
+				log.Errorf("error shutting down API server: %s", err.Error())
+				s.closeErr = err
+			}
+		}
+		if webapp != nil {
+			if err := webapp.Shutdown(ctx); err != nil {
+				log.Errorf("error shutting down webapp server: %s", err.Error())
+			}
+		}
+		if rpcListener != nil {
+			rpcListener.Close()
+			s.drainRPC(ctx)
+		}
+
+		if err := s.qriNode.Close(); err != nil {
+			log.Errorf("error closing qri node: %s", err.Error())
+			s.closeErr = err
+		}
+	})
+	return s.closeErr
 }
 
-// ServeRPC checks for a configured RPC port, and registers a listner if so
+// ServeRPC checks for a configured RPC socket or port, and registers a
+// listener if so. Relative socket paths must be resolved before calling
+// ServeRPC
 func (s *Server) ServeRPC() {
-	if !s.cfg.RPC.Enabled || s.cfg.RPC.Port == 0 {
+	if !s.cfg.RPC.Enabled || (s.cfg.RPC.Socket == "" && s.cfg.RPC.Port == 0) {
 		return
 	}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.RPC.Port))
+	srv := qrpc.NewServer(lib.VersionNumber)
+	for _, rcvr := range lib.Receivers(s.qriNode) {
+		if err := srv.Register(rcvr); err != nil {
+			log.Infof("error registering RPC receiver %s: %s", rcvr.CoreRequestsName(), err.Error())
+			return
+		}
+	}
+
+	network, addr := s.cfg.RPC.Addr("")
+	if network == "unix" {
+		// a socket file left behind by a process that didn't shut down cleanly
+		// blocks listening. only remove it if nothing answers on it
+		if conn, err := net.Dial(network, addr); err == nil {
+			conn.Close()
+			log.Infof("RPC listen on %s error: socket is in use", addr)
+			return
+		}
+		os.Remove(addr)
+	}
+
+	listener, err := net.Listen(network, addr)
 	if err != nil {
-		log.Infof("RPC listen on port %d error: %s", s.cfg.RPC.Port, err)
+		log.Infof("RPC listen on %s error: %s", addr, err)
 		return
 	}
+	if network == "unix" {
+		// only the user running qri may call lib methods
+		if err := os.Chmod(addr, 0600); err != nil {
+			listener.Close()
+			log.Infof("RPC error restricting socket permissions: %s", err)
+			return
+		}
+	}
 
-	for _, rcvr := range lib.Receivers(s.qriNode) {
-		if err := rpc.Register(rcvr); err != nil {
-			log.Infof("error registering RPC receiver %s: %s", rcvr.CoreRequestsName(), err.Error())
+	if !s.register(func() { s.rpc = listener }) {
+		listener.Close()
+		return
+	}
+
+	// accept connections by hand instead of rpc.Accept so in-flight
+	// calls can be drained on shutdown
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// listener is closed on shutdown
 			return
 		}
+
+		s.lock.Lock()
+		s.rpcConns[conn] = struct{}{}
+		s.rpcWg.Add(1)
+		s.lock.Unlock()
+
+		go func(conn net.Conn) {
+			if err := srv.ServeConn(conn); err != nil {
+				log.Debugf("RPC connection error: %s", err)
+			}
+			s.lock.Lock()
+			delete(s.rpcConns, conn)
+			s.lock.Unlock()
+			s.rpcWg.Done()
+		}(conn)
+	}
+}
+
+// register records a started listener or server with set, returning false
+// without calling set if the server is already closing. Close either sees
+// what set records, or register sees the server closing, so nothing started
+// concurrently with Close is left open
+func (s *Server) register(set func()) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	select {
+	case <-s.closing:
+		return false
+	default:
+		set()
+		return true
 	}
+}
 
-	rpc.Accept(listener)
-	return
+// drainRPC waits for open RPC connections to hang up, force-closing any
+// that remain open when ctx is done
+func (s *Server) drainRPC(ctx context.Context) {
+	drained := make(chan struct{})
+	go func() {
+		s.rpcWg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		s.lock.Lock()
+		for conn := range s.rpcConns {
+			conn.Close()
+		}
+		s.lock.Unlock()
+		<-drained
+	}
 }
 
 // HandleIPFSPath responds to IPFS Hash requests with raw data
@@ -204,59 +386,83 @@ func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{ "meta": { "code": 200, "status": "ok", "version":"` + lib.VersionNumber + `" }, "data": [] }`))
 }
 
-// NewServerRoutes returns a Muxer that has all API routes
+// NewServerRoutes returns a Muxer that has all API routes. Each route sets
+// the token scope required for GET requests & for requests that modify the
+// repo, enforced when config.API.Auth is true
 func NewServerRoutes(s *Server) *http.ServeMux {
 	m := http.NewServeMux()
+	read, write, admin := repo.ScopeRead, repo.ScopeWrite, repo.ScopeAdmin
 
 	m.Handle("/status", s.middleware(HealthCheckHandler))
-	m.Handle("/ipfs/", s.middleware(s.HandleIPFSPath))
-	m.Handle("/ipns/", s.middleware(s.HandleIPNSPath))
+	m.Handle("/ipfs/", s.middleware(s.scoped(read, read, s.HandleIPFSPath)))
+	m.Handle("/ipns/", s.middleware(s.scoped(read, read, s.HandleIPNSPath)))
 
 	proh := NewProfileHandlers(s.qriNode, s.cfg.API.ReadOnly)
-	m.Handle("/me", s.middleware(proh.ProfileHandler))
-	m.Handle("/profile", s.middleware(proh.ProfileHandler))
-	m.Handle("/profile/photo", s.middleware(proh.ProfilePhotoHandler))
-	m.Handle("/profile/poster", s.middleware(proh.PosterHandler))
+	m.Handle("/me", s.middleware(s.scoped(read, admin, proh.ProfileHandler)))
+	m.Handle("/profile", s.middleware(s.scoped(read, admin, proh.ProfileHandler)))
+	m.Handle("/profile/photo", s.middleware(s.scoped(read, admin, proh.ProfilePhotoHandler)))
+	m.Handle("/profile/poster", s.middleware(s.scoped(read, admin, proh.PosterHandler)))
 
 	ph := NewPeerHandlers(s.qriNode, s.cfg.API.ReadOnly)
-	m.Handle("/peers", s.middleware(ph.PeersHandler))
-	m.Handle("/peers/", s.middleware(ph.PeerHandler))
+	m.Handle("/peers", s.middleware(s.scoped(read, admin, ph.PeersHandler)))
+	m.Handle("/peers/", s.middleware(s.scoped(read, admin, ph.PeerHandler)))
 
-	m.Handle("/connect/", s.middleware(ph.ConnectToPeerHandler))
-	m.Handle("/connections", s.middleware(ph.ConnectionsHandler))
+	m.Handle("/connect/", s.middleware(s.scoped(admin, admin, ph.ConnectToPeerHandler)))
+	m.Handle("/connections", s.middleware(s.scoped(read, admin, ph.ConnectionsHandler)))
+	m.Handle("/queue", s.middleware(s.scoped(read, admin, ph.QueueHandler)))
+	m.Handle("/events", s.middleware(s.scoped(read, read, s.EventsHandler)))
+	m.Handle("/metrics", s.middleware(s.scoped(read, read, s.MetricsHandler)))
 
 	dsh := NewDatasetHandlers(s.qriNode, s.cfg.API.ReadOnly)
+	// uploads are staged in the repo, they're only served once connect has
+	// resolved the uploads dir against the repo path
+	if s.cfg.API.Uploads != "" {
+		uph := NewUploadHandlers(s.qriNode, s.cfg.API.Uploads)
+		m.Handle("/uploads", s.middleware(s.scoped(write, write, uph.UploadsHandler)))
+		m.Handle("/uploads/", s.middleware(s.scoped(write, write, uph.UploadHandler)))
+		dsh.uploads = uph.uploads
+	}
 
-	m.Handle("/list", s.middleware(dsh.ListHandler))
-	m.Handle("/list/", s.middleware(dsh.PeerListHandler))
-	m.Handle("/save", s.middleware(dsh.SaveHandler))
-	m.Handle("/save/", s.middleware(dsh.SaveHandler))
-	m.Handle("/remove/", s.middleware(dsh.RemoveHandler))
-	m.Handle("/me/", s.middleware(dsh.GetHandler))
-	m.Handle("/add/", s.middleware(dsh.AddHandler))
-	m.Handle("/rename", s.middleware(dsh.RenameHandler))
-	m.Handle("/export/", s.middleware(dsh.ZipDatasetHandler))
-	m.Handle("/diff", s.middleware(dsh.DiffHandler))
-	m.Handle("/body/", s.middleware(dsh.BodyHandler))
-	m.Handle("/unpack/", s.middleware(dsh.UnpackHandler))
-	m.Handle("/publish/", s.middleware(dsh.PublishHandler))
-	m.Handle("/update/", s.middleware(dsh.UpdateHandler))
+	m.Handle("/list", s.middleware(s.scoped(read, read, dsh.ListHandler)))
+	m.Handle("/list/", s.middleware(s.scoped(read, read, dsh.PeerListHandler)))
+	m.Handle("/save", s.middleware(s.scoped(read, write, dsh.SaveHandler)))
+	m.Handle("/save/", s.middleware(s.scoped(read, write, dsh.SaveHandler)))
+	m.Handle("/remove/", s.middleware(s.scoped(read, write, dsh.RemoveHandler)))
+	m.Handle("/me/", s.middleware(s.scoped(read, write, dsh.GetHandler)))
+	m.Handle("/add/", s.middleware(s.scoped(read, write, dsh.AddHandler)))
+	m.Handle("/rename", s.middleware(s.scoped(read, write, dsh.RenameHandler)))
+	m.Handle("/export/", s.middleware(s.scoped(read, read, dsh.ZipDatasetHandler)))
+	m.Handle("/diff", s.middleware(s.scoped(read, read, dsh.DiffHandler)))
+	m.Handle("/body/", s.middleware(s.scoped(read, read, dsh.BodyHandler)))
+	m.Handle("/stats/", s.middleware(s.scoped(read, read, dsh.StatsHandler)))
+	m.Handle("/unpack/", s.middleware(s.scoped(read, read, dsh.UnpackHandler)))
+	m.Handle("/publish/", s.middleware(s.scoped(read, write, dsh.PublishHandler)))
+	m.Handle("/update/", s.middleware(s.scoped(read, write, dsh.UpdateHandler)))
 
 	renderh := NewRenderHandlers(s.qriNode.Repo)
-	m.Handle("/render/", s.middleware(renderh.RenderHandler))
+	m.Handle("/render/", s.middleware(s.scoped(read, read, renderh.RenderHandler)))
 
 	lh := NewLogHandlers(s.qriNode)
-	m.Handle("/history/", s.middleware(lh.LogHandler))
+	m.Handle("/history/", s.middleware(s.scoped(read, read, lh.LogHandler)))
 
 	rgh := NewRegistryHandlers(s.qriNode)
-	m.Handle("/registry/datasets", s.middleware(rgh.RegistryDatasetsHandler))
-	m.Handle("/registry/", s.middleware(rgh.RegistryHandler))
+	m.Handle("/registry/datasets", s.middleware(s.scoped(read, read, rgh.RegistryDatasetsHandler)))
+	m.Handle("/registry/", s.middleware(s.scoped(read, write, rgh.RegistryHandler)))
 
 	sh := NewSearchHandlers(s.qriNode)
-	m.Handle("/search", s.middleware(sh.SearchHandler))
+	m.Handle("/search", s.middleware(s.scoped(read, read, sh.SearchHandler)))
+
+	m.Handle(v1Prefix+"/", s.middleware(newV1Router(s).ServeHTTP))
+
+	gqlh := NewGraphQLHandlers(s.qriNode)
+	m.Handle("/graphql", s.middleware(s.scoped(read, read, gqlh.GraphQLHandler)))
+
+	jh := NewJobHandlers(s.qriNode)
+	m.Handle("/jobs", s.middleware(s.scoped(read, read, jh.JobsHandler)))
+	m.Handle("/jobs/", s.middleware(s.scoped(read, write, jh.JobHandler)))
 
 	rh := NewRootHandler(dsh, ph)
-	m.Handle("/", s.datasetRefMiddleware(s.middleware(rh.Handler)))
+	m.Handle("/", s.datasetRefMiddleware(s.middleware(s.scoped(read, write, rh.Handler))))
 
 	return m
 }
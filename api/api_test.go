@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -20,6 +21,7 @@ import (
 	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/repo/profile"
 	"github.com/qri-io/qri/repo/test"
@@ -83,6 +85,126 @@ func newTestNode(t *testing.T) (node *p2p.QriNode, teardown func()) {
 	return node, teardown
 }
 
+func TestServerStartClose(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	cfg := config.DefaultConfigForTesting()
+	cfg.API.Enabled = false
+	cfg.RPC.Enabled = false
+	cfg.Webapp.Enabled = false
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(node, cfg)
+	errs := make(chan error)
+	go func() {
+		errs <- s.Start(ctx)
+	}()
+
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("unexpected error starting server: %s", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for server to shut down")
+	}
+
+	if node.Online {
+		t.Error("expected node to be offline after shutdown")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("expected calling Close twice to be safe, got error: %s", err)
+	}
+}
+
+func TestServeRPCAfterClose(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	dir, err := ioutil.TempDir("", "qri_api_rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.DefaultConfigForTesting()
+	cfg.RPC.Enabled = true
+	cfg.RPC.Socket = filepath.Join(dir, "qri.sock")
+
+	// a listener started as the server closes mustn't be left open
+	s := New(node, cfg)
+	s.Close()
+	s.ServeRPC()
+	if _, err := os.Stat(cfg.RPC.Socket); !os.IsNotExist(err) {
+		t.Errorf("expected rpc socket to be closed, got: %v", err)
+	}
+}
+
+func TestServeRPC(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	dir, err := ioutil.TempDir("", "qri_api_rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.DefaultConfigForTesting()
+	cfg.API.Enabled = false
+	cfg.Webapp.Enabled = false
+	cfg.RPC.Enabled = true
+	cfg.RPC.Socket = filepath.Join(dir, "qri.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(node, cfg)
+	errs := make(chan error)
+	go func() {
+		errs <- s.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-errs
+	}()
+
+	var cli *qrpc.Client
+	deadline := time.Now().Add(time.Second * 5)
+	for cli == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for rpc socket: %s", err)
+		}
+		if cli, err = qrpc.Dial("unix", cfg.RPC.Socket, lib.VersionNumber); err != nil {
+			time.Sleep(time.Millisecond * 10)
+		}
+	}
+	defer cli.Close()
+
+	fi, err := os.Stat(cfg.RPC.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected socket permissions to be 0600, got: %o", perm)
+	}
+
+	refs := []repo.DatasetRef{}
+	dsr := lib.NewDatasetRequests(nil, cli)
+	if err := dsr.List(&lib.ListParams{Peername: "me", Limit: 1}, &refs); err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 {
+		t.Errorf("expected 1 dataset over rpc, got: %d", len(refs))
+	}
+
+	if _, err := qrpc.Dial("unix", cfg.RPC.Socket, "0.0.0-mismatch"); err == nil {
+		t.Error("expected dialing with a different version to error")
+	} else if _, ok := err.(qrpc.VersionError); !ok {
+		t.Errorf("expected version error, got: %s", err)
+	}
+}
+
 type handlerTestCase struct {
 	method, endpoint string
 	body             []byte
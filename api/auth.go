@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	util "github.com/datatogether/api/apiutil"
+	"github.com/qri-io/qri/repo"
+)
+
+// TokenCtxKey is the key for adding the API token a request was made with
+// to a context.Context
+const TokenCtxKey QriCtxKey = "token"
+
+// TokenFromCtx extracts the API token a request was authenticated with,
+// returning false if the request didn't carry a token
+func TokenFromCtx(ctx context.Context) (repo.Token, bool) {
+	t, ok := ctx.Value(TokenCtxKey).(repo.Token)
+	return t, ok
+}
+
+// scoped wraps a handler with token authorization. safe is the scope required
+// for GET requests, mutate is the scope required for all other methods.
+// scopes are only enforced when config.API.Auth is true. OPTIONS requests are
+// always allowed through so browsers can complete CORS preflight checks.
+// scoped handlers are also subject to config.API.Limits
+func (s *Server) scoped(safe, mutate repo.TokenScope, handler http.HandlerFunc) http.HandlerFunc {
+	// limits are checked once the token a request was made with is known
+	handler = s.limited(handler)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.cfg.API.Auth || r.Method == "OPTIONS" {
+			handler(w, r)
+			return
+		}
+
+		ts, ok := s.qriNode.Repo.(repo.TokenStore)
+		if !ok {
+			util.WriteErrResponse(w, http.StatusInternalServerError, fmt.Errorf("api auth is enabled, but this repo can't store tokens"))
+			return
+		}
+
+		secret := bearerToken(r)
+		if secret == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="qri"`)
+			util.WriteErrResponse(w, http.StatusUnauthorized, fmt.Errorf("an API token is required. provide one with an 'Authorization: Bearer' header"))
+			return
+		}
+
+		t, err := ts.TokenBySecret(secret)
+		if err != nil {
+			if err != repo.ErrTokenNotFound {
+				log.Errorf("error checking token: %s", err.Error())
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="qri", error="invalid_token"`)
+			util.WriteErrResponse(w, http.StatusUnauthorized, fmt.Errorf("invalid API token"))
+			return
+		}
+
+		required := mutate
+		if r.Method == "GET" {
+			required = safe
+		}
+		if !t.Scope.Allows(required) {
+			util.WriteErrResponse(w, http.StatusForbidden, fmt.Errorf("token '%s' has %s scope, %s %s requires %s scope", t.ID, t.Scope, r.Method, r.URL.Path, required))
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), TokenCtxKey, t))
+		if r.Method == "GET" {
+			handler(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		handler(sw, r)
+		if sw.status < http.StatusBadRequest {
+			s.logMutation(r, t, sw.status)
+		}
+	}
+}
+
+// logMutation records a successful mutating request in the event log,
+// along with the token the request was made with
+func (s *Server) logMutation(r *http.Request, t repo.Token, status int) {
+	el, ok := s.qriNode.Repo.(repo.DetailedEventLog)
+	if !ok {
+		return
+	}
+
+	ref, _ := DatasetRefFromReq(r)
+	params := map[string]interface{}{
+		"tokenID":   t.ID,
+		"tokenName": t.Name,
+		"scope":     string(t.Scope),
+		"method":    r.Method,
+		"path":      r.URL.Path,
+		"status":    status,
+	}
+	if err := el.LogEventDetails(repo.ETAPIMutation, time.Now().Unix(), s.qriNode.ID, ref, params); err != nil {
+		log.Errorf("error logging api mutation: %s", err.Error())
+	}
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+// statusWriter records the status code written to a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code & writes it to the underlying writer
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements the http.Flusher interface, so streaming handlers keep
+// working when wrapped
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/repo"
+)
+
+func TestScopedAuth(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	cfg := config.DefaultConfigForTesting()
+	cfg.API.Auth = true
+	s := New(node, cfg)
+
+	ts := node.Repo.(repo.TokenStore)
+	secrets := map[repo.TokenScope]string{}
+	for _, scope := range []repo.TokenScope{repo.ScopeRead, repo.ScopeWrite, repo.ScopeAdmin} {
+		tok, secret, err := repo.NewToken(string(scope), scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ts.PutToken(tok); err != nil {
+			t.Fatal(err)
+		}
+		secrets[scope] = secret
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		if _, has := TokenFromCtx(r.Context()); !has && r.Method != "OPTIONS" {
+			t.Errorf("%s %s: expected token in request context", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	h := s.scoped(repo.ScopeRead, repo.ScopeWrite, ok)
+
+	cases := []struct {
+		method, auth string
+		expect       int
+	}{
+		{"OPTIONS", "", http.StatusOK},
+		{"GET", "", http.StatusUnauthorized},
+		{"GET", "Bearer not-a-token", http.StatusUnauthorized},
+		{"GET", "Basic " + secrets[repo.ScopeRead], http.StatusUnauthorized},
+		{"GET", "Bearer " + secrets[repo.ScopeRead], http.StatusOK},
+		{"GET", "bearer " + secrets[repo.ScopeRead], http.StatusOK},
+		{"POST", "Bearer " + secrets[repo.ScopeRead], http.StatusForbidden},
+		{"POST", "Bearer " + secrets[repo.ScopeWrite], http.StatusOK},
+		{"DELETE", "Bearer " + secrets[repo.ScopeAdmin], http.StatusOK},
+	}
+
+	for i, c := range cases {
+		r := httptest.NewRequest(c.method, "/me/cities", nil)
+		if c.auth != "" {
+			r.Header.Set("Authorization", c.auth)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != c.expect {
+			t.Errorf("case %d %s %q: expected status %d, got: %d. body: %s", i, c.method, c.auth, c.expect, w.Code, w.Body.String())
+		}
+	}
+
+	events, err := node.Repo.Events(100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutations := 0
+	for _, e := range events {
+		if e.Type != repo.ETAPIMutation {
+			continue
+		}
+		mutations++
+		params, _ := e.Params.(map[string]interface{})
+		if params["tokenID"] == nil || params["tokenID"] == "" {
+			t.Errorf("expected mutation event to record a token ID, got params: %v", e.Params)
+		}
+	}
+	if mutations != 2 {
+		t.Errorf("expected 2 logged mutations, got: %d", mutations)
+	}
+}
+
+func TestScopedAuthDisabled(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	s := New(node, config.DefaultConfigForTesting())
+	h := s.scoped(repo.ScopeAdmin, repo.ScopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("POST", "/connect/peer", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected requests to pass through when auth is disabled, got status: %d", w.Code)
+	}
+}
+
+func TestStatusRequiresNoToken(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	cfg := config.DefaultConfigForTesting()
+	cfg.API.Auth = true
+	m := NewServerRoutes(New(node, cfg))
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected /status to respond without a token, got status: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/list", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected /list to require a token, got status: %d", w.Code)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/base"
+)
+
+// bodyMediaType describes a media type the /body/ endpoint can stream
+type bodyMediaType struct {
+	ContentType string
+	Format      dataset.DataFormat
+	// Encoding optionally names a base body encoding to write entries with
+	Encoding string
+}
+
+// bodyMediaTypes lists streamable body encodings, in order of preference.
+// requests that don't ask for one of these get the default JSON page response
+var bodyMediaTypes = []bodyMediaType{
+	{"text/csv", dataset.CSVDataFormat, ""},
+	{"application/x-ndjson", dataset.JSONDataFormat, base.NDJSONEncoding},
+	{"application/cbor", dataset.CBORDataFormat, ""},
+	{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", dataset.JSONDataFormat, base.XLSXEncoding},
+	{"application/vnd.apache.parquet", dataset.JSONDataFormat, base.ParquetEncoding},
+}
+
+// negotiateBodyType picks a streamable media type from an Accept header,
+// returning nil if the client prefers JSON or didn't ask for anything
+// specific. Accept quality values are honoured, wildcards are not
+func negotiateBodyType(accept string) *bodyMediaType {
+	type choice struct {
+		mt *bodyMediaType
+		q  float64
+	}
+	var choices []choice
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		if name == "application/json" {
+			choices = append(choices, choice{nil, q})
+			continue
+		}
+		for i, mt := range bodyMediaTypes {
+			if mt.ContentType == name {
+				choices = append(choices, choice{&bodyMediaTypes[i], q})
+			}
+		}
+	}
+
+	if len(choices) == 0 {
+		return nil
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	return choices[0].mt
+}
+
+// rowRange is a window of body entries requested with a "Range: rows=" header
+type rowRange struct {
+	Offset int
+	// Limit is the number of rows requested, -1 means all rows from Offset on
+	Limit int
+}
+
+// String formats the range for a Content-Range header. the total number of
+// rows isn't known while streaming, so it's always "*"
+func (rr rowRange) String() string {
+	if rr.Limit < 0 {
+		return fmt.Sprintf("rows %d-*/*", rr.Offset)
+	}
+	return fmt.Sprintf("rows %d-%d/*", rr.Offset, rr.Offset+rr.Limit-1)
+}
+
+// parseRowRange reads a "Range: rows=start-end" header. end is inclusive and
+// can be omitted to request all rows from start onward. returns nil if the
+// request has no Range header
+func parseRowRange(r *http.Request) (*rowRange, error) {
+	h := r.Header.Get("Range")
+	if h == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(h, "rows=") {
+		return nil, fmt.Errorf("unsupported range unit. only 'rows' ranges are supported")
+	}
+
+	bounds := strings.Split(h[len("rows="):], "-")
+	if len(bounds) != 2 || strings.Contains(bounds[1], ",") {
+		return nil, fmt.Errorf("invalid range '%s'. expected rows=start-end", h)
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil || start < 0 {
+		return nil, fmt.Errorf("invalid range start '%s'", bounds[0])
+	}
+	if strings.TrimSpace(bounds[1]) == "" {
+		return &rowRange{Offset: start, Limit: -1}, nil
+	}
+
+	end, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil || end < start {
+		return nil, fmt.Errorf("invalid range end '%s'", bounds[1])
+	}
+	return &rowRange{Offset: start, Limit: end - start + 1}, nil
+}
+
+// streamWriter defers writing response headers until the first byte of the
+// body is written, so errors that happen before streaming starts can still
+// be reported with an error status
+type streamWriter struct {
+	w       http.ResponseWriter
+	status  int
+	started bool
+}
+
+// Write writes the response header on first call, then p
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.started = true
+		sw.w.WriteHeader(sw.status)
+	}
+	return sw.w.Write(p)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateBodyType(t *testing.T) {
+	cases := []struct {
+		accept string
+		expect string
+	}{
+		{"", ""},
+		{"*/*", ""},
+		{"application/json", ""},
+		{"text/csv", "text/csv"},
+		{"application/x-ndjson", "application/x-ndjson"},
+		{"application/cbor", "application/cbor"},
+		{"application/vnd.apache.parquet", "application/vnd.apache.parquet"},
+		{"text/html, text/csv", "text/csv"},
+		{"application/json, text/csv", ""},
+		{"application/json;q=0.5, text/csv", "text/csv"},
+		{"text/csv;q=0, application/cbor", "application/cbor"},
+	}
+
+	for i, c := range cases {
+		got := ""
+		if mt := negotiateBodyType(c.accept); mt != nil {
+			got = mt.ContentType
+		}
+		if got != c.expect {
+			t.Errorf("case %d %q: expected %q, got: %q", i, c.accept, c.expect, got)
+		}
+	}
+}
+
+func TestParseRowRange(t *testing.T) {
+	cases := []struct {
+		header string
+		expect string
+		err    string
+	}{
+		{"rows=0-9", "rows 0-9/*", ""},
+		{"rows=10-", "rows 10-*/*", ""},
+		{"rows=5-5", "rows 5-5/*", ""},
+		{"bytes=0-100", "", "unsupported range unit. only 'rows' ranges are supported"},
+		{"rows=9-0", "", "invalid range end '0'"},
+		{"rows=-5", "", "invalid range start ''"},
+		{"rows=0-1,4-5", "", "invalid range 'rows=0-1,4-5'. expected rows=start-end"},
+	}
+
+	for i, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Range", c.header)
+		rr, err := parseRowRange(r)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if rr != nil && rr.String() != c.expect {
+			t.Errorf("case %d expected: %q, got: %q", i, c.expect, rr.String())
+		}
+	}
+}
+
+func TestBodyHandlerStreaming(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+	h := NewDatasetHandlers(node, false)
+
+	cases := []struct {
+		accept, rng  string
+		status       int
+		contentType  string
+		contentRange string
+		lines        int
+	}{
+		{"text/csv", "", http.StatusOK, "text/csv", "", 101},
+		{"text/csv", "rows=0-4", http.StatusPartialContent, "text/csv", "rows 0-4/*", 6},
+		{"application/x-ndjson", "rows=2-3", http.StatusPartialContent, "application/x-ndjson", "rows 2-3/*", 2},
+		{"application/x-ndjson", "pages=1-2", http.StatusRequestedRangeNotSatisfiable, "", "", 0},
+	}
+
+	for i, c := range cases {
+		r := httptest.NewRequest("GET", "/body/peer/movies", nil)
+		r.Header.Set("Accept", c.accept)
+		if c.rng != "" {
+			r.Header.Set("Range", c.rng)
+		}
+		w := httptest.NewRecorder()
+		h.BodyHandler(w, r)
+
+		if w.Code != c.status {
+			t.Errorf("case %d expected status %d, got: %d. body: %s", i, c.status, w.Code, w.Body.String())
+			continue
+		}
+		if c.status >= 400 {
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); got != c.contentType {
+			t.Errorf("case %d expected content type %q, got: %q", i, c.contentType, got)
+		}
+		if got := w.Header().Get("Content-Range"); got != c.contentRange {
+			t.Errorf("case %d expected content range %q, got: %q", i, c.contentRange, got)
+		}
+
+		lines := 0
+		sc := bufio.NewScanner(strings.NewReader(w.Body.String()))
+		for sc.Scan() {
+			lines++
+		}
+		if lines != c.lines {
+			t.Errorf("case %d expected %d lines, got: %d", i, c.lines, lines)
+		}
+	}
+}
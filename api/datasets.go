@@ -18,6 +18,7 @@ import (
 	"github.com/qri-io/dataset/dsutil"
 	"github.com/qri-io/dsdiff"
 	"github.com/qri-io/qri/actions"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/p2p"
 	"github.com/qri-io/qri/repo"
@@ -31,12 +32,15 @@ type DatasetHandlers struct {
 	node     *p2p.QriNode
 	repo     repo.Repo
 	ReadOnly bool
+	// uploads holds staged body uploads that can be saved by ID. nil
+	// disables saving uploads
+	uploads *uploadStore
 }
 
 // NewDatasetHandlers allocates a DatasetHandlers pointer
 func NewDatasetHandlers(node *p2p.QriNode, readOnly bool) *DatasetHandlers {
 	req := lib.NewDatasetRequests(node, nil)
-	h := DatasetHandlers{*req, node, node.Repo, readOnly}
+	h := DatasetHandlers{DatasetRequests: *req, node: node, repo: node.Repo, ReadOnly: readOnly}
 	return &h
 }
 
@@ -164,6 +168,18 @@ func (h *DatasetHandlers) BodyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// StatsHandler gets column statistics of a dataset version
+func (h *DatasetHandlers) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		util.EmptyOkHandler(w, r)
+	case "GET":
+		h.statsHandler(w, r)
+	default:
+		util.NotFoundHandler(w, r)
+	}
+}
+
 // UnpackHandler unpacks a zip file and sends it back as json
 func (h *DatasetHandlers) UnpackHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -312,6 +328,21 @@ func (h *DatasetHandlers) getHandler(w http.ResponseWriter, r *http.Request) {
 	util.WriteResponse(w, res)
 }
 
+func (h *DatasetHandlers) statsHandler(w http.ResponseWriter, r *http.Request) {
+	args, err := DatasetRefFromPath(r.URL.Path[len("/stats"):])
+	if err != nil {
+		util.WriteErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res := &base.Stats{}
+	if err := h.Stats(&args, res); err != nil {
+		util.WriteErrResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	util.WriteResponse(w, res)
+}
+
 type diffAPIParams struct {
 	Left, Right string
 	Format      string
@@ -351,7 +382,11 @@ func (h *DatasetHandlers) diffHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err = h.Diff(p, &diffs); err != nil {
-		util.WriteErrResponse(w, http.StatusInternalServerError, fmt.Errorf("error diffing datasets: %s", err))
+		if _, ok := err.(actions.StatsUnavailableError); !ok {
+			util.WriteErrResponse(w, http.StatusInternalServerError, fmt.Errorf("error diffing datasets: %s", err))
+			return
+		}
+		w.Header().Set("Warning", fmt.Sprintf(`199 qri "%s"`, err.Error()))
 	}
 
 	if d.Format != "" {
@@ -452,6 +487,11 @@ func (h *DatasetHandlers) addHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isAsync(r) {
+		submitJob(w, h.node, &lib.JobParams{Add: &ref})
+		return
+	}
+
 	res := repo.DatasetRef{}
 	err = h.Add(&ref, &res)
 	if err != nil {
@@ -490,6 +530,24 @@ func (h *DatasetHandlers) saveHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	uploadID := r.FormValue("upload")
+	if uploadID != "" {
+		if h.uploads == nil {
+			util.WriteErrResponse(w, http.StatusBadRequest, fmt.Errorf("uploads aren't supported"))
+			return
+		}
+		if dsp.BodyPath != "" || dsp.BodyBytes != nil {
+			util.WriteErrResponse(w, http.StatusBadRequest, fmt.Errorf("can't save an upload along with a body"))
+			return
+		}
+		path, err := h.uploads.path(uploadID)
+		if err != nil {
+			util.WriteErrResponse(w, uploadErrStatus(err), err)
+			return
+		}
+		dsp.BodyPath = path
+	}
+
 	res := &repo.DatasetRef{}
 	scriptOutput := &bytes.Buffer{}
 	p := &lib.SaveParams{
@@ -498,7 +556,18 @@ func (h *DatasetHandlers) saveHandler(w http.ResponseWriter, r *http.Request) {
 		DryRun:              r.FormValue("dry_run") == "true",
 		ReturnBody:          r.FormValue("return_body") == "true",
 		ConvertFormatToPrev: true,
+		Strict:              r.FormValue("strict") == "true",
 		ScriptOutput:        scriptOutput,
+		Ctx:                 actions.RefuseExec(r.Context()),
+	}
+
+	if r.FormValue("rules") != "" {
+		rules, err := base.ParseRules([]byte(r.FormValue("rules")))
+		if err != nil {
+			util.WriteErrResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		p.Rules = rules
 	}
 
 	if r.FormValue("secrets") != "" {
@@ -512,10 +581,41 @@ func (h *DatasetHandlers) saveHandler(w http.ResponseWriter, r *http.Request) {
 		p.Secrets = dsp.Transform.Secrets
 	}
 
+	// hold the upload while it's saved, so it can't be pruned or written to
+	if uploadID != "" {
+		if err := h.uploads.hold(uploadID); err != nil {
+			util.WriteErrResponse(w, uploadErrStatus(err), err)
+			return
+		}
+		defer h.uploads.release(uploadID)
+	}
+
+	if isAsync(r) {
+		p.ScriptOutput = nil
+		p.ReturnBody = false
+		jp := &lib.JobParams{Save: p}
+		if uploadID != "" && !p.DryRun {
+			// the job removes the upload once it's saved
+			jp.Cleanup = []string{filepath.Dir(dsp.BodyPath)}
+		}
+		job, err := submitJob(w, h.node, jp)
+		if err == nil && uploadID != "" {
+			if err := h.uploads.claim(uploadID, job.ID); err != nil {
+				log.Debugf("error claiming upload: %s", err.Error())
+			}
+		}
+		return
+	}
+
 	if err := h.Save(p, res); err != nil {
 		util.WriteErrResponse(w, http.StatusInternalServerError, err)
 		return
 	}
+	if uploadID != "" && !p.DryRun {
+		if err := h.uploads.remove(uploadID); err != nil {
+			log.Infof("error removing saved upload: %s", err.Error())
+		}
+	}
 	// Don't leak paths across the API, it's possible they contain absolute paths or tmp dirs.
 	res.Dataset.BodyPath = filepath.Base(res.Dataset.BodyPath)
 
@@ -645,6 +745,13 @@ func (h DatasetHandlers) bodyHandler(w http.ResponseWriter, r *http.Request) {
 		err = nil
 	}
 
+	if mt := negotiateBodyType(r.Header.Get("Accept")); mt != nil {
+		if status, err := h.streamBody(w, r, d, mt, limit, offset); err != nil {
+			util.WriteErrResponse(w, status, err)
+		}
+		return
+	}
+
 	p := &lib.LookupParams{
 		Path:   d.Path,
 		Format: dataset.JSONDataFormat,
@@ -652,6 +759,7 @@ func (h DatasetHandlers) bodyHandler(w http.ResponseWriter, r *http.Request) {
 		Offset: offset,
 		All:    r.FormValue("all") == "true" && limit == defaultDataLimit && offset == 0,
 	}
+	p.Limit, p.All = capRows(r, p.Limit, p.All)
 
 	result := &lib.LookupResult{}
 	if err := h.LookupBody(p, result); err != nil {
@@ -669,6 +777,69 @@ func (h DatasetHandlers) bodyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// streamBody writes a dataset body in the negotiated media type without
+// buffering it. a "Range: rows=start-end" header selects a window of entries,
+// taking precedence over limit & offset params. streamBody only returns an
+// error if nothing has been written, leaving the caller to write an error
+// response with the returned status
+func (h DatasetHandlers) streamBody(w http.ResponseWriter, r *http.Request, d repo.DatasetRef, mt *bodyMediaType, limit, offset int) (int, error) {
+	rr, err := parseRowRange(r)
+	if err != nil {
+		return http.StatusRequestedRangeNotSatisfiable, err
+	}
+
+	p := &lib.LookupParams{
+		Ref:      d,
+		Path:     d.Path,
+		Format:   mt.Format,
+		Limit:    limit,
+		Offset:   offset,
+		All:      r.FormValue("all") == "true",
+		Encoding: mt.Encoding,
+	}
+	if mt.Format == dataset.CSVDataFormat {
+		p.FormatConfig = &dataset.CSVOptions{HeaderRow: r.FormValue("header") != "false"}
+	}
+
+	status := http.StatusOK
+	if rr != nil {
+		p.Offset = rr.Offset
+		p.Limit = rr.Limit
+		p.All = rr.Limit < 0
+		if p.All {
+			p.Limit = 0
+		}
+		status = http.StatusPartialContent
+	}
+
+	p.Limit, p.All = capRows(r, p.Limit, p.All)
+	if rr != nil {
+		// report the range actually sent, which may be clamped by row limits
+		if !p.All {
+			rr.Limit = p.Limit
+		}
+		w.Header().Set("Content-Range", rr.String())
+	}
+
+	w.Header().Set("Content-Type", mt.ContentType)
+	w.Header().Set("Accept-Ranges", "rows")
+	sw := &streamWriter{w: w, status: status}
+	if _, err := h.LookupBodyTo(sw, p); err != nil {
+		if !sw.started {
+			w.Header().Del("Content-Range")
+			w.Header().Set("Content-Type", "application/json")
+			return http.StatusInternalServerError, err
+		}
+		// headers are already sent, all we can do is stop writing
+		log.Infof("error streaming body: %s", err.Error())
+		return status, nil
+	}
+	if !sw.started {
+		w.WriteHeader(status)
+	}
+	return status, nil
+}
+
 func (h DatasetHandlers) publishHandler(w http.ResponseWriter, r *http.Request, publish bool) {
 	ref, err := DatasetRefFromPath(r.URL.Path[len("/publish"):])
 	if err != nil {
@@ -682,6 +853,11 @@ func (h DatasetHandlers) publishHandler(w http.ResponseWriter, r *http.Request,
 		UpdateRegistry:    r.FormValue("no_registry") != "true",
 		UpdateRegistryPin: r.FormValue("no_pin") != "true",
 	}
+	if isAsync(r) {
+		submitJob(w, h.node, &lib.JobParams{Publish: p})
+		return
+	}
+
 	var ok bool
 	if err := h.DatasetRequests.SetPublishStatus(p, &ok); err != nil {
 		util.WriteErrResponse(w, http.StatusInternalServerError, err)
@@ -703,6 +879,7 @@ func (h DatasetHandlers) updateHandler(w http.ResponseWriter, r *http.Request) {
 		Message:    r.FormValue("message"),
 		DryRun:     r.FormValue("dry_run") == "true",
 		ReturnBody: false,
+		Ctx:        actions.RefuseExec(r.Context()),
 	}
 
 	if r.FormValue("secrets") != "" {
@@ -713,6 +890,11 @@ func (h DatasetHandlers) updateHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if isAsync(r) {
+		submitJob(w, h.node, &lib.JobParams{Update: p})
+		return
+	}
+
 	res := &repo.DatasetRef{}
 	if err := h.DatasetRequests.Update(p, res); err != nil {
 		util.WriteErrResponse(w, http.StatusInternalServerError, err)
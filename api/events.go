@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	util "github.com/datatogether/api/apiutil"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+)
+
+// eventsHeartbeatInterval is how often an idle event stream sends a comment
+// to keep proxies from closing the connection
+var eventsHeartbeatInterval = time.Second * 15
+
+// EventsHandler streams node activity as server-sent events. Each event is
+// sent with the event type as the SSE event name, and a JSON-encoded
+// p2p.NodeEvent as data. An optional "types" param limits the stream to a
+// comma-separated list of event types. Transform script output can carry
+// anything a script prints, so when auth is enabled it's only streamed to
+// admin tokens
+func (s *Server) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		util.EmptyOkHandler(w, r)
+	case "GET":
+		s.eventsHandler(w, r)
+	default:
+		util.NotFoundHandler(w, r)
+	}
+}
+
+func (s *Server) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		util.WriteErrResponse(w, http.StatusInternalServerError, fmt.Errorf("response doesn't support streaming"))
+		return
+	}
+
+	var types map[p2p.NodeEventType]bool
+	if str := r.FormValue("types"); str != "" {
+		types = map[p2p.NodeEventType]bool{}
+		for _, t := range strings.Split(str, ",") {
+			types[p2p.NodeEventType(strings.TrimSpace(t))] = true
+		}
+	}
+
+	hideOutput := false
+	if s.cfg.API.Auth {
+		t, _ := TokenFromCtx(r.Context())
+		hideOutput = !t.Scope.Allows(repo.ScopeAdmin)
+	}
+
+	events := s.qriNode.SubscribeEvents(r.Context())
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if types != nil && !types[e.Type] || hideOutput && e.Type == p2p.NETransformOutput {
+				continue
+			}
+			if err := writeServerSentEvent(w, e); err != nil {
+				log.Infof("error writing event: %s", err.Error())
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// writeServerSentEvent writes a single node event in the text/event-stream
+// format
+func writeServerSentEvent(w http.ResponseWriter, e p2p.NodeEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return err
+}
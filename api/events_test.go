@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+)
+
+func TestEventsHandler(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	s := New(node, config.DefaultConfigForTesting())
+	server := httptest.NewServer(http.HandlerFunc(s.EventsHandler))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest("GET", server.URL+"/events?types=progress", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected content type text/event-stream, got: %s", ct)
+	}
+
+	// filtered out by the types param
+	node.PublishEvent(p2p.NETransformOutput, "ignored")
+	node.PublishEvent(p2p.NEProgress, p2p.Progress{Op: "save", Ref: "peer/cities", Status: "done"})
+
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+
+	expect := []string{
+		"event: progress",
+		`data: {"type":"progress",`,
+	}
+	for _, exp := range expect {
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, exp) {
+				t.Errorf("expected line to start with %q, got: %q", exp, line)
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatalf("timed out waiting for %q", exp)
+		}
+	}
+}
+
+func TestEventsHandlerHidesScriptOutput(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	cfg := config.DefaultConfigForTesting()
+	cfg.API.Auth = true
+	s := New(node, cfg)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), TokenCtxKey, repo.Token{ID: "reader", Scope: repo.ScopeRead})
+		s.EventsHandler(w, r.WithContext(ctx))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest("GET", server.URL+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	// script output is only streamed to admin tokens
+	node.PublishEvent(p2p.NETransformOutput, "secret output")
+	node.PublishEvent(p2p.NEProgress, p2p.Progress{Op: "save", Ref: "peer/cities", Status: "done"})
+
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+
+	select {
+	case line := <-lines:
+		if line != "event: progress" {
+			t.Errorf("expected script output to be hidden from a read token, got: %q", line)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("timed out waiting for progress event")
+	}
+}
@@ -0,0 +1,557 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	util "github.com/datatogether/api/apiutil"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/graphql"
+	"github.com/qri-io/qri/lib"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+)
+
+// graphqlLoaderCtxKey is the key for adding the loader for a graphql query to
+// a context
+const graphqlLoaderCtxKey QriCtxKey = "graphqlLoader"
+
+const (
+	// graphqlMaxDepth is the deepest the fields of a query can nest
+	graphqlMaxDepth = 12
+	// graphqlMaxCost is the most fields & list items a query can resolve
+	graphqlMaxCost = 10000
+)
+
+// GraphQLHandlers executes GraphQL queries over datasets, history, peers and
+// profiles. Queries let clients fetch in one request what would otherwise take
+// a round-trip to /list, /me/, /history/ & /profile for each dataset
+type GraphQLHandlers struct {
+	node   *p2p.QriNode
+	schema *graphql.Schema
+}
+
+// NewGraphQLHandlers allocates a GraphQLHandlers pointer
+func NewGraphQLHandlers(node *p2p.QriNode) *GraphQLHandlers {
+	return &GraphQLHandlers{node: node, schema: newGraphQLSchema()}
+}
+
+// GraphQLHandler is the endpoint for GraphQL queries. Queries can be sent as
+// the "query" param of a GET request, or POSTed as JSON with query,
+// operationName & variables fields
+func (h *GraphQLHandlers) GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		util.EmptyOkHandler(w, r)
+	case "GET", "POST":
+		h.graphqlHandler(w, r)
+	default:
+		util.NotFoundHandler(w, r)
+	}
+}
+
+func (h *GraphQLHandlers) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	p, err := graphqlParams(r)
+	if err != nil {
+		util.WriteErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	p.Context = context.WithValue(r.Context(), graphqlLoaderCtxKey, newGraphQLLoader(h.node, r))
+	p.MaxDepth, p.MaxCost = graphqlMaxDepth, graphqlMaxCost
+
+	res := graphql.Do(h.schema, p)
+	w.Header().Set("Content-Type", "application/json")
+	if res.Data == nil && len(res.Errors) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Infof("error writing graphql response: %s", err.Error())
+	}
+}
+
+// graphqlParams reads a query from a request
+func graphqlParams(r *http.Request) (p graphql.Params, err error) {
+	if r.Method == "GET" {
+		p.Query = r.FormValue("query")
+		p.OperationName = r.FormValue("operationName")
+		if vars := r.FormValue("variables"); vars != "" {
+			if err = json.Unmarshal([]byte(vars), &p.Variables); err != nil {
+				return p, fmt.Errorf("error decoding variables: %s", err.Error())
+			}
+		}
+	} else if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return p, fmt.Errorf("error reading query: %s", err.Error())
+		}
+		p.Query = string(data)
+	} else if err = json.NewDecoder(r.Body).Decode(&p); err != nil {
+		return p, fmt.Errorf("error decoding query: %s", err.Error())
+	}
+
+	if p.Query == "" {
+		return p, fmt.Errorf("query is required")
+	}
+	return p, nil
+}
+
+// graphqlLoader fetches data for a single query. Datasets are cached by path
+// & profiles by peername, so a query that reaches the same dataset version
+// more than once, say through both history & previous, only reads it from
+// the store once
+type graphqlLoader struct {
+	r        *http.Request
+	datasets *lib.DatasetRequests
+	logs     *lib.LogRequests
+	peers    *lib.PeerRequests
+	profiles *lib.ProfileRequests
+
+	refs    map[string]*repo.DatasetRef
+	peerPro map[string]map[string]interface{}
+	// loads counts datasets read through lib
+	loads int
+	// rows counts body entries the query has read
+	rows int
+}
+
+func newGraphQLLoader(node *p2p.QriNode, r *http.Request) *graphqlLoader {
+	return &graphqlLoader{
+		r:        r,
+		datasets: lib.NewDatasetRequests(node, nil),
+		logs:     lib.NewLogRequests(node, nil),
+		peers:    lib.NewPeerRequests(node, nil),
+		profiles: lib.NewProfileRequests(node, nil),
+		refs:     map[string]*repo.DatasetRef{},
+		peerPro:  map[string]map[string]interface{}{},
+	}
+}
+
+// loaderFromCtx gets the loader for the query being executed
+func loaderFromCtx(ctx context.Context) *graphqlLoader {
+	return ctx.Value(graphqlLoaderCtxKey).(*graphqlLoader)
+}
+
+// graphqlMaxRows is the most body entries a query can read when no
+// MaxResponseRows limit applies. query results are built in memory, so body
+// fields are never unbounded
+var graphqlMaxRows = 10000
+
+// maxRows gives the most body entries the query can read
+func (l *graphqlLoader) maxRows() int {
+	if max := LimitsFromCtx(l.r.Context()).MaxResponseRows; max > 0 {
+		return max
+	}
+	return graphqlMaxRows
+}
+
+// takeRows reserves up to n body entries of the query's row limit, so body
+// fields of a query can't add up to more entries than a single response is
+// allowed
+func (l *graphqlLoader) takeRows(n int) (int, error) {
+	max := l.maxRows()
+	left := max - l.rows
+	if left <= 0 {
+		return 0, fmt.Errorf("query reads more than the maximum of %d body entries", max)
+	}
+	if n > left {
+		n = left
+	}
+	l.rows += n
+	return n, nil
+}
+
+// prime adds refs that already carry a dataset to the cache
+func (l *graphqlLoader) prime(refs []repo.DatasetRef) []*repo.DatasetRef {
+	res := make([]*repo.DatasetRef, len(refs))
+	for i := range refs {
+		ref := &refs[i]
+		if cached, ok := l.refs[ref.Path]; ok && ref.Path != "" {
+			ref = cached
+		} else if ref.Dataset != nil && ref.Path != "" {
+			l.refs[ref.Path] = ref
+		}
+		res[i] = ref
+	}
+	return res
+}
+
+// dataset returns ref with it's dataset loaded
+func (l *graphqlLoader) dataset(ref *repo.DatasetRef) (*repo.DatasetRef, error) {
+	if ref.Dataset != nil {
+		return ref, nil
+	}
+	if cached, ok := l.refs[ref.Path]; ok && ref.Path != "" {
+		return cached, nil
+	}
+
+	in := *ref
+	res := &repo.DatasetRef{}
+	if err := l.datasets.Get(&in, res); err != nil {
+		return nil, err
+	}
+	l.loads++
+	if cached, ok := l.refs[res.Path]; ok {
+		return cached, nil
+	}
+	l.refs[res.Path] = res
+	return res, nil
+}
+
+// profile returns the profile of a peer as a JSON object
+func (l *graphqlLoader) profile(peername string) (map[string]interface{}, error) {
+	if pro, ok := l.peerPro[peername]; ok {
+		return pro, nil
+	}
+	res := &config.ProfilePod{}
+	if err := l.peers.Info(&lib.PeerInfoParams{Peername: peername}, res); err != nil {
+		return nil, err
+	}
+	pro, err := profileObject(res)
+	if err != nil {
+		return nil, err
+	}
+	l.peerPro[peername] = pro
+	return pro, nil
+}
+
+// profileObject converts a profile to a JSON object, dropping the private key
+func profileObject(pro *config.ProfilePod) (map[string]interface{}, error) {
+	obj, err := jsonObject(pro)
+	if err != nil {
+		return nil, err
+	}
+	delete(obj, "privkey")
+	return obj, nil
+}
+
+// jsonObject converts a value to a generic JSON object, fields of the object
+// are resolved by their JSON names
+func jsonObject(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	err = json.Unmarshal(data, &obj)
+	return obj, err
+}
+
+// pageArgs are the arguments to fields that return a page of results
+func pageArgs(limit int) graphql.Args {
+	return graphql.Args{
+		"limit":  {Type: graphql.Int, Default: limit, Description: "maximum number of items to return"},
+		"offset": {Type: graphql.Int, Default: 0, Description: "number of items to skip"},
+	}
+}
+
+// page reads page arguments
+func page(args map[string]interface{}) (limit, offset int, err error) {
+	// arguments explicitly set to null aren't filled with defaults
+	limit, _ = args["limit"].(int)
+	offset, _ = args["offset"].(int)
+	if limit < 0 || offset < 0 {
+		err = fmt.Errorf("limit & offset must be positive")
+	}
+	return
+}
+
+// newGraphQLSchema builds the schema served at /graphql
+func newGraphQLSchema() *graphql.Schema {
+	str, strs, raw := graphql.String, graphql.NewList(graphql.String), graphql.JSON
+
+	profile := &graphql.Object{Name: "Profile", Fields: graphql.Fields{
+		"id":          {Type: graphql.NewNonNull(graphql.ID)},
+		"peername":    {Type: str},
+		"created":     {Type: str},
+		"updated":     {Type: str},
+		"type":        {Type: str},
+		"email":       {Type: str},
+		"name":        {Type: str},
+		"description": {Type: str},
+		"homeurl":     {Type: str},
+		"color":       {Type: str},
+		"thumb":       {Type: str},
+		"photo":       {Type: str},
+		"poster":      {Type: str},
+		"twitter":     {Type: str},
+		"online":      {Type: graphql.Boolean},
+	}}
+
+	commit := &graphql.Object{Name: "Commit", Fields: graphql.Fields{
+		"path":      {Type: str},
+		"qri":       {Type: str},
+		"title":     {Type: str},
+		"message":   {Type: str},
+		"timestamp": {Type: str},
+		"signature": {Type: str},
+		"author":    {Type: raw},
+	}}
+
+	meta := &graphql.Object{Name: "Meta", Fields: graphql.Fields{
+		"path":               {Type: str},
+		"qri":                {Type: str},
+		"title":              {Type: str},
+		"description":        {Type: str},
+		"keywords":           {Type: strs},
+		"theme":              {Type: strs},
+		"language":           {Type: strs},
+		"version":            {Type: str},
+		"identifier":         {Type: str},
+		"accessPath":         {Type: str},
+		"downloadPath":       {Type: str},
+		"homePath":           {Type: str},
+		"readmePath":         {Type: str},
+		"accrualPeriodicity": {Type: str},
+		"license":            {Type: raw},
+		"citations":          {Type: raw},
+		"contributors":       {Type: raw},
+	}}
+
+	structure := &graphql.Object{Name: "Structure", Fields: graphql.Fields{
+		"path":         {Type: str},
+		"qri":          {Type: str},
+		"checksum":     {Type: str},
+		"compression":  {Type: str},
+		"encoding":     {Type: str},
+		"format":       {Type: str},
+		"formatConfig": {Type: raw},
+		"depth":        {Type: graphql.Int},
+		"entries":      {Type: graphql.Int},
+		"errCount":     {Type: graphql.Int},
+		"length":       {Type: graphql.Int},
+		"schema":       {Type: raw},
+	}}
+
+	transform := &graphql.Object{Name: "Transform", Fields: graphql.Fields{
+		"path":          {Type: str},
+		"qri":           {Type: str},
+		"syntax":        {Type: str},
+		"syntaxVersion": {Type: str},
+		"scriptPath":    {Type: str},
+		"config":        {Type: raw},
+		"resources":     {Type: raw},
+	}}
+
+	viz := &graphql.Object{Name: "Viz", Fields: graphql.Fields{
+		"path":       {Type: str},
+		"qri":        {Type: str},
+		"format":     {Type: str},
+		"scriptPath": {Type: str},
+	}}
+
+	ds := &graphql.Object{Name: "Dataset", Fields: graphql.Fields{
+		"path":         {Type: str},
+		"qri":          {Type: str},
+		"previousPath": {Type: str},
+		"bodyPath":     {Type: str},
+		"commit":       {Type: commit},
+		"meta":         {Type: meta},
+		"structure":    {Type: structure},
+		"transform":    {Type: transform},
+		"viz":          {Type: viz},
+	}}
+
+	ref := &graphql.Object{Name: "DatasetRef"}
+	ref.Fields = graphql.Fields{
+		"peername":  {Type: str},
+		"profileID": {Type: str},
+		"name":      {Type: str},
+		"path":      {Type: str},
+		"published": {Type: graphql.Boolean},
+		"alias": {
+			Type:        str,
+			Description: "peername/name",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*repo.DatasetRef).AliasString(), nil
+			},
+		},
+		"dataset": {
+			Type: ds,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				res, err := loaderFromCtx(p.Context).dataset(p.Source.(*repo.DatasetRef))
+				if err != nil {
+					return nil, err
+				}
+				return jsonObject(res.Dataset)
+			},
+		},
+		"profile": {
+			Type: profile,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return loaderFromCtx(p.Context).profile(p.Source.(*repo.DatasetRef).Peername)
+			},
+		},
+		"previous": {
+			Type:        ref,
+			Description: "the version before this one",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				l := loaderFromCtx(p.Context)
+				res, err := l.dataset(p.Source.(*repo.DatasetRef))
+				if err != nil {
+					return nil, err
+				}
+				if res.Dataset.PreviousPath == "" {
+					return nil, nil
+				}
+				return l.dataset(&repo.DatasetRef{
+					Peername:  res.Peername,
+					ProfileID: res.ProfileID,
+					Name:      res.Name,
+					Path:      res.Dataset.PreviousPath,
+				})
+			},
+		},
+		"history": {
+			Type:        graphql.NewList(graphql.NewNonNull(ref)),
+			Description: "versions of the dataset, starting with this one",
+			Args:        pageArgs(lib.DefaultPageSize),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				limit, offset, err := page(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				src := p.Source.(*repo.DatasetRef)
+				params := &lib.LogParams{
+					ListParams: lib.ListParams{Limit: limit, Offset: offset},
+					Ref:        repo.DatasetRef{Peername: src.Peername, ProfileID: src.ProfileID, Name: src.Name, Path: src.Path},
+				}
+				l := loaderFromCtx(p.Context)
+				res := []repo.DatasetRef{}
+				if err := l.logs.Log(params, &res); err != nil {
+					return nil, err
+				}
+				return l.prime(res), nil
+			},
+		},
+		"body": {
+			Type:        raw,
+			Description: "a page of body entries",
+			Args: graphql.Args{
+				"limit":  {Type: graphql.Int, Default: defaultDataLimit},
+				"offset": {Type: graphql.Int, Default: 0},
+				"all":    {Type: graphql.Boolean, Default: false, Description: "return all entries, ignoring limit. entries are still capped by the query's row limit"},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				limit, offset, err := page(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				l := loaderFromCtx(p.Context)
+				src := p.Source.(*repo.DatasetRef)
+				lp := &lib.LookupParams{
+					Ref:    *src,
+					Path:   src.Path,
+					Format: dataset.JSONDataFormat,
+					Limit:  limit,
+					Offset: offset,
+					All:    p.Args["all"] == true,
+				}
+				lp.Ref.Dataset = nil
+				if lp.All {
+					lp.Limit, lp.All = l.maxRows(), false
+				}
+				if lp.Limit, err = l.takeRows(lp.Limit); err != nil {
+					return nil, err
+				}
+
+				res := &lib.LookupResult{}
+				if err := l.datasets.LookupBody(lp, res); err != nil {
+					return nil, err
+				}
+				return json.RawMessage(res.Data), nil
+			},
+		},
+	}
+
+	query := &graphql.Object{Name: "Query", Fields: graphql.Fields{
+		"me": {
+			Type:        profile,
+			Description: "this node's profile",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				in := true
+				res := &config.ProfilePod{}
+				if err := loaderFromCtx(p.Context).profiles.GetProfile(&in, res); err != nil {
+					return nil, err
+				}
+				return profileObject(res)
+			},
+		},
+		"peer": {
+			Type: profile,
+			Args: graphql.Args{"peername": {Type: graphql.NewNonNull(str)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return loaderFromCtx(p.Context).profile(p.Args["peername"].(string))
+			},
+		},
+		"peers": {
+			Type: graphql.NewList(graphql.NewNonNull(profile)),
+			Args: func() graphql.Args {
+				args := pageArgs(lib.DefaultPageSize)
+				args["cached"] = &graphql.Arg{Type: graphql.Boolean, Default: false, Description: "include peers that aren't connected"}
+				return args
+			}(),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				limit, offset, err := page(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				params := &lib.PeerListParams{Limit: limit, Offset: offset, Cached: p.Args["cached"] == true}
+				res := []*config.ProfilePod{}
+				if err := loaderFromCtx(p.Context).peers.List(params, &res); err != nil {
+					return nil, err
+				}
+				pros := make([]map[string]interface{}, len(res))
+				for i, pro := range res {
+					if pros[i], err = profileObject(pro); err != nil {
+						return nil, err
+					}
+				}
+				return pros, nil
+			},
+		},
+		"datasets": {
+			Type:        graphql.NewList(graphql.NewNonNull(ref)),
+			Description: "list datasets in this node's repo, or a peer's datasets",
+			Args: func() graphql.Args {
+				args := pageArgs(lib.DefaultPageSize)
+				args["peername"] = &graphql.Arg{Type: str}
+				return args
+			}(),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				limit, offset, err := page(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				params := &lib.ListParams{OrderBy: "created", Limit: limit, Offset: offset}
+				if peername, ok := p.Args["peername"].(string); ok {
+					params.Peername = peername
+				}
+				l := loaderFromCtx(p.Context)
+				res := []repo.DatasetRef{}
+				if err := l.datasets.List(params, &res); err != nil {
+					return nil, err
+				}
+				return l.prime(res), nil
+			},
+		},
+		"dataset": {
+			Type:        ref,
+			Description: "get a dataset by reference, like peername/name or peername/name@/ipfs/path",
+			Args:        graphql.Args{"ref": {Type: graphql.NewNonNull(str)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				ref, err := repo.ParseDatasetRef(p.Args["ref"].(string))
+				if err != nil {
+					return nil, err
+				}
+				return loaderFromCtx(p.Context).dataset(&ref)
+			},
+		},
+	}}
+
+	return &graphql.Schema{Query: query}
+}
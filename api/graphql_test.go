@@ -0,0 +1,248 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/graphql"
+	"github.com/qri-io/qri/lib"
+	"github.com/qri-io/qri/repo"
+)
+
+func TestGraphQLQuery(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	p := &lib.SaveParams{
+		Dataset: &dataset.DatasetPod{
+			Peername: "me",
+			Name:     "cities",
+			Meta:     &dataset.Meta{Title: "Updated Title"},
+		},
+	}
+	if err := lib.NewDatasetRequests(node, nil).Save(p, &repo.DatasetRef{}); err != nil {
+		t.Fatalf("error writing dataset update: %s", err.Error())
+	}
+
+	query := `{
+		dataset(ref: "peer/cities") {
+			alias
+			dataset { meta { title } }
+			history { path dataset { commit { title } } }
+			previous { path dataset { structure { format } } previous { path } }
+			body(limit: 2)
+		}
+	}`
+
+	l := newGraphQLLoader(node, httptest.NewRequest("POST", "/graphql", nil))
+	ctx := context.WithValue(context.Background(), graphqlLoaderCtxKey, l)
+	res := graphql.Do(newGraphQLSchema(), graphql.Params{Query: query, Context: ctx})
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", res.Errors[0])
+	}
+
+	data, err := json.Marshal(res.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := struct {
+		Dataset struct {
+			Alias   string
+			Dataset struct {
+				Meta struct{ Title string }
+			}
+			History []struct {
+				Path    string
+				Dataset struct {
+					Commit struct{ Title string }
+				}
+			}
+			Previous struct {
+				Path    string
+				Dataset struct {
+					Structure struct{ Format string }
+				}
+				Previous *struct{ Path string }
+			}
+			Body []interface{}
+		}
+	}{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	ds := got.Dataset
+	if ds.Alias != "peer/cities" {
+		t.Errorf("expected alias to be peer/cities, got: %s", ds.Alias)
+	}
+	if ds.Dataset.Meta.Title != "Updated Title" {
+		t.Errorf("expected meta title to be 'Updated Title', got: '%s'", ds.Dataset.Meta.Title)
+	}
+	if len(ds.History) != 2 {
+		t.Fatalf("expected 2 versions of history, got: %d", len(ds.History))
+	}
+	if ds.History[0].Dataset.Commit.Title == "" {
+		t.Error("expected history entries to have a commit title")
+	}
+	if ds.Previous.Path != ds.History[1].Path {
+		t.Errorf("expected previous path to be %s, got: %s", ds.History[1].Path, ds.Previous.Path)
+	}
+	if ds.Previous.Dataset.Structure.Format != "csv" {
+		t.Errorf("expected previous structure format to be csv, got: %s", ds.Previous.Dataset.Structure.Format)
+	}
+	if ds.Previous.Previous != nil {
+		t.Errorf("expected the first version to have no previous version, got: %v", ds.Previous.Previous)
+	}
+	if len(ds.Body) != 2 {
+		t.Errorf("expected 2 body entries, got: %d", len(ds.Body))
+	}
+
+	// the head is read once, previous versions come from history
+	if l.loads != 1 {
+		t.Errorf("expected datasets to be loaded once, got: %d", l.loads)
+	}
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	h := NewGraphQLHandlers(node)
+	server := httptest.NewServer(http.HandlerFunc(h.GraphQLHandler))
+	defer server.Close()
+
+	cases := []struct {
+		description string
+		req         *http.Request
+		status      int
+		expect      string
+	}{
+		{"GET with variables",
+			mustNewRequest(t, "GET", server.URL+"?"+url.Values{
+				"query":     {`query ($name: String!) { peer(peername: $name) { peername } }`},
+				"variables": {`{"name":"peer"}`},
+			}.Encode(), nil, ""),
+			http.StatusOK,
+			`{"data":{"peer":{"peername":"peer"}}}`},
+		{"POST json",
+			mustNewRequest(t, "POST", server.URL, []byte(`{"query":"{ me { peername } datasets(limit: 1) { peername } }"}`), "application/json"),
+			http.StatusOK,
+			`{"data":{"me":{"peername":"peer"},"datasets":[{"peername":"peer"}]}}`},
+		{"POST graphql",
+			mustNewRequest(t, "POST", server.URL, []byte(`{ peer(peername: "nobody") { name } }`), "application/graphql"),
+			http.StatusOK,
+			`{"data":{"peer":null},"errors":[{"message":"repo: not found","locations":[{"line":1,"column":3}],"path":["peer"]}]}`},
+		{"syntax error",
+			mustNewRequest(t, "GET", server.URL+"?query=%7B", nil, ""),
+			http.StatusBadRequest,
+			`{"data":null,"errors":[{"message":"syntax error at 1:2: expected name, found end of document","locations":[{"line":1,"column":2}]}]}`},
+	}
+
+	for _, c := range cases {
+		res, err := http.DefaultClient.Do(c.req)
+		if err != nil {
+			t.Fatalf("case '%s' request error: %s", c.description, err.Error())
+		}
+		buf := &bytes.Buffer{}
+		buf.ReadFrom(res.Body)
+		res.Body.Close()
+
+		if res.StatusCode != c.status {
+			t.Errorf("case '%s' expected status %d, got: %d", c.description, c.status, res.StatusCode)
+		}
+		if got := string(bytes.TrimSpace(buf.Bytes())); got != c.expect {
+			t.Errorf("case '%s' response mismatch.\nexpected: %s\ngot:      %s", c.description, c.expect, got)
+		}
+	}
+
+	res, err := http.Post(server.URL, "application/json", bytes.NewReader([]byte(`{}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an empty query to respond with status %d, got: %d", http.StatusBadRequest, res.StatusCode)
+	}
+}
+
+func mustNewRequest(t *testing.T, method, u string, body []byte, contentType string) *http.Request {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req
+}
+
+func TestGraphQLMaxResponseRows(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	r := httptest.NewRequest("POST", "/graphql", nil)
+	r = r.WithContext(context.WithValue(r.Context(), LimitsCtxKey, config.APILimits{MaxResponseRows: 3}))
+	l := newGraphQLLoader(node, r)
+	ctx := context.WithValue(context.Background(), graphqlLoaderCtxKey, l)
+
+	query := `{ dataset(ref: "peer/cities") { a: body(limit: 2) b: body(limit: 2) c: body(all: true) } }`
+	res := graphql.Do(newGraphQLSchema(), graphql.Params{Query: query, Context: ctx})
+	if len(res.Errors) != 1 || res.Errors[0].Message != "query reads more than the maximum of 3 body entries" {
+		t.Fatalf("expected one row limit error, got: %v", res.Errors)
+	}
+
+	data, err := json.Marshal(res.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := struct {
+		Dataset struct {
+			A, B, C []interface{}
+		}
+	}{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Dataset.A) != 2 || len(got.Dataset.B) != 1 || got.Dataset.C != nil {
+		t.Errorf("expected body fields to share 3 rows, got: %d, %d, %d", len(got.Dataset.A), len(got.Dataset.B), len(got.Dataset.C))
+	}
+}
+
+func TestGraphQLDefaultMaxRows(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	defer func(max int) { graphqlMaxRows = max }(graphqlMaxRows)
+	graphqlMaxRows = 2
+
+	// without a MaxResponseRows limit, all is capped by the default
+	r := httptest.NewRequest("POST", "/graphql", nil)
+	ctx := context.WithValue(context.Background(), graphqlLoaderCtxKey, newGraphQLLoader(node, r))
+
+	res := graphql.Do(newGraphQLSchema(), graphql.Params{Query: `{ dataset(ref: "peer/cities") { body(all: true) } }`, Context: ctx})
+	if len(res.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", res.Errors)
+	}
+	data, err := json.Marshal(res.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := struct {
+		Dataset struct {
+			Body []interface{}
+		}
+	}{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Dataset.Body) != 2 {
+		t.Errorf("expected all entries to be capped at 2, got: %d", len(got.Dataset.Body))
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	util "github.com/datatogether/api/apiutil"
+	"github.com/qri-io/qri/lib"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+)
+
+// JobHandlers wraps a JobRequests with http.HandlerFuncs
+type JobHandlers struct {
+	lib.JobRequests
+}
+
+// NewJobHandlers allocates a JobHandlers pointer
+func NewJobHandlers(n *p2p.QriNode) *JobHandlers {
+	req := lib.NewJobRequests(n, nil)
+	h := JobHandlers{*req}
+	return &h
+}
+
+// JobsHandler is the endpoint for listing background jobs
+func (h *JobHandlers) JobsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		util.EmptyOkHandler(w, r)
+	case "GET":
+		h.listJobsHandler(w, r)
+	default:
+		util.NotFoundHandler(w, r)
+	}
+}
+
+// JobHandler is the endpoint for checking on a single job. GET shows the
+// job's status, progress, script output & result, DELETE cancels the job
+func (h *JobHandlers) JobHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		util.EmptyOkHandler(w, r)
+	case "GET":
+		h.getJobHandler(w, r)
+	case "DELETE":
+		h.cancelJobHandler(w, r)
+	default:
+		util.NotFoundHandler(w, r)
+	}
+}
+
+func (h *JobHandlers) listJobsHandler(w http.ResponseWriter, r *http.Request) {
+	lp := lib.ListParamsFromRequest(r)
+	res := []repo.Job{}
+	if err := h.List(&lp, &res); err != nil {
+		util.WriteErrResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	for i := range res {
+		res[i] = publicJob(res[i])
+	}
+	if err := util.WritePageResponse(w, res, r, lp.Page()); err != nil {
+		log.Infof("error list jobs response: %s", err.Error())
+	}
+}
+
+func (h *JobHandlers) getJobHandler(w http.ResponseWriter, r *http.Request) {
+	id := jobIDFromPath(r.URL.Path)
+	res := repo.Job{}
+	if err := h.Get(&id, &res); err != nil {
+		util.WriteErrResponse(w, jobErrStatus(err), err)
+		return
+	}
+	util.WriteResponse(w, publicJob(res))
+}
+
+func (h *JobHandlers) cancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	id := jobIDFromPath(r.URL.Path)
+	res := repo.Job{}
+	if err := h.Cancel(&id, &res); err != nil {
+		util.WriteErrResponse(w, jobErrStatus(err), err)
+		return
+	}
+	util.WriteResponse(w, publicJob(res))
+}
+
+// jobIDFromPath gets the job ID from a /jobs/{id} path
+func jobIDFromPath(path string) string {
+	return strings.Trim(strings.TrimPrefix(path, "/jobs"), "/")
+}
+
+func jobErrStatus(err error) int {
+	switch err {
+	case lib.ErrJobNotFound:
+		return http.StatusNotFound
+	case lib.ErrJobsNotSupported:
+		return http.StatusNotImplemented
+	}
+	return http.StatusBadRequest
+}
+
+// publicJob removes job params from API responses, params can include
+// transform secrets
+func publicJob(job repo.Job) repo.Job {
+	job.Params = nil
+	return job
+}
+
+// isAsync returns true if a request asks to be run as a background job
+func isAsync(r *http.Request) bool {
+	return r.FormValue("async") == "true"
+}
+
+// submitJob runs an operation in the background, responding with
+// 202 Accepted and the queued job. Poll the Location header for the outcome.
+// the submitted job is returned, callers don't need to respond on error
+func submitJob(w http.ResponseWriter, node *p2p.QriNode, p *lib.JobParams) (job repo.Job, err error) {
+	if err = lib.NewJobRequests(node, nil).Submit(p, &job); err != nil {
+		util.WriteErrResponse(w, jobErrStatus(err), err)
+		return job, err
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"meta": map[string]interface{}{"code": http.StatusAccepted},
+		"data": publicJob(job),
+	})
+	if err != nil {
+		util.WriteErrResponse(w, http.StatusInternalServerError, err)
+		return job, err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%s", job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(data)
+	return job, nil
+}
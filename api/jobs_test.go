@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/qri-io/qri/repo"
+)
+
+func TestJobHandlers(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	dsh := NewDatasetHandlers(node, false)
+	jh := NewJobHandlers(node)
+
+	w := httptest.NewRecorder()
+	dsh.PublishHandler(w, httptest.NewRequest("POST", "/publish/peer/movies?async=true&no_registry=true", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got: %d. body: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	accepted := struct {
+		Data repo.Job
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &accepted); err != nil {
+		t.Fatal(err)
+	}
+	if loc := w.Header().Get("Location"); loc != "/jobs/"+accepted.Data.ID {
+		t.Errorf("location header mismatch. expected: %s, got: %s", "/jobs/"+accepted.Data.ID, loc)
+	}
+
+	job := repo.Job{}
+	deadline := time.Now().Add(time.Second * 5)
+	for !job.Status.Finished() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for job to finish. status: %s", job.Status)
+		}
+		w = httptest.NewRecorder()
+		jh.JobHandler(w, httptest.NewRequest("GET", "/jobs/"+accepted.Data.ID, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got: %d. body: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		res := struct {
+			Data repo.Job
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		job = res.Data
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if job.Status != repo.JobSucceeded {
+		t.Errorf("expected job to succeed, got: %s %s", job.Status, job.Error)
+	}
+	if job.Params != nil {
+		t.Error("expected job params to be omitted from responses")
+	}
+
+	cases := []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "/jobs", http.StatusOK},
+		{"GET", "/jobs/not_a_job", http.StatusNotFound},
+		{"DELETE", "/jobs/not_a_job", http.StatusNotFound},
+		{"DELETE", "/jobs/" + job.ID, http.StatusBadRequest},
+	}
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(c.method, c.path, nil)
+		if c.path == "/jobs" {
+			jh.JobsHandler(w, r)
+		} else {
+			jh.JobHandler(w, r)
+		}
+		if w.Code != c.status {
+			t.Errorf("case %d %s %s: expected status %d, got: %d", i, c.method, c.path, c.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/qri-io/qri/config"
+)
+
+// LimitsCtxKey is the key for adding the limits that apply to a request to a
+// context.Context
+const LimitsCtxKey QriCtxKey = "limits"
+
+// LimitsFromCtx extracts the limits that apply to a request, returning empty
+// limits if none are set
+func LimitsFromCtx(ctx context.Context) config.APILimits {
+	l, _ := ctx.Value(LimitsCtxKey).(config.APILimits)
+	return l
+}
+
+// quotaWindow is the length of the RequestsPerDay quota window
+const quotaWindow = time.Hour * 24
+
+// limitViolation describes why a request was refused by a limit
+type limitViolation struct {
+	// Kind is one of "rate", "quota" or "size"
+	Kind string `json:"kind"`
+	// Limit is the value of the limit that was exceeded
+	Limit int64 `json:"limit"`
+	// RetryAfter is the number of seconds until the request can be retried,
+	// zero for limits that waiting won't fix
+	RetryAfter int `json:"retryAfter,omitempty"`
+}
+
+// status gives the HTTP status code for a violation
+func (v limitViolation) status() int {
+	if v.Kind == "size" {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusTooManyRequests
+}
+
+// Error implements the error interface
+func (v limitViolation) Error() string {
+	switch v.Kind {
+	case "rate":
+		return fmt.Sprintf("rate limit exceeded. limit is %d requests per minute", v.Limit)
+	case "quota":
+		return fmt.Sprintf("quota exceeded. limit is %d requests per day", v.Limit)
+	default:
+		return fmt.Sprintf("request body too large. limit is %d bytes", v.Limit)
+	}
+}
+
+// writeLimitResponse writes a structured error response for a violation
+func writeLimitResponse(w http.ResponseWriter, v limitViolation) {
+	if v.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(v.RetryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(v.status())
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"meta": map[string]interface{}{
+			"code":  v.status(),
+			"error": v.Error(),
+			"limit": v,
+		},
+	})
+}
+
+// limited wraps a handler with the request limits configured for the client
+// making the request. limited must run after token authorization so token
+// limits can be applied
+func (s *Server) limited(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" || (s.cfg.API.Limits == config.APILimits{} && len(s.cfg.API.TokenLimits) == 0) {
+			handler(w, r)
+			return
+		}
+
+		client := "addr:" + remoteHost(r)
+		var tokenLimits *config.APILimits
+		if t, ok := TokenFromCtx(r.Context()); ok {
+			client = "token:" + t.ID
+			tokenLimits = s.cfg.API.TokenLimits[t.ID]
+		}
+		lim := s.cfg.API.Limits.Merge(tokenLimits)
+
+		if v := s.limiter.allow(client, lim, time.Now()); v != nil {
+			writeLimitResponse(w, *v)
+			return
+		}
+		if lim.RequestsPerMinute > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(lim.RequestsPerMinute))
+		}
+
+		if lim.MaxRequestBytes > 0 && r.ContentLength != 0 {
+			if r.ContentLength > int64(lim.MaxRequestBytes) {
+				writeLimitResponse(w, limitViolation{Kind: "size", Limit: int64(lim.MaxRequestBytes)})
+				return
+			}
+			if r.ContentLength < 0 {
+				// length isn't known up front, enforce the limit while reading
+				body := &limitedBody{ReadCloser: r.Body, remaining: int64(lim.MaxRequestBytes)}
+				r.Body = body
+				w = &limitedWriter{ResponseWriter: w, body: body, limit: int64(lim.MaxRequestBytes)}
+			}
+		}
+
+		handler(w, r.WithContext(context.WithValue(r.Context(), LimitsCtxKey, lim)))
+	}
+}
+
+// capRows clamps a requested window of body entries to the MaxResponseRows
+// limit that applies to r
+func capRows(r *http.Request, limit int, all bool) (int, bool) {
+	max := LimitsFromCtx(r.Context()).MaxResponseRows
+	if max > 0 && (all || limit > max) {
+		return max, false
+	}
+	return limit, all
+}
+
+// remoteHost gives the host a request came from, without a port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limiter tracks request rates & quotas for each client
+type limiter struct {
+	lock      sync.Mutex
+	clients   map[string]*clientUsage
+	lastPrune time.Time
+}
+
+// clientUsage is the state of a single client's limits
+type clientUsage struct {
+	// tokens is the number of requests the client can make right now
+	tokens   float64
+	refilled time.Time
+	// windowStart is the start of the current quota window
+	windowStart time.Time
+	requests    int
+	lastSeen    time.Time
+}
+
+func newLimiter() *limiter {
+	return &limiter{clients: map[string]*clientUsage{}}
+}
+
+// allow records a request made at now, returning a violation if the request
+// exceeds lim. refused requests don't count against limits
+func (l *limiter) allow(client string, lim config.APILimits, now time.Time) *limitViolation {
+	if lim.RequestsPerMinute <= 0 && lim.RequestsPerDay <= 0 {
+		return nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.prune(now)
+
+	u, ok := l.clients[client]
+	if !ok {
+		u = &clientUsage{tokens: float64(lim.RequestsPerMinute), refilled: now, windowStart: now}
+		l.clients[client] = u
+	}
+	u.lastSeen = now
+
+	if lim.RequestsPerDay > 0 {
+		if now.Sub(u.windowStart) >= quotaWindow {
+			u.windowStart = now
+			u.requests = 0
+		}
+		if u.requests >= lim.RequestsPerDay {
+			return &limitViolation{
+				Kind:       "quota",
+				Limit:      int64(lim.RequestsPerDay),
+				RetryAfter: retrySeconds(u.windowStart.Add(quotaWindow).Sub(now)),
+			}
+		}
+	}
+
+	if lim.RequestsPerMinute > 0 {
+		perSecond := float64(lim.RequestsPerMinute) / 60
+		u.tokens = math.Min(float64(lim.RequestsPerMinute), u.tokens+now.Sub(u.refilled).Seconds()*perSecond)
+		u.refilled = now
+		if u.tokens < 1 {
+			return &limitViolation{
+				Kind:       "rate",
+				Limit:      int64(lim.RequestsPerMinute),
+				RetryAfter: retrySeconds(time.Duration((1 - u.tokens) / perSecond * float64(time.Second))),
+			}
+		}
+		u.tokens--
+	}
+
+	u.requests++
+	return nil
+}
+
+// prune drops clients that haven't been seen for a full quota window, their
+// usage has reset anyway
+func (l *limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute*10 {
+		return
+	}
+	l.lastPrune = now
+	for client, u := range l.clients {
+		if now.Sub(u.lastSeen) >= quotaWindow {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// retrySeconds rounds a wait up to whole seconds
+func retrySeconds(d time.Duration) int {
+	secs := int(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+// errRequestTooLarge is returned by limitedBody once a request body passes
+// the size limit
+var errRequestTooLarge = fmt.Errorf("request body too large")
+
+// limitedBody is a request body that errors once more than remaining bytes
+// are read
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+// Read implements the io.Reader interface
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errRequestTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		b.exceeded = true
+		return int(b.remaining), errRequestTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// limitedWriter replaces a handler's response with a structured 413 error if
+// the handler read past the request size limit before responding
+type limitedWriter struct {
+	http.ResponseWriter
+	body  *limitedBody
+	limit int64
+	// started is true once the handler's response has started
+	started bool
+	// overridden is true once the error response has been written
+	overridden bool
+}
+
+// WriteHeader implements the http.ResponseWriter interface
+func (w *limitedWriter) WriteHeader(status int) {
+	if w.overridden || w.started {
+		return
+	}
+	if w.body.exceeded {
+		w.overridden = true
+		writeLimitResponse(w.ResponseWriter, limitViolation{Kind: "size", Limit: w.limit})
+		return
+	}
+	w.started = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements the http.ResponseWriter interface
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if !w.started && !w.overridden {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.overridden {
+		// drop the handler's response, the error has been written
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements the http.Flusher interface
+func (w *limitedWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && w.started {
+		f.Flush()
+	}
+}
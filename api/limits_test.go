@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/repo"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	l := newLimiter()
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	lim := config.APILimits{RequestsPerMinute: 2}
+	for i := 0; i < 2; i++ {
+		if v := l.allow("a", lim, now); v != nil {
+			t.Fatalf("request %d: unexpected violation: %s", i, v)
+		}
+	}
+	v := l.allow("a", lim, now)
+	if v == nil || v.Kind != "rate" {
+		t.Fatalf("expected rate violation, got: %v", v)
+	}
+	if v.RetryAfter != 30 {
+		t.Errorf("expected retry after 30 seconds, got: %d", v.RetryAfter)
+	}
+	if v := l.allow("b", lim, now); v != nil {
+		t.Errorf("expected limits to be tracked per client, got: %s", v)
+	}
+	if v := l.allow("a", lim, now.Add(time.Second*30)); v != nil {
+		t.Errorf("expected rate limit to refill, got: %s", v)
+	}
+
+	quota := config.APILimits{RequestsPerDay: 1}
+	if v := l.allow("c", quota, now); v != nil {
+		t.Fatalf("unexpected violation: %s", v)
+	}
+	v = l.allow("c", quota, now.Add(time.Hour))
+	if v == nil || v.Kind != "quota" {
+		t.Fatalf("expected quota violation, got: %v", v)
+	}
+	if v.RetryAfter != int((time.Hour * 23).Seconds()) {
+		t.Errorf("expected retry after 23 hours, got: %d seconds", v.RetryAfter)
+	}
+	if v := l.allow("c", quota, now.Add(quotaWindow)); v != nil {
+		t.Errorf("expected quota to reset after window, got: %s", v)
+	}
+
+	if v := l.allow("d", config.APILimits{}, now); v != nil {
+		t.Errorf("expected no limits to allow everything, got: %s", v)
+	}
+	if _, ok := l.clients["d"]; ok {
+		t.Error("expected clients without limits not to be tracked")
+	}
+
+	l.prune(now.Add(quotaWindow * 2))
+	if len(l.clients) != 0 {
+		t.Errorf("expected idle clients to be pruned, got: %d", len(l.clients))
+	}
+}
+
+func TestLimited(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	cfg := config.DefaultConfigForTesting()
+	cfg.API.Limits = config.APILimits{RequestsPerMinute: 1, MaxRequestBytes: 8}
+	s := New(node, cfg)
+
+	h := s.limited(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/list", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got: %d", w.Code)
+	}
+	if w.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Errorf("expected rate limit header, got: %q", w.Header().Get("X-RateLimit-Limit"))
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/list", nil))
+	checkLimitResponse(t, w, http.StatusTooManyRequests, "rate")
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	// requests from other addresses have their own limits
+	req := httptest.NewRequest("POST", "/save", strings.NewReader("too many bytes"))
+	req.RemoteAddr = "10.0.0.1:1234"
+	w = httptest.NewRecorder()
+	h(w, req)
+	checkLimitResponse(t, w, http.StatusRequestEntityTooLarge, "size")
+
+	// bodies of unknown length are checked while they're read
+	req = httptest.NewRequest("POST", "/save", strings.NewReader("too many bytes"))
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	h(w, req)
+	checkLimitResponse(t, w, http.StatusRequestEntityTooLarge, "size")
+
+	req = httptest.NewRequest("POST", "/save", strings.NewReader("ok"))
+	req.RemoteAddr = "10.0.0.3:1234"
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	h(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected small body of unknown length to be accepted, got: %d", w.Code)
+	}
+}
+
+func TestLimitedTokenLimits(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	cfg := config.DefaultConfigForTesting()
+	cfg.API.Limits = config.APILimits{RequestsPerDay: 1, MaxResponseRows: 10}
+	cfg.API.TokenLimits = map[string]*config.APILimits{"unlimited": {RequestsPerDay: 100}}
+	s := New(node, cfg)
+
+	var rows int
+	h := s.limited(func(w http.ResponseWriter, r *http.Request) {
+		rows, _ = capRows(r, 50, false)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	send := func(tokenID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/body/me/movies", nil)
+		if tokenID != "" {
+			req = req.WithContext(context.WithValue(req.Context(), TokenCtxKey, repo.Token{ID: tokenID}))
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := send("unlimited"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected token limits to override defaults, got: %d", i, w.Code)
+		}
+	}
+	if rows != 10 {
+		t.Errorf("expected token limits to keep default row limit of 10, got: %d", rows)
+	}
+
+	if w := send("limited"); w.Code != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got: %d", w.Code)
+	}
+	checkLimitResponse(t, send("limited"), http.StatusTooManyRequests, "quota")
+}
+
+func TestCapRows(t *testing.T) {
+	r := httptest.NewRequest("GET", "/body/me/movies", nil)
+	if limit, all := capRows(r, 50, true); limit != 50 || !all {
+		t.Errorf("expected no row limit to leave request alone, got: %d, %t", limit, all)
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), LimitsCtxKey, config.APILimits{MaxResponseRows: 10}))
+	cases := []struct {
+		limit     int
+		all       bool
+		expLimit  int
+		expectAll bool
+	}{
+		{5, false, 5, false},
+		{50, false, 10, false},
+		{0, true, 10, false},
+	}
+	for i, c := range cases {
+		limit, all := capRows(r, c.limit, c.all)
+		if limit != c.expLimit || all != c.expectAll {
+			t.Errorf("case %d: expected %d, %t. got: %d, %t", i, c.expLimit, c.expectAll, limit, all)
+		}
+	}
+}
+
+func checkLimitResponse(t *testing.T, w *httptest.ResponseRecorder, status int, kind string) {
+	if w.Code != status {
+		t.Errorf("expected status %d, got: %d", status, w.Code)
+		return
+	}
+	res := struct {
+		Meta struct {
+			Code  int
+			Error string
+			Limit limitViolation
+		}
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Errorf("error decoding limit response: %s. body: %s", err, w.Body.String())
+		return
+	}
+	if res.Meta.Code != status {
+		t.Errorf("expected meta code %d, got: %d", status, res.Meta.Code)
+	}
+	if res.Meta.Limit.Kind != kind {
+		t.Errorf("expected limit kind %s, got: %s", kind, res.Meta.Limit.Kind)
+	}
+	if res.Meta.Error == "" {
+		t.Error("expected error message")
+	}
+}
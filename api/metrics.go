@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	util "github.com/datatogether/api/apiutil"
+	"github.com/qri-io/qri/metrics"
+)
+
+var (
+	apiRequests = metrics.NewCounterVec("qri_api_requests_total",
+		"api requests handled, by route pattern, method & response status code", "route", "method", "code")
+	apiRequestDuration = metrics.NewHistogramVec("qri_api_request_duration_seconds",
+		"time taken to handle api requests, by route pattern & method", metrics.DefBuckets, "route", "method")
+	apiErrors = metrics.NewCounterVec("qri_api_errors_total",
+		"api requests handlers failed, by route pattern & class. class is client for 4xx responses, server for 5xx", "route", "class")
+)
+
+func init() {
+	metrics.MustRegister(apiRequests, apiRequestDuration, apiErrors)
+}
+
+// instrument records request counts & latencies for each route of mux.
+// routes are labelled by the pattern they were registered with, so metrics
+// don't grow with the number of distinct request paths
+func instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "none"
+		}
+		method := r.Method
+		switch method {
+		case "GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS", "PATCH":
+		default:
+			method = "other"
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(sw, r)
+
+		apiRequestDuration.With(route, method).ObserveSince(start)
+		apiRequests.With(route, method, strconv.Itoa(sw.status)).Inc()
+		switch {
+		case sw.status >= 500:
+			apiErrors.With(route, "server").Inc()
+		case sw.status >= 400:
+			apiErrors.With(route, "client").Inc()
+		}
+	})
+}
+
+// MetricsHandler writes node metrics in the Prometheus text exposition format
+func (s *Server) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		util.EmptyOkHandler(w, r)
+	case "GET":
+		s.metricsHandler(w, r)
+	default:
+		util.NotFoundHandler(w, r)
+	}
+}
+
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if err := metrics.Default.Write(w); err != nil {
+		log.Infof("error writing metrics: %s", err.Error())
+		return
+	}
+
+	// node & repo stats are read when metrics are requested. anything that
+	// would mean reading a log is kept as a running total instead
+	node := s.qriNode
+	metrics.WriteGauge(w, "qri_p2p_online", "1 if the node is connected to the p2p network", boolGauge(node.Online))
+	metrics.WriteGauge(w, "qri_p2p_connected_qri_peers", "number of connected peers that speak the qri protocol", float64(len(node.ConnectedQriPeerIDs())))
+
+	if count, err := node.Repo.RefCount(); err == nil {
+		metrics.WriteGauge(w, "qri_repo_datasets", "number of datasets in the repo", float64(count))
+	} else {
+		log.Infof("error counting datasets for metrics: %s", err.Error())
+	}
+	if ipfsNode, err := node.IPFSNode(); err == nil {
+		if su, ok := ipfsNode.Repo.(interface {
+			GetStorageUsage() (uint64, error)
+		}); ok {
+			if size, err := su.GetStorageUsage(); err == nil {
+				metrics.WriteGauge(w, "qri_repo_store_bytes", "size of the content-addressed store, in bytes", float64(size))
+			}
+		}
+	}
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/metrics"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	s := New(node, config.DefaultConfigForTesting())
+	server := httptest.NewServer(instrument(NewServerRoutes(s)))
+	defer server.Close()
+
+	// make a request so there's at least one route to report
+	res, err := http.Get(server.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	res, err = http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got: %d", http.StatusOK, res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("expected content type %q, got: %q", metrics.ContentType, ct)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(data)
+
+	expect := []string{
+		`qri_api_requests_total{route="/status",method="GET",code="200"}`,
+		`qri_api_request_duration_seconds_count{route="/status",method="GET"}`,
+		"# TYPE qri_p2p_messages_sent_total counter",
+		"# TYPE qri_transform_duration_seconds histogram",
+		"qri_p2p_connected_qri_peers 0",
+		"qri_repo_datasets ",
+		"# TYPE qri_repo_events_logged_total counter",
+		"# TYPE qri_operations_total counter",
+	}
+	for _, exp := range expect {
+		if !strings.Contains(body, exp) {
+			t.Errorf("expected metrics to contain %q. got:\n%s", exp, body)
+		}
+	}
+}
+
+func TestInstrumentUnmatchedRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/teapot", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := instrument(mux)
+
+	before := apiRequests.With("/teapot", "other", "418").Value()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/teapot", nil))
+	if got := apiRequests.With("/teapot", "other", "418").Value(); got != before+1 {
+		t.Errorf("expected teapot count to be %v, got: %v", before+1, got)
+	}
+
+	if got := apiErrors.With("/teapot", "client").Value(); got < 1 {
+		t.Errorf("expected a 418 response to count as a client error, got: %v", got)
+	}
+
+	before = apiRequests.With("none", "GET", "404").Value()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nothing/here", nil))
+	if got := apiRequests.With("none", "GET", "404").Value(); got != before+1 {
+		t.Errorf("expected unmatched count to be %v, got: %v", before+1, got)
+	}
+}
@@ -51,8 +51,9 @@ func (s *Server) addCORSHeaders(w http.ResponseWriter, r *http.Request) {
 	for _, o := range s.cfg.API.AllowedOrigins {
 		if origin == o {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
-			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, POST, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, PUT, POST, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization,Range,Upload-Offset")
+			w.Header().Set("Access-Control-Expose-Headers", "Content-Range,Location,Upload-Offset,Upload-Length")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			return
 		}
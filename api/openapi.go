@@ -0,0 +1,258 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// v1APIVersion is the version of the versioned API reported in it's OpenAPI
+// document
+const v1APIVersion = "1.0.0"
+
+// v1BodyContentTypes are the media types the body route can respond with
+var v1BodyContentTypes = []string{
+	"application/json",
+	"text/csv",
+	"application/x-ndjson",
+	"application/cbor",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.apache.parquet",
+}
+
+// v1Schemas are the component schemas referenced by v1 routes
+var v1Schemas = map[string]interface{}{
+	"Error": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"status":  map[string]interface{}{"type": "integer"},
+					"message": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	},
+	"Status": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"status":  map[string]interface{}{"type": "string"},
+			"version": map[string]interface{}{"type": "string"},
+		},
+	},
+	"OpenAPI": map[string]interface{}{
+		"type":        "object",
+		"description": "an OpenAPI 3 document",
+	},
+	"Profile": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":          map[string]interface{}{"type": "string"},
+			"peername":    map[string]interface{}{"type": "string"},
+			"name":        map[string]interface{}{"type": "string"},
+			"description": map[string]interface{}{"type": "string"},
+			"created":     map[string]interface{}{"type": "string", "format": "date-time"},
+		},
+	},
+	"ProfileList": map[string]interface{}{
+		"type":  "array",
+		"items": v1SchemaRef("Profile"),
+	},
+	"Dataset": map[string]interface{}{
+		"type":        "object",
+		"description": "a dataset document",
+		"properties": map[string]interface{}{
+			"commit":    map[string]interface{}{"type": "object"},
+			"meta":      map[string]interface{}{"type": "object"},
+			"structure": map[string]interface{}{"type": "object"},
+			"transform": map[string]interface{}{"type": "object"},
+			"viz":       map[string]interface{}{"type": "object"},
+			"body":      map[string]interface{}{},
+			"bodyPath":  map[string]interface{}{"type": "string"},
+		},
+	},
+	"DatasetRef": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"peername":  map[string]interface{}{"type": "string"},
+			"profileID": map[string]interface{}{"type": "string"},
+			"name":      map[string]interface{}{"type": "string"},
+			"path":      map[string]interface{}{"type": "string"},
+			"published": map[string]interface{}{"type": "boolean"},
+			"dataset":   v1SchemaRef("Dataset"),
+		},
+	},
+	"DatasetRefList": map[string]interface{}{
+		"type":  "array",
+		"items": v1SchemaRef("DatasetRef"),
+	},
+	"RemoveResult": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"ref":     v1SchemaRef("DatasetRef"),
+			"removed": map[string]interface{}{"type": "integer"},
+		},
+	},
+	"RenameRequest": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"name"},
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+		},
+	},
+	"SearchResultList": map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"Type":  map[string]interface{}{"type": "string"},
+				"ID":    map[string]interface{}{"type": "string"},
+				"Value": map[string]interface{}{"type": "object"},
+			},
+		},
+	},
+	"Stats": map[string]interface{}{
+		"type":        "object",
+		"description": "a profile of each column of a dataset body",
+		"properties": map[string]interface{}{
+			"entries": map[string]interface{}{"type": "integer"},
+			"columns": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"title":     map[string]interface{}{"type": "string"},
+						"types":     map[string]interface{}{"type": "object"},
+						"count":     map[string]interface{}{"type": "integer"},
+						"nulls":     map[string]interface{}{"type": "integer"},
+						"distinct":  map[string]interface{}{"type": "integer"},
+						"min":       map[string]interface{}{},
+						"max":       map[string]interface{}{},
+						"mean":      map[string]interface{}{"type": "number"},
+						"histogram": map[string]interface{}{"type": "array"},
+						"topK":      map[string]interface{}{"type": "array"},
+					},
+				},
+			},
+		},
+	},
+	"Body": map[string]interface{}{
+		"description": "dataset body entries, encoded according to the Accept header",
+	},
+}
+
+// v1OpenAPI generates an OpenAPI 3 document describing routes
+func v1OpenAPI(routes []v1Route) map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, rt := range routes {
+		item, ok := paths[rt.Path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[rt.Path] = item
+		}
+		item[strings.ToLower(rt.Method)] = v1Operation(rt)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":       "Qri API",
+			"description": "Qri API used to communicate with a Qri node",
+			"version":     v1APIVersion,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": v1Schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+			},
+		},
+	}
+}
+
+// v1Operation describes a single route as an OpenAPI operation
+func v1Operation(rt v1Route) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": rt.OperationID,
+		"summary":     rt.Summary,
+		"responses": map[string]interface{}{
+			strconv.Itoa(rt.Status): v1Response(rt.Response),
+			"default": map[string]interface{}{
+				"description": "error",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": v1SchemaRef("Error")},
+				},
+			},
+		},
+	}
+
+	params := []interface{}{}
+	for _, name := range v1PathParams(rt.Path) {
+		params = append(params, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, q := range rt.Query {
+		params = append(params, map[string]interface{}{
+			"name":        q.Name,
+			"in":          "query",
+			"description": q.Description,
+			"schema":      map[string]interface{}{"type": q.Type},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if rt.Request != "" {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": v1SchemaRef(rt.Request)},
+			},
+		}
+	}
+
+	if rt.Scope != "" {
+		op["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+		op["x-qri-scope"] = string(rt.Scope)
+	}
+	return op
+}
+
+// v1Response describes a successful response with the named schema
+func v1Response(schema string) map[string]interface{} {
+	types := []string{"application/json"}
+	if schema == "Body" {
+		types = v1BodyContentTypes
+	}
+
+	content := map[string]interface{}{}
+	for _, t := range types {
+		content[t] = map[string]interface{}{"schema": v1SchemaRef(schema)}
+	}
+	return map[string]interface{}{
+		"description": "success",
+		"content":     content,
+	}
+}
+
+// v1PathParams lists the names of path parameters in a route pattern
+func v1PathParams(pattern string) (names []string) {
+	for _, seg := range strings.Split(pattern, "/") {
+		if name := v1PathParamName(seg); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func v1SchemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/repo/profile"
 )
 
@@ -84,6 +85,26 @@ func (h *PeerHandlers) ConnectionsHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// QueueHandler is the endpoint for inspecting & clearing the outbound
+// message queue
+func (h *PeerHandlers) QueueHandler(w http.ResponseWriter, r *http.Request) {
+	if h.ReadOnly {
+		readOnlyResponse(w, "/queue")
+		return
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		util.EmptyOkHandler(w, r)
+	case "GET":
+		h.listQueueHandler(w, r)
+	case "DELETE":
+		h.clearQueueHandler(w, r)
+	default:
+		util.NotFoundHandler(w, r)
+	}
+}
+
 func (h *PeerHandlers) listPeersHandler(w http.ResponseWriter, r *http.Request) {
 	args := lib.ListParamsFromRequest(r)
 	// args.OrderBy = "created"
@@ -158,3 +179,25 @@ func (h *PeerHandlers) connectToPeerHandler(w http.ResponseWriter, r *http.Reque
 
 	util.WriteResponse(w, res)
 }
+
+func (h *PeerHandlers) listQueueHandler(w http.ResponseWriter, r *http.Request) {
+	args := lib.ListParamsFromRequest(r)
+	res := []repo.QueuedMessage{}
+	if err := h.QueuedMessages(&args, &res); err != nil {
+		log.Infof("error listing queued messages: %s", err.Error())
+		util.WriteErrResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	util.WritePageResponse(w, res, r, args.Page())
+}
+
+func (h *PeerHandlers) clearQueueHandler(w http.ResponseWriter, r *http.Request) {
+	in := true
+	cleared := 0
+	if err := h.ClearQueue(&in, &cleared); err != nil {
+		log.Infof("error clearing message queue: %s", err.Error())
+		util.WriteErrResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	util.WriteResponse(w, map[string]int{"cleared": cleared})
+}
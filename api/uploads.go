@@ -0,0 +1,507 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	util "github.com/datatogether/api/apiutil"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+)
+
+// UploadTTL is how long an upload is kept after it was last written to
+var UploadTTL = time.Hour * 24
+
+// UploadMaxBytes is the largest total size of all staged uploads
+var UploadMaxBytes int64 = 10 << 30
+
+var (
+	// errUploadNotFound is returned for unknown upload IDs
+	errUploadNotFound = fmt.Errorf("upload not found")
+	// errUploadBusy is returned when a chunk is written to, or a save is
+	// started from, an upload that's already being written to or saved
+	errUploadBusy = fmt.Errorf("upload is already being written to or saved")
+	// errUploadIncomplete is returned when saving an upload that hasn't
+	// received all of it's bytes
+	errUploadIncomplete = fmt.Errorf("upload isn't complete")
+)
+
+// offsetError is returned when a chunk doesn't start where the staged upload
+// ends. clients resume from Offset
+type offsetError struct {
+	Offset int64
+}
+
+func (e offsetError) Error() string {
+	return fmt.Sprintf("upload offset mismatch, upload is at offset %d", e.Offset)
+}
+
+// checksumError is returned when a complete upload doesn't match it's
+// checksum
+type checksumError struct {
+	Expected, Got string
+}
+
+func (e checksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch. expected sha256 %s, got %s. the upload has been reset", e.Expected, e.Got)
+}
+
+// Upload is a body file being uploaded in chunks. Uploads are created with a
+// filename, size & checksum, then written to with PATCH requests that carry
+// an Upload-Offset header. A chunk that's cut off part way through keeps the
+// bytes that arrived, so clients resume by asking for the upload's offset &
+// sending the rest. Once all bytes have arrived the checksum is verified &
+// the upload can be saved by passing it's ID as the "upload" param to /save
+type Upload struct {
+	ID string `json:"id"`
+	// Filename is the name of the body file. it's extension sets the body
+	// format
+	Filename string `json:"filename"`
+	// Size is the total number of bytes in the upload
+	Size int64 `json:"size"`
+	// Offset is the number of bytes received so far
+	Offset int64 `json:"offset"`
+	// SHA256 is the hex-encoded sha256 checksum of the complete upload
+	SHA256   string    `json:"sha256"`
+	Complete bool      `json:"complete"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	// Job is the ID of a background save job reading the upload. uploads
+	// aren't pruned while their job is unfinished
+	Job string `json:"job,omitempty"`
+}
+
+// uploadStore stages uploads on disk. each upload is a directory named by
+// it's ID, holding an info.json file & the body file
+type uploadStore struct {
+	dir string
+	// jobs is checked for jobs that are reading uploads, if set
+	jobs repo.JobStore
+
+	lock sync.Mutex
+	// busy is the set of uploads being written to or saved
+	busy map[string]bool
+}
+
+func newUploadStore(dir string) *uploadStore {
+	return &uploadStore{dir: dir, busy: map[string]bool{}}
+}
+
+// create starts a new upload
+func (s *uploadStore) create(filename string, size int64, sum string) (*Upload, error) {
+	filename = filepath.Base(filename)
+	if filename == "" || filename == "." || filename == string(filepath.Separator) || filename == "info.json" {
+		return nil, fmt.Errorf("filename is required")
+	}
+	if filepath.Ext(filename) == "" {
+		return nil, fmt.Errorf("filename must have an extension that names the body format, eg: body.csv")
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("size must be greater than 0")
+	}
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+		return nil, fmt.Errorf("sha256 must be a hex-encoded sha256 checksum")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.prune()
+	if staged := s.staged(); staged+size > UploadMaxBytes {
+		return nil, fmt.Errorf("upload is too large, staged uploads are limited to %d bytes in total & %d bytes are in use", UploadMaxBytes, staged)
+	}
+
+	idb := make([]byte, 16)
+	if _, err := rand.Read(idb); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	up := &Upload{
+		ID:       hex.EncodeToString(idb),
+		Filename: filename,
+		Size:     size,
+		SHA256:   strings.ToLower(sum),
+		Created:  now,
+		Updated:  now,
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.dir, up.ID), 0700); err != nil {
+		return nil, fmt.Errorf("creating upload: %s", err.Error())
+	}
+	f, err := os.OpenFile(s.bodyPath(up), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("creating upload: %s", err.Error())
+	}
+	f.Close()
+	return up, s.saveInfo(up)
+}
+
+// get reads an upload's state. The offset is read from the body file, so
+// it's correct even if the node restarted part way through a chunk
+func (s *uploadStore) get(id string) (*Upload, error) {
+	if b, err := hex.DecodeString(id); err != nil || len(b) != 16 {
+		return nil, errUploadNotFound
+	}
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, id, "info.json"))
+	if err != nil {
+		return nil, errUploadNotFound
+	}
+	up := &Upload{}
+	if err := json.Unmarshal(data, up); err != nil {
+		return nil, fmt.Errorf("reading upload: %s", err.Error())
+	}
+	fi, err := os.Stat(s.bodyPath(up))
+	if err != nil {
+		return nil, errUploadNotFound
+	}
+	up.Offset = fi.Size()
+	return up, nil
+}
+
+// write appends a chunk to an upload. offset must match the upload's
+// current offset. When the last byte arrives the checksum is verified, a
+// checksum mismatch discards the upload's bytes so the client can start over
+func (s *uploadStore) write(id string, offset int64, r io.Reader) (up *Upload, err error) {
+	s.lock.Lock()
+	if s.busy[id] {
+		s.lock.Unlock()
+		return nil, errUploadBusy
+	}
+	s.busy[id] = true
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.busy, id)
+		s.lock.Unlock()
+	}()
+
+	if up, err = s.get(id); err != nil {
+		return nil, err
+	}
+	if up.Complete {
+		return up, fmt.Errorf("upload is already complete")
+	}
+	if offset != up.Offset {
+		return up, offsetError{Offset: up.Offset}
+	}
+
+	f, err := os.OpenFile(s.bodyPath(up), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return up, fmt.Errorf("opening upload: %s", err.Error())
+	}
+	// read one byte past the remaining size to catch chunks that overrun it
+	n, copyErr := io.Copy(f, io.LimitReader(r, up.Size-up.Offset+1))
+	if up.Offset+n > up.Size {
+		copyErr = fmt.Errorf("chunk is larger than the %d bytes remaining", up.Size-up.Offset)
+		n = 0
+		f.Truncate(up.Offset)
+	}
+	if err = f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	up.Offset += n
+	up.Updated = time.Now().UTC()
+
+	if up.Offset == up.Size {
+		if err = s.verify(up); err != nil {
+			if _, ok := err.(checksumError); ok {
+				up.Offset = 0
+				os.Truncate(s.bodyPath(up), 0)
+				s.saveInfo(up)
+			}
+			return up, err
+		}
+		up.Complete = true
+	}
+	if err = s.saveInfo(up); err != nil {
+		return up, err
+	}
+	return up, copyErr
+}
+
+// verify checks an upload's bytes match it's checksum
+func (s *uploadStore) verify(up *Upload) error {
+	f, err := os.Open(s.bodyPath(up))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != up.SHA256 {
+		return checksumError{Expected: up.SHA256, Got: sum}
+	}
+	return nil
+}
+
+// path gives the location of a complete upload's body file
+func (s *uploadStore) path(id string) (string, error) {
+	up, err := s.get(id)
+	if err != nil {
+		return "", err
+	}
+	if !up.Complete {
+		return "", errUploadIncomplete
+	}
+	return s.bodyPath(up), nil
+}
+
+// hold marks an upload as in use while it's saved, so it isn't written to or
+// pruned until release is called
+func (s *uploadStore) hold(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.busy[id] {
+		return errUploadBusy
+	}
+	s.busy[id] = true
+	return nil
+}
+
+// release undoes hold
+func (s *uploadStore) release(id string) {
+	s.lock.Lock()
+	delete(s.busy, id)
+	s.lock.Unlock()
+}
+
+// claim records the job that's saving an upload. the upload must be held
+func (s *uploadStore) claim(id, jobID string) error {
+	up, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	up.Job = jobID
+	return s.saveInfo(up)
+}
+
+// remove deletes an upload
+func (s *uploadStore) remove(id string) error {
+	if _, err := s.get(id); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(s.dir, id))
+}
+
+// prune removes uploads that haven't been written to within UploadTTL.
+// uploads that are being written to or saved are kept. s.lock must be held
+func (s *uploadStore) prune() {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, fi := range infos {
+		up, err := s.get(fi.Name())
+		if err != nil || s.busy[up.ID] || s.saving(up) {
+			continue
+		}
+		if time.Since(up.Updated) > UploadTTL {
+			log.Debugf("removing expired upload %s", up.ID)
+			os.RemoveAll(filepath.Join(s.dir, up.ID))
+		}
+	}
+}
+
+// saving reports if an upload is claimed by a job that hasn't finished
+func (s *uploadStore) saving(up *Upload) bool {
+	if up.Job == "" || s.jobs == nil {
+		return false
+	}
+	job, err := s.jobs.Job(up.Job)
+	if err != nil {
+		return false
+	}
+	return !job.Status.Finished()
+}
+
+// staged totals the size of all uploads
+func (s *uploadStore) staged() (total int64) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return 0
+	}
+	for _, fi := range infos {
+		if up, err := s.get(fi.Name()); err == nil {
+			total += up.Size
+		}
+	}
+	return total
+}
+
+func (s *uploadStore) bodyPath(up *Upload) string {
+	return filepath.Join(s.dir, up.ID, up.Filename)
+}
+
+func (s *uploadStore) saveInfo(up *Upload) error {
+	data, err := json.Marshal(up)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.dir, up.ID, "info.json"), data, 0600)
+}
+
+// UploadHandlers implements resumable body uploads
+type UploadHandlers struct {
+	node    *p2p.QriNode
+	uploads *uploadStore
+}
+
+// NewUploadHandlers allocates an UploadHandlers pointer that stages uploads
+// in dir
+func NewUploadHandlers(node *p2p.QriNode, dir string) *UploadHandlers {
+	uploads := newUploadStore(dir)
+	if node != nil {
+		uploads.jobs, _ = node.Repo.(repo.JobStore)
+	}
+	return &UploadHandlers{node: node, uploads: uploads}
+}
+
+// UploadsHandler creates uploads. POST a JSON object with filename, size &
+// sha256 fields to start an upload
+func (h *UploadHandlers) UploadsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		util.EmptyOkHandler(w, r)
+	case "POST":
+		h.createUploadHandler(w, r)
+	default:
+		util.NotFoundHandler(w, r)
+	}
+}
+
+// UploadHandler reads, writes to & removes an upload at /uploads/[id].
+// GET & HEAD report the upload's offset, PATCH appends a chunk that starts
+// at the Upload-Offset header, DELETE discards the upload
+func (h *UploadHandlers) UploadHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/uploads/")
+	switch r.Method {
+	case "OPTIONS":
+		util.EmptyOkHandler(w, r)
+	case "GET", "HEAD":
+		h.getUploadHandler(w, r, id)
+	case "PATCH":
+		h.writeUploadHandler(w, r, id)
+	case "DELETE":
+		h.removeUploadHandler(w, r, id)
+	default:
+		util.NotFoundHandler(w, r)
+	}
+}
+
+func (h *UploadHandlers) createUploadHandler(w http.ResponseWriter, r *http.Request) {
+	p := &Upload{}
+	if err := json.NewDecoder(r.Body).Decode(p); err != nil {
+		util.WriteErrResponse(w, http.StatusBadRequest, fmt.Errorf("error decoding upload: %s", err.Error()))
+		return
+	}
+	up, err := h.uploads.create(p.Filename, p.Size, p.SHA256)
+	if err != nil {
+		util.WriteErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	h.progress(up, "started", "")
+
+	w.Header().Set("Location", "/uploads/"+up.ID)
+	writeUploadHeaders(w, up)
+	util.WriteResponse(w, up)
+}
+
+func (h *UploadHandlers) getUploadHandler(w http.ResponseWriter, r *http.Request, id string) {
+	up, err := h.uploads.get(id)
+	if err != nil {
+		util.WriteErrResponse(w, uploadErrStatus(err), err)
+		return
+	}
+	writeUploadHeaders(w, up)
+	if r.Method == "HEAD" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	util.WriteResponse(w, up)
+}
+
+func (h *UploadHandlers) writeUploadHandler(w http.ResponseWriter, r *http.Request, id string) {
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		util.WriteErrResponse(w, http.StatusBadRequest, fmt.Errorf("an Upload-Offset header with the offset the chunk starts at is required"))
+		return
+	}
+
+	up, err := h.uploads.write(id, offset, r.Body)
+	if up != nil {
+		writeUploadHeaders(w, up)
+	}
+	if err != nil {
+		if _, ok := err.(checksumError); ok {
+			h.progress(up, "failed", err.Error())
+		}
+		util.WriteErrResponse(w, uploadErrStatus(err), err)
+		return
+	}
+
+	if up.Complete {
+		h.progress(up, "done", "")
+	} else {
+		h.progress(up, "progress", "")
+	}
+	util.WriteResponse(w, up)
+}
+
+func (h *UploadHandlers) removeUploadHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if err := h.uploads.remove(id); err != nil {
+		util.WriteErrResponse(w, uploadErrStatus(err), err)
+		return
+	}
+	util.WriteResponse(w, map[string]string{"id": id})
+}
+
+// progress publishes an upload progress event
+func (h *UploadHandlers) progress(up *Upload, status, msg string) {
+	if h.node == nil {
+		return
+	}
+	h.node.PublishEvent(p2p.NEProgress, p2p.Progress{
+		Op:        "upload",
+		Ref:       up.ID,
+		Status:    status,
+		Message:   msg,
+		Completed: up.Offset,
+		Total:     up.Size,
+	})
+}
+
+// writeUploadHeaders describes an upload's progress in response headers, so
+// HEAD requests can check where to resume from
+func writeUploadHeaders(w http.ResponseWriter, up *Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(up.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(up.Size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// uploadErrStatus picks a status code for an upload error
+func uploadErrStatus(err error) int {
+	switch err.(type) {
+	case offsetError:
+		return http.StatusConflict
+	}
+	switch err {
+	case errUploadNotFound:
+		return http.StatusNotFound
+	case errUploadBusy:
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/repo"
+)
+
+func TestUploadStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_upload_store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := newUploadStore(dir)
+
+	body := []byte("city,pop\ntoronto,40000000\nnew york,8500000\n")
+	sum := sha256.Sum256(body)
+
+	badCreates := []struct {
+		filename string
+		size     int64
+		sum      string
+	}{
+		{"", 10, hex.EncodeToString(sum[:])},
+		{"body", 10, hex.EncodeToString(sum[:])},
+		{"body.csv", 0, hex.EncodeToString(sum[:])},
+		{"body.csv", 10, "nope"},
+	}
+	for i, c := range badCreates {
+		if _, err := s.create(c.filename, c.size, c.sum); err == nil {
+			t.Errorf("bad create case %d expected error, got nil", i)
+		}
+	}
+
+	up, err := s.create("../../body.csv", int64(len(body)), hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up.Filename != "body.csv" {
+		t.Errorf("expected filename to be cleaned to body.csv, got: %s", up.Filename)
+	}
+	if fi, err := os.Stat(s.bodyPath(up)); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected staged body to only be readable by it's owner, got mode: %s", fi.Mode())
+	}
+
+	prevMax := UploadMaxBytes
+	UploadMaxBytes = int64(len(body)) + 5
+	if _, err := s.create("other.csv", 10, hex.EncodeToString(sum[:])); err == nil {
+		t.Error("expected creating uploads past UploadMaxBytes to error")
+	}
+	UploadMaxBytes = prevMax
+
+	if _, err := s.write(up.ID, 0, bytes.NewReader(body[:10])); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.write(up.ID, 5, bytes.NewReader(body[10:])); err == nil {
+		t.Error("expected writing at the wrong offset to error")
+	} else if oe, ok := err.(offsetError); !ok || oe.Offset != 10 {
+		t.Errorf("expected an offset error at offset 10, got: %s", err)
+	}
+	if _, err := s.path(up.ID); err != errUploadIncomplete {
+		t.Errorf("expected path of an incomplete upload to error with: %s, got: %v", errUploadIncomplete, err)
+	}
+	if _, err := s.write(up.ID, 10, bytes.NewReader(append(body[10:], 'x'))); err == nil {
+		t.Error("expected writing past the upload size to error")
+	}
+
+	got, err := s.write(up.ID, 10, bytes.NewReader(body[10:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Complete || got.Offset != int64(len(body)) {
+		t.Errorf("expected upload to be complete at offset %d, got complete: %t, offset: %d", len(body), got.Complete, got.Offset)
+	}
+	path, err := s.path(up.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, body) {
+		t.Errorf("staged body mismatch. expected: %q, got: %q", body, data)
+	}
+
+	// uploads that don't match their checksum are reset
+	bad, err := s.create("body.csv", 4, hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.write(bad.ID, 0, bytes.NewReader([]byte("nope"))); err == nil {
+		t.Error("expected checksum mismatch to error")
+	} else if _, ok := err.(checksumError); !ok {
+		t.Errorf("expected a checksum error, got: %s", err)
+	}
+	if got, err := s.get(bad.ID); err != nil {
+		t.Fatal(err)
+	} else if got.Offset != 0 || got.Complete {
+		t.Errorf("expected failed upload to be reset, got offset: %d, complete: %t", got.Offset, got.Complete)
+	}
+
+	if err := s.remove(up.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.get(up.ID); err != errUploadNotFound {
+		t.Errorf("expected removed upload to be not found, got: %v", err)
+	}
+
+	prevTTL := UploadTTL
+	defer func() { UploadTTL = prevTTL }()
+	UploadTTL = 0
+
+	// uploads being saved aren't pruned
+	jobs := &repo.MemJobStore{}
+	s.jobs = jobs
+	job, err := repo.NewJob("save", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs.PutJob(job)
+	held, err := s.create("held.csv", 4, hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := s.create("claimed.csv", 4, hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.hold(held.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.hold(held.ID); err != errUploadBusy {
+		t.Errorf("expected holding a held upload to error with: %s, got: %v", errUploadBusy, err)
+	}
+	if err := s.claim(claimed.ID, job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	s.prune()
+	if _, err := s.get(bad.ID); err != errUploadNotFound {
+		t.Errorf("expected expired upload to be pruned, got: %v", err)
+	}
+	for _, id := range []string{held.ID, claimed.ID} {
+		if _, err := s.get(id); err != nil {
+			t.Errorf("expected upload %s that's being saved to be kept, got: %v", id, err)
+		}
+	}
+
+	s.release(held.ID)
+	job.Status = repo.JobSucceeded
+	jobs.PutJob(job)
+	s.prune()
+	for _, id := range []string{held.ID, claimed.ID} {
+		if _, err := s.get(id); err != errUploadNotFound {
+			t.Errorf("expected upload %s to be pruned once it's saved, got: %v", id, err)
+		}
+	}
+}
+
+func TestUploadAndSave(t *testing.T) {
+	node, teardown := newTestNode(t)
+	defer teardown()
+
+	dir, err := ioutil.TempDir("", "test_upload_and_save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.DefaultConfigForTesting()
+	cfg.API.Uploads = dir
+	s := New(node, cfg)
+	server := httptest.NewServer(NewServerRoutes(s))
+	defer server.Close()
+
+	body := []byte("city,pop\ntoronto,40000000\nnew york,8500000\nchatham,35000\n")
+	sum := sha256.Sum256(body)
+
+	req, _ := json.Marshal(map[string]interface{}{"filename": "cities.csv", "size": len(body), "sha256": hex.EncodeToString(sum[:])})
+	res, err := http.Post(server.URL+"/uploads", "application/json", bytes.NewReader(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := struct{ Data Upload }{}
+	json.NewDecoder(res.Body).Decode(&created)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected creating an upload to respond with status %d, got: %d", http.StatusOK, res.StatusCode)
+	}
+	location := res.Header.Get("Location")
+	if location != "/uploads/"+created.Data.ID {
+		t.Errorf("expected location header to point to the upload, got: %s", location)
+	}
+
+	patch := func(offset int, chunk []byte) *http.Response {
+		req, err := http.NewRequest("PATCH", server.URL+location, bytes.NewReader(chunk))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	if res := patch(0, body[:20]); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected first chunk to respond with status %d, got: %d", http.StatusOK, res.StatusCode)
+	}
+	if res := patch(0, body[20:]); res.StatusCode != http.StatusConflict {
+		t.Errorf("expected a chunk at the wrong offset to respond with status %d, got: %d", http.StatusConflict, res.StatusCode)
+	} else if res.Header.Get("Upload-Offset") != "20" {
+		t.Errorf("expected conflict to report offset 20, got: %s", res.Header.Get("Upload-Offset"))
+	}
+
+	headReq, _ := http.NewRequest("HEAD", server.URL+location, nil)
+	if res, err := http.DefaultClient.Do(headReq); err != nil {
+		t.Fatal(err)
+	} else if res.Header.Get("Upload-Offset") != "20" || res.Header.Get("Upload-Length") != strconv.Itoa(len(body)) {
+		t.Errorf("expected HEAD to report offset 20 of %d, got: %s of %s", len(body), res.Header.Get("Upload-Offset"), res.Header.Get("Upload-Length"))
+	}
+
+	dsp := []byte(`{"peername":"me","name":"uploaded_cities","meta":{"title":"uploaded"}}`)
+
+	// saving before the upload is complete fails
+	res, err = http.Post(server.URL+"/save/?upload="+created.Data.ID, "application/json", bytes.NewReader(dsp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected saving an incomplete upload to respond with status %d, got: %d", http.StatusBadRequest, res.StatusCode)
+	}
+
+	if res := patch(20, body[20:]); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected last chunk to respond with status %d, got: %d", http.StatusOK, res.StatusCode)
+	}
+
+	res, err = http.Post(server.URL+"/save/?upload="+created.Data.ID, "application/json", bytes.NewReader(dsp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := struct {
+		Data struct {
+			Name    string
+			Dataset struct {
+				BodyPath  string
+				Structure struct {
+					Format  string
+					Entries int
+				}
+			}
+		}
+	}{}
+	json.NewDecoder(res.Body).Decode(&saved)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected saving an upload to respond with status %d, got: %d", http.StatusOK, res.StatusCode)
+	}
+	if saved.Data.Name != "uploaded_cities" {
+		t.Errorf("expected saved dataset name to be uploaded_cities, got: %s", saved.Data.Name)
+	}
+	if saved.Data.Dataset.Structure.Format != "csv" || saved.Data.Dataset.Structure.Entries != 3 {
+		t.Errorf("expected a csv body with 3 entries, got format: %s, entries: %d", saved.Data.Dataset.Structure.Format, saved.Data.Dataset.Structure.Entries)
+	}
+
+	// saved uploads are removed
+	if res, err := http.Get(server.URL + location); err != nil {
+		t.Fatal(err)
+	} else if res.StatusCode != http.StatusNotFound {
+		t.Errorf("expected saved upload to be removed, got status: %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,588 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/actions"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/lib"
+	"github.com/qri-io/qri/repo"
+	"github.com/qri-io/qri/rev"
+)
+
+// v1Prefix is the path prefix for the versioned API. Routes under a version
+// prefix only change in backwards-compatible ways, breaking changes need a
+// new prefix
+const v1Prefix = "/v1"
+
+// v1Param describes a query parameter accepted by a route
+type v1Param struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// v1HandlerFunc handles a request to a versioned route. params holds values
+// for the route's path parameters, keyed by name
+type v1HandlerFunc func(w http.ResponseWriter, r *http.Request, params map[string]string)
+
+// v1Route is a route in the versioned API. The route table is the single
+// source for both request routing & the OpenAPI document, see v1OpenAPI
+type v1Route struct {
+	Method string
+	// Path is the route pattern. segments wrapped in braces like {name} are
+	// path parameters that match any single path segment
+	Path        string
+	OperationID string
+	Summary     string
+	// Scope is the token scope required to call this route. routes with no
+	// scope never require a token
+	Scope repo.TokenScope
+	Query []v1Param
+	// Request names the schema of the request body, if the route accepts one
+	Request string
+	// Response names the schema of a successful response
+	Response string
+	// Status is the status code of a successful response
+	Status  int
+	Handler v1HandlerFunc
+}
+
+var (
+	pageParams = []v1Param{
+		{"offset", "integer", "number of items to skip"},
+		{"limit", "integer", "maximum number of items to return"},
+	}
+	dryRunParam = v1Param{"dry_run", "boolean", "run the request without saving any changes"}
+	strictParam = v1Param{"strict", "boolean", "refuse to save a version that breaks any of the dataset's data quality rules"}
+)
+
+// v1Handlers implements the versioned API on top of lib
+type v1Handlers struct {
+	datasets *DatasetHandlers
+	logs     *lib.LogRequests
+	peers    *lib.PeerRequests
+	profiles *lib.ProfileRequests
+	search   *lib.SearchRequests
+	repo     repo.Repo
+}
+
+// newV1Handlers allocates v1Handlers for a server
+func newV1Handlers(s *Server) *v1Handlers {
+	return &v1Handlers{
+		datasets: NewDatasetHandlers(s.qriNode, s.cfg.API.ReadOnly),
+		logs:     lib.NewLogRequests(s.qriNode, nil),
+		peers:    lib.NewPeerRequests(s.qriNode, nil),
+		profiles: lib.NewProfileRequests(s.qriNode, nil),
+		search:   lib.NewSearchRequests(s.qriNode, nil),
+		repo:     s.qriNode.Repo,
+	}
+}
+
+// routes lists every versioned API route
+func (h *v1Handlers) routes() []v1Route {
+	read, write := repo.ScopeRead, repo.ScopeWrite
+	ok, created := http.StatusOK, http.StatusCreated
+
+	return []v1Route{
+		{"GET", "/v1/openapi.json", "getOpenAPI", "get the OpenAPI document describing this API", "", nil, "", "OpenAPI", ok, h.openAPI},
+		{"GET", "/v1/status", "getStatus", "check the API is up & get the version of qri it's running", "", nil, "", "Status", ok, h.status},
+		{"GET", "/v1/me", "getProfile", "get this node's profile", read, nil, "", "Profile", ok, h.getProfile},
+
+		{"GET", "/v1/ds", "listDatasets", "list datasets in this node's repo", read, pageParams, "", "DatasetRefList", ok, h.listDatasets},
+		{"GET", "/v1/ds/{peer}", "listPeerDatasets", "list a peer's datasets", read, pageParams, "", "DatasetRefList", ok, h.listPeerDatasets},
+		{"GET", "/v1/ds/{peer}/{name}", "getDataset", "get the latest version of a dataset", read, nil, "", "DatasetRef", ok, h.getDataset},
+		{"DELETE", "/v1/ds/{peer}/{name}", "removeDataset", "remove a dataset & all of it's versions", write, nil, "", "RemoveResult", ok, h.removeDataset},
+		{"GET", "/v1/ds/{peer}/{name}/versions", "listVersions", "list the version history of a dataset, newest first", read, pageParams, "", "DatasetRefList", ok, h.listVersions},
+		{"POST", "/v1/ds/{peer}/{name}/versions", "saveVersion", "save a new version of a dataset, creating the dataset if it doesn't exist", write, []v1Param{dryRunParam, strictParam}, "Dataset", "DatasetRef", created, h.saveVersion},
+		{"DELETE", "/v1/ds/{peer}/{name}/versions", "removeVersions", "remove the latest versions of a dataset", write, []v1Param{{"count", "integer", "number of versions to remove, defaults to 1"}}, "", "RemoveResult", ok, h.removeVersions},
+		{"GET", "/v1/ds/{peer}/{name}/body", "getBody", "get the body of the latest version of a dataset. supports Accept & Range headers", read, append(pageParams, v1Param{"all", "boolean", "return all entries, ignoring limit"}), "", "Body", ok, h.getBody},
+		{"GET", "/v1/ds/{peer}/{name}/stats", "getStats", "get column statistics of the latest version of a dataset", read, nil, "", "Stats", ok, h.getStats},
+		{"POST", "/v1/ds/{peer}/{name}/rename", "renameDataset", "rename a dataset", write, nil, "RenameRequest", "DatasetRef", ok, h.renameDataset},
+		{"PUT", "/v1/ds/{peer}/{name}/publication", "publishDataset", "publish a dataset", write, []v1Param{{"registry", "boolean", "also publish to the configured registry, defaults to true"}}, "", "DatasetRef", ok, h.publishDataset},
+		{"DELETE", "/v1/ds/{peer}/{name}/publication", "unpublishDataset", "unpublish a dataset", write, []v1Param{{"registry", "boolean", "also unpublish from the configured registry, defaults to true"}}, "", "DatasetRef", ok, h.unpublishDataset},
+		{"POST", "/v1/ds/{peer}/{name}/update", "updateDataset", "update a dataset by re-running it's transform or fetching the latest version from peers", write, []v1Param{dryRunParam}, "", "DatasetRef", ok, h.updateDataset},
+
+		{"GET", "/v1/peers", "listPeers", "list peers", read, append(pageParams, v1Param{"cached", "boolean", "include peers that aren't connected"}), "", "ProfileList", ok, h.listPeers},
+		{"GET", "/v1/peers/{peer}", "getPeer", "get a peer's profile", read, nil, "", "Profile", ok, h.getPeer},
+
+		{"GET", "/v1/search", "search", "search for datasets", read, append([]v1Param{{"q", "string", "search query"}}, pageParams...), "", "SearchResultList", ok, h.searchDatasets},
+	}
+}
+
+// v1Router routes requests to the versioned API
+type v1Router struct {
+	s      *Server
+	routes []v1Route
+}
+
+// newV1Router creates a router for all versioned API routes
+func newV1Router(s *Server) *v1Router {
+	return &v1Router{s: s, routes: newV1Handlers(s).routes()}
+}
+
+// match finds the route for a method & path. if the path matches routes but
+// none accept method, match returns the methods that are allowed
+func (vr *v1Router) match(method, path string) (rt *v1Route, params map[string]string, allowed []string) {
+	for i := range vr.routes {
+		p, ok := matchV1Path(vr.routes[i].Path, path)
+		if !ok {
+			continue
+		}
+		if vr.routes[i].Method != method {
+			allowed = append(allowed, vr.routes[i].Method)
+			continue
+		}
+		return &vr.routes[i], p, nil
+	}
+	return nil, nil, allowed
+}
+
+// ServeHTTP implements the http.Handler interface
+func (vr *v1Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt, params, allowed := vr.match(r.Method, r.URL.Path)
+	if rt == nil {
+		if len(allowed) == 0 {
+			writeV1Error(w, http.StatusNotFound, fmt.Errorf("not found: %s", r.URL.Path))
+			return
+		}
+		w.Header().Set("Allow", strings.Join(append(allowed, "OPTIONS"), ", "))
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		writeV1Error(w, http.StatusMethodNotAllowed, fmt.Errorf("%s isn't supported on %s", r.Method, r.URL.Path))
+		return
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		rt.Handler(w, r, params)
+	}
+	if rt.Scope != "" {
+		handler = vr.s.scoped(rt.Scope, rt.Scope, handler)
+	}
+	handler(w, r)
+}
+
+// matchV1Path matches a path against a route pattern, returning values for
+// the pattern's path parameters
+func matchV1Path(pattern, path string) (map[string]string, bool) {
+	pat := strings.Split(strings.Trim(pattern, "/"), "/")
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(pat) != len(segs) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, p := range pat {
+		if name := v1PathParamName(p); name != "" {
+			val, err := url.PathUnescape(segs[i])
+			if err != nil || val == "" {
+				return nil, false
+			}
+			params[name] = val
+		} else if p != segs[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// v1PathParamName returns the parameter name of a pattern segment like
+// "{name}", or an empty string if the segment isn't a parameter
+func v1PathParamName(seg string) string {
+	if len(seg) > 2 && seg[0] == '{' && seg[len(seg)-1] == '}' {
+		return seg[1 : len(seg)-1]
+	}
+	return ""
+}
+
+// v1ErrorResponse is the body of all error responses from the versioned API
+type v1ErrorResponse struct {
+	Error v1Error `json:"error"`
+}
+
+// v1Error describes what went wrong with a request
+type v1Error struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// v1RemoveResult is the response to requests that remove dataset versions
+type v1RemoveResult struct {
+	Ref     repo.DatasetRef `json:"ref"`
+	Removed int             `json:"removed"`
+}
+
+// v1RenameRequest is the request body for renaming a dataset
+type v1RenameRequest struct {
+	Name string `json:"name"`
+}
+
+// writeV1JSON writes v as the JSON body of a response. unlike the unversioned
+// API, responses aren't wrapped in an envelope
+func writeV1JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Infof("error writing response: %s", err.Error())
+	}
+}
+
+// writeV1Error writes an error response
+func writeV1Error(w http.ResponseWriter, status int, err error) {
+	writeV1JSON(w, status, v1ErrorResponse{v1Error{Status: status, Message: err.Error()}})
+}
+
+// v1ErrStatus picks a status code for an error returned by lib
+func v1ErrStatus(err error) int {
+	if err == repo.ErrNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// v1QueryInt reads an integer query parameter, returning def if the
+// parameter isn't set
+func v1QueryInt(r *http.Request, name string, def int) (int, error) {
+	str := r.FormValue(name)
+	if str == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(str)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s '%s': must be a positive integer", name, str)
+	}
+	return i, nil
+}
+
+// v1Page reads offset & limit query parameters
+func v1Page(r *http.Request) (offset, limit int, err error) {
+	if offset, err = v1QueryInt(r, "offset", 0); err != nil {
+		return
+	}
+	limit, err = v1QueryInt(r, "limit", lib.DefaultPageSize)
+	return
+}
+
+// ref resolves the dataset named by a route's path parameters
+func (h *v1Handlers) ref(params map[string]string) (repo.DatasetRef, error) {
+	ref := repo.DatasetRef{Peername: params["peer"], Name: params["name"]}
+	err := repo.CanonicalizeDatasetRef(h.repo, &ref)
+	return ref, err
+}
+
+func (h *v1Handlers) openAPI(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	writeV1JSON(w, http.StatusOK, v1OpenAPI(h.routes()))
+}
+
+func (h *v1Handlers) status(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	writeV1JSON(w, http.StatusOK, map[string]string{"status": "ok", "version": lib.VersionNumber})
+}
+
+func (h *v1Handlers) getProfile(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	in := true
+	res := &config.ProfilePod{}
+	if err := h.profiles.GetProfile(&in, res); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
+
+func (h *v1Handlers) listDatasets(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	h.list(w, r, lib.ListParams{})
+}
+
+func (h *v1Handlers) listPeerDatasets(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	h.list(w, r, lib.ListParams{Peername: params["peer"]})
+}
+
+func (h *v1Handlers) list(w http.ResponseWriter, r *http.Request, p lib.ListParams) {
+	offset, limit, err := v1Page(r)
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, err)
+		return
+	}
+	p.OrderBy = "created"
+	p.Offset = offset
+	p.Limit = limit
+
+	res := []repo.DatasetRef{}
+	if err := h.datasets.List(&p, &res); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
+
+func (h *v1Handlers) getDataset(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	ref, err := h.ref(params)
+	if err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+
+	res := &repo.DatasetRef{}
+	if err := h.datasets.Get(&ref, res); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
+
+func (h *v1Handlers) removeDataset(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	h.remove(w, params, rev.NewAllRevisions())
+}
+
+func (h *v1Handlers) removeVersions(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	count, err := v1QueryInt(r, "count", 1)
+	if err != nil || count == 0 {
+		writeV1Error(w, http.StatusBadRequest, fmt.Errorf("count must be a positive integer"))
+		return
+	}
+	h.remove(w, params, rev.Rev{Field: "ds", Gen: count})
+}
+
+func (h *v1Handlers) remove(w http.ResponseWriter, params map[string]string, revision rev.Rev) {
+	ref, err := h.ref(params)
+	if err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+
+	res := v1RemoveResult{Ref: ref}
+	p := &lib.RemoveParams{Ref: &ref, Revision: revision}
+	if err := h.datasets.Remove(p, &res.Removed); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
+
+func (h *v1Handlers) listVersions(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	offset, limit, err := v1Page(r)
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, err)
+		return
+	}
+	ref, err := h.ref(params)
+	if err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+
+	p := &lib.LogParams{
+		ListParams: lib.ListParams{Offset: offset, Limit: limit},
+		Ref:        ref,
+	}
+	res := []repo.DatasetRef{}
+	if err := h.logs.Log(p, &res); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
+
+func (h *v1Handlers) saveVersion(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	dsp := &dataset.DatasetPod{}
+	if err := json.NewDecoder(r.Body).Decode(dsp); err != nil {
+		writeV1Error(w, http.StatusBadRequest, fmt.Errorf("error decoding dataset: %s", err.Error()))
+		return
+	}
+	dsp.Peername = params["peer"]
+	dsp.Name = params["name"]
+
+	p := &lib.SaveParams{
+		Dataset:             dsp,
+		DryRun:              r.FormValue("dry_run") == "true",
+		ConvertFormatToPrev: true,
+		Strict:              r.FormValue("strict") == "true",
+		Ctx:                 actions.RefuseExec(r.Context()),
+	}
+	if dsp.Transform != nil && dsp.Transform.Secrets != nil {
+		p.Secrets = dsp.Transform.Secrets
+	}
+
+	res := &repo.DatasetRef{}
+	if err := h.datasets.Save(p, res); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	// don't leak local filepaths
+	res.Dataset.BodyPath = filepath.Base(res.Dataset.BodyPath)
+	writeV1JSON(w, http.StatusCreated, res)
+}
+
+func (h *v1Handlers) getBody(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	offset, limit, err := v1Page(r)
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, err)
+		return
+	}
+	ref, err := h.ref(params)
+	if err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+
+	mt := negotiateBodyType(r.Header.Get("Accept"))
+	if mt == nil {
+		mt = &bodyMediaType{"application/json", dataset.JSONDataFormat, ""}
+	}
+	if status, err := h.datasets.streamBody(w, r, ref, mt, limit, offset); err != nil {
+		writeV1Error(w, status, err)
+	}
+}
+
+func (h *v1Handlers) getStats(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	ref, err := h.ref(params)
+	if err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+
+	res := &base.Stats{}
+	if err := h.datasets.Stats(&ref, res); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
+
+func (h *v1Handlers) renameDataset(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	req := &v1RenameRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeV1Error(w, http.StatusBadRequest, fmt.Errorf("error decoding rename request: %s", err.Error()))
+		return
+	}
+	if req.Name == "" {
+		writeV1Error(w, http.StatusBadRequest, fmt.Errorf("name is required"))
+		return
+	}
+
+	ref, err := h.ref(params)
+	if err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+
+	p := &lib.RenameParams{
+		Current: ref,
+		New:     repo.DatasetRef{Peername: ref.Peername, Name: req.Name},
+	}
+	res := &repo.DatasetRef{}
+	if err := h.datasets.Rename(p, res); err != nil {
+		writeV1Error(w, http.StatusBadRequest, err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
+
+func (h *v1Handlers) publishDataset(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	h.setPublished(w, r, params, true)
+}
+
+func (h *v1Handlers) unpublishDataset(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	h.setPublished(w, r, params, false)
+}
+
+func (h *v1Handlers) setPublished(w http.ResponseWriter, r *http.Request, params map[string]string, published bool) {
+	ref, err := h.ref(params)
+	if err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+
+	ref.Published = published
+	registry := r.FormValue("registry") != "false"
+	p := &lib.SetPublishStatusParams{
+		Ref:               &ref,
+		UpdateRegistry:    registry,
+		UpdateRegistryPin: registry,
+	}
+	var ok bool
+	if err := h.datasets.SetPublishStatus(p, &ok); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, ref)
+}
+
+func (h *v1Handlers) updateDataset(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	ref, err := h.ref(params)
+	if err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+
+	p := &lib.UpdateParams{
+		Ref:    ref.String(),
+		DryRun: r.FormValue("dry_run") == "true",
+		Ctx:    actions.RefuseExec(r.Context()),
+	}
+	res := &repo.DatasetRef{}
+	if err := h.datasets.Update(p, res); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
+
+func (h *v1Handlers) listPeers(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	offset, limit, err := v1Page(r)
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	p := &lib.PeerListParams{
+		Offset: offset,
+		Limit:  limit,
+		Cached: r.FormValue("cached") == "true",
+	}
+	res := []*config.ProfilePod{}
+	if err := h.peers.List(p, &res); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
+
+func (h *v1Handlers) getPeer(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	res := &config.ProfilePod{}
+	if err := h.peers.Info(&lib.PeerInfoParams{Peername: params["peer"]}, res); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
+
+func (h *v1Handlers) searchDatasets(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	offset, limit, err := v1Page(r)
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, err)
+		return
+	}
+	q := r.FormValue("q")
+	if q == "" {
+		writeV1Error(w, http.StatusBadRequest, fmt.Errorf("q is required"))
+		return
+	}
+
+	p := &lib.SearchParams{QueryString: q, Offset: offset, Limit: limit}
+	res := []lib.SearchResult{}
+	if err := h.search.Search(p, &res); err != nil {
+		writeV1Error(w, v1ErrStatus(err), err)
+		return
+	}
+	writeV1JSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/qri/config"
+)
+
+var updateOpenAPI = flag.Bool("update-openapi", false, "overwrite testdata/openapi_v1.json with the generated OpenAPI document")
+
+const openAPIContractPath = "testdata/openapi_v1.json"
+
+func newTestV1Router(t *testing.T) (*v1Router, func()) {
+	node, teardown := newTestNode(t)
+	cfg := config.DefaultConfigForTesting()
+	return newV1Router(New(node, cfg)), teardown
+}
+
+// openAPIOperations summarizes each operation in an OpenAPI document as a
+// "METHOD path" key mapped to it's operationId & parameters
+func openAPIOperations(t *testing.T, data []byte) map[string]string {
+	doc := struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+		} `json:"paths"`
+	}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("error decoding OpenAPI document: %s", err.Error())
+	}
+
+	ops := map[string]string{}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			params := []string{}
+			for _, p := range op.Parameters {
+				params = append(params, p.In+":"+p.Name)
+			}
+			sort.Strings(params)
+			ops[strings.ToUpper(method)+" "+path] = fmt.Sprintf("%s(%s)", op.OperationID, strings.Join(params, ","))
+		}
+	}
+	return ops
+}
+
+// TestV1OpenAPIContract checks the served OpenAPI document against the
+// committed contract. Changing or removing a documented operation breaks
+// generated clients, if the change is intentional regenerate the contract
+// with: go test ./api -run TestV1OpenAPIContract -update-openapi
+func TestV1OpenAPIContract(t *testing.T) {
+	vr, teardown := newTestV1Router(t)
+	defer teardown()
+
+	w := httptest.NewRecorder()
+	vr.ServeHTTP(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got: %d", http.StatusOK, w.Code)
+	}
+
+	if *updateOpenAPI {
+		buf := &bytes.Buffer{}
+		if err := json.Indent(buf, w.Body.Bytes(), "", "  "); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(openAPIContractPath, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contract, err := ioutil.ReadFile(openAPIContractPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := openAPIOperations(t, contract)
+	got := openAPIOperations(t, w.Body.Bytes())
+	for key, op := range expect {
+		if got[key] == "" {
+			t.Errorf("%s is in the contract but isn't served", key)
+		} else if got[key] != op {
+			t.Errorf("%s changed. contract: %s, served: %s", key, op, got[key])
+		}
+	}
+	for key := range got {
+		if expect[key] == "" {
+			t.Errorf("%s is served but missing from the contract", key)
+		}
+	}
+}
+
+// TestV1RoutesMatchSpec checks every operation in the contract is routed to a
+// handler with the documented operationId
+func TestV1RoutesMatchSpec(t *testing.T) {
+	vr, teardown := newTestV1Router(t)
+	defer teardown()
+
+	contract, err := ioutil.ReadFile(openAPIContractPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, op := range openAPIOperations(t, contract) {
+		parts := strings.SplitN(key, " ", 2)
+		method, path := parts[0], parts[1]
+		for _, name := range v1PathParams(path) {
+			path = strings.Replace(path, "{"+name+"}", "x", 1)
+		}
+
+		rt, _, _ := vr.match(method, path)
+		if rt == nil {
+			t.Errorf("%s: no route for %s %s", key, method, path)
+			continue
+		}
+		if !strings.HasPrefix(op, rt.OperationID+"(") {
+			t.Errorf("%s: expected operation %s, routed to: %s", key, op, rt.OperationID)
+		}
+	}
+}
+
+func TestV1Router(t *testing.T) {
+	vr, teardown := newTestV1Router(t)
+	defer teardown()
+
+	cases := []struct {
+		method, path, accept string
+		body                 string
+		status               int
+	}{
+		{"GET", "/v1/status", "", "", http.StatusOK},
+		{"GET", "/v1/me", "", "", http.StatusOK},
+		{"GET", "/v1/ds", "", "", http.StatusOK},
+		{"GET", "/v1/ds?limit=nope", "", "", http.StatusBadRequest},
+		{"GET", "/v1/ds/peer/movies", "", "", http.StatusOK},
+		{"GET", "/v1/ds/peer/movies/versions", "", "", http.StatusOK},
+		{"GET", "/v1/ds/peer/movies/body?limit=5", "", "", http.StatusOK},
+		{"GET", "/v1/ds/peer/movies/body", "text/csv", "", http.StatusOK},
+		{"GET", "/v1/ds/peer/movies/stats", "", "", http.StatusOK},
+		{"GET", "/v1/ds/peer/not_a_dataset", "", "", http.StatusNotFound},
+		{"POST", "/v1/ds/peer/movies/rename", "", `{}`, http.StatusBadRequest},
+		{"GET", "/v1/search", "", "", http.StatusBadRequest},
+		{"PATCH", "/v1/ds/peer/movies", "", "", http.StatusMethodNotAllowed},
+		{"OPTIONS", "/v1/ds/peer/movies", "", "", http.StatusOK},
+		{"GET", "/v1/not/a/route/at/all", "", "", http.StatusNotFound},
+	}
+
+	for i, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+		w := httptest.NewRecorder()
+		vr.ServeHTTP(w, r)
+
+		if w.Code != c.status {
+			t.Errorf("case %d %s %s: expected status %d, got: %d. body: %s", i, c.method, c.path, c.status, w.Code, w.Body.String())
+			continue
+		}
+		if w.Code >= 400 {
+			res := v1ErrorResponse{}
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Error.Status != c.status {
+				t.Errorf("case %d expected error response with status %d, got: %s", i, c.status, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestMatchV1Path(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		match         bool
+		params        map[string]string
+	}{
+		{"/v1/ds", "/v1/ds", true, map[string]string{}},
+		{"/v1/ds", "/v1/ds/", true, map[string]string{}},
+		{"/v1/ds/{peer}/{name}", "/v1/ds/b5/world_bank", true, map[string]string{"peer": "b5", "name": "world_bank"}},
+		{"/v1/ds/{peer}/{name}", "/v1/ds/b5", false, nil},
+		{"/v1/ds/{peer}/{name}/body", "/v1/ds/b5/world_bank/versions", false, nil},
+		{"/v1/peers/{peer}", "/v1/peers/%20", true, map[string]string{"peer": " "}},
+	}
+
+	for i, c := range cases {
+		params, ok := matchV1Path(c.pattern, c.path)
+		if ok != c.match {
+			t.Errorf("case %d expected match %t, got: %t", i, c.match, ok)
+			continue
+		}
+		for k, v := range c.params {
+			if params[k] != v {
+				t.Errorf("case %d param %s expected: %q, got: %q", i, k, v, params[k])
+			}
+		}
+	}
+}
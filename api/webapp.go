@@ -25,6 +25,11 @@ func (s *Server) ServeWebapp() {
 	m.Handle("/webapp/", s.FrontendHandler("/webapp"))
 
 	webappserver := &http.Server{Handler: m}
+	if !s.register(func() { s.webapp = webappserver }) {
+		listener.Close()
+		return
+	}
+
 	webappserver.Serve(listener)
 	return
 }
@@ -0,0 +1,352 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+// ConvertBodyPage reads a page of entries from file, which has structure in,
+// and encodes them according to structure out. if all is true limit & offset
+// are ignored. fields optionally projects each entry down to a set of named
+// fields, see ProjectEntries
+func ConvertBodyPage(file cafs.File, in, out *dataset.Structure, limit, offset int, all bool, fields []string) (data []byte, err error) {
+	rr, err := NewBodyReader(in, BodyFileEncoding(file.FileName()), file)
+	if err != nil {
+		err = fmt.Errorf("error allocating data reader: %s", err)
+		return
+	}
+
+	if rr, out, err = projectBody(rr, out, fields); err != nil {
+		return nil, err
+	}
+
+	buf, err := dsio.NewEntryBuffer(out)
+	if err != nil {
+		err = fmt.Errorf("error allocating result buffer: %s", err)
+		return
+	}
+
+	if !all {
+		rr = &dsio.PagedReader{
+			Reader: rr,
+			Limit:  limit,
+			Offset: offset,
+		}
+	}
+	if err = dsio.Copy(rr, buf); err != nil {
+		return nil, err
+	}
+
+	if err := buf.Close(); err != nil {
+		return nil, fmt.Errorf("error closing row buffer: %s", err.Error())
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Body encodings are formats bodies can be read from & written to that aren't
+// a dataset.DataFormat. Bodies are never stored in these encodings, they're
+// converted to JSON on save, see DecodeBody
+const (
+	// NDJSONEncoding is JSON entries as newline-delimited JSON values
+	NDJSONEncoding = "ndjson"
+	// XLSXEncoding is entries as rows of an XLSX workbook
+	XLSXEncoding = "xlsx"
+	// ParquetEncoding is entries as rows of a parquet file
+	ParquetEncoding = "parquet"
+)
+
+// BodyFileEncoding gives the body encoding of a file from it's extension,
+// returning "" if the file isn't in a body encoding
+func BodyFileEncoding(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ndjson", ".jsonl":
+		return NDJSONEncoding
+	case ".xlsx":
+		return XLSXEncoding
+	case ".parquet":
+		return ParquetEncoding
+	default:
+		return ""
+	}
+}
+
+// NewBodyReader allocates an entry reader for a body in a body encoding. An
+// empty encoding reads st.Format with dsio.NewEntryReader. Parquet & XLSX
+// bodies are read whole before any entries are returned. Readers for
+// encodings that carry their own schema replace the schema of st
+func NewBodyReader(st *dataset.Structure, encoding string, r io.Reader) (dsio.EntryReader, error) {
+	switch encoding {
+	case NDJSONEncoding:
+		return NewNDJSONReader(st, r), nil
+	case XLSXEncoding:
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		body, err := NewXLSXBody("body.xlsx", data, nil)
+		if err != nil {
+			return nil, err
+		}
+		return dsio.NewEntryReader(body.Structure, body)
+	case ParquetEncoding:
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return NewParquetReader(st, data)
+	case "":
+		return dsio.NewEntryReader(st, r)
+	default:
+		return nil, fmt.Errorf("unknown body encoding: %s", encoding)
+	}
+}
+
+// NewBodyWriter allocates an entry writer for a body encoding. An empty
+// encoding writes st.Format with dsio.NewEntryWriter
+func NewBodyWriter(st *dataset.Structure, encoding string, w io.Writer) (dsio.EntryWriter, error) {
+	switch encoding {
+	case NDJSONEncoding:
+		if st.Format != dataset.JSONDataFormat {
+			return nil, fmt.Errorf("line-delimited output requires json format, got: %s", st.Format)
+		}
+		return NewNDJSONWriter(st, w), nil
+	case XLSXEncoding:
+		ew, err := NewXLSXWriter(st, w)
+		if err != nil {
+			return nil, fmt.Errorf("error allocating data writer: %s", err)
+		}
+		return ew, nil
+	case ParquetEncoding:
+		return NewParquetWriter(st, w), nil
+	case "":
+		ew, err := dsio.NewEntryWriter(st, w)
+		if err != nil {
+			return nil, fmt.Errorf("error allocating data writer: %s", err)
+		}
+		return ew, nil
+	default:
+		return nil, fmt.Errorf("unknown body encoding: %s", encoding)
+	}
+}
+
+// WriteBody streams entries from file, which has structure in, to w, encoded
+// according to structure out. Unlike ConvertBodyPage entries are written as
+// they're read, so bodies of any size can be written without buffering.
+// if all is true every entry from offset onward is written, otherwise at most
+// limit entries are written. encoding optionally names a body encoding to
+// write entries in instead of out.Format, see NewBodyWriter
+func WriteBody(w io.Writer, file cafs.File, in, out *dataset.Structure, limit, offset int, all bool, encoding string, fields []string) error {
+	rr, err := NewBodyReader(in, BodyFileEncoding(file.FileName()), file)
+	if err != nil {
+		return fmt.Errorf("error allocating data reader: %s", err)
+	}
+
+	if rr, out, err = projectBody(rr, out, fields); err != nil {
+		return err
+	}
+
+	ew, err := NewBodyWriter(out, encoding, w)
+	if err != nil {
+		return err
+	}
+
+	for i, written := 0, 0; all || written < limit; i++ {
+		ent, err := rr.ReadEntry()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("error reading entry %d: %s", i, err)
+		}
+		if i < offset {
+			continue
+		}
+		if err := ew.WriteEntry(ent); err != nil {
+			return fmt.Errorf("error writing entry %d: %s", i, err)
+		}
+		written++
+	}
+
+	return ew.Close()
+}
+
+// projectBody applies ProjectEntries to rr if any fields are given, returning
+// out with it's schema replaced by the projected schema
+func projectBody(rr dsio.EntryReader, out *dataset.Structure, fields []string) (dsio.EntryReader, *dataset.Structure, error) {
+	if len(fields) == 0 {
+		return rr, out, nil
+	}
+
+	rr, err := ProjectEntries(rr, fields)
+	if err != nil {
+		return nil, nil, err
+	}
+	out = &dataset.Structure{
+		Format:       out.Format,
+		FormatConfig: out.FormatConfig,
+		Schema:       rr.Structure().Schema,
+	}
+	return rr, out, nil
+}
+
+// schemaColumns gives the column definitions of an array-of-arrays schema
+// (items.items)
+func schemaColumns(st *dataset.Structure) []interface{} {
+	if st == nil || st.Schema == nil {
+		return nil
+	}
+	data, err := st.Schema.MarshalJSON()
+	if err != nil {
+		return nil
+	}
+	sch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &sch); err != nil {
+		return nil
+	}
+	items, _ := sch["items"].(map[string]interface{})
+	cols, _ := items["items"].([]interface{})
+	return cols
+}
+
+// schemaColumnTitles reads at least width column titles from an
+// array-of-arrays schema, falling back to field_1, field_2... for columns
+// without a title
+func schemaColumnTitles(st *dataset.Structure, width int) []string {
+	cols := schemaColumns(st)
+	if len(cols) > width {
+		width = len(cols)
+	}
+
+	titles := make([]string, width)
+	for i := range titles {
+		titles[i] = fmt.Sprintf("field_%d", i+1)
+		if i < len(cols) {
+			if c, ok := cols[i].(map[string]interface{}); ok {
+				if t, ok := c["title"].(string); ok && t != "" {
+					titles[i] = t
+				}
+			}
+		}
+	}
+	return titles
+}
+
+// schemaColumnTypes reads column types from an array-of-arrays schema.
+// columns without a single type have an empty type
+func schemaColumnTypes(st *dataset.Structure) []string {
+	cols := schemaColumns(st)
+	types := make([]string, len(cols))
+	for i, col := range cols {
+		if c, ok := col.(map[string]interface{}); ok {
+			types[i], _ = c["type"].(string)
+		}
+	}
+	return types
+}
+
+// ProjectEntries wraps an EntryReader, keeping only the named fields of each
+// entry. Object entries are matched by key. Array entries are matched against
+// the titles of the schema's column definitions (items.items[n].title)
+func ProjectEntries(r dsio.EntryReader, fields []string) (dsio.EntryReader, error) {
+	st := r.Structure()
+	if st == nil || st.Schema == nil {
+		return nil, fmt.Errorf("projecting fields requires a schema")
+	}
+
+	data, err := st.Schema.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	sch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &sch); err != nil {
+		return nil, err
+	}
+
+	pr := &projectedReader{EntryReader: r, fields: fields}
+
+	// array-of-array bodies map field names to column indices using the schema
+	items, _ := sch["items"].(map[string]interface{})
+	cols, _ := items["items"].([]interface{})
+	if len(cols) > 0 {
+		projected := make([]interface{}, len(fields))
+		for i, field := range fields {
+			idx := -1
+			for j, col := range cols {
+				if c, ok := col.(map[string]interface{}); ok && c["title"] == field {
+					idx = j
+					projected[i] = c
+					break
+				}
+			}
+			if idx == -1 {
+				return nil, fmt.Errorf("field '%s' not found in schema", field)
+			}
+			pr.indices = append(pr.indices, idx)
+		}
+		items["items"] = projected
+	}
+
+	if data, err = json.Marshal(sch); err != nil {
+		return nil, err
+	}
+	rs := &jsonschema.RootSchema{}
+	if err := rs.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+
+	pr.st = &dataset.Structure{}
+	pr.st.Assign(st, &dataset.Structure{Schema: rs})
+	return pr, nil
+}
+
+// projectedReader implements the dsio.EntryReader interface, filtering the
+// value of each entry down to a set of fields
+type projectedReader struct {
+	dsio.EntryReader
+	st      *dataset.Structure
+	fields  []string
+	indices []int
+}
+
+// Structure gives the projected structure of this reader
+func (r *projectedReader) Structure() *dataset.Structure {
+	return r.st
+}
+
+// ReadEntry reads & projects a single entry
+func (r *projectedReader) ReadEntry() (dsio.Entry, error) {
+	ent, err := r.EntryReader.ReadEntry()
+	if err != nil {
+		return ent, err
+	}
+
+	switch v := ent.Value.(type) {
+	case map[string]interface{}:
+		projected := map[string]interface{}{}
+		for _, field := range r.fields {
+			if val, ok := v[field]; ok {
+				projected[field] = val
+			}
+		}
+		ent.Value = projected
+	case []interface{}:
+		projected := make([]interface{}, len(r.indices))
+		for i, idx := range r.indices {
+			if idx < len(v) {
+				projected[i] = v[idx]
+			}
+		}
+		ent.Value = projected
+	}
+	return ent, nil
+}
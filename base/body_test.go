@@ -0,0 +1,101 @@
+package base
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsfs"
+)
+
+func TestConvertBodyPage(t *testing.T) {
+	r := newTestRepo(t)
+	ref := addCitiesDataset(t, r)
+
+	ds, err := dsfs.LoadDataset(r.Store(), ref.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		limit, offset int
+		all           bool
+		fields        []string
+		expect        string
+		err           string
+	}{
+		{1, 1, false, nil, `[["new york",8500000,44.4,true]]`, ""},
+		{2, 0, false, []string{"city"}, `[["toronto"],["new york"]]`, ""},
+		{1, 2, false, []string{"in_usa", "city"}, `[[true,"chicago"]]`, ""},
+		{1, 0, false, []string{"nope"}, "", "field 'nope' not found in schema"},
+	}
+
+	for i, c := range cases {
+		file, err := dsfs.LoadBody(r.Store(), ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := &dataset.Structure{
+			Format: dataset.JSONDataFormat,
+			Schema: ds.Structure.Schema,
+		}
+		got, err := ConvertBodyPage(file, ds.Structure, out, c.limit, c.offset, c.all, c.fields)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if string(got) != c.expect {
+			t.Errorf("case %d result mismatch. expected: %s, got: %s", i, c.expect, string(got))
+		}
+	}
+}
+
+func TestWriteBody(t *testing.T) {
+	r := newTestRepo(t)
+	ref := addCitiesDataset(t, r)
+
+	ds, err := dsfs.LoadDataset(r.Store(), ref.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		format        dataset.DataFormat
+		limit, offset int
+		all           bool
+		encoding      string
+		fields        []string
+		expect        string
+		err           string
+	}{
+		{dataset.JSONDataFormat, 1, 1, false, "", nil, `[["new york",8500000,44.4,true]]`, ""},
+		{dataset.JSONDataFormat, 2, 0, false, NDJSONEncoding, []string{"city"}, "[\"toronto\"]\n[\"new york\"]\n", ""},
+		{dataset.JSONDataFormat, 0, 3, true, NDJSONEncoding, []string{"city"}, "[\"chatham\"]\n[\"raleigh\"]\n", ""},
+		{dataset.CSVDataFormat, 1, 2, false, "", []string{"in_usa", "city"}, "true,chicago\n", ""},
+		{dataset.CSVDataFormat, 1, 0, false, NDJSONEncoding, nil, "", "line-delimited output requires json format, got: csv"},
+		{dataset.JSONDataFormat, 1, 0, false, "", []string{"nope"}, "", "field 'nope' not found in schema"},
+		{dataset.JSONDataFormat, 1, 0, false, "nope", nil, "", "unknown body encoding: nope"},
+	}
+
+	for i, c := range cases {
+		file, err := dsfs.LoadBody(r.Store(), ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := &dataset.Structure{
+			Format: c.format,
+			Schema: ds.Structure.Schema,
+		}
+		buf := &bytes.Buffer{}
+		err = WriteBody(buf, file, ds.Structure, out, c.limit, c.offset, c.all, c.encoding, c.fields)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if c.err == "" && buf.String() != c.expect {
+			t.Errorf("case %d result mismatch. expected: %q, got: %q", i, c.expect, buf.String())
+		}
+	}
+}
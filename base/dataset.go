@@ -20,7 +20,7 @@ import (
 )
 
 // ListDatasets lists datasets from a repo
-func ListDatasets(r repo.Repo, limit, offset int, RPC, publishedOnly bool) (res []repo.DatasetRef, err error) {
+func ListDatasets(r repo.Repo, limit, offset int, publishedOnly bool) (res []repo.DatasetRef, err error) {
 	store := r.Store()
 	res, err = r.References(limit, offset)
 	if err != nil {
@@ -52,9 +52,6 @@ func ListDatasets(r repo.Repo, limit, offset int, RPC, publishedOnly bool) (res
 			return nil, fmt.Errorf("error loading path: %s, err: %s", ref.Path, err.Error())
 		}
 		res[i].Dataset = ds.Encode()
-		if RPC {
-			res[i].Dataset.Structure.Schema = nil
-		}
 	}
 
 	// TODO: If renames.Renames is non-empty, apply it to r
@@ -122,6 +119,11 @@ func CreateDataset(r repo.Repo, streams ioes.IOStreams, name string, ds, dsPrev
 	if err = ReadDataset(r, &ref); err != nil {
 		return
 	}
+	// profile the stored body. stats can always be recomputed from the body, so
+	// failing to compute them doesn't fail the save
+	if _, e := DatasetStats(r, ref); e != nil {
+		log.Debugf("error computing stats: %s", e.Error())
+	}
 	if resBody, err = r.Store().Get(ref.Dataset.BodyPath); err != nil {
 		fmt.Println("error getting from store:", err.Error())
 	}
@@ -202,6 +204,7 @@ func UnpinDataset(r repo.Repo, ref repo.DatasetRef) error {
 
 // DatasetPodBodyFile creates a streaming data file from a DatasetPod using the following precedence:
 // * dsp.BodyBytes not being nil (requires dsp.Structure.Format be set to know data format)
+// * dsp.BodyPath being a source url, see IsSourcePath
 // * dsp.BodyPath being a url
 // * dsp.BodyPath being a path on the local filesystem
 // TODO - consider moving this func to some other package. maybe actions?
@@ -210,14 +213,25 @@ func DatasetPodBodyFile(store cafs.Filestore, dsp *dataset.DatasetPod) (cafs.Fil
 		if dsp.Structure == nil || dsp.Structure.Format == "" {
 			return nil, fmt.Errorf("specifying bodyBytes requires format be specified in dataset.structure")
 		}
-		return cafs.NewMemfileBytes(fmt.Sprintf("body.%s", dsp.Structure.Format), dsp.BodyBytes), nil
+		filename := fmt.Sprintf("body.%s", dsp.Structure.Format)
+		if BodyFileEncoding(filename) == XLSXEncoding {
+			return xlsxPodBody(dsp, filename, dsp.BodyBytes)
+		}
+		decodedPodFormat(dsp)
+		return cafs.NewMemfileBytes(filename, dsp.BodyBytes), nil
 	}
+	decodedPodFormat(dsp)
 
 	// all other methods are based on path, bail if we don't have one
 	if dsp.BodyPath == "" {
 		return nil, nil
 	}
 
+	// source urls are pulled from databases & apis, see PullSource
+	if IsSourcePath(dsp.BodyPath) {
+		return sourcePodBody(dsp)
+	}
+
 	loweredPath := strings.ToLower(dsp.BodyPath)
 
 	// if opening protocol is http/s, we're dealing with a web request
@@ -225,7 +239,7 @@ func DatasetPodBodyFile(store cafs.Filestore, dsp *dataset.DatasetPod) (cafs.Fil
 		// TODO - attempt to determine file format based on response headers
 		filename := filepath.Base(dsp.BodyPath)
 
-		res, err := http.Get(dsp.BodyPath)
+		res, err := httpClient.Get(dsp.BodyPath)
 		if err != nil {
 			return nil, fmt.Errorf("fetching body url: %s", err.Error())
 		}
@@ -233,6 +247,14 @@ func DatasetPodBodyFile(store cafs.Filestore, dsp *dataset.DatasetPod) (cafs.Fil
 			return nil, fmt.Errorf("invalid status code fetching body url: %d", res.StatusCode)
 		}
 
+		if strings.ToLower(filepath.Ext(filename)) == ".xlsx" {
+			data, err := readAllXLSX(res.Body)
+			if err != nil {
+				return nil, err
+			}
+			return xlsxPodBody(dsp, filename, data)
+		}
+
 		return cafs.NewMemfileReader(filename, res.Body), nil
 	}
 
@@ -240,8 +262,18 @@ func DatasetPodBodyFile(store cafs.Filestore, dsp *dataset.DatasetPod) (cafs.Fil
 		return store.Get(dsp.BodyPath)
 	}
 
-	// convert yaml input to json as a hack to support yaml input for now
 	ext := strings.ToLower(filepath.Ext(dsp.BodyPath))
+
+	// bodies are never stored as xlsx, workbooks are converted to json
+	if ext == ".xlsx" {
+		data, err := ioutil.ReadFile(dsp.BodyPath)
+		if err != nil {
+			return nil, fmt.Errorf("body file: %s", err.Error())
+		}
+		return xlsxPodBody(dsp, filepath.Base(dsp.BodyPath), data)
+	}
+
+	// convert yaml input to json as a hack to support yaml input for now
 	if ext == ".yaml" || ext == ".yml" {
 		yamlBody, err := ioutil.ReadFile(dsp.BodyPath)
 		if err != nil {
@@ -264,17 +296,53 @@ func DatasetPodBodyFile(store cafs.Filestore, dsp *dataset.DatasetPod) (cafs.Fil
 	return cafs.NewMemfileReader(filepath.Base(dsp.BodyPath), file), nil
 }
 
+// decodedPodFormat sets the format of a dataset structure that names a body
+// encoding InferValues converts to json, like ndjson or parquet, to json.
+// after saving the structure describes the converted body
+func decodedPodFormat(dsp *dataset.DatasetPod) {
+	if dsp.Structure == nil {
+		return
+	}
+	switch dsp.Structure.Format {
+	case NDJSONEncoding, ParquetEncoding:
+		dsp.Structure.Format = dataset.JSONDataFormat.String()
+		dsp.Structure.FormatConfig = nil
+	}
+}
+
+// xlsxPodBody converts an XLSX workbook body to JSON. dsp.Structure may
+// give xlsx options in it's formatConfig, which are consumed here, leaving
+// dsp.Structure describing the converted JSON body
+func xlsxPodBody(dsp *dataset.DatasetPod, filename string, data []byte) (cafs.File, error) {
+	var cfg map[string]interface{}
+	if dsp.Structure != nil {
+		cfg = dsp.Structure.FormatConfig
+	}
+	body, err := NewXLSXBody(filename, data, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if dsp.Structure != nil {
+		dsp.Structure.Format = dataset.JSONDataFormat.String()
+		dsp.Structure.FormatConfig = nil
+	}
+	return body, nil
+}
+
 // ConvertBodyFormat rewrites a body from a source format to a destination format.
-func ConvertBodyFormat(bodyFile cafs.File, fromSt, toSt *dataset.Structure) (cafs.File, error) {
+// bodies in a body encoding are read according to the extension of
+// bodyFile's name, encoding optionally writes the converted body in a body
+// encoding instead of toSt.Format, see NewBodyWriter
+func ConvertBodyFormat(bodyFile cafs.File, fromSt, toSt *dataset.Structure, encoding string) (cafs.File, error) {
 	// Reader for entries of the source body.
-	r, err := dsio.NewEntryReader(fromSt, bodyFile)
+	r, err := NewBodyReader(fromSt, BodyFileEncoding(bodyFile.FileName()), bodyFile)
 	if err != nil {
 		return nil, err
 	}
 
 	// Writes entries to a new body.
 	buffer := &bytes.Buffer{}
-	w, err := dsio.NewEntryWriter(toSt, buffer)
+	w, err := NewBodyWriter(toSt, encoding, buffer)
 	if err != nil {
 		return nil, err
 	}
@@ -288,5 +356,48 @@ func ConvertBodyFormat(bodyFile cafs.File, fromSt, toSt *dataset.Structure) (caf
 		return nil, err
 	}
 
-	return cafs.NewMemfileReader(fmt.Sprintf("body.%s", toSt.Format), buffer), nil
+	ext := toSt.Format.String()
+	if encoding != "" {
+		ext = encoding
+	}
+	return cafs.NewMemfileReader(fmt.Sprintf("body.%s", ext), buffer), nil
+}
+
+// DecodeBody converts a body file in a body encoding to JSON, detecting the
+// encoding from the extension of the file's name. Files that aren't in a body
+// encoding are returned as-is with a nil structure. The returned structure
+// describes the converted body, it's schema is only set for encodings that
+// carry one
+func DecodeBody(file cafs.File) (cafs.File, *dataset.Structure, error) {
+	encoding := BodyFileEncoding(file.FileName())
+	if encoding == "" {
+		return file, nil, nil
+	}
+
+	st := &dataset.Structure{Format: dataset.JSONDataFormat}
+	r, err := NewBodyReader(st, encoding, file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading %s body: %s", encoding, err.Error())
+	}
+	st.Schema = r.Structure().Schema
+
+	out := st
+	if out.Schema == nil {
+		out = &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray}
+	}
+	buf := &bytes.Buffer{}
+	w, err := dsio.NewEntryWriter(out, buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := dsio.Copy(r, w); err != nil {
+		return nil, nil, fmt.Errorf("reading %s body: %s", encoding, err.Error())
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	name := filepath.Base(file.FileName())
+	name = fmt.Sprintf("%s.json", strings.TrimSuffix(name, filepath.Ext(name)))
+	return cafs.NewMemfileBytes(name, buf.Bytes()), st, nil
 }
@@ -56,6 +56,19 @@ func InferValues(pro *profile.Profile, name *string, ds *dataset.Dataset, body c
 		*name = varName.CreateVarNameFromString(body.FileName())
 	}
 
+	// bodies are never stored in body encodings like ndjson & parquet, convert
+	// them to json, keeping any structure they carry
+	var encoded *dataset.Structure
+	if body != nil {
+		var err error
+		if body, encoded, err = DecodeBody(body); err != nil {
+			return nil, err
+		}
+		if encoded != nil && ds.Structure != nil {
+			ds.Structure.Format = dataset.JSONDataFormat
+		}
+	}
+
 	// infer commit values
 	if ds.Commit == nil {
 		ds.Commit = &dataset.Commit{}
@@ -67,23 +80,38 @@ func InferValues(pro *profile.Profile, name *string, ds *dataset.Dataset, body c
 
 	// if we don't have a structure or schema then attempt to determine one
 	if body != nil && (ds.Structure == nil || ds.Structure.Schema == nil) {
-		// use a TeeReader that writes to a buffer to preserve data
-		buf := &bytes.Buffer{}
-		tr := io.TeeReader(body, buf)
-		var df dataset.DataFormat
-
-		df, err := detect.ExtensionDataFormat(body.FileName())
-		if err != nil {
-			log.Debug(err.Error())
-			err = fmt.Errorf("invalid data format: %s", err.Error())
-			return nil, err
-		}
-
-		guessedStructure, _, err := detect.FromReader(df, tr)
-		if err != nil {
-			log.Debug(err.Error())
-			err = fmt.Errorf("determining dataset structure: %s", err.Error())
-			return nil, err
+		var guessedStructure *dataset.Structure
+
+		if xb, ok := body.(*XLSXBody); ok {
+			// workbooks carry a structure inferred from cell types
+			guessedStructure = xb.Structure
+		} else if sb, ok := body.(*SourceBody); ok {
+			// sources carry a structure inferred from the source
+			guessedStructure = sb.Structure
+		} else if encoded != nil && encoded.Schema != nil {
+			guessedStructure = encoded
+		} else {
+			// use a TeeReader that writes to a buffer to preserve data
+			buf := &bytes.Buffer{}
+			tr := io.TeeReader(body, buf)
+			var df dataset.DataFormat
+
+			df, err := detect.ExtensionDataFormat(body.FileName())
+			if err != nil {
+				log.Debug(err.Error())
+				err = fmt.Errorf("invalid data format: %s", err.Error())
+				return nil, err
+			}
+
+			guessedStructure, _, err = detect.FromReader(df, tr)
+			if err != nil {
+				log.Debug(err.Error())
+				err = fmt.Errorf("determining dataset structure: %s", err.Error())
+				return nil, err
+			}
+
+			// glue whatever we just read back onto the reader
+			body = cafs.NewMemfileReader(body.FileName(), io.MultiReader(buf, body))
 		}
 
 		// attach the structure, schema, and formatConfig, as appropriate
@@ -96,9 +124,6 @@ func InferValues(pro *profile.Profile, name *string, ds *dataset.Dataset, body c
 		if ds.Structure.FormatConfig == nil {
 			ds.Structure.FormatConfig = guessedStructure.FormatConfig
 		}
-
-		// glue whatever we just read back onto the reader
-		body = cafs.NewMemfileReader(body.FileName(), io.MultiReader(buf, body))
 	}
 
 	if ds.Transform != nil && ds.Transform.IsEmpty() {
@@ -1,10 +1,13 @@
 package base
 
 import (
+	"bytes"
+	"io/ioutil"
 	"testing"
 
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
 	"github.com/qri-io/jsonschema"
 )
 
@@ -137,6 +140,66 @@ func TestInferValuesSchema(t *testing.T) {
 	}
 }
 
+func TestInferValuesBodyEncodings(t *testing.T) {
+	r := newTestRepo(t)
+	pro, err := r.Profile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pq := &bytes.Buffer{}
+	w := NewParquetWriter(&dataset.Structure{Format: dataset.JSONDataFormat}, pq)
+	w.WriteEntry(dsio.Entry{Index: 0, Value: []interface{}{"cat", 1.4}})
+	w.WriteEntry(dsio.Entry{Index: 1, Value: []interface{}{"dog", 3.7}})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		body         cafs.File
+		name, expect string
+		schema       string
+	}{
+		{cafs.NewMemfileBytes("animals.ndjson", []byte("[\"cat\",1.4]\n[\"dog\",3.7]\n")),
+			"animals.json",
+			`[["cat",1.4],["dog",3.7]]`,
+			// ndjson schemas are detected from the converted json
+			""},
+		{cafs.NewMemfileBytes("animals.parquet", pq.Bytes()),
+			"animals.json",
+			`[["cat",1.4],["dog",3.7]]`,
+			`{"items":{"items":[{"title":"field_1","type":"string"},{"title":"field_2","type":"number"}],"type":"array"},"type":"array"}`},
+	}
+
+	for i, c := range cases {
+		name := "animals"
+		ds := &dataset.Dataset{}
+		body, err := InferValues(pro, &name, ds, c.body)
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		if body.FileName() != c.name {
+			t.Errorf("case %d filename mismatch. expected: %s, got: %s", i, c.name, body.FileName())
+		}
+		if ds.Structure.Format != dataset.JSONDataFormat {
+			t.Errorf("case %d expected format json, got %s", i, ds.Structure.Format)
+		}
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != c.expect {
+			t.Errorf("case %d body mismatch. expected: %s, got: %s", i, c.expect, string(data))
+		}
+		if ds.Structure.Schema == nil {
+			t.Errorf("case %d expected a schema to be inferred", i)
+		} else if got := datasetSchemaToJSON(ds); c.schema != "" && got != c.schema {
+			t.Errorf("case %d schema mismatch. expected: %s, got: %s", i, c.schema, got)
+		}
+	}
+}
+
 func TestInferValuesDontOverwriteSchema(t *testing.T) {
 	r := newTestRepo(t)
 	pro, err := r.Profile()
@@ -20,7 +20,7 @@ func TestListDatasets(t *testing.T) {
 	r := newTestRepo(t)
 	ref := addCitiesDataset(t, r)
 
-	res, err := ListDatasets(r, 1, 0, false, false)
+	res, err := ListDatasets(r, 1, 0, false)
 	if err != nil {
 		t.Error(err.Error())
 	}
@@ -28,7 +28,7 @@ func TestListDatasets(t *testing.T) {
 		t.Error("expected one dataset response")
 	}
 
-	res, err = ListDatasets(r, 1, 0, false, true)
+	res, err = ListDatasets(r, 1, 0, true)
 	if err != nil {
 		t.Error(err.Error())
 	}
@@ -41,7 +41,7 @@ func TestListDatasets(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	res, err = ListDatasets(r, 1, 0, false, true)
+	res, err = ListDatasets(r, 1, 0, true)
 	if err != nil {
 		t.Error(err.Error())
 	}
@@ -424,7 +424,7 @@ func TestConvertBodyFormat(t *testing.T) {
 
 	// CSV -> JSON
 	body := cafs.NewMemfileBytes("", []byte("a,b,c"))
-	got, err := ConvertBodyFormat(body, csvStructure, jsonStructure)
+	got, err := ConvertBodyFormat(body, csvStructure, jsonStructure, "")
 	if err != nil {
 		t.Error(err.Error())
 	}
@@ -438,7 +438,7 @@ func TestConvertBodyFormat(t *testing.T) {
 
 	// CSV -> JSON, multiple lines
 	body = cafs.NewMemfileBytes("", []byte("a,b,c\n\rd,e,f\n\rg,h,i"))
-	got, err = ConvertBodyFormat(body, csvStructure, jsonStructure)
+	got, err = ConvertBodyFormat(body, csvStructure, jsonStructure, "")
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -452,7 +452,7 @@ func TestConvertBodyFormat(t *testing.T) {
 
 	// JSON -> CSV
 	body = cafs.NewMemfileBytes("", []byte(`[["a","b","c"]]`))
-	got, err = ConvertBodyFormat(body, jsonStructure, csvStructure)
+	got, err = ConvertBodyFormat(body, jsonStructure, csvStructure, "")
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -466,7 +466,7 @@ func TestConvertBodyFormat(t *testing.T) {
 
 	// CSV -> CSV
 	body = cafs.NewMemfileBytes("", []byte("a,b,c"))
-	got, err = ConvertBodyFormat(body, csvStructure, csvStructure)
+	got, err = ConvertBodyFormat(body, csvStructure, csvStructure, "")
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -480,7 +480,7 @@ func TestConvertBodyFormat(t *testing.T) {
 
 	// JSON -> JSON
 	body = cafs.NewMemfileBytes("", []byte(`[["a","b","c"]]`))
-	got, err = ConvertBodyFormat(body, jsonStructure, jsonStructure)
+	got, err = ConvertBodyFormat(body, jsonStructure, jsonStructure, "")
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -491,4 +491,60 @@ func TestConvertBodyFormat(t *testing.T) {
 	if !bytes.Equal(data, []byte(`[["a","b","c"]]`)) {
 		t.Error(fmt.Errorf("converted body didn't match, got: %s", data))
 	}
+
+	// NDJSON -> JSON
+	body = cafs.NewMemfileBytes("body.ndjson", []byte("[\"a\",\"b\",\"c\"]\n[\"d\",\"e\",\"f\"]\n"))
+	got, err = ConvertBodyFormat(body, jsonStructure, jsonStructure, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err = ioutil.ReadAll(got)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(data, []byte(`[["a","b","c"],["d","e","f"]]`)) {
+		t.Error(fmt.Errorf("converted body didn't match, got: %s", data))
+	}
+
+	// JSON -> NDJSON
+	body = cafs.NewMemfileBytes("", []byte(`[["a","b","c"]]`))
+	got, err = ConvertBodyFormat(body, jsonStructure, jsonStructure, NDJSONEncoding)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if got.FileName() != "body.ndjson" {
+		t.Errorf("expected converted filename to be body.ndjson, got: %s", got.FileName())
+	}
+	data, err = ioutil.ReadAll(got)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(data, []byte("[\"a\",\"b\",\"c\"]\n")) {
+		t.Error(fmt.Errorf("converted body didn't match, got: %s", data))
+	}
+
+	// JSON -> Parquet -> CSV
+	body = cafs.NewMemfileBytes("", []byte(`[["a","b","c"]]`))
+	got, err = ConvertBodyFormat(body, jsonStructure, jsonStructure, ParquetEncoding)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if got.FileName() != "body.parquet" {
+		t.Errorf("expected converted filename to be body.parquet, got: %s", got.FileName())
+	}
+	got, err = ConvertBodyFormat(got, jsonStructure, csvStructure, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	data, err = ioutil.ReadAll(got)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(data, []byte("a,b,c\n")) {
+		t.Error(fmt.Errorf("converted body didn't match, got: %s", data))
+	}
+
+	if _, err = ConvertBodyFormat(body, jsonStructure, jsonStructure, "nope"); err == nil {
+		t.Error("expected converting to an unknown encoding to error")
+	}
 }
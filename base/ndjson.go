@@ -0,0 +1,101 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+)
+
+// NDJSONReader implements the dsio.EntryReader interface, reading one JSON
+// value per line. Entries are read as they're needed, so bodies of any size
+// can be streamed. Integers are read as int64s, all other numbers as
+// float64s
+type NDJSONReader struct {
+	st  *dataset.Structure
+	dec *json.Decoder
+	i   int
+}
+
+// NewNDJSONReader creates a newline-delimited JSON entry reader
+func NewNDJSONReader(st *dataset.Structure, r io.Reader) *NDJSONReader {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &NDJSONReader{st: st, dec: dec}
+}
+
+// Structure gives the structure being read
+func (r *NDJSONReader) Structure() *dataset.Structure {
+	return r.st
+}
+
+// ReadEntry reads one line as an entry
+func (r *NDJSONReader) ReadEntry() (dsio.Entry, error) {
+	var v interface{}
+	if err := r.dec.Decode(&v); err != nil {
+		if err == io.EOF {
+			return dsio.Entry{}, err
+		}
+		return dsio.Entry{}, fmt.Errorf("reading ndjson entry %d: %s", r.i, err.Error())
+	}
+	ent := dsio.Entry{Index: r.i, Value: ndjsonValue(v)}
+	r.i++
+	return ent, nil
+}
+
+// ndjsonValue replaces json.Number values with int64 or float64 values
+func ndjsonValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	case []interface{}:
+		for i, val := range x {
+			x[i] = ndjsonValue(val)
+		}
+	case map[string]interface{}:
+		for key, val := range x {
+			x[key] = ndjsonValue(val)
+		}
+	}
+	return v
+}
+
+// NDJSONWriter implements the dsio.EntryWriter interface, writing each entry
+// as a single line of JSON. entries with keys are written as single-key
+// objects
+type NDJSONWriter struct {
+	st  *dataset.Structure
+	enc *json.Encoder
+}
+
+// NewNDJSONWriter creates a newline-delimited JSON entry writer
+func NewNDJSONWriter(st *dataset.Structure, w io.Writer) *NDJSONWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &NDJSONWriter{st: st, enc: enc}
+}
+
+// Structure gives the structure being written
+func (w *NDJSONWriter) Structure() *dataset.Structure {
+	return w.st
+}
+
+// WriteEntry writes one entry followed by a newline
+func (w *NDJSONWriter) WriteEntry(ent dsio.Entry) error {
+	if ent.Key != "" {
+		return w.enc.Encode(map[string]interface{}{ent.Key: ent.Value})
+	}
+	return w.enc.Encode(ent.Value)
+}
+
+// Close finalizes the writer. NDJSON has no closing delimiter, so this is a
+// no-op
+func (w *NDJSONWriter) Close() error {
+	return nil
+}
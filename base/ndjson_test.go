@@ -0,0 +1,73 @@
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+)
+
+func TestNDJSONReader(t *testing.T) {
+	cases := []struct {
+		in, expect, err string
+	}{
+		{"", `[]`, ""},
+		{"{\"a\":1}\n{\"a\":2.5}\n", `[{"a":1},{"a":2.5}]`, ""},
+		{"[\"a\",9007199254740993]\n\n[\"b\",{\"c\":[1,2]}]", `[["a",9007199254740993],["b",{"c":[1,2]}]]`, ""},
+		{"\"a\"\ntrue\nnull\n", `["a",true,null]`, ""},
+		{"{\"a\":1}\n{nope}\n", "", "reading ndjson entry 1: invalid character 'n' looking for beginning of object key string"},
+	}
+
+	for i, c := range cases {
+		r := NewNDJSONReader(&dataset.Structure{Format: dataset.JSONDataFormat}, strings.NewReader(c.in))
+		vals := []interface{}{}
+		var err error
+		for {
+			var ent dsio.Entry
+			if ent, err = r.ReadEntry(); err != nil {
+				break
+			}
+			if ent.Index != len(vals) {
+				t.Errorf("case %d index mismatch. expected: %d, got: %d", i, len(vals), ent.Index)
+			}
+			vals = append(vals, ent.Value)
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if c.err != "" {
+			continue
+		}
+		if got, _ := json.Marshal(vals); string(got) != c.expect {
+			t.Errorf("case %d mismatch.\nexpected: %s\ngot:      %s", i, c.expect, string(got))
+		}
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewNDJSONWriter(&dataset.Structure{Format: dataset.JSONDataFormat}, buf)
+	for _, ent := range []dsio.Entry{
+		{Index: 0, Value: []interface{}{"<a>", 1}},
+		{Key: "b", Value: true},
+	} {
+		if err := w.WriteEntry(ent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expect := "[\"<a>\",1]\n{\"b\":true}\n"
+	if buf.String() != expect {
+		t.Errorf("mismatch. expected: %q, got: %q", expect, buf.String())
+	}
+}
@@ -0,0 +1,1247 @@
+package base
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+// parquet physical types
+const (
+	parquetBoolean           = 0
+	parquetInt32             = 1
+	parquetInt64             = 2
+	parquetInt96             = 3
+	parquetFloat             = 4
+	parquetDouble            = 5
+	parquetByteArray         = 6
+	parquetFixedLenByteArray = 7
+)
+
+// parquet value encodings, compression codecs & page types
+const (
+	parquetPlain          = 0
+	parquetPlainDict      = 2
+	parquetRLE            = 3
+	parquetRLEDict        = 8
+	parquetUncompressed   = 0
+	parquetSnappy         = 1
+	parquetGzip           = 2
+	parquetDataPage       = 0
+	parquetDictionaryPage = 2
+	parquetDataPageV2     = 3
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn describes a column of a flat parquet schema
+type parquetColumn struct {
+	name       string
+	typ        int64
+	typeLength int
+	optional   bool
+}
+
+// ParquetReader implements the dsio.EntryReader interface, reading rows of a
+// parquet file as array entries. Only flat schemas are supported: columns
+// can't be nested or repeated. Pages may be plain or dictionary encoded, and
+// uncompressed, snappy or gzip compressed. The file is decoded when the
+// reader is created
+type ParquetReader struct {
+	st   *dataset.Structure
+	rows [][]interface{}
+	i    int
+}
+
+// NewParquetReader decodes a parquet file. The reader's structure is st with
+// a schema describing the file's columns
+func NewParquetReader(st *dataset.Structure, data []byte) (*ParquetReader, error) {
+	cols, rows, err := readParquet(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading parquet: %s", err.Error())
+	}
+	sch, err := parquetSchema(cols)
+	if err != nil {
+		return nil, err
+	}
+
+	if st == nil {
+		st = &dataset.Structure{Format: dataset.JSONDataFormat}
+	}
+	rst := &dataset.Structure{}
+	rst.Assign(st, &dataset.Structure{Schema: sch})
+	return &ParquetReader{st: rst, rows: rows}, nil
+}
+
+// Structure gives the structure being read
+func (r *ParquetReader) Structure() *dataset.Structure {
+	return r.st
+}
+
+// ReadEntry reads one row as an entry
+func (r *ParquetReader) ReadEntry() (dsio.Entry, error) {
+	if r.i >= len(r.rows) {
+		return dsio.Entry{}, io.EOF
+	}
+	ent := dsio.Entry{Index: r.i, Value: r.rows[r.i]}
+	r.i++
+	return ent, nil
+}
+
+// parquetSchema describes parquet columns as an array-of-arrays schema
+func parquetSchema(cols []parquetColumn) (*jsonschema.RootSchema, error) {
+	items := make([]interface{}, len(cols))
+	for i, col := range cols {
+		t := "string"
+		switch col.typ {
+		case parquetBoolean:
+			t = "boolean"
+		case parquetInt32, parquetInt64:
+			t = "integer"
+		case parquetFloat, parquetDouble:
+			t = "number"
+		}
+		items[i] = map[string]interface{}{"title": col.name, "type": t}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":  "array",
+			"items": items,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	rs := &jsonschema.RootSchema{}
+	if err := rs.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// readParquet decodes the columns & rows of a parquet file
+func readParquet(data []byte) ([]parquetColumn, [][]interface{}, error) {
+	if len(data) < 12 || !bytes.Equal(data[:4], parquetMagic) || !bytes.Equal(data[len(data)-4:], parquetMagic) {
+		return nil, nil, fmt.Errorf("not a parquet file")
+	}
+	metaLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if metaLen > len(data)-12 {
+		return nil, nil, fmt.Errorf("invalid footer length: %d", metaLen)
+	}
+	tr := &thriftReader{data: data[len(data)-8-metaLen : len(data)-8]}
+	meta, err := tr.readStruct()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading file metadata: %s", err.Error())
+	}
+
+	schema := meta.list(2)
+	if len(schema) == 0 {
+		return nil, nil, fmt.Errorf("file has no schema")
+	}
+	root, _ := schema[0].(thriftStruct)
+	if int(root.int(5)) != len(schema)-1 {
+		return nil, nil, fmt.Errorf("nested schemas are not supported")
+	}
+	cols := make([]parquetColumn, len(schema)-1)
+	for i, el := range schema[1:] {
+		s, _ := el.(thriftStruct)
+		if s.int(5) > 0 {
+			return nil, nil, fmt.Errorf("column '%s': nested schemas are not supported", s.str(4))
+		}
+		if s.int(3) == 2 {
+			return nil, nil, fmt.Errorf("column '%s': repeated columns are not supported", s.str(4))
+		}
+		cols[i] = parquetColumn{
+			name:       s.str(4),
+			typ:        s.int(1),
+			typeLength: int(s.int(2)),
+			optional:   s.int(3) == 1,
+		}
+	}
+
+	values := make([][]interface{}, len(cols))
+	for _, rg := range meta.list(4) {
+		rgs, _ := rg.(thriftStruct)
+		chunks := rgs.list(1)
+		if len(chunks) != len(cols) {
+			return nil, nil, fmt.Errorf("row group has %d columns, expected %d", len(chunks), len(cols))
+		}
+		for i, cc := range chunks {
+			ccs, _ := cc.(thriftStruct)
+			cm := ccs.strct(3)
+			if cm == nil {
+				return nil, nil, fmt.Errorf("column '%s': chunks stored in other files are not supported", cols[i].name)
+			}
+			vals, err := readParquetChunk(data, cols[i], cm)
+			if err != nil {
+				return nil, nil, fmt.Errorf("column '%s': %s", cols[i].name, err.Error())
+			}
+			values[i] = append(values[i], vals...)
+		}
+	}
+
+	// check the row count against values that were read before allocating
+	numRows := meta.int(3)
+	if numRows < 0 {
+		return nil, nil, fmt.Errorf("invalid row count: %d", numRows)
+	}
+	for c := range cols {
+		if numRows > int64(len(values[c])) {
+			return nil, nil, fmt.Errorf("column '%s' has %d values, expected %d", cols[c].name, len(values[c]), numRows)
+		}
+	}
+	rows := make([][]interface{}, int(numRows))
+	for r := range rows {
+		rows[r] = make([]interface{}, len(cols))
+		for c := range cols {
+			rows[r][c] = values[c][r]
+		}
+	}
+	return cols, rows, nil
+}
+
+// readParquetChunk decodes the pages of a column chunk described by column
+// metadata cm
+func readParquetChunk(data []byte, col parquetColumn, cm thriftStruct) ([]interface{}, error) {
+	codec := cm.int(4)
+	numValues := cm.int(5)
+	if numValues < 0 {
+		return nil, fmt.Errorf("invalid value count: %d", numValues)
+	}
+	start := cm.int(9)
+	if dictOffset := cm.int(11); dictOffset > 0 && dictOffset < start {
+		start = dictOffset
+	}
+	end := start + cm.int(7)
+	if start < 4 || end > int64(len(data)) || end < start {
+		return nil, fmt.Errorf("invalid chunk offsets")
+	}
+	chunk := data[start:end]
+
+	// encoded values can be smaller than a byte, so the count can't be held
+	// to the chunk size, but up front allocation is
+	capacity := numValues
+	if capacity > int64(len(chunk)) {
+		capacity = int64(len(chunk))
+	}
+	var (
+		dict   []interface{}
+		values = make([]interface{}, 0, int(capacity))
+		pos    = 0
+	)
+	for int64(len(values)) < numValues {
+		if pos >= len(chunk) {
+			return nil, fmt.Errorf("expected %d values, found %d", numValues, len(values))
+		}
+		tr := &thriftReader{data: chunk[pos:]}
+		ph, err := tr.readStruct()
+		if err != nil {
+			return nil, fmt.Errorf("reading page header: %s", err.Error())
+		}
+		pos += tr.pos
+		size := int(ph.int(3))
+		if size < 0 || pos+size > len(chunk) {
+			return nil, fmt.Errorf("invalid page size: %d", size)
+		}
+		page := chunk[pos : pos+size]
+		pos += size
+
+		switch ph.int(1) {
+		case parquetDictionaryPage:
+			dph := ph.strct(7)
+			if page, err = parquetDecompress(codec, page); err != nil {
+				return nil, err
+			}
+			if dict, err = parquetPlainValues(col, page, int(dph.int(1))); err != nil {
+				return nil, fmt.Errorf("reading dictionary: %s", err.Error())
+			}
+		case parquetDataPage:
+			dph := ph.strct(5)
+			if page, err = parquetDecompress(codec, page); err != nil {
+				return nil, err
+			}
+			n, err := parquetPageCount(dph.int(1), numValues-int64(len(values)))
+			if err != nil {
+				return nil, err
+			}
+			var defs []int
+			if col.optional {
+				if len(page) < 4 {
+					return nil, fmt.Errorf("unexpected end of page data")
+				}
+				l := int(binary.LittleEndian.Uint32(page))
+				if l > len(page)-4 {
+					return nil, fmt.Errorf("invalid definition levels length: %d", l)
+				}
+				if defs, err = parquetRLEValues(page[4:4+l], 1, n); err != nil {
+					return nil, err
+				}
+				page = page[4+l:]
+			}
+			vals, err := parquetPageValues(col, dph.int(2), page, n, defs, dict)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, vals...)
+		case parquetDataPageV2:
+			dph := ph.strct(8)
+			n, err := parquetPageCount(dph.int(1), numValues-int64(len(values)))
+			if err != nil {
+				return nil, err
+			}
+			defLen, repLen := int(dph.int(5)), int(dph.int(6))
+			if defLen < 0 || repLen < 0 || defLen+repLen > len(page) {
+				return nil, fmt.Errorf("invalid level lengths")
+			}
+			var defs []int
+			if col.optional {
+				if defs, err = parquetRLEValues(page[repLen:repLen+defLen], 1, n); err != nil {
+					return nil, err
+				}
+			}
+			page = page[repLen+defLen:]
+			// levels are never compressed, values are unless is_compressed is false
+			if compressed, ok := dph[7].(bool); !ok || compressed {
+				if page, err = parquetDecompress(codec, page); err != nil {
+					return nil, err
+				}
+			}
+			vals, err := parquetPageValues(col, dph.int(4), page, n, defs, dict)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, vals...)
+		}
+	}
+	return values, nil
+}
+
+// parquetPageCount checks the value count of a data page against the values
+// left to read in it's chunk
+func parquetPageCount(n, left int64) (int, error) {
+	if n < 0 || n > left {
+		return 0, fmt.Errorf("invalid page value count %d, chunk has %d values left", n, left)
+	}
+	return int(n), nil
+}
+
+// parquetPageValues decodes the values of a data page. defs are the page's
+// definition levels, values with a level of 0 are null
+func parquetPageValues(col parquetColumn, encoding int64, data []byte, n int, defs []int, dict []interface{}) ([]interface{}, error) {
+	count := n
+	if defs != nil {
+		count = 0
+		for _, d := range defs {
+			count += d
+		}
+	}
+
+	var (
+		vals []interface{}
+		err  error
+	)
+	switch encoding {
+	case parquetPlain:
+		vals, err = parquetPlainValues(col, data, count)
+	case parquetPlainDict, parquetRLEDict:
+		if dict == nil {
+			return nil, fmt.Errorf("dictionary encoded page without a dictionary")
+		}
+		if len(data) < 1 {
+			return nil, fmt.Errorf("unexpected end of page data")
+		}
+		var idx []int
+		if idx, err = parquetRLEValues(data[1:], int(data[0]), count); err != nil {
+			return nil, err
+		}
+		vals = make([]interface{}, count)
+		for i, j := range idx {
+			if j >= len(dict) {
+				return nil, fmt.Errorf("dictionary index out of range: %d", j)
+			}
+			vals[i] = dict[j]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %d", encoding)
+	}
+	if err != nil || defs == nil {
+		return vals, err
+	}
+
+	values := make([]interface{}, n)
+	for i, j := 0, 0; i < n; i++ {
+		if defs[i] == 1 {
+			values[i] = vals[j]
+			j++
+		}
+	}
+	return values, nil
+}
+
+// parquetPlainValues decodes count plain-encoded values
+func parquetPlainValues(col parquetColumn, data []byte, count int) ([]interface{}, error) {
+	short := fmt.Errorf("unexpected end of page data")
+	// plain values take at least a bit each
+	if count < 0 || count > len(data)*8 {
+		return nil, short
+	}
+	vals := make([]interface{}, count)
+	pos := 0
+	for i := range vals {
+		switch col.typ {
+		case parquetBoolean:
+			if i/8 >= len(data) {
+				return nil, short
+			}
+			vals[i] = data[i/8]>>uint(i%8)&1 == 1
+		case parquetInt32:
+			if pos+4 > len(data) {
+				return nil, short
+			}
+			vals[i] = int64(int32(binary.LittleEndian.Uint32(data[pos:])))
+			pos += 4
+		case parquetInt64:
+			if pos+8 > len(data) {
+				return nil, short
+			}
+			vals[i] = int64(binary.LittleEndian.Uint64(data[pos:]))
+			pos += 8
+		case parquetInt96:
+			// legacy timestamps: nanoseconds of the day followed by a julian day
+			if pos+12 > len(data) {
+				return nil, short
+			}
+			nanos := int64(binary.LittleEndian.Uint64(data[pos:]))
+			days := int64(binary.LittleEndian.Uint32(data[pos+8:])) - 2440588
+			vals[i] = time.Unix(days*86400, nanos).UTC().Format(time.RFC3339Nano)
+			pos += 12
+		case parquetFloat:
+			if pos+4 > len(data) {
+				return nil, short
+			}
+			vals[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[pos:])))
+			pos += 4
+		case parquetDouble:
+			if pos+8 > len(data) {
+				return nil, short
+			}
+			vals[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[pos:]))
+			pos += 8
+		case parquetByteArray:
+			if pos+4 > len(data) {
+				return nil, short
+			}
+			l := int(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+			if l < 0 || pos+l > len(data) {
+				return nil, short
+			}
+			vals[i] = string(data[pos : pos+l])
+			pos += l
+		case parquetFixedLenByteArray:
+			if pos+col.typeLength > len(data) {
+				return nil, short
+			}
+			vals[i] = string(data[pos : pos+col.typeLength])
+			pos += col.typeLength
+		default:
+			return nil, fmt.Errorf("unsupported type: %d", col.typ)
+		}
+	}
+	return vals, nil
+}
+
+// parquetRLEValues decodes count values from the RLE / bit-packed hybrid
+// encoding parquet uses for levels & dictionary indices
+func parquetRLEValues(data []byte, bitWidth, count int) ([]int, error) {
+	if bitWidth > 32 {
+		return nil, fmt.Errorf("invalid bit width: %d", bitWidth)
+	}
+	byteWidth := (bitWidth + 7) / 8
+	// runs can encode any number of values, allocation is held to the data size
+	capacity := count
+	if capacity > len(data)*8 {
+		capacity = len(data) * 8
+	}
+	vals := make([]int, 0, capacity)
+	for len(vals) < count {
+		h, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("unexpected end of encoded values")
+		}
+		data = data[n:]
+
+		if h&1 == 0 {
+			// rle run: a repeat count followed by a single value
+			if len(data) < byteWidth {
+				return nil, fmt.Errorf("unexpected end of encoded values")
+			}
+			v := 0
+			for i := 0; i < byteWidth; i++ {
+				v |= int(data[i]) << uint(8*i)
+			}
+			data = data[byteWidth:]
+			for i := uint64(0); i < h>>1 && len(vals) < count; i++ {
+				vals = append(vals, v)
+			}
+			continue
+		}
+
+		// bit-packed run: groups of 8 values packed least significant bit first
+		groups := int(h >> 1)
+		size := groups * bitWidth
+		if len(data) < size {
+			return nil, fmt.Errorf("unexpected end of encoded values")
+		}
+		for i := 0; i < groups*8 && len(vals) < count; i++ {
+			v := 0
+			for b := 0; b < bitWidth; b++ {
+				bit := i*bitWidth + b
+				v |= int(data[bit/8]>>uint(bit%8)&1) << uint(b)
+			}
+			vals = append(vals, v)
+		}
+		data = data[size:]
+	}
+	return vals, nil
+}
+
+// parquetDecompress decompresses page data
+func parquetDecompress(codec int64, data []byte) ([]byte, error) {
+	switch codec {
+	case parquetUncompressed:
+		return data, nil
+	case parquetSnappy:
+		return snappyDecode(data)
+	case parquetGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unsupported compression codec: %d", codec)
+	}
+}
+
+// snappyDecode decodes a block of snappy-compressed data
+func snappyDecode(src []byte) ([]byte, error) {
+	corrupt := fmt.Errorf("corrupt snappy data")
+	n, i := binary.Uvarint(src)
+	if i <= 0 {
+		return nil, corrupt
+	}
+	src = src[i:]
+
+	var dst []byte
+	for len(src) > 0 {
+		var length, offset int
+		tag := src[0]
+		switch tag & 3 {
+		case 0:
+			// literal
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				nb := length - 59
+				if len(src) < nb {
+					return nil, corrupt
+				}
+				length = 0
+				for j := 0; j < nb; j++ {
+					length |= int(src[j]) << uint(8*j)
+				}
+				src = src[nb:]
+			}
+			length++
+			if length <= 0 || len(src) < length {
+				return nil, corrupt
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 1:
+			if len(src) < 2 {
+				return nil, corrupt
+			}
+			length = 4 + int(tag>>2&7)
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+		case 2:
+			if len(src) < 3 {
+				return nil, corrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3:
+			if len(src) < 5 {
+				return nil, corrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if offset <= 0 || offset > len(dst) {
+			return nil, corrupt
+		}
+		// copies may overlap the bytes they produce
+		for j := 0; j < length; j++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != n {
+		return nil, corrupt
+	}
+	return dst, nil
+}
+
+// ParquetWriter implements the dsio.EntryWriter interface, writing entries as
+// rows of a parquet file with a single row group. Columns are laid out the
+// same way XLSXWriter lays them out: column titles from the schema for array
+// entries, sorted keys for object entries and a single "value" column for
+// other entries, prefixed with a "key" column when entries are keyed.
+// Column types are inferred from their values. Entries are buffered until
+// the writer is closed
+type ParquetWriter struct {
+	st      *dataset.Structure
+	w       io.Writer
+	entries []dsio.Entry
+}
+
+// NewParquetWriter creates a parquet entry writer
+func NewParquetWriter(st *dataset.Structure, w io.Writer) *ParquetWriter {
+	return &ParquetWriter{st: st, w: w}
+}
+
+// Structure gives the structure being written
+func (w *ParquetWriter) Structure() *dataset.Structure {
+	return w.st
+}
+
+// WriteEntry adds an entry to the file
+func (w *ParquetWriter) WriteEntry(ent dsio.Entry) error {
+	w.entries = append(w.entries, ent)
+	return nil
+}
+
+// Close writes the parquet file
+func (w *ParquetWriter) Close() error {
+	titles, rows, err := w.layout()
+	if err != nil {
+		return err
+	}
+	// schema column types line up with titles after any key column
+	declared := schemaColumnTypes(w.st)
+	if len(w.entries) > 0 && w.entries[0].Key != "" {
+		declared = append([]string{"string"}, declared...)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(parquetMagic)
+
+	meta := &thriftWriter{}
+	meta.begin()
+	meta.i32(1, 1)
+	meta.listHeader(2, thriftStructType, len(titles)+1)
+	meta.begin()
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(titles)))
+	meta.end()
+
+	chunks := &thriftWriter{}
+	size := 0
+	for c, title := range titles {
+		vals := make([]interface{}, len(rows))
+		for r, row := range rows {
+			vals[r] = row[c]
+		}
+		decl := ""
+		if c < len(declared) {
+			decl = declared[c]
+		}
+		typ := parquetColumnType(decl, vals)
+
+		meta.begin()
+		meta.i32(1, int32(typ))
+		meta.i32(3, 1)
+		meta.binary(4, []byte(title))
+		if typ == parquetByteArray {
+			// UTF8
+			meta.i32(6, 0)
+		}
+		meta.end()
+
+		page, err := parquetPage(typ, vals)
+		if err != nil {
+			return fmt.Errorf("column '%s': %s", title, err.Error())
+		}
+		ph := &thriftWriter{}
+		ph.begin()
+		ph.i32(1, parquetDataPage)
+		ph.i32(2, int32(len(page)))
+		ph.i32(3, int32(len(page)))
+		ph.beginStruct(5)
+		ph.i32(1, int32(len(vals)))
+		ph.i32(2, parquetPlain)
+		ph.i32(3, parquetRLE)
+		ph.i32(4, parquetRLE)
+		ph.end()
+		ph.end()
+
+		offset := int64(buf.Len())
+		buf.Write(ph.buf.Bytes())
+		buf.Write(page)
+		chunkSize := int64(ph.buf.Len() + len(page))
+		size += int(chunkSize)
+
+		chunks.begin()
+		chunks.i64(2, offset)
+		chunks.beginStruct(3)
+		chunks.i32(1, int32(typ))
+		chunks.listHeader(2, thriftI32Type, 2)
+		chunks.varint(parquetPlain)
+		chunks.varint(parquetRLE)
+		chunks.listHeader(3, thriftBinaryType, 1)
+		chunks.uvarint(uint64(len(title)))
+		chunks.buf.WriteString(title)
+		chunks.i32(4, parquetUncompressed)
+		chunks.i64(5, int64(len(vals)))
+		chunks.i64(6, chunkSize)
+		chunks.i64(7, chunkSize)
+		chunks.i64(9, offset)
+		chunks.end()
+		chunks.end()
+	}
+
+	meta.i64(3, int64(len(rows)))
+	if len(rows) == 0 {
+		meta.listHeader(4, thriftStructType, 0)
+	} else {
+		meta.listHeader(4, thriftStructType, 1)
+		meta.begin()
+		meta.listHeader(1, thriftStructType, len(titles))
+		meta.buf.Write(chunks.buf.Bytes())
+		meta.i64(2, int64(size))
+		meta.i64(3, int64(len(rows)))
+		meta.end()
+	}
+	meta.binary(6, []byte("qri"))
+	meta.end()
+
+	buf.Write(meta.buf.Bytes())
+	binary.Write(buf, binary.LittleEndian, uint32(meta.buf.Len()))
+	buf.Write(parquetMagic)
+
+	_, err = w.w.Write(buf.Bytes())
+	return err
+}
+
+// layout arranges buffered entries into titled columns
+func (w *ParquetWriter) layout() ([]string, [][]interface{}, error) {
+	var (
+		titles []string
+		fields []string
+		kind   string
+	)
+	if len(w.entries) == 0 {
+		return schemaColumnTitles(w.st, 0), nil, nil
+	}
+
+	switch v := w.entries[0].Value.(type) {
+	case map[string]interface{}:
+		kind = "object"
+		keys := map[string]bool{}
+		for _, ent := range w.entries {
+			if m, ok := ent.Value.(map[string]interface{}); ok {
+				for key := range m {
+					keys[key] = true
+				}
+			}
+		}
+		for key := range keys {
+			fields = append(fields, key)
+		}
+		sort.Strings(fields)
+		titles = fields
+	case []interface{}:
+		kind = "array"
+		width := len(v)
+		for _, ent := range w.entries {
+			if a, ok := ent.Value.([]interface{}); ok && len(a) > width {
+				width = len(a)
+			}
+		}
+		titles = schemaColumnTitles(w.st, width)
+	default:
+		titles = []string{"value"}
+	}
+	keyed := w.entries[0].Key != ""
+	if keyed {
+		titles = append([]string{"key"}, titles...)
+	}
+
+	rows := make([][]interface{}, len(w.entries))
+	for i, ent := range w.entries {
+		var cells []interface{}
+		switch v := ent.Value.(type) {
+		case map[string]interface{}:
+			if kind != "object" {
+				return nil, nil, fmt.Errorf("entry %d: expected all entries to be objects", i)
+			}
+			cells = make([]interface{}, len(fields))
+			for j, f := range fields {
+				cells[j] = v[f]
+			}
+		case []interface{}:
+			if kind != "array" {
+				return nil, nil, fmt.Errorf("entry %d: expected all entries to be arrays", i)
+			}
+			cells = v
+		default:
+			if kind != "" {
+				return nil, nil, fmt.Errorf("entry %d: expected all entries to be %ss", i, kind)
+			}
+			cells = []interface{}{v}
+		}
+		if keyed {
+			cells = append([]interface{}{ent.Key}, cells...)
+		}
+		row := make([]interface{}, len(titles))
+		copy(row, cells)
+		rows[i] = row
+	}
+	return titles, rows, nil
+}
+
+// parquetColumnType picks a physical type for a column of values. columns
+// that mix integers & decimals are doubles, columns that mix other types of
+// value are strings. declared is the column type given by a schema, used
+// when a column has no values & to keep whole-valued decimals integers
+func parquetColumnType(declared string, vals []interface{}) int {
+	typ := -1
+	for _, v := range vals {
+		t := parquetByteArray
+		switch v.(type) {
+		case nil:
+			continue
+		case bool:
+			t = parquetBoolean
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32:
+			t = parquetInt64
+		case float32, float64:
+			t = parquetDouble
+		case json.Number:
+			t = parquetDouble
+			if _, err := v.(json.Number).Int64(); err == nil {
+				t = parquetInt64
+			}
+		}
+		switch {
+		case typ == -1 || typ == t:
+			typ = t
+		case (typ == parquetInt64 || typ == parquetDouble) && (t == parquetInt64 || t == parquetDouble):
+			typ = parquetDouble
+		default:
+			typ = parquetByteArray
+		}
+	}
+
+	switch {
+	case typ == -1 && declared == "boolean":
+		return parquetBoolean
+	case typ == -1 && declared == "integer":
+		return parquetInt64
+	case typ == -1 && declared == "number":
+		return parquetDouble
+	case typ == -1:
+		return parquetByteArray
+	case typ == parquetDouble && declared == "integer":
+		for _, v := range vals {
+			if f, ok := parquetFloat64(v); ok && f != math.Trunc(f) {
+				return parquetDouble
+			}
+		}
+		return parquetInt64
+	}
+	return typ
+}
+
+// parquetPage encodes values as a data page of RLE definition levels
+// followed by plain-encoded non-null values
+func parquetPage(typ int, vals []interface{}) ([]byte, error) {
+	levels := &bytes.Buffer{}
+	for i := 0; i < len(vals); {
+		def := byte(1)
+		if vals[i] == nil {
+			def = 0
+		}
+		run := 1
+		for i+run < len(vals) && (vals[i+run] == nil) == (def == 0) {
+			run++
+		}
+		levels.Write(parquetUvarint(uint64(run) << 1))
+		levels.WriteByte(def)
+		i += run
+	}
+
+	page := &bytes.Buffer{}
+	binary.Write(page, binary.LittleEndian, uint32(levels.Len()))
+	page.Write(levels.Bytes())
+
+	var bits []byte
+	n := 0
+	for _, v := range vals {
+		if v == nil {
+			continue
+		}
+		switch typ {
+		case parquetBoolean:
+			if n%8 == 0 {
+				bits = append(bits, 0)
+			}
+			if v.(bool) {
+				bits[n/8] |= 1 << uint(n%8)
+			}
+		case parquetInt64:
+			binary.Write(page, binary.LittleEndian, parquetInt64Value(v))
+		case parquetDouble:
+			f, _ := parquetFloat64(v)
+			binary.Write(page, binary.LittleEndian, math.Float64bits(f))
+		case parquetByteArray:
+			s, ok := v.(string)
+			if !ok {
+				data, err := json.Marshal(v)
+				if err != nil {
+					return nil, err
+				}
+				s = string(data)
+			}
+			binary.Write(page, binary.LittleEndian, uint32(len(s)))
+			page.WriteString(s)
+		}
+		n++
+	}
+	page.Write(bits)
+	return page.Bytes(), nil
+}
+
+// parquetInt64Value converts a value of an integer column to an int64
+func parquetInt64Value(v interface{}) int64 {
+	switch x := v.(type) {
+	case int:
+		return int64(x)
+	case int64:
+		return x
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+	}
+	f, _ := parquetFloat64(v)
+	return int64(f)
+}
+
+// parquetFloat64 converts a numeric value to a float64
+func parquetFloat64(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func parquetUvarint(v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, v)]
+}
+
+// thrift compact protocol types
+const (
+	thriftTrue       = 1
+	thriftFalse      = 2
+	thriftByteType   = 3
+	thriftI16Type    = 4
+	thriftI32Type    = 5
+	thriftI64Type    = 6
+	thriftDoubleType = 7
+	thriftBinaryType = 8
+	thriftListType   = 9
+	thriftSetType    = 10
+	thriftMapType    = 11
+	thriftStructType = 12
+)
+
+// thriftStruct is a decoded thrift struct, keyed by field id. integers are
+// int64s, binaries are []byte, lists are []interface{} & structs are
+// thriftStructs
+type thriftStruct map[int16]interface{}
+
+func (s thriftStruct) int(id int16) int64 {
+	i, _ := s[id].(int64)
+	return i
+}
+
+func (s thriftStruct) str(id int16) string {
+	b, _ := s[id].([]byte)
+	return string(b)
+}
+
+func (s thriftStruct) list(id int16) []interface{} {
+	l, _ := s[id].([]interface{})
+	return l
+}
+
+func (s thriftStruct) strct(id int16) thriftStruct {
+	st, _ := s[id].(thriftStruct)
+	return st
+}
+
+// thriftReader decodes the thrift compact protocol parquet metadata is
+// written in
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) varint() (int64, error) {
+	v, err := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (r *thriftReader) readStruct() (thriftStruct, error) {
+	s := thriftStruct{}
+	var last int16
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			return s, nil
+		}
+
+		typ := b & 0x0f
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		last = id
+
+		switch typ {
+		case thriftTrue:
+			s[id] = true
+		case thriftFalse:
+			s[id] = false
+		default:
+			if s[id], err = r.readValue(typ); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) (interface{}, error) {
+	switch typ {
+	case thriftByteType:
+		b, err := r.byte()
+		return int64(int8(b)), err
+	case thriftI16Type, thriftI32Type, thriftI64Type:
+		return r.varint()
+	case thriftDoubleType:
+		if r.pos+8 > len(r.data) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos:]))
+		r.pos += 8
+		return f, nil
+	case thriftBinaryType:
+		l, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if l > uint64(len(r.data)-r.pos) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b := r.data[r.pos : r.pos+int(l)]
+		r.pos += int(l)
+		return b, nil
+	case thriftListType, thriftSetType:
+		h, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, elem := uint64(h>>4), h&0x0f
+		if size == 15 {
+			if size, err = r.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		if size > uint64(len(r.data)-r.pos) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		l := make([]interface{}, int(size))
+		for i := range l {
+			if elem == thriftTrue || elem == thriftFalse {
+				b, err := r.byte()
+				if err != nil {
+					return nil, err
+				}
+				l[i] = b == thriftTrue
+				continue
+			}
+			if l[i], err = r.readValue(elem); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	case thriftMapType:
+		// maps aren't used by parquet metadata, they're read & discarded
+		size, err := r.uvarint()
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		kv, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < size; i++ {
+			if _, err := r.readValue(kv >> 4); err != nil {
+				return nil, err
+			}
+			if _, err := r.readValue(kv & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case thriftStructType:
+		return r.readStruct()
+	default:
+		return nil, fmt.Errorf("unknown thrift type: %d", typ)
+	}
+}
+
+// thriftWriter encodes structs in the thrift compact protocol. begin & end
+// bracket each struct, nested structs that are fields are started with
+// beginStruct
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16
+}
+
+func (w *thriftWriter) begin() {
+	w.last = append(w.last, 0)
+}
+
+func (w *thriftWriter) end() {
+	w.buf.WriteByte(0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := w.last[len(w.last)-1]
+	if d := id - last; d > 0 && d <= 15 {
+		w.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	w.last[len(w.last)-1] = id
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	w.buf.Write(parquetUvarint(v))
+}
+
+func (w *thriftWriter) varint(v int64) {
+	w.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32Type)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64Type)
+	w.varint(v)
+}
+
+func (w *thriftWriter) binary(id int16, b []byte) {
+	w.field(id, thriftBinaryType)
+	w.uvarint(uint64(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *thriftWriter) beginStruct(id int16) {
+	w.field(id, thriftStructType)
+	w.begin()
+}
+
+func (w *thriftWriter) listHeader(id int16, elem byte, size int) {
+	w.field(id, thriftListType)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elem)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elem)
+	w.uvarint(uint64(size))
+}
@@ -0,0 +1,304 @@
+package base
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestParquetWriterReader(t *testing.T) {
+	sch := &jsonschema.RootSchema{}
+	if err := sch.UnmarshalJSON([]byte(`{"type":"array","items":{"type":"array","items":[{"title":"city","type":"string"},{"title":"pop","type":"integer"},{"title":"avg_age","type":"number"},{"title":"in_usa","type":"boolean"}]}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		st      *dataset.Structure
+		entries []dsio.Entry
+		rows    string
+		schema  string
+	}{
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch},
+			[]dsio.Entry{
+				{Index: 0, Value: []interface{}{"toronto", float64(40000000), 55.5, false}},
+				{Index: 1, Value: []interface{}{"new york", float64(8500000), nil, true}},
+				{Index: 2, Value: []interface{}{"chicago", int64(300000), 44, true, map[string]interface{}{"a": 1}}},
+			},
+			`[["toronto",40000000,55.5,false,null],["new york",8500000,null,true,null],["chicago",300000,44,true,"{\"a\":1}"]]`,
+			`{"items":{"items":[{"title":"city","type":"string"},{"title":"pop","type":"integer"},{"title":"avg_age","type":"number"},{"title":"in_usa","type":"boolean"},{"title":"field_5","type":"string"}],"type":"array"},"type":"array"}`},
+		{&dataset.Structure{Format: dataset.JSONDataFormat},
+			[]dsio.Entry{
+				{Key: "b", Value: map[string]interface{}{"z": 1, "y": "two"}},
+				{Key: "a", Value: map[string]interface{}{"z": 2.5}},
+			},
+			`[["b","two",1],["a",null,2.5]]`,
+			`{"items":{"items":[{"title":"key","type":"string"},{"title":"y","type":"string"},{"title":"z","type":"number"}],"type":"array"},"type":"array"}`},
+		{&dataset.Structure{Format: dataset.JSONDataFormat},
+			[]dsio.Entry{
+				{Index: 0, Value: "a"},
+				{Index: 1, Value: 2},
+			},
+			`[["a"],["2"]]`,
+			`{"items":{"items":[{"title":"value","type":"string"}],"type":"array"},"type":"array"}`},
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch},
+			nil,
+			`[]`,
+			`{"items":{"items":[{"title":"city","type":"string"},{"title":"pop","type":"integer"},{"title":"avg_age","type":"number"},{"title":"in_usa","type":"boolean"}],"type":"array"},"type":"array"}`},
+	}
+
+	for i, c := range cases {
+		buf := &bytes.Buffer{}
+		w := NewParquetWriter(c.st, buf)
+		for _, ent := range c.entries {
+			if err := w.WriteEntry(ent); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("case %d error closing writer: %s", i, err.Error())
+			continue
+		}
+
+		r, err := NewParquetReader(&dataset.Structure{Format: dataset.JSONDataFormat}, buf.Bytes())
+		if err != nil {
+			t.Errorf("case %d error reading written file: %s", i, err.Error())
+			continue
+		}
+		rows := []interface{}{}
+		for {
+			ent, err := r.ReadEntry()
+			if err != nil {
+				break
+			}
+			rows = append(rows, ent.Value)
+		}
+		if got, _ := json.Marshal(rows); string(got) != c.rows {
+			t.Errorf("case %d rows mismatch.\nexpected: %s\ngot:      %s", i, c.rows, string(got))
+		}
+
+		data, _ := r.Structure().Schema.MarshalJSON()
+		var got, expect interface{}
+		json.Unmarshal(data, &got)
+		json.Unmarshal([]byte(c.schema), &expect)
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("case %d schema mismatch.\nexpected: %s\ngot:      %s", i, c.schema, string(data))
+		}
+	}
+
+	w := NewParquetWriter(&dataset.Structure{Format: dataset.JSONDataFormat}, &bytes.Buffer{})
+	w.WriteEntry(dsio.Entry{Value: []interface{}{"a"}})
+	w.WriteEntry(dsio.Entry{Value: map[string]interface{}{"a": "b"}})
+	if err := w.Close(); err == nil {
+		t.Error("expected writing mixed entries to error")
+	}
+}
+
+// TestParquetDictionaryPages reads a file laid out the way most parquet
+// writers lay them out: a snappy-compressed dictionary page followed by a
+// v2 data page of dictionary indices
+func TestParquetDictionaryPages(t *testing.T) {
+	// snappy block of plain-encoded strings: "toronto", "new york"
+	dict := &bytes.Buffer{}
+	for _, s := range []string{"toronto", "new york"} {
+		binary.Write(dict, binary.LittleEndian, uint32(len(s)))
+		dict.WriteString(s)
+	}
+	snappyDict := append([]byte{byte(dict.Len()), byte(dict.Len()-1) << 2}, dict.Bytes()...)
+
+	// definition levels for [set, null, set, set], as a bit-packed run
+	defs := []byte{3, 0x0d}
+	// indices [1, 0, 1] at a bit width of 1, as an rle run of 1 & a
+	// bit-packed run of [0, 1]
+	indices := []byte{1, 2, 1, 3, 0x02}
+
+	buf := &bytes.Buffer{}
+	buf.Write(parquetMagic)
+	offset := int64(buf.Len())
+
+	dph := &thriftWriter{}
+	dph.begin()
+	dph.i32(1, parquetDictionaryPage)
+	dph.i32(2, int32(dict.Len()))
+	dph.i32(3, int32(len(snappyDict)))
+	dph.beginStruct(7)
+	dph.i32(1, 2)
+	dph.i32(2, parquetPlainDict)
+	dph.end()
+	dph.end()
+	buf.Write(dph.buf.Bytes())
+	buf.Write(snappyDict)
+
+	dataOffset := int64(buf.Len())
+	// levels of v2 pages are never compressed, values are marked uncompressed
+	page := append(append([]byte{}, defs...), indices...)
+	ph := &thriftWriter{}
+	ph.begin()
+	ph.i32(1, parquetDataPageV2)
+	ph.i32(2, int32(len(page)))
+	ph.i32(3, int32(len(page)))
+	ph.beginStruct(8)
+	ph.i32(1, 4)
+	ph.i32(2, 1)
+	ph.i32(3, 4)
+	ph.i32(4, parquetRLEDict)
+	ph.i32(5, int32(len(defs)))
+	ph.i32(6, 0)
+	ph.field(7, thriftFalse)
+	ph.end()
+	ph.end()
+	buf.Write(ph.buf.Bytes())
+	buf.Write(page)
+	size := int64(buf.Len()) - offset
+
+	meta := &thriftWriter{}
+	meta.begin()
+	meta.i32(1, 1)
+	meta.listHeader(2, thriftStructType, 2)
+	meta.begin()
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, 1)
+	meta.end()
+	meta.begin()
+	meta.i32(1, parquetByteArray)
+	meta.i32(3, 1)
+	meta.binary(4, []byte("city"))
+	meta.end()
+	meta.i64(3, 4)
+	meta.listHeader(4, thriftStructType, 1)
+	meta.begin()
+	meta.listHeader(1, thriftStructType, 1)
+	meta.begin()
+	meta.i64(2, offset)
+	meta.beginStruct(3)
+	meta.i32(1, parquetByteArray)
+	meta.listHeader(2, thriftI32Type, 1)
+	meta.varint(parquetRLEDict)
+	meta.listHeader(3, thriftBinaryType, 1)
+	meta.uvarint(4)
+	meta.buf.WriteString("city")
+	meta.i32(4, parquetSnappy)
+	meta.i64(5, 4)
+	meta.i64(6, size)
+	meta.i64(7, size)
+	meta.i64(9, dataOffset)
+	meta.i64(11, offset)
+	meta.end()
+	meta.end()
+	meta.i64(2, size)
+	meta.i64(3, 4)
+	meta.end()
+	meta.end()
+
+	buf.Write(meta.buf.Bytes())
+	binary.Write(buf, binary.LittleEndian, uint32(meta.buf.Len()))
+	buf.Write(parquetMagic)
+
+	cols, rows, err := readParquet(buf.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(cols) != 1 || cols[0].name != "city" || !cols[0].optional {
+		t.Errorf("column mismatch. got: %#v", cols)
+	}
+	expect := `[["new york"],[null],["toronto"],["new york"]]`
+	if got, _ := json.Marshal(rows); string(got) != expect {
+		t.Errorf("rows mismatch.\nexpected: %s\ngot:      %s", expect, string(got))
+	}
+}
+
+func TestReadParquetErrors(t *testing.T) {
+	cases := []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte("not parquet"), "not a parquet file"},
+		{[]byte("PAR1\xff\xff\x00\x00PAR1"), "invalid footer length: 65535"},
+		{[]byte("PAR1\x15\x01\x00\x00\x00PAR1"), "reading file metadata: unexpected EOF"},
+		{[]byte("PAR1\x00\x01\x00\x00\x00PAR1"), "file has no schema"},
+	}
+	for i, c := range cases {
+		if _, _, err := readParquet(c.data); err == nil || err.Error() != c.err {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+		}
+	}
+}
+
+func TestSnappyDecode(t *testing.T) {
+	cases := []struct {
+		in     []byte
+		expect string
+		err    bool
+	}{
+		// a literal followed by an overlapping copy
+		{[]byte{12, 2 << 2, 'a', 'b', 'c', 5<<2 | 1, 3}, "abcabcabcabc", false},
+		// two-byte offset copy
+		{[]byte{6, 2 << 2, 'x', 'y', 'z', 2<<2 | 2, 3, 0}, "xyzxyz", false},
+		{[]byte{}, "", true},
+		{[]byte{4, 1<<2 | 1, 1}, "", true},
+		{[]byte{9, 2 << 2, 'a', 'b', 'c'}, "", true},
+	}
+	for i, c := range cases {
+		got, err := snappyDecode(c.in)
+		if (err != nil) != c.err {
+			t.Errorf("case %d error mismatch. expected error: %t, got: %v", i, c.err, err)
+			continue
+		}
+		if string(got) != c.expect {
+			t.Errorf("case %d mismatch. expected: %q, got: %q", i, c.expect, string(got))
+		}
+	}
+}
+
+func TestParquetRLEValues(t *testing.T) {
+	cases := []struct {
+		data     []byte
+		bitWidth int
+		count    int
+		expect   []int
+	}{
+		// rle run of four 5s
+		{[]byte{4 << 1, 5}, 3, 4, []int{5, 5, 5, 5}},
+		// bit-packed run of 0..7 at a bit width of 3
+		{[]byte{1<<1 | 1, 0x88, 0xc6, 0xfa}, 3, 8, []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		// bit-packed values beyond count are padding
+		{[]byte{1<<1 | 1, 0x05}, 1, 3, []int{1, 0, 1}},
+		// two-byte rle values
+		{[]byte{2 << 1, 0x01, 0x02}, 10, 2, []int{513, 513}},
+	}
+	for i, c := range cases {
+		got, err := parquetRLEValues(c.data, c.bitWidth, c.count)
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("case %d mismatch. expected: %v, got: %v", i, c.expect, got)
+		}
+	}
+
+	if _, err := parquetRLEValues([]byte{4 << 1}, 8, 4); err == nil {
+		t.Error("expected truncated values to error")
+	}
+}
+
+func TestParquetValueCounts(t *testing.T) {
+	if _, err := parquetPageCount(-1, 10); err == nil {
+		t.Error("expected negative page value count to error")
+	}
+	if _, err := parquetPageCount(11, 10); err == nil {
+		t.Error("expected page value count past the chunk's values to error")
+	}
+	if n, err := parquetPageCount(10, 10); err != nil || n != 10 {
+		t.Errorf("expected page value count of 10, got: %d (%v)", n, err)
+	}
+	// a single byte can't hold more than 8 plain values
+	if _, err := parquetPlainValues(parquetColumn{typ: parquetBoolean}, []byte{0xff}, 1<<40); err == nil {
+		t.Error("expected plain value count past the data size to error")
+	}
+}
@@ -0,0 +1,221 @@
+package base
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+)
+
+// QueryBody runs a sqlite query against a body, which is loaded into an
+// in-memory table named "body". params are bound to the named parameters the
+// query uses, like :page. Results are an array-of-arrays body, as with sql
+// sources. body can be nil, leaving no body table. queries must be a single
+// SELECT, WITH or VALUES statement, so they can't attach other databases
+func QueryBody(st *dataset.Structure, body cafs.File, query string, params map[string]interface{}) (*SourceBody, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	// every connection to an in-memory database is a different database
+	db.SetMaxOpenConns(1)
+
+	if body != nil {
+		if err := loadBodyTable(db, st, body); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var args []interface{}
+	for _, name := range names {
+		// sqlite refuses parameters a query doesn't use
+		if regexp.MustCompile(`[:@$]` + regexp.QuoteMeta(name) + `\b`).MatchString(query) {
+			args = append(args, sql.Named(name, queryValue(params[name])))
+		}
+	}
+
+	rows, sch, err := querySource(db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return newSourceBody(rows, sch)
+}
+
+// loadBodyTable creates a table named "body" with a row for each entry of a
+// body. array entries have a column for each item, titled by the schema's
+// columns. object entries have a column for each key. other entries are a
+// single "value" column. entries of object bodies are keyed by a "key" column
+func loadBodyTable(db *sql.DB, st *dataset.Structure, body cafs.File) error {
+	rr, err := NewBodyReader(st, BodyFileEncoding(body.FileName()), body)
+	if err != nil {
+		return fmt.Errorf("error allocating data reader: %s", err)
+	}
+	var (
+		keys    []string
+		entries []interface{}
+	)
+	for {
+		ent, err := rr.ReadEntry()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("error reading body: %s", err.Error())
+		}
+		if ent.Key != "" {
+			keys = append(keys, ent.Key)
+		}
+		entries = append(entries, ent.Value)
+	}
+
+	cols := bodyTableColumns(st, entries)
+	if keys != nil {
+		cols = append([]schemaColumn{{title: "key", types: []string{"string"}}}, cols...)
+	}
+	defs := make([]string, len(cols))
+	marks := make([]string, len(cols))
+	for i, col := range cols {
+		defs[i] = fmt.Sprintf(`"%s" %s`, strings.Replace(col.title, `"`, `""`, -1), sqliteColumnType(col.types))
+		marks[i] = "?"
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE body (%s)", strings.Join(defs, ", "))); err != nil {
+		return fmt.Errorf("error creating body table: %s", err.Error())
+	}
+	insert, err := tx.Prepare(fmt.Sprintf("INSERT INTO body VALUES (%s)", strings.Join(marks, ", ")))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for i, entry := range entries {
+		row := make([]interface{}, 0, len(cols))
+		if keys != nil {
+			row = append(row, keys[i])
+		}
+		switch e := entry.(type) {
+		case []interface{}:
+			for j := 0; len(row) < len(cols); j++ {
+				var v interface{}
+				if j < len(e) {
+					v = e[j]
+				}
+				row = append(row, queryValue(v))
+			}
+		case map[string]interface{}:
+			for _, col := range cols[len(row):] {
+				row = append(row, queryValue(e[col.title]))
+			}
+		default:
+			row = append(row, queryValue(e))
+		}
+		if _, err := insert.Exec(row...); err != nil {
+			return fmt.Errorf("error loading body entry %d: %s", i, err.Error())
+		}
+	}
+	return tx.Commit()
+}
+
+// bodyTableColumns gives the columns of a table holding body entries. array
+// entries use the columns of the schema, with extra items titled by position.
+// object entries use every key of every entry, typed by the schema
+func bodyTableColumns(st *dataset.Structure, entries []interface{}) []schemaColumn {
+	schemaCols := schemaTableColumns(st)
+	if len(entries) == 0 {
+		if len(schemaCols) == 0 {
+			return []schemaColumn{{title: "value"}}
+		}
+		return schemaCols
+	}
+
+	switch entries[0].(type) {
+	case []interface{}:
+		cols := schemaCols
+		for _, entry := range entries {
+			items, _ := entry.([]interface{})
+			for len(cols) < len(items) {
+				cols = append(cols, schemaColumn{title: fmt.Sprintf("field_%d", len(cols)+1)})
+			}
+		}
+		return cols
+	case map[string]interface{}:
+		types := map[string][]string{}
+		for _, col := range schemaCols {
+			types[col.title] = col.types
+		}
+		seen := map[string]bool{}
+		var cols []schemaColumn
+		for _, entry := range entries {
+			obj, _ := entry.(map[string]interface{})
+			for key := range obj {
+				if !seen[key] {
+					seen[key] = true
+					cols = append(cols, schemaColumn{title: key, types: types[key]})
+				}
+			}
+		}
+		sort.Slice(cols, func(i, j int) bool { return cols[i].title < cols[j].title })
+		return cols
+	}
+	return []schemaColumn{{title: "value"}}
+}
+
+// sqliteColumnType gives the sqlite type of a column from it's schema types.
+// columns without a single type are left untyped, storing values as-is
+func sqliteColumnType(types []string) string {
+	var typ string
+	for _, t := range types {
+		if t == "null" {
+			continue
+		}
+		if typ != "" {
+			return ""
+		}
+		typ = t
+	}
+	switch typ {
+	case "integer":
+		return "INTEGER"
+	case "number":
+		return "REAL"
+	case "boolean":
+		return "BOOLEAN"
+	case "string":
+		return "TEXT"
+	}
+	return ""
+}
+
+// queryValue converts a JSON value to a sqlite value. arrays & objects are
+// stored as JSON text, and whole numbers as integers
+func queryValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case float64:
+		if x == float64(int64(x)) {
+			return int64(x)
+		}
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(x)
+		if err != nil {
+			return nil
+		}
+		return string(data)
+	}
+	return v
+}
@@ -0,0 +1,80 @@
+package base
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestQueryBody(t *testing.T) {
+	sch := &jsonschema.RootSchema{}
+	if err := sch.UnmarshalJSON([]byte(`{"type":"array","items":{"type":"array","items":[{"title":"city","type":"string"},{"title":"pop","type":"integer"},{"title":"in_usa","type":"boolean"}]}}`)); err != nil {
+		t.Fatal(err)
+	}
+	st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch}
+	body := []byte(`[["toronto",40000000,false],["new york",8500000,true],["chicago",300000,true]]`)
+
+	cases := []struct {
+		query  string
+		params map[string]interface{}
+		expect string
+		err    string
+	}{
+		{`SELECT city FROM body WHERE in_usa ORDER BY pop`, nil, `[["chicago"],["new york"]]`, ""},
+		{`SELECT city, pop FROM body WHERE pop > :min LIMIT :n`, map[string]interface{}{"min": 1000000.0, "n": 1.0, "unused": "x"}, `[["toronto",40000000]]`, ""},
+		{`SELECT count(*) AS cities, in_usa FROM body GROUP BY in_usa ORDER BY in_usa`, nil, `[[1,false],[2,true]]`, ""},
+		{`SELECT nope FROM body`, nil, "", "querying source: no such column: nope"},
+		{`ATTACH DATABASE '/etc/passwd.db' AS other`, nil, "", "queries can only read with SELECT, WITH or VALUES, got: ATTACH"},
+		{`SELECT city FROM body; PRAGMA table_info(body)`, nil, "", "queries must be a single statement, got 2"},
+	}
+
+	for i, c := range cases {
+		res, err := QueryBody(st, cafs.NewMemfileBytes("body.json", body), c.query, c.params)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadAll(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != c.expect {
+			t.Errorf("case %d body mismatch. expected: %s, got: %s", i, c.expect, string(data))
+		}
+	}
+}
+
+func TestQueryBodyObjects(t *testing.T) {
+	st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray}
+	body := []byte(`[{"name":"a","tags":["x"]},{"name":"b","size":2}]`)
+
+	res, err := QueryBody(st, cafs.NewMemfileBytes("body.json", body), `SELECT name, size, tags FROM body ORDER BY name`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	expect := []interface{}{[]interface{}{"a", nil, `["x"]`}, []interface{}{"b", 2.0, nil}}
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("body mismatch. expected: %v, got: %v", expect, got)
+	}
+
+	// queries can run without a body
+	if _, err := QueryBody(nil, nil, `SELECT 1 AS one`, nil); err != nil {
+		t.Errorf("expected a query without a body to succeed, got: %s", err)
+	}
+}
@@ -16,6 +16,19 @@ func InLocalNamespace(r repo.Repo, ref *repo.DatasetRef) bool {
 	return p.ID == ref.ProfileID
 }
 
+// CheckPublishable returns an error if a dataset version fails any of it's
+// quality rules. every path that publishes a dataset must check it first
+func CheckPublishable(r repo.Repo, ref repo.DatasetRef) error {
+	results, err := CheckDatasetRules(r, ref, nil)
+	if err != nil {
+		return err
+	}
+	if err := RulesError(results); err != nil {
+		return fmt.Errorf("can't publish %s, %s", ref.AliasString(), err.Error())
+	}
+	return nil
+}
+
 // SetPublishStatus updates the Published field of a dataset ref
 func SetPublishStatus(r repo.Repo, ref *repo.DatasetRef, published bool) error {
 	if err := repo.CanonicalizeDatasetRef(r, ref); err != nil {
@@ -26,6 +39,12 @@ func SetPublishStatus(r repo.Repo, ref *repo.DatasetRef, published bool) error {
 		return fmt.Errorf("can't publish datasets that are not in your namespace")
 	}
 
+	if published {
+		if err := CheckPublishable(r, *ref); err != nil {
+			return err
+		}
+	}
+
 	ref.Published = published
 	return r.PutRef(*ref)
 }
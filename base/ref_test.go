@@ -68,3 +68,29 @@ func TestSetPublishStatus(t *testing.T) {
 		t.Error("expected setting the publish status of a name outside peer's namespace to fail")
 	}
 }
+
+func TestSetPublishStatusRules(t *testing.T) {
+	r := newTestRepo(t)
+	ref := addCitiesDataset(t, r)
+
+	max := float64(3)
+	if err := PutDatasetRules(r, ref.Path, []*Rule{{Type: RuleRowCount, Max: &max}}); err != nil {
+		t.Fatal(err)
+	}
+	expect := "can't publish peer/cities, 1 of 1 quality rules failed:\n  rowCount: 5 entries is more than the maximum of 3"
+	if err := SetPublishStatus(r, &ref, true); err == nil || err.Error() != expect {
+		t.Errorf("error mismatch. expected: %s, got: %v", expect, err)
+	}
+	// failing rules don't prevent unpublishing
+	if err := SetPublishStatus(r, &ref, false); err != nil {
+		t.Error(err)
+	}
+
+	max = 5
+	if err := PutDatasetRules(r, ref.Path, []*Rule{{Type: RuleRowCount, Max: &max}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPublishStatus(r, &ref, true); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,646 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsfs"
+	"github.com/qri-io/qri/repo"
+)
+
+const (
+	// RuleUnique requires the values of a rule's columns to be unique across
+	// entries. entries with a null key column are skipped
+	RuleUnique = "unique"
+	// RuleReference requires the values of a rule's columns to exist in the
+	// reference columns of another dataset's body. entries with a null key
+	// column are skipped
+	RuleReference = "reference"
+	// RuleRowCount bounds the number of entries, either absolutely with min &
+	// max or relative to the previous version with maxDecrease & maxIncrease
+	RuleRowCount = "rowCount"
+	// RulePattern requires the values of a rule's columns to match a regular
+	// expression. numbers & booleans are matched as JSON
+	RulePattern = "pattern"
+	// RuleRange requires the values of a rule's columns to be numbers between
+	// min & max
+	RuleRange = "range"
+	// RuleFreshness requires the newest timestamp in a column, or the commit
+	// timestamp if no column is given, to be no older than maxAge
+	RuleFreshness = "freshness"
+	// RuleSchema requires schema changes from the previous version to keep the
+	// compatibility of a policy. saves enforce schema rules whether or not
+	// they're strict
+	RuleSchema = "schema"
+)
+
+// RulesComponent is the name rules are stored under by repos that implement
+// repo.ComponentStore
+const RulesComponent = "rules"
+
+// Rule is a declarative data quality check stored with a dataset version.
+// Which fields apply depends on the rule's Type
+type Rule struct {
+	// Name identifies the rule in results, defaults to the rule's type &
+	// columns
+	Name string `json:"name,omitempty"`
+	// Type is one of the Rule constants
+	Type string `json:"type"`
+	// Columns are the column titles the rule checks
+	Columns []string `json:"columns,omitempty"`
+	// Dataset is a reference to the dataset checked by reference rules
+	Dataset string `json:"dataset,omitempty"`
+	// RefColumns are the columns of Dataset that reference rules check
+	// against, defaults to Columns
+	RefColumns []string `json:"refColumns,omitempty"`
+	// Pattern is the regular expression of pattern rules
+	Pattern string `json:"pattern,omitempty"`
+	// Min & Max are inclusive bounds of range & rowCount rules
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// MaxDecrease & MaxIncrease bound the change in entries of rowCount rules
+	// as a fraction of the previous version's entries. 0.1 allows a 10% change
+	MaxDecrease *float64 `json:"maxDecrease,omitempty"`
+	MaxIncrease *float64 `json:"maxIncrease,omitempty"`
+	// MaxAge is the oldest freshness rules allow, as a duration string like
+	// "36h"
+	MaxAge string `json:"maxAge,omitempty"`
+	// Policy is the compatibility of schema rules, one of the SchemaPolicy
+	// constants
+	Policy string `json:"policy,omitempty"`
+}
+
+// Title names a rule, defaulting to the rule's type & columns
+func (r *Rule) Title() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	if len(r.Columns) == 0 {
+		return r.Type
+	}
+	return fmt.Sprintf("%s(%s)", r.Type, strings.Join(r.Columns, ","))
+}
+
+// Valid checks a rule has the fields it's type requires
+func (r *Rule) Valid() error {
+	switch r.Type {
+	case RuleUnique, RuleRange, RulePattern, RuleReference:
+		if len(r.Columns) == 0 {
+			return fmt.Errorf("%s rules require columns", r.Type)
+		}
+	case RuleRowCount, RuleFreshness, RuleSchema:
+	case "":
+		return fmt.Errorf("type is required")
+	default:
+		return fmt.Errorf("unknown rule type '%s'", r.Type)
+	}
+
+	switch r.Type {
+	case RuleReference:
+		if r.Dataset == "" {
+			return fmt.Errorf("reference rules require a dataset")
+		}
+		if r.RefColumns != nil && len(r.RefColumns) != len(r.Columns) {
+			return fmt.Errorf("reference rules require the same number of columns & refColumns")
+		}
+	case RulePattern:
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %s", err.Error())
+		}
+	case RuleRange:
+		if r.Min == nil && r.Max == nil {
+			return fmt.Errorf("range rules require a min or max")
+		}
+	case RuleRowCount:
+		if r.Min == nil && r.Max == nil && r.MaxDecrease == nil && r.MaxIncrease == nil {
+			return fmt.Errorf("rowCount rules require at least one of min, max, maxDecrease or maxIncrease")
+		}
+	case RuleFreshness:
+		if len(r.Columns) > 1 {
+			return fmt.Errorf("freshness rules check at most one column")
+		}
+		if _, err := time.ParseDuration(r.MaxAge); err != nil {
+			return fmt.Errorf("invalid maxAge: %s", err.Error())
+		}
+	case RuleSchema:
+		if _, ok := schemaPolicyBreaks[r.Policy]; !ok {
+			return fmt.Errorf("schema rules require a policy of backward, forward or none")
+		}
+	}
+	return nil
+}
+
+// RuleResult is the outcome of checking a dataset version against a rule
+type RuleResult struct {
+	Rule   string `json:"rule"`
+	Type   string `json:"type"`
+	Passed bool   `json:"passed"`
+	// Failures counts the entries that broke the rule
+	Failures int `json:"failures,omitempty"`
+	// Message describes why a rule failed
+	Message string `json:"message,omitempty"`
+}
+
+// ParseRules decodes a JSON or YAML list of rules, checking each is valid
+func ParseRules(data []byte) ([]*Rule, error) {
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rules: %s", err.Error())
+	}
+	rules := []*Rule{}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("error reading rules: %s", err.Error())
+	}
+	for i, rule := range rules {
+		if err := rule.Valid(); err != nil {
+			return nil, fmt.Errorf("rule %d: %s", i, err.Error())
+		}
+	}
+	return rules, nil
+}
+
+// DatasetRules gets the rules stored with a dataset version. versions
+// without rules and repos that don't implement repo.ComponentStore have no
+// rules
+func DatasetRules(r repo.Repo, path string) ([]*Rule, error) {
+	cs, ok := r.(repo.ComponentStore)
+	if !ok || path == "" {
+		return nil, nil
+	}
+	data, err := cs.Component(path, RulesComponent)
+	if err == repo.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	rules := []*Rule{}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("error decoding rules: %s", err.Error())
+	}
+	return rules, nil
+}
+
+// PutDatasetRules stores rules with a dataset version if r is a
+// repo.ComponentStore
+func PutDatasetRules(r repo.Repo, path string, rules []*Rule) error {
+	cs, ok := r.(repo.ComponentStore)
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	return cs.PutComponent(path, RulesComponent, data)
+}
+
+// CheckRules checks a dataset version against rules in a single pass over
+// it's body. prev is the previous version & may be nil. datasets of reference
+// rules are read from r
+func CheckRules(r repo.Repo, rules []*Rule, ds, prev *dataset.Dataset, body cafs.File) ([]*RuleResult, error) {
+	checkers := make([]ruleChecker, len(rules))
+	for i, rule := range rules {
+		c, err := newRuleChecker(r, rule, ds, prev)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %s", rule.Title(), err.Error())
+		}
+		checkers[i] = c
+	}
+
+	entries, err := eachRow(ds.Structure, body, func(i int, row map[string]interface{}) {
+		for _, c := range checkers {
+			c.check(i, row)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*RuleResult, len(checkers))
+	for i, c := range checkers {
+		results[i] = c.result(entries)
+	}
+	return results, nil
+}
+
+// CheckDatasetRules checks a stored dataset version against rules, checking
+// the rules stored with the version if rules is nil
+func CheckDatasetRules(r repo.Repo, ref repo.DatasetRef, rules []*Rule) ([]*RuleResult, error) {
+	if rules == nil {
+		var err error
+		if rules, err = DatasetRules(r, ref.Path); err != nil {
+			return nil, err
+		}
+	}
+	if len(rules) == 0 {
+		return []*RuleResult{}, nil
+	}
+
+	if ref.Dataset == nil {
+		if err := ReadDataset(r, &ref); err != nil {
+			return nil, err
+		}
+	}
+	ds := &dataset.Dataset{}
+	if err := ds.Decode(ref.Dataset); err != nil {
+		return nil, err
+	}
+	// the previous version may not be stored locally, rules that compare
+	// versions pass without it
+	var prev *dataset.Dataset
+	if ds.PreviousPath != "" {
+		prev, _ = dsfs.LoadDataset(r.Store(), ds.PreviousPath)
+	}
+
+	body, err := dsfs.LoadBody(r.Store(), ds)
+	if err != nil {
+		return nil, fmt.Errorf("error loading body: %s", err.Error())
+	}
+	defer body.Close()
+	return CheckRules(r, rules, ds, prev, body)
+}
+
+// RulesError returns an error listing failed rules, or nil if all rules
+// passed
+func RulesError(results []*RuleResult) error {
+	var failed []string
+	for _, res := range results {
+		if !res.Passed {
+			failed = append(failed, fmt.Sprintf("  %s: %s", res.Rule, res.Message))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d quality rules failed:\n%s", len(failed), len(results), strings.Join(failed, "\n"))
+}
+
+// eachRow calls fn with each entry of a body mapped to column titles, the
+// same way stats profiles columns. eachRow returns the number of entries read
+func eachRow(st *dataset.Structure, body cafs.File, fn func(i int, row map[string]interface{})) (int, error) {
+	rr, err := NewBodyReader(st, BodyFileEncoding(body.FileName()), body)
+	if err != nil {
+		return 0, fmt.Errorf("error allocating data reader: %s", err)
+	}
+
+	titles := schemaColumnTitles(st, 0)
+	i := 0
+	for ; ; i++ {
+		ent, err := rr.ReadEntry()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return i, fmt.Errorf("error reading entry %d: %s", i, err)
+		}
+
+		row := map[string]interface{}{}
+		switch v := ent.Value.(type) {
+		case []interface{}:
+			for j, val := range v {
+				title := fmt.Sprintf("field_%d", j+1)
+				if j < len(titles) {
+					title = titles[j]
+				}
+				row[title] = val
+			}
+		case map[string]interface{}:
+			row = v
+		default:
+			row["value"] = v
+		}
+		fn(i, row)
+	}
+	return i, nil
+}
+
+// ruleChecker accumulates the result of a rule for a stream of entries
+type ruleChecker interface {
+	check(i int, row map[string]interface{})
+	result(entries int) *RuleResult
+}
+
+func newRuleChecker(r repo.Repo, rule *Rule, ds, prev *dataset.Dataset) (ruleChecker, error) {
+	if err := rule.Valid(); err != nil {
+		return nil, err
+	}
+
+	switch rule.Type {
+	case RuleUnique:
+		return &uniqueChecker{ruleFailures: ruleFailures{rule: rule}, seen: map[string]bool{}}, nil
+	case RuleReference:
+		return newReferenceChecker(r, rule)
+	case RuleRowCount:
+		c := &rowCountChecker{rule: rule}
+		if prev != nil && prev.Structure != nil {
+			c.prev = prev.Structure.Entries
+		}
+		return c, nil
+	case RulePattern:
+		return &patternChecker{ruleFailures: ruleFailures{rule: rule}, re: regexp.MustCompile(rule.Pattern)}, nil
+	case RuleRange:
+		return &rangeChecker{ruleFailures: ruleFailures{rule: rule}}, nil
+	case RuleFreshness:
+		maxAge, _ := time.ParseDuration(rule.MaxAge)
+		c := &freshnessChecker{rule: rule, maxAge: maxAge}
+		if len(rule.Columns) == 0 {
+			// versions that haven't been committed yet are as fresh as now
+			c.newest = dsfs.Timestamp()
+			if ds.Commit != nil && !ds.Commit.Timestamp.IsZero() {
+				c.newest = ds.Commit.Timestamp
+			}
+		}
+		return c, nil
+	case RuleSchema:
+		c := &schemaChecker{rule: rule}
+		if prev != nil {
+			c.violations = SchemaViolations(rule.Policy, SchemaChanges(prev.Structure, ds.Structure))
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown rule type '%s'", rule.Type)
+}
+
+// ruleFailures counts entries that break a rule, keeping the first as an
+// example
+type ruleFailures struct {
+	rule    *Rule
+	count   int
+	example string
+}
+
+func (f *ruleFailures) add(i int, format string, args ...interface{}) {
+	if f.count == 0 {
+		f.example = fmt.Sprintf("at entry %d: ", i) + fmt.Sprintf(format, args...)
+	}
+	f.count++
+}
+
+// result describes failures as a count of what failed & the first example
+func (f *ruleFailures) result(what string) *RuleResult {
+	res := &RuleResult{Rule: f.rule.Title(), Type: f.rule.Type, Passed: f.count == 0, Failures: f.count}
+	if f.count > 0 {
+		res.Message = fmt.Sprintf("%d %s, first %s", f.count, what, f.example)
+	}
+	return res
+}
+
+// rowKey joins the values of columns of a row into a comparable key. rows
+// with a null column have no key
+func rowKey(row map[string]interface{}, columns []string) (string, bool) {
+	parts := make([]string, len(columns))
+	for i, col := range columns {
+		v := row[col]
+		if v == nil {
+			return "", false
+		}
+		parts[i] = statsValueKey(v)
+	}
+	return strings.Join(parts, "\x1f"), true
+}
+
+// rowValues formats the values of columns of a row for failure messages
+func rowValues(row map[string]interface{}, columns []string) string {
+	vals := make([]interface{}, len(columns))
+	for i, col := range columns {
+		vals[i] = row[col]
+	}
+	data, _ := json.Marshal(vals)
+	return string(data)
+}
+
+type uniqueChecker struct {
+	ruleFailures
+	seen map[string]bool
+}
+
+func (c *uniqueChecker) check(i int, row map[string]interface{}) {
+	key, ok := rowKey(row, c.rule.Columns)
+	if !ok {
+		return
+	}
+	if c.seen[key] {
+		c.add(i, "%s", rowValues(row, c.rule.Columns))
+		return
+	}
+	c.seen[key] = true
+}
+
+func (c *uniqueChecker) result(entries int) *RuleResult {
+	return c.ruleFailures.result("duplicate values")
+}
+
+type referenceChecker struct {
+	ruleFailures
+	keys map[string]bool
+}
+
+// newReferenceChecker reads the keys of a reference rule's dataset
+func newReferenceChecker(r repo.Repo, rule *Rule) (*referenceChecker, error) {
+	ref, err := repo.ParseDatasetRef(rule.Dataset)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dataset reference '%s': %s", rule.Dataset, err.Error())
+	}
+	if err := repo.CanonicalizeDatasetRef(r, &ref); err != nil {
+		return nil, fmt.Errorf("error finding dataset '%s': %s", rule.Dataset, err.Error())
+	}
+	if err := ReadDataset(r, &ref); err != nil {
+		return nil, fmt.Errorf("error reading dataset '%s': %s", rule.Dataset, err.Error())
+	}
+	ds := &dataset.Dataset{}
+	if err := ds.Decode(ref.Dataset); err != nil {
+		return nil, err
+	}
+	body, err := dsfs.LoadBody(r.Store(), ds)
+	if err != nil {
+		return nil, fmt.Errorf("error loading body of '%s': %s", rule.Dataset, err.Error())
+	}
+	defer body.Close()
+
+	refCols := rule.RefColumns
+	if refCols == nil {
+		refCols = rule.Columns
+	}
+	c := &referenceChecker{ruleFailures: ruleFailures{rule: rule}, keys: map[string]bool{}}
+	_, err = eachRow(ds.Structure, body, func(i int, row map[string]interface{}) {
+		if key, ok := rowKey(row, refCols); ok {
+			c.keys[key] = true
+		}
+	})
+	return c, err
+}
+
+func (c *referenceChecker) check(i int, row map[string]interface{}) {
+	if key, ok := rowKey(row, c.rule.Columns); ok && !c.keys[key] {
+		c.add(i, "%s", rowValues(row, c.rule.Columns))
+	}
+}
+
+func (c *referenceChecker) result(entries int) *RuleResult {
+	return c.ruleFailures.result("values not found in " + c.rule.Dataset)
+}
+
+type rowCountChecker struct {
+	rule *Rule
+	// prev is the number of entries in the previous version, 0 if unknown
+	prev int
+}
+
+func (c *rowCountChecker) check(i int, row map[string]interface{}) {}
+
+func (c *rowCountChecker) result(entries int) *RuleResult {
+	res := &RuleResult{Rule: c.rule.Title(), Type: c.rule.Type}
+	n := float64(entries)
+	change := 0.0
+	if c.prev > 0 {
+		change = (n - float64(c.prev)) / float64(c.prev)
+	}
+
+	switch {
+	case c.rule.Min != nil && n < *c.rule.Min:
+		res.Message = fmt.Sprintf("%d entries is less than the minimum of %v", entries, *c.rule.Min)
+	case c.rule.Max != nil && n > *c.rule.Max:
+		res.Message = fmt.Sprintf("%d entries is more than the maximum of %v", entries, *c.rule.Max)
+	case c.prev > 0 && c.rule.MaxDecrease != nil && -change > *c.rule.MaxDecrease:
+		res.Message = fmt.Sprintf("entries decreased %.0f%% from %d to %d, more than the allowed %.0f%%", -change*100, c.prev, entries, *c.rule.MaxDecrease*100)
+	case c.prev > 0 && c.rule.MaxIncrease != nil && change > *c.rule.MaxIncrease:
+		res.Message = fmt.Sprintf("entries increased %.0f%% from %d to %d, more than the allowed %.0f%%", change*100, c.prev, entries, *c.rule.MaxIncrease*100)
+	default:
+		res.Passed = true
+	}
+	return res
+}
+
+type patternChecker struct {
+	ruleFailures
+	re *regexp.Regexp
+}
+
+func (c *patternChecker) check(i int, row map[string]interface{}) {
+	for _, col := range c.rule.Columns {
+		var s string
+		switch v := row[col].(type) {
+		case nil:
+			continue
+		case string:
+			s = v
+		case map[string]interface{}, []interface{}:
+			c.add(i, "%s isn't a string", col)
+			continue
+		default:
+			data, _ := json.Marshal(v)
+			s = string(data)
+		}
+		if !c.re.MatchString(s) {
+			c.add(i, "%s %q", col, s)
+		}
+	}
+}
+
+func (c *patternChecker) result(entries int) *RuleResult {
+	return c.ruleFailures.result("values don't match " + c.rule.Pattern)
+}
+
+type rangeChecker struct {
+	ruleFailures
+}
+
+func (c *rangeChecker) check(i int, row map[string]interface{}) {
+	for _, col := range c.rule.Columns {
+		v := row[col]
+		if v == nil {
+			continue
+		}
+		f, ok := statsNumber(v)
+		if !ok {
+			c.add(i, "%s isn't a number", col)
+			continue
+		}
+		if (c.rule.Min != nil && f < *c.rule.Min) || (c.rule.Max != nil && f > *c.rule.Max) {
+			c.add(i, "%s %v", col, f)
+		}
+	}
+}
+
+func (c *rangeChecker) result(entries int) *RuleResult {
+	bounds := func(b *float64) string {
+		if b == nil {
+			return "∞"
+		}
+		return fmt.Sprintf("%v", *b)
+	}
+	return c.ruleFailures.result(fmt.Sprintf("values out of range [%s, %s]", bounds(c.rule.Min), bounds(c.rule.Max)))
+}
+
+type freshnessChecker struct {
+	rule   *Rule
+	maxAge time.Duration
+	newest time.Time
+}
+
+func (c *freshnessChecker) check(i int, row map[string]interface{}) {
+	if len(c.rule.Columns) == 0 {
+		return
+	}
+	if t, ok := ruleTime(row[c.rule.Columns[0]]); ok && t.After(c.newest) {
+		c.newest = t
+	}
+}
+
+func (c *freshnessChecker) result(entries int) *RuleResult {
+	res := &RuleResult{Rule: c.rule.Title(), Type: c.rule.Type}
+	if c.newest.IsZero() {
+		res.Message = "no timestamps found"
+		return res
+	}
+	if age := dsfs.Timestamp().Sub(c.newest); age > c.maxAge {
+		res.Message = fmt.Sprintf("newest timestamp %s is %s old, more than the allowed %s", c.newest.Format(time.RFC3339), age.Round(time.Second), c.maxAge)
+		return res
+	}
+	res.Passed = true
+	return res
+}
+
+type schemaChecker struct {
+	rule       *Rule
+	violations []*SchemaChange
+}
+
+func (c *schemaChecker) check(i int, row map[string]interface{}) {}
+
+func (c *schemaChecker) result(entries int) *RuleResult {
+	res := &RuleResult{Rule: c.rule.Title(), Type: c.rule.Type, Passed: len(c.violations) == 0}
+	if len(c.violations) > 0 {
+		changes := make([]string, len(c.violations))
+		for i, v := range c.violations {
+			changes[i] = v.String()
+		}
+		res.Message = fmt.Sprintf("%d changes break %s compatibility: %s", len(c.violations), c.rule.Policy, strings.Join(changes, ", "))
+	}
+	return res
+}
+
+// ruleTime reads a timestamp from RFC3339 & date strings, or numbers of unix
+// seconds
+func ruleTime(v interface{}) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+	if f, ok := statsNumber(v); ok {
+		return time.Unix(int64(f), 0).UTC(), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,192 @@
+package base
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsfs"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestParseRules(t *testing.T) {
+	cases := []struct {
+		data   string
+		expect int
+		err    string
+	}{
+		{`[]`, 0, ""},
+		{`[{"type":"unique","columns":["id"]},{"type":"rowCount","min":1}]`, 2, ""},
+		{"- type: range\n  columns: [pop]\n  min: 0\n- type: freshness\n  maxAge: 36h\n", 2, ""},
+		{`{"type":"unique"}`, 0, "error reading rules: json: cannot unmarshal object into Go value of type []*base.Rule"},
+		{`[{"columns":["id"]}]`, 0, "rule 0: type is required"},
+		{`[{"type":"nope"}]`, 0, "rule 0: unknown rule type 'nope'"},
+		{`[{"type":"unique"}]`, 0, "rule 0: unique rules require columns"},
+		{`[{"type":"reference","columns":["id"]}]`, 0, "rule 0: reference rules require a dataset"},
+		{`[{"type":"reference","columns":["id"],"dataset":"me/ids","refColumns":["a","b"]}]`, 0, "rule 0: reference rules require the same number of columns & refColumns"},
+		{`[{"type":"pattern","columns":["id"],"pattern":"("}]`, 0, "rule 0: invalid pattern: "},
+		{`[{"type":"range","columns":["pop"]}]`, 0, "rule 0: range rules require a min or max"},
+		{`[{"type":"rowCount"}]`, 0, "rule 0: rowCount rules require at least one of min, max, maxDecrease or maxIncrease"},
+		{`[{"type":"freshness","maxAge":"2 days"}]`, 0, "rule 0: invalid maxAge: time: "},
+		{`[{"type":"freshness","columns":["a","b"],"maxAge":"1h"}]`, 0, "rule 0: freshness rules check at most one column"},
+		{`[{"type":"schema","policy":"forward"}]`, 1, ""},
+		{`[{"type":"schema"}]`, 0, "rule 0: schema rules require a policy of backward, forward or none"},
+	}
+
+	for i, c := range cases {
+		rules, err := ParseRules([]byte(c.data))
+		// error messages of time & regexp packages vary between go versions
+		if !(err == nil && c.err == "" || err != nil && c.err != "" && strings.HasPrefix(err.Error(), c.err)) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if len(rules) != c.expect {
+			t.Errorf("case %d expected %d rules, got: %d", i, c.expect, len(rules))
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	prevTs := dsfs.Timestamp
+	defer func() { dsfs.Timestamp = prevTs }()
+	dsfs.Timestamp = func() time.Time { return time.Date(2018, 1, 4, 0, 0, 0, 0, time.UTC) }
+
+	sch := &jsonschema.RootSchema{}
+	if err := sch.UnmarshalJSON([]byte(`{"type":"array","items":{"type":"array","items":[{"title":"city","type":"string"},{"title":"pop","type":"integer"},{"title":"date","type":"string"}]}}`)); err != nil {
+		t.Fatal(err)
+	}
+	ds := &dataset.Dataset{
+		Commit:    &dataset.Commit{Timestamp: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Structure: &dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch},
+	}
+	prev := &dataset.Dataset{Structure: &dataset.Structure{Entries: 10}}
+	body := `[["toronto",100,"2018-01-01"],["chicago",200,"2018-01-03T00:00:00Z"],["chicago",null,"bad"],["01234",-5,null]]`
+
+	cases := []struct {
+		rule   string
+		expect string
+	}{
+		{`{"type":"unique","columns":["city"]}`,
+			`{"rule":"unique(city)","type":"unique","passed":false,"failures":1,"message":"1 duplicate values, first at entry 2: [\"chicago\"]"}`},
+		{`{"type":"unique","columns":["city","pop"]}`,
+			`{"rule":"unique(city,pop)","type":"unique","passed":true}`},
+		{`{"name":"lowercase","type":"pattern","columns":["city"],"pattern":"^[a-z ]+$"}`,
+			`{"rule":"lowercase","type":"pattern","passed":false,"failures":1,"message":"1 values don't match ^[a-z ]+$, first at entry 3: city \"01234\""}`},
+		{`{"type":"pattern","columns":["pop"],"pattern":"^\\d+$"}`,
+			`{"rule":"pattern(pop)","type":"pattern","passed":false,"failures":1,"message":"1 values don't match ^\\d+$, first at entry 3: pop \"-5\""}`},
+		{`{"type":"range","columns":["pop"],"min":0}`,
+			`{"rule":"range(pop)","type":"range","passed":false,"failures":1,"message":"1 values out of range [0, ∞], first at entry 3: pop -5"}`},
+		{`{"type":"range","columns":["pop"],"min":-10,"max":200}`,
+			`{"rule":"range(pop)","type":"range","passed":true}`},
+		{`{"type":"range","columns":["city"],"max":1}`,
+			`{"rule":"range(city)","type":"range","passed":false,"failures":4,"message":"4 values out of range [∞, 1], first at entry 0: city isn't a number"}`},
+		{`{"type":"rowCount","min":5}`,
+			`{"rule":"rowCount","type":"rowCount","passed":false,"message":"4 entries is less than the minimum of 5"}`},
+		{`{"type":"rowCount","max":3}`,
+			`{"rule":"rowCount","type":"rowCount","passed":false,"message":"4 entries is more than the maximum of 3"}`},
+		{`{"type":"rowCount","maxDecrease":0.1}`,
+			`{"rule":"rowCount","type":"rowCount","passed":false,"message":"entries decreased 60% from 10 to 4, more than the allowed 10%"}`},
+		{`{"type":"rowCount","min":1,"maxIncrease":0.5}`,
+			`{"rule":"rowCount","type":"rowCount","passed":true}`},
+		{`{"type":"freshness","columns":["date"],"maxAge":"48h"}`,
+			`{"rule":"freshness(date)","type":"freshness","passed":true}`},
+		{`{"type":"freshness","columns":["date"],"maxAge":"12h"}`,
+			`{"rule":"freshness(date)","type":"freshness","passed":false,"message":"newest timestamp 2018-01-03T00:00:00Z is 24h0m0s old, more than the allowed 12h0m0s"}`},
+		{`{"type":"freshness","columns":["city"],"maxAge":"12h"}`,
+			`{"rule":"freshness(city)","type":"freshness","passed":false,"message":"no timestamps found"}`},
+		{`{"type":"freshness","maxAge":"1h"}`,
+			`{"rule":"freshness","type":"freshness","passed":false,"message":"newest timestamp 2018-01-01T00:00:00Z is 72h0m0s old, more than the allowed 1h0m0s"}`},
+	}
+
+	for i, c := range cases {
+		rule := &Rule{}
+		if err := json.Unmarshal([]byte(c.rule), rule); err != nil {
+			t.Fatalf("case %d invalid rule: %s", i, err.Error())
+		}
+		results, err := CheckRules(nil, []*Rule{rule}, ds, prev, cafs.NewMemfileBytes("body.json", []byte(body)))
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		data, _ := json.Marshal(results[0])
+		var got, expect interface{}
+		json.Unmarshal(data, &got)
+		if err := json.Unmarshal([]byte(c.expect), &expect); err != nil {
+			t.Fatalf("case %d invalid expectation: %s", i, err.Error())
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("case %d result mismatch.\nexpected: %s\ngot:      %s", i, c.expect, string(data))
+		}
+	}
+
+	// without a previous version rules relative to it pass
+	rule := &Rule{Type: RuleRowCount, MaxDecrease: new(float64)}
+	results, err := CheckRules(nil, []*Rule{rule}, ds, nil, cafs.NewMemfileBytes("body.json", []byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Passed {
+		t.Errorf("expected rowCount without a previous version to pass, got: %s", results[0].Message)
+	}
+}
+
+func TestCheckRulesReference(t *testing.T) {
+	r := newTestRepo(t)
+	ref := addCitiesDataset(t, r)
+
+	ds := &dataset.Dataset{Structure: &dataset.Structure{Format: dataset.JSONDataFormat}}
+	body := `[{"name":"toronto"},{"name":"paris"},{"name":null},{"name":"chicago"}]`
+
+	rule := &Rule{Type: RuleReference, Columns: []string{"name"}, Dataset: ref.AliasString(), RefColumns: []string{"city"}}
+	results, err := CheckRules(r, []*Rule{rule}, ds, nil, cafs.NewMemfileBytes("body.json", []byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `1 values not found in peer/cities, first at entry 1: ["paris"]`
+	if results[0].Passed || results[0].Message != expect {
+		t.Errorf("result mismatch. expected: %s, got: %s", expect, results[0].Message)
+	}
+
+	rule = &Rule{Type: RuleReference, Columns: []string{"name"}, Dataset: "peer/not_a_dataset"}
+	if _, err := CheckRules(r, []*Rule{rule}, ds, nil, cafs.NewMemfileBytes("body.json", []byte(body))); err == nil {
+		t.Error("expected referencing a missing dataset to error")
+	}
+}
+
+func TestDatasetRules(t *testing.T) {
+	r := newTestRepo(t)
+	ref := addCitiesDataset(t, r)
+
+	rules, err := DatasetRules(r, ref.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules != nil {
+		t.Errorf("expected a version without rules to have nil rules, got: %v", rules)
+	}
+
+	min := float64(10)
+	if err := PutDatasetRules(r, ref.Path, []*Rule{{Type: RuleRowCount, Min: &min}}); err != nil {
+		t.Fatal(err)
+	}
+	if rules, err = DatasetRules(r, ref.Path); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Type != RuleRowCount || *rules[0].Min != 10 {
+		data, _ := json.Marshal(rules)
+		t.Errorf("stored rules mismatch, got: %s", string(data))
+	}
+
+	results, err := CheckDatasetRules(r, ref, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "1 of 1 quality rules failed:\n  rowCount: 5 entries is less than the minimum of 10"
+	if err := RulesError(results); err == nil || err.Error() != expect {
+		t.Errorf("rules error mismatch. expected: %s, got: %v", expect, err)
+	}
+}
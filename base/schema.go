@@ -0,0 +1,239 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/qri-io/dataset"
+)
+
+const (
+	// SchemaPolicyNone allows any change to a dataset's schema
+	SchemaPolicyNone = "none"
+	// SchemaPolicyBackward allows changes that keep readers of a new version
+	// able to read previous versions: adding & removing columns, and widening
+	// column types
+	SchemaPolicyBackward = "backward"
+	// SchemaPolicyForward allows changes that keep readers of previous versions
+	// able to read a new version: adding columns
+	SchemaPolicyForward = "forward"
+)
+
+const (
+	// SchemaColumnAdded is a column in a new schema that wasn't in the previous
+	SchemaColumnAdded = "added column"
+	// SchemaColumnRemoved is a column of the previous schema that a new schema
+	// drops
+	SchemaColumnRemoved = "removed column"
+	// SchemaTypeWidening is a column type that accepts every value the
+	// previous type did, like integer to number
+	SchemaTypeWidening = "type widening"
+	// SchemaTypeBreaking is a column type that rejects values the previous
+	// type accepted, like number to integer or string to number
+	SchemaTypeBreaking = "breaking type change"
+)
+
+// schemaPolicyBreaks lists the kinds of change each policy refuses
+var schemaPolicyBreaks = map[string]map[string]bool{
+	SchemaPolicyNone:     {},
+	SchemaPolicyBackward: {SchemaTypeBreaking: true},
+	SchemaPolicyForward:  {SchemaColumnRemoved: true, SchemaTypeWidening: true, SchemaTypeBreaking: true},
+}
+
+// SchemaChange is a difference in a column between the schemas of two
+// versions of a dataset
+type SchemaChange struct {
+	// Kind is one of the SchemaChange constants
+	Kind   string `json:"kind"`
+	Column string `json:"column"`
+	// From & To are the column's types before & after type changes. an empty
+	// list of types allows any type
+	From []string `json:"from,omitempty"`
+	To   []string `json:"to,omitempty"`
+}
+
+// String describes a change for reports
+func (c *SchemaChange) String() string {
+	switch c.Kind {
+	case SchemaColumnAdded, SchemaColumnRemoved:
+		return fmt.Sprintf("%s %s", c.Kind, c.Column)
+	}
+	return fmt.Sprintf("%s of %s from %s to %s", c.Kind, c.Column, schemaTypesString(c.From), schemaTypesString(c.To))
+}
+
+// SchemaChanges classifies the differences between the columns of two
+// structures. columns are matched by title. previous schemas that don't
+// describe columns can't be compared & have no changes, a next schema that
+// doesn't describe columns removes every previous column
+func SchemaChanges(prev, next *dataset.Structure) []*SchemaChange {
+	prevCols := schemaTableColumns(prev)
+	nextCols := schemaTableColumns(next)
+	if len(prevCols) == 0 {
+		return nil
+	}
+
+	nextTypes := map[string][]string{}
+	for _, col := range nextCols {
+		nextTypes[col.title] = col.types
+	}
+	prevTypes := map[string][]string{}
+
+	changes := []*SchemaChange{}
+	for _, col := range prevCols {
+		prevTypes[col.title] = col.types
+		to, ok := nextTypes[col.title]
+		if !ok {
+			changes = append(changes, &SchemaChange{Kind: SchemaColumnRemoved, Column: col.title})
+			continue
+		}
+		if schemaTypesCover(col.types, to) && schemaTypesCover(to, col.types) {
+			continue
+		}
+		kind := SchemaTypeBreaking
+		if schemaTypesCover(to, col.types) {
+			kind = SchemaTypeWidening
+		}
+		changes = append(changes, &SchemaChange{Kind: kind, Column: col.title, From: col.types, To: to})
+	}
+	for _, col := range nextCols {
+		if _, ok := prevTypes[col.title]; !ok {
+			changes = append(changes, &SchemaChange{Kind: SchemaColumnAdded, Column: col.title})
+		}
+	}
+	return changes
+}
+
+// SchemaViolations filters changes to those a compatibility policy refuses
+func SchemaViolations(policy string, changes []*SchemaChange) []*SchemaChange {
+	breaks := schemaPolicyBreaks[policy]
+	var violations []*SchemaChange
+	for _, c := range changes {
+		if breaks[c.Kind] {
+			violations = append(violations, c)
+		}
+	}
+	return violations
+}
+
+// CheckSchemaRules checks the schema changes from prev to ds against the
+// policies of any schema rules, returning an error that reports each change
+// a policy refuses. schema rules only compare structures, so unlike other
+// rules checking them doesn't read the body
+func CheckSchemaRules(rules []*Rule, ds, prev *dataset.Dataset) error {
+	if prev == nil {
+		return nil
+	}
+	var changes []*SchemaChange
+	var reports []string
+	for _, rule := range rules {
+		if rule.Type != RuleSchema {
+			continue
+		}
+		if changes == nil {
+			changes = SchemaChanges(prev.Structure, ds.Structure)
+		}
+		violations := SchemaViolations(rule.Policy, changes)
+		if len(violations) == 0 {
+			continue
+		}
+		lines := make([]string, len(violations))
+		for i, v := range violations {
+			lines[i] = "  " + v.String()
+		}
+		reports = append(reports, fmt.Sprintf("schema changes break %s compatibility:\n%s", rule.Policy, strings.Join(lines, "\n")))
+	}
+	if len(reports) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(reports, "\n"))
+}
+
+// schemaColumn is the title & types of a column
+type schemaColumn struct {
+	title string
+	types []string
+}
+
+// schemaTableColumns reads the columns of array-of-arrays schemas in order,
+// and the properties of array-of-objects schemas sorted by title
+func schemaTableColumns(st *dataset.Structure) []schemaColumn {
+	if st == nil || st.Schema == nil {
+		return nil
+	}
+	data, err := st.Schema.MarshalJSON()
+	if err != nil {
+		return nil
+	}
+	sch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &sch); err != nil {
+		return nil
+	}
+	items, _ := sch["items"].(map[string]interface{})
+
+	var cols []schemaColumn
+	if list, ok := items["items"].([]interface{}); ok {
+		for i, c := range list {
+			col, _ := c.(map[string]interface{})
+			title, _ := col["title"].(string)
+			if title == "" {
+				title = fmt.Sprintf("field_%d", i+1)
+			}
+			cols = append(cols, schemaColumn{title: title, types: schemaTypes(col["type"])})
+		}
+	} else if props, ok := items["properties"].(map[string]interface{}); ok {
+		for title, p := range props {
+			col, _ := p.(map[string]interface{})
+			cols = append(cols, schemaColumn{title: title, types: schemaTypes(col["type"])})
+		}
+		sort.Slice(cols, func(i, j int) bool { return cols[i].title < cols[j].title })
+	}
+	return cols
+}
+
+// schemaTypes reads the "type" keyword of a schema, which can be a string or
+// a list of strings
+func schemaTypes(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := []string{}
+		for _, s := range t {
+			if str, ok := s.(string); ok {
+				types = append(types, str)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// schemaTypesCover checks every value of types from is a value of types to.
+// empty types allow any value, and numbers include integers
+func schemaTypesCover(to, from []string) bool {
+	if len(to) == 0 {
+		return true
+	}
+	if len(from) == 0 {
+		return false
+	}
+	allowed := map[string]bool{}
+	for _, t := range to {
+		allowed[t] = true
+	}
+	for _, t := range from {
+		if !allowed[t] && !(t == "integer" && allowed["number"]) {
+			return false
+		}
+	}
+	return true
+}
+
+func schemaTypesString(types []string) string {
+	if len(types) == 0 {
+		return "any"
+	}
+	return strings.Join(types, "|")
+}
@@ -0,0 +1,120 @@
+package base
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+)
+
+func schemaStructure(t *testing.T, schema string) *dataset.Structure {
+	sch := &jsonschema.RootSchema{}
+	if err := sch.UnmarshalJSON([]byte(schema)); err != nil {
+		t.Fatal(err)
+	}
+	return &dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch}
+}
+
+func TestSchemaChanges(t *testing.T) {
+	cases := []struct {
+		prev, next string
+		expect     []string
+	}{
+		{`{"type":"array"}`, `{"type":"array","items":{"type":"array","items":[{"title":"a","type":"string"}]}}`, nil},
+		{`{"type":"array","items":{"type":"array","items":[{"title":"a","type":"string"},{"title":"b","type":"integer"}]}}`,
+			`{"type":"array","items":{"type":"array","items":[{"title":"a","type":"string"},{"title":"b","type":"integer"}]}}`,
+			nil},
+		{`{"type":"array","items":{"type":"array","items":[{"title":"a","type":"string"},{"title":"b","type":"integer"},{"title":"c","type":"number"},{"title":"d"}]}}`,
+			`{"type":"array","items":{"type":"array","items":[{"title":"a","type":["string","null"]},{"title":"c","type":"integer"},{"title":"d","type":"string"},{"title":"e","type":"boolean"}]}}`,
+			[]string{
+				"type widening of a from string to string|null",
+				"removed column b",
+				"breaking type change of c from number to integer",
+				"breaking type change of d from any to string",
+				"added column e",
+			}},
+		{`{"type":"array","items":{"type":"object","properties":{"id":{"type":"integer"},"name":{"type":"string"}}}}`,
+			`{"type":"array","items":{"type":"object","properties":{"id":{"type":"number"},"name":{"type":"integer"},"tags":{"type":"array"}}}}`,
+			[]string{
+				"type widening of id from integer to number",
+				"breaking type change of name from string to integer",
+				"added column tags",
+			}},
+		{`{"type":"array","items":{"type":"array","items":[{"type":"integer"},{"type":"string"}]}}`,
+			`{"type":"array","items":{"type":"array","items":[{"type":["integer","number"]}]}}`,
+			[]string{"type widening of field_1 from integer to integer|number", "removed column field_2"}},
+		{`{"type":"array","items":{"type":"array","items":[{"title":"a","type":"string"},{"title":"b","type":"integer"}]}}`,
+			`{"type":"array"}`,
+			[]string{"removed column a", "removed column b"}},
+	}
+
+	for i, c := range cases {
+		changes := SchemaChanges(schemaStructure(t, c.prev), schemaStructure(t, c.next))
+		var got []string
+		for _, ch := range changes {
+			got = append(got, ch.String())
+		}
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("case %d changes mismatch.\nexpected: %v\ngot:      %v", i, c.expect, got)
+		}
+	}
+}
+
+func TestSchemaViolations(t *testing.T) {
+	changes := []*SchemaChange{
+		{Kind: SchemaColumnAdded, Column: "a"},
+		{Kind: SchemaColumnRemoved, Column: "b"},
+		{Kind: SchemaTypeWidening, Column: "c"},
+		{Kind: SchemaTypeBreaking, Column: "d"},
+	}
+	cases := []struct {
+		policy string
+		expect []string
+	}{
+		{SchemaPolicyNone, nil},
+		{SchemaPolicyBackward, []string{"d"}},
+		{SchemaPolicyForward, []string{"b", "c", "d"}},
+	}
+	for i, c := range cases {
+		var got []string
+		for _, v := range SchemaViolations(c.policy, changes) {
+			got = append(got, v.Column)
+		}
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("case %d violations mismatch. expected: %v, got: %v", i, c.expect, got)
+		}
+	}
+}
+
+func TestCheckSchemaRules(t *testing.T) {
+	prev := &dataset.Dataset{Structure: schemaStructure(t, `{"type":"array","items":{"type":"array","items":[{"title":"city","type":"string"},{"title":"pop","type":"integer"}]}}`)}
+	ds := &dataset.Dataset{Structure: schemaStructure(t, `{"type":"array","items":{"type":"array","items":[{"title":"city","type":"string"},{"title":"avg_age","type":"number"}]}}`)}
+
+	rules := []*Rule{{Type: RuleUnique, Columns: []string{"city"}}, {Type: RuleSchema, Policy: SchemaPolicyBackward}}
+	if err := CheckSchemaRules(rules, ds, prev); err != nil {
+		t.Errorf("expected backward compatible changes to pass, got: %s", err)
+	}
+	if err := CheckSchemaRules(rules, ds, nil); err != nil {
+		t.Errorf("expected a first version to pass, got: %s", err)
+	}
+
+	rules = []*Rule{{Type: RuleSchema, Policy: SchemaPolicyForward}}
+	expect := "schema changes break forward compatibility:\n  removed column pop"
+	if err := CheckSchemaRules(rules, ds, prev); err == nil || err.Error() != expect {
+		t.Errorf("error mismatch. expected: %s, got: %v", expect, err)
+	}
+
+	// validating reports schema rules like any other rule
+	results, err := CheckRules(nil, rules, ds, prev, cafs.NewMemfileBytes("body.json", []byte(`[]`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(results[0])
+	expect = `{"rule":"schema","type":"schema","passed":false,"message":"1 changes break forward compatibility: removed column pop"}`
+	if string(data) != expect {
+		t.Errorf("result mismatch.\nexpected: %s\ngot:      %s", expect, string(data))
+	}
+}
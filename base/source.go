@@ -0,0 +1,774 @@
+package base
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+
+	// database drivers for sql sources
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SourceSyntax is the transform syntax of datasets pulled from a source.
+// Sources are recorded as a transform without a script, with the source url
+// stored in transform.config.source, so updates can re-pull them
+const SourceSyntax = "source"
+
+// httpClient makes base's requests for sources & body urls. it's separate
+// from go's default client, which transforms are held to their allowed hosts
+// through
+var httpClient = &http.Client{}
+
+// SourceMaxPages is the default number of pages an api source will request
+const SourceMaxPages = 100
+
+// sourceSchemes maps url schemes to the kind of source they connect to
+var sourceSchemes = map[string]string{
+	"sqlite":     "sqlite",
+	"sqlite3":    "sqlite",
+	"postgres":   "postgres",
+	"postgresql": "postgres",
+	"api+http":   "api",
+	"api+https":  "api",
+}
+
+// IsSourcePath checks if a body path is a source url, one of:
+// * sqlite:///path/to/file.db?table=name or ?query=SELECT...
+// * postgres://user@host/db?query=SELECT... or ?table=name
+// * api+https://host/path#select=$.data&next=$.links.next
+func IsSourcePath(path string) bool {
+	return sourceKind(path) != ""
+}
+
+// sourceKind gives the kind of source a path connects to, "" if path isn't a
+// source url
+func sourceKind(path string) string {
+	i := strings.Index(path, "://")
+	if i < 0 {
+		return ""
+	}
+	return sourceSchemes[strings.ToLower(path[:i])]
+}
+
+// AbsSourcePath resolves the file path of a sqlite source url against the
+// current working directory. Other source urls are returned as-is
+func AbsSourcePath(path string) (string, error) {
+	if sourceKind(path) != "sqlite" {
+		return path, nil
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid source url: %s", err.Error())
+	}
+	if u.Host == "" {
+		return path, nil
+	}
+	abs, err := filepath.Abs(u.Host + u.Path)
+	if err != nil {
+		return "", err
+	}
+	u.Host = ""
+	u.Path = abs
+	return u.String(), nil
+}
+
+// sourceCredentialParams are query params that carry credentials. They're
+// removed from recorded source urls, and restored from secrets of the same
+// name when pulling a source
+var sourceCredentialParams = map[string]bool{
+	"access_token":  true,
+	"api_key":       true,
+	"apikey":        true,
+	"client_secret": true,
+	"key":           true,
+	"passwd":        true,
+	"password":      true,
+	"secret":        true,
+	"sslcert":       true,
+	"sslkey":        true,
+	"sslpassword":   true,
+	"sslrootcert":   true,
+	"token":         true,
+}
+
+// RedactSourceURL removes any password & credential query params from a
+// source url. Redacted urls are safe to record in a dataset, credentials are
+// supplied as secrets when re-pulling a source
+func RedactSourceURL(path string) (string, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid source url: %s", err.Error())
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.User(u.User.Username())
+		}
+	}
+	params := u.Query()
+	redacted := false
+	for key := range params {
+		if sourceCredentialParams[strings.ToLower(key)] {
+			params.Del(key)
+			redacted = true
+		}
+	}
+	if redacted {
+		u.RawQuery = params.Encode()
+	}
+	return u.String(), nil
+}
+
+// restoreSourceCredentials adds credential params missing from a source url
+// back from secrets, returning true if any were added. secrets named in skip
+// are used some other way & aren't added
+func restoreSourceCredentials(params url.Values, secrets map[string]string, skip ...string) (restored bool) {
+	for key, val := range secrets {
+		if !sourceCredentialParams[key] || val == "" || params.Get(key) != "" {
+			continue
+		}
+		skipped := false
+		for _, s := range skip {
+			skipped = skipped || s == key
+		}
+		if !skipped {
+			params.Set(key, val)
+			restored = true
+		}
+	}
+	return restored
+}
+
+// SourceBody is a body pulled from a source, converted to JSON. Bodies carry
+// a structure with a schema inferred from the source
+type SourceBody struct {
+	cafs.File
+	Structure *dataset.Structure
+}
+
+// PullSource reads a body from a source url. secrets optionally supply
+// credentials the url doesn't carry:
+// * password: the password for a database user
+// * token: sent to api sources as a bearer token
+// * other credential params, like api_key or sslkey: added to the url's query
+// when it doesn't set them
+func PullSource(path string, secrets map[string]string) (*SourceBody, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source url: %s", err.Error())
+	}
+
+	var (
+		rows []interface{}
+		sch  map[string]interface{}
+	)
+
+	switch sourceKind(path) {
+	case "sqlite":
+		rows, sch, err = pullSQLite(u)
+	case "postgres":
+		rows, sch, err = pullPostgres(u, secrets)
+	case "api":
+		rows, err = pullAPI(u, secrets)
+		sch = inferRowsSchema(rows)
+	default:
+		return nil, fmt.Errorf("unsupported source: %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSourceBody(rows, sch)
+}
+
+// newSourceBody encodes rows & a schema as a JSON source body
+func newSourceBody(rows []interface{}, sch map[string]interface{}) (*SourceBody, error) {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	schData, err := json.Marshal(sch)
+	if err != nil {
+		return nil, err
+	}
+	rs := &jsonschema.RootSchema{}
+	if err := rs.UnmarshalJSON(schData); err != nil {
+		return nil, err
+	}
+
+	return &SourceBody{
+		File: cafs.NewMemfileBytes("body.json", data),
+		Structure: &dataset.Structure{
+			Format: dataset.JSONDataFormat,
+			Schema: rs,
+		},
+	}, nil
+}
+
+// sourcePodBody pulls a body for a dataset pod with a source body path,
+// recording the source as the pod's transform
+func sourcePodBody(dsp *dataset.DatasetPod) (cafs.File, error) {
+	var secrets map[string]string
+	if dsp.Transform != nil {
+		if dsp.Transform.ScriptPath != "" {
+			return nil, fmt.Errorf("a body source can't be combined with a transform script")
+		}
+		secrets = dsp.Transform.Secrets
+	}
+
+	body, err := PullSource(dsp.BodyPath, secrets)
+	if err != nil {
+		return nil, err
+	}
+	redacted, err := RedactSourceURL(dsp.BodyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if dsp.Transform == nil {
+		dsp.Transform = &dataset.TransformPod{}
+	}
+	dsp.Transform.Syntax = SourceSyntax
+	dsp.Transform.Config = map[string]interface{}{"source": redacted}
+	dsp.BodyPath = ""
+	if dsp.Structure != nil {
+		dsp.Structure.Format = dataset.JSONDataFormat.String()
+		dsp.Structure.FormatConfig = nil
+	}
+	return body, nil
+}
+
+// pullSQLite reads a table or query from a sqlite database file
+func pullSQLite(u *url.URL) ([]interface{}, map[string]interface{}, error) {
+	path := u.Host + u.Path
+	// sqlite creates databases that don't exist, which is never what we want
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("sqlite source: %s", err.Error())
+	}
+	query, err := sourceQuery(u.Query())
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return nil, nil, fmt.Errorf("sqlite source: %s", err.Error())
+	}
+	defer db.Close()
+	return querySource(db, query)
+}
+
+// pullPostgres reads a table or query from a postgres database
+func pullPostgres(u *url.URL, secrets map[string]string) ([]interface{}, map[string]interface{}, error) {
+	params := u.Query()
+	query, err := sourceQuery(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	params.Del("query")
+	params.Del("table")
+	// a password secret fills in the user's password when the url names a user
+	if u.User != nil {
+		restoreSourceCredentials(params, secrets, "password")
+	} else {
+		restoreSourceCredentials(params, secrets)
+	}
+
+	dsn := *u
+	dsn.Scheme = "postgres"
+	dsn.RawQuery = params.Encode()
+	if dsn.User != nil && secrets["password"] != "" {
+		if _, ok := dsn.User.Password(); !ok {
+			dsn.User = url.UserPassword(dsn.User.Username(), secrets["password"])
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("postgres source: %s", err.Error())
+	}
+	defer db.Close()
+	return querySource(db, query)
+}
+
+// sourceQuery reads the query of a sql source url from it's "query" or
+// "table" param
+func sourceQuery(params url.Values) (string, error) {
+	if q := params.Get("query"); q != "" {
+		return q, nil
+	}
+	if t := params.Get("table"); t != "" {
+		return fmt.Sprintf(`SELECT * FROM "%s"`, strings.Replace(t, `"`, `""`, -1)), nil
+	}
+	return "", fmt.Errorf("sql sources require a table or query param")
+}
+
+// readStatements are the statements a source query can start with. anything
+// else, like ATTACH, PRAGMA or VACUUM INTO, can read or write files the
+// query shouldn't reach
+var readStatements = map[string]bool{"SELECT": true, "WITH": true, "VALUES": true}
+
+// checkReadQuery refuses queries that aren't a single read statement
+func checkReadQuery(query string) error {
+	stmts := sqlStatements(query)
+	if len(stmts) == 0 {
+		return fmt.Errorf("query is empty")
+	}
+	if len(stmts) > 1 {
+		return fmt.Errorf("queries must be a single statement, got %d", len(stmts))
+	}
+	words := strings.FieldsFunc(stmts[0], func(r rune) bool {
+		return !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if len(words) == 0 {
+		return fmt.Errorf("queries can only read with SELECT, WITH or VALUES")
+	}
+	if keyword := strings.ToUpper(words[0]); !readStatements[keyword] {
+		return fmt.Errorf("queries can only read with SELECT, WITH or VALUES, got: %s", keyword)
+	}
+	return nil
+}
+
+// sqlStatements splits a query into it's non-empty statements, with comments
+// removed & string literals and quoted identifiers blanked out
+func sqlStatements(query string) []string {
+	var (
+		stmts []string
+		cur   strings.Builder
+	)
+	end := func() {
+		if stmt := strings.TrimSpace(cur.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		cur.Reset()
+	}
+	// skip moves past a quoted section that closes with close, which is
+	// escaped by doubling it
+	skip := func(i int, close byte) int {
+		for i++; i < len(query); i++ {
+			if query[i] == close {
+				if i+1 < len(query) && query[i+1] == close && close != ']' {
+					i++
+					continue
+				}
+				return i
+			}
+		}
+		return i
+	}
+
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			i = skip(i, c)
+			cur.WriteString(" '' ")
+		case c == '[':
+			i = skip(i, ']')
+			cur.WriteString(" '' ")
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			cur.WriteByte(' ')
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(query)
+			}
+			cur.WriteByte(' ')
+		case c == ';':
+			end()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	end()
+	return stmts
+}
+
+// querySource runs a query, reading the result as rows of an array-of-arrays
+// body with a schema built from the result's column types. args are bound to
+// parameters of the query
+func querySource(db *sql.DB, query string, args ...interface{}) ([]interface{}, map[string]interface{}, error) {
+	if err := checkReadQuery(query); err != nil {
+		return nil, nil, err
+	}
+	res, err := db.Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("querying source: %s", err.Error())
+	}
+	defer res.Close()
+
+	colTypes, err := res.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := []interface{}{}
+	for res.Next() {
+		vals := make([]interface{}, len(colTypes))
+		ptrs := make([]interface{}, len(colTypes))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := res.Scan(ptrs...); err != nil {
+			return nil, nil, fmt.Errorf("reading source row %d: %s", len(rows), err.Error())
+		}
+		for i, v := range vals {
+			vals[i] = sourceValue(v)
+		}
+		rows = append(rows, vals)
+	}
+	if err := res.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading source rows: %s", err.Error())
+	}
+
+	cols := make([]interface{}, len(colTypes))
+	for i, ct := range colTypes {
+		col := map[string]interface{}{"title": ct.Name()}
+		typ := sqlColumnType(ct.DatabaseTypeName())
+		if typ == "" {
+			// expressions & untyped sqlite columns don't declare a type, use
+			// the type of the first value
+			for _, row := range rows {
+				if t := jsonValueType(row.([]interface{})[i]); t != "" && t != "null" {
+					typ = t
+					break
+				}
+			}
+		}
+		if typ != "" {
+			col["type"] = typ
+		}
+		cols[i] = col
+	}
+
+	sch := map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":  "array",
+			"items": cols,
+		},
+	}
+	return rows, sch, nil
+}
+
+// sourceValue converts a value scanned from a database to a JSON value
+func sourceValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []byte:
+		return string(x)
+	case time.Time:
+		return x.UTC().Format(time.RFC3339)
+	}
+	return v
+}
+
+// sqlColumnType maps a database column type to a JSON schema type, returning
+// "" for unknown types
+func sqlColumnType(dbType string) string {
+	t := strings.ToUpper(dbType)
+	switch {
+	case t == "":
+		return ""
+	case strings.Contains(t, "BOOL"):
+		return "boolean"
+	case strings.Contains(t, "INT"), t == "SERIAL", t == "BIGSERIAL":
+		return "integer"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"),
+		strings.Contains(t, "NUMERIC"), strings.Contains(t, "DECIMAL"):
+		return "number"
+	case strings.Contains(t, "JSON"):
+		return ""
+	}
+	return "string"
+}
+
+// pullAPI reads entries from a JSON http api. Options are read from the
+// url's fragment, which is never sent to the api:
+// * select: a JSONPath selecting entries from each page, defaults to "$"
+// * next: a JSONPath to the url of the next page in each response
+// * page: a query param incremented for each page
+// * maxPages: the most pages to request, defaults to SourceMaxPages
+// paging stops when a page selects no entries. The first page is always the
+// url as given
+func pullAPI(u *url.URL, secrets map[string]string) ([]interface{}, error) {
+	opts, err := url.ParseQuery(u.Fragment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid api source options: %s", err.Error())
+	}
+	sel := opts.Get("select")
+	if sel == "" {
+		sel = "$"
+	}
+	maxPages := SourceMaxPages
+	if mp := opts.Get("maxPages"); mp != "" {
+		if maxPages, err = strconv.Atoi(mp); err != nil || maxPages < 1 {
+			return nil, fmt.Errorf("invalid api source maxPages: %s", mp)
+		}
+	}
+
+	page := *u
+	page.Scheme = strings.TrimPrefix(strings.ToLower(u.Scheme), "api+")
+	page.Fragment = ""
+	// the token secret is sent as a bearer token, not a param
+	if params := page.Query(); restoreSourceCredentials(params, secrets, "token") {
+		page.RawQuery = params.Encode()
+	}
+	pageNum := 1
+	if p := opts.Get("page"); p != "" {
+		if v := page.Query().Get(p); v != "" {
+			if pageNum, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("invalid api source page: %s", v)
+			}
+		}
+	}
+
+	entries := []interface{}{}
+	seen := map[string]bool{}
+	next := page.String()
+	for i := 0; i < maxPages && next != "" && !seen[next]; i++ {
+		seen[next] = true
+		current, err := url.Parse(next)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := fetchAPIPage(next, secrets["token"])
+		if err != nil {
+			return nil, err
+		}
+		selected, err := selectJSONPath(doc, sel)
+		if err != nil {
+			return nil, err
+		}
+		if len(selected) == 1 {
+			if arr, ok := selected[0].([]interface{}); ok {
+				selected = arr
+			}
+		}
+		if len(selected) == 0 {
+			break
+		}
+		entries = append(entries, selected...)
+
+		switch {
+		case opts.Get("next") != "":
+			next = ""
+			if found, err := selectJSONPath(doc, opts.Get("next")); err == nil && len(found) == 1 {
+				if s, ok := found[0].(string); ok && s != "" {
+					nu, err := url.Parse(s)
+					if err != nil {
+						return nil, fmt.Errorf("invalid next page url: %s", s)
+					}
+					next = current.ResolveReference(nu).String()
+				}
+			}
+		case opts.Get("page") != "":
+			pageNum++
+			q := page.Query()
+			q.Set(opts.Get("page"), strconv.Itoa(pageNum))
+			page.RawQuery = q.Encode()
+			next = page.String()
+		default:
+			next = ""
+		}
+	}
+	return entries, nil
+}
+
+// fetchAPIPage requests & decodes a page of JSON from an api
+func fetchAPIPage(pageURL, token string) (interface{}, error) {
+	req, err := http.NewRequest("GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching api source: %s", err.Error())
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid status code fetching api source: %d", res.StatusCode)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decoding api source response: %s", err.Error())
+	}
+	return doc, nil
+}
+
+// selectJSONPath evaluates a subset of JSONPath against a decoded JSON
+// document: the root ($), child keys (.key or ['key']), array indices ([0])
+// and wildcards (.* or [*])
+func selectJSONPath(doc interface{}, path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSONPath '%s': must start with $", path)
+	}
+	matches := []interface{}{doc}
+	rest := path[1:]
+	for rest != "" {
+		var seg string
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			seg, rest = rest[:end], rest[end:]
+			if seg == "" {
+				return nil, fmt.Errorf("invalid JSONPath '%s': empty key", path)
+			}
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath '%s': unclosed bracket", path)
+			}
+			seg, rest = rest[1:end], rest[end+1:]
+			if len(seg) >= 2 && (seg[0] == '\'' || seg[0] == '"') && seg[len(seg)-1] == seg[0] {
+				seg = "." + seg[1:len(seg)-1]
+			} else if seg != "*" {
+				if _, err := strconv.Atoi(seg); err != nil {
+					return nil, fmt.Errorf("invalid JSONPath '%s': invalid index '%s'", path, seg)
+				}
+				seg = "#" + seg
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSONPath '%s'", path)
+		}
+
+		next := []interface{}{}
+		for _, m := range matches {
+			next = append(next, jsonPathStep(m, seg)...)
+		}
+		matches = next
+	}
+	return matches, nil
+}
+
+// jsonPathStep applies a single JSONPath segment to a value. segments are a
+// key, a key prefixed with "." (from bracket notation), an index prefixed with
+// "#", or a wildcard
+func jsonPathStep(v interface{}, seg string) []interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if seg == "*" {
+			keys := make([]string, 0, len(x))
+			for key := range x {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			vals := make([]interface{}, len(keys))
+			for i, key := range keys {
+				vals[i] = x[key]
+			}
+			return vals
+		}
+		if val, ok := x[strings.TrimPrefix(seg, ".")]; ok {
+			return []interface{}{val}
+		}
+	case []interface{}:
+		if seg == "*" {
+			return x
+		}
+		if strings.HasPrefix(seg, "#") {
+			i, _ := strconv.Atoi(seg[1:])
+			if i < 0 {
+				i += len(x)
+			}
+			if i >= 0 && i < len(x) {
+				return []interface{}{x[i]}
+			}
+		}
+	}
+	return nil
+}
+
+// inferRowsSchema builds a schema for decoded JSON entries. object entries
+// list the type of each property, types that vary between entries are
+// omitted
+func inferRowsSchema(rows []interface{}) map[string]interface{} {
+	sch := map[string]interface{}{"type": "array"}
+	props := map[string]string{}
+	for _, row := range rows {
+		obj, ok := row.(map[string]interface{})
+		if !ok {
+			return sch
+		}
+		for key, val := range obj {
+			t := jsonValueType(val)
+			if prev, ok := props[key]; !ok || prev == "null" {
+				props[key] = t
+			} else if t != prev && t != "null" {
+				if (prev == "integer" && t == "number") || (prev == "number" && t == "integer") {
+					props[key] = "number"
+				} else {
+					props[key] = ""
+				}
+			}
+		}
+	}
+	if len(rows) == 0 {
+		return sch
+	}
+
+	properties := map[string]interface{}{}
+	for key, t := range props {
+		prop := map[string]interface{}{}
+		if t != "" && t != "null" {
+			prop["type"] = t
+		}
+		properties[key] = prop
+	}
+	sch["items"] = map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	return sch
+}
+
+// jsonValueType gives the JSON schema type of a value
+func jsonValueType(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int, int32, int64:
+		return "integer"
+	case float32:
+		return jsonValueType(float64(x))
+	case float64:
+		if x == float64(int64(x)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return ""
+}
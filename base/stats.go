@@ -0,0 +1,532 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsfs"
+	"github.com/qri-io/qri/repo"
+)
+
+const (
+	// StatsHistogramBins is the number of equal-width bins in a numeric
+	// column's histogram
+	StatsHistogramBins = 10
+	// StatsTopK is the number of most frequent values listed per column
+	StatsTopK = 10
+
+	// statsDistinctSample is the number of hashes kept to estimate distinct
+	// values. columns with fewer distinct values are counted exactly
+	statsDistinctSample = 1024
+	// statsTopKCounters is the number of values counted to find the most
+	// frequent. columns with fewer distinct values are counted exactly
+	statsTopKCounters = 100
+	// statsCentroids is the number of centroids kept to build a histogram
+	statsCentroids = 64
+)
+
+// StatsComponent is the name stats are stored under by repos that implement
+// repo.ComponentStore
+const StatsComponent = "stats"
+
+// Stats is a profile of a dataset body, computed in a single pass over it's
+// entries. Stats are computed on save & stored by repos that implement
+// repo.ComponentStore
+type Stats struct {
+	// Entries is the number of entries in the body
+	Entries int `json:"entries"`
+	// Columns profiles each column of the body. Columns of array entries are
+	// titled from the schema, object entries have a column per key & all
+	// other entries are profiled as a single "value" column
+	Columns []*ColumnStats `json:"columns"`
+}
+
+// ColumnStats is a profile of the values in one column of a body
+type ColumnStats struct {
+	Title string `json:"title"`
+	// Types counts values by JSON type
+	Types map[string]int `json:"types"`
+	// Count is the number of non-null values
+	Count int `json:"count"`
+	// Nulls is the number of null or missing values
+	Nulls int `json:"nulls"`
+	// Distinct is the number of distinct non-null values. Counts are exact up
+	// to 1024 distinct values, estimated beyond
+	Distinct int `json:"distinct"`
+	// Min & Max are numeric bounds if the column has any numbers, otherwise
+	// the lexical bounds of it's strings
+	Min interface{} `json:"min,omitempty"`
+	Max interface{} `json:"max,omitempty"`
+	// Mean is the mean of numeric values
+	Mean *float64 `json:"mean,omitempty"`
+	// Histogram counts numeric values in equal-width bins between Min & Max
+	Histogram []HistogramBin `json:"histogram,omitempty"`
+	// TopK lists the most frequent values, most frequent first
+	TopK []ValueCount `json:"topK,omitempty"`
+}
+
+// HistogramBin is a count of values in the range [Min, Max). The last bin of
+// a histogram includes it's Max
+type HistogramBin struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// ValueCount is a value & the number of times it occurs. counts of columns
+// with more than 100 distinct values are estimates
+type ValueCount struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+}
+
+// ComputeStats profiles a body file in a single streaming pass. bodies in a
+// body encoding are read according to the extension of file's name
+func ComputeStats(st *dataset.Structure, file cafs.File) (*Stats, error) {
+	rr, err := NewBodyReader(st, BodyFileEncoding(file.FileName()), file)
+	if err != nil {
+		return nil, fmt.Errorf("error allocating data reader: %s", err)
+	}
+
+	p := newStatsProfiler(schemaColumnTitles(st, 0))
+	for {
+		ent, err := rr.ReadEntry()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("error reading entry %d: %s", p.entries, err)
+		}
+		p.add(ent.Value)
+	}
+	return p.stats(), nil
+}
+
+// DatasetStats gets the stats of a dataset version. stats are read from
+// repos that implement repo.ComponentStore, falling back to profiling the
+// version's body. stats computed from the body are stored if possible
+func DatasetStats(r repo.Repo, ref repo.DatasetRef) (*Stats, error) {
+	cs, isComponentStore := r.(repo.ComponentStore)
+	if isComponentStore && ref.Path != "" {
+		if data, err := cs.Component(ref.Path, StatsComponent); err == nil {
+			stats := &Stats{}
+			if err := json.Unmarshal(data, stats); err != nil {
+				return nil, fmt.Errorf("error decoding stats: %s", err.Error())
+			}
+			return stats, nil
+		}
+	}
+
+	if ref.Dataset == nil {
+		if err := ReadDataset(r, &ref); err != nil {
+			return nil, err
+		}
+	}
+	ds := &dataset.Dataset{}
+	if err := ds.Decode(ref.Dataset); err != nil {
+		return nil, err
+	}
+	return storeStats(r, ref.Path, ds)
+}
+
+// storeStats profiles the body of a dataset, storing the result if r is a
+// repo.ComponentStore
+func storeStats(r repo.Repo, path string, ds *dataset.Dataset) (*Stats, error) {
+	body, err := dsfs.LoadBody(r.Store(), ds)
+	if err != nil {
+		return nil, fmt.Errorf("error loading body: %s", err.Error())
+	}
+	defer body.Close()
+
+	stats, err := ComputeStats(ds.Structure, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if cs, ok := r.(repo.ComponentStore); ok && path != "" {
+		data, err := json.Marshal(stats)
+		if err != nil {
+			return nil, err
+		}
+		if err := cs.PutComponent(path, StatsComponent, data); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// statsProfiler accumulates stats for a stream of entries
+type statsProfiler struct {
+	entries int
+	titles  []string
+	cols    []*columnProfiler
+	byTitle map[string]*columnProfiler
+}
+
+func newStatsProfiler(titles []string) *statsProfiler {
+	return &statsProfiler{titles: titles, byTitle: map[string]*columnProfiler{}}
+}
+
+// column gets the profiler for a column, adding columns as they're seen.
+// columns added after the first entry are back-filled with nulls
+func (p *statsProfiler) column(title string) *columnProfiler {
+	if c, ok := p.byTitle[title]; ok {
+		return c
+	}
+	c := newColumnProfiler(title)
+	c.nulls = p.entries
+	p.byTitle[title] = c
+	p.cols = append(p.cols, c)
+	return c
+}
+
+func (p *statsProfiler) add(entry interface{}) {
+	seen := map[*columnProfiler]bool{}
+	switch v := entry.(type) {
+	case []interface{}:
+		for i, val := range v {
+			title := fmt.Sprintf("field_%d", i+1)
+			if i < len(p.titles) {
+				title = p.titles[i]
+			}
+			c := p.column(title)
+			c.add(val)
+			seen[c] = true
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			c := p.column(key)
+			c.add(v[key])
+			seen[c] = true
+		}
+	default:
+		c := p.column("value")
+		c.add(v)
+		seen[c] = true
+	}
+
+	// columns this entry doesn't have count as null
+	for _, c := range p.cols {
+		if !seen[c] {
+			c.add(nil)
+		}
+	}
+	p.entries++
+}
+
+func (p *statsProfiler) stats() *Stats {
+	s := &Stats{Entries: p.entries, Columns: make([]*ColumnStats, len(p.cols))}
+	for i, c := range p.cols {
+		s.Columns[i] = c.stats()
+	}
+	return s
+}
+
+// columnProfiler accumulates stats for a single column
+type columnProfiler struct {
+	title string
+	types map[string]int
+	count int
+	nulls int
+
+	// numeric values
+	nums      int
+	sum       float64
+	numMin    float64
+	numMax    float64
+	centroids []centroid
+
+	// string values
+	strs   int
+	strMin string
+	strMax string
+
+	distinct    *distinctCounter
+	frequencies *spaceSaving
+}
+
+func newColumnProfiler(title string) *columnProfiler {
+	return &columnProfiler{
+		title:       title,
+		types:       map[string]int{},
+		distinct:    &distinctCounter{hashes: map[uint64]bool{}},
+		frequencies: &spaceSaving{capacity: statsTopKCounters, counts: map[string]*valueCounter{}},
+	}
+}
+
+func (c *columnProfiler) add(v interface{}) {
+	if v == nil {
+		c.nulls++
+		return
+	}
+
+	typ := jsonValueType(v)
+	if typ == "" {
+		typ = "unknown"
+	}
+	c.types[typ]++
+	c.count++
+
+	key := statsValueKey(v)
+	c.distinct.add(key)
+	if typ != "object" && typ != "array" {
+		c.frequencies.add(key, v)
+	}
+
+	if f, ok := statsNumber(v); ok {
+		if c.nums == 0 || f < c.numMin {
+			c.numMin = f
+		}
+		if c.nums == 0 || f > c.numMax {
+			c.numMax = f
+		}
+		c.nums++
+		c.sum += f
+		c.addCentroid(f)
+	} else if s, ok := v.(string); ok {
+		if c.strs == 0 || s < c.strMin {
+			c.strMin = s
+		}
+		if c.strs == 0 || s > c.strMax {
+			c.strMax = s
+		}
+		c.strs++
+	}
+}
+
+func (c *columnProfiler) stats() *ColumnStats {
+	cs := &ColumnStats{
+		Title:    c.title,
+		Types:    c.types,
+		Count:    c.count,
+		Nulls:    c.nulls,
+		Distinct: c.distinct.estimate(),
+		TopK:     c.frequencies.top(StatsTopK),
+	}
+	if c.nums > 0 {
+		mean := c.sum / float64(c.nums)
+		cs.Min, cs.Max, cs.Mean = c.numMin, c.numMax, &mean
+		cs.Histogram = c.histogram()
+	} else if c.strs > 0 {
+		cs.Min, cs.Max = c.strMin, c.strMax
+	}
+	return cs
+}
+
+// centroid is a point in a streaming histogram, the mean of count values
+type centroid struct {
+	value float64
+	count int
+}
+
+// addCentroid adds a value to a streaming histogram (Ben-Haim & Tom-Tov),
+// merging the two closest centroids when there are too many
+func (c *columnProfiler) addCentroid(f float64) {
+	i := sort.Search(len(c.centroids), func(i int) bool { return c.centroids[i].value >= f })
+	if i < len(c.centroids) && c.centroids[i].value == f {
+		c.centroids[i].count++
+		return
+	}
+	c.centroids = append(c.centroids, centroid{})
+	copy(c.centroids[i+1:], c.centroids[i:])
+	c.centroids[i] = centroid{value: f, count: 1}
+
+	if len(c.centroids) <= statsCentroids {
+		return
+	}
+	closest := 0
+	for j := 1; j < len(c.centroids)-1; j++ {
+		if c.centroids[j+1].value-c.centroids[j].value < c.centroids[closest+1].value-c.centroids[closest].value {
+			closest = j
+		}
+	}
+	a, b := c.centroids[closest], c.centroids[closest+1]
+	count := a.count + b.count
+	c.centroids[closest] = centroid{
+		value: (a.value*float64(a.count) + b.value*float64(b.count)) / float64(count),
+		count: count,
+	}
+	c.centroids = append(c.centroids[:closest+1], c.centroids[closest+2:]...)
+}
+
+// histogram distributes centroids into equal-width bins between the column's
+// min & max. columns with a single value have a single bin
+func (c *columnProfiler) histogram() []HistogramBin {
+	if c.numMin == c.numMax {
+		return []HistogramBin{{Min: c.numMin, Max: c.numMax, Count: c.nums}}
+	}
+
+	width := (c.numMax - c.numMin) / StatsHistogramBins
+	bins := make([]HistogramBin, StatsHistogramBins)
+	for i := range bins {
+		bins[i].Min = c.numMin + float64(i)*width
+		bins[i].Max = c.numMin + float64(i+1)*width
+	}
+	bins[len(bins)-1].Max = c.numMax
+
+	for _, cen := range c.centroids {
+		i := int((cen.value - c.numMin) / width)
+		if i >= len(bins) {
+			i = len(bins) - 1
+		}
+		bins[i].Count += cen.count
+	}
+	return bins
+}
+
+// distinctCounter counts distinct values exactly until it's seen
+// statsDistinctSample values, then estimates the count from the k minimum
+// value hashes (KMV)
+type distinctCounter struct {
+	hashes map[uint64]bool
+	// max is the largest hash kept once the sample is full
+	max uint64
+}
+
+func (d *distinctCounter) add(key string) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := mixHash(h.Sum64())
+
+	if d.hashes[sum] {
+		return
+	}
+	if len(d.hashes) < statsDistinctSample {
+		d.hashes[sum] = true
+		if len(d.hashes) == statsDistinctSample {
+			d.max = d.maxHash()
+		}
+		return
+	}
+	if sum < d.max {
+		delete(d.hashes, d.max)
+		d.hashes[sum] = true
+		d.max = d.maxHash()
+	}
+}
+
+// mixHash spreads fnv hashes of similar keys across the full uint64 range,
+// which the KMV estimate depends on (murmur3's 64 bit finalizer)
+func mixHash(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func (d *distinctCounter) maxHash() (max uint64) {
+	for h := range d.hashes {
+		if h > max {
+			max = h
+		}
+	}
+	return max
+}
+
+func (d *distinctCounter) estimate() int {
+	if len(d.hashes) < statsDistinctSample {
+		return len(d.hashes)
+	}
+	return int((statsDistinctSample - 1) / (float64(d.max) / math.MaxUint64))
+}
+
+// spaceSaving finds the most frequent values in a stream using a fixed number
+// of counters (Metwally et al.). counts are exact while there are fewer
+// distinct values than counters
+type spaceSaving struct {
+	capacity int
+	counts   map[string]*valueCounter
+}
+
+type valueCounter struct {
+	value interface{}
+	count int
+}
+
+func (s *spaceSaving) add(key string, v interface{}) {
+	if vc, ok := s.counts[key]; ok {
+		vc.count++
+		return
+	}
+	if len(s.counts) < s.capacity {
+		s.counts[key] = &valueCounter{value: v, count: 1}
+		return
+	}
+
+	// replace the least frequent value, inheriting it's count
+	var minKey string
+	for k, vc := range s.counts {
+		if minKey == "" || vc.count < s.counts[minKey].count || (vc.count == s.counts[minKey].count && k < minKey) {
+			minKey = k
+		}
+	}
+	count := s.counts[minKey].count
+	delete(s.counts, minKey)
+	s.counts[key] = &valueCounter{value: v, count: count + 1}
+}
+
+func (s *spaceSaving) top(k int) []ValueCount {
+	keys := make([]string, 0, len(s.counts))
+	for key := range s.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := s.counts[keys[i]], s.counts[keys[j]]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > k {
+		keys = keys[:k]
+	}
+
+	top := make([]ValueCount, len(keys))
+	for i, key := range keys {
+		top[i] = ValueCount{Value: s.counts[key].value, Count: s.counts[key].count}
+	}
+	return top
+}
+
+// statsValueKey encodes a value as a string that's equal for equal values,
+// regardless of how numbers are typed
+func statsValueKey(v interface{}) string {
+	if f, ok := statsNumber(v); ok {
+		return fmt.Sprintf("n:%v", f)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%T:%v", v, v)
+	}
+	return string(data)
+}
+
+// statsNumber reads a numeric value as a float64
+func statsNumber(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
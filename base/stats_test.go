@@ -0,0 +1,184 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+	"github.com/qri-io/qri/repo"
+)
+
+func TestComputeStats(t *testing.T) {
+	sch := &jsonschema.RootSchema{}
+	if err := sch.UnmarshalJSON([]byte(`{"type":"array","items":{"type":"array","items":[{"title":"city","type":"string"},{"title":"pop","type":"integer"},{"title":"in_usa","type":"boolean"}]}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		st     *dataset.Structure
+		body   string
+		expect string
+	}{
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch},
+			`[["toronto",100,false],["new york",300,true],["chicago",200,true],["chicago",null,true]]`,
+			`{"entries":4,"columns":[
+				{"title":"city","types":{"string":4},"count":4,"nulls":0,"distinct":3,"min":"chicago","max":"toronto","topK":[{"value":"chicago","count":2},{"value":"new york","count":1},{"value":"toronto","count":1}]},
+				{"title":"pop","types":{"integer":3},"count":3,"nulls":1,"distinct":3,"min":100,"max":300,"mean":200,
+					"histogram":[{"min":100,"max":120,"count":1},{"min":120,"max":140,"count":0},{"min":140,"max":160,"count":0},{"min":160,"max":180,"count":0},{"min":180,"max":200,"count":0},{"min":200,"max":220,"count":1},{"min":220,"max":240,"count":0},{"min":240,"max":260,"count":0},{"min":260,"max":280,"count":0},{"min":280,"max":300,"count":1}],
+					"topK":[{"value":100,"count":1},{"value":200,"count":1},{"value":300,"count":1}]},
+				{"title":"in_usa","types":{"boolean":4},"count":4,"nulls":0,"distinct":2,"topK":[{"value":true,"count":3},{"value":false,"count":1}]}
+			]}`},
+		{&dataset.Structure{Format: dataset.JSONDataFormat},
+			`[{"a":1.5},{"a":1.5,"b":"x"},{"b":{"nested":true}}]`,
+			`{"entries":3,"columns":[
+				{"title":"a","types":{"number":2},"count":2,"nulls":1,"distinct":1,"min":1.5,"max":1.5,"mean":1.5,"histogram":[{"min":1.5,"max":1.5,"count":2}],"topK":[{"value":1.5,"count":2}]},
+				{"title":"b","types":{"object":1,"string":1},"count":2,"nulls":1,"distinct":2,"min":"x","max":"x","topK":[{"value":"x","count":1}]}
+			]}`},
+		{&dataset.Structure{Format: dataset.JSONDataFormat},
+			`["a","b","a"]`,
+			`{"entries":3,"columns":[
+				{"title":"value","types":{"string":3},"count":3,"nulls":0,"distinct":2,"min":"a","max":"b","topK":[{"value":"a","count":2},{"value":"b","count":1}]}
+			]}`},
+		{&dataset.Structure{Format: dataset.JSONDataFormat},
+			`[]`,
+			`{"entries":0,"columns":[]}`},
+	}
+
+	for i, c := range cases {
+		stats, err := ComputeStats(c.st, cafs.NewMemfileBytes("body.json", []byte(c.body)))
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		data, _ := json.Marshal(stats)
+		var got, expect interface{}
+		json.Unmarshal(data, &got)
+		if err := json.Unmarshal([]byte(c.expect), &expect); err != nil {
+			t.Fatalf("case %d invalid expectation: %s", i, err.Error())
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("case %d stats mismatch.\nexpected: %s\ngot:      %s", i, c.expect, string(data))
+		}
+	}
+
+	if _, err := ComputeStats(&dataset.Structure{Format: dataset.JSONDataFormat}, cafs.NewMemfileBytes("body.json", []byte(`[1,`))); err == nil {
+		t.Error("expected invalid body to error")
+	}
+}
+
+func TestComputeStatsNDJSON(t *testing.T) {
+	stats, err := ComputeStats(&dataset.Structure{Format: dataset.JSONDataFormat}, cafs.NewMemfileBytes("body.ndjson", []byte("{\"a\":1}\n{\"a\":2}\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 2 || len(stats.Columns) != 1 || stats.Columns[0].Count != 2 || *stats.Columns[0].Mean != 1.5 {
+		data, _ := json.Marshal(stats)
+		t.Errorf("unexpected stats: %s", string(data))
+	}
+}
+
+func TestDistinctCounter(t *testing.T) {
+	cases := []struct {
+		values int
+		// acceptable relative error of the estimate
+		tolerance float64
+	}{
+		{10, 0},
+		{statsDistinctSample - 1, 0},
+		{10000, 0.1},
+		{100000, 0.1},
+	}
+
+	for i, c := range cases {
+		d := &distinctCounter{hashes: map[uint64]bool{}}
+		// add every value twice, repeats shouldn't count
+		for j := 0; j < c.values*2; j++ {
+			d.add(fmt.Sprintf("value_%d", j%c.values))
+		}
+		got := d.estimate()
+		if err := math.Abs(float64(got-c.values)) / float64(c.values); err > c.tolerance {
+			t.Errorf("case %d estimate out of tolerance. expected: %d ± %.0f%%, got: %d", i, c.values, c.tolerance*100, got)
+		}
+	}
+}
+
+func TestSpaceSaving(t *testing.T) {
+	s := &spaceSaving{capacity: 10, counts: map[string]*valueCounter{}}
+	// a few heavy hitters in a stream of many unique values
+	for i := 0; i < 1000; i++ {
+		s.add(fmt.Sprintf("unique_%d", i), i)
+		if i%2 == 0 {
+			s.add("half", "half")
+		}
+		if i%4 == 0 {
+			s.add("quarter", "quarter")
+		}
+	}
+
+	top := s.top(2)
+	if len(top) != 2 || top[0].Value != "half" || top[1].Value != "quarter" {
+		t.Fatalf("expected heavy hitters half & quarter, got: %v", top)
+	}
+	// space-saving counts never underestimate
+	if top[0].Count < 500 || top[1].Count < 250 {
+		t.Errorf("expected counts of at least 500 & 250, got: %d & %d", top[0].Count, top[1].Count)
+	}
+}
+
+func TestStatsHistogram(t *testing.T) {
+	c := newColumnProfiler("x")
+	// more distinct values than centroids
+	for i := 0; i < 1000; i++ {
+		c.add(float64(i))
+	}
+	if len(c.centroids) > statsCentroids {
+		t.Errorf("expected at most %d centroids, got: %d", statsCentroids, len(c.centroids))
+	}
+
+	bins := c.histogram()
+	if len(bins) != StatsHistogramBins {
+		t.Fatalf("expected %d bins, got: %d", StatsHistogramBins, len(bins))
+	}
+	total := 0
+	for i, b := range bins {
+		total += b.Count
+		// values are uniform, each bin should hold roughly 100
+		if b.Count < 50 || b.Count > 150 {
+			t.Errorf("bin %d count out of range: %d", i, b.Count)
+		}
+	}
+	if total != 1000 {
+		t.Errorf("expected bins to count 1000 values, got: %d", total)
+	}
+	if bins[0].Min != 0 || bins[len(bins)-1].Max != 999 {
+		t.Errorf("expected bins to span 0-999, got: %v-%v", bins[0].Min, bins[len(bins)-1].Max)
+	}
+}
+
+func TestDatasetStats(t *testing.T) {
+	r := newTestRepo(t)
+	ref := addCitiesDataset(t, r)
+
+	stats, err := DatasetStats(r, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 5 {
+		t.Errorf("expected 5 entries, got: %d", stats.Entries)
+	}
+	if len(stats.Columns) != 4 || stats.Columns[0].Title != "city" {
+		data, _ := json.Marshal(stats)
+		t.Errorf("unexpected columns: %s", string(data))
+	}
+
+	// stats are computed on save, reading them back shouldn't need the body
+	cs := r.(repo.ComponentStore)
+	if _, err := cs.Component(ref.Path, StatsComponent); err != nil {
+		t.Errorf("expected stats to be stored on save: %s", err.Error())
+	}
+}
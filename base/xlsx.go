@@ -0,0 +1,629 @@
+package base
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+// XLSXBody is a sheet of an XLSX workbook converted to a JSON body. Bodies
+// are never stored as XLSX. Structure describes the converted body, with a
+// schema inferred from the types of the sheet's cells
+type XLSXBody struct {
+	cafs.File
+	Structure *dataset.Structure
+}
+
+// NewXLSXBody reads a sheet from an XLSX workbook, converting it to a JSON
+// array of rows. Options are read from cfg:
+// * sheetName: the sheet to read, defaults to the first sheet in the workbook
+// * headerRow: if the first row holds column titles. If unset, the first row
+// is treated as a header if all of it's cells are strings and later rows
+// contain other types
+func NewXLSXBody(filename string, data []byte, cfg map[string]interface{}) (*XLSXBody, error) {
+	sheetName, _ := cfg["sheetName"].(string)
+	rows, err := ReadXLSXSheet(data, sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	headerRow, ok := cfg["headerRow"].(bool)
+	if !ok {
+		headerRow = xlsxDetectHeader(rows)
+	}
+
+	var titles []interface{}
+	if headerRow && len(rows) > 0 {
+		titles = rows[0]
+		rows = rows[1:]
+	}
+
+	sch, err := xlsxSchema(titles, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("%s.json", strings.TrimSuffix(path.Base(filename), path.Ext(filename)))
+	return &XLSXBody{
+		File: cafs.NewMemfileBytes(name, body),
+		Structure: &dataset.Structure{
+			Format: dataset.JSONDataFormat,
+			Schema: sch,
+		},
+	}, nil
+}
+
+// ReadXLSXSheet reads the rows of a sheet from XLSX workbook data. Cell values
+// are typed: numbers become int64 or float64, booleans become bool, and empty
+// cells are nil. Rows & cells are placed by their references, rows that are
+// skipped are empty. Rows are padded with nil to the width of the widest row.
+// If sheetName is empty the first sheet in the workbook is read
+func ReadXLSXSheet(data []byte, sheetName string) ([][]interface{}, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading xlsx: %s", err.Error())
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	wb := xlsxWorkbook{}
+	if err := xlsxDecodePart(files, "xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+	rels := xlsxRelationships{}
+	if err := xlsxDecodePart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	if len(wb.Sheets) == 0 {
+		return nil, fmt.Errorf("reading xlsx: workbook has no sheets")
+	}
+
+	var rid string
+	if sheetName == "" {
+		rid = wb.Sheets[0].RID
+	} else {
+		names := make([]string, len(wb.Sheets))
+		for i, s := range wb.Sheets {
+			names[i] = s.Name
+			if s.Name == sheetName {
+				rid = s.RID
+			}
+		}
+		if rid == "" {
+			return nil, fmt.Errorf("reading xlsx: sheet '%s' not found. sheets are: %s", sheetName, strings.Join(names, ", "))
+		}
+	}
+
+	target := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == rid {
+			target = rel.Target
+		}
+	}
+	if strings.HasPrefix(target, "/") {
+		target = target[1:]
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	// workbooks without any text cells don't have a shared strings part
+	sst := xlsxSharedStrings{}
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := xlsxDecodePart(files, "xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet := xlsxWorksheet{}
+	if err := xlsxDecodePart(files, target, &sheet); err != nil {
+		return nil, err
+	}
+
+	var (
+		rows  [][]interface{}
+		width int
+	)
+	for _, r := range sheet.Rows {
+		// rows without a reference follow the previous row
+		if r.Ref != "" {
+			n, err := strconv.Atoi(r.Ref)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("reading xlsx: invalid row reference '%s'", r.Ref)
+			}
+			if n-1 > xlsxMaxRow {
+				return nil, fmt.Errorf("reading xlsx: row %d is past the last row, %d", n, xlsxMaxRow+1)
+			}
+			if n-1 < len(rows) {
+				return nil, fmt.Errorf("reading xlsx: row %d is out of order", n)
+			}
+			for len(rows) < n-1 {
+				rows = append(rows, nil)
+			}
+		}
+		if len(rows) > xlsxMaxRow {
+			return nil, fmt.Errorf("reading xlsx: sheet has more than %d rows", xlsxMaxRow+1)
+		}
+
+		var row []interface{}
+		// cells without a reference follow the previous cell
+		col := -1
+		for _, c := range r.Cells {
+			col++
+			if c.Ref != "" {
+				if col, err = xlsxColumnIndex(c.Ref); err != nil {
+					return nil, err
+				}
+			} else if col > xlsxMaxColumn {
+				return nil, fmt.Errorf("reading xlsx: row %d has cells past the last column, XFD", len(rows)+1)
+			}
+			for len(row) <= col {
+				row = append(row, nil)
+			}
+			if row[col], err = c.value(sst); err != nil {
+				return nil, fmt.Errorf("reading xlsx cell %s%d: %s", xlsxColumnName(col), len(rows)+1, err.Error())
+			}
+		}
+		if len(row) > width {
+			width = len(row)
+		}
+		rows = append(rows, row)
+	}
+
+	// drop trailing empty rows, which spreadsheets often carry formatting in
+	for len(rows) > 0 && xlsxEmptyRow(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	if len(rows)*width > xlsxMaxCells {
+		return nil, fmt.Errorf("reading xlsx: sheet is too large, %d rows of %d columns is more than %d cells", len(rows), width, xlsxMaxCells)
+	}
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, nil)
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+func xlsxDecodePart(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("reading xlsx: missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("reading xlsx: %s", err.Error())
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("reading xlsx %s: %s", name, err.Error())
+	}
+	return nil
+}
+
+func xlsxEmptyRow(row []interface{}) bool {
+	for _, v := range row {
+		if v != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// xlsxDetectHeader guesses if the first row of a sheet holds column titles
+func xlsxDetectHeader(rows [][]interface{}) bool {
+	if len(rows) < 2 {
+		return false
+	}
+	for _, v := range rows[0] {
+		if _, ok := v.(string); !ok {
+			return false
+		}
+	}
+	for _, row := range rows[1:] {
+		for _, v := range row {
+			if _, ok := v.(string); !ok && v != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// xlsxSchema infers a schema for an array of rows. each column is typed by
+// the cells it contains, integer columns that also contain decimals are
+// numbers, and columns that mix other types are left untyped
+func xlsxSchema(titles []interface{}, rows [][]interface{}) (*jsonschema.RootSchema, error) {
+	width := len(titles)
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	cols := make([]interface{}, width)
+	for i := range cols {
+		col := map[string]interface{}{}
+		if i < len(titles) && titles[i] != nil {
+			col["title"] = fmt.Sprintf("%v", titles[i])
+		} else {
+			col["title"] = fmt.Sprintf("field_%d", i+1)
+		}
+
+		colType := ""
+		for _, row := range rows {
+			if i >= len(row) || row[i] == nil {
+				continue
+			}
+			t := xlsxCellType(row[i])
+			switch {
+			case colType == "":
+				colType = t
+			case colType == t:
+			case colType == "integer" && t == "number" || colType == "number" && t == "integer":
+				colType = "number"
+			default:
+				colType = "mixed"
+			}
+		}
+		if colType != "" && colType != "mixed" {
+			col["type"] = colType
+		}
+		cols[i] = col
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":  "array",
+			"items": cols,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	rs := &jsonschema.RootSchema{}
+	if err := rs.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func xlsxCellType(v interface{}) string {
+	switch v.(type) {
+	case int64:
+		return "integer"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// xlsxMaxColumn is the zero-based index of the last column a worksheet can
+// have, XFD
+const xlsxMaxColumn = 16383
+
+// xlsxMaxRow is the zero-based index of the last row a worksheet can have
+const xlsxMaxRow = 1048575
+
+// xlsxMaxCells is the most cells a sheet read into memory can have once
+// rows are padded to the same width. sparse sheets can reference far away
+// cells without being large themselves
+var xlsxMaxCells = 1 << 24
+
+// xlsxColumnIndex gives the zero-based column of a cell reference like "AB12"
+func xlsxColumnIndex(ref string) (int, error) {
+	col := 0
+	for i, r := range ref {
+		if r >= 'A' && r <= 'Z' {
+			if col = col*26 + int(r-'A') + 1; col-1 > xlsxMaxColumn {
+				return 0, fmt.Errorf("reading xlsx: cell reference '%s' is past the last column, XFD", ref)
+			}
+			continue
+		}
+		if i == 0 {
+			break
+		}
+		return col - 1, nil
+	}
+	return 0, fmt.Errorf("reading xlsx: invalid cell reference '%s'", ref)
+}
+
+// xlsxColumnName is the inverse of xlsxColumnIndex, giving the letters of a
+// zero-based column
+func xlsxColumnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is rich or plain text, used by shared & inline strings
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	buf := &bytes.Buffer{}
+	for _, r := range t.Runs {
+		buf.WriteString(r.T)
+	}
+	return buf.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Ref   string     `xml:"r,attr"`
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+func (c xlsxCell) value(sst xlsxSharedStrings) (interface{}, error) {
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(c.Value)
+		if err != nil || i < 0 || i >= len(sst.Items) {
+			return nil, fmt.Errorf("invalid shared string index '%s'", c.Value)
+		}
+		return sst.Items[i].String(), nil
+	case "inlineStr":
+		return c.Inline.String(), nil
+	case "str", "e", "d":
+		return c.Value, nil
+	case "b":
+		return c.Value == "1", nil
+	default:
+		if c.Value == "" {
+			return nil, nil
+		}
+		if i, err := strconv.ParseInt(c.Value, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(c.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", c.Value)
+		}
+		return f, nil
+	}
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbookXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// XLSXWriter implements the dsio.EntryWriter interface, writing entries as
+// rows of a single-sheet XLSX workbook. The first row is always a header:
+// column titles from the schema for array entries, sorted keys for object
+// entries, prefixed with a "key" column when entries are keyed. Rows are
+// streamed to the underlying writer as they're written
+type XLSXWriter struct {
+	st     *dataset.Structure
+	zw     *zip.Writer
+	sheet  io.Writer
+	row    int
+	fields []string
+}
+
+// NewXLSXWriter creates an XLSX entry writer
+func NewXLSXWriter(st *dataset.Structure, w io.Writer) (*XLSXWriter, error) {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, data string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbookXML},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.data); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xlsxSheetStart); err != nil {
+		return nil, err
+	}
+	return &XLSXWriter{st: st, zw: zw, sheet: sheet}, nil
+}
+
+// Structure gives the structure being written
+func (w *XLSXWriter) Structure() *dataset.Structure {
+	return w.st
+}
+
+// WriteEntry writes one entry as a row
+func (w *XLSXWriter) WriteEntry(ent dsio.Entry) error {
+	if w.row == 0 {
+		var header []string
+		switch v := ent.Value.(type) {
+		case map[string]interface{}:
+			for key := range v {
+				w.fields = append(w.fields, key)
+			}
+			sort.Strings(w.fields)
+			header = w.fields
+		case []interface{}:
+			header = schemaColumnTitles(w.st, len(v))
+		default:
+			header = []string{"value"}
+		}
+		if ent.Key != "" {
+			header = append([]string{"key"}, header...)
+		}
+		if err := w.writeHeader(header); err != nil {
+			return err
+		}
+	}
+
+	var cells []interface{}
+	switch v := ent.Value.(type) {
+	case []interface{}:
+		cells = v
+	case map[string]interface{}:
+		if w.fields == nil {
+			// objects that follow other types of entry have no columns to map
+			// keys to, and are written as a single JSON cell
+			cells = []interface{}{v}
+			break
+		}
+		cells = make([]interface{}, len(w.fields))
+		for i, f := range w.fields {
+			cells[i] = v[f]
+		}
+	default:
+		cells = []interface{}{v}
+	}
+	if ent.Key != "" {
+		cells = append([]interface{}{ent.Key}, cells...)
+	}
+	return w.writeRow(cells)
+}
+
+func (w *XLSXWriter) writeHeader(titles []string) error {
+	cells := make([]interface{}, len(titles))
+	for i, t := range titles {
+		cells[i] = t
+	}
+	return w.writeRow(cells)
+}
+
+func (w *XLSXWriter) writeRow(cells []interface{}) error {
+	w.row++
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<row r="%d">`, w.row)
+	for i, v := range cells {
+		ref := xlsxColumnName(i) + strconv.Itoa(w.row)
+		switch x := v.(type) {
+		case nil:
+			continue
+		case bool:
+			b := 0
+			if x {
+				b = 1
+			}
+			fmt.Fprintf(buf, `<c r="%s" t="b"><v>%d</v></c>`, ref, b)
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			fmt.Fprintf(buf, `<c r="%s"><v>%d</v></c>`, ref, x)
+		case float32, float64:
+			fmt.Fprintf(buf, `<c r="%s"><v>%v</v></c>`, ref, x)
+		case string:
+			w.writeString(buf, ref, x)
+		default:
+			// nested values are written as JSON text
+			data, err := json.Marshal(x)
+			if err != nil {
+				return err
+			}
+			w.writeString(buf, ref, string(data))
+		}
+	}
+	buf.WriteString("</row>")
+	_, err := w.sheet.Write(buf.Bytes())
+	return err
+}
+
+func (w *XLSXWriter) writeString(buf *bytes.Buffer, ref, s string) {
+	fmt.Fprintf(buf, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+	xml.EscapeText(buf, []byte(s))
+	buf.WriteString("</t></is></c>")
+}
+
+// Close finishes the sheet & workbook. Writers that haven't written any
+// entries write a header of the schema's column titles
+func (w *XLSXWriter) Close() error {
+	if w.row == 0 {
+		if titles := schemaColumnTitles(w.st, 0); len(titles) > 0 {
+			if err := w.writeHeader(titles); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := io.WriteString(w.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// readAllXLSX reads an XLSX body from r, closing it if it's a closer
+func readAllXLSX(r io.Reader) ([]byte, error) {
+	if rc, ok := r.(io.Closer); ok {
+		defer rc.Close()
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("body file: %s", err.Error())
+	}
+	return data, nil
+}
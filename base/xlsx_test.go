@@ -0,0 +1,258 @@
+package base
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+// testWorkbook builds an xlsx workbook with three sheets, shared & rich text
+// strings, sparse rows & cells and an absolute sheet target, the way
+// spreadsheet programs write them
+func testWorkbook(t *testing.T) []byte {
+	parts := map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="notes" sheetId="1" r:id="rId2"/><sheet name="cities" sheetId="2" r:id="rId1"/><sheet name="sparse" sheetId="3" r:id="rId3"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet3.xml"/>
+</Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>city</t></si><si><t>pop</t></si><si><t>avg_age</t></si><si><t>in_usa</t></si>
+<si><r><t>toronto</t></r></si><si><r><t>new </t></r><r><t>york</t></r></si><si><t>hello</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>6</v></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c></row>
+<row r="2"><c r="A2" t="s"><v>4</v></c><c r="B2"><v>40000000</v></c><c r="C2"><v>55.5</v></c><c r="D2" t="b"><v>0</v></c></row>
+<row r="3"><c r="A3" t="s"><v>5</v></c><c r="B3"><v>8500000</v></c><c r="D3" t="b"><v>1</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><t>chicago</t></is></c><c r="B4"><v>300000</v></c><c r="C4"><v>44</v></c><c r="D4" t="b"><v>1</v></c></row>
+<row r="5"></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/sheet3.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1"><v>1</v></c><c><v>2</v></c><c r="D1"><v>4</v></c><c><v>5</v></c></row>
+<row r="3"><c><v>6</v></c></row>
+<row><c r="B4"><v>7</v></c></row>
+</sheetData></worksheet>`,
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, data := range parts {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSXSheet(t *testing.T) {
+	data := testWorkbook(t)
+
+	cases := []struct {
+		sheet, expect, err string
+	}{
+		{"", `[["hello"]]`, ""},
+		{"cities", `[["city","pop","avg_age","in_usa"],["toronto",40000000,55.5,false],["new york",8500000,null,true],["chicago",300000,44,true]]`, ""},
+		{"sparse", `[[1,2,null,4,5],[null,null,null,null,null],[6,null,null,null,null],[null,7,null,null,null]]`, ""},
+		{"nope", "", "reading xlsx: sheet 'nope' not found. sheets are: notes, cities, sparse"},
+	}
+
+	for i, c := range cases {
+		rows, err := ReadXLSXSheet(data, c.sheet)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if c.err != "" {
+			continue
+		}
+		got, _ := json.Marshal(rows)
+		if string(got) != c.expect {
+			t.Errorf("case %d rows mismatch.\nexpected: %s\ngot:      %s", i, c.expect, string(got))
+		}
+	}
+
+	if _, err := ReadXLSXSheet([]byte("not a workbook"), ""); err == nil {
+		t.Error("expected reading invalid data to error")
+	}
+
+	bad := []struct {
+		sheetData, err string
+	}{
+		{`<row r="2"></row><row r="1"></row>`, "reading xlsx: row 1 is out of order"},
+		{`<row r="1048577"></row>`, "reading xlsx: row 1048577 is past the last row, 1048576"},
+		{`<row r="1"><c r="A1"><v>1</v></c></row><row r="1048576"><c r="XFD1048576"><v>1</v></c></row>`, "reading xlsx: sheet is too large, 1048576 rows of 16384 columns is more than 16777216 cells"},
+	}
+	for i, c := range bad {
+		_, err := ReadXLSXSheet(testSheetWorkbook(t, c.sheetData), "")
+		if err == nil || err.Error() != c.err {
+			t.Errorf("bad case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+		}
+	}
+}
+
+// testSheetWorkbook builds a single-sheet workbook from sheet data xml
+func testSheetWorkbook(t *testing.T, sheetData string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, data := range map[string]string{
+		"[Content_Types].xml":        xlsxContentTypes,
+		"_rels/.rels":                xlsxRootRels,
+		"xl/workbook.xml":            xlsxWorkbookXML,
+		"xl/_rels/workbook.xml.rels": xlsxWorkbookRels,
+		"xl/worksheets/sheet1.xml":   xlsxSheetStart + sheetData + xlsxSheetEnd,
+	} {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewXLSXBody(t *testing.T) {
+	data := testWorkbook(t)
+
+	cases := []struct {
+		cfg          map[string]interface{}
+		body, schema string
+	}{
+		{map[string]interface{}{"sheetName": "cities"},
+			`[["toronto",40000000,55.5,false],["new york",8500000,null,true],["chicago",300000,44,true]]`,
+			`{"items":{"items":[{"title":"city","type":"string"},{"title":"pop","type":"integer"},{"title":"avg_age","type":"number"},{"title":"in_usa","type":"boolean"}],"type":"array"},"type":"array"}`},
+		{map[string]interface{}{"sheetName": "cities", "headerRow": false},
+			`[["city","pop","avg_age","in_usa"],["toronto",40000000,55.5,false],["new york",8500000,null,true],["chicago",300000,44,true]]`,
+			`{"items":{"items":[{"title":"field_1","type":"string"},{"title":"field_2"},{"title":"field_3"},{"title":"field_4"}],"type":"array"},"type":"array"}`},
+		{nil,
+			`[["hello"]]`,
+			`{"items":{"items":[{"title":"field_1","type":"string"}],"type":"array"},"type":"array"}`},
+	}
+
+	for i, c := range cases {
+		body, err := NewXLSXBody("data.xlsx", data, c.cfg)
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		if body.FileName() != "data.json" {
+			t.Errorf("case %d expected filename data.json, got: %s", i, body.FileName())
+		}
+		if body.Structure.Format != dataset.JSONDataFormat {
+			t.Errorf("case %d expected json format, got: %s", i, body.Structure.Format)
+		}
+		if got, _ := ioutil.ReadAll(body); string(got) != c.body {
+			t.Errorf("case %d body mismatch.\nexpected: %s\ngot:      %s", i, c.body, string(got))
+		}
+		sch, _ := body.Structure.Schema.MarshalJSON()
+		var got, expect interface{}
+		json.Unmarshal(sch, &got)
+		json.Unmarshal([]byte(c.schema), &expect)
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("case %d schema mismatch.\nexpected: %s\ngot:      %s", i, c.schema, string(sch))
+		}
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	sch := &jsonschema.RootSchema{}
+	if err := sch.UnmarshalJSON([]byte(`{"type":"array","items":{"type":"array","items":[{"title":"city"},{"title":"pop"}]}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		st      *dataset.Structure
+		entries []dsio.Entry
+		expect  string
+	}{
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch},
+			[]dsio.Entry{
+				{Index: 0, Value: []interface{}{"toronto", 40000000}},
+				{Index: 1, Value: []interface{}{"<new york & co>", 8500000.5, true, nil, map[string]interface{}{"a": 1}}},
+			},
+			`[["city","pop",null,null,null],["toronto",40000000,null,null,null],["<new york & co>",8500000.5,true,null,"{\"a\":1}"]]`},
+		{&dataset.Structure{Format: dataset.JSONDataFormat},
+			[]dsio.Entry{
+				{Key: "b", Value: map[string]interface{}{"z": 1, "y": "two"}},
+				{Key: "a", Value: map[string]interface{}{"z": false}},
+			},
+			`[["key","y","z"],["b","two",1],["a",null,false]]`},
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch},
+			nil,
+			`[["city","pop"]]`},
+	}
+
+	for i, c := range cases {
+		buf := &bytes.Buffer{}
+		w, err := NewXLSXWriter(c.st, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ent := range c.entries {
+			if err := w.WriteEntry(ent); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := ReadXLSXSheet(buf.Bytes(), "Sheet1")
+		if err != nil {
+			t.Errorf("case %d error reading written workbook: %s", i, err.Error())
+			continue
+		}
+		got := &bytes.Buffer{}
+		enc := json.NewEncoder(got)
+		enc.SetEscapeHTML(false)
+		enc.Encode(rows)
+		if strings.TrimSpace(got.String()) != c.expect {
+			t.Errorf("case %d rows mismatch.\nexpected: %s\ngot:      %s", i, c.expect, got.String())
+		}
+	}
+}
+
+func TestXLSXColumnNames(t *testing.T) {
+	for i, name := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumnName(i); got != name {
+			t.Errorf("column %d name mismatch. expected: %s, got: %s", i, name, got)
+		}
+		if got, err := xlsxColumnIndex(fmt.Sprintf("%s12", name)); err != nil || got != i {
+			t.Errorf("column %s index mismatch. expected: %d, got: %d (%v)", name, i, got, err)
+		}
+	}
+	if _, err := xlsxColumnIndex("12"); err == nil {
+		t.Error("expected cell reference without a column to error")
+	}
+	if got, err := xlsxColumnIndex("XFD1"); err != nil || got != xlsxMaxColumn {
+		t.Errorf("expected XFD to be the last column, got: %d (%v)", got, err)
+	}
+	if _, err := xlsxColumnIndex("XFE1"); err == nil {
+		t.Error("expected cell reference past XFD to error")
+	}
+}
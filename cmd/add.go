@@ -33,17 +33,27 @@ the name of the peer that originally added the dataset. You must have
 		},
 	}
 
+	cmd.Flags().BoolVar(&o.Async, "async", false, "add in the background of a running qri connect, check on it with qri job")
+
 	return cmd
 }
 
 // AddOptions encapsulates state for the add command
 type AddOptions struct {
 	ioes.IOStreams
+	Async bool
+
 	DatasetRequests *lib.DatasetRequests
+	JobRequests     *lib.JobRequests
 }
 
 // Complete adds any missing configuration that can only be added just before calling Run
 func (o *AddOptions) Complete(f Factory) (err error) {
+	if o.Async {
+		if o.JobRequests, err = asyncJobRequests(f); err != nil {
+			return
+		}
+	}
 	if o.DatasetRequests, err = f.DatasetRequests(); err != nil {
 		return
 	}
@@ -61,6 +71,14 @@ func (o *AddOptions) Run(args []string) error {
 			return err
 		}
 
+		if o.Async {
+			o.StopSpinner()
+			if err = submitJob(o.Out, o.JobRequests, &lib.JobParams{Add: &ref}); err != nil {
+				return err
+			}
+			continue
+		}
+
 		res := repo.DatasetRef{}
 		if err = o.DatasetRequests.Add(&ref, &res); err != nil {
 			return err
@@ -3,11 +3,13 @@ package cmd
 import (
 	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/repo"
 	"github.com/spf13/cobra"
@@ -28,7 +30,19 @@ func NewBodyCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
   $ qri body --offset 50 me/dataset_name
 
   save the body as csv to file
-  $ qri body -o new_file.csv -f csv me/dataset_name`,
+  $ qri body -o new_file.csv -f csv me/dataset_name
+
+  save the whole body as an excel workbook
+  $ qri body --all -o new_file.xlsx -f xlsx me/dataset_name
+
+  stream the whole body as newline-delimited json:
+  $ qri body --all -f ndjson me/dataset_name
+
+  save the whole body as a parquet file
+  $ qri body --all -o new_file.parquet -f parquet me/dataset_name
+
+  preview two columns of a dataset published by a peer:
+  $ qri body --limit 10 --fields name,population peer/dataset_name`,
 		Annotations: map[string]string{
 			"group": "dataset",
 		},
@@ -42,9 +56,10 @@ func NewBodyCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
 
 	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "path to write to, default is stdout")
 	cmd.Flags().BoolVarP(&o.All, "all", "a", false, "read all dataset entries (overrides limit, offest)")
-	cmd.Flags().StringVarP(&o.Format, "format", "f", "json", "format to export. one of [json,csv,cbor]")
+	cmd.Flags().StringVarP(&o.Format, "format", "f", "json", "format to export. one of [json,csv,cbor,ndjson,xlsx,parquet]")
 	cmd.Flags().IntVarP(&o.Limit, "limit", "l", 50, "max number of records to read")
 	cmd.Flags().IntVarP(&o.Offset, "offset", "s", 0, "number of records to skip")
+	cmd.Flags().StringSliceVar(&o.Fields, "fields", nil, "comma-separated list of fields to include in each entry")
 
 	return cmd
 }
@@ -59,8 +74,8 @@ type BodyOptions struct {
 	Offset int
 	All    bool
 	Ref    string
+	Fields []string
 
-	UsingRPC        bool
 	DatasetRequests *lib.DatasetRequests
 }
 
@@ -69,7 +84,6 @@ func (o *BodyOptions) Complete(f Factory, args []string) (err error) {
 	if len(args) > 0 {
 		o.Ref = args[0]
 	}
-	o.UsingRPC = f.RPC() != nil
 	o.DatasetRequests, err = f.DatasetRequests()
 	return err
 }
@@ -79,10 +93,6 @@ func (o *BodyOptions) Run() error {
 	o.StartSpinner()
 	defer o.StopSpinner()
 
-	if o.UsingRPC {
-		return usingRPCError("body")
-	}
-
 	dsr, err := repo.ParseDatasetRef(o.Ref)
 	if err != nil && err != repo.ErrEmptyRef {
 		return err
@@ -97,17 +107,45 @@ func (o *BodyOptions) Run() error {
 	}
 
 	ds := res.Dataset
-	df, err := dataset.ParseDataFormatString(o.Format)
+	format, encoding := o.Format, ""
+	switch format {
+	case base.XLSXEncoding, base.ParquetEncoding:
+		if o.Output == "" {
+			return fmt.Errorf("%s bodies must be written to a file, use --output", format)
+		}
+		format, encoding = dataset.JSONDataFormat.String(), o.Format
+	case base.NDJSONEncoding:
+		format, encoding = dataset.JSONDataFormat.String(), o.Format
+	}
+	df, err := dataset.ParseDataFormatString(format)
 	if err != nil {
 		return err
 	}
 
 	p := &lib.LookupParams{
-		Format: df,
-		Path:   ds.Path,
-		Limit:  o.Limit,
-		Offset: o.Offset,
-		All:    o.All,
+		Format:   df,
+		Path:     ds.Path,
+		Limit:    o.Limit,
+		Offset:   o.Offset,
+		All:      o.All,
+		Ref:      *res,
+		Fields:   o.Fields,
+		Encoding: encoding,
+	}
+
+	if encoding != "" {
+		// body encodings are streamed as they're read
+		var out io.Writer = o.Out
+		if o.Output != "" {
+			f, err := os.Create(o.Output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		_, err = o.DatasetRequests.LookupBodyTo(out, p)
+		return err
 	}
 
 	result := &lib.LookupResult{}
@@ -172,7 +172,7 @@ func TestCommandsIntegration(t *testing.T) {
 		"qri log me/movies",
 		"qri diff me/movies me/movies2 -d=detail",
 		fmt.Sprintf("qri export -o=%s me/movies --zip", path),
-		//fmt.Sprintf("qri export -o=%s --format=cbor --body-format=json me/movies", path),
+		fmt.Sprintf("qri export -o=%s --format=json --body-format=json --history me/movies", path),
 		"qri publish me/movies",
 		"qri ls -p",
 		"qri publish --unpublish me/movies",
@@ -5,11 +5,13 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/qri-io/ioes"
 	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/lib"
+	"github.com/qri-io/qri/repo"
 	"github.com/spf13/cobra"
 )
 
@@ -105,12 +107,77 @@ field checkout: https://github.com/qri-io/qri/blob/master/config/readme.md`,
 		},
 	}
 
+	token := &cobra.Command{
+		Use:   "token",
+		Short: "Manage API access tokens",
+		Long: `API tokens authenticate requests to the qri HTTP API when api.auth is
+enabled. Requests carry a token in an "Authorization: Bearer <token>" header.
+
+Each token has a scope that limits what it can do:
+  read    GET requests only
+  write   read, plus saving, updating, publishing & removing datasets
+  admin   write, plus managing peers, your profile & the message queue`,
+		Example: `  # create a read-only token for a dashboard
+  qri config token create --name dashboard --scope read
+
+  # list tokens
+  qri config token list
+
+  # revoke a token
+  qri config token delete 3f9a1c2b7d4e`,
+	}
+
+	tokenCreate := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API token",
+		Long: `create makes a new API token & prints it. The token is only shown once,
+qri stores a hash of the token, not the token itself.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f); err != nil {
+				return err
+			}
+			return o.CreateToken()
+		},
+	}
+
+	tokenList := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List API tokens",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f); err != nil {
+				return err
+			}
+			return o.ListTokens()
+		},
+	}
+
+	tokenDelete := &cobra.Command{
+		Use:     "delete [ID]",
+		Aliases: []string{"rm", "revoke"},
+		Short:   "Revoke an API token",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f); err != nil {
+				return err
+			}
+			return o.DeleteToken(args[0])
+		},
+	}
+
+	tokenCreate.Flags().StringVar(&o.TokenName, "name", "", "description of what the token is for")
+	tokenCreate.Flags().StringVar(&o.TokenScope, "scope", "read", "access level of the token. one of: read, write, admin")
+	token.AddCommand(tokenCreate, tokenList, tokenDelete)
+
 	get.Flags().BoolVar(&o.WithPrivateKeys, "with-private-keys", false, "include private keys in export")
 	get.Flags().BoolVarP(&o.Concise, "concise", "c", false, "print output without indentation, only applies to json format")
 	get.Flags().StringVarP(&o.Format, "format", "f", "yaml", "data format to export. either json or yaml")
 	get.Flags().StringVarP(&o.Output, "output", "o", "", "path to export to")
 	cmd.AddCommand(get)
 	cmd.AddCommand(set)
+	cmd.AddCommand(token)
 
 	return cmd
 }
@@ -123,13 +190,19 @@ type ConfigOptions struct {
 	WithPrivateKeys bool
 	Concise         bool
 	Output          string
+	TokenName       string
+	TokenScope      string
 
 	ProfileRequests *lib.ProfileRequests
+	TokenRequests   *lib.TokenRequests
 }
 
 // Complete adds any missing configuration that can only be added just before calling Run
 func (o *ConfigOptions) Complete(f Factory) (err error) {
-	o.ProfileRequests, err = f.ProfileRequests()
+	if o.ProfileRequests, err = f.ProfileRequests(); err != nil {
+		return
+	}
+	o.TokenRequests, err = f.TokenRequests()
 	return
 }
 
@@ -219,6 +292,54 @@ func (o *ConfigOptions) Set(args []string) (err error) {
 	return nil
 }
 
+// CreateToken makes a new API token
+func (o *ConfigOptions) CreateToken() error {
+	p := &lib.CreateTokenParams{
+		Name:  o.TokenName,
+		Scope: o.TokenScope,
+	}
+	res := &lib.CreateTokenResult{}
+	if err := o.TokenRequests.Create(p, res); err != nil {
+		return err
+	}
+
+	printSuccess(o.Out, "created %s token %s", res.Token.Scope, res.Token.ID)
+	printWarning(o.Out, "this token won't be shown again, store it somewhere safe:")
+	fmt.Fprintln(o.Out, res.Secret)
+	if lib.Config != nil && lib.Config.API != nil && !lib.Config.API.Auth {
+		printInfo(o.Out, "api auth is disabled. enable it with: qri config set api.auth true")
+	}
+	return nil
+}
+
+// ListTokens prints all API tokens
+func (o *ConfigOptions) ListTokens() error {
+	toks := []repo.Token{}
+	if err := o.TokenRequests.List(&lib.ListParams{}, &toks); err != nil {
+		return err
+	}
+
+	if len(toks) == 0 {
+		printInfo(o.Out, "no API tokens. create one with: qri config token create")
+		return nil
+	}
+	for i, t := range toks {
+		printSuccess(o.Out, "%d.\t%s\t%s\t%s", i+1, t.ID, t.Scope, t.Name)
+		printInfo(o.Out, "\tcreated %s", t.Created.Format(time.RFC822))
+	}
+	return nil
+}
+
+// DeleteToken revokes an API token
+func (o *ConfigOptions) DeleteToken(id string) error {
+	done := false
+	if err := o.TokenRequests.Delete(&id, &done); err != nil {
+		return err
+	}
+	printSuccess(o.Out, "revoked token %s", id)
+	return nil
+}
+
 func setPhotoPath(req *lib.ProfileRequests, proppath, filepath string) error {
 	f, err := loadFileIfPath(filepath)
 	if err != nil {
@@ -1,7 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/qri-io/ioes"
 	"github.com/qri-io/qri/api"
@@ -40,7 +45,7 @@ peers & swapping data.`,
 	}
 
 	cmd.Flags().IntVarP(&o.APIPort, "api-port", "", 0, "port to start api on")
-	cmd.Flags().IntVarP(&o.RPCPort, "rpc-port", "", 0, "port to start rpc listener on")
+	cmd.Flags().IntVarP(&o.RPCPort, "rpc-port", "", 0, "port to start rpc listener on, instead of the rpc socket")
 	cmd.Flags().IntVarP(&o.WebappPort, "webapp-port", "", 0, "port to serve webapp on")
 	cmd.Flags().IntVarP(&o.DisconnectAfter, "disconnect-after", "", 0, "duration to keep connected in seconds, 0 means run indefinitely")
 
@@ -76,6 +81,9 @@ type ConnectOptions struct {
 	Setup    bool
 	ReadOnly bool
 
+	// QriRepoPath is the directory relative rpc socket paths are resolved in
+	QriRepoPath string
+
 	Node   *p2p.QriNode
 	Config *config.Config
 }
@@ -83,6 +91,7 @@ type ConnectOptions struct {
 // Complete adds any missing configuration that can only be added just before calling Run
 func (o *ConnectOptions) Complete(f Factory, args []string) (err error) {
 	qriPath := f.QriRepoPath()
+	o.QriRepoPath = qriPath
 
 	if o.Setup && !QRIRepoInitialized(qriPath) {
 		so := &SetupOptions{
@@ -116,11 +125,23 @@ func (o *ConnectOptions) Complete(f Factory, args []string) (err error) {
 func (o *ConnectOptions) Run() (err error) {
 	cfg := *o.Config
 
-	if o.APIPort != 0 {
-		cfg.API.Port = o.APIPort
+	if cfg.API != nil {
+		cfg.API = cfg.API.Copy()
+		if o.APIPort != 0 {
+			cfg.API.Port = o.APIPort
+		}
+		cfg.API.Uploads = cfg.API.UploadsDir(o.QriRepoPath)
 	}
-	if o.RPCPort != 0 {
-		cfg.RPC.Port = o.RPCPort
+	if cfg.RPC != nil {
+		cfg.RPC = cfg.RPC.Copy()
+		if o.RPCPort != 0 {
+			// an explicit port means listening over tcp
+			cfg.RPC.Port = o.RPCPort
+			cfg.RPC.Socket = ""
+		}
+		if cfg.RPC.Socket != "" {
+			_, cfg.RPC.Socket = cfg.RPC.Addr(o.QriRepoPath)
+		}
 	}
 	if o.WebappPort != 0 {
 		cfg.Webapp.Port = o.WebappPort
@@ -146,8 +167,28 @@ func (o *ConnectOptions) Run() (err error) {
 		cfg.Webapp.Enabled = false
 	}
 
+	if status, err := o.Node.QueueStatus(); err == nil && status.Queued > 0 {
+		printInfo(o.Out, "%d queued messages waiting to be sent, %d failing. oldest queued at %s", status.Queued, status.Failing, status.Oldest.Format(time.RFC3339))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// shut down gracefully on SIGINT / SIGTERM
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case sig := <-sigs:
+			printInfo(o.Out, "received %s, shutting down...", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	s := api.New(o.Node, &cfg)
-	err = s.Serve()
+	err = s.Start(ctx)
 	if err != nil && err.Error() == "http: Server closed" {
 		return nil
 	}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"github.com/qri-io/dsdiff"
 	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/actions"
 	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/repo"
 	"github.com/spf13/cobra"
@@ -91,7 +92,10 @@ func (o *DiffOptions) Run() error {
 	}
 
 	if err = o.DatasetRequests.Diff(p, &diffs); err != nil {
-		return err
+		if _, ok := err.(actions.StatsUnavailableError); !ok {
+			return err
+		}
+		printWarning(o.ErrOut, err.Error())
 	}
 
 	displayFormat := "listKeys"
@@ -19,9 +19,18 @@ func NewExportCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
 		Use:   "export",
 		Short: "Copy datasets to your local filesystem",
 		Long: `
-Export gets datasets out of qri. By default it exports the dataset body, as ` + "`body.csv`" + `, header as` + "`dataset.json`" + `, and ref, as ` + "`ref.txt`" + ` files. 
+Export gets datasets out of qri. By default it writes a directory named after
+the dataset that contains the dataset document as ` + "`dataset.yaml`" + `, the body
+as ` + "`body.csv`" + ` (or whatever format the body is stored in), the dataset
+reference as ` + "`ref.txt`" + `, and any transform or viz scripts.
 
-To export to a specific directory, use the --output flag.
+Paths in the exported dataset document are relative to the document, so an
+export can be edited and saved back to qri with ` + "`qri save --file`" + `.
+
+To export to a specific directory, use the --output flag. Use --zip to write
+a zip archive instead of a directory, --body-format to convert the body to
+another data format, and --history to include every previous version of the
+dataset in a ` + "`history`" + ` directory.
 
 If you want an empty dataset that can be filled in with details to create a
 new dataset, use --blank.`,
@@ -32,7 +41,14 @@ new dataset, use --blank.`,
   qri export --no-body me/annual_pop
 
   # export to a specific directory
-  qri export -o ~/new_directory me/annual_pop`,
+  qri export -o ~/new_directory me/annual_pop
+
+  # export a zip archive with the body as json
+  qri export --zip --body-format json me/annual_pop
+
+  # export every version of a dataset, then save it again
+  qri export --history me/annual_pop
+  qri save --file annual_pop/dataset.yaml me/annual_pop_copy`,
 		Annotations: map[string]string{
 			"group": "dataset",
 		},
@@ -46,11 +62,12 @@ new dataset, use --blank.`,
 
 	cmd.Flags().BoolVarP(&o.Blank, "blank", "", false, "export a blank dataset YAML file, overrides all other flags except output")
 	cmd.Flags().StringVarP(&o.Output, "output", "o", ".", "path to write to, default is current directory")
-	cmd.Flags().StringVarP(&o.Format, "format", "f", "yaml", "format for all exported files, except for body. yaml is the default format. options: yaml, json")
-	cmd.Flags().StringVarP(&o.BodyFormat, "body-format", "", "", "format for dataset body. default is the original data format. options: json, csv, cbor")
+	cmd.Flags().StringVarP(&o.Format, "format", "f", "yaml", "format for all exported files, except for body. yaml is the default format, zip archives always use json. options: yaml, json")
+	cmd.Flags().StringVarP(&o.BodyFormat, "body-format", "", "", "format for dataset body. default is the original data format. options: json, csv, cbor, ndjson, xlsx, parquet")
 	cmd.Flags().BoolVarP(&o.NoBody, "no-body", "b", false, "don't include dataset body in export")
 	cmd.Flags().BoolVarP(&o.PeerDir, "peer-dir", "d", false, "export to a peer name namespaced directory")
 	cmd.Flags().BoolVarP(&o.Zipped, "zip", "z", false, "export as a zip file")
+	cmd.Flags().BoolVarP(&o.History, "history", "", false, "include every previous version of the dataset")
 
 	return cmd
 }
@@ -67,6 +84,7 @@ type ExportOptions struct {
 	Format     string
 	BodyFormat string
 	NoBody     bool
+	History    bool
 
 	UsingRPC       bool
 	ExportRequests *lib.ExportRequests
@@ -104,30 +122,6 @@ func (o *ExportOptions) Run() error {
 		return fmt.Errorf("'%s' already exists", path)
 	}
 
-	// TODO: Support these flag, and remove these check.
-	if bodyFormat != "" {
-		return fmt.Errorf("--body-format flag is not supported currently")
-	}
-	if o.NoBody {
-		return fmt.Errorf("--no-body flag is not supported currently")
-	}
-	if o.PeerDir {
-		return fmt.Errorf("--peer-dir flag is not supported currently")
-	}
-	if !o.Zipped {
-		return fmt.Errorf("only exporting to a zip file is supported, must use --zip flag")
-	}
-
-	if format == "" {
-		format = "yaml"
-	} else if format != "yaml" && format != "json" {
-		return fmt.Errorf("%s is not an accepted format, options are yaml and json", format)
-	}
-
-	if bodyFormat != "" && bodyFormat != "json" && bodyFormat != "csv" && bodyFormat != "cbor" {
-		return fmt.Errorf("%s is not an accepted data format, options are json, csv, and cbor", bodyFormat)
-	}
-
 	ref, err := repo.ParseDatasetRef(o.Ref)
 	if err != nil {
 		return err
@@ -138,17 +132,22 @@ func (o *ExportOptions) Run() error {
 	}
 
 	p := &lib.ExportParams{
-		Ref:     ref,
-		RootDir: path,
-		PeerDir: false,
-		Format:  format,
+		Ref:        ref,
+		RootDir:    path,
+		PeerDir:    o.PeerDir,
+		Format:     format,
+		BodyFormat: bodyFormat,
+		Zipped:     o.Zipped,
+		NoBody:     o.NoBody,
+		History:    o.History,
 	}
 
-	ok := false
-	if err = o.ExportRequests.Export(p, &ok); err != nil {
+	fileWritten := ""
+	if err = o.ExportRequests.Export(p, &fileWritten); err != nil {
 		return err
 	}
 
+	printSuccess(o.Out, "exported dataset to: %s", fileWritten)
 	return nil
 }
 
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"net/rpc"
 	"os"
 	"path/filepath"
 
@@ -9,6 +8,7 @@ import (
 	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo/gen"
 )
 
@@ -22,7 +22,7 @@ type Factory interface {
 	CryptoGenerator() gen.CryptoGenerator
 
 	Init() error
-	RPC() *rpc.Client
+	RPC() *qrpc.Client
 	ConnectionNode() (*p2p.QriNode, error)
 
 	DatasetRequests() (*lib.DatasetRequests, error)
@@ -34,6 +34,8 @@ type Factory interface {
 	SearchRequests() (*lib.SearchRequests, error)
 	RenderRequests() (*lib.RenderRequests, error)
 	SelectionRequests() (*lib.SelectionRequests, error)
+	TokenRequests() (*lib.TokenRequests, error)
+	JobRequests() (*lib.JobRequests, error)
 }
 
 // PathFactory is a function that returns paths to qri & ipfs repos
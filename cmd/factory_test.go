@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"net/rpc"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,6 +12,7 @@ import (
 	"github.com/qri-io/qri/lib"
 	libtest "github.com/qri-io/qri/lib/test"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/repo/gen"
 	"github.com/qri-io/qri/repo/test"
@@ -33,7 +33,7 @@ type TestFactory struct {
 	config *config.Config
 	node   *p2p.QriNode
 	repo   repo.Repo
-	rpc    *rpc.Client
+	rpc    *qrpc.Client
 }
 
 // NewTestFactory creates TestFactory object with an in memory test repo
@@ -96,7 +96,7 @@ func (t TestFactory) ConnectionNode() (*p2p.QriNode, error) {
 }
 
 // RPC returns from internal state
-func (t TestFactory) RPC() *rpc.Client {
+func (t TestFactory) RPC() *qrpc.Client {
 	return nil
 }
 
@@ -145,6 +145,16 @@ func (t TestFactory) RenderRequests() (*lib.RenderRequests, error) {
 	return lib.NewRenderRequests(t.repo, t.rpc), nil
 }
 
+// TokenRequests generates a lib.TokenRequests from internal state
+func (t TestFactory) TokenRequests() (*lib.TokenRequests, error) {
+	return lib.NewTokenRequests(t.repo, t.rpc), nil
+}
+
+// JobRequests generates a lib.JobRequests from internal state
+func (t TestFactory) JobRequests() (*lib.JobRequests, error) {
+	return lib.NewJobRequests(t.node, t.rpc), nil
+}
+
 func TestEnvPathFactory(t *testing.T) {
 	//Needed to clean up changes after the test has finished running
 	prevQRIPath := os.Getenv("QRI_PATH")
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"encoding/json"
 
@@ -29,7 +30,11 @@ You can get pertinent information on multiple datasets at the same time
 by supplying more than one dataset reference.
 
 Check out https://qri.io/docs/reference/dataset/ to learn about each section of the 
-dataset and its fields.`,
+dataset and its fields.
+
+Getting stats prints a profile of each column of the dataset body: counts of
+values, nulls & distinct values, min, max, mean, a histogram and the most
+frequent values. Stats are computed when a version is saved.`,
 		Example: `  # print the entire dataset to the console
   qri get me/annual_pop
 
@@ -39,6 +44,9 @@ dataset and its fields.`,
   # print the dataset body size to the console
   qri get structure.length me/annual_pop
 
+  # print column statistics of the dataset body
+  qri get stats me/annual_pop
+
   # print the dataset body size for two different datasets
   qri get structure.length me/annual_pop me/annual_gdp`,
 		Annotations: map[string]string{
@@ -71,7 +79,7 @@ type GetOptions struct {
 }
 
 // isDatasetField checks if a string is a dataset field or not
-var isDatasetField = regexp.MustCompile("(?i)^(commit|structure|body|meta|viz|transform)$")
+var isDatasetField = regexp.MustCompile("(?i)^(commit|structure|body|meta|viz|transform|stats)$")
 
 // Complete adds any missing configuration that can only be added just before calling Run
 func (o *GetOptions) Complete(f Factory, args []string) (err error) {
@@ -105,7 +113,13 @@ func (o *GetOptions) Run() (err error) {
 
 	// TOOD: Specially handle `body` to call LookupBody on the dataset.
 	var value interface{}
-	if o.Path == "" {
+	if strings.ToLower(o.Path) == "stats" {
+		stats := &base.Stats{}
+		if err = o.DatasetRequests.Stats(&res, stats); err != nil {
+			return err
+		}
+		value = stats
+	} else if o.Path == "" {
 		value = res
 	} else {
 		// TODO: Don't depend directly on base.
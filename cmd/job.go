@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"io"
+	"time"
+
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/lib"
+	"github.com/qri-io/qri/repo"
+	"github.com/spf13/cobra"
+)
+
+// NewJobCommand creates a `qri job` subcommand for checking on background jobs
+func NewJobCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
+	o := &JobOptions{IOStreams: ioStreams}
+	cmd := &cobra.Command{
+		Use:   "job",
+		Short: "Check on background jobs",
+		Long: `Long-running commands like save, update, publish & add accept an --async
+flag that runs the command in the background of a running ` + "`qri connect`" + `
+instead of waiting for it to finish. Each async command starts a job, use the
+job command to check on a job's progress, see it's output, or cancel it.
+
+Jobs are kept in your repo, queued jobs resume when ` + "`qri connect`" + ` restarts.
+Secrets & uploaded bodies aren't kept, jobs that use them must be submitted
+again after a restart.`,
+		Example: `  # save a dataset in the background
+  qri save --async --body data.csv me/dataset
+
+  # list recent jobs
+  qri job list
+
+  # show a job's status & output
+  qri job status 3f9a1c2b7d4e5f60
+
+  # cancel a queued or running job
+  qri job cancel 3f9a1c2b7d4e5f60`,
+		Annotations: map[string]string{
+			"group": "other",
+		},
+	}
+
+	list := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List background jobs, newest first",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f); err != nil {
+				return err
+			}
+			return o.List()
+		},
+	}
+
+	status := &cobra.Command{
+		Use:     "status [ID]",
+		Aliases: []string{"get"},
+		Short:   "Show the status, progress & output of a job",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f); err != nil {
+				return err
+			}
+			return o.Status(args[0])
+		},
+	}
+
+	cancel := &cobra.Command{
+		Use:   "cancel [ID]",
+		Short: "Cancel a queued or running job",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f); err != nil {
+				return err
+			}
+			return o.Cancel(args[0])
+		},
+	}
+
+	list.Flags().IntVarP(&o.Limit, "limit", "l", 25, "limit results, default 25")
+	list.Flags().IntVarP(&o.Offset, "offset", "o", 0, "offset results, default 0")
+	cmd.AddCommand(list, status, cancel)
+
+	return cmd
+}
+
+// JobOptions encapsulates state for the job command
+type JobOptions struct {
+	ioes.IOStreams
+
+	Limit  int
+	Offset int
+
+	JobRequests *lib.JobRequests
+}
+
+// Complete adds any missing configuration that can only be added just before calling Run
+func (o *JobOptions) Complete(f Factory) (err error) {
+	o.JobRequests, err = f.JobRequests()
+	return
+}
+
+// List shows recent jobs
+func (o *JobOptions) List() error {
+	jobs := []repo.Job{}
+	if err := o.JobRequests.List(&lib.ListParams{Limit: o.Limit, Offset: o.Offset}, &jobs); err != nil {
+		return err
+	}
+
+	if len(jobs) == 0 {
+		printInfo(o.Out, "no jobs. run save, update, publish or add with --async to start one")
+		return nil
+	}
+	for i, j := range jobs {
+		printSuccess(o.Out, "%d.\t%s\t%s\t%s", i+o.Offset+1, j.ID, j.Type, j.Status)
+		printInfo(o.Out, "\tsubmitted %s", j.Created.Format(time.RFC822))
+	}
+	return nil
+}
+
+// Status prints the details of a single job
+func (o *JobOptions) Status(id string) error {
+	job := repo.Job{}
+	if err := o.JobRequests.Get(&id, &job); err != nil {
+		return err
+	}
+	printJob(o.Out, job)
+	return nil
+}
+
+// Cancel stops a queued or running job
+func (o *JobOptions) Cancel(id string) error {
+	job := repo.Job{}
+	if err := o.JobRequests.Cancel(&id, &job); err != nil {
+		return err
+	}
+	printSuccess(o.Out, "cancelled job %s", id)
+	return nil
+}
+
+func printJob(w io.Writer, job repo.Job) {
+	printSuccess(w, "%s\t%s\t%s", job.ID, job.Type, job.Status)
+	for _, p := range job.Progress {
+		printInfo(w, "\t%s", p)
+	}
+	if job.Output != "" {
+		printInfo(w, "output:\n%s", job.Output)
+	}
+	if job.Error != "" {
+		printWarning(w, "error: %s", job.Error)
+	}
+	if len(job.Result) > 0 {
+		printInfo(w, "result: %s", string(job.Result))
+	}
+}
+
+// asyncJobRequests gets JobRequests for commands run with --async. Jobs are
+// run by a `qri connect` process, a command that exits can't keep a job going
+func asyncJobRequests(f Factory) (*lib.JobRequests, error) {
+	if f.RPC() == nil {
+		return nil, lib.NewError(lib.ErrBadArgs, "--async requires `qri connect` running in another terminal")
+	}
+	return f.JobRequests()
+}
+
+// submitJob starts a background job, printing how to check on it
+func submitJob(w io.Writer, jr *lib.JobRequests, p *lib.JobParams) error {
+	job := repo.Job{}
+	if err := jr.Submit(p, &job); err != nil {
+		return err
+	}
+	printSuccess(w, "started %s job %s", job.Type, job.ID)
+	printInfo(w, "check on it with: qri job status %s", job.ID)
+	return nil
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/lib"
+	"github.com/qri-io/qri/repo"
+)
+
+func TestAsyncRequiresConnect(t *testing.T) {
+	streams, _, _, _ := ioes.NewTestIOStreams()
+	f, err := NewTestFactory(nil)
+	if err != nil {
+		t.Fatalf("error creating new test factory: %s", err)
+	}
+
+	save := &SaveOptions{IOStreams: streams, Async: true}
+	if err := save.Complete(f, []string{"me/movies"}); err == nil {
+		t.Error("expected save --async without a connection to error")
+	}
+	update := &UpdateOptions{IOStreams: streams, Async: true}
+	if err := update.Complete(f, []string{"me/movies"}); err == nil {
+		t.Error("expected update --async without a connection to error")
+	}
+	publish := &PublishOptions{IOStreams: streams, Async: true}
+	if err := publish.Complete(f, []string{"me/movies"}); err == nil {
+		t.Error("expected publish --async without a connection to error")
+	}
+	add := &AddOptions{IOStreams: streams, Async: true}
+	if err := add.Complete(f); err == nil {
+		t.Error("expected add --async without a connection to error")
+	}
+}
+
+func TestJobRun(t *testing.T) {
+	streams, _, out, _ := ioes.NewTestIOStreams()
+	setNoColor(true)
+
+	f, err := NewTestFactory(nil)
+	if err != nil {
+		t.Fatalf("error creating new test factory: %s", err)
+	}
+
+	o := &JobOptions{IOStreams: streams}
+	if err := o.Complete(f); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.List(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "no jobs") {
+		t.Errorf("expected empty job list message, got: %s", out.String())
+	}
+	out.Reset()
+
+	ref := repo.MustParseDatasetRef("peer/movies")
+	ref.Published = true
+	if err := submitJob(out, o.JobRequests, &lib.JobParams{Publish: &lib.SetPublishStatusParams{Ref: &ref}}); err != nil {
+		t.Fatal(err)
+	}
+	jobs := []repo.Job{}
+	if err := o.JobRequests.List(&lib.ListParams{}, &jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got: %d", len(jobs))
+	}
+	id := jobs[0].ID
+	if !strings.Contains(out.String(), "qri job status "+id) {
+		t.Errorf("expected submit to explain how to check on the job, got: %s", out.String())
+	}
+
+	job := repo.Job{}
+	deadline := time.Now().Add(time.Second * 5)
+	for !job.Status.Finished() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for job to finish")
+		}
+		if err := o.JobRequests.Get(&id, &job); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	out.Reset()
+	if err := o.Status(id); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), string(repo.JobSucceeded)) {
+		t.Errorf("expected status to show the job succeeded, got: %s", out.String())
+	}
+
+	if err := o.Cancel(id); err == nil {
+		t.Error("expected cancelling a finished job to error")
+	}
+}
@@ -43,6 +43,7 @@ to a published dataset will be immediately visible to connected peers.
 	cmd.Flags().BoolVarP(&o.Unpublish, "unpublish", "", false, "unpublish a dataset")
 	cmd.Flags().BoolVarP(&o.NoRegistry, "no-registry", "", false, "don't publish to registry")
 	cmd.Flags().BoolVarP(&o.NoPin, "no-pin", "", false, "don't pin dataset to registry")
+	cmd.Flags().BoolVar(&o.Async, "async", false, "publish in the background of a running qri connect, check on it with qri job")
 
 	return cmd
 }
@@ -55,13 +56,20 @@ type PublishOptions struct {
 	Unpublish  bool
 	NoRegistry bool
 	NoPin      bool
+	Async      bool
 
 	DatasetRequests *lib.DatasetRequests
+	JobRequests     *lib.JobRequests
 }
 
 // Complete adds any missing configuration that can only be added just before calling Run
 func (o *PublishOptions) Complete(f Factory, args []string) (err error) {
 	o.Refs = args
+	if o.Async {
+		if o.JobRequests, err = asyncJobRequests(f); err != nil {
+			return err
+		}
+	}
 	o.DatasetRequests, err = f.DatasetRequests()
 	return
 }
@@ -83,6 +91,13 @@ func (o *PublishOptions) Run() error {
 			UpdateRegistryPin: !o.NoPin,
 		}
 
+		if o.Async {
+			if err = submitJob(o.Out, o.JobRequests, &lib.JobParams{Publish: p}); err != nil {
+				return err
+			}
+			continue
+		}
+
 		if err = o.DatasetRequests.SetPublishStatus(p, &res); err != nil {
 			return err
 		}
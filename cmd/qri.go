@@ -2,8 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"net"
-	"net/rpc"
 	"os"
 	"path/filepath"
 	"sync"
@@ -13,6 +11,7 @@ import (
 	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/repo/fs"
 	"github.com/qri-io/qri/repo/gen"
@@ -54,6 +53,7 @@ https://github.com/qri-io/qri/issues`,
 		NewExportCommand(opt, ioStreams),
 		NewGetCommand(opt, ioStreams),
 		NewInfoCommand(opt, ioStreams),
+		NewJobCommand(opt, ioStreams),
 		NewListCommand(opt, ioStreams),
 		NewLogCommand(opt, ioStreams),
 		NewManifestCommand(opt, ioStreams),
@@ -66,6 +66,7 @@ https://github.com/qri-io/qri/issues`,
 		NewSaveCommand(opt, ioStreams),
 		NewSearchCommand(opt, ioStreams),
 		NewSetupCommand(opt, ioStreams),
+		NewTransformCommand(opt, ioStreams),
 		NewUseCommand(opt, ioStreams),
 		NewUpdateCommand(opt, ioStreams),
 		NewValidateCommand(opt, ioStreams),
@@ -99,7 +100,7 @@ type QriOptions struct {
 	config      *config.Config
 	node        *p2p.QriNode
 	repo        repo.Repo
-	rpc         *rpc.Client
+	rpc         *qrpc.Client
 	initialized sync.Once
 }
 
@@ -129,11 +130,15 @@ func (o *QriOptions) Init() (err error) {
 		setNoColor(!o.config.CLI.ColorizeOutput || o.NoColor)
 
 		if o.config.RPC.Enabled {
-			addr := fmt.Sprintf(":%d", o.config.RPC.Port)
-			if conn, err := net.Dial("tcp", addr); err != nil {
-				err = nil
-			} else {
-				o.rpc = rpc.NewClient(conn)
+			network, addr := o.config.RPC.Addr(o.qriRepoPath)
+			cli, dialErr := qrpc.Dial(network, addr, lib.VersionNumber)
+			if _, ok := dialErr.(qrpc.VersionError); ok {
+				// talking to a different version of qri connect is worse than not
+				// talking to it at all, so refuse to carry on
+				err = dialErr
+				return
+			} else if dialErr == nil {
+				o.rpc = cli
 				return
 			}
 		}
@@ -210,7 +215,7 @@ func (o *QriOptions) CryptoGenerator() gen.CryptoGenerator {
 }
 
 // RPC returns from internal state
-func (o *QriOptions) RPC() *rpc.Client {
+func (o *QriOptions) RPC() *qrpc.Client {
 	return o.rpc
 }
 
@@ -293,3 +298,19 @@ func (o *QriOptions) RenderRequests() (*lib.RenderRequests, error) {
 	}
 	return lib.NewRenderRequests(o.repo, o.rpc), nil
 }
+
+// TokenRequests generates a lib.TokenRequests from internal state
+func (o *QriOptions) TokenRequests() (*lib.TokenRequests, error) {
+	if err := o.Init(); err != nil {
+		return nil, err
+	}
+	return lib.NewTokenRequests(o.repo, o.rpc), nil
+}
+
+// JobRequests generates a lib.JobRequests from internal state
+func (o *QriOptions) JobRequests() (*lib.JobRequests, error) {
+	if err := o.Init(); err != nil {
+		return nil, err
+	}
+	return lib.NewJobRequests(o.node, o.rpc), nil
+}
@@ -25,6 +25,10 @@ your dataset’s log (which you can see by running ` + "`qri log <dataset_refere
 
 If the dataset you're changing has defined a transform, running ` + "`qri save`" + `
 will re execute the transform. To only re-run the transform, run save with no args.
+Transforms are starlark by default. A transform script with a .sql extension
+is a sqlite query of the previous body, and a transform with ` + "`syntax: exec`" + `
+runs it's script as a local executable. Exec transforms only run for scripts
+on your filesystem when transform.allowExec is set in your config.
 
 Every time you save, you can provide a message about what you changed and why. 
 If you don’t provide a message Qri will automatically generate one for you.
@@ -33,13 +37,56 @@ When you make an update and save a dataset that you originally added from a diff
 peer, the dataset gets renamed from ` + "`peers_name/dataset_name`" + ` to ` + "`my_name/dataset_name`" + `.
 
 The ` + "`--message`" + `" and ` + "`--title`" + ` flags allow you to add a 
-commit message and title to the save.`,
+commit message and title to the save.
+
+Data quality rules are stored with each version, and are carried over to new
+versions. Provide a JSON or YAML list of rules with ` + "`--rules`" + ` to
+replace them, and add ` + "`--strict`" + ` to refuse to save a version that
+breaks any rule. A version that breaks a rule can't be published. Rule types
+are unique, reference, rowCount, pattern, range, freshness and schema:
+
+  - type: unique
+    columns: [city]
+  - type: reference
+    columns: [country]
+    dataset: me/countries
+    refColumns: [code]
+  - type: rowCount
+    min: 1
+    maxDecrease: 0.1
+  - type: pattern
+    columns: [zip]
+    pattern: '^[0-9]{5}$'
+  - type: range
+    columns: [pop]
+    min: 0
+  - type: freshness
+    columns: [updated]
+    maxAge: 48h
+  - type: schema
+    policy: forward
+
+Schema rules set how the columns of a dataset's schema may change between
+versions, and every save enforces them, strict or not. A backward policy
+allows adding & removing columns and widening types (like integer to number),
+so new readers can read old versions. A forward policy only allows adding
+columns, so readers of old versions can read new ones. none allows any change.`,
 		Example: `  # save updated data to dataset annual_pop:
   qri save --body /path/to/data.csv me/annual_pop
 
   # save updated dataset (no data) to annual_pop:
   qri save --file /path/to/dataset.yaml me/annual_pop
   
+  # save the rows of a sqlite table as the body of cities. running
+  # "qri update me/cities" later re-pulls the table:
+  qri save --body "sqlite:///path/to/data.db?table=cities" me/cities
+
+  # save every page of a paginated api, selecting entries with JSONPath:
+  qri save --body "api+https://example.com/items#select=$.data&next=$.links.next" me/items
+
+  # save new data, refusing to save if it breaks any quality rule:
+  qri save --body /path/to/data.csv --rules rules.yaml --strict me/annual_pop
+
   # re-execute a dataset that has a transform:
   qri save me/tf_dataset`,
 		Annotations: map[string]string{
@@ -59,12 +106,15 @@ commit message and title to the save.`,
 	cmd.Flags().StringVarP(&o.FilePath, "file", "f", "", "dataset data file (yaml or json)")
 	cmd.Flags().StringVarP(&o.Title, "title", "t", "", "title of commit message for save")
 	cmd.Flags().StringVarP(&o.Message, "message", "m", "", "commit message for save")
-	cmd.Flags().StringVarP(&o.BodyPath, "body", "", "", "path to file or url of data to add as dataset contents")
+	cmd.Flags().StringVarP(&o.BodyPath, "body", "", "", "path to file, url or source url (sqlite://, postgres://, api+https://) of data to add as dataset contents")
 	cmd.Flags().StringVarP(&o.Recall, "recall", "", "", "restore revisions from dataset history")
+	cmd.Flags().StringVar(&o.RulesPath, "rules", "", "path to a yaml or json file of data quality rules to store with the dataset")
+	cmd.Flags().BoolVar(&o.Strict, "strict", false, "refuse to save if the dataset breaks any of it's data quality rules")
 	// cmd.Flags().BoolVarP(&o.ShowValidation, "show-validation", "s", false, "display a list of validation errors upon adding")
 	cmd.Flags().StringSliceVar(&o.Secrets, "secrets", nil, "transform secrets as comma separated key,value,key,value,... sequence")
 	cmd.Flags().BoolVarP(&o.Publish, "publish", "p", false, "publish this dataset to the registry")
 	cmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "simulate saving a dataset")
+	cmd.Flags().BoolVar(&o.Async, "async", false, "save in the background of a running qri connect, check on it with qri job")
 
 	return cmd
 }
@@ -79,14 +129,18 @@ type SaveOptions struct {
 	Title          string
 	Message        string
 	Recall         string
+	RulesPath      string
+	Strict         bool
 	Passive        bool
 	Rescursive     bool
 	ShowValidation bool
 	Publish        bool
 	DryRun         bool
+	Async          bool
 	Secrets        []string
 
 	DatasetRequests *lib.DatasetRequests
+	JobRequests     *lib.JobRequests
 }
 
 // Complete adds any missing configuration that can only be added just before calling Run
@@ -106,6 +160,16 @@ func (o *SaveOptions) Complete(f Factory, args []string) (err error) {
 		return fmt.Errorf("body file: %s", err)
 	}
 
+	if err := lib.AbsPath(&o.RulesPath); err != nil {
+		return fmt.Errorf("rules file: %s", err)
+	}
+
+	if o.Async {
+		if o.JobRequests, err = asyncJobRequests(f); err != nil {
+			return err
+		}
+	}
+
 	o.DatasetRequests, err = f.DatasetRequests()
 	return
 }
@@ -142,6 +206,10 @@ func (o *SaveOptions) Run() (err error) {
 		Publish:     o.Publish,
 		DryRun:      o.DryRun,
 		Recall:      o.Recall,
+		RulesPath:   o.RulesPath,
+		Strict:      o.Strict,
+		// stream transform print output, including from qri connect
+		ScriptOutput: o.Out,
 	}
 
 	if o.Secrets != nil {
@@ -156,6 +224,11 @@ continue?`, true) {
 		}
 	}
 
+	if o.Async {
+		o.StopSpinner()
+		return submitJob(o.Out, o.JobRequests, &lib.JobParams{Save: p})
+	}
+
 	res := &repo.DatasetRef{}
 	if err = o.DatasetRequests.Save(p, res); err != nil {
 		return err
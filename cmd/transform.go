@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/qri-io/dsdiff"
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/actions"
+	"github.com/qri-io/qri/lib"
+	"github.com/qri-io/qri/repo"
+	"github.com/spf13/cobra"
+)
+
+// NewTransformCommand creates a `qri transform` subcommand for working with
+// transform scripts
+func NewTransformCommand(f Factory, ioStreams ioes.IOStreams) *cobra.Command {
+	o := &TransformOptions{IOStreams: ioStreams}
+	cmd := &cobra.Command{
+		Use:   "transform",
+		Short: "Develop & test transform scripts",
+		Annotations: map[string]string{
+			"group": "dataset",
+		},
+	}
+
+	test := &cobra.Command{
+		Use:   "test [SCRIPT] [DATASET]",
+		Short: "Run a transform against fixture inputs without saving",
+		Long: `Test runs a transform script without saving, checking it's output against
+a fixture, and showing how the output differs from the previous version.
+
+Given a dataset, the transform runs on the dataset's latest version. Without
+one, the transform runs as if it were creating a new dataset.
+
+Scripts run with the syntax of the dataset's transform. Without one .sql
+scripts are sql, and other scripts are starlark. Use --syntax exec to test
+an executable transform, which needs transform.allowExec set in your config
+and can't be tested with a cassette.
+
+A fixture is a yaml or json file of inputs for the transform, and
+expectations of it's output. Every field is optional:
+
+  # replaces the body of the previous version
+  prevBody: [[1, "a"]]
+  # replaces the transform config of the previous version
+  config:
+    page: 1
+  # used in place of real secrets
+  secrets:
+    api_key: fake
+  # a file of recorded http responses, relative to the fixture. if the
+  # file doesn't exist, real requests are made & recorded to it. later
+  # tests replay the recorded responses instead of using the network
+  cassette: requests.json
+  expect:
+    # the exact body the transform should output
+    body: [[1, "a"], [2, "b"]]
+    # structure fields the output should have
+    structure:
+      format: csv
+
+Tests that don't meet an expectation fail. Cassettes match requests by
+method & url, delete a cassette to record it again.`,
+		Example: `  # run a transform on the latest version of a dataset, showing changes
+  qri transform test transform.star me/dataset
+
+  # test a transform against a fixture
+  qri transform test --fixture fixture.yaml transform.star`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(f, args); err != nil {
+				return err
+			}
+			return o.Test()
+		},
+	}
+
+	test.Flags().StringVarP(&o.FixturePath, "fixture", "f", "", "path to a yaml or json fixture of inputs & expected outputs")
+	test.Flags().StringVar(&o.Syntax, "syntax", "", "transform syntax of the script [starlark|sql|exec]")
+	test.Flags().StringVarP(&o.Display, "display", "d", "", "set diff display format [reg|short|delta|detail]")
+	cmd.AddCommand(test)
+
+	return cmd
+}
+
+// TransformOptions encapsulates state for the transform command
+type TransformOptions struct {
+	ioes.IOStreams
+
+	ScriptPath  string
+	Ref         string
+	FixturePath string
+	Syntax      string
+	Display     string
+
+	DatasetRequests *lib.DatasetRequests
+}
+
+// Complete adds any missing configuration that can only be added just before calling Run
+func (o *TransformOptions) Complete(f Factory, args []string) (err error) {
+	if len(args) > 0 {
+		o.ScriptPath = args[0]
+	}
+	if len(args) > 1 {
+		o.Ref = args[1]
+	}
+	if err = lib.AbsPath(&o.ScriptPath); err != nil {
+		return
+	}
+	if err = lib.AbsPath(&o.FixturePath); err != nil {
+		return
+	}
+	o.DatasetRequests, err = f.DatasetRequests()
+	return
+}
+
+// Test runs a transform against a fixture
+func (o *TransformOptions) Test() error {
+	ref, err := repo.ParseDatasetRef(o.Ref)
+	if err != nil && err != repo.ErrEmptyRef {
+		return err
+	}
+
+	p := &lib.TestTransformParams{
+		ScriptPath:   o.ScriptPath,
+		Syntax:       o.Syntax,
+		FixturePath:  o.FixturePath,
+		Ref:          ref,
+		ScriptOutput: o.Out,
+	}
+	res := actions.TransformTestResult{}
+	if err := o.DatasetRequests.TestTransform(p, &res); err != nil {
+		return err
+	}
+
+	if res.Recorded > 0 {
+		printInfo(o.Out, "recorded %d requests to the fixture's cassette", res.Recorded)
+	}
+
+	if len(res.Diffs) > 0 {
+		printInfo(o.Out, "changes from the previous version:")
+		if err := o.printDiffs(res.Diffs); err != nil {
+			return err
+		}
+	} else {
+		data, err := json.MarshalIndent(res.Body, "", "  ")
+		if err != nil {
+			return err
+		}
+		printInfo(o.Out, "body:\n%s", string(data))
+	}
+
+	if len(res.Failures) > 0 {
+		for _, failure := range res.Failures {
+			printWarning(o.Out, "✖ %s", failure)
+		}
+		if len(res.ExpectDiffs) > 0 {
+			printInfo(o.Out, "differences from the expected output:")
+			if err := o.printDiffs(res.ExpectDiffs); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("transform test failed, %d of the fixture's expectations weren't met", len(res.Failures))
+	}
+
+	printSuccess(o.Out, "✅ transform test passed")
+	return nil
+}
+
+func (o *TransformOptions) printDiffs(diffs map[string]*dsdiff.SubDiff) error {
+	displayFormat := "listKeys"
+	switch o.Display {
+	case "short", "s":
+		displayFormat = "simple"
+	case "delta":
+		displayFormat = "delta"
+	case "detail":
+		displayFormat = "plusMinus"
+	}
+
+	text, err := dsdiff.MapDiffsToString(diffs, displayFormat)
+	if err != nil {
+		return err
+	}
+	printDiffs(o.Out, text)
+	return nil
+}
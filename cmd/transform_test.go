@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/ioes"
+)
+
+func TestTransformComplete(t *testing.T) {
+	streams, _, _, _ := ioes.NewTestIOStreams()
+	f, err := NewTestFactory(nil)
+	if err != nil {
+		t.Fatalf("error creating new test factory: %s", err)
+	}
+
+	o := &TransformOptions{IOStreams: streams}
+	if err := o.Complete(f, []string{"tf.star", "me/movies"}); err != nil {
+		t.Fatal(err)
+	}
+	if !filepath.IsAbs(o.ScriptPath) {
+		t.Errorf("expected script path to be absolute, got: %s", o.ScriptPath)
+	}
+	if o.Ref != "me/movies" {
+		t.Errorf("expected ref to be me/movies, got: %s", o.Ref)
+	}
+	if o.DatasetRequests == nil {
+		t.Error("expected DatasetRequests to be set")
+	}
+}
+
+func TestTransformTest(t *testing.T) {
+	streams, in, out, errs := ioes.NewTestIOStreams()
+	setNoColor(true)
+
+	f, err := NewTestFactory(nil)
+	if err != nil {
+		t.Fatalf("error creating new test factory: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "qri_transform_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scriptPath := filepath.Join(dir, "transform.star")
+	if err := ioutil.WriteFile(scriptPath, []byte("def transform(ds, ctx):\n  ds.set_body([ctx.get_config('n')])\n"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		fixture string
+		expect  string
+		err     string
+	}{
+		{"config:\n  n: 1\nexpect:\n  body: [1]\n", "transform test passed", ""},
+		{"config:\n  n: 2\nexpect:\n  body: [1]\n", "body doesn't match the expected body", "transform test failed, 1 of the fixture's expectations weren't met"},
+	}
+
+	for i, c := range cases {
+		fixturePath := filepath.Join(dir, "fixture.yaml")
+		if err := ioutil.WriteFile(fixturePath, []byte(c.fixture), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		o := &TransformOptions{IOStreams: streams, FixturePath: fixturePath}
+		if err := o.Complete(f, []string{scriptPath}); err != nil {
+			t.Fatal(err)
+		}
+		err := o.Test()
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+		}
+		if !strings.Contains(out.String(), c.expect) {
+			t.Errorf("case %d expected output to contain '%s', got: %s", i, c.expect, out.String())
+		}
+		ioReset(in, out, errs)
+	}
+}
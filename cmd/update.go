@@ -24,14 +24,32 @@ irritating, we're working on a solution.**
 
 Calling update on a dataset in your namespace will advance your dataset by 
 re-running any specified transform script, creating a new version of your 
-dataset in the process. If your dataset doesn't have a transform script, update 
-will error.`,
+dataset in the process. Datasets saved from a source url (see ` + "`qri save --body`" + `)
+re-pull the source instead. Source passwords & credential params like api_key
+are never recorded, supply them as secrets of the same name. If your dataset
+doesn't have a transform script or source, update will error. Updates enforce
+the data quality rules stored with a dataset, refusing to save a version that
+breaks any rule.
+
+Transforms can be limited in the transform section of your config, which sets
+a timeout, the most entries & bytes a transform body can have, and the hosts
+a transform can make http requests to. Transforms that exceed a limit fail,
+and are recorded in your event log:
+
+  transform:
+    timeout: 30s
+    maxOutputRows: 100000
+    maxOutputBytes: 10000000
+    allowedHosts: [api.example.com]`,
 		Example: `  # get the freshest version of a dataset from a peer
   qri update other_person/dataset
 
   # update your local dataset by re-running the dataset transform
   qri update me/dataset_with_transform
 
+  # re-pull a dataset saved from a postgres query
+  qri update me/dataset_from_postgres --secrets=password,hunter2
+
   # supply secrets to an update, publish on successful run
   qri update me/dataset_with_transform -p --secrets=keyboard,cat`,
 		Annotations: map[string]string{
@@ -51,6 +69,7 @@ will error.`,
 	cmd.Flags().StringSliceVar(&o.Secrets, "secrets", nil, "transform secrets as comma separated key,value,key,value,... sequence")
 	// cmd.Flags().BoolVarP(&o.Publish, "publish", "p", false, "publish this dataset to the registry")
 	cmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "simulate updating a dataset")
+	cmd.Flags().BoolVar(&o.Async, "async", false, "update in the background of a running qri connect, check on it with qri job")
 
 	return cmd
 }
@@ -65,9 +84,11 @@ type UpdateOptions struct {
 	Recall  string
 	Publish bool
 	DryRun  bool
+	Async   bool
 	Secrets []string
 
 	DatasetRequests *lib.DatasetRequests
+	JobRequests     *lib.JobRequests
 }
 
 // Complete adds any missing configuration that can only be added just before calling Run
@@ -75,6 +96,11 @@ func (o *UpdateOptions) Complete(f Factory, args []string) (err error) {
 	if len(args) == 1 {
 		o.Ref = args[0]
 	}
+	if o.Async {
+		if o.JobRequests, err = asyncJobRequests(f); err != nil {
+			return err
+		}
+	}
 	o.DatasetRequests, err = f.DatasetRequests()
 	return
 }
@@ -102,6 +128,8 @@ func (o *UpdateOptions) Run() (err error) {
 		DryRun:     o.DryRun,
 		Publish:    o.Publish,
 		ReturnBody: false,
+		// stream transform print output, including from qri connect
+		ScriptOutput: o.Out,
 	}
 
 	if o.Secrets != nil {
@@ -117,6 +145,11 @@ func (o *UpdateOptions) Run() (err error) {
 		}
 	}
 
+	if o.Async {
+		o.StopSpinner()
+		return submitJob(o.Out, o.JobRequests, &lib.JobParams{Update: p})
+	}
+
 	res := &repo.DatasetRef{}
 	if err := o.DatasetRequests.Update(p, res); err != nil {
 		return err
@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
+	"github.com/fatih/color"
 	"github.com/qri-io/ioes"
 	"github.com/qri-io/jsonschema"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/lib"
 	"github.com/qri-io/qri/repo"
 	"github.com/spf13/cobra"
@@ -52,6 +55,11 @@ will affect a dataset before saving changes to a dataset.
 You can get the current schema of a dataset by running the ` + "`qri get structure.schema`" + `
 command.
 
+When validating a dataset, validate also checks the data quality rules stored
+with the dataset, printing a result for each rule. To check rules that aren't
+stored yet, provide a yaml or json file of rules with --rules. See
+` + "`qri save --help`" + ` for the types of rules.
+
 Note: --body and --schema flags will override the dataset if both flags are provided.`,
 		Example: `  # show errors in an existing dataset:
   qri validate b5/comics
@@ -60,7 +68,10 @@ Note: --body and --schema flags will override the dataset if both flags are prov
   qri validate --body new_data.csv me/annual_pop
 
   # validate data against a new schema
-  qri validate --body data.csv --schema schema.json`,
+  qri validate --body data.csv --schema schema.json
+
+  # check a dataset against new data quality rules
+  qri validate --rules rules.yaml me/annual_pop`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := o.Complete(f, args); err != nil {
 				return err
@@ -73,6 +84,7 @@ Note: --body and --schema flags will override the dataset if both flags are prov
 	// cmd.Flags().StringVarP(&o.URL, "url", "u", "", "url to file to initialize from")
 	cmd.Flags().StringVarP(&o.Filepath, "body", "b", "", "data file to initialize from")
 	cmd.Flags().StringVarP(&o.SchemaFilepath, "schema", "", "", "json schema file to use for validation")
+	cmd.Flags().StringVar(&o.RulesFilepath, "rules", "", "yaml or json file of data quality rules to check")
 
 	return cmd
 }
@@ -84,6 +96,7 @@ type ValidateOptions struct {
 	Ref            string
 	Filepath       string
 	SchemaFilepath string
+	RulesFilepath  string
 	URL            string
 	// validateDsPassive        bool
 
@@ -100,6 +113,10 @@ func (o *ValidateOptions) Complete(f Factory, args []string) (err error) {
 		o.Ref = args[0]
 	}
 
+	if err = lib.AbsPath(&o.RulesFilepath); err != nil {
+		return
+	}
+
 	o.DatasetRequests, err = f.DatasetRequests()
 	return
 }
@@ -152,15 +169,42 @@ func (o *ValidateOptions) Run() (err error) {
 		return err
 	}
 
+	// rules are only stored with datasets
+	results := []*base.RuleResult{}
+	if o.Ref != "" || o.RulesFilepath != "" {
+		rp := &lib.CheckRulesParams{Ref: ref, RulesPath: o.RulesFilepath}
+		if err = o.DatasetRequests.CheckRules(rp, &results); err != nil {
+			return err
+		}
+	}
+
 	o.StopSpinner()
 
-	if len(res) == 0 {
+	if len(res) == 0 && base.RulesError(results) == nil {
 		printSuccess(o.Out, "✔ All good!")
-		return
+		if len(results) == 0 {
+			return
+		}
 	}
 
 	for i, err := range res {
 		fmt.Fprintf(o.Out, "%d: %s\n", i, err.Error())
 	}
+	printRuleResults(o.Out, results)
 	return nil
 }
+
+// printRuleResults writes a line for each data quality rule result
+func printRuleResults(w io.Writer, results []*base.RuleResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nquality rules:")
+	for _, res := range results {
+		if res.Passed {
+			printSuccess(w, "  ✔ %s", res.Rule)
+		} else {
+			fmt.Fprintln(w, color.New(color.FgRed).Sprintf("  ✘ %s: %s", res.Rule, res.Message))
+		}
+	}
+}
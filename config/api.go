@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"path/filepath"
 	"reflect"
 
 	"github.com/qri-io/jsonschema"
@@ -29,8 +30,85 @@ type API struct {
 	ProxyForceHTTPS bool `json:"proxyforcehttps"`
 	// support CORS signing from a list of origins
 	AllowedOrigins []string `json:"allowedorigins"`
+	// Auth requires requests carry an API token in an "Authorization: Bearer"
+	// header. create tokens with `qri config token create`
+	Auth bool `json:"auth,omitempty"`
+	// Limits bounds requests from each client. clients are identified by API
+	// token when auth is enabled, and by remote address otherwise
+	Limits APILimits `json:"limits"`
+	// TokenLimits overrides Limits for specific API tokens, keyed by token ID.
+	// only non-zero fields override
+	TokenLimits map[string]*APILimits `json:"tokenlimits,omitempty"`
+	// Uploads is the directory resumable body uploads are staged in. relative
+	// paths are resolved against the qri repo path. defaults to an uploads
+	// directory in the qri repo
+	Uploads string `json:"uploads,omitempty"`
 }
 
+// UploadsDir gives the directory to stage uploads in, resolving a relative
+// Uploads path against repoPath
+func (a API) UploadsDir(repoPath string) string {
+	if a.Uploads == "" {
+		return filepath.Join(repoPath, "uploads")
+	}
+	if filepath.IsAbs(a.Uploads) {
+		return a.Uploads
+	}
+	return filepath.Join(repoPath, a.Uploads)
+}
+
+// APILimits bounds what a single API client can ask of a node. Zero values
+// mean no limit
+type APILimits struct {
+	// RequestsPerMinute is the sustained rate of requests a client can make.
+	// clients can burst up to a minute's worth of requests at once
+	RequestsPerMinute int `json:"requestsperminute,omitempty"`
+	// RequestsPerDay is the total number of requests a client can make in a
+	// 24 hour window
+	RequestsPerDay int `json:"requestsperday,omitempty"`
+	// MaxRequestBytes is the largest request body accepted, in bytes
+	MaxRequestBytes int `json:"maxrequestbytes,omitempty"`
+	// MaxResponseRows is the most body entries a single response will include
+	MaxResponseRows int `json:"maxresponserows,omitempty"`
+}
+
+// Merge returns a copy of l with non-zero fields of o taking precedence.
+// either can be nil
+func (l *APILimits) Merge(o *APILimits) APILimits {
+	res := APILimits{}
+	if l != nil {
+		res = *l
+	}
+	if o == nil {
+		return res
+	}
+	if o.RequestsPerMinute != 0 {
+		res.RequestsPerMinute = o.RequestsPerMinute
+	}
+	if o.RequestsPerDay != 0 {
+		res.RequestsPerDay = o.RequestsPerDay
+	}
+	if o.MaxRequestBytes != 0 {
+		res.MaxRequestBytes = o.MaxRequestBytes
+	}
+	if o.MaxResponseRows != 0 {
+		res.MaxResponseRows = o.MaxResponseRows
+	}
+	return res
+}
+
+// apiLimitsSchema is the schema for an APILimits object
+const apiLimitsSchema = `{
+        "description": "Bounds on requests from a single client. zero means no limit",
+        "type": "object",
+        "properties": {
+          "requestsperminute": { "type": "integer", "minimum": 0 },
+          "requestsperday": { "type": "integer", "minimum": 0 },
+          "maxrequestbytes": { "type": "integer", "minimum": 0 },
+          "maxresponserows": { "type": "integer", "minimum": 0 }
+        }
+      }`
+
 // Validate validates all fields of api returning all errors found.
 func (a API) Validate() error {
 	schema := jsonschema.Must(`{
@@ -68,6 +146,20 @@ func (a API) Validate() error {
         "description": "When true, requests that have X-Forwarded-Proto: http will be redirected to their https variant",
         "type": "boolean"
       },
+      "auth": {
+        "description": "When true, requests must carry an API token with a scope that permits the request",
+        "type": "boolean"
+      },
+      "limits": ` + apiLimitsSchema + `,
+      "tokenlimits": {
+        "description": "Overrides of limits for specific API tokens, keyed by token ID",
+        "type": "object",
+        "additionalProperties": ` + apiLimitsSchema + `
+      },
+      "uploads": {
+        "description": "Directory resumable uploads are staged in. relative paths are resolved against the qri repo path",
+        "type": "string"
+      },
       "allowedorigins": {
         "description": "Support CORS signing from a list of origins",
         "type": "array",
@@ -105,6 +197,20 @@ func (a *API) Copy() *API {
 		TLS:             a.TLS,
 		DisconnectAfter: a.DisconnectAfter,
 		ProxyForceHTTPS: a.ProxyForceHTTPS,
+		Auth:            a.Auth,
+		Limits:          a.Limits,
+		Uploads:         a.Uploads,
+	}
+	if a.TokenLimits != nil {
+		res.TokenLimits = map[string]*APILimits{}
+		for id, tl := range a.TokenLimits {
+			if tl == nil {
+				res.TokenLimits[id] = nil
+				continue
+			}
+			l := *tl
+			res.TokenLimits[id] = &l
+		}
 	}
 	if a.AllowedOrigins != nil {
 		res.AllowedOrigins = make([]string, len(a.AllowedOrigins))
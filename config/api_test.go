@@ -1,6 +1,7 @@
 package config
 
 import (
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -17,6 +18,12 @@ func TestAPICopy(t *testing.T) {
 		api *API
 	}{
 		{DefaultAPI()},
+		{&API{
+			AllowedOrigins: []string{"http://localhost"},
+			Limits:         APILimits{RequestsPerMinute: 60},
+			TokenLimits:    map[string]*APILimits{"abc": {RequestsPerDay: 10}},
+			Uploads:        "uploads",
+		}},
 	}
 	for i, c := range cases {
 		cpy := c.api.Copy()
@@ -31,3 +38,54 @@ func TestAPICopy(t *testing.T) {
 		}
 	}
 }
+
+func TestAPILimitsValidate(t *testing.T) {
+	a := DefaultAPI()
+	a.Limits = APILimits{RequestsPerMinute: 60, MaxRequestBytes: 1024}
+	a.TokenLimits = map[string]*APILimits{"abc": {MaxResponseRows: 100}}
+	if err := a.Validate(); err != nil {
+		t.Errorf("error validating api with limits: %s", err)
+	}
+
+	a.Limits.RequestsPerDay = -1
+	if err := a.Validate(); err == nil {
+		t.Error("expected negative limit to fail validation")
+	}
+}
+
+func TestAPILimitsMerge(t *testing.T) {
+	var none *APILimits
+	if got := none.Merge(nil); got != (APILimits{}) {
+		t.Errorf("expected merging nil limits to be empty, got: %#v", got)
+	}
+
+	base := &APILimits{RequestsPerMinute: 60, MaxRequestBytes: 1024}
+	got := base.Merge(&APILimits{RequestsPerMinute: 600, MaxResponseRows: 10})
+	expect := APILimits{RequestsPerMinute: 600, MaxRequestBytes: 1024, MaxResponseRows: 10}
+	if got != expect {
+		t.Errorf("merge mismatch. expected: %#v, got: %#v", expect, got)
+	}
+	if base.RequestsPerMinute != 60 {
+		t.Error("expected merge not to modify receiver")
+	}
+}
+
+func TestAPIUploadsDir(t *testing.T) {
+	abs, err := filepath.Abs("uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		uploads, repoPath, expect string
+	}{
+		{"", "/repo", filepath.Join("/repo", "uploads")},
+		{"uploads", "/repo", filepath.Join("/repo", "uploads")},
+		{abs, "/repo", abs},
+	}
+	for i, c := range cases {
+		got := API{Uploads: c.uploads}.UploadsDir(c.repoPath)
+		if got != c.expect {
+			t.Errorf("case %d expected: %s, got: %s", i, c.expect, got)
+		}
+	}
+}
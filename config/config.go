@@ -34,7 +34,8 @@ type Config struct {
 	RPC     *RPC
 	Logging *Logging
 
-	Render *Render
+	Render    *Render
+	Transform *Transform
 }
 
 // NOTE: The configuration returned by DefaultConfig is insufficient, as is, to run a functional
@@ -61,7 +62,8 @@ func DefaultConfig() *Config {
 		RPC:     DefaultRPC(),
 		Logging: DefaultLogging(),
 
-		Render: DefaultRender(),
+		Render:    DefaultRender(),
+		Transform: DefaultTransform(),
 	}
 }
 
@@ -78,7 +80,11 @@ func (cfg Config) SummaryString() (summary string) {
 	}
 
 	if cfg.RPC != nil && cfg.RPC.Enabled {
-		summary += fmt.Sprintf("RPC port:\t%d\n", cfg.RPC.Port)
+		if cfg.RPC.Socket != "" {
+			summary += fmt.Sprintf("RPC socket:\t%s\n", cfg.RPC.Socket)
+		} else {
+			summary += fmt.Sprintf("RPC port:\t%d\n", cfg.RPC.Port)
+		}
 	}
 
 	if cfg.Webapp != nil && cfg.Webapp.Enabled {
@@ -296,6 +302,11 @@ func (cfg Config) Validate() error {
 	if err := cfg.RPC.Validate(); err != nil {
 		return err
 	}
+	if cfg.Transform != nil {
+		if err := cfg.Transform.Validate(); err != nil {
+			return err
+		}
+	}
 	return cfg.Logging.Validate()
 }
 
@@ -338,6 +349,9 @@ func (cfg *Config) Copy() *Config {
 	if cfg.Render != nil {
 		res.Render = cfg.Render.Copy()
 	}
+	if cfg.Transform != nil {
+		res.Transform = cfg.Transform.Copy()
+	}
 
 	return res
 }
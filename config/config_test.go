@@ -203,6 +203,13 @@ func TestConfigValidate(t *testing.T) {
 	if err := l.Validate(); err == nil {
 		t.Error("When given bad input in Logging, config.Validate did not catch the error.")
 	}
+
+	// Transform:
+	tf := DefaultConfigForTesting()
+	tf.Transform.Timeout = "soon"
+	if err := tf.Validate(); err == nil {
+		t.Error("When given bad input in Transform, config.Validate did not catch the error.")
+	}
 }
 
 func TestConfigCopy(t *testing.T) {
@@ -1,22 +1,48 @@
 package config
 
-import "github.com/qri-io/jsonschema"
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/qri-io/jsonschema"
+)
 
 // RPC configures a Remote Procedure Call (RPC) listener
 type RPC struct {
 	Enabled bool `json:"enabled"`
 	Port    int  `json:"port"`
+	// Socket is the path of a unix domain socket to listen on. Relative paths
+	// are relative to the qri repo directory. When set Socket is used instead
+	// of Port
+	Socket string `json:"socket,omitempty"`
 }
 
 // DefaultRPCPort is local the port RPC serves on by default
 var DefaultRPCPort = 2504
 
+// DefaultRPCSocket is the unix domain socket RPC serves on by default, relative
+// to the qri repo directory
+var DefaultRPCSocket = "qri.sock"
+
 // DefaultRPC creates a new default RPC configuration
 func DefaultRPC() *RPC {
 	return &RPC{
 		Enabled: true,
 		Port:    DefaultRPCPort,
+		Socket:  DefaultRPCSocket,
+	}
+}
+
+// Addr gives the network & address to listen on or dial, resolving relative
+// socket paths against repoPath
+func (cfg RPC) Addr(repoPath string) (network, address string) {
+	if cfg.Socket != "" {
+		if filepath.IsAbs(cfg.Socket) {
+			return "unix", cfg.Socket
+		}
+		return "unix", filepath.Join(repoPath, cfg.Socket)
 	}
+	return "tcp", fmt.Sprintf(":%d", cfg.Port)
 }
 
 // Validate validates all fields of rpc returning all errors found.
@@ -34,8 +60,12 @@ func (cfg RPC) Validate() error {
         "type": "boolean"
       },
       "port": {
-        "description": "The port on which to listen for rpc calls",
+        "description": "The port on which to listen for rpc calls when socket is empty",
         "type": "integer"
+      },
+      "socket": {
+        "description": "Path of the unix domain socket on which to listen for rpc calls",
+        "type": "string"
       }
     }
   }`)
@@ -47,6 +77,7 @@ func (cfg *RPC) Copy() *RPC {
 	res := &RPC{
 		Enabled: cfg.Enabled,
 		Port:    cfg.Port,
+		Socket:  cfg.Socket,
 	}
 
 	return res
@@ -31,3 +31,24 @@ func TestRPCCopy(t *testing.T) {
 		}
 	}
 }
+
+func TestRPCAddr(t *testing.T) {
+	cases := []struct {
+		rpc              RPC
+		repoPath         string
+		network, address string
+	}{
+		{RPC{Port: 2504}, "/repo", "tcp", ":2504"},
+		{RPC{Port: 2504, Socket: "qri.sock"}, "/repo", "unix", "/repo/qri.sock"},
+		{RPC{Port: 2504, Socket: "/tmp/qri.sock"}, "/repo", "unix", "/tmp/qri.sock"},
+	}
+	for i, c := range cases {
+		network, address := c.rpc.Addr(c.repoPath)
+		if network != c.network {
+			t.Errorf("case %d network mismatch. expected: %s, got: %s", i, c.network, network)
+		}
+		if address != c.address {
+			t.Errorf("case %d address mismatch. expected: %s, got: %s", i, c.address, address)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/qri-io/jsonschema"
+)
+
+// Transform configures limits on the resources dataset transforms can use.
+// zero values don't limit. qri update runs transforms written by other
+// peers, so nodes that update datasets they don't control should set limits.
+// limits aren't a sandbox: steps & memory aren't limited, and starlark
+// transforms that time out keep running in the background, without network
+// access. starlark runs in an interpreter the startf package creates, which
+// doesn't expose a step limit or a way to stop it. exec transforms that time
+// out are killed
+type Transform struct {
+	// Timeout is the longest a transform can run, as a duration string like
+	// "30s"
+	Timeout string `json:"timeout"`
+	// MaxOutputRows is the most entries the body of a transform can have
+	MaxOutputRows int `json:"maxOutputRows"`
+	// MaxOutputBytes is the largest the body of a transform can be, in bytes
+	MaxOutputBytes int `json:"maxOutputBytes"`
+	// AllowedHosts lists the hosts transforms can make http requests to. an
+	// empty list allows any host
+	AllowedHosts []string `json:"allowedHosts"`
+	// AllowExec lets transforms with the "exec" syntax run local executables.
+	// exec transforms can do anything the user running qri can, so they only
+	// run for saves from the command line of scripts read from the local
+	// filesystem, and command line updates of versions the user committed,
+	// never for saves or updates over the API or jobs. AllowExec is only read
+	// from the config, limits given to a save can't set it
+	AllowExec bool `json:"allowExec"`
+}
+
+// DefaultTransform creates a new default Transform configuration, which
+// doesn't limit transforms
+func DefaultTransform() *Transform {
+	return &Transform{}
+}
+
+// Validate validates all fields of transform returning all errors found.
+func (cfg Transform) Validate() error {
+	schema := jsonschema.Must(`{
+    "$schema": "http://json-schema.org/draft-06/schema#",
+    "title": "Transform",
+    "description": "Limits on the resources transforms can use",
+    "type": "object",
+    "properties": {
+      "timeout": {
+        "description": "longest a transform can run, as a duration string like 30s",
+        "type": "string"
+      },
+      "maxOutputRows": {
+        "description": "most entries the body of a transform can have, 0 doesn't limit",
+        "type": "integer",
+        "minimum": 0
+      },
+      "maxOutputBytes": {
+        "description": "largest the body of a transform can be in bytes, 0 doesn't limit",
+        "type": "integer",
+        "minimum": 0
+      },
+      "allowedHosts": {
+        "description": "hosts transforms can make http requests to, an empty list allows any host",
+        "type": ["array", "null"],
+        "items": {
+          "type": "string"
+        }
+      },
+      "allowExec": {
+        "description": "let exec transforms run local executables when saving local scripts from the command line",
+        "type": "boolean"
+      }
+    }
+  }`)
+	if err := validate(schema, &cfg); err != nil {
+		return err
+	}
+	if cfg.Timeout != "" {
+		if _, err := time.ParseDuration(cfg.Timeout); err != nil {
+			return fmt.Errorf("invalid transform timeout: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// Copy returns a deep copy of the Transform struct
+func (cfg *Transform) Copy() *Transform {
+	res := &Transform{
+		Timeout:        cfg.Timeout,
+		MaxOutputRows:  cfg.MaxOutputRows,
+		MaxOutputBytes: cfg.MaxOutputBytes,
+		AllowExec:      cfg.AllowExec,
+	}
+	if cfg.AllowedHosts != nil {
+		res.AllowedHosts = make([]string, len(cfg.AllowedHosts))
+		copy(res.AllowedHosts, cfg.AllowedHosts)
+	}
+	return res
+}
+
+// Merge returns a copy of cfg with the set limits of o replacing it's own.
+// either may be nil. AllowExec always comes from cfg
+func (cfg *Transform) Merge(o *Transform) *Transform {
+	res := DefaultTransform()
+	if cfg != nil {
+		res = cfg.Copy()
+	}
+	if o == nil {
+		return res
+	}
+	if o.Timeout != "" {
+		res.Timeout = o.Timeout
+	}
+	if o.MaxOutputRows != 0 {
+		res.MaxOutputRows = o.MaxOutputRows
+	}
+	if o.MaxOutputBytes != 0 {
+		res.MaxOutputBytes = o.MaxOutputBytes
+	}
+	if o.AllowedHosts != nil {
+		res.AllowedHosts = o.Copy().AllowedHosts
+	}
+	return res
+}
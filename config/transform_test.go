@@ -0,0 +1,65 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransformValidate(t *testing.T) {
+	err := DefaultTransform().Validate()
+	if err != nil {
+		t.Errorf("error validating default transform: %s", err)
+	}
+
+	cases := []struct {
+		transform *Transform
+		err       string
+	}{
+		{&Transform{Timeout: "30s", MaxOutputRows: 10, AllowedHosts: []string{"example.com"}}, ""},
+		{&Transform{Timeout: "a while"}, "invalid transform timeout: time: "},
+	}
+	for i, c := range cases {
+		err := c.transform.Validate()
+		// error messages of the time package vary between go versions
+		if !(err == nil && c.err == "" || err != nil && c.err != "" && strings.HasPrefix(err.Error(), c.err)) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+		}
+	}
+}
+
+func TestTransformCopy(t *testing.T) {
+	cases := []struct {
+		transform *Transform
+	}{
+		{DefaultTransform()},
+		{&Transform{Timeout: "1m", MaxOutputRows: 10, MaxOutputBytes: 1024, AllowedHosts: []string{"example.com"}, AllowExec: true}},
+	}
+	for i, c := range cases {
+		cpy := c.transform.Copy()
+		if !reflect.DeepEqual(cpy, c.transform) {
+			t.Errorf("Transform Copy test case %v, transform structs are not equal: \ncopy: %v, \noriginal: %v", i, cpy, c.transform)
+			continue
+		}
+		cpy.Timeout = "foo"
+		if reflect.DeepEqual(cpy, c.transform) {
+			t.Errorf("Transform Copy test case %v, editing one transform struct should not affect the other: \ncopy: %v, \noriginal: %v", i, cpy, c.transform)
+			continue
+		}
+	}
+}
+
+func TestTransformMerge(t *testing.T) {
+	cfg := &Transform{Timeout: "1m", MaxOutputRows: 10, AllowedHosts: []string{"example.com"}}
+	// limits given with a request can't allow exec transforms
+	got := cfg.Merge(&Transform{Timeout: "5s", MaxOutputBytes: 1024, AllowExec: true})
+	expect := &Transform{Timeout: "5s", MaxOutputRows: 10, MaxOutputBytes: 1024, AllowedHosts: []string{"example.com"}}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("merge mismatch. expected: %v, got: %v", expect, got)
+	}
+
+	var none *Transform
+	if got := none.Merge(nil); !reflect.DeepEqual(got, DefaultTransform()) {
+		t.Errorf("expected merging nil limits to give default limits, got: %v", got)
+	}
+}
@@ -0,0 +1,521 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// builtins are the input types that can be named in variable definitions
+var builtins = map[string]Type{
+	"Int":     Int,
+	"Float":   Float,
+	"String":  String,
+	"Boolean": Boolean,
+	"ID":      ID,
+	"JSON":    JSON,
+}
+
+// Do parses & executes a query. Errors are reported in the result, with data
+// set to null if the query couldn't be executed
+func Do(s *Schema, p Params) *Result {
+	doc, err := parse(p.Query)
+	if err != nil {
+		if se, ok := err.(*SyntaxError); ok {
+			return &Result{Errors: []*Error{{Message: se.Error(), Locations: []Location{se.Location}}}}
+		}
+		return errResult(err)
+	}
+	op, err := doc.operation(p.OperationName)
+	if err != nil {
+		return errResult(err)
+	}
+	if op.kind != "query" {
+		return &Result{Errors: []*Error{{Message: fmt.Sprintf("%s operations aren't supported", op.kind), Locations: []Location{op.loc}}}}
+	}
+
+	if p.MaxDepth > 0 {
+		if depth := doc.depth(op.selections, map[string]int{}, map[string]bool{}); depth > p.MaxDepth {
+			return errResult(fmt.Errorf("query has a depth of %d, exceeding the maximum depth of %d", depth, p.MaxDepth))
+		}
+	}
+
+	ctx := p.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	e := &executor{schema: s, doc: doc, ctx: ctx, maxCost: p.MaxCost}
+	if e.vars, err = coerceVariables(op.vars, p.Variables); err != nil {
+		return errResult(err)
+	}
+
+	res := &Result{}
+	if data, ok := e.executeSelections(s.Query, nil, op.selections, nil); ok {
+		res.Data = data
+	}
+	res.Errors = e.errors
+	return res
+}
+
+func errResult(err error) *Result {
+	return &Result{Errors: []*Error{{Message: err.Error()}}}
+}
+
+// depth measures how deeply the fields of a selection set nest. depths of
+// fragments are memoized, visited guards against fragment cycles
+func (d *document) depth(sels []selection, memo map[string]int, visited map[string]bool) int {
+	max := 0
+	for _, sel := range sels {
+		n := 0
+		switch s := sel.(type) {
+		case *field:
+			n = 1 + d.depth(s.selections, memo, visited)
+		case *inlineFragment:
+			n = d.depth(s.selections, memo, visited)
+		case *fragmentSpread:
+			if m, ok := memo[s.name]; ok {
+				n = m
+			} else if f, ok := d.fragments[s.name]; ok && !visited[s.name] {
+				visited[s.name] = true
+				n = d.depth(f.selections, memo, visited)
+				visited[s.name] = false
+				memo[s.name] = n
+			}
+		}
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// operation picks the operation to execute from a document
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when a document contains more than one operation")
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation named '%s'", name)
+}
+
+// coerceVariables checks variable values against an operation's definitions,
+// filling in defaults
+func coerceVariables(defs []*varDef, values map[string]interface{}) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for _, def := range defs {
+		t, err := inputType(def.typ)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %s", def.name, err.Error())
+		}
+		v, ok := values[def.name]
+		if !ok {
+			if def.def != nil {
+				v = def.def
+			} else if _, nonNull := t.(*NonNull); nonNull {
+				return nil, fmt.Errorf("variable $%s of required type %s was not provided", def.name, t)
+			} else {
+				continue
+			}
+		}
+		if vars[def.name], err = coerceInput(t, v); err != nil {
+			return nil, fmt.Errorf("variable $%s: %s", def.name, err.Error())
+		}
+	}
+	return vars, nil
+}
+
+// inputType resolves a type named in a query document
+func inputType(ref *typeRef) (t Type, err error) {
+	if ref.list != nil {
+		var of Type
+		if of, err = inputType(ref.list); err != nil {
+			return nil, err
+		}
+		t = NewList(of)
+	} else if t = builtins[ref.name]; t == nil {
+		return nil, fmt.Errorf("unknown type %s", ref.name)
+	}
+	if ref.nonNull {
+		t = NewNonNull(t)
+	}
+	return t, nil
+}
+
+// coerceInput converts an input value to the Go value for a type
+func coerceInput(t Type, v interface{}) (interface{}, error) {
+	switch t := t.(type) {
+	case *NonNull:
+		if v == nil {
+			return nil, fmt.Errorf("expected non-null value of type %s", t)
+		}
+		return coerceInput(t.OfType, v)
+	case *List:
+		if v == nil {
+			return nil, nil
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			// single values are coerced to lists of one item
+			item, err := coerceInput(t.OfType, v)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		out := make([]interface{}, len(list))
+		for i, item := range list {
+			var err error
+			if out[i], err = coerceInput(t.OfType, item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case *Scalar:
+		if v == nil {
+			return nil, nil
+		}
+		if _, ok := v.(enum); ok && t != JSON {
+			return nil, fmt.Errorf("%s cannot represent enum value: %v", t, v)
+		}
+		return t.ParseValue(v)
+	}
+	return nil, fmt.Errorf("%s can't be used as an input type", t)
+}
+
+// executor holds the state of a single query execution
+type executor struct {
+	schema *Schema
+	doc    *document
+	vars   map[string]interface{}
+	ctx    context.Context
+	errors []*Error
+	// maxCost is the most values the query can resolve, cost counts values
+	// resolved so far
+	maxCost, cost int
+}
+
+// spend adds n to the cost of the query, recording an error the first time
+// the query goes over it's maximum cost. ok is false once the query is over
+func (e *executor) spend(n int, f *field, path []interface{}) (ok bool) {
+	if e.maxCost <= 0 {
+		return true
+	}
+	over := e.cost > e.maxCost
+	e.cost += n
+	if e.cost > e.maxCost {
+		if !over {
+			e.addErr(fmt.Errorf("query exceeds the maximum cost of %d values", e.maxCost), f, path)
+		}
+		return false
+	}
+	return true
+}
+
+func (e *executor) addErr(err error, f *field, path []interface{}) {
+	e.errors = append(e.errors, &Error{
+		Message:   err.Error(),
+		Locations: []Location{f.loc},
+		Path:      append([]interface{}(nil), path...),
+	})
+}
+
+// fieldGroup is the set of fields that share a response key
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+// executeSelections resolves a selection set on an object. ok is false when a
+// non-null field resolved to null, making the whole object null
+func (e *executor) executeSelections(t *Object, source interface{}, sels []selection, path []interface{}) (res interface{}, ok bool) {
+	var groups []*fieldGroup
+	if err := e.collect(t, sels, &groups, map[string]*fieldGroup{}, map[string]bool{}); err != nil {
+		e.errors = append(e.errors, &Error{Message: err.Error(), Path: append([]interface{}(nil), path...)})
+		return nil, false
+	}
+
+	out := newOrderedMap()
+	ok = true
+	for _, g := range groups {
+		v, fok := e.executeField(t, source, g, append(path, g.key))
+		if !fok {
+			ok = false
+		}
+		out.set(g.key, v)
+	}
+	if !ok {
+		return nil, false
+	}
+	return out, true
+}
+
+// collect groups the fields of a selection set by response key, expanding
+// fragments & applying @skip and @include
+func (e *executor) collect(t *Object, sels []selection, groups *[]*fieldGroup, index map[string]*fieldGroup, visited map[string]bool) error {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			include, err := e.include(s.directives)
+			if err != nil {
+				return err
+			}
+			if !include {
+				continue
+			}
+			g, ok := index[s.key()]
+			if !ok {
+				g = &fieldGroup{key: s.key()}
+				index[s.key()] = g
+				*groups = append(*groups, g)
+			}
+			g.fields = append(g.fields, s)
+		case *fragmentSpread:
+			include, err := e.include(s.directives)
+			if err != nil {
+				return err
+			}
+			if !include || visited[s.name] {
+				continue
+			}
+			visited[s.name] = true
+			f, ok := e.doc.fragments[s.name]
+			if !ok {
+				return fmt.Errorf("unknown fragment %s", s.name)
+			}
+			if f.typeCond != t.Name {
+				continue
+			}
+			if err := e.collect(t, f.selections, groups, index, visited); err != nil {
+				return err
+			}
+		case *inlineFragment:
+			include, err := e.include(s.directives)
+			if err != nil {
+				return err
+			}
+			if !include || (s.typeCond != "" && s.typeCond != t.Name) {
+				continue
+			}
+			if err := e.collect(t, s.selections, groups, index, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// include evaluates @skip & @include directives
+func (e *executor) include(dirs []*directive) (bool, error) {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			return false, fmt.Errorf("unknown directive @%s", d.name)
+		}
+		args, err := e.args(Args{"if": {Type: NewNonNull(Boolean)}}, d.args)
+		if err != nil {
+			return false, fmt.Errorf("directive @%s: %s", d.name, err.Error())
+		}
+		if args["if"].(bool) == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// args coerces the arguments given to a field, filling in defaults
+func (e *executor) args(defs Args, given []*argument) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	present := map[string]bool{}
+	for _, a := range given {
+		if defs[a.name] == nil {
+			return nil, fmt.Errorf("unknown argument %s", a.name)
+		}
+		if name, ok := a.value.(variable); ok {
+			v, set := e.vars[string(name)]
+			if !set {
+				continue
+			}
+			values[a.name] = v
+		} else {
+			values[a.name] = e.value(a.value)
+		}
+		present[a.name] = true
+	}
+
+	args := map[string]interface{}{}
+	for name, def := range defs {
+		if !present[name] {
+			if def.Default != nil {
+				args[name] = def.Default
+			} else if _, ok := def.Type.(*NonNull); ok {
+				return nil, fmt.Errorf("argument %s of type %s is required", name, def.Type)
+			}
+			continue
+		}
+		v, err := coerceInput(def.Type, values[name])
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %s", name, err.Error())
+		}
+		args[name] = v
+	}
+	return args, nil
+}
+
+// value substitutes variables in a parsed input value
+func (e *executor) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case variable:
+		return e.vars[string(v)]
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = e.value(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for key, item := range v {
+			out[key] = e.value(item)
+		}
+		return out
+	}
+	return v
+}
+
+// executeField resolves & completes the value of a field
+func (e *executor) executeField(t *Object, source interface{}, g *fieldGroup, path []interface{}) (interface{}, bool) {
+	f := g.fields[0]
+	if f.name == "__typename" {
+		return t.Name, true
+	}
+	def := t.Fields[f.name]
+	if def == nil {
+		e.addErr(fmt.Errorf("cannot query field %s on type %s", f.name, t.Name), f, path)
+		return nil, true
+	}
+	_, nonNull := def.Type.(*NonNull)
+	if !e.spend(1, f, path) {
+		return nil, !nonNull
+	}
+
+	named := namedType(def.Type)
+	if _, isObj := named.(*Object); isObj && len(f.selections) == 0 {
+		e.addErr(fmt.Errorf("field %s of type %s must have a selection of subfields", f.name, def.Type), f, path)
+		return nil, !nonNull
+	} else if !isObj && len(f.selections) > 0 {
+		e.addErr(fmt.Errorf("field %s of type %s can't have a selection of subfields", f.name, def.Type), f, path)
+		return nil, !nonNull
+	}
+
+	args, err := e.args(def.Args, f.args)
+	if err != nil {
+		e.addErr(err, f, path)
+		return nil, !nonNull
+	}
+	p := ResolveParams{Context: e.ctx, Source: source, Args: args}
+
+	var v interface{}
+	if err = e.ctx.Err(); err == nil {
+		if def.Resolve != nil {
+			v, err = def.Resolve(p)
+		} else {
+			v, err = DefaultResolve(p, f.name)
+		}
+	}
+	if err != nil {
+		e.addErr(err, f, path)
+		return nil, !nonNull
+	}
+	return e.complete(def.Type, g, v, path)
+}
+
+// complete converts a resolved value to the shape of its type. ok is false
+// when a non-null value is null, and the null must propagate to the parent
+func (e *executor) complete(t Type, g *fieldGroup, v interface{}, path []interface{}) (interface{}, bool) {
+	if nn, ok := t.(*NonNull); ok {
+		errs := len(e.errors)
+		c, ok := e.complete(nn.OfType, g, v, path)
+		if ok && c == nil {
+			if len(e.errors) == errs {
+				e.addErr(fmt.Errorf("cannot return null for non-nullable field %s", g.fields[0].name), g.fields[0], path)
+			}
+			return nil, false
+		}
+		return c, ok
+	}
+	if isNil(v) {
+		return nil, true
+	}
+
+	switch t := t.(type) {
+	case *List:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.addErr(fmt.Errorf("expected a list for field %s, got %T", g.fields[0].name, v), g.fields[0], path)
+			return nil, true
+		}
+		if !e.spend(rv.Len(), g.fields[0], path) {
+			return nil, true
+		}
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			item, ok := e.complete(t.OfType, g, rv.Index(i).Interface(), append(path, i))
+			if !ok {
+				return nil, true
+			}
+			out[i] = item
+		}
+		return out, true
+	case *Scalar:
+		s, err := t.Serialize(v)
+		if err != nil {
+			e.addErr(err, g.fields[0], path)
+			return nil, true
+		}
+		return s, true
+	case *Object:
+		var sels []selection
+		for _, f := range g.fields {
+			sels = append(sels, f.selections...)
+		}
+		res, ok := e.executeSelections(t, v, sels, path)
+		if !ok {
+			return nil, true
+		}
+		return res, true
+	}
+	e.addErr(fmt.Errorf("unsupported type %s", t), g.fields[0], path)
+	return nil, true
+}
+
+// namedType unwraps list & non-null types
+func namedType(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case *NonNull:
+			t = w.OfType
+		case *List:
+			t = w.OfType
+		default:
+			return t
+		}
+	}
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,332 @@
+// Package graphql executes GraphQL queries against a schema of Go resolver
+// functions. It implements the parts of the GraphQL spec qri's api needs:
+// queries with variables, aliases, fragments & the @skip and @include
+// directives. Mutations, subscriptions, interfaces, unions & introspection
+// beyond __typename aren't supported.
+//
+// Schemas are built from Object types, with a resolver for each field:
+//
+//	query := &graphql.Object{Name: "Query", Fields: graphql.Fields{
+//	  "hello": &graphql.Field{
+//	    Type: graphql.String,
+//	    Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+//	      return "world", nil
+//	    },
+//	  },
+//	}}
+//	res := graphql.Do(&graphql.Schema{Query: query}, graphql.Params{Query: "{ hello }"})
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Type is a GraphQL output or input type. Types are *Scalar, *Object, *List
+// or *NonNull
+type Type interface {
+	// String gives the type as it's written in a query, like [String!]
+	String() string
+}
+
+// Scalar is a leaf type
+type Scalar struct {
+	Name string
+	// Serialize converts a resolved value to a value that's encoded as JSON
+	Serialize func(v interface{}) (interface{}, error)
+	// ParseValue converts an argument or variable value to a Go value
+	ParseValue func(v interface{}) (interface{}, error)
+}
+
+// String implements the Type interface
+func (s *Scalar) String() string { return s.Name }
+
+// Object is a type with a set of named fields
+type Object struct {
+	Name        string
+	Description string
+	Fields      Fields
+}
+
+// String implements the Type interface
+func (o *Object) String() string { return o.Name }
+
+// Fields maps field names to definitions
+type Fields map[string]*Field
+
+// Field is a field of an object type
+type Field struct {
+	Type        Type
+	Description string
+	Args        Args
+	// Resolve produces the field's value. Fields without a resolver read the
+	// value from their parent, see DefaultResolve
+	Resolve ResolveFunc
+}
+
+// Args maps argument names to definitions
+type Args map[string]*Arg
+
+// Arg is an argument a field accepts
+type Arg struct {
+	Type        Type
+	Description string
+	// Default is used when the argument isn't given
+	Default interface{}
+}
+
+// List is a list of another type
+type List struct {
+	OfType Type
+}
+
+// NewList creates a list type
+func NewList(t Type) *List { return &List{OfType: t} }
+
+// String implements the Type interface
+func (l *List) String() string { return "[" + l.OfType.String() + "]" }
+
+// NonNull is a type that can't be null
+type NonNull struct {
+	OfType Type
+}
+
+// NewNonNull creates a non-null type
+func NewNonNull(t Type) *NonNull { return &NonNull{OfType: t} }
+
+// String implements the Type interface
+func (n *NonNull) String() string { return n.OfType.String() + "!" }
+
+// ResolveParams are passed to resolvers
+type ResolveParams struct {
+	Context context.Context
+	// Source is the value of the parent field
+	Source interface{}
+	// Args holds argument values, with defaults filled in
+	Args map[string]interface{}
+}
+
+// ResolveFunc resolves the value of a field
+type ResolveFunc func(p ResolveParams) (interface{}, error)
+
+// Schema describes the types that can be queried
+type Schema struct {
+	Query *Object
+}
+
+// Params are the inputs to executing a query
+type Params struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Context       context.Context        `json:"-"`
+	// MaxDepth limits how deeply the fields of a query can nest. 0 means no
+	// limit
+	MaxDepth int `json:"-"`
+	// MaxCost limits the number of values a query can resolve, counting each
+	// field & list item. 0 means no limit
+	MaxCost int `json:"-"`
+}
+
+// Result is the response to a query
+type Result struct {
+	Data   interface{} `json:"data"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is an error that occurred parsing or executing a query
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+// Error implements the error interface
+func (e *Error) Error() string { return e.Message }
+
+// Built-in scalar types. JSON is a custom scalar for values with no fixed
+// shape, like dataset bodies. JSON values are written as-is
+var (
+	Int = &Scalar{
+		Name:       "Int",
+		Serialize:  serializeInt,
+		ParseValue: serializeInt,
+	}
+	Float = &Scalar{
+		Name:       "Float",
+		Serialize:  serializeFloat,
+		ParseValue: serializeFloat,
+	}
+	String = &Scalar{
+		Name:       "String",
+		Serialize:  serializeString,
+		ParseValue: parseString,
+	}
+	Boolean = &Scalar{
+		Name:       "Boolean",
+		Serialize:  serializeBoolean,
+		ParseValue: serializeBoolean,
+	}
+	ID = &Scalar{
+		Name:      "ID",
+		Serialize: serializeString,
+		ParseValue: func(v interface{}) (interface{}, error) {
+			if i, ok := v.(int); ok {
+				return strconv.Itoa(i), nil
+			}
+			return parseString(v)
+		},
+	}
+	JSON = &Scalar{
+		Name:       "JSON",
+		Serialize:  func(v interface{}) (interface{}, error) { return v, nil },
+		ParseValue: func(v interface{}) (interface{}, error) { return v, nil },
+	}
+)
+
+func serializeInt(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := rv.Int(); i >= math.MinInt32 && i <= math.MaxInt32 {
+			return int(i), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i := rv.Uint(); i <= math.MaxInt32 {
+			return int(i), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		// numbers decoded from JSON are floats
+		if f := rv.Float(); f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxInt32 {
+			return int(f), nil
+		}
+	}
+	return nil, fmt.Errorf("Int cannot represent value: %v", v)
+}
+
+func serializeFloat(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return nil, fmt.Errorf("Float cannot represent value: %v", v)
+}
+
+func serializeString(v interface{}) (interface{}, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case fmt.Stringer:
+		return s.String(), nil
+	case bool, int, int64, float64:
+		return fmt.Sprintf("%v", s), nil
+	}
+	return nil, fmt.Errorf("String cannot represent value: %v", v)
+}
+
+func parseString(v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("String cannot represent value: %v", v)
+}
+
+func serializeBoolean(v interface{}) (interface{}, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("Boolean cannot represent value: %v", v)
+}
+
+// DefaultResolve reads a field from a source value. map keys & struct fields
+// match field names, struct fields are matched by their JSON names first
+func DefaultResolve(p ResolveParams, name string) (interface{}, error) {
+	if p.Source == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(p.Source)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, nil
+		}
+		v := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !v.IsValid() {
+			return nil, nil
+		}
+		return v.Interface(), nil
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(f.Tag.Get("json"), ",")[0]
+			if tag == name || (tag == "" && strings.EqualFold(f.Name, name)) {
+				return rv.Field(i).Interface(), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// orderedMap is a JSON object that keeps keys in insertion order. GraphQL
+// responses list fields in the order they were requested
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: map[string]interface{}{}}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,195 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+type testPerson struct {
+	Name    string `json:"name"`
+	Age     int    `json:"age"`
+	Friends []string
+}
+
+func testSchema() *Schema {
+	people := map[string]*testPerson{
+		"ada":   {Name: "Ada", Age: 36, Friends: []string{"grace"}},
+		"grace": {Name: "Grace", Age: 85, Friends: []string{"ada", "nobody"}},
+	}
+
+	person := &Object{Name: "Person"}
+	person.Fields = Fields{
+		"name": &Field{Type: NewNonNull(String)},
+		"age":  &Field{Type: Int},
+		"friends": &Field{
+			Type: NewList(NewNonNull(person)),
+			Args: Args{"first": {Type: Int, Default: 10}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				var friends []*testPerson
+				for _, name := range p.Source.(*testPerson).Friends {
+					friends = append(friends, people[name])
+				}
+				if first := p.Args["first"].(int); first < len(friends) {
+					friends = friends[:first]
+				}
+				return friends, nil
+			},
+		},
+		"bestFriend": &Field{
+			Type: person,
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				return nil, fmt.Errorf("it's complicated")
+			},
+		},
+	}
+
+	return &Schema{Query: &Object{Name: "Query", Fields: Fields{
+		"person": &Field{
+			Type: person,
+			Args: Args{"name": {Type: NewNonNull(String)}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				return people[p.Args["name"].(string)], nil
+			},
+		},
+		"numbers": &Field{
+			Type: NewList(Float),
+			Args: Args{"n": {Type: NewList(Float)}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				return p.Args["n"], nil
+			},
+		},
+		"json": &Field{
+			Type: JSON,
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				return json.RawMessage(`[1,{"a":true}]`), nil
+			},
+		},
+	}}}
+}
+
+func TestDo(t *testing.T) {
+	s := testSchema()
+	cases := []struct {
+		description string
+		params      Params
+		expect      string
+	}{
+		{"aliases & field order",
+			Params{Query: `{ b: person(name: "ada") { age name } a: person(name: "grace") { name } }`},
+			`{"data":{"b":{"age":36,"name":"Ada"},"a":{"name":"Grace"}}}`},
+		{"nested lists & default args",
+			Params{Query: `query { person(name: "ada") { friends { name friends(first: 1) { name } } } }`},
+			`{"data":{"person":{"friends":[{"name":"Grace","friends":[{"name":"Ada"}]}]}}}`},
+		{"variables",
+			Params{Query: `query Q($who: String!, $n: [Float] = [1]) { person(name: $who) { name } numbers(n: $n) }`, Variables: map[string]interface{}{"who": "grace"}},
+			`{"data":{"person":{"name":"Grace"},"numbers":[1]}}`},
+		{"single values coerce to lists",
+			Params{Query: `{ numbers(n: 2.5) }`},
+			`{"data":{"numbers":[2.5]}}`},
+		{"fragments, directives & __typename",
+			Params{Query: `
+				query ($skip: Boolean = true) {
+					person(name: "ada") { ...P age @include(if: false) ... on Person { __typename } }
+				}
+				fragment P on Person { name @skip(if: $skip) age }`},
+			`{"data":{"person":{"age":36,"__typename":"Person"}}}`},
+		{"operation name",
+			Params{Query: `query A { json } query B { person(name: "x") { name } }`, OperationName: "A"},
+			`{"data":{"json":[1,{"a":true}]}}`},
+		{"resolver errors are nulled",
+			Params{Query: `{ person(name: "ada") { name bestFriend { name } } }`},
+			`{"data":{"person":{"name":"Ada","bestFriend":null}},"errors":[{"message":"it's complicated","locations":[{"line":1,"column":30}],"path":["person","bestFriend"]}]}`},
+		{"non-null errors propagate to the nearest nullable parent",
+			Params{Query: `{ person(name: "grace") { friends { name } } }`},
+			`{"data":{"person":{"friends":null}},"errors":[{"message":"cannot return null for non-nullable field friends","locations":[{"line":1,"column":27}],"path":["person","friends",1]}]}`},
+		{"unknown fields",
+			Params{Query: `{ person(name: "ada") { height } }`},
+			`{"data":{"person":{"height":null}},"errors":[{"message":"cannot query field height on type Person","locations":[{"line":1,"column":25}],"path":["person","height"]}]}`},
+		{"missing required args",
+			Params{Query: `{ person { name } }`},
+			`{"data":{"person":null},"errors":[{"message":"argument name of type String! is required","locations":[{"line":1,"column":3}],"path":["person"]}]}`},
+		{"missing subselection",
+			Params{Query: `{ person(name: "ada") }`},
+			`{"data":{"person":null},"errors":[{"message":"field person of type Person must have a selection of subfields","locations":[{"line":1,"column":3}],"path":["person"]}]}`},
+		{"missing variables",
+			Params{Query: `query ($who: String!) { person(name: $who) { name } }`},
+			`{"data":null,"errors":[{"message":"variable $who of required type String! was not provided"}]}`},
+		{"syntax errors",
+			Params{Query: "{\n  person(name: \"ada\" { name } }"},
+			`{"data":null,"errors":[{"message":"syntax error at 2:22: expected name, found '{'","locations":[{"line":2,"column":22}]}]}`},
+		{"mutations",
+			Params{Query: `mutation { person(name: "ada") { name } }`},
+			`{"data":null,"errors":[{"message":"mutation operations aren't supported","locations":[{"line":1,"column":1}]}]}`},
+		{"ambiguous operations",
+			Params{Query: `query A { json } query B { json }`},
+			`{"data":null,"errors":[{"message":"operationName is required when a document contains more than one operation"}]}`},
+		{"max depth",
+			Params{Query: `{ person(name: "ada") { ...F } } fragment F on Person { friends { friends { name } } }`, MaxDepth: 3},
+			`{"data":null,"errors":[{"message":"query has a depth of 4, exceeding the maximum depth of 3"}]}`},
+		{"max cost",
+			Params{Query: `{ person(name: "ada") { name age } }`, MaxCost: 2},
+			`{"data":{"person":{"name":"Ada","age":null}},"errors":[{"message":"query exceeds the maximum cost of 2 values","locations":[{"line":1,"column":30}],"path":["person","age"]}]}`},
+	}
+
+	for _, c := range cases {
+		data, err := json.Marshal(Do(s, c.params))
+		if err != nil {
+			t.Errorf("case '%s' error encoding result: %s", c.description, err.Error())
+			continue
+		}
+		if string(data) != c.expect {
+			t.Errorf("case '%s' result mismatch.\nexpected: %s\ngot:      %s", c.description, c.expect, string(data))
+		}
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	doc, err := parse(`{ f(a: -1.5e2, b: "tab\there é", c: [1 2], d: {x: null}, e: FOO, g: """
+		block
+		  string
+	""") }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := doc.operations[0].selections[0].(*field).args
+	got := map[string]interface{}{}
+	for _, a := range args {
+		got[a.name] = a.value
+	}
+	expect := map[string]interface{}{
+		"a": -150.0,
+		"b": "tab\there é",
+		"c": []interface{}{1, 2},
+		"d": map[string]interface{}{"x": nil},
+		"e": enum("FOO"),
+		"g": "block\n  string",
+	}
+	if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", expect) {
+		t.Errorf("values mismatch.\nexpected: %#v\ngot:      %#v", expect, got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		query, err string
+	}{
+		{"", "syntax error at 1:1: document has no operations"},
+		{"{ }", "syntax error at 1:3: selection sets can't be empty"},
+		{`{ a(b: "c) }`, "syntax error at 1:8: unterminated string"},
+		{"{ a(b: 01x) }", "syntax error at 1:8: invalid number"},
+		{"query ($a: Int = $b) { a }", "syntax error at 1:18: variables aren't allowed in default values"},
+		{"fragment F on T { a } fragment F on T { b } { a }", "syntax error at 1:23: fragment F is defined more than once"},
+		{"{ a } ?", "syntax error at 1:7: unexpected character '?'"},
+	}
+	for i, c := range cases {
+		_, err := parse(c.query)
+		if err == nil {
+			t.Errorf("case %d expected error, got nil", i)
+			continue
+		}
+		if err.Error() != c.err {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err.Error())
+		}
+	}
+}
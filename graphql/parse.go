@@ -0,0 +1,766 @@
+package graphql
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Location is a position in a query document. Lines & columns start at 1
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// document is a parsed query document
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation is a query, mutation or subscription
+type operation struct {
+	kind       string
+	name       string
+	vars       []*varDef
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+// varDef declares a variable an operation accepts
+type varDef struct {
+	name string
+	typ  *typeRef
+	def  interface{}
+}
+
+// typeRef is a type named in a variable definition, like [String!]!
+type typeRef struct {
+	name    string
+	list    *typeRef
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.list != nil {
+		s = "[" + t.list.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// selection is one of *field, *fragmentSpread or *inlineFragment
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+// key is the name of a field in the response
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type argument struct {
+	name  string
+	value interface{}
+	loc   Location
+}
+
+type directive struct {
+	name string
+	args []*argument
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	loc        Location
+}
+
+type inlineFragment struct {
+	typeCond   string
+	directives []*directive
+	selections []selection
+}
+
+type fragment struct {
+	name       string
+	typeCond   string
+	selections []selection
+	loc        Location
+}
+
+// parsed values are Go values: nil, bool, int, float64, string, []interface{}
+// & map[string]interface{}, plus these types for variables & enum literals
+type (
+	variable string
+	enum     string
+)
+
+// SyntaxError is returned when a query document can't be parsed
+type SyntaxError struct {
+	Message  string
+	Location Location
+}
+
+// Error implements the error interface
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Location.Line, e.Location.Column, e.Message)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	loc   Location
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of document"
+	case tokString:
+		return strconv.Quote(t.value)
+	}
+	return "'" + t.value + "'"
+}
+
+// lexer splits a query document into tokens
+type lexer struct {
+	src       string
+	pos       int
+	line, col int
+}
+
+func (l *lexer) loc() Location {
+	return Location{Line: l.line, Column: l.col}
+}
+
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.pos < len(l.src); i++ {
+		if l.src[l.pos] == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) errorf(loc Location, format string, args ...interface{}) error {
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Location: loc}
+}
+
+// skipIgnored skips whitespace, commas & comments
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.advance(1)
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			l.pos += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	loc := l.loc()
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, loc: loc}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.advance(3)
+		return token{kind: tokPunct, value: "...", loc: loc}, nil
+	case strings.IndexByte("!$():=@[]{}|&", c) >= 0:
+		l.advance(1)
+		return token{kind: tokPunct, value: string(c), loc: loc}, nil
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.advance(1)
+		}
+		return token{kind: tokName, value: l.src[start:l.pos], loc: loc}, nil
+	case c == '-' || isDigit(c):
+		return l.number(loc)
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString(loc)
+		}
+		return l.str(loc)
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, l.errorf(loc, "unexpected character %q", r)
+}
+
+func (l *lexer) number(loc Location) (token, error) {
+	start := l.pos
+	kind := tokInt
+	if l.src[l.pos] == '-' {
+		l.advance(1)
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.advance(1)
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, l.errorf(loc, "invalid number")
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokFloat
+		l.advance(1)
+		if digits() == 0 {
+			return token{}, l.errorf(loc, "invalid number")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokFloat
+		l.advance(1)
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.advance(1)
+		}
+		if digits() == 0 {
+			return token{}, l.errorf(loc, "invalid number")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == '_' || l.src[l.pos] == '.' || isLetter(l.src[l.pos])) {
+		return token{}, l.errorf(loc, "invalid number")
+	}
+	return token{kind: kind, value: l.src[start:l.pos], loc: loc}, nil
+}
+
+func (l *lexer) str(loc Location) (token, error) {
+	l.advance(1)
+	buf := &bytes.Buffer{}
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.advance(1)
+			return token{kind: tokString, value: buf.String(), loc: loc}, nil
+		case c == '\n' || c == '\r':
+			return token{}, l.errorf(loc, "unterminated string")
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, l.errorf(loc, "unterminated string")
+			}
+			esc := l.src[l.pos+1]
+			switch esc {
+			case '"', '\\', '/':
+				buf.WriteByte(esc)
+			case 'b':
+				buf.WriteByte('\b')
+			case 'f':
+				buf.WriteByte('\f')
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case 'u':
+				if l.pos+6 > len(l.src) {
+					return token{}, l.errorf(l.loc(), "invalid unicode escape")
+				}
+				n, err := strconv.ParseUint(l.src[l.pos+2:l.pos+6], 16, 32)
+				if err != nil {
+					return token{}, l.errorf(l.loc(), "invalid unicode escape")
+				}
+				buf.WriteRune(rune(n))
+				l.advance(4)
+			default:
+				return token{}, l.errorf(l.loc(), "invalid escape sequence \\%c", esc)
+			}
+			l.advance(2)
+		default:
+			buf.WriteByte(c)
+			l.advance(1)
+		}
+	}
+	return token{}, l.errorf(loc, "unterminated string")
+}
+
+// blockString reads a """ delimited string. common indentation & leading and
+// trailing blank lines are removed
+func (l *lexer) blockString(loc Location) (token, error) {
+	l.advance(3)
+	buf := &bytes.Buffer{}
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			l.advance(3)
+			return token{kind: tokString, value: blockStringValue(buf.String()), loc: loc}, nil
+		case strings.HasPrefix(l.src[l.pos:], `\"""`):
+			buf.WriteString(`"""`)
+			l.advance(4)
+		default:
+			buf.WriteByte(l.src[l.pos])
+			l.advance(1)
+		}
+	}
+	return token{}, l.errorf(loc, "unterminated string")
+}
+
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.Replace(raw, "\r\n", "\n", -1), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+// parser builds a document from tokens, reading one token ahead
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// parse parses a query document
+func parse(src string) (*document, error) {
+	p := &parser{lex: &lexer{src: src, line: 1, col: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek(tokPunct, "{"):
+			loc := p.tok.loc
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: sels, loc: loc})
+		case p.peek(tokName, "fragment"):
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[f.name]; ok {
+				return nil, &SyntaxError{Message: fmt.Sprintf("fragment %s is defined more than once", f.name), Location: f.loc}
+			}
+			doc.fragments[f.name] = f
+		case p.peek(tokName, "query") || p.peek(tokName, "mutation") || p.peek(tokName, "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &SyntaxError{Message: "document has no operations", Location: p.tok.loc}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() (err error) {
+	p.tok, err = p.lex.next()
+	return
+}
+
+func (p *parser) peek(kind tokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+func (p *parser) unexpected() error {
+	return &SyntaxError{Message: fmt.Sprintf("unexpected %s", p.tok), Location: p.tok.loc}
+}
+
+// expect consumes a punctuator, erroring if the current token is anything else
+func (p *parser) expect(punct string) error {
+	if !p.peek(tokPunct, punct) {
+		return &SyntaxError{Message: fmt.Sprintf("expected '%s', found %s", punct, p.tok), Location: p.tok.loc}
+	}
+	return p.advance()
+}
+
+// skip consumes a punctuator if it's the current token
+func (p *parser) skip(punct string) (bool, error) {
+	if !p.peek(tokPunct, punct) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", &SyntaxError{Message: fmt.Sprintf("expected name, found %s", p.tok), Location: p.tok.loc}
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: p.tok.value, loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if p.tok.kind == tokName {
+		if op.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek(tokPunct, "(") {
+		if op.vars, err = p.varDefs(); err != nil {
+			return nil, err
+		}
+	}
+	if op.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if op.selections, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (p *parser) varDefs() (defs []*varDef, err error) {
+	if err = p.expect("("); err != nil {
+		return
+	}
+	for !p.peek(tokPunct, ")") {
+		if err = p.expect("$"); err != nil {
+			return
+		}
+		def := &varDef{}
+		if def.name, err = p.name(); err != nil {
+			return
+		}
+		if err = p.expect(":"); err != nil {
+			return
+		}
+		if def.typ, err = p.typeRef(); err != nil {
+			return
+		}
+		if ok, e := p.skip("="); e != nil {
+			return nil, e
+		} else if ok {
+			if def.def, err = p.value(true); err != nil {
+				return
+			}
+		}
+		defs = append(defs, def)
+	}
+	return defs, p.advance()
+}
+
+func (p *parser) typeRef() (t *typeRef, err error) {
+	t = &typeRef{}
+	if ok, e := p.skip("["); e != nil {
+		return nil, e
+	} else if ok {
+		if t.list, err = p.typeRef(); err != nil {
+			return
+		}
+		if err = p.expect("]"); err != nil {
+			return
+		}
+	} else if t.name, err = p.name(); err != nil {
+		return
+	}
+	t.nonNull, err = p.skip("!")
+	return
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	f := &fragment{loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.name == "on" {
+		return nil, &SyntaxError{Message: "fragments can't be named 'on'", Location: f.loc}
+	}
+	if !p.peek(tokName, "on") {
+		return nil, &SyntaxError{Message: fmt.Sprintf("expected 'on', found %s", p.tok), Location: p.tok.loc}
+	}
+	if err = p.advance(); err != nil {
+		return nil, err
+	}
+	if f.typeCond, err = p.name(); err != nil {
+		return nil, err
+	}
+	if _, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if f.selections, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *parser) selectionSet() (sels []selection, err error) {
+	if err = p.expect("{"); err != nil {
+		return
+	}
+	for !p.peek(tokPunct, "}") {
+		var sel selection
+		if p.peek(tokPunct, "...") {
+			sel, err = p.spread()
+		} else {
+			sel, err = p.field()
+		}
+		if err != nil {
+			return
+		}
+		sels = append(sels, sel)
+	}
+	if len(sels) == 0 {
+		return nil, &SyntaxError{Message: "selection sets can't be empty", Location: p.tok.loc}
+	}
+	return sels, p.advance()
+}
+
+func (p *parser) spread() (selection, error) {
+	loc := p.tok.loc
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokName && p.tok.value != "on" {
+		s := &fragmentSpread{name: p.tok.value, loc: loc}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var err error
+		s.directives, err = p.directives()
+		return s, err
+	}
+
+	f := &inlineFragment{}
+	var err error
+	if p.peek(tokName, "on") {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		if f.typeCond, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	f.selections, err = p.selectionSet()
+	return f, err
+}
+
+func (p *parser) field() (*field, error) {
+	f := &field{loc: p.tok.loc}
+	var err error
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if ok, e := p.skip(":"); e != nil {
+		return nil, e
+	} else if ok {
+		f.alias = f.name
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek(tokPunct, "(") {
+		if f.args, err = p.arguments(); err != nil {
+			return nil, err
+		}
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokPunct, "{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments() (args []*argument, err error) {
+	if err = p.expect("("); err != nil {
+		return
+	}
+	for !p.peek(tokPunct, ")") {
+		arg := &argument{loc: p.tok.loc}
+		if arg.name, err = p.name(); err != nil {
+			return
+		}
+		if err = p.expect(":"); err != nil {
+			return
+		}
+		if arg.value, err = p.value(false); err != nil {
+			return
+		}
+		args = append(args, arg)
+	}
+	if len(args) == 0 {
+		return nil, &SyntaxError{Message: "argument lists can't be empty", Location: p.tok.loc}
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() (dirs []*directive, err error) {
+	for p.peek(tokPunct, "@") {
+		if err = p.advance(); err != nil {
+			return
+		}
+		d := &directive{}
+		if d.name, err = p.name(); err != nil {
+			return
+		}
+		if p.peek(tokPunct, "(") {
+			if d.args, err = p.arguments(); err != nil {
+				return
+			}
+		}
+		dirs = append(dirs, d)
+	}
+	return
+}
+
+// value parses an input value. constant values can't contain variables
+func (p *parser) value(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokPunct:
+		switch tok.value {
+		case "$":
+			if constant {
+				return nil, &SyntaxError{Message: "variables aren't allowed in default values", Location: tok.loc}
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			return variable(name), err
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			list := []interface{}{}
+			for !p.peek(tokPunct, "]") {
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, p.advance()
+		case "{":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			obj := map[string]interface{}{}
+			for !p.peek(tokPunct, "}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err = p.expect(":"); err != nil {
+					return nil, err
+				}
+				if obj[name], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+			return obj, p.advance()
+		}
+	case tokInt:
+		i, err := strconv.Atoi(tok.value)
+		if err != nil {
+			return nil, &SyntaxError{Message: fmt.Sprintf("invalid integer %s", tok.value), Location: tok.loc}
+		}
+		return i, p.advance()
+	case tokFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, &SyntaxError{Message: fmt.Sprintf("invalid float %s", tok.value), Location: tok.loc}
+		}
+		return f, p.advance()
+	case tokString:
+		return tok.value, p.advance()
+	case tokName:
+		var v interface{}
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enum(tok.value)
+		}
+		return v, p.advance()
+	}
+	return nil, p.unexpected()
+}
@@ -48,6 +48,12 @@ func LoadConfig(streams ioes.IOStreams, path string) (err error) {
 		}
 	}
 
+	// configs written before transform limits don't have a transform section,
+	// add one so limits can be set with "qri config set"
+	if cfg.Transform == nil {
+		cfg.Transform = config.DefaultTransform()
+	}
+
 	Config = cfg
 
 	migrated, err := migrate.RunMigrations(streams, cfg)
@@ -1,9 +1,12 @@
 package lib
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"net/rpc"
+	"os"
+	"path/filepath"
 
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/dag"
@@ -11,14 +14,17 @@ import (
 	"github.com/qri-io/dsdiff"
 	"github.com/qri-io/jsonschema"
 	"github.com/qri-io/qri/actions"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/rev"
 )
 
 // DatasetRequests encapsulates business logic for working with Datasets on Qri
 type DatasetRequests struct {
-	cli  *rpc.Client
+	cli  *qrpc.Client
 	node *p2p.QriNode
 }
 
@@ -26,8 +32,8 @@ type DatasetRequests struct {
 func (DatasetRequests) CoreRequestsName() string { return "datasets" }
 
 // NewDatasetRequests creates a DatasetRequests pointer from either a repo
-// or an rpc.Client
-func NewDatasetRequests(node *p2p.QriNode, cli *rpc.Client) *DatasetRequests {
+// or a qrpc.Client
+func NewDatasetRequests(node *p2p.QriNode, cli *qrpc.Client) *DatasetRequests {
 	if node != nil && cli != nil {
 		panic(fmt.Errorf("both repo and client supplied to NewDatasetRequests"))
 	}
@@ -41,7 +47,6 @@ func NewDatasetRequests(node *p2p.QriNode, cli *rpc.Client) *DatasetRequests {
 // List returns this repo's datasets
 func (r *DatasetRequests) List(p *ListParams, res *[]repo.DatasetRef) error {
 	if r.cli != nil {
-		p.RPC = true
 		return r.cli.Call("DatasetRequests.List", p, res)
 	}
 
@@ -59,7 +64,7 @@ func (r *DatasetRequests) List(p *ListParams, res *[]repo.DatasetRef) error {
 		p.Offset = 0
 	}
 
-	replies, err := actions.ListDatasets(r.node, ds, p.Limit, p.Offset, p.RPC, p.Published)
+	replies, err := actions.ListDatasets(r.node, ds, p.Limit, p.Offset, p.Published)
 
 	*res = replies
 	return err
@@ -85,6 +90,25 @@ func (r *DatasetRequests) Get(ref *repo.DatasetRef, res *repo.DatasetRef) error
 	return nil
 }
 
+// Stats gets column statistics for a dataset version, see base.Stats
+func (r *DatasetRequests) Stats(ref *repo.DatasetRef, res *base.Stats) error {
+	if r.cli != nil {
+		return r.cli.Call("DatasetRequests.Stats", ref, res)
+	}
+
+	// Handle `qri use` to get the current default dataset.
+	if err := DefaultSelectedRef(r.node.Repo, ref); err != nil {
+		return err
+	}
+
+	stats, err := actions.DatasetStats(r.node, ref)
+	if err != nil {
+		return err
+	}
+	*res = *stats
+	return nil
+}
+
 // SaveParams encapsulates arguments to Save
 type SaveParams struct {
 	// dataset to create if both Dataset and DatasetPath are provided
@@ -107,21 +131,29 @@ type SaveParams struct {
 	ConvertFormatToPrev bool
 	// string of references to recall before saving
 	Recall string
-	// optional writer to have transform script record standard output to
-	// note: this won't work over RPC, only on local calls
-	ScriptOutput io.Writer
+	// absolute path to a JSON or YAML file of data quality rules to store with
+	// the saved version. versions keep the rules of the previous version if
+	// no rules are given
+	RulesPath string
+	// rules to store with the saved version, overrides RulesPath
+	Rules []*base.Rule
+	// if true, refuse to save a version that fails any of it's rules
+	Strict bool
+	// limits on the resources a transform can use, set limits override the
+	// transform limits of the config
+	Limits *config.Transform
+	// optional writer to have transform script record standard output to.
+	// writers aren't encoded, qrpc streams output to the caller instead
+	ScriptOutput io.Writer `json:"-"`
+	// optional context, cancelling it stops a running transform
+	Ctx context.Context `json:"-"`
 }
 
 // Save adds a history entry, updating a dataset
 // TODO - need to make sure users aren't forking by referencing commits other than tip
 func (r *DatasetRequests) Save(p *SaveParams, res *repo.DatasetRef) (err error) {
 	if r.cli != nil {
-		if p.ReturnBody {
-			// can't send an io.Reader interface over RPC
-			p.ReturnBody = false
-			log.Error("cannot return body bytes over RPC, disabling body return")
-		}
-		return r.cli.Call("DatasetRequests.Save", p, res)
+		return r.cli.CallContext(requestContext(p.Ctx), "DatasetRequests.Save", p, res)
 	}
 
 	if p.Private {
@@ -166,13 +198,29 @@ func (r *DatasetRequests) Save(p *SaveParams, res *repo.DatasetRef) (err error)
 		return fmt.Errorf("no changes to save")
 	}
 
-	ref, body, err := actions.SaveDataset(r.node, ds, p.Secrets, p.ScriptOutput, p.DryRun, true, p.ConvertFormatToPrev)
+	rules := p.Rules
+	if rules == nil && p.RulesPath != "" {
+		if rules, err = ReadRulesFile(p.RulesPath); err != nil {
+			return err
+		}
+	}
+
+	done := trackProgress(r.node, "save", fmt.Sprintf("%s/%s", ds.Peername, ds.Name))
+	defer func() { done(err) }()
+
+	opts := &actions.SaveDatasetOptions{
+		DryRun:              p.DryRun,
+		Pin:                 true,
+		ConvertFormatToPrev: p.ConvertFormatToPrev,
+		Rules:               rules,
+		Strict:              p.Strict,
+		Limits:              transformLimits(p.Limits),
+	}
+	ref, body, err := actions.SaveDataset(requestContext(p.Ctx), r.node, ds, p.Secrets, eventOutput(r.node, p.ScriptOutput), opts)
 	if err != nil {
 		log.Debugf("create ds error: %s\n", err.Error())
 		return err
 	}
-	// TODO - check to make sure RPC saves aren't horribly broken, and if not remove this
-	// ref.Dataset = p.Dataset.Encode()
 
 	if p.Publish {
 		var done bool
@@ -205,21 +253,40 @@ type UpdateParams struct {
 	Publish    bool
 	DryRun     bool
 	ReturnBody bool
-	// optional writer to have transform script record standard output to
-	// note: this won't work over RPC, only on local calls
-	ScriptOutput io.Writer
+	// limits on the resources a transform can use, set limits override the
+	// transform limits of the config
+	Limits *config.Transform
+	// optional writer to have transform script record standard output to.
+	// writers aren't encoded, qrpc streams output to the caller instead
+	ScriptOutput io.Writer `json:"-"`
+	// optional context, cancelling it stops a running transform
+	Ctx context.Context `json:"-"`
+}
+
+// requestContext returns ctx, falling back to a background context for
+// requests that don't set one
+func requestContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// transformLimits applies limits given with a request to the transform
+// limits of the config
+func transformLimits(limits *config.Transform) *config.Transform {
+	var cfg *config.Transform
+	if Config != nil {
+		cfg = Config.Transform
+	}
+	return cfg.Merge(limits)
 }
 
 // Update advances a dataset to the latest known version from either a peer or by
 // re-running a transform in the peer's namespace
-func (r *DatasetRequests) Update(p *UpdateParams, res *repo.DatasetRef) error {
+func (r *DatasetRequests) Update(p *UpdateParams, res *repo.DatasetRef) (err error) {
 	if r.cli != nil {
-		if p.ReturnBody {
-			// can't send an io.Reader interface over RPC
-			p.ReturnBody = false
-			log.Error("cannot return body bytes over RPC, disabling body return")
-		}
-		return r.cli.Call("DatasetRequests.Update", p, res)
+		return r.cli.CallContext(requestContext(p.Ctx), "DatasetRequests.Update", p, res)
 	}
 
 	ref, err := repo.ParseDatasetRef(p.Ref)
@@ -254,7 +321,10 @@ func (r *DatasetRequests) Update(p *UpdateParams, res *repo.DatasetRef) error {
 		ref.Dataset.Transform.Assign(recall.Transform)
 	}
 
-	result, body, err := actions.UpdateDataset(r.node, &ref, p.Secrets, p.ScriptOutput, p.DryRun, true)
+	done := trackProgress(r.node, "update", ref.AliasString())
+	defer func() { done(err) }()
+
+	result, body, err := actions.UpdateDataset(requestContext(p.Ctx), r.node, &ref, p.Secrets, transformLimits(p.Limits), eventOutput(r.node, p.ScriptOutput), p.DryRun, true)
 	if err != nil {
 		return err
 	}
@@ -281,6 +351,14 @@ func (r *DatasetRequests) SetPublishStatus(p *SetPublishStatusParams, res *bool)
 
 	ref := p.Ref
 	res = &ref.Published
+
+	op := "unpublish"
+	if ref.Published {
+		op = "publish"
+	}
+	done := trackProgress(r.node, op, ref.AliasString())
+	defer func() { done(err) }()
+
 	if err = actions.SetPublishStatus(r.node, ref, ref.Published); err != nil {
 		return err
 	}
@@ -405,6 +483,26 @@ type LookupParams struct {
 	Path          string
 	Limit, Offset int
 	All           bool
+	// Ref optionally identifies the dataset Path belongs to. If Ref isn't in
+	// the local repo the body page is requested from peers instead
+	Ref repo.DatasetRef
+	// Fields optionally limits each entry to a set of named fields
+	Fields []string
+	// LineDelimited writes JSON bodies as newline-delimited JSON. only used by
+	// LookupBodyTo
+	LineDelimited bool
+	// Encoding optionally writes the body in a body encoding that isn't a
+	// dataset.DataFormat, like base.XLSXEncoding. Format still determines the
+	// structure entries are read with
+	Encoding string
+}
+
+// encoding gives the body encoding to write with, if any
+func (p *LookupParams) encoding() string {
+	if p.LineDelimited {
+		return base.NDJSONEncoding
+	}
+	return p.Encoding
 }
 
 // LookupResult combines data with it's hashed path
@@ -424,7 +522,26 @@ func (r *DatasetRequests) LookupBody(p *LookupParams, data *LookupResult) (err e
 		return fmt.Errorf("invalid limit / offset settings")
 	}
 
-	bodyPath, bufData, err := actions.LookupBody(r.node, p.Path, p.Format, p.FormatConfig, p.Limit, p.Offset, p.All)
+	var (
+		bodyPath string
+		bufData  []byte
+	)
+
+	if ref := p.Ref; r.isRemoteRef(&ref) {
+		if p.All {
+			return fmt.Errorf("can't read all entries of a dataset that isn't in the local repo")
+		}
+		if p.Encoding != "" {
+			return fmt.Errorf("%s bodies can only be read from the local repo", p.Encoding)
+		}
+		bodyPath, bufData, err = actions.LookupRemoteBody(r.node, ref, p.Format, p.FormatConfig, p.Limit, p.Offset, p.Fields)
+	} else if p.Encoding != "" {
+		buf := &bytes.Buffer{}
+		bodyPath, err = actions.WriteBody(r.node, buf, p.Path, p.Format, p.FormatConfig, p.Limit, p.Offset, p.All, p.Encoding, p.Fields)
+		bufData = buf.Bytes()
+	} else {
+		bodyPath, bufData, err = actions.LookupBody(r.node, p.Path, p.Format, p.FormatConfig, p.Limit, p.Offset, p.All, p.Fields)
+	}
 	if err != nil {
 		return err
 	}
@@ -436,6 +553,76 @@ func (r *DatasetRequests) LookupBody(p *LookupParams, data *LookupResult) (err e
 	return nil
 }
 
+// LookupBodyTo writes the dataset body to w, returning the body's path.
+// Unlike LookupBody entries are streamed as they're read, so large bodies
+// never sit in memory, including over RPC. When All is true every entry from
+// Offset onward is written. Bodies that aren't in the local repo are fetched
+// as a single page, which is bounded by the p2p message size
+func (r *DatasetRequests) LookupBodyTo(w io.Writer, p *LookupParams) (bodyPath string, err error) {
+	if r.cli != nil {
+		err = r.WriteBody(&WriteBodyParams{LookupParams: *p, Out: w}, &bodyPath)
+		return bodyPath, err
+	}
+
+	if p.Limit < 0 || p.Offset < 0 {
+		return "", fmt.Errorf("invalid limit / offset settings")
+	}
+
+	if ref := p.Ref; r.isRemoteRef(&ref) {
+		if p.LineDelimited {
+			return "", fmt.Errorf("line-delimited bodies can only be read from the local repo")
+		}
+		res := &LookupResult{}
+		if err = r.LookupBody(p, res); err != nil {
+			return "", err
+		}
+		_, err = w.Write(res.Data)
+		return res.Path, err
+	}
+
+	return actions.WriteBody(r.node, w, p.Path, p.Format, p.FormatConfig, p.Limit, p.Offset, p.All, p.encoding(), p.Fields)
+}
+
+// WriteBodyParams are the parameters of WriteBody
+type WriteBodyParams struct {
+	LookupParams
+	// Out is the writer the body is written to
+	Out io.Writer
+}
+
+// WriteBody writes the dataset body to p.Out, setting bodyPath to the path of
+// the body. It's how LookupBodyTo streams bodies over RPC: writes are sent to
+// the caller as they're made
+func (r *DatasetRequests) WriteBody(p *WriteBodyParams, bodyPath *string) (err error) {
+	if r.cli != nil {
+		return r.cli.Call("DatasetRequests.WriteBody", p, bodyPath)
+	}
+	if p.Out == nil {
+		return fmt.Errorf("an output writer is required")
+	}
+
+	*bodyPath, err = r.LookupBodyTo(p.Out, &p.LookupParams)
+	return err
+}
+
+// isRemoteRef canonicalizes ref, reporting if it names a dataset that isn't in
+// the local repo. CanonicalizeDatasetRef doesn't look up refs that are already
+// complete, so the refstore is always checked. Refs without a name can only
+// be read locally
+func (r *DatasetRequests) isRemoteRef(ref *repo.DatasetRef) bool {
+	if ref.IsEmpty() {
+		return false
+	}
+	if err := repo.CanonicalizeDatasetRef(r.node.Repo, ref); err == repo.ErrNotFound {
+		return ref.Name != ""
+	}
+	if ref.Name == "" {
+		return false
+	}
+	_, err := r.node.Repo.GetRef(repo.DatasetRef{Peername: ref.Peername, ProfileID: ref.ProfileID, Name: ref.Name})
+	return err == repo.ErrNotFound
+}
+
 // Add adds an existing dataset to a peer's repository
 func (r *DatasetRequests) Add(ref *repo.DatasetRef, res *repo.DatasetRef) (err error) {
 	if r.cli != nil {
@@ -490,6 +677,40 @@ func (r *DatasetRequests) Validate(p *ValidateDatasetParams, errors *[]jsonschem
 	return
 }
 
+// CheckRulesParams defines parameters for checking a dataset against data
+// quality rules
+type CheckRulesParams struct {
+	Ref repo.DatasetRef
+	// absolute path to a JSON or YAML file of rules to check instead of the
+	// rules stored with the dataset
+	RulesPath string
+}
+
+// CheckRules checks a dataset version against data quality rules, giving a
+// result for each rule
+func (r *DatasetRequests) CheckRules(p *CheckRulesParams, res *[]*base.RuleResult) (err error) {
+	if r.cli != nil {
+		return r.cli.Call("DatasetRequests.CheckRules", p, res)
+	}
+
+	if err = DefaultSelectedRef(r.node.Repo, &p.Ref); err != nil {
+		return
+	}
+	if p.Ref.IsEmpty() {
+		return NewError(ErrBadArgs, "please provide a dataset name")
+	}
+
+	var rules []*base.Rule
+	if p.RulesPath != "" {
+		if rules, err = ReadRulesFile(p.RulesPath); err != nil {
+			return
+		}
+	}
+
+	*res, err = actions.CheckRules(r.node, &p.Ref, rules)
+	return
+}
+
 // DiffParams defines parameters for diffing two datasets with Diff
 type DiffParams struct {
 	// The pointers to the datasets to diff
@@ -497,11 +718,13 @@ type DiffParams struct {
 	// override flag to diff full dataset without having to specify each component
 	DiffAll bool
 	// if DiffAll is false, DiffComponents specifies which components of a dataset to diff
-	// currently supported components include "structure", "data", "meta", "transform", and "viz"
+	// currently supported components include "structure", "data", "meta", "transform", "viz" and "stats"
 	DiffComponents map[string]bool
 }
 
-// Diff computes the diff of two datasets
+// Diff computes the diff of two datasets. When diffing all components
+// an actions.StatsUnavailableError is returned with the diffs of every other
+// component if stats can't be compared
 func (r *DatasetRequests) Diff(p *DiffParams, diffs *map[string]*dsdiff.SubDiff) (err error) {
 	refs := []repo.DatasetRef{}
 
@@ -524,6 +747,61 @@ func (r *DatasetRequests) Diff(p *DiffParams, diffs *map[string]*dsdiff.SubDiff)
 	return
 }
 
+// TestTransformParams defines parameters for testing a transform script
+type TestTransformParams struct {
+	// absolute path to the transform script
+	ScriptPath string
+	// transform syntax of the script, optional. see actions.TestTransform
+	Syntax string
+	// absolute path to a JSON or YAML transform fixture, optional
+	FixturePath string
+	// dataset the transform runs on, optional. output is diffed against it's
+	// head
+	Ref repo.DatasetRef
+	// limits on the resources the transform can use, set limits override the
+	// transform limits of the config
+	Limits *config.Transform
+	// optional writer to have transform script record standard output to.
+	// writers aren't encoded, qrpc streams output to the caller instead
+	ScriptOutput io.Writer `json:"-"`
+	// optional context, cancelling it stops a running transform
+	Ctx context.Context `json:"-"`
+}
+
+// TestTransform runs a transform script against the inputs of a fixture
+// without saving, checking the output against the fixture's expectations
+func (r *DatasetRequests) TestTransform(p *TestTransformParams, res *actions.TransformTestResult) (err error) {
+	if r.cli != nil {
+		return r.cli.CallContext(requestContext(p.Ctx), "DatasetRequests.TestTransform", p, res)
+	}
+	if p.ScriptPath == "" {
+		return NewError(ErrBadArgs, "please provide a transform script")
+	}
+	f, err := os.Open(p.ScriptPath)
+	if err != nil {
+		return fmt.Errorf("error opening transform script: %s", err.Error())
+	}
+	defer f.Close()
+
+	var fixture *actions.TransformFixture
+	if p.FixturePath != "" {
+		if fixture, err = ReadTransformFixtureFile(p.FixturePath); err != nil {
+			return
+		}
+	}
+	var ref *repo.DatasetRef
+	if !p.Ref.IsEmpty() {
+		ref = &p.Ref
+	}
+
+	result, err := actions.TestTransform(requestContext(p.Ctx), r.node, cafs.NewMemfileReader(filepath.Base(p.ScriptPath), f), p.Syntax, fixture, ref, transformLimits(p.Limits), p.ScriptOutput)
+	if err != nil {
+		return
+	}
+	*res = *result
+	return
+}
+
 // Manifest generates a manifest for a dataset path
 func (r *DatasetRequests) Manifest(refstr *string, m *dag.Manifest) (err error) {
 	if r.cli != nil {
@@ -561,3 +839,29 @@ func (r *DatasetRequests) ManifestMissing(a, b *dag.Manifest) (err error) {
 	*b = *mf
 	return
 }
+
+// trackProgress publishes a "started" progress event for an operation,
+// returning a function that publishes & counts the operation's outcome
+func trackProgress(node *p2p.QriNode, op, ref string) func(err error) {
+	node.PublishEvent(p2p.NEProgress, p2p.Progress{Op: op, Ref: ref, Status: "started"})
+	return func(err error) {
+		pr := p2p.Progress{Op: op, Ref: ref, Status: "done"}
+		result := "succeeded"
+		if err != nil {
+			pr.Status = "failed"
+			pr.Message = err.Error()
+			result = "failed"
+		}
+		operations.With(op, result).Inc()
+		node.PublishEvent(p2p.NEProgress, pr)
+	}
+}
+
+// eventOutput tees transform script output to node event subscribers
+func eventOutput(node *p2p.QriNode, w io.Writer) io.Writer {
+	ew := node.EventWriter(p2p.NETransformOutput)
+	if w == nil {
+		return ew
+	}
+	return io.MultiWriter(w, ew)
+}
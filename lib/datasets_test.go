@@ -5,8 +5,11 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"testing"
@@ -18,10 +21,13 @@ import (
 	"github.com/qri-io/dataset/dstest"
 	"github.com/qri-io/dsdiff"
 	"github.com/qri-io/jsonschema"
+	"github.com/qri-io/qri/actions"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/p2p"
 	"github.com/qri-io/qri/p2p/test"
 	"github.com/qri-io/qri/repo"
+	"github.com/qri-io/qri/repo/profile"
 	testrepo "github.com/qri-io/qri/repo/test"
 	"github.com/qri-io/qri/rev"
 	regmock "github.com/qri-io/registry/regserver/mock"
@@ -560,6 +566,12 @@ func TestDatasetRequestsLookupBody(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error getting sitemap ref: %s", err.Error())
 	}
+	remoteRef := repo.DatasetRef{
+		Peername:  "other",
+		ProfileID: profile.IDB58MustDecode("QmY1PxkV9t9RoBwtXHfue1Qf6iYob19nL6rDHuXxooAVZa"),
+		Name:      "movies",
+		Path:      moviesRef.Path,
+	}
 
 	var df1 = dataset.JSONDataFormat
 	cases := []struct {
@@ -574,6 +586,10 @@ func TestDatasetRequestsLookupBody(t *testing.T) {
 		{&LookupParams{Format: df1, Path: clRef.Path, Limit: 0, Offset: 0, All: true}, 0, ""},
 		{&LookupParams{Format: df1, Path: clRef.Path, Limit: 2, Offset: 0, All: false}, 2, ""},
 		{&LookupParams{Format: df1, Path: sitemapRef.Path, Limit: 3, Offset: 0, All: false}, 3, ""},
+		// complete refs are checked against the local repo
+		{&LookupParams{Format: df1, Path: moviesRef.Path, Ref: moviesRef, Limit: 2}, 2, ""},
+		// complete refs that aren't local are requested from peers
+		{&LookupParams{Format: df1, Path: moviesRef.Path, Ref: remoteRef, Limit: 2}, 0, "no p2p connection"},
 	}
 
 	req := NewDatasetRequests(node, nil)
@@ -609,6 +625,69 @@ func TestDatasetRequestsLookupBody(t *testing.T) {
 	}
 }
 
+func TestDatasetRequestsLookupBodyTo(t *testing.T) {
+	rc, _ := regmock.NewMockServer()
+	mr, err := testrepo.NewTestRepo(rc)
+	if err != nil {
+		t.Fatalf("error allocating test repo: %s", err.Error())
+	}
+	node, err := p2p.NewQriNode(mr, config.DefaultP2PForTesting())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	moviesRef, err := mr.GetRef(repo.DatasetRef{Peername: "peer", Name: "movies"})
+	if err != nil {
+		t.Fatalf("error getting movies ref: %s", err.Error())
+	}
+
+	cases := []struct {
+		p     *LookupParams
+		lines int
+		err   string
+	}{
+		{&LookupParams{Format: dataset.JSONDataFormat, Path: moviesRef.Path, Limit: -1}, 0, "invalid limit / offset settings"},
+		{&LookupParams{Format: dataset.CSVDataFormat, Path: moviesRef.Path, Limit: 5, Offset: 2}, 5, ""},
+		{&LookupParams{Format: dataset.JSONDataFormat, Path: moviesRef.Path, Limit: 3, LineDelimited: true}, 3, ""},
+		{&LookupParams{Format: dataset.CSVDataFormat, Path: moviesRef.Path, Limit: 3, LineDelimited: true}, 0, "line-delimited output requires json format, got: csv"},
+	}
+
+	req := NewDatasetRequests(node, nil)
+	for i, c := range cases {
+		buf := &bytes.Buffer{}
+		path, err := req.LookupBodyTo(buf, c.p)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch: expected: %s, got: %s", i, c.err, err)
+			continue
+		}
+		if c.err != "" {
+			continue
+		}
+		if path == "" {
+			t.Errorf("case %d expected body path to be returned", i)
+		}
+		if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != c.lines {
+			t.Errorf("case %d expected %d lines, got: %d", i, c.lines, lines)
+		}
+	}
+
+	path := ""
+	if err := req.WriteBody(&WriteBodyParams{LookupParams: LookupParams{Path: moviesRef.Path}}, &path); err == nil {
+		t.Error("expected writing a body without an output writer to error")
+	}
+	buf := &bytes.Buffer{}
+	p := &WriteBodyParams{LookupParams: LookupParams{Format: dataset.CSVDataFormat, Path: moviesRef.Path, Limit: 4}, Out: buf}
+	if err := req.WriteBody(p, &path); err != nil {
+		t.Fatal(err)
+	}
+	if path == "" {
+		t.Error("expected body path to be set")
+	}
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 4 {
+		t.Errorf("expected 4 lines, got: %d", lines)
+	}
+}
+
 func TestDatasetRequestsAdd(t *testing.T) {
 	cases := []struct {
 		p   *repo.DatasetRef
@@ -773,6 +852,104 @@ Pirates of the Caribbean: At World's End ,foo
 	}
 }
 
+func TestDatasetRequestsCheckRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qri_test_check_rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rulesPath := filepath.Join(dir, "rules.yaml")
+	if err := ioutil.WriteFile(rulesPath, []byte("- type: rowCount\n  min: 1\n- type: range\n  columns: [duration]\n  min: 0\n"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		p          CheckRulesParams
+		numResults int
+		err        string
+	}{
+		{CheckRulesParams{Ref: repo.DatasetRef{}}, 0, "bad arguments provided"},
+		{CheckRulesParams{Ref: repo.DatasetRef{Peername: "me", Name: "movies"}}, 0, ""},
+		{CheckRulesParams{Ref: repo.DatasetRef{Peername: "me", Name: "movies"}, RulesPath: rulesPath}, 2, ""},
+		{CheckRulesParams{Ref: repo.DatasetRef{Peername: "me", Name: "movies"}, RulesPath: "/not/a/file.yaml"}, 0, "error reading rules file: open /not/a/file.yaml: no such file or directory"},
+	}
+
+	mr, err := testrepo.NewTestRepo(nil)
+	if err != nil {
+		t.Fatalf("error allocating test repo: %s", err.Error())
+	}
+	node, err := p2p.NewQriNode(mr, config.DefaultP2PForTesting())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	req := NewDatasetRequests(node, nil)
+	for i, c := range cases {
+		got := []*base.RuleResult{}
+		err := req.CheckRules(&c.p, &got)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch: expected: %s, got: %v", i, c.err, err)
+			continue
+		}
+		if len(got) != c.numResults {
+			t.Errorf("case %d result count mismatch. expected: %d, got: %d", i, c.numResults, len(got))
+		}
+	}
+}
+
+func TestDatasetRequestsTestTransform(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qri_test_transform_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scriptPath := filepath.Join(dir, "transform.star")
+	if err := ioutil.WriteFile(scriptPath, []byte("def transform(ds, ctx):\n  ds.set_body(ds.get_body() + [ctx.get_secret('token')])\n"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	fixturePath := filepath.Join(dir, "fixture.yaml")
+	if err := ioutil.WriteFile(fixturePath, []byte("prevBody: [a]\nsecrets:\n  token: b\ncassette: cassette.json\nexpect:\n  body: [a, b]\n"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		p        TestTransformParams
+		failures int
+		err      string
+	}{
+		{TestTransformParams{}, 0, "bad arguments provided"},
+		{TestTransformParams{ScriptPath: scriptPath, FixturePath: "/not/a/fixture.yaml"}, 0, "error reading transform fixture file: open /not/a/fixture.yaml: no such file or directory"},
+		{TestTransformParams{ScriptPath: scriptPath, FixturePath: fixturePath}, 0, ""},
+	}
+
+	mr, err := testrepo.NewTestRepo(nil)
+	if err != nil {
+		t.Fatalf("error allocating test repo: %s", err.Error())
+	}
+	node, err := p2p.NewQriNode(mr, config.DefaultP2PForTesting())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	req := NewDatasetRequests(node, nil)
+	for i, c := range cases {
+		got := actions.TransformTestResult{}
+		err := req.TestTransform(&c.p, &got)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch: expected: %s, got: %v", i, c.err, err)
+			continue
+		}
+		if len(got.Failures) != c.failures {
+			t.Errorf("case %d failure count mismatch. expected: %d, got: %v", i, c.failures, got.Failures)
+		}
+	}
+
+	// cassettes are relative to the fixture
+	if _, err := os.Stat(filepath.Join(dir, "cassette.json")); err != nil {
+		t.Errorf("expected a cassette to be recorded next to the fixture: %s", err)
+	}
+}
+
 func TestDatasetRequestsDiff(t *testing.T) {
 	rc, _ := regmock.NewMockServer()
 	mr, err := testrepo.NewTestRepo(rc)
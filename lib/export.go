@@ -1,28 +1,37 @@
 package lib
 
 import (
+	"archive/zip"
+	"encoding/json"
 	"fmt"
-	"net/rpc"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 
-	"github.com/qri-io/dataset/dsutil"
+	"github.com/ghodss/yaml"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsfs"
 	"github.com/qri-io/qri/actions"
+	"github.com/qri-io/qri/base"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
+	"github.com/qri-io/qri/repo"
 )
 
 // ExportRequests encapsulates business logic of export operation
 type ExportRequests struct {
 	node *p2p.QriNode
-	cli  *rpc.Client
+	cli  *qrpc.Client
 }
 
 // CoreRequestsName implements the Requests interface
 func (r ExportRequests) CoreRequestsName() string { return "export" }
 
 // NewExportRequests creates a ExportRequests pointer from either a repo
-// or an rpc.Client
-func NewExportRequests(node *p2p.QriNode, cli *rpc.Client) *ExportRequests {
+// or a qrpc.Client
+func NewExportRequests(node *p2p.QriNode, cli *qrpc.Client) *ExportRequests {
 	if node != nil && cli != nil {
 		panic(fmt.Errorf("both node and client supplied to NewExportRequests"))
 	}
@@ -32,26 +41,56 @@ func NewExportRequests(node *p2p.QriNode, cli *rpc.Client) *ExportRequests {
 	}
 }
 
-// Export exports a dataset in the specified format
-func (r *ExportRequests) Export(p *ExportParams, ok *bool) error {
+// Export writes a dataset to either a directory or a zip archive named after
+// the dataset. Exports contain a dataset document, the body, a ref.txt file
+// and any transform & viz scripts. Paths in the dataset document are relative
+// to the document, so an export can be saved again with `qri save --file`.
+// With p.History set previous versions are written to history/1 (the first
+// version), history/2 and so on, using the same layout.
+// fileWritten is set to the path of the directory or archive
+func (r *ExportRequests) Export(p *ExportParams, fileWritten *string) (err error) {
 	if r.cli != nil {
-		return r.cli.Call("ExportRequests.Export", p, ok)
+		return r.cli.Call("ExportRequests.Export", p, fileWritten)
+	}
+
+	format := p.Format
+	if format == "" {
+		format = "yaml"
+	} else if format != "yaml" && format != "json" {
+		return fmt.Errorf("%s is not an accepted format, options are yaml and json", format)
+	}
+	if p.Zipped {
+		// zip archives are read back with a dataset.json file
+		format = "json"
+	}
+
+	bodyFormat, bodyEncoding := dataset.UnknownDataFormat, ""
+	switch p.BodyFormat {
+	case base.NDJSONEncoding, base.XLSXEncoding, base.ParquetEncoding:
+		bodyFormat, bodyEncoding = dataset.JSONDataFormat, p.BodyFormat
+	case "":
+	default:
+		if bodyFormat, err = dataset.ParseDataFormatString(p.BodyFormat); err != nil {
+			return err
+		}
 	}
 
 	ref := p.Ref
 
 	// Handle `qri use` to get the current default dataset.
-	if err := DefaultSelectedRef(r.node.Repo, &ref); err != nil {
+	if err = DefaultSelectedRef(r.node.Repo, &ref); err != nil {
 		return err
 	}
 
-	if err := actions.DatasetHead(r.node, &ref); err != nil {
+	if err = actions.DatasetHead(r.node, &ref); err != nil {
 		return err
 	}
 
-	ds, err := ref.DecodeDataset()
-	if err != nil {
-		return err
+	versions := []repo.DatasetRef{ref}
+	if p.History {
+		if versions, err = base.DatasetLog(r.node.Repo, ref, -1, 0, true); err != nil {
+			return err
+		}
 	}
 
 	profile, err := r.node.Repo.Profile()
@@ -59,119 +98,296 @@ func (r *ExportRequests) Export(p *ExportParams, ok *bool) error {
 		return err
 	}
 
-	// TODO (dlong): The -o option, once it is implemened, can be used to calculate `path`.
-	path := p.RootDir
+	// TODO (dlong): The -o option, once it is implemened, can be used to calculate `exportPath`.
+	exportPath := p.RootDir
 	if p.PeerDir {
 		peerName := ref.Peername
 		if peerName == "me" {
 			peerName = profile.Peername
 		}
-		path = filepath.Join(path, peerName)
+		exportPath = filepath.Join(exportPath, peerName)
 	}
-	path = filepath.Join(path, ref.Name)
+	exportPath = filepath.Join(exportPath, ref.Name)
 
-	// TODO (dlong): When --zip flag is not required, don't always do this.
-	dst, err := os.Create(fmt.Sprintf("%s.zip", path))
-	if err != nil {
+	var w exportWriter
+	if p.Zipped {
+		exportPath = fmt.Sprintf("%s.zip", exportPath)
+		if w, err = newZipExportWriter(exportPath); err != nil {
+			return err
+		}
+	} else {
+		w = &dirExportWriter{root: exportPath}
+	}
+
+	ex := &exporter{
+		w:            w,
+		store:        r.node.Repo.Store(),
+		format:       format,
+		bodyFormat:   bodyFormat,
+		bodyEncoding: bodyEncoding,
+		noBody:       p.NoBody,
+	}
+
+	// versions are ordered newest-first, with the head at index 0
+	for i, v := range versions {
+		dir := ""
+		if i > 0 {
+			dir = path.Join("history", fmt.Sprintf("%d", len(versions)-i))
+		}
+		if err = ex.writeVersion(dir, v); err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	if err = w.Close(); err != nil {
 		return err
 	}
+	*fileWritten = exportPath
+	return nil
+}
+
+// exportWriter creates the files of an export. names are slash-separated and
+// relative to the root of the export. Writers returned by Create are only
+// valid until the next call to Create or Close
+type exportWriter interface {
+	Create(name string) (io.Writer, error)
+	Close() error
+}
+
+// dirExportWriter writes an export to a directory
+type dirExportWriter struct {
+	root string
+	f    *os.File
+}
+
+func (w *dirExportWriter) Create(name string) (io.Writer, error) {
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(w.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w.f = f
+	return f, nil
+}
 
-	store := r.node.Repo.Store()
+func (w *dirExportWriter) Close() error {
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// zipExportWriter writes an export to a zip archive
+type zipExportWriter struct {
+	*zip.Writer
+	f *os.File
+}
 
-	// TODO (dlong): Use --body-format here to convert the body and ds.Structure.Format, before
-	// passing ds to WriteZipArchive.
-	if err = dsutil.WriteZipArchive(store, ds, p.Format, ref.String(), dst); err != nil {
+func newZipExportWriter(path string) (*zipExportWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &zipExportWriter{Writer: zip.NewWriter(f), f: f}, nil
+}
+
+func (w *zipExportWriter) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		w.f.Close()
 		return err
 	}
-	*ok = true
-	return dst.Close()
+	return w.f.Close()
+}
 
-	// TODO (dlong): Document the full functionality of export, and restore this code below. Allow
-	// non-zip formats like dataset.json with inline body, body.json by itself, outputting to a
-	// a directory, along with yaml, and xlsx.
-	/*if path != "" {
-		if err = os.MkdirAll(path, os.ModePerm); err != nil {
-			return err
-		}
+// scriptExtensions maps script syntaxes to the file extension used when
+// exporting scripts of that syntax
+var scriptExtensions = map[string]string{
+	"starlark": ".star",
+	"skylark":  ".star",
+	"html":     ".html",
+	"sql":      ".sql",
+	// exec transforms are executables, which name their own interpreter
+	"exec": "",
+}
+
+// scriptFilename names an exported script, falling back to defaultExt for
+// syntaxes without a known extension
+func scriptFilename(name, syntax, defaultExt string) string {
+	if ext, ok := scriptExtensions[syntax]; ok {
+		return name + ext
+	}
+	if syntax != "" {
+		return fmt.Sprintf("%s.%s", name, syntax)
 	}
+	return name + defaultExt
+}
 
-	if !o.NoBody {
-		if bodyFormat == "" {
-			bodyFormat = ds.Structure.Format.String()
-		}
+// exporter writes dataset versions to an exportWriter
+type exporter struct {
+	w            exportWriter
+	store        cafs.Filestore
+	format       string
+	bodyFormat   dataset.DataFormat
+	bodyEncoding string
+	noBody       bool
+}
 
-		df, err := dataset.ParseDataFormatString(bodyFormat)
-		if err != nil {
-			return err
-		}
+// writeVersion writes one version of a dataset to dir
+func (ex *exporter) writeVersion(dir string, ref repo.DatasetRef) error {
+	ds, err := ref.DecodeDataset()
+	if err != nil {
+		return err
+	}
 
-		p := &lib.LookupParams{
-			Format: df,
-			Path:   ds.Path().String(),
-			All:    true,
-		}
-		r := &lib.LookupResult{}
+	if err := ex.writeFile(path.Join(dir, "ref.txt"), []byte(ref.String())); err != nil {
+		return err
+	}
 
-		if err = o.DatasetRequests.LookupBody(p, r); err != nil {
-			return err
+	if ds.Transform != nil && ds.Transform.ScriptPath != "" {
+		name := scriptFilename("transform", ds.Transform.Syntax, ".star")
+		if err := ex.copyFile(path.Join(dir, name), ds.Transform.ScriptPath); err != nil {
+			return fmt.Errorf("exporting transform script: %s", err.Error())
 		}
+		ds.Transform.ScriptPath = name
+	}
 
-		dataPath := filepath.Join(path, fmt.Sprintf("data.%s", bodyFormat))
-		dst, err := os.Create(dataPath)
-		if err != nil {
-			return err
+	if ds.Viz != nil && ds.Viz.ScriptPath != "" {
+		name := scriptFilename("viz", ds.Viz.Syntax, ".html")
+		if err := ex.copyFile(path.Join(dir, name), ds.Viz.ScriptPath); err != nil {
+			return fmt.Errorf("exporting viz script: %s", err.Error())
 		}
+		ds.Viz.ScriptPath = name
+	}
+
+	if ex.noBody || ds.BodyPath == "" {
+		ds.BodyPath = ""
+	} else if err := ex.writeBody(dir, ds); err != nil {
+		return fmt.Errorf("exporting body: %s", err.Error())
+	}
 
-		if p.Format == dataset.CBORDataFormat {
-			r.Data = []byte(hex.EncodeToString(r.Data))
+	dsp := ds.Encode()
+	// paths refer to stored versions, which a saved export won't match
+	dsp.Path = ""
+	dsp.PreviousPath = ""
+
+	if ex.bodyEncoding != "" && dsp.BodyPath != "" {
+		// saving reads bodies in the encoding named by format & converts them
+		// to json
+		dsp.Structure.Format = ex.bodyEncoding
+		dsp.Structure.FormatConfig = nil
+		if ex.bodyEncoding == base.XLSXEncoding {
+			dsp.Structure.FormatConfig = map[string]interface{}{"headerRow": true}
 		}
-		if _, err = dst.Write(r.Data); err != nil {
-			return err
+		// workbooks & parquet files are always rows of cells, other schemas
+		// won't match them
+		if ex.bodyEncoding != base.NDJSONEncoding && !tabularSchema(dsp.Structure.Schema) {
+			dsp.Structure.Schema = nil
 		}
+	}
 
-		if err = dst.Close(); err != nil {
-			return err
-		}
-		printSuccess(o.Out, "exported data to: %s", dataPath)
+	var data []byte
+	if ex.format == "json" {
+		data, err = json.MarshalIndent(dsp, "", "  ")
+	} else {
+		data, err = yaml.Marshal(dsp)
 	}
+	if err != nil {
+		return err
+	}
+	return ex.writeFile(path.Join(dir, fmt.Sprintf("dataset.%s", ex.format)), data)
+}
 
-	dsPath := filepath.Join(path, dsfs.PackageFileDataset.String())
-	var dsBytes []byte
+// writeBody writes the body of ds to dir, converting it to ex.bodyFormat if
+// one is set. ds.BodyPath & ds.Structure are updated to describe the written
+// body
+func (ex *exporter) writeBody(dir string, ds *dataset.Dataset) error {
+	file, err := dsfs.LoadBody(ex.store, ds)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-	switch format {
-	case "json":
-		dsBytes, err = json.MarshalIndent(ds, "", "  ")
-		if err != nil {
-			return err
-		}
-	default:
-		dsBytes, err = yaml.Marshal(ds)
-		if err != nil {
-			return err
+	in := ds.Structure
+	out := &dataset.Structure{
+		Format:       in.Format,
+		FormatConfig: in.FormatConfig,
+		Schema:       in.Schema,
+	}
+	if ex.bodyFormat != dataset.UnknownDataFormat && ex.bodyFormat != in.Format {
+		out.Format = ex.bodyFormat
+		out.FormatConfig = nil
+		if out.Format == dataset.CSVDataFormat {
+			out.FormatConfig = &dataset.CSVOptions{HeaderRow: true}
 		}
-		dsPath = fmt.Sprintf("%s.yaml", strings.TrimSuffix(dsPath, filepath.Ext(dsPath)))
 	}
-	if err = ioutil.WriteFile(dsPath, dsBytes, os.ModePerm); err != nil {
+
+	name := fmt.Sprintf("body.%s", out.Format)
+	if ex.bodyEncoding != "" {
+		name = fmt.Sprintf("body.%s", ex.bodyEncoding)
+	}
+	w, err := ex.w.Create(path.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	if out.Format == in.Format && ex.bodyEncoding == "" {
+		_, err = io.Copy(w, file)
+	} else {
+		err = base.WriteBody(w, file, in, out, 0, 0, true, ex.bodyEncoding, nil)
+	}
+	if err != nil {
 		return err
 	}
 
-	if ds.Transform != nil && ds.Transform.ScriptPath != "" {
-		f, err := o.Repo.Store().Get(datastore.NewKey(ds.Transform.ScriptPath))
-		if err != nil {
-			return err
-		}
-		scriptData, err := ioutil.ReadAll(f)
-		if err != nil {
-			return err
-		}
-		// TODO - transformations should have default file extensions
-		if err = ioutil.WriteFile(filepath.Join(path, "transform.sky"), scriptData, os.ModePerm); err != nil {
-			return err
-		}
-		printSuccess(o.Out, "exported transform script to: %s", filepath.Join(path, "transform.sky"))
+	if out.Format != in.Format || ex.bodyEncoding != "" {
+		ds.Structure.Format = out.Format
+		ds.Structure.FormatConfig = out.FormatConfig
+		// checksum & length describe the stored body, not the converted one
+		ds.Structure.Checksum = ""
+		ds.Structure.Length = 0
 	}
+	ds.BodyPath = name
+	return nil
+}
+
+// tabularSchema checks if a schema describes an array of rows
+func tabularSchema(sch map[string]interface{}) bool {
+	items, _ := sch["items"].(map[string]interface{})
+	return sch["type"] == "array" && items["type"] == "array"
+}
+
+// copyFile copies a file from the store into the export
+func (ex *exporter) copyFile(name, storePath string) error {
+	f, err := ex.store.Get(storePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	printSuccess(o.Out, "exported dataset.json to: %s", dsPath)
+	w, err := ex.w.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
 
-	return nil*/
+func (ex *exporter) writeFile(name string, data []byte) error {
+	w, err := ex.w.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
 }
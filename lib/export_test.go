@@ -0,0 +1,178 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+	testrepo "github.com/qri-io/qri/repo/test"
+	regmock "github.com/qri-io/registry/regserver/mock"
+)
+
+func TestExportRequestsExport(t *testing.T) {
+	rc, _ := regmock.NewMockServer()
+	mr, err := testrepo.NewTestRepo(rc)
+	if err != nil {
+		t.Fatalf("error allocating test repo: %s", err.Error())
+	}
+	node, err := p2p.NewQriNode(mr, config.DefaultP2PForTesting())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// add a second version of cities
+	update := &SaveParams{
+		Dataset: &dataset.DatasetPod{
+			Peername: "me",
+			Name:     "cities",
+			Meta:     &dataset.Meta{Title: "updated cities"},
+		},
+	}
+	if err := NewDatasetRequests(node, nil).Save(update, &repo.DatasetRef{}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tmp, err := ioutil.TempDir("", "test_export")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(tmp)
+
+	req := NewExportRequests(node, nil)
+	ref := repo.DatasetRef{Peername: "me", Name: "cities"}
+
+	fileWritten := ""
+	p := &ExportParams{Ref: ref, RootDir: tmp, BodyFormat: "json", History: true}
+	if err := req.Export(p, &fileWritten); err != nil {
+		t.Fatal(err.Error())
+	}
+	if fileWritten != filepath.Join(tmp, "cities") {
+		t.Errorf("expected export to be written to %s, got: %s", filepath.Join(tmp, "cities"), fileWritten)
+	}
+
+	for _, name := range []string{
+		"dataset.yaml",
+		"body.json",
+		"ref.txt",
+		"history/1/dataset.yaml",
+		"history/1/body.json",
+		"history/1/ref.txt",
+	} {
+		if _, err := os.Stat(filepath.Join(fileWritten, name)); err != nil {
+			t.Errorf("expected export to contain %s: %s", name, err.Error())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(fileWritten, "history/2")); !os.IsNotExist(err) {
+		t.Error("expected the head version not to be repeated in history")
+	}
+
+	// exported body is converted to json
+	data, err := ioutil.ReadFile(filepath.Join(fileWritten, "body.json"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	body := []interface{}{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("expected body to be valid json: %s", err.Error())
+	}
+	if len(body) != 5 {
+		t.Errorf("expected 5 body entries, got: %d", len(body))
+	}
+
+	// exports are read back as dataset files
+	dsp, err := ReadDatasetFile(filepath.Join(fileWritten, "dataset.yaml"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if dsp.Meta.Title != "updated cities" {
+		t.Errorf("expected meta title to be 'updated cities', got: '%s'", dsp.Meta.Title)
+	}
+	if dsp.Structure.Format != "json" {
+		t.Errorf("expected structure format to be json, got: %s", dsp.Structure.Format)
+	}
+	if dsp.BodyPath != filepath.Join(fileWritten, "body.json") {
+		t.Errorf("expected body path to resolve to the exported body, got: %s", dsp.BodyPath)
+	}
+	if dsp.Path != "" || dsp.PreviousPath != "" {
+		t.Errorf("expected exported paths to be empty, got path: '%s', previousPath: '%s'", dsp.Path, dsp.PreviousPath)
+	}
+
+	prev, err := ReadDatasetFile(filepath.Join(fileWritten, "history/1/dataset.yaml"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if prev.Meta.Title == "updated cities" {
+		t.Error("expected history to contain the previous version")
+	}
+
+	res := repo.DatasetRef{}
+	save := &SaveParams{
+		Dataset:     &dataset.DatasetPod{Peername: "me", Name: "cities_copy"},
+		DatasetPath: filepath.Join(fileWritten, "dataset.yaml"),
+	}
+	if err := NewDatasetRequests(node, nil).Save(save, &res); err != nil {
+		t.Fatalf("error saving exported dataset: %s", err.Error())
+	}
+	if res.Dataset.Structure.Entries != 5 {
+		t.Errorf("expected saved export to have 5 entries, got: %d", res.Dataset.Structure.Entries)
+	}
+
+	// zip archives
+	p = &ExportParams{Ref: ref, RootDir: tmp, PeerDir: true, Zipped: true, Format: "yaml"}
+	if err := req.Export(p, &fileWritten); err != nil {
+		t.Fatal(err.Error())
+	}
+	if fileWritten != filepath.Join(tmp, "peer", "cities.zip") {
+		t.Errorf("expected zip to be written to %s, got: %s", filepath.Join(tmp, "peer", "cities.zip"), fileWritten)
+	}
+	if dsp, err = ReadDatasetFile(fileWritten); err != nil {
+		t.Fatalf("error reading exported zip: %s", err.Error())
+	}
+	if dsp.Meta.Title != "updated cities" {
+		t.Errorf("expected zipped meta title to be 'updated cities', got: '%s'", dsp.Meta.Title)
+	}
+
+	// bodies in body encodings are saved back as json
+	for _, enc := range []string{"ndjson", "parquet"} {
+		p = &ExportParams{Ref: ref, RootDir: filepath.Join(tmp, enc), BodyFormat: enc}
+		if err := req.Export(p, &fileWritten); err != nil {
+			t.Fatal(err.Error())
+		}
+		if dsp, err = ReadDatasetFile(filepath.Join(fileWritten, "dataset.yaml")); err != nil {
+			t.Fatal(err.Error())
+		}
+		if dsp.Structure.Format != enc {
+			t.Errorf("expected %s export structure format to be %s, got: %s", enc, enc, dsp.Structure.Format)
+		}
+		if dsp.BodyPath != filepath.Join(fileWritten, "body."+enc) {
+			t.Errorf("expected %s body path to resolve to the exported body, got: %s", enc, dsp.BodyPath)
+		}
+		dsp.Peername, dsp.Name = "me", "cities_"+enc
+		if err := NewDatasetRequests(node, nil).Save(&SaveParams{Dataset: dsp}, &res); err != nil {
+			t.Fatalf("error saving %s export: %s", enc, err.Error())
+		}
+		if res.Dataset.Structure.Format != "json" {
+			t.Errorf("expected saved %s export to be json, got: %s", enc, res.Dataset.Structure.Format)
+		}
+		if res.Dataset.Structure.Entries != 5 {
+			t.Errorf("expected saved %s export to have 5 entries, got: %d", enc, res.Dataset.Structure.Entries)
+		}
+	}
+
+	bad := []*ExportParams{
+		{Ref: ref, RootDir: tmp, Format: "xml"},
+		{Ref: ref, RootDir: tmp, BodyFormat: "nope"},
+		{Ref: repo.DatasetRef{Peername: "me", Name: "not_a_dataset"}, RootDir: tmp},
+	}
+	for i, p := range bad {
+		if err := req.Export(p, &fileWritten); err == nil {
+			t.Errorf("bad case %d expected error, got nil", i)
+		}
+	}
+}
@@ -11,6 +11,8 @@ import (
 
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/dataset/dsutil"
+	"github.com/qri-io/qri/actions"
+	"github.com/qri-io/qri/base"
 )
 
 // AbsPath adjusts the provided string to a path lib functions can work with
@@ -33,6 +35,10 @@ func AbsPath(path *string) (err error) {
 	if pk == "http" || pk == "ipfs" {
 		return
 	}
+	if pk == "source" {
+		*path, err = base.AbsSourcePath(p)
+		return
+	}
 
 	// TODO - perform tilda (~) expansion
 	if filepath.IsAbs(p) {
@@ -45,6 +51,8 @@ func AbsPath(path *string) (err error) {
 func pathKind(path string) string {
 	if path == "" {
 		return "none"
+	} else if base.IsSourcePath(path) {
+		return "source"
 	} else if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
 		return "http"
 	} else if strings.HasPrefix(path, "/ipfs") {
@@ -122,6 +130,38 @@ func ReadDatasetFile(path string) (dsp *dataset.DatasetPod, err error) {
 	return
 }
 
+// ReadRulesFile reads a JSON or YAML file of data quality rules
+func ReadRulesFile(path string) ([]*base.Rule, error) {
+	if pathKind(path) != "file" {
+		return nil, fmt.Errorf("rules must be read from a local file")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rules file: %s", err.Error())
+	}
+	return base.ParseRules(data)
+}
+
+// ReadTransformFixtureFile reads a JSON or YAML transform fixture. a relative
+// cassette path is relative to the fixture file
+func ReadTransformFixtureFile(path string) (*actions.TransformFixture, error) {
+	if pathKind(path) != "file" {
+		return nil, fmt.Errorf("transform fixtures must be read from a local file")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading transform fixture file: %s", err.Error())
+	}
+	fixture, err := actions.ParseTransformFixture(data)
+	if err != nil {
+		return nil, err
+	}
+	if fixture.Cassette != "" && !filepath.IsAbs(fixture.Cassette) {
+		fixture.Cassette = filepath.Join(filepath.Dir(path), fixture.Cassette)
+	}
+	return fixture, nil
+}
+
 // absDatasetPaths converts any relative filepath references in a DatasetPod to
 // their absolute counterpart
 func absDatasetPaths(path string, dsp *dataset.DatasetPod) {
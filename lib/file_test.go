@@ -22,6 +22,11 @@ func TestAbsPath(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	sqliteAbs, err := filepath.Abs("data/cities.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	cases := []struct {
 		in, out, err string
 	}{
@@ -31,6 +36,9 @@ func TestAbsPath(t *testing.T) {
 		{"relative/path/data.yaml", pathAbs, ""},
 		{"http_got/relative/dataset.yaml", httpAbs, ""},
 		{"/ipfs", "/ipfs", ""},
+		{"sqlite://data/cities.db?table=cities", "sqlite://" + sqliteAbs + "?table=cities", ""},
+		{"sqlite:///tmp/cities.db?table=cities", "sqlite:///tmp/cities.db?table=cities", ""},
+		{"postgres://me@localhost/db?table=cities", "postgres://me@localhost/db?table=cities", ""},
 		{tmp, tmp, ""},
 	}
 
@@ -0,0 +1,591 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qri-io/qri/actions"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
+	"github.com/qri-io/qri/repo"
+)
+
+// JobConcurrency is the number of background jobs a node will run at once.
+// Jobs submitted while all slots are busy wait in the queue
+var JobConcurrency = 2
+
+// JobHistory is the number of finished jobs kept in the repo. The oldest
+// finished jobs are dropped first
+var JobHistory = 100
+
+// ErrJobsNotSupported is returned when a repo can't store background jobs
+var ErrJobsNotSupported = fmt.Errorf("repo doesn't support background jobs")
+
+// ErrJobNotFound is returned when a job ID doesn't match any stored job
+var ErrJobNotFound = fmt.Errorf("job not found")
+
+// JobRequests encapsulates business logic for running long operations in the
+// background
+type JobRequests struct {
+	cli  *qrpc.Client
+	node *p2p.QriNode
+}
+
+// NewJobRequests creates a JobRequests pointer from either a node or an
+// qrpc.Client
+func NewJobRequests(node *p2p.QriNode, cli *qrpc.Client) *JobRequests {
+	if node != nil && cli != nil {
+		panic(fmt.Errorf("both node and client supplied to NewJobRequests"))
+	}
+	return &JobRequests{
+		cli:  cli,
+		node: node,
+	}
+}
+
+// CoreRequestsName implements the Requests interface
+func (r JobRequests) CoreRequestsName() string { return "jobs" }
+
+// JobParams describes an operation to run as a job. Exactly one field must
+// be set
+type JobParams struct {
+	Save    *SaveParams
+	Update  *UpdateParams
+	Publish *SetPublishStatusParams
+	Add     *repo.DatasetRef
+	// Cleanup lists files & directories removed once the operation succeeds,
+	// like a staged upload a save reads it's body from
+	Cleanup []string `json:"cleanup,omitempty"`
+	// Stripped lists params that were left out of the stored job, jobs with
+	// stripped params can't be resumed after a restart
+	Stripped []string `json:"stripped,omitempty"`
+}
+
+// cleanup removes the paths listed in Cleanup
+func (p *JobParams) cleanup() {
+	for _, path := range p.Cleanup {
+		if err := os.RemoveAll(path); err != nil {
+			log.Infof("error cleaning up after job: %s", err.Error())
+		}
+	}
+}
+
+// jobType returns the name of the operation params describe
+func (p *JobParams) jobType() (string, error) {
+	types := []string{}
+	if p.Save != nil {
+		types = append(types, "save")
+	}
+	if p.Update != nil {
+		types = append(types, "update")
+	}
+	if p.Publish != nil {
+		types = append(types, "publish")
+	}
+	if p.Add != nil {
+		types = append(types, "add")
+	}
+
+	if len(types) != 1 {
+		return "", fmt.Errorf("a job must run exactly one operation, got: %d", len(types))
+	}
+	return types[0], nil
+}
+
+// stored returns a copy of params that's safe to write to the repo. Secrets
+// & uploaded bodies aren't stored, they're only held in memory
+func (p *JobParams) stored() *JobParams {
+	sp := *p
+	secrets, body := false, false
+
+	if p.Save != nil {
+		save := *p.Save
+		if save.Secrets != nil {
+			save.Secrets = nil
+			secrets = true
+		}
+		if save.Dataset != nil {
+			ds := *save.Dataset
+			if ds.BodyBytes != nil {
+				ds.BodyBytes = nil
+				body = true
+			}
+			if ds.Transform != nil && ds.Transform.Secrets != nil {
+				tf := *ds.Transform
+				tf.Secrets = nil
+				ds.Transform = &tf
+				secrets = true
+			}
+			save.Dataset = &ds
+		}
+		sp.Save = &save
+	}
+	if p.Update != nil && p.Update.Secrets != nil {
+		update := *p.Update
+		update.Secrets = nil
+		sp.Update = &update
+		secrets = true
+	}
+
+	sp.Stripped = nil
+	if secrets {
+		sp.Stripped = append(sp.Stripped, "secrets")
+	}
+	if body {
+		sp.Stripped = append(sp.Stripped, "body")
+	}
+	return &sp
+}
+
+// Submit queues an operation to run in the background, returning the queued
+// job immediately. Use Get to check on the job's progress
+func (r *JobRequests) Submit(p *JobParams, res *repo.Job) error {
+	if r.cli != nil {
+		return r.cli.Call("JobRequests.Submit", p, res)
+	}
+
+	jr, err := jobRunnerFor(r.node)
+	if err != nil {
+		return err
+	}
+	job, err := jr.submit(p)
+	if err != nil {
+		return err
+	}
+	*res = job
+	return nil
+}
+
+// Get fetches a job by ID. Jobs that are running include script output
+// produced so far
+func (r *JobRequests) Get(id *string, res *repo.Job) error {
+	if r.cli != nil {
+		return r.cli.Call("JobRequests.Get", id, res)
+	}
+
+	jr, err := jobRunnerFor(r.node)
+	if err != nil {
+		return err
+	}
+	job, err := jr.job(*id)
+	if err != nil {
+		return err
+	}
+	*res = job
+	return nil
+}
+
+// List shows jobs, newest first
+func (r *JobRequests) List(p *ListParams, res *[]repo.Job) error {
+	if r.cli != nil {
+		return r.cli.Call("JobRequests.List", p, res)
+	}
+
+	jr, err := jobRunnerFor(r.node)
+	if err != nil {
+		return err
+	}
+	stored, err := jr.store.Jobs()
+	if err != nil {
+		return err
+	}
+
+	jobs := make([]repo.Job, len(stored))
+	for i, j := range stored {
+		jobs[len(stored)-1-i] = j
+	}
+
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+	if p.Offset > len(jobs) {
+		p.Offset = len(jobs)
+	}
+	stop := len(jobs)
+	if p.Limit > 0 && p.Offset+p.Limit < stop {
+		stop = p.Offset + p.Limit
+	}
+
+	*res = jobs[p.Offset:stop]
+	return nil
+}
+
+// Cancel stops a queued job from running, or cancels a running job.
+// Cancelling a running job stops it's transform, a job that finishes before
+// it can be stopped keeps it's result
+func (r *JobRequests) Cancel(id *string, res *repo.Job) error {
+	if r.cli != nil {
+		return r.cli.Call("JobRequests.Cancel", id, res)
+	}
+
+	jr, err := jobRunnerFor(r.node)
+	if err != nil {
+		return err
+	}
+	job, err := jr.cancel(*id)
+	if err != nil {
+		return err
+	}
+	*res = job
+	return nil
+}
+
+// StartJobs resumes jobs left queued by a previous process. Nodes that can't
+// store jobs have nothing to resume, so they aren't an error
+func StartJobs(node *p2p.QriNode) error {
+	if _, err := jobRunnerFor(node); err != nil && err != ErrJobsNotSupported {
+		return err
+	}
+	return nil
+}
+
+var (
+	runnersLock sync.Mutex
+	// runners holds one job runner per node, so concurrency limits apply
+	// across all JobRequests that share a node
+	runners = map[*p2p.QriNode]*jobRunner{}
+)
+
+// jobRunnerFor gets the job runner for a node, creating one if none exists.
+// Creating a runner resumes any jobs left queued by a previous process
+func jobRunnerFor(node *p2p.QriNode) (*jobRunner, error) {
+	if node == nil {
+		return nil, fmt.Errorf("node is required to run jobs")
+	}
+	store, ok := node.Repo.(repo.JobStore)
+	if !ok {
+		return nil, ErrJobsNotSupported
+	}
+
+	runnersLock.Lock()
+	defer runnersLock.Unlock()
+	if jr, ok := runners[node]; ok {
+		return jr, nil
+	}
+
+	jr := &jobRunner{
+		node:    node,
+		store:   store,
+		slots:   make(chan struct{}, JobConcurrency),
+		queued:  map[string]chan struct{}{},
+		running: map[string]context.CancelFunc{},
+		outputs: map[string]*jobOutput{},
+	}
+	if err := jr.resume(); err != nil {
+		return nil, err
+	}
+	runners[node] = jr
+
+	go func() {
+		<-node.Context().Done()
+		runnersLock.Lock()
+		delete(runners, node)
+		runnersLock.Unlock()
+	}()
+
+	return jr, nil
+}
+
+// jobRunner runs jobs with bounded concurrency, persisting job state to the
+// repo as it changes
+type jobRunner struct {
+	node  *p2p.QriNode
+	store repo.JobStore
+	// slots is a semaphore limiting the number of running jobs
+	slots chan struct{}
+
+	lock sync.Mutex
+	// queued maps the ID of each job waiting for a slot to a channel that is
+	// closed when the job is cancelled
+	queued map[string]chan struct{}
+	// running maps the ID of each running job to a func that cancels it
+	running map[string]context.CancelFunc
+	// outputs holds script output of running jobs
+	outputs map[string]*jobOutput
+}
+
+// resume restarts queued jobs & fails jobs that were interrupted by the
+// previous process exiting
+func (jr *jobRunner) resume() error {
+	jobs, err := jr.store.Jobs()
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		switch job.Status {
+		case repo.JobRunning:
+			jr.finish(job, nil, fmt.Errorf("interrupted: qri stopped while this job was running"))
+		case repo.JobQueued:
+			p := &JobParams{}
+			if err := json.Unmarshal(job.Params, p); err != nil {
+				jr.finish(job, nil, fmt.Errorf("error decoding job params: %s", err.Error()))
+				continue
+			}
+			if len(p.Stripped) > 0 {
+				jr.finish(job, nil, fmt.Errorf("interrupted: job %s aren't stored, submit the job again", strings.Join(p.Stripped, " & ")))
+				continue
+			}
+			jr.enqueue(job, p)
+		}
+	}
+	return nil
+}
+
+func (jr *jobRunner) submit(p *JobParams) (repo.Job, error) {
+	typ, err := p.jobType()
+	if err != nil {
+		return repo.Job{}, err
+	}
+
+	// script output is captured by the job, writers can't be persisted
+	if p.Save != nil {
+		p.Save.ScriptOutput = nil
+	}
+	if p.Update != nil {
+		p.Update.ScriptOutput = nil
+	}
+
+	params, err := json.Marshal(p.stored())
+	if err != nil {
+		return repo.Job{}, fmt.Errorf("error encoding job params: %s", err.Error())
+	}
+	job, err := repo.NewJob(typ, params)
+	if err != nil {
+		return job, err
+	}
+	job.Progress = append(job.Progress, jobProgress("queued"))
+	if err := jr.store.PutJob(job); err != nil {
+		return job, err
+	}
+
+	jr.enqueue(job, p)
+	return job, nil
+}
+
+func (jr *jobRunner) enqueue(job repo.Job, p *JobParams) {
+	cancelled := make(chan struct{})
+	jr.lock.Lock()
+	jr.queued[job.ID] = cancelled
+	jr.lock.Unlock()
+
+	go func() {
+		select {
+		case jr.slots <- struct{}{}:
+		case <-cancelled:
+			return
+		case <-jr.node.Context().Done():
+			// leave the job queued in the store, it'll resume on restart
+			return
+		}
+		defer func() { <-jr.slots }()
+
+		jr.lock.Lock()
+		if _, ok := jr.queued[job.ID]; !ok {
+			// cancelled while acquiring a slot
+			jr.lock.Unlock()
+			return
+		}
+		delete(jr.queued, job.ID)
+		out := &jobOutput{}
+		jr.outputs[job.ID] = out
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		jr.running[job.ID] = cancel
+
+		job.Status = repo.JobRunning
+		job.Started = time.Now()
+		job.Progress = append(job.Progress, jobProgress("running"))
+		if err := jr.store.PutJob(job); err != nil {
+			log.Errorf("error saving job %s: %s", job.ID, err.Error())
+		}
+		jr.lock.Unlock()
+
+		res, err := jr.exec(ctx, p, out)
+
+		jr.lock.Lock()
+		delete(jr.outputs, job.ID)
+		delete(jr.running, job.ID)
+		jr.lock.Unlock()
+
+		// keep progress recorded by cancel while the job ran
+		if stored, e := jr.store.Job(job.ID); e == nil {
+			job.Progress = stored.Progress
+		}
+		job.Output = out.String()
+		if err != nil && ctx.Err() != nil {
+			if _, err := jr.cancelled(job); err != nil {
+				log.Errorf("error saving job %s: %s", job.ID, err.Error())
+			}
+			return
+		}
+		if err == nil {
+			p.cleanup()
+		}
+		jr.finish(job, res, err)
+	}()
+}
+
+// exec performs a job's operation, returning it's result
+func (jr *jobRunner) exec(ctx context.Context, p *JobParams, out *jobOutput) (res interface{}, err error) {
+	dsr := NewDatasetRequests(jr.node, nil)
+	ref := &repo.DatasetRef{}
+
+	switch {
+	case p.Save != nil:
+		p.Save.ScriptOutput = out
+		p.Save.ReturnBody = false
+		// jobs are submitted over the API, they never run local executables
+		p.Save.Ctx = actions.RefuseExec(ctx)
+		err = dsr.Save(p.Save, ref)
+	case p.Update != nil:
+		p.Update.ScriptOutput = out
+		p.Update.ReturnBody = false
+		p.Update.Ctx = actions.RefuseExec(ctx)
+		err = dsr.Update(p.Update, ref)
+	case p.Publish != nil:
+		var done bool
+		if err = dsr.SetPublishStatus(p.Publish, &done); err == nil && p.Publish.Ref != nil {
+			ref = p.Publish.Ref
+		}
+	case p.Add != nil:
+		err = dsr.Add(p.Add, ref)
+	default:
+		err = fmt.Errorf("job has no operation to run")
+	}
+
+	return ref, err
+}
+
+// finish records the outcome of a job
+func (jr *jobRunner) finish(job repo.Job, res interface{}, err error) {
+	job.Finished = time.Now()
+	// params can carry transform secrets, drop them once they're not needed
+	job.Params = nil
+	if err != nil {
+		job.Status = repo.JobFailed
+		job.Error = err.Error()
+		job.Progress = append(job.Progress, jobProgress("failed"))
+	} else {
+		job.Status = repo.JobSucceeded
+		job.Progress = append(job.Progress, jobProgress("succeeded"))
+		if res != nil {
+			if data, e := json.Marshal(res); e == nil {
+				job.Result = data
+			} else {
+				log.Errorf("error encoding result of job %s: %s", job.ID, e.Error())
+			}
+		}
+	}
+	jobsFinished.With(string(job.Status)).Inc()
+
+	if e := jr.store.PutJob(job); e != nil {
+		log.Errorf("error saving job %s: %s", job.ID, e.Error())
+	}
+	jr.prune()
+}
+
+// prune drops the oldest finished jobs beyond JobHistory
+func (jr *jobRunner) prune() {
+	jobs, err := jr.store.Jobs()
+	if err != nil {
+		return
+	}
+
+	finished := 0
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if !jobs[i].Status.Finished() {
+			continue
+		}
+		finished++
+		if finished > JobHistory {
+			if err := jr.store.DeleteJob(jobs[i].ID); err != nil {
+				log.Debugf("error pruning job %s: %s", jobs[i].ID, err.Error())
+			}
+		}
+	}
+}
+
+func (jr *jobRunner) job(id string) (repo.Job, error) {
+	job, err := jr.store.Job(id)
+	if err == repo.ErrNotFound {
+		return job, ErrJobNotFound
+	} else if err != nil {
+		return job, err
+	}
+
+	jr.lock.Lock()
+	if out, ok := jr.outputs[id]; ok {
+		job.Output = out.String()
+	}
+	jr.lock.Unlock()
+	return job, nil
+}
+
+func (jr *jobRunner) cancel(id string) (repo.Job, error) {
+	jr.lock.Lock()
+	defer jr.lock.Unlock()
+
+	job, err := jr.store.Job(id)
+	if err == repo.ErrNotFound {
+		return job, ErrJobNotFound
+	} else if err != nil {
+		return job, err
+	}
+
+	if cancel, ok := jr.running[id]; ok {
+		// the job goroutine records the outcome once the operation stops
+		cancel()
+		job.Progress = append(job.Progress, jobProgress("cancelling"))
+		return job, jr.store.PutJob(job)
+	}
+
+	cancelled, ok := jr.queued[id]
+	if !ok {
+		return job, fmt.Errorf("job %s is %s, only queued & running jobs can be cancelled", id, job.Status)
+	}
+	close(cancelled)
+	delete(jr.queued, id)
+	return jr.cancelled(job)
+}
+
+// cancelled records a job as cancelled
+func (jr *jobRunner) cancelled(job repo.Job) (repo.Job, error) {
+	job.Status = repo.JobCancelled
+	job.Finished = time.Now()
+	job.Params = nil
+	job.Progress = append(job.Progress, jobProgress("cancelled"))
+	return job, jr.store.PutJob(job)
+}
+
+// jobProgress formats a timestamped progress message
+func jobProgress(msg string) string {
+	return fmt.Sprintf("%s %s", time.Now().Format(time.RFC3339), msg)
+}
+
+// jobOutput collects script output of a running job. It's safe to read from
+// while a job is writing
+type jobOutput struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+// Write implements the io.Writer interface
+func (o *jobOutput) Write(p []byte) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.buf.Write(p)
+}
+
+// String returns all output written so far
+func (o *jobOutput) String() string {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.buf.String()
+}
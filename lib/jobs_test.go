@@ -0,0 +1,285 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/config"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+	testrepo "github.com/qri-io/qri/repo/test"
+)
+
+func newTestJobNode(t *testing.T) *p2p.QriNode {
+	mr, err := testrepo.NewTestRepo(nil)
+	if err != nil {
+		t.Fatalf("error allocating test repo: %s", err.Error())
+	}
+	node, err := p2p.NewQriNode(mr, config.DefaultP2PForTesting())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return node
+}
+
+// waitForJob polls a job until it finishes
+func waitForJob(t *testing.T, jr *JobRequests, id string) repo.Job {
+	deadline := time.Now().Add(time.Second * 5)
+	job := repo.Job{}
+	for time.Now().Before(deadline) {
+		if err := jr.Get(&id, &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Status.Finished() {
+			return job
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("timed out waiting for job %s to finish. status: %s", id, job.Status)
+	return job
+}
+
+func TestJobRequests(t *testing.T) {
+	node := newTestJobNode(t)
+	jr := NewJobRequests(node, nil)
+
+	job := repo.Job{}
+	if err := jr.Submit(&JobParams{}, &job); err == nil {
+		t.Error("expected submitting a job without an operation to error")
+	}
+
+	// cleanup paths are only removed when a job succeeds
+	kept, err := ioutil.TempDir("", "test_job_cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(kept)
+	removed, err := ioutil.TempDir("", "test_job_cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(removed)
+
+	if err := jr.Submit(&JobParams{Add: &repo.DatasetRef{Name: "abc", Path: "hash###"}, Cleanup: []string{kept}}, &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Type != "add" || job.Status != repo.JobQueued {
+		t.Errorf("expected a queued add job, got: %s %s", job.Status, job.Type)
+	}
+
+	failed := waitForJob(t, jr, job.ID)
+	if failed.Status != repo.JobFailed {
+		t.Errorf("expected job to fail, got status: %s", failed.Status)
+	}
+	if failed.Error != "node is not online and no registry is configured" {
+		t.Errorf("error mismatch. got: %s", failed.Error)
+	}
+	if len(failed.Progress) != 3 {
+		t.Errorf("expected 3 progress messages, got: %v", failed.Progress)
+	}
+
+	ref := repo.MustParseDatasetRef("peer/movies")
+	ref.Published = true
+	if err := jr.Submit(&JobParams{Publish: &SetPublishStatusParams{Ref: &ref}, Cleanup: []string{removed}}, &job); err != nil {
+		t.Fatal(err)
+	}
+	published := waitForJob(t, jr, job.ID)
+	if published.Status != repo.JobSucceeded {
+		t.Fatalf("expected publish job to succeed, got: %s %s", published.Status, published.Error)
+	}
+	res := repo.DatasetRef{}
+	if err := json.Unmarshal(published.Result, &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Published {
+		t.Error("expected job result to be a published ref")
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("expected a failed job to leave it's cleanup paths, got: %s", err)
+	}
+	if _, err := os.Stat(removed); !os.IsNotExist(err) {
+		t.Errorf("expected a succeeded job to remove it's cleanup paths, got: %v", err)
+	}
+
+	jobs := []repo.Job{}
+	if err := jr.List(&ListParams{}, &jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got: %d", len(jobs))
+	}
+	if jobs[0].ID != published.ID {
+		t.Error("expected jobs to be listed newest first")
+	}
+
+	missing := "not_a_job"
+	if err := jr.Get(&missing, &job); err != ErrJobNotFound {
+		t.Errorf("expected getting a missing job to return ErrJobNotFound, got: %v", err)
+	}
+}
+
+func TestJobRequestsCancel(t *testing.T) {
+	prev := JobConcurrency
+	// with no slots, jobs never leave the queue
+	JobConcurrency = 0
+	defer func() { JobConcurrency = prev }()
+
+	node := newTestJobNode(t)
+	jr := NewJobRequests(node, nil)
+
+	job := repo.Job{}
+	if err := jr.Submit(&JobParams{Add: &repo.DatasetRef{Name: "abc", Path: "hash###"}}, &job); err != nil {
+		t.Fatal(err)
+	}
+
+	id := job.ID
+	if err := jr.Cancel(&id, &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != repo.JobCancelled {
+		t.Errorf("expected job to be cancelled, got: %s", job.Status)
+	}
+	if err := jr.Cancel(&id, &job); err == nil {
+		t.Error("expected cancelling a cancelled job to error")
+	}
+}
+
+func TestJobRunnerResume(t *testing.T) {
+	node := newTestJobNode(t)
+	js := node.Repo.(repo.JobStore)
+
+	running, err := repo.NewJob("save", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	running.Status = repo.JobRunning
+
+	params, err := json.Marshal(&JobParams{Add: &repo.DatasetRef{Name: "abc", Path: "hash###"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := repo.NewJob("add", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, j := range []repo.Job{running, queued} {
+		if err := js.PutJob(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jr := NewJobRequests(node, nil)
+	interrupted := repo.Job{}
+	if err := jr.Get(&running.ID, &interrupted); err != nil {
+		t.Fatal(err)
+	}
+	if interrupted.Status != repo.JobFailed {
+		t.Errorf("expected job running at startup to fail, got: %s", interrupted.Status)
+	}
+
+	resumed := waitForJob(t, jr, queued.ID)
+	if resumed.Started.IsZero() {
+		t.Error("expected queued job to run when the runner starts")
+	}
+}
+
+func TestJobRequestsCancelRunning(t *testing.T) {
+	node := newTestJobNode(t)
+	jr := NewJobRequests(node, nil)
+
+	job := repo.Job{}
+	p := &JobParams{Save: &SaveParams{
+		Dataset: &dataset.DatasetPod{
+			Peername:  "peer",
+			Name:      "slow",
+			Transform: &dataset.TransformPod{ScriptPath: "testdata/slow_tf/transform.star"},
+		},
+	}}
+	if err := jr.Submit(p, &job); err != nil {
+		t.Fatal(err)
+	}
+
+	id := job.ID
+	deadline := time.Now().Add(time.Second * 5)
+	for job.Status != repo.JobRunning && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+		if err := jr.Get(&id, &job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := jr.Cancel(&id, &job); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled := waitForJob(t, jr, id)
+	if cancelled.Status != repo.JobCancelled {
+		t.Errorf("expected running job to be cancelled, got: %s %s", cancelled.Status, cancelled.Error)
+	}
+}
+
+func TestJobParamsStored(t *testing.T) {
+	node := newTestJobNode(t)
+	js := node.Repo.(repo.JobStore)
+
+	prev := JobConcurrency
+	JobConcurrency = 0
+	defer func() { JobConcurrency = prev }()
+
+	jr := NewJobRequests(node, nil)
+	job := repo.Job{}
+	p := &JobParams{Save: &SaveParams{
+		Dataset: &dataset.DatasetPod{
+			Peername:  "peer",
+			Name:      "secret",
+			BodyBytes: []byte(`[1,2,3]`),
+		},
+		Secrets: map[string]string{"token": "shh"},
+	}}
+	if err := jr.Submit(p, &job); err != nil {
+		t.Fatal(err)
+	}
+	if p.Save.Secrets == nil || p.Save.Dataset.BodyBytes == nil {
+		t.Error("expected submitted params to keep secrets & body in memory")
+	}
+
+	stored, err := js.Job(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored.Params, []byte("shh")) {
+		t.Errorf("expected stored params to drop secrets, got: %s", string(stored.Params))
+	}
+	sp := &JobParams{}
+	if err := json.Unmarshal(stored.Params, sp); err != nil {
+		t.Fatal(err)
+	}
+	if sp.Save.Dataset.BodyBytes != nil {
+		t.Error("expected stored params to drop the uploaded body")
+	}
+	if strings.Join(sp.Stripped, ",") != "secrets,body" {
+		t.Errorf("stripped mismatch. got: %v", sp.Stripped)
+	}
+
+	// a fresh runner can't resume a job that lost it's params
+	node.Close()
+	node2, err := p2p.NewQriNode(node.Repo, config.DefaultP2PForTesting())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jr2 := NewJobRequests(node2, nil)
+	resumed := repo.Job{}
+	if err := jr2.Get(&job.ID, &resumed); err != nil {
+		t.Fatal(err)
+	}
+	expect := "interrupted: job secrets & body aren't stored, submit the job again"
+	if resumed.Status != repo.JobFailed || resumed.Error != expect {
+		t.Errorf("expected resumed job to fail with: %s, got: %s %s", expect, resumed.Status, resumed.Error)
+	}
+}
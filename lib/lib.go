@@ -4,8 +4,6 @@
 package lib
 
 import (
-	"encoding/gob"
-
 	golog "github.com/ipfs/go-log"
 	"github.com/qri-io/qri/p2p"
 )
@@ -22,14 +20,6 @@ type Requests interface {
 	CoreRequestsName() string
 }
 
-func init() {
-	// Fields like dataset.Structure.Schema contain data of arbitrary types,
-	// registering with the gob package prevents errors when sending them
-	// over net/rpc calls.
-	gob.Register([]interface{}{})
-	gob.Register(map[string]interface{}{})
-}
-
 // Receivers returns a slice of CoreRequests that defines the full local
 // API of lib methods
 func Receivers(node *p2p.QriNode) []Requests {
@@ -43,5 +33,7 @@ func Receivers(node *p2p.QriNode) []Requests {
 		NewSearchRequests(node, nil),
 		NewRenderRequests(node.Repo, nil),
 		NewSelectionRequests(node.Repo, nil),
+		NewTokenRequests(node.Repo, nil),
+		NewJobRequests(node, nil),
 	}
 }
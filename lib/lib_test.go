@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"os"
@@ -57,8 +58,8 @@ func TestReceivers(t *testing.T) {
 
 	node := n.(*p2p.QriNode)
 	reqs := Receivers(node)
-	if len(reqs) != 9 {
-		t.Errorf("unexpected number of receivers returned. expected: %d. got: %d\nhave you added/removed a receiver?", 9, len(reqs))
+	if len(reqs) != 11 {
+		t.Errorf("unexpected number of receivers returned. expected: %d. got: %d\nhave you added/removed a receiver?", 11, len(reqs))
 		return
 	}
 }
@@ -78,7 +79,7 @@ func addCitiesDataset(t *testing.T, node *p2p.QriNode) repo.DatasetRef {
 	dsp.Name = tc.Name
 	dsp.BodyBytes = tc.Body
 
-	ref, _, err := actions.SaveDataset(node, dsp, nil, nil, false, true, false)
+	ref, _, err := actions.SaveDataset(context.Background(), node, dsp, nil, nil, &actions.SaveDatasetOptions{Pin: true})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -94,7 +95,7 @@ func addNowTransformDataset(t *testing.T, node *p2p.QriNode) repo.DatasetRef {
 	dsp.Name = tc.Name
 	dsp.Transform.ScriptPath = "testdata/now_tf/transform.star"
 
-	ref, _, err := actions.SaveDataset(node, dsp, nil, nil, false, true, false)
+	ref, _, err := actions.SaveDataset(context.Background(), node, dsp, nil, nil, &actions.SaveDatasetOptions{Pin: true})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -2,10 +2,10 @@ package lib
 
 import (
 	"fmt"
-	"net/rpc"
 
 	"github.com/qri-io/qri/actions"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 )
 
@@ -13,15 +13,15 @@ import (
 // of changes to datasets, think "git log"
 type LogRequests struct {
 	node *p2p.QriNode
-	cli  *rpc.Client
+	cli  *qrpc.Client
 }
 
 // CoreRequestsName implements the Requets interface
 func (r LogRequests) CoreRequestsName() string { return "log" }
 
 // NewLogRequests creates a LogRequests pointer from either a repo
-// or an rpc.Client
-func NewLogRequests(node *p2p.QriNode, cli *rpc.Client) *LogRequests {
+// or a qrpc.Client
+func NewLogRequests(node *p2p.QriNode, cli *qrpc.Client) *LogRequests {
 	if node != nil && cli != nil {
 		panic(fmt.Errorf("both node and client supplied to NewLogRequests"))
 	}
@@ -0,0 +1,14 @@
+package lib
+
+import "github.com/qri-io/qri/metrics"
+
+var (
+	operations = metrics.NewCounterVec("qri_operations_total",
+		"dataset operations like save & update, by operation & result. result is succeeded or failed", "op", "result")
+	jobsFinished = metrics.NewCounterVec("qri_jobs_finished_total",
+		"background jobs that finished running, by status", "status")
+)
+
+func init() {
+	metrics.MustRegister(operations, jobsFinished)
+}
@@ -30,9 +30,6 @@ type ListParams struct {
 	OrderBy   string
 	Limit     int
 	Offset    int
-	// RPC is a horrible hack while we work to replace the net/rpc package
-	// TODO - remove this
-	RPC bool
 	// Published only applies to listing datasets
 	Published bool
 }
@@ -86,5 +83,16 @@ type ExportParams struct {
 	Ref     repo.DatasetRef
 	RootDir string
 	PeerDir bool
-	Format  string
+	// Format is the encoding for dataset documents, either yaml or json.
+	// zip archives always use json
+	Format string
+	// BodyFormat converts the body to a data format, defaults to the format
+	// the body is stored in
+	BodyFormat string
+	// Zipped writes a zip archive instead of a directory
+	Zipped bool
+	// NoBody leaves the body out of the export
+	NoBody bool
+	// History includes every previous version of the dataset
+	History bool
 }
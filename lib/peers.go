@@ -3,12 +3,12 @@ package lib
 import (
 	"context"
 	"fmt"
-	"net/rpc"
 	"strings"
 
 	"github.com/qri-io/qri/actions"
 	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/repo/profile"
 
@@ -20,7 +20,7 @@ import (
 // relating to peer-to-peer interaction
 type PeerRequests struct {
 	qriNode *p2p.QriNode
-	cli     *rpc.Client
+	cli     *qrpc.Client
 }
 
 // CoreRequestsName implements the Requets interface
@@ -28,7 +28,7 @@ func (d PeerRequests) CoreRequestsName() string { return "peers" }
 
 // NewPeerRequests creates a PeerRequests pointer from either a
 // qri Node or an rpc.Client
-func NewPeerRequests(node *p2p.QriNode, cli *rpc.Client) *PeerRequests {
+func NewPeerRequests(node *p2p.QriNode, cli *qrpc.Client) *PeerRequests {
 	if node != nil && cli != nil {
 		panic(fmt.Errorf("both node and client supplied to NewPeerRequests"))
 	}
@@ -195,6 +195,71 @@ func (d *PeerRequests) DisconnectFromPeer(p *PeerConnectionParamsPod, res *bool)
 	return nil
 }
 
+// QueuedMessages lists outbound messages waiting for the node to come online
+func (d *PeerRequests) QueuedMessages(p *ListParams, res *[]repo.QueuedMessage) error {
+	if d.cli != nil {
+		return d.cli.Call("PeerRequests.QueuedMessages", p, res)
+	}
+
+	mq, ok := d.qriNode.Repo.(repo.MessageQueue)
+	if !ok {
+		return p2p.ErrQueueNotSupported
+	}
+
+	msgs, err := mq.QueuedMessages()
+	if err != nil {
+		return err
+	}
+
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+	if p.Offset > len(msgs) {
+		p.Offset = len(msgs)
+	}
+	stop := len(msgs)
+	if p.Limit > 0 && p.Offset+p.Limit < stop {
+		stop = p.Offset + p.Limit
+	}
+
+	*res = msgs[p.Offset:stop]
+	return nil
+}
+
+// QueueStatus summarizes the state of the outbound message queue
+func (d *PeerRequests) QueueStatus(in *bool, res *p2p.QueueStatus) (err error) {
+	if d.cli != nil {
+		return d.cli.Call("PeerRequests.QueueStatus", in, res)
+	}
+
+	*res, err = d.qriNode.QueueStatus()
+	return err
+}
+
+// ClearQueue drops all queued outbound messages, reporting the number of
+// messages dropped
+func (d *PeerRequests) ClearQueue(in *bool, res *int) error {
+	if d.cli != nil {
+		return d.cli.Call("PeerRequests.ClearQueue", in, res)
+	}
+
+	mq, ok := d.qriNode.Repo.(repo.MessageQueue)
+	if !ok {
+		return p2p.ErrQueueNotSupported
+	}
+
+	msgs, err := mq.QueuedMessages()
+	if err != nil {
+		return err
+	}
+	if err := mq.ClearQueue(); err != nil {
+		return err
+	}
+
+	*res = len(msgs)
+	return nil
+}
+
 // PeerInfoParams defines parameters for the Info method
 type PeerInfoParams struct {
 	Peername  string
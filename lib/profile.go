@@ -5,13 +5,13 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/rpc"
 	"strings"
 
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/qri/actions"
 	"github.com/qri-io/qri/config"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/qri/repo/profile"
 )
@@ -20,15 +20,15 @@ import (
 // user profile
 type ProfileRequests struct {
 	node *p2p.QriNode
-	cli  *rpc.Client
+	cli  *qrpc.Client
 }
 
 // CoreRequestsName implements the Request interface
 func (ProfileRequests) CoreRequestsName() string { return "profile" }
 
 // NewProfileRequests creates a ProfileRequests pointer from either a repo
-// or an rpc.Client
-func NewProfileRequests(node *p2p.QriNode, cli *rpc.Client) *ProfileRequests {
+// or a qrpc.Client
+func NewProfileRequests(node *p2p.QriNode, cli *qrpc.Client) *ProfileRequests {
 	if node != nil && cli != nil {
 		panic(fmt.Errorf("both repo and client supplied to NewProfileRequests"))
 	}
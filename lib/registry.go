@@ -2,25 +2,25 @@ package lib
 
 import (
 	"fmt"
-	"net/rpc"
 
 	"github.com/qri-io/qri/actions"
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 )
 
 // RegistryRequests defines business logic for working with registries
 type RegistryRequests struct {
 	node *p2p.QriNode
-	cli  *rpc.Client
+	cli  *qrpc.Client
 }
 
 // CoreRequestsName implements the Requests interface
 func (RegistryRequests) CoreRequestsName() string { return "registry" }
 
 // NewRegistryRequests creates a RegistryRequests pointer from either a repo
-// or an rpc.Client
-func NewRegistryRequests(node *p2p.QriNode, cli *rpc.Client) *RegistryRequests {
+// or a qrpc.Client
+func NewRegistryRequests(node *p2p.QriNode, cli *qrpc.Client) *RegistryRequests {
 	if node != nil && cli != nil {
 		panic(fmt.Errorf("both repo and client supplied to NewRegistryRequests"))
 	}
@@ -2,22 +2,22 @@ package lib
 
 import (
 	"fmt"
-	"net/rpc"
 
 	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 )
 
 // RenderRequests encapsulates business logic for this node's
 // user profile
 type RenderRequests struct {
-	cli  *rpc.Client
+	cli  *qrpc.Client
 	repo repo.Repo
 }
 
 // NewRenderRequests creates a RenderRequests pointer from either a repo
-// or an rpc.Client
-func NewRenderRequests(r repo.Repo, cli *rpc.Client) *RenderRequests {
+// or a qrpc.Client
+func NewRenderRequests(r repo.Repo, cli *qrpc.Client) *RenderRequests {
 	if r != nil && cli != nil {
 		panic(fmt.Errorf("both repo and client supplied to NewRenderRequests"))
 	}
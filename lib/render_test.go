@@ -1,13 +1,10 @@
 package lib
 
 import (
-	"net"
-	"net/http"
-	"net/http/httptest"
-	"net/rpc"
 	"testing"
 
 	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 	testrepo "github.com/qri-io/qri/repo/test"
 	"github.com/sergi/go-diff/diffmatchpatch"
@@ -26,20 +23,13 @@ func TestNewRenderRequests(t *testing.T) {
 		return
 	}
 
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte{}) }))
-	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
-	if err != nil {
-		t.Errorf("error allocating listener: %s", err.Error())
-		return
-	}
-
 	reqs := NewRenderRequests(tr, nil)
 	if reqs.CoreRequestsName() != "render" {
 		t.Errorf("invalid requests name. expected: '%s', got: '%s'", "render", reqs.CoreRequestsName())
 	}
 
 	// this should panic:
-	NewRenderRequests(tr, rpc.NewClient(conn))
+	NewRenderRequests(tr, &qrpc.Client{})
 }
 
 func TestRenderRequestsRender(t *testing.T) {
@@ -2,9 +2,9 @@ package lib
 
 import (
 	"fmt"
-	"net/rpc"
 
 	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 	"github.com/qri-io/registry/regclient"
 )
@@ -12,13 +12,13 @@ import (
 // SearchRequests encapsulates business logic for the qri search
 // command
 type SearchRequests struct {
-	cli  *rpc.Client
+	cli  *qrpc.Client
 	node *p2p.QriNode
 }
 
 // NewSearchRequests creates a SearchRequests pointer from either a repo
-// or an rpc.Client
-func NewSearchRequests(node *p2p.QriNode, cli *rpc.Client) *SearchRequests {
+// or a qrpc.Client
+func NewSearchRequests(node *p2p.QriNode, cli *qrpc.Client) *SearchRequests {
 	if node != nil && cli != nil {
 		panic(fmt.Errorf("both node and client supplied to NewSearchRequests"))
 	}
@@ -2,21 +2,21 @@ package lib
 
 import (
 	"fmt"
-	"net/rpc"
 
+	"github.com/qri-io/qri/qrpc"
 	"github.com/qri-io/qri/repo"
 )
 
 // SelectionRequests encapsulates business logic for the qri search
 // command
 type SelectionRequests struct {
-	cli  *rpc.Client
+	cli  *qrpc.Client
 	repo repo.Repo
 }
 
 // NewSelectionRequests creates a SelectionRequests pointer from either a repo
-// or an rpc.Client
-func NewSelectionRequests(r repo.Repo, cli *rpc.Client) *SelectionRequests {
+// or a qrpc.Client
+func NewSelectionRequests(r repo.Repo, cli *qrpc.Client) *SelectionRequests {
 	if r != nil && cli != nil {
 		panic(fmt.Errorf("both repo and client supplied to NewSelectionRequests"))
 	}
@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"fmt"
+
+	"github.com/qri-io/qri/qrpc"
+	"github.com/qri-io/qri/repo"
+)
+
+// ErrTokensNotSupported is returned when a repo can't store API tokens
+var ErrTokensNotSupported = fmt.Errorf("repo doesn't support storing API tokens")
+
+// TokenRequests encapsulates business logic for managing API tokens
+type TokenRequests struct {
+	cli  *qrpc.Client
+	repo repo.Repo
+}
+
+// NewTokenRequests creates a TokenRequests pointer from either a repo
+// or a qrpc.Client
+func NewTokenRequests(r repo.Repo, cli *qrpc.Client) *TokenRequests {
+	if r != nil && cli != nil {
+		panic(fmt.Errorf("both repo and client supplied to NewTokenRequests"))
+	}
+	return &TokenRequests{
+		cli:  cli,
+		repo: r,
+	}
+}
+
+// CoreRequestsName implements the Requests interface
+func (r TokenRequests) CoreRequestsName() string { return "tokens" }
+
+// CreateTokenParams defines parameters for creating an API token
+type CreateTokenParams struct {
+	// Name describes what the token is for
+	Name string
+	// Scope is one of read, write or admin
+	Scope string
+}
+
+// CreateTokenResult is a newly-created token & it's secret. The secret is
+// only available when the token is created
+type CreateTokenResult struct {
+	Token  repo.Token
+	Secret string
+}
+
+// Create makes a new API token
+func (r *TokenRequests) Create(p *CreateTokenParams, res *CreateTokenResult) error {
+	if r.cli != nil {
+		return r.cli.Call("TokenRequests.Create", p, res)
+	}
+
+	ts, ok := r.repo.(repo.TokenStore)
+	if !ok {
+		return ErrTokensNotSupported
+	}
+
+	scope, err := repo.ParseTokenScope(p.Scope)
+	if err != nil {
+		return err
+	}
+
+	t, secret, err := repo.NewToken(p.Name, scope)
+	if err != nil {
+		return err
+	}
+	if err := ts.PutToken(t); err != nil {
+		return err
+	}
+
+	*res = CreateTokenResult{Token: t, Secret: secret}
+	return nil
+}
+
+// List shows all API tokens. Token secrets are never listed
+func (r *TokenRequests) List(p *ListParams, res *[]repo.Token) error {
+	if r.cli != nil {
+		return r.cli.Call("TokenRequests.List", p, res)
+	}
+
+	ts, ok := r.repo.(repo.TokenStore)
+	if !ok {
+		return ErrTokensNotSupported
+	}
+
+	toks, err := ts.Tokens()
+	if err != nil {
+		return err
+	}
+
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+	if p.Offset > len(toks) {
+		p.Offset = len(toks)
+	}
+	stop := len(toks)
+	if p.Limit > 0 && p.Offset+p.Limit < stop {
+		stop = p.Offset + p.Limit
+	}
+
+	*res = toks[p.Offset:stop]
+	return nil
+}
+
+// Delete revokes an API token by ID
+func (r *TokenRequests) Delete(id *string, done *bool) error {
+	if r.cli != nil {
+		return r.cli.Call("TokenRequests.Delete", id, done)
+	}
+
+	ts, ok := r.repo.(repo.TokenStore)
+	if !ok {
+		return ErrTokensNotSupported
+	}
+
+	if err := ts.DeleteToken(*id); err != nil {
+		if err == repo.ErrNotFound {
+			return fmt.Errorf("no token with id '%s'", *id)
+		}
+		return err
+	}
+	*done = true
+	return nil
+}
@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/qri-io/qri/repo"
+	testrepo "github.com/qri-io/qri/repo/test"
+)
+
+func TestTokenRequests(t *testing.T) {
+	mr, err := testrepo.NewTestRepo(nil)
+	if err != nil {
+		t.Fatalf("error allocating test repo: %s", err.Error())
+	}
+
+	tr := NewTokenRequests(mr, nil)
+
+	res := &CreateTokenResult{}
+	if err := tr.Create(&CreateTokenParams{Name: "ci", Scope: "superuser"}, res); err == nil {
+		t.Error("expected creating a token with an invalid scope to error")
+	}
+	if err := tr.Create(&CreateTokenParams{Name: "ci", Scope: "write"}, res); err != nil {
+		t.Fatalf("error creating token: %s", err.Error())
+	}
+	if res.Secret == "" {
+		t.Error("expected create to return a secret")
+	}
+	if res.Token.Scope != repo.ScopeWrite {
+		t.Errorf("scope mismatch. expected: %s, got: %s", repo.ScopeWrite, res.Token.Scope)
+	}
+
+	toks := []repo.Token{}
+	if err := tr.List(&ListParams{}, &toks); err != nil {
+		t.Fatal(err)
+	}
+	if len(toks) != 1 {
+		t.Fatalf("expected 1 token, got: %d", len(toks))
+	}
+	if toks[0].Name != "ci" {
+		t.Errorf("name mismatch. expected: %s, got: %s", "ci", toks[0].Name)
+	}
+
+	done := false
+	id := res.Token.ID
+	if err := tr.Delete(&id, &done); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Delete(&id, &done); err == nil {
+		t.Error("expected deleting a missing token to error")
+	}
+	if err := tr.List(&ListParams{}, &toks); err != nil {
+		t.Fatal(err)
+	}
+	if len(toks) != 0 {
+		t.Errorf("expected 0 tokens after delete, got: %d", len(toks))
+	}
+}
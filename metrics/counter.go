@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// CounterVec is a set of counters partitioned by label values
+type CounterVec struct {
+	*vec
+}
+
+// NewCounterVec creates a counter with the given label names. Counter names
+// should end in "_total"
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newVec(name, help, labels)}
+}
+
+// With returns the counter for a set of label values, in the order label
+// names were given to NewCounterVec
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.get(values, func() interface{} { return &Counter{} }).(*Counter)
+}
+
+// Write implements the Metric interface
+func (c *CounterVec) Write(w io.Writer) error {
+	if err := c.writeHeader(w, "counter"); err != nil {
+		return err
+	}
+	values, samples := c.sorted()
+	for i, s := range samples {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, labelString(c.labels, values[i]), formatFloat(s.(*Counter).Value())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	lock  sync.Mutex
+	value float64
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds v to the counter. v must not be negative
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.lock.Lock()
+	c.value += v
+	c.lock.Unlock()
+}
+
+// Value returns the current count
+func (c *Counter) Value() float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.value
+}
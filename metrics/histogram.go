@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HistogramVec is a set of histograms partitioned by label values
+type HistogramVec struct {
+	*vec
+	buckets []float64
+}
+
+// NewHistogramVec creates a histogram with the given bucket upper bounds &
+// label names. Histograms of durations should be in seconds, with names
+// ending in "_seconds"
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	bs := append([]float64(nil), buckets...)
+	sort.Float64s(bs)
+	return &HistogramVec{vec: newVec(name, help, labels), buckets: bs}
+}
+
+// With returns the histogram for a set of label values, in the order label
+// names were given to NewHistogramVec
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.get(values, func() interface{} {
+		return &Histogram{buckets: h.buckets, counts: make([]uint64, len(h.buckets))}
+	}).(*Histogram)
+}
+
+// Write implements the Metric interface
+func (h *HistogramVec) Write(w io.Writer) error {
+	if err := h.writeHeader(w, "histogram"); err != nil {
+		return err
+	}
+	values, samples := h.sorted()
+	for i, s := range samples {
+		counts, count, sum := s.(*Histogram).snapshot()
+		var cumulative uint64
+		for j, upper := range h.buckets {
+			cumulative += counts[j]
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelString(h.labels, values[i], "le", formatFloat(upper)), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, labelString(h.labels, values[i], "le", "+Inf"), count,
+			h.name, labelString(h.labels, values[i]), formatFloat(sum),
+			h.name, labelString(h.labels, values[i]), count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	buckets []float64
+
+	lock   sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe records a single value
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+// ObserveSince records the time elapsed since start, in seconds
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// snapshot copies the histogram's state
+func (h *Histogram) snapshot() ([]uint64, uint64, float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]uint64(nil), h.counts...), h.count, h.sum
+}
@@ -0,0 +1,201 @@
+// Package metrics records counters & histograms of qri activity, and writes
+// them in the Prometheus text exposition format. Packages declare their
+// metrics as package-level vars & add them to the default registry in an
+// init func:
+//
+//	var sent = metrics.NewCounterVec("qri_things_sent_total", "things sent", "type")
+//
+//	func init() {
+//	  metrics.MustRegister(sent)
+//	}
+//
+// the api package serves the default registry at /metrics
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are default histogram buckets, in seconds. They cover durations
+// from a few milliseconds to ten seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metric is a named collection of samples
+type Metric interface {
+	// Name is the name of the metric family
+	Name() string
+	// Write writes the metric family in the text exposition format
+	Write(w io.Writer) error
+}
+
+// Registry is a set of metrics that are written together
+type Registry struct {
+	lock    sync.Mutex
+	metrics map[string]Metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]Metric{}}
+}
+
+// Default is the registry served by the qri api
+var Default = NewRegistry()
+
+// MustRegister adds metrics to the default registry, panicking if a metric
+// with the same name is already registered
+func MustRegister(ms ...Metric) {
+	for _, m := range ms {
+		if err := Default.Register(m); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register adds a metric to the registry. Names must be unique
+func (r *Registry) Register(m Metric) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.metrics[m.Name()]; ok {
+		return fmt.Errorf("metric %s is already registered", m.Name())
+	}
+	r.metrics[m.Name()] = m
+	return nil
+}
+
+// Write writes all registered metrics, sorted by name
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	ms := make([]Metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		ms = append(ms, m)
+	}
+	r.lock.Unlock()
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name() < ms[j].Name() })
+	for _, m := range ms {
+		if err := m.Write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteGauge writes a single unlabelled gauge. Use it for values that are
+// read when metrics are written, like the number of connected peers
+func WriteGauge(w io.Writer, name, help string, value float64) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, escapeHelp(help), name, name, formatFloat(value))
+	return err
+}
+
+// vec holds the state shared by labelled metrics
+type vec struct {
+	name, help string
+	labels     []string
+
+	lock sync.Mutex
+	// values maps joined label values to samples
+	values map[string]interface{}
+	keys   map[string][]string
+}
+
+func newVec(name, help string, labels []string) *vec {
+	return &vec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: map[string]interface{}{},
+		keys:   map[string][]string{},
+	}
+}
+
+// Name implements the Metric interface
+func (v *vec) Name() string { return v.name }
+
+// get returns the sample for a set of label values, creating it with mk if
+// it doesn't exist. get panics if the wrong number of values is given
+func (v *vec) get(values []string, mk func() interface{}) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Errorf("metric %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	s, ok := v.values[key]
+	if !ok {
+		s = mk()
+		v.values[key] = s
+		v.keys[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// sorted returns samples & their label values, ordered by label values
+func (v *vec) sorted() ([][]string, []interface{}) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([][]string, len(keys))
+	samples := make([]interface{}, len(keys))
+	for i, k := range keys {
+		values[i] = v.keys[k]
+		samples[i] = v.values[k]
+	}
+	return values, samples
+}
+
+func (v *vec) writeHeader(w io.Writer, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, typ)
+	return err
+}
+
+// labelString formats label names & values as {name="value",...}. extra is
+// an additional name, value pair, used for histogram buckets
+func labelString(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabel(values[i])))
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[0], escapeLabel(extra[1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
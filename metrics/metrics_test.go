@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	sent := NewCounterVec("test_sent_total", "messages sent", "type")
+	dur := NewHistogramVec("test_duration_seconds", "how long things take", []float64{1, 0.1}, "route")
+	if err := r.Register(sent); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(dur); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(NewCounterVec("test_sent_total", "again")); err == nil {
+		t.Error("expected registering a duplicate name to error")
+	}
+
+	sent.With("ping").Inc()
+	sent.With("ping").Add(2)
+	sent.With("say \"hi\"").Inc()
+	sent.With("ping").Add(-1)
+
+	dur.With("/list").Observe(0.05)
+	dur.With("/list").Observe(0.5)
+	dur.With("/list").Observe(5)
+
+	buf := &bytes.Buffer{}
+	if err := r.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := `# HELP test_duration_seconds how long things take
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/list",le="0.1"} 1
+test_duration_seconds_bucket{route="/list",le="1"} 2
+test_duration_seconds_bucket{route="/list",le="+Inf"} 3
+test_duration_seconds_sum{route="/list"} 5.55
+test_duration_seconds_count{route="/list"} 3
+# HELP test_sent_total messages sent
+# TYPE test_sent_total counter
+test_sent_total{type="ping"} 3
+test_sent_total{type="say \"hi\""} 1
+`
+	if buf.String() != expect {
+		t.Errorf("output mismatch. expected:\n%s\ngot:\n%s", expect, buf.String())
+	}
+}
+
+func TestWithWrongLabelCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected wrong number of label values to panic")
+		}
+	}()
+	NewCounterVec("test_total", "test", "a", "b").With("a")
+}
+
+func TestWriteGauge(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteGauge(buf, "test_peers", "connected\npeers", 4); err != nil {
+		t.Fatal(err)
+	}
+	expect := "# HELP test_peers connected\\npeers\n# TYPE test_peers gauge\ntest_peers 4\n"
+	if buf.String() != expect {
+		t.Errorf("output mismatch. expected:\n%s\ngot:\n%s", expect, buf.String())
+	}
+}
+
+func TestUnlabelled(t *testing.T) {
+	c := NewCounterVec("test_total", "test")
+	c.With().Inc()
+	buf := &bytes.Buffer{}
+	if err := c.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	expect := "# HELP test_total test\n# TYPE test_total counter\ntest_total 1\n"
+	if buf.String() != expect {
+		t.Errorf("output mismatch. expected:\n%s\ngot:\n%s", expect, buf.String())
+	}
+}
@@ -1,7 +1,6 @@
 package p2p
 
 import (
-	"context"
 	"math/rand"
 
 	ma "gx/ipfs/QmT4U94DnD8FRfqr21obWY32HLM5VExccPKMjQHofeYqr9/go-multiaddr"
@@ -25,7 +24,7 @@ func (n *QriNode) Bootstrap(boostrapAddrs []string, boostrapPeers chan pstore.Pe
 	for _, p := range randomSubsetOfPeers(pinfos, 4) {
 		go func(p pstore.PeerInfo) {
 			log.Debugf("boostrapping to: %s", p.ID.Pretty())
-			if err := n.host.Connect(context.Background(), p); err == nil {
+			if err := n.host.Connect(n.Context(), p); err == nil {
 				if err = n.UpgradeToQriConnection(p); err != nil && err != ErrQriProtocolNotSupported {
 					log.Errorf("error adding peer: %s", err.Error())
 				} else {
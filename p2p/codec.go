@@ -0,0 +1,133 @@
+package p2p
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ugorji/go/codec"
+
+	multicodec "gx/ipfs/QmU4qokxecGJBZPGmc4D9g2HdTyo8CPqUoZ2gwXKsQxqc9/go-multicodec"
+	json "gx/ipfs/QmU4qokxecGJBZPGmc4D9g2HdTyo8CPqUoZ2gwXKsQxqc9/go-multicodec/json"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+// MaxMessageSize is the largest encoded message, in bytes, that will be
+// accepted on a stream. Peers that announce a larger binary frame are hung up
+// on before any of the frame is read, JSON messages stop being read once they
+// pass the limit
+const MaxMessageSize = 1 << 22 // 4Mb
+
+// ErrMessageTooLarge is returned when a message frame exceeds MaxMessageSize
+var ErrMessageTooLarge = fmt.Errorf("message exceeds max size of %d bytes", MaxMessageSize)
+
+// cborHandle configures CBOR encoding for binary framing
+var cborHandle = &codec.CborHandle{TimeRFC3339: true}
+
+// codecForProtocol picks an encoder/decoder pair based on the protocol
+// negotiated for a stream. streams that didn't negotiate binary framing
+// fall back to JSON, which all qri peers understand
+func codecForProtocol(p protocol.ID, r *bufio.Reader, w *bufio.Writer) (multicodec.Encoder, multicodec.Decoder) {
+	if p == QriBinaryProtocolID {
+		return newFrameEncoder(w, MaxMessageSize), newFrameDecoder(r, MaxMessageSize)
+	}
+	// See https://godoc.org/github.com/multiformats/go-multicodec/json
+	return json.Multicodec(false).Encoder(w), newJSONDecoder(r, MaxMessageSize)
+}
+
+// jsonDecoder decodes JSON messages, reading at most max bytes for each
+// message. the JSON decoder reads ahead, so bytes of the next message can
+// count toward the limit of the message being decoded
+type jsonDecoder struct {
+	r   *messageReader
+	dec multicodec.Decoder
+	max int
+}
+
+func newJSONDecoder(r io.Reader, max int) *jsonDecoder {
+	mr := &messageReader{r: r}
+	return &jsonDecoder{r: mr, dec: json.Multicodec(false).Decoder(mr), max: max}
+}
+
+// Decode reads a single message into v
+func (d *jsonDecoder) Decode(v interface{}) error {
+	d.r.left = d.max
+	return d.dec.Decode(v)
+}
+
+// messageReader is a reader that fails with ErrMessageTooLarge once it's
+// read left bytes
+type messageReader struct {
+	r    io.Reader
+	left int
+}
+
+// Read implements the io.Reader interface
+func (r *messageReader) Read(p []byte) (int, error) {
+	if r.left <= 0 {
+		return 0, ErrMessageTooLarge
+	}
+	if len(p) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.r.Read(p)
+	r.left -= n
+	return n, err
+}
+
+// frameEncoder writes values as uvarint length-prefixed CBOR frames
+type frameEncoder struct {
+	w   io.Writer
+	max int
+}
+
+func newFrameEncoder(w io.Writer, max int) *frameEncoder {
+	return &frameEncoder{w: w, max: max}
+}
+
+// Encode writes a single frame containing v
+func (e *frameEncoder) Encode(v interface{}) error {
+	var data []byte
+	if err := codec.NewEncoderBytes(&data, cborHandle).Encode(v); err != nil {
+		return err
+	}
+	if len(data) > e.max {
+		return ErrMessageTooLarge
+	}
+
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(data)))
+	if _, err := e.w.Write(prefix[:n]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(data)
+	return err
+}
+
+// frameDecoder reads uvarint length-prefixed CBOR frames
+type frameDecoder struct {
+	r   *bufio.Reader
+	max int
+}
+
+func newFrameDecoder(r *bufio.Reader, max int) *frameDecoder {
+	return &frameDecoder{r: r, max: max}
+}
+
+// Decode reads a single frame into v
+func (d *frameDecoder) Decode(v interface{}) error {
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err
+	}
+	if size > uint64(d.max) {
+		return ErrMessageTooLarge
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return err
+	}
+	return codec.NewDecoderBytes(data, cborHandle).Decode(v)
+}
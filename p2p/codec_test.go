@@ -0,0 +1,208 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/repo"
+
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+func TestFrameCodecRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	enc, dec := codecForProtocol(QriBinaryProtocolID, bufio.NewReader(buf), w)
+
+	msgs := []Message{
+		NewMessage("a", MtPing, []byte("hello")),
+		NewMessage("b", MtDatasets, nil).WithHeaders("phase", "request"),
+	}
+
+	for _, msg := range msgs {
+		if err := enc.Encode(&msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+
+	for i, expect := range msgs {
+		got := Message{}
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("case %d decode error: %s", i, err)
+		}
+		if got.ID != expect.ID {
+			t.Errorf("case %d ID mismatch. expected: %s, got: %s", i, expect.ID, got.ID)
+		}
+		if got.Type != expect.Type {
+			t.Errorf("case %d type mismatch. expected: %s, got: %s", i, expect.Type, got.Type)
+		}
+		if got.Header("phase") != expect.Header("phase") {
+			t.Errorf("case %d header mismatch. expected: %s, got: %s", i, expect.Header("phase"), got.Header("phase"))
+		}
+		if !bytes.Equal(got.Body, expect.Body) {
+			t.Errorf("case %d body mismatch. expected: %s, got: %s", i, string(expect.Body), string(got.Body))
+		}
+	}
+}
+
+func TestFrameCodecSizeLimit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	msg := NewMessage("a", MtPing, make([]byte, 512))
+
+	if err := newFrameEncoder(buf, 256).Encode(&msg); err != ErrMessageTooLarge {
+		t.Errorf("expected oversized encode to error with ErrMessageTooLarge, got: %v", err)
+	}
+
+	if err := newFrameEncoder(buf, MaxMessageSize).Encode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	got := Message{}
+	if err := newFrameDecoder(bufio.NewReader(buf), 256).Decode(&got); err != ErrMessageTooLarge {
+		t.Errorf("expected oversized decode to error with ErrMessageTooLarge, got: %v", err)
+	}
+}
+
+func TestJSONCodecSizeLimit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	enc, _ := codecForProtocol(QriProtocolID, bufio.NewReader(buf), w)
+	small := NewMessage("a", MtPing, []byte("hello"))
+	large := NewMessage("b", MtPing, make([]byte, 512))
+	for _, msg := range []Message{small, large} {
+		if err := enc.Encode(&msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+
+	dec := newJSONDecoder(buf, 256)
+	got := Message{}
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("expected a message under the limit to decode, got: %s", err)
+	}
+	if got.ID != "a" {
+		t.Errorf("expected message a, got: %s", got.ID)
+	}
+	if err := dec.Decode(&got); err != ErrMessageTooLarge {
+		t.Errorf("expected oversized decode to error with ErrMessageTooLarge, got: %v", err)
+	}
+}
+
+func benchmarkRefs(n int) []repo.DatasetRef {
+	refs := make([]repo.DatasetRef, n)
+	for i := range refs {
+		refs[i] = repo.DatasetRef{
+			Peername:  "peer",
+			ProfileID: "QmZePf5LeXow3RW5U1AgEiNbW46YnRGhZ7HPvm1UmPFPwt",
+			Name:      "dataset_name",
+			Path:      "/ipfs/QmPi5wrPsY4xPwy2oRr7NRZyfFxTeupfmnrVDubzoABLNP",
+			Dataset: &dataset.DatasetPod{
+				Commit: &dataset.CommitPod{
+					Title:     "created dataset",
+					Timestamp: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+					Signature: "JI/VSNqMuFGYVEwm3n8ZMjZmey+W2mhkD5if2337wDp+kaYfek9DntOyZiILXocW5JuOp48EqcsWf",
+				},
+				Meta: &dataset.Meta{
+					Title:       "a dataset for benchmarking",
+					Description: "dataset refs are what travel over the wire in log and list responses",
+				},
+				Structure: &dataset.StructurePod{
+					Format:  "json",
+					Entries: 7,
+					Length:  19116,
+				},
+			},
+		}
+	}
+	return refs
+}
+
+// benchmarkEncode measures encoding a response message with the codec for
+// protocol p. bytes-per-op is set to the encoded message size, so MB/s output
+// is directly comparable between codecs
+func benchmarkEncode(b *testing.B, p protocol.ID, mt MsgType, body interface{}) {
+	msg, err := NewJSONBodyMessage("QmZePf5LeXow3RW5U1AgEiNbW46YnRGhZ7HPvm1UmPFPwt", mt, body)
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg = msg.WithHeaders("phase", "response")
+
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	enc, _ := codecForProtocol(p, bufio.NewReader(buf), w)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := enc.Encode(&msg); err != nil {
+			b.Fatal(err)
+		}
+		w.Flush()
+	}
+	b.SetBytes(int64(buf.Len()))
+	b.Logf("%s %s message size: %d bytes", p, mt, buf.Len())
+}
+
+// benchmarkDecode measures decoding a response message with the codec for
+// protocol p
+func benchmarkDecode(b *testing.B, p protocol.ID, mt MsgType, body interface{}) {
+	msg, err := NewJSONBodyMessage("QmZePf5LeXow3RW5U1AgEiNbW46YnRGhZ7HPvm1UmPFPwt", mt, body)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	enc, _ := codecForProtocol(p, bufio.NewReader(buf), w)
+	if err := enc.Encode(&msg); err != nil {
+		b.Fatal(err)
+	}
+	w.Flush()
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, dec := codecForProtocol(p, bufio.NewReader(bytes.NewReader(data)), w)
+		got := Message{}
+		if err := dec.Decode(&got); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeDatasetLogJSON(b *testing.B) {
+	benchmarkEncode(b, QriProtocolID, MtDatasetLog, DatasetLogResponse{History: benchmarkRefs(20)})
+}
+
+func BenchmarkEncodeDatasetLogBinary(b *testing.B) {
+	benchmarkEncode(b, QriBinaryProtocolID, MtDatasetLog, DatasetLogResponse{History: benchmarkRefs(20)})
+}
+
+func BenchmarkDecodeDatasetLogJSON(b *testing.B) {
+	benchmarkDecode(b, QriProtocolID, MtDatasetLog, DatasetLogResponse{History: benchmarkRefs(20)})
+}
+
+func BenchmarkDecodeDatasetLogBinary(b *testing.B) {
+	benchmarkDecode(b, QriBinaryProtocolID, MtDatasetLog, DatasetLogResponse{History: benchmarkRefs(20)})
+}
+
+func BenchmarkEncodeDatasetsJSON(b *testing.B) {
+	benchmarkEncode(b, QriProtocolID, MtDatasets, benchmarkRefs(listMax))
+}
+
+func BenchmarkEncodeDatasetsBinary(b *testing.B) {
+	benchmarkEncode(b, QriBinaryProtocolID, MtDatasets, benchmarkRefs(listMax))
+}
+
+func BenchmarkDecodeDatasetsJSON(b *testing.B) {
+	benchmarkDecode(b, QriProtocolID, MtDatasets, benchmarkRefs(listMax))
+}
+
+func BenchmarkDecodeDatasetsBinary(b *testing.B) {
+	benchmarkDecode(b, QriBinaryProtocolID, MtDatasets, benchmarkRefs(listMax))
+}
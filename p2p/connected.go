@@ -36,8 +36,13 @@ func (pod pinfoPod) Decode() (pstore.PeerInfo, error) {
 	return pi, nil
 }
 
-// AnnounceConnected kicks off a notice to other peers that a profile has connected
+// AnnounceConnected kicks off a notice to other peers that a profile has connected.
+// Announcements aren't queued, nodes announce themselves each time they
+// come online
 func (n *QriNode) AnnounceConnected() error {
+	if !n.Online {
+		return ErrNotConnected
+	}
 	pids := n.ConnectedQriPeerIDs()
 	log.Debugf("%s AnnounceConnected to %d peers", n.ID, len(pids))
 
@@ -0,0 +1,223 @@
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsfs"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/repo"
+)
+
+// MtDatasetBody requests a page of a dataset body
+const MtDatasetBody = MsgType("dataset_body")
+
+// bodyPageMax is the highest number of entries a peer will serve in a single
+// body page
+const bodyPageMax = 1000
+
+// bodyPageMaxBytes is the largest a body page can be, in bytes. on the JSON
+// protocol page data is base64 encoded in the response, which is base64
+// encoded again in the message, so pages are kept to 9/16ths of
+// MaxMessageSize, less room for the rest of the message. pages that would be
+// larger are served with fewer entries
+var bodyPageMaxBytes = (MaxMessageSize - 1<<16) * 9 / 16
+
+// bodyResponseTimeout is how long to wait for a peer to respond with a body
+// page before moving on to the next peer
+var bodyResponseTimeout = time.Second * 30
+
+// DatasetBodyRequest encapsulates options for requesting a page of a dataset body
+type DatasetBodyRequest struct {
+	Ref repo.DatasetRef
+	// Format is the data format to encode the page in, defaults to json
+	Format       string
+	FormatConfig map[string]interface{}
+	Limit        int
+	Offset       int
+	// Fields optionally limits entries to a set of named fields
+	Fields []string
+}
+
+// DatasetBodyResponse is a page of a dataset body
+type DatasetBodyResponse struct {
+	// Path is the body path of the dataset the page was read from
+	Path string
+	Data []byte
+	// Limit is the number of entries the page was read with, which is less
+	// than the requested limit for pages that would be too large to send
+	Limit int
+	// Err is a message describing why a page couldn't be served, if any
+	Err string
+}
+
+// RequestDatasetBody asks peers for a page of a dataset body. Peers only serve
+// pages from the head of datasets they have published
+func (n *QriNode) RequestDatasetBody(p DatasetBodyRequest) (*DatasetBodyResponse, error) {
+	log.Debugf("%s RequestDatasetBody %s", n.ID, p.Ref)
+
+	if !n.Online {
+		return nil, ErrNotConnected
+	}
+
+	pids := n.ClosestConnectedQriPeers(p.Ref.ProfileID, NumPeersToContact)
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no connected peers")
+	}
+
+	req, err := NewJSONBodyMessage(n.ID, MtDatasetBody, p)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	req = req.WithHeaders("phase", "request")
+
+	for _, pid := range pids {
+		// each peer gets it's own buffered channel so a late reply from a peer
+		// that timed out can't block or be mistaken for the next peer's reply
+		replies := make(chan Message, 1)
+		if err = n.SendMessage(req, replies, pid); err != nil {
+			log.Debugf("%s err: %s", pid, err.Error())
+			continue
+		}
+
+		var msg Message
+		select {
+		case msg = <-replies:
+		case <-time.After(bodyResponseTimeout):
+			err = fmt.Errorf("timed out waiting for peer %s to respond", pid.Pretty())
+			log.Debugf("%s err: %s", pid, err.Error())
+			continue
+		case <-n.Context().Done():
+			return nil, n.Context().Err()
+		}
+
+		res := &DatasetBodyResponse{}
+		if err = json.Unmarshal(msg.Body, res); err != nil {
+			log.Debugf("%s err: %s", pid, err.Error())
+			continue
+		}
+		if res.Err != "" {
+			err = fmt.Errorf("%s", res.Err)
+			log.Debugf("%s err: %s", pid, res.Err)
+			continue
+		}
+		if res.Path != "" {
+			return res, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("unable to locate dataset body for %s", p.Ref)
+}
+
+func (n *QriNode) handleDatasetBody(ws *WrappedStream, msg Message) (hangup bool) {
+	hangup = true
+
+	switch msg.Header("phase") {
+	case "request":
+		p := DatasetBodyRequest{}
+		if err := json.Unmarshal(msg.Body, &p); err != nil {
+			log.Debug(err.Error())
+			return
+		}
+
+		res := DatasetBodyResponse{}
+		res.Path, res.Data, res.Limit, res.Err = n.readBodyPage(p)
+		reply, err := msg.UpdateJSON(res)
+		if err != nil {
+			log.Debug(err.Error())
+			return
+		}
+
+		reply = reply.WithHeaders("phase", "response")
+		if err := ws.sendMessage(reply); err != nil {
+			log.Debug(err.Error())
+		}
+	}
+
+	return
+}
+
+// readBodyPage reads a requested page of a local dataset body, returning an
+// error message if the request can't be served. Only the published status &
+// path stored in the local repo are used, never the ones the requester sent.
+// pages larger than bodyPageMaxBytes are read again with half the entries
+func (n *QriNode) readBodyPage(p DatasetBodyRequest) (bodyPath string, data []byte, limit int, errMsg string) {
+	// look the dataset up by name alone, a path would match any ref with that path
+	req := repo.DatasetRef{Peername: p.Ref.Peername, ProfileID: p.Ref.ProfileID, Name: p.Ref.Name}
+	if req.Name == "" {
+		return "", nil, 0, "dataset body requests must include a dataset name"
+	}
+	if err := repo.CanonicalizeProfile(n.Repo, &req, nil); err != nil {
+		return "", nil, 0, err.Error()
+	}
+	ref, err := n.Repo.GetRef(req)
+	if err != nil {
+		// non-local datasets respond with empty results
+		if err == repo.ErrNotFound {
+			return "", nil, 0, ""
+		}
+		return "", nil, 0, err.Error()
+	}
+
+	if !ref.Published {
+		return "", nil, 0, fmt.Sprintf("dataset %s is not published", ref.AliasString())
+	}
+	if p.Ref.Path != "" && p.Ref.Path != ref.Path {
+		return "", nil, 0, fmt.Sprintf("only the latest version of %s can be read", ref.AliasString())
+	}
+
+	if p.Limit <= 0 || p.Limit > bodyPageMax {
+		p.Limit = bodyPageMax
+	}
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+
+	df := dataset.JSONDataFormat
+	if p.Format != "" {
+		if df, err = dataset.ParseDataFormatString(p.Format); err != nil {
+			return "", nil, 0, err.Error()
+		}
+	}
+	fcfg, err := dataset.ParseFormatConfigMap(df, p.FormatConfig)
+	if err != nil {
+		return "", nil, 0, err.Error()
+	}
+
+	store := n.Repo.Store()
+	ds, err := dsfs.LoadDataset(store, ref.Path)
+	if err != nil {
+		return "", nil, 0, err.Error()
+	}
+	st := &dataset.Structure{}
+	st.Assign(ds.Structure, &dataset.Structure{
+		Format:       df,
+		FormatConfig: fcfg,
+		Schema:       ds.Structure.Schema,
+	})
+
+	for {
+		file, err := dsfs.LoadBody(store, ds)
+		if err != nil {
+			return "", nil, 0, err.Error()
+		}
+		data, err = base.ConvertBodyPage(file, ds.Structure, st, p.Limit, p.Offset, false, p.Fields)
+		file.Close()
+		if err != nil {
+			return "", nil, 0, err.Error()
+		}
+		if len(data) <= bodyPageMaxBytes {
+			return ds.BodyPath, data, p.Limit, ""
+		}
+		if p.Limit == 1 {
+			return "", nil, 0, fmt.Sprintf("body entry %d of %s is larger than the max page size of %d bytes", p.Offset, ref.AliasString(), bodyPageMaxBytes)
+		}
+		p.Limit /= 2
+	}
+}
@@ -0,0 +1,101 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/qri-io/dataset/dstest"
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/p2p/test"
+)
+
+func TestRequestDatasetBody(t *testing.T) {
+	ctx := context.Background()
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestDirNetwork(ctx, factory)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	if err := p2ptest.ConnectQriNodes(ctx, testPeers); err != nil {
+		t.Fatalf("error connecting peers: %s", err.Error())
+	}
+
+	peers := asQriNodes(testPeers)
+
+	tc, err := dstest.NewTestCaseFromDir("testdata/tim/craigslist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// add a dataset to tim
+	ref, _, err := base.CreateDataset(peers[4].Repo, ioes.NewDiscardIOStreams(), tc.Name, tc.Input, nil, tc.BodyFile(), nil, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := DatasetBodyRequest{Ref: ref, Limit: 2, Fields: []string{"name"}}
+	if _, err := peers[0].RequestDatasetBody(p); err == nil {
+		t.Error("expected requesting an unpublished dataset body to error")
+	}
+
+	// peers only trust their own record of published status
+	claimed := ref
+	claimed.Published = true
+	if _, err := peers[0].RequestDatasetBody(DatasetBodyRequest{Ref: claimed, Limit: 2}); err == nil {
+		t.Error("expected requesting an unpublished dataset body that claims to be published to error")
+	}
+
+	if err := base.SetPublishStatus(peers[4].Repo, &ref, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// paths other than the published head can't be read
+	other := ref
+	other.Path = "/map/QmNotTheHeadOfThisDataset"
+	if _, err := peers[0].RequestDatasetBody(DatasetBodyRequest{Ref: other, Limit: 2}); err == nil {
+		t.Error("expected requesting a path other than the dataset head to error")
+	}
+
+	res, err := peers[0].RequestDatasetBody(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []map[string]interface{}{}
+	if err := json.Unmarshal(res.Data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected 2 entries, got: %d", len(entries))
+	}
+	for i, ent := range entries {
+		if len(ent) != 1 || ent["name"] == nil {
+			t.Errorf("entry %d expected to only have a name field, got: %v", i, ent)
+		}
+	}
+
+	// pages too large to send are served with fewer entries
+	defer func(max int) { bodyPageMaxBytes = max }(bodyPageMaxBytes)
+	bodyPageMaxBytes = len(res.Data) - 1
+	trimmed, err := peers[0].RequestDatasetBody(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trimmed.Limit != 1 {
+		t.Errorf("expected a trimmed page to have a limit of 1, got: %d", trimmed.Limit)
+	}
+	if err := json.Unmarshal(trimmed.Data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected 1 entry, got: %d", len(entries))
+	}
+
+	// entries too large for a page are refused, not dropped
+	bodyPageMaxBytes = 1
+	if _, err := peers[0].RequestDatasetBody(p); err == nil {
+		t.Error("expected requesting an entry larger than a page to error")
+	}
+}
@@ -1,7 +1,6 @@
 package p2p
 
 import (
-	"context"
 	"fmt"
 	"time"
 
@@ -13,7 +12,7 @@ import (
 // services if one doesn't exist, then registering to be notified on peer discovery
 func (n *QriNode) StartDiscovery(bootstrapPeers chan pstore.PeerInfo) error {
 	if n.Discovery == nil {
-		service, err := discovery.NewMdnsService(context.Background(), n.host, time.Second*5, QriServiceTag)
+		service, err := discovery.NewMdnsService(n.Context(), n.host, time.Second*5, QriServiceTag)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,85 @@
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/qri-io/qri/repo"
+
+	peer "gx/ipfs/QmTRhk7cgjUf2gfQ3p2M9KPECNZEW9XUrmHcFCgog4cPgB/go-libp2p-peer"
+)
+
+// MtFollow tells the peer that owns a dataset that this node follows it
+const MtFollow = MsgType("follow")
+
+// RequestFollow tells the peers of the profile that owns ref that this node
+// follows the dataset. Requests made while offline are queued & sent once the
+// node is back online. Owners record follows in their event log
+func (n *QriNode) RequestFollow(ref repo.DatasetRef) error {
+	log.Debugf("%s RequestFollow %s", n.ID, ref)
+	return n.sendDatasetRequest(MtFollow, ref)
+}
+
+func (n *QriNode) handleFollow(ws *WrappedStream, msg Message) (hangup bool) {
+	hangup = true
+	n.logDatasetRequest(repo.ETDsFollowed, msg, true)
+	return
+}
+
+// sendDatasetRequest sends or queues a message of type mt about ref to the
+// peers of the profile that owns ref. requests for profiles without known
+// peers go to every connected peer
+func (n *QriNode) sendDatasetRequest(mt MsgType, ref repo.DatasetRef, pids ...peer.ID) error {
+	if ref.ProfileID == "" || ref.Name == "" {
+		return fmt.Errorf("%s requests need a dataset profile ID & name", mt)
+	}
+	// only send identifiers, ref.Dataset may be populated
+	data, err := json.Marshal(repo.DatasetRef{
+		Peername:  ref.Peername,
+		ProfileID: ref.ProfileID,
+		Name:      ref.Name,
+		Path:      ref.Path,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(pids) == 0 {
+		if pids, err = n.Repo.Profiles().PeerIDs(ref.ProfileID); err != nil {
+			log.Debugf("no known peers for %s, sending %s to connected peers: %s", ref.ProfileID, mt, err.Error())
+		}
+	}
+	return n.SendOrQueueMessage(NewMessage(n.ID, mt, data), pids...)
+}
+
+// logDatasetRequest records a dataset request in the event log. owned
+// requests are only recorded for datasets this repo has
+func (n *QriNode) logDatasetRequest(t repo.EventType, msg Message, owned bool) {
+	// bail early if we've seen this message before
+	if _, ok := n.msgState.Load(msg.ID); ok {
+		return
+	}
+	n.msgState.Store(msg.ID, true)
+	go func(id string) {
+		<-time.After(time.Minute)
+		n.msgState.Delete(id)
+	}(msg.ID)
+
+	ref := repo.DatasetRef{}
+	if err := json.Unmarshal(msg.Body, &ref); err != nil {
+		log.Debug(err.Error())
+		return
+	}
+	if owned {
+		local, err := n.Repo.GetRef(repo.DatasetRef{ProfileID: ref.ProfileID, Name: ref.Name})
+		if err != nil {
+			log.Debugf("%s request for a dataset this repo doesn't have: %s", msg.Type, ref)
+			return
+		}
+		ref = local
+	}
+	if err := n.Repo.LogEvent(t, ref); err != nil {
+		log.Debug(err.Error())
+	}
+}
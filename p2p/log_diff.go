@@ -17,7 +17,7 @@ const MtLogDiff = MsgType("log_diff")
 func (n *QriNode) RequestLogDiff(ref *repo.DatasetRef) (ldr base.LogDiffResult, err error) {
 	log.Debugf("%s RequestLogDiff %s", n.ID, ref)
 
-	p, err := n.ConnectToPeer(n.ctx, PeerConnectionParams{
+	p, err := n.ConnectToPeer(n.Context(), PeerConnectionParams{
 		Peername:  ref.Peername,
 		ProfileID: ref.ProfileID,
 	})
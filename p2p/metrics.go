@@ -0,0 +1,16 @@
+package p2p
+
+import "github.com/qri-io/qri/metrics"
+
+var (
+	messagesSent = metrics.NewCounterVec("qri_p2p_messages_sent_total",
+		"qri protocol messages sent to peers, by message type", "type")
+	messagesReceived = metrics.NewCounterVec("qri_p2p_messages_received_total",
+		"qri protocol messages received from peers, by message type", "type")
+	messageErrors = metrics.NewCounterVec("qri_p2p_message_errors_total",
+		"qri protocol messages that failed to send, couldn't be read, or had no handler. direction is send or receive", "type", "direction")
+)
+
+func init() {
+	metrics.MustRegister(messagesSent, messagesReceived, messageErrors)
+}
@@ -5,7 +5,8 @@ import (
 	net "gx/ipfs/QmXuRkCR7BNQa9uqfpTiFWsTQLzmTWYg91Ja1w95gnqb6u/go-libp2p-net"
 )
 
-// a no-op implimentation of the Notifee interface
+// networkNotifee implements the Notifee interface, publishing node events
+// for qri peer connections
 type networkNotifee struct {
 	node *QriNode
 }
@@ -13,8 +14,14 @@ type networkNotifee struct {
 // Connected is called when a connection opened
 func (n networkNotifee) Connected(net net.Network, conn net.Conn) {}
 
-// Disconnectec is called when a connection closed
-func (n networkNotifee) Disconnected(net net.Network, conn net.Conn) {}
+// Disconnected is called when a connection closed, publishing an event if
+// the peer was a qri peer
+func (n networkNotifee) Disconnected(net net.Network, conn net.Conn) {
+	pid := conn.RemotePeer()
+	if support, err := n.node.host.Peerstore().Get(pid, qriSupportKey); err == nil && support == true {
+		n.node.PublishEvent(NEPeerDisconnected, pid.Pretty())
+	}
+}
 
 // OpenedStream is called when a stream opened
 func (n networkNotifee) OpenedStream(net net.Network, s net.Stream) {}
@@ -3,6 +3,7 @@ package p2p
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -36,8 +37,11 @@ type QriNode struct {
 
 	cfg *config.P2P
 
-	// base context for this node
-	ctx context.Context
+	// base context for this node, cancelled on Close. Start replaces it, so
+	// it's only read with Context while holding ctxLock
+	ctxLock sync.RWMutex
+	ctx     context.Context
+	cancel  context.CancelFunc
 
 	// Online indicates weather this is node is connected to the p2p network
 	Online bool
@@ -73,6 +77,14 @@ type QriNode struct {
 	// local feedback as opposed to p2p connections
 	LocalStreams ioes.IOStreams
 
+	// SendFilter, if set, is called before every message this node sends.
+	// Returning an error prevents the message from being sent. Used to
+	// simulate network conditions in tests, see the p2p/sim package
+	SendFilter SendFilter
+
+	// events fans node activity out to subscribers, see SubscribeEvents
+	events eventBus
+
 	// networkNotifee satisfies the net.Notifee interface
 	networkNotifee networkNotifee
 
@@ -81,6 +93,10 @@ type QriNode struct {
 	// autonat *autonat.AutoNATService
 }
 
+// SendFilter inspects an outbound message before it's sent to peer pid.
+// Filters may block to delay delivery, and return an error to drop a message
+type SendFilter func(pid peer.ID, msg Message) error
+
 // Assert that conversions needed by the tests are valid.
 var _ p2ptest.TestablePeerNode = (*QriNode)(nil)
 var _ p2ptest.NodeMakerFunc = NewTestableQriNode
@@ -100,11 +116,13 @@ func NewQriNode(r repo.Repo, p2pconf *config.P2P) (node *QriNode, err error) {
 		return nil, fmt.Errorf("error decoding peer id: %s", err.Error())
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	node = &QriNode{
 		ID:       pid,
 		cfg:      p2pconf,
 		Repo:     r,
-		ctx:      context.Background(),
+		ctx:      ctx,
+		cancel:   cancel,
 		msgState: &sync.Map{},
 		msgChan:  make(chan Message),
 		// Make sure we always have proper IOStreams, this can be set
@@ -133,6 +151,23 @@ func (n *QriNode) setHost(h host.Host) {
 	n.host = h
 }
 
+// Start brings the node online, binding the node's lifetime to ctx. Cancelling
+// ctx stops all background work the node has started, but doesn't release
+// resources. Call Close for a full shutdown
+func (n *QriNode) Start(ctx context.Context) error {
+	if n.Online {
+		return nil
+	}
+	n.ctxLock.Lock()
+	// cancel the context NewQriNode created so nothing is left waiting on it
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.ctx, n.cancel = context.WithCancel(ctx)
+	n.ctxLock.Unlock()
+	return n.GoOnline()
+}
+
 // GoOnline puts QriNode on the distributed web, ensuring there's an active peer-2-peer host
 // participating in a peer-2-peer network, and kicks off requests to connect to known bootstrap
 // peers that support the QriProtocol
@@ -164,7 +199,7 @@ func (n *QriNode) GoOnline() (err error) {
 		}
 	} else if n.host == nil {
 		ps := pstoremem.NewPeerstore()
-		n.host, err = makeBasicHost(n.ctx, ps, n.cfg)
+		n.host, err = makeBasicHost(n.Context(), ps, n.cfg)
 		if err != nil {
 			return fmt.Errorf("error creating host: %s", err.Error())
 		}
@@ -175,6 +210,7 @@ func (n *QriNode) GoOnline() (err error) {
 	// the distributed web that this node supports Qri. for more info on
 	// multistreams  check github.com/multformats/go-multistream
 	n.host.SetStreamHandler(QriProtocolID, n.QriStreamHandler)
+	n.host.SetStreamHandler(QriBinaryProtocolID, n.QriStreamHandler)
 
 	// TODO - wait for new IPFS release
 	// if n.cfg.AutoNAT {
@@ -216,11 +252,18 @@ func (n *QriNode) startOnlineServices() error {
 
 	go func() {
 		// block until we have at least one successful bootstrap connection
-		<-bsPeers
+		select {
+		case <-bsPeers:
+		case <-n.Context().Done():
+			return
+		}
 
 		if err := n.AnnounceConnected(); err != nil {
 			log.Infof("error announcing connected: %s", err.Error())
 		}
+
+		// now that we have peers, deliver anything queued while offline
+		n.flushQueueLoop()
 	}()
 
 	return n.StartDiscovery(bsPeers)
@@ -235,9 +278,13 @@ func (n *QriNode) ReceiveMessages() chan Message {
 
 func (n *QriNode) echoMessages() {
 	for {
-		msg := <-n.msgChan
-		for _, r := range n.receivers {
-			r <- msg
+		select {
+		case msg := <-n.msgChan:
+			for _, r := range n.receivers {
+				r <- msg
+			}
+		case <-n.Context().Done():
+			return
 		}
 	}
 }
@@ -280,18 +327,58 @@ func (n *QriNode) EncapsulatedAddresses() []ma.Multiaddr {
 
 // Context returns this node's context
 func (n *QriNode) Context() context.Context {
+	n.ctxLock.RLock()
+	defer n.ctxLock.RUnlock()
 	if n.ctx == nil {
-		n.ctx = context.Background()
+		return context.Background()
 	}
 	return n.ctx
 }
 
-// TODO - finish. We need a proper termination & cleanup process
-// func (n *QriNode) Close() error {
-// 	if node, err := n.IPFSNode(); err == nil {
-// 		return node.Close()
-// 	}
-// }
+// Close shuts the node down, stopping background work, closing the p2p host
+// & underlying IPFS node, and closing the repo if the repo supports it.
+// Closing an IPFS node releases it's repo lock
+func (n *QriNode) Close() (err error) {
+	n.ctxLock.RLock()
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.ctxLock.RUnlock()
+	n.Online = false
+
+	if n.Discovery != nil {
+		n.Discovery.UnregisterNotifee(n)
+	}
+
+	if node, e := n.IPFSNode(); e == nil {
+		// the ipfs node owns both host & discovery service
+		if e := node.Close(); e != nil {
+			log.Errorf("error closing IPFS node: %s", e.Error())
+			err = e
+		}
+	} else {
+		if n.Discovery != nil {
+			if e := n.Discovery.Close(); e != nil {
+				log.Debugf("error closing discovery service: %s", e.Error())
+			}
+		}
+		if n.host != nil {
+			if e := n.host.Close(); e != nil {
+				log.Errorf("error closing host: %s", e.Error())
+				err = e
+			}
+		}
+	}
+
+	if closer, ok := n.Repo.(io.Closer); ok {
+		if e := closer.Close(); e != nil {
+			log.Errorf("error closing repo: %s", e.Error())
+			err = e
+		}
+	}
+
+	return err
+}
 
 // makeBasicHost creates a LibP2P host from a NodeCfg
 func makeBasicHost(ctx context.Context, ps pstore.Peerstore, p2pconf *config.P2P) (host.Host, error) {
@@ -337,8 +424,17 @@ func (n *QriNode) SendMessage(msg Message, replies chan Message, pids ...peer.ID
 			continue
 		}
 
-		s, err := n.host.NewStream(n.Context(), peerID, QriProtocolID)
+		if n.SendFilter != nil {
+			if err := n.SendFilter(peerID, msg); err != nil {
+				messageErrors.With(msg.Type.String(), "send").Inc()
+				return err
+			}
+		}
+
+		// list binary framing first so it's picked whenever the peer supports it
+		s, err := n.host.NewStream(n.Context(), peerID, QriBinaryProtocolID, QriProtocolID)
 		if err != nil {
+			messageErrors.With(msg.Type.String(), "send").Inc()
 			return fmt.Errorf("error opening stream: %s", err.Error())
 		}
 		defer s.Close()
@@ -350,8 +446,10 @@ func (n *QriNode) SendMessage(msg Message, replies chan Message, pids ...peer.ID
 		ws := WrapStream(s)
 		go n.handleStream(ws, replies)
 		if err := ws.sendMessage(msg); err != nil {
+			messageErrors.With(msg.Type.String(), "send").Inc()
 			return err
 		}
+		messagesSent.With(msg.Type.String()).Inc()
 	}
 
 	return nil
@@ -377,6 +475,7 @@ func (n *QriNode) handleStream(ws *WrappedStream, replies chan Message) {
 				break
 			}
 			log.Debugf("error receiving message: %s", err.Error())
+			messageErrors.With("", "receive").Inc()
 			break
 		}
 
@@ -384,14 +483,21 @@ func (n *QriNode) handleStream(ws *WrappedStream, replies chan Message) {
 			go func() { replies <- msg }()
 		}
 		go func() {
-			n.msgChan <- msg
+			select {
+			case n.msgChan <- msg:
+			case <-n.Context().Done():
+			}
 		}()
+		n.PublishEvent(NEMessage, MessageInfo{ID: msg.ID, Type: msg.Type, Initiator: msg.Initiator})
 
 		handler, ok := n.handlers[msg.Type]
 		if !ok {
 			log.Infof("peer %s sent unrecognized message type '%s', hanging up", n.ID, msg.Type)
+			// peers choose message types, don't let them choose metric labels
+			messageErrors.With("unknown", "receive").Inc()
 			break
 		}
+		messagesReceived.With(msg.Type.String()).Inc()
 
 		if hangup := handler(ws, msg); hangup {
 			break
@@ -425,6 +531,7 @@ func MakeHandlers(n *QriNode) map[MsgType]HandlerFunc {
 		MtPing:              n.handlePing,
 		MtProfile:           n.handleProfile,
 		MtDatasetInfo:       n.handleDataset,
+		MtDatasetBody:       n.handleDatasetBody,
 		MtDatasets:          n.handleDatasetsList,
 		MtEvents:            n.handleEvents,
 		MtConnected:         n.handleConnected,
@@ -432,5 +539,7 @@ func MakeHandlers(n *QriNode) map[MsgType]HandlerFunc {
 		MtDatasetLog:        n.handleDatasetLog,
 		MtQriPeers:          n.handleQriPeers,
 		MtLogDiff:           n.handleLogDiff,
+		MtFollow:            n.handleFollow,
+		MtPinRequest:        n.handlePinRequest,
 	}
 }
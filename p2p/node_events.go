@@ -0,0 +1,175 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/qri-io/qri/repo"
+
+	peer "gx/ipfs/QmTRhk7cgjUf2gfQ3p2M9KPECNZEW9XUrmHcFCgog4cPgB/go-libp2p-peer"
+)
+
+// NodeEventType classifies node activity
+type NodeEventType string
+
+const (
+	// NERepoEvent is an event logged by the node's repo, like saving or
+	// renaming a dataset. Data is a *repo.Event
+	NERepoEvent = NodeEventType("repo_event")
+	// NEMessage is a p2p message received by the node. Data is a MessageInfo
+	NEMessage = NodeEventType("p2p_message")
+	// NEPeerConnected is a qri peer connecting. Data is the peer's ID string
+	NEPeerConnected = NodeEventType("peer_connected")
+	// NEPeerDisconnected is a qri peer disconnecting. Data is the peer's ID string
+	NEPeerDisconnected = NodeEventType("peer_disconnected")
+	// NETransformOutput is output from a running transform script. Data is a
+	// string
+	NETransformOutput = NodeEventType("transform_output")
+	// NEProgress reports the progress of a long-running operation. Data is a
+	// Progress
+	NEProgress = NodeEventType("progress")
+)
+
+// eventBufferSize is the number of events buffered for each subscriber.
+// subscribers that fall further behind miss events
+const eventBufferSize = 64
+
+// NodeEvent is a notification of node activity
+type NodeEvent struct {
+	Type NodeEventType `json:"type"`
+	Time time.Time     `json:"time"`
+	Data interface{}   `json:"data"`
+}
+
+// MessageInfo summarizes a p2p message, omitting the message body
+type MessageInfo struct {
+	ID        string  `json:"id"`
+	Type      MsgType `json:"type"`
+	Initiator peer.ID `json:"initiator"`
+}
+
+// Progress describes the state of a long-running operation
+type Progress struct {
+	// Op names the operation, eg: "save", "publish"
+	Op string `json:"op"`
+	// Ref is the dataset the operation applies to
+	Ref string `json:"ref"`
+	// Status is one of "started", "progress", "done" or "failed"
+	Status string `json:"status"`
+	// Message is optional detail, like an error message
+	Message string `json:"message,omitempty"`
+	// Completed & Total count units of work, like bytes uploaded, for
+	// operations that report partial progress
+	Completed int64 `json:"completed,omitempty"`
+	Total     int64 `json:"total,omitempty"`
+}
+
+// eventBus fans node events out to subscribers
+type eventBus struct {
+	lock sync.Mutex
+	subs map[chan NodeEvent]struct{}
+	// forwarding is true while repo events are being forwarded to subscribers
+	forwarding bool
+}
+
+// SubscribeEvents returns a channel of node events that closes when ctx is
+// cancelled. Subscribers that don't keep up miss events rather than slow
+// down the node
+func (n *QriNode) SubscribeEvents(ctx context.Context) <-chan NodeEvent {
+	ch := make(chan NodeEvent, eventBufferSize)
+
+	n.events.lock.Lock()
+	if n.events.subs == nil {
+		n.events.subs = map[chan NodeEvent]struct{}{}
+	}
+	n.events.subs[ch] = struct{}{}
+	if !n.events.forwarding {
+		n.forwardRepoEvents()
+	}
+	n.events.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		n.events.lock.Lock()
+		delete(n.events.subs, ch)
+		close(ch)
+		n.events.lock.Unlock()
+	}()
+	return ch
+}
+
+// PublishEvent sends an event to all subscribers without blocking
+func (n *QriNode) PublishEvent(t NodeEventType, data interface{}) {
+	e := NodeEvent{Type: t, Time: time.Now(), Data: data}
+
+	n.events.lock.Lock()
+	defer n.events.lock.Unlock()
+	for ch := range n.events.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// EventWriter returns an io.Writer that publishes each write as an event of
+// type t
+func (n *QriNode) EventWriter(t NodeEventType) *EventWriter {
+	return &EventWriter{node: n, t: t}
+}
+
+// EventWriter publishes writes as node events
+type EventWriter struct {
+	node *QriNode
+	t    NodeEventType
+}
+
+// Write implements the io.Writer interface
+func (w *EventWriter) Write(p []byte) (int, error) {
+	w.node.PublishEvent(w.t, string(p))
+	return len(p), nil
+}
+
+// forwardRepoEvents starts publishing events logged by the repo until there
+// are no subscribers left. callers must hold the event bus lock
+func (n *QriNode) forwardRepoEvents() {
+	feed, ok := n.Repo.(repo.EventFeed)
+	if !ok {
+		return
+	}
+
+	// subscribe before returning so events logged right after a call to
+	// SubscribeEvents aren't missed
+	events := make(chan *repo.Event, eventBufferSize)
+	feed.SubscribeEvents(events)
+	n.events.forwarding = true
+
+	go func() {
+		defer feed.UnsubscribeEvents(events)
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+
+		for {
+			select {
+			case e := <-events:
+				n.PublishEvent(NERepoEvent, e)
+			case <-t.C:
+				n.events.lock.Lock()
+				if len(n.events.subs) == 0 {
+					// clear the flag while holding the lock so the next
+					// subscriber starts a fresh forwarder
+					n.events.forwarding = false
+					n.events.lock.Unlock()
+					return
+				}
+				n.events.lock.Unlock()
+			case <-n.Context().Done():
+				n.events.lock.Lock()
+				n.events.forwarding = false
+				n.events.lock.Unlock()
+				return
+			}
+		}
+	}()
+}
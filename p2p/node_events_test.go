@@ -0,0 +1,81 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qri-io/qri/config"
+	cfgtest "github.com/qri-io/qri/config/test"
+	"github.com/qri-io/qri/repo"
+	"github.com/qri-io/qri/repo/profile"
+	"github.com/qri-io/qri/repo/test"
+)
+
+func newEventsTestNode(t *testing.T) *QriNode {
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.ID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	node, err := NewQriNode(r, config.DefaultP2PForTesting())
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+	return node
+}
+
+func expectNodeEvent(t *testing.T, events <-chan NodeEvent, typ NodeEventType) NodeEvent {
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("event channel closed waiting for %s event", typ)
+			}
+			if e.Type == typ {
+				return e
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatalf("timed out waiting for %s event", typ)
+		}
+	}
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	node := newEventsTestNode(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := node.SubscribeEvents(ctx)
+
+	node.PublishEvent(NEProgress, Progress{Op: "save", Ref: "peer/cities", Status: "started"})
+	e := expectNodeEvent(t, events, NEProgress)
+	if pr, ok := e.Data.(Progress); !ok || pr.Status != "started" {
+		t.Errorf("expected started progress event, got: %#v", e.Data)
+	}
+
+	if _, err := node.EventWriter(NETransformOutput).Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	if e := expectNodeEvent(t, events, NETransformOutput); e.Data != "hello\n" {
+		t.Errorf("expected transform output 'hello\\n', got: %#v", e.Data)
+	}
+
+	ref := repo.DatasetRef{Peername: "peer", Name: "cities"}
+	if err := node.Repo.LogEvent(repo.ETDsRenamed, ref); err != nil {
+		t.Fatal(err)
+	}
+	e = expectNodeEvent(t, events, NERepoEvent)
+	if re, ok := e.Data.(*repo.Event); !ok || re.Type != repo.ETDsRenamed {
+		t.Errorf("expected %s repo event, got: %#v", repo.ETDsRenamed, e.Data)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		for ok {
+			_, ok = <-events
+		}
+	case <-time.After(time.Second):
+		t.Error("expected event channel to close when context is cancelled")
+	}
+}
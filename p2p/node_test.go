@@ -1,7 +1,9 @@
 package p2p
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/qri-io/qri/config"
 	cfgtest "github.com/qri-io/qri/config/test"
@@ -34,3 +36,35 @@ func TestNewNode(t *testing.T) {
 		t.Errorf("online should equal true")
 	}
 }
+
+func TestNodeStart(t *testing.T) {
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.ID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+	n, err := NewQriNode(r, config.DefaultP2PForTesting())
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+	defer n.Close()
+
+	orig := n.Context()
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := n.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-orig.Done():
+	default:
+		t.Error("expected starting a node to cancel it's original context")
+	}
+
+	cancel()
+	select {
+	case <-n.Context().Done():
+	case <-time.After(time.Second):
+		t.Error("expected cancelling the start context to cancel the node context")
+	}
+}
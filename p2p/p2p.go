@@ -15,6 +15,10 @@ var log = golog.Logger("qrip2p")
 const (
 	// QriProtocolID is the top level Protocol Identifier
 	QriProtocolID = protocol.ID("/qri")
+	// QriBinaryProtocolID is the qri protocol using length-prefixed CBOR
+	// message framing instead of JSON. Peers that support it are preferred
+	// when opening streams
+	QriBinaryProtocolID = protocol.ID("/qri/cbor/0.1.0")
 	// QriServiceTag tags the type & version of the qri service
 	QriServiceTag = "qri/0.6.2-dev"
 	// default value to give qri peer connections in connmanager, one hunnit
@@ -0,0 +1,25 @@
+package p2p
+
+import (
+	"github.com/qri-io/qri/repo"
+
+	peer "gx/ipfs/QmTRhk7cgjUf2gfQ3p2M9KPECNZEW9XUrmHcFCgog4cPgB/go-libp2p-peer"
+)
+
+// MtPinRequest asks a peer to pin a dataset
+const MtPinRequest = MsgType("pin_request")
+
+// RequestPin asks peers to pin a dataset, sending to the peers of the
+// profile that owns ref if no peer IDs are given. Requests made while
+// offline are queued & sent once the node is back online. Peers record pin
+// requests in their event log, pinning is left to the peer's user
+func (n *QriNode) RequestPin(ref repo.DatasetRef, pids ...peer.ID) error {
+	log.Debugf("%s RequestPin %s", n.ID, ref)
+	return n.sendDatasetRequest(MtPinRequest, ref, pids...)
+}
+
+func (n *QriNode) handlePinRequest(ws *WrappedStream, msg Message) (hangup bool) {
+	hangup = true
+	n.logDatasetRequest(repo.ETDsPinRequested, msg, false)
+	return
+}
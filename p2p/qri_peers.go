@@ -65,6 +65,7 @@ func (n *QriNode) UpgradeToQriConnection(pinfo pstore.PeerInfo) error {
 	log.Debugf("%s upgraded %s to Qri connection", n.ID, pid)
 	// tag the connection as more important in the conn manager:
 	n.host.ConnManager().TagPeer(pid, qriSupportKey, qriSupportValue)
+	n.PublishEvent(NEPeerConnected, pid.Pretty())
 
 	if _, err := n.RequestProfile(pid); err != nil {
 		log.Debug(err.Error())
@@ -76,7 +77,7 @@ func (n *QriNode) UpgradeToQriConnection(pinfo pstore.PeerInfo) error {
 		if err != nil {
 			log.Debug("error fetching qri peers: %s", err)
 		}
-		n.RequestNewPeers(n.ctx, ps)
+		n.RequestNewPeers(n.Context(), ps)
 	}()
 
 	return nil
@@ -0,0 +1,213 @@
+package p2p
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/qri-io/qri/repo"
+
+	peer "gx/ipfs/QmTRhk7cgjUf2gfQ3p2M9KPECNZEW9XUrmHcFCgog4cPgB/go-libp2p-peer"
+)
+
+const (
+	// MaxQueueAttempts is the number of times sending a queued message is tried
+	// before it's dropped from the queue
+	MaxQueueAttempts = 10
+	// queueFlushInterval is how often an online node checks it's message queue
+	queueFlushInterval = time.Second * 30
+	// queueBackoffBase is the delay before retrying a failed message for the
+	// first time. each subsequent failure doubles the delay
+	queueBackoffBase = time.Second * 5
+	// queueBackoffMax caps the delay between retries
+	queueBackoffMax = time.Hour
+)
+
+// ErrQueueNotSupported is returned when a node's repo can't persist messages
+var ErrQueueNotSupported = fmt.Errorf("repo doesn't support queueing messages")
+
+// QueueStatus summarizes the state of a node's outbound message queue
+type QueueStatus struct {
+	// Queued is the number of messages waiting to be sent
+	Queued int
+	// Failing is the number of queued messages that have failed at least once
+	Failing int
+	// Oldest is when the oldest queued message was created
+	Oldest time.Time
+}
+
+// SendOrQueueMessage sends a message that doesn't expect replies, queueing
+// the message in the repo if the node is offline. Queued messages are sent
+// when the node comes back online. Providing no peer IDs sends to all
+// connected qri peers
+func (n *QriNode) SendOrQueueMessage(msg Message, pids ...peer.ID) error {
+	if n.Online {
+		to := pids
+		if len(to) == 0 {
+			to = n.ConnectedQriPeerIDs()
+		}
+		if len(to) > 0 {
+			err := n.SendMessage(msg, nil, to...)
+			if err == nil {
+				return nil
+			}
+			log.Debugf("error sending message, queueing: %s", err.Error())
+		}
+	}
+	return n.QueueMessage(msg, pids...)
+}
+
+// QueueMessage adds a message to the repo's outbound queue
+func (n *QriNode) QueueMessage(msg Message, pids ...peer.ID) error {
+	mq, ok := n.Repo.(repo.MessageQueue)
+	if !ok {
+		return ErrQueueNotSupported
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return mq.PutQueuedMessage(repo.QueuedMessage{
+		ID:      msg.ID,
+		Type:    msg.Type.String(),
+		Message: data,
+		PeerIDs: pids,
+		Created: time.Now(),
+	})
+}
+
+// QueueStatus reports on the state of the outbound message queue
+func (n *QriNode) QueueStatus() (QueueStatus, error) {
+	status := QueueStatus{}
+	mq, ok := n.Repo.(repo.MessageQueue)
+	if !ok {
+		return status, ErrQueueNotSupported
+	}
+
+	msgs, err := mq.QueuedMessages()
+	if err != nil {
+		return status, err
+	}
+
+	status.Queued = len(msgs)
+	for _, m := range msgs {
+		if m.Attempts > 0 {
+			status.Failing++
+		}
+		if status.Oldest.IsZero() || m.Created.Before(status.Oldest) {
+			status.Oldest = m.Created
+		}
+	}
+	return status, nil
+}
+
+// FlushQueue attempts to send all queued messages that are due, returning
+// the number of messages sent. Failed messages are retried with exponential
+// backoff, and dropped after MaxQueueAttempts
+func (n *QriNode) FlushQueue() (sent int, err error) {
+	if !n.Online {
+		return 0, ErrNotConnected
+	}
+
+	mq, ok := n.Repo.(repo.MessageQueue)
+	if !ok {
+		return 0, ErrQueueNotSupported
+	}
+
+	msgs, err := mq.QueuedMessages()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	connected := n.ConnectedQriPeerIDs()
+	for _, qm := range msgs {
+		if qm.NextAttempt.After(now) {
+			continue
+		}
+		// broadcast messages wait for peers without counting as an attempt
+		if len(qm.PeerIDs) == 0 && len(connected) == 0 {
+			continue
+		}
+
+		if sendErr := n.sendQueuedMessage(qm, connected); sendErr != nil {
+			qm.Attempts++
+			qm.LastError = sendErr.Error()
+			qm.NextAttempt = now.Add(queueBackoff(qm.Attempts))
+
+			if qm.Attempts >= MaxQueueAttempts {
+				log.Infof("dropping queued %s message %s after %d attempts: %s", qm.Type, qm.ID, qm.Attempts, qm.LastError)
+				err = mq.DeleteQueuedMessage(qm.ID)
+			} else {
+				err = mq.PutQueuedMessage(qm)
+			}
+			if err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		if err = mq.DeleteQueuedMessage(qm.ID); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (n *QriNode) sendQueuedMessage(qm repo.QueuedMessage, connected []peer.ID) error {
+	msg := Message{}
+	if err := json.Unmarshal(qm.Message, &msg); err != nil {
+		return err
+	}
+
+	pids := qm.PeerIDs
+	if len(pids) == 0 {
+		pids = connected
+	}
+	return n.SendMessage(msg, nil, pids...)
+}
+
+// flushQueueLoop periodically flushes the message queue while the node is
+// online
+func (n *QriNode) flushQueueLoop() {
+	if _, ok := n.Repo.(repo.MessageQueue); !ok {
+		return
+	}
+
+	t := time.NewTicker(queueFlushInterval)
+	defer t.Stop()
+
+	for {
+		if !n.Online {
+			return
+		}
+		if sent, err := n.FlushQueue(); err != nil {
+			log.Debugf("error flushing message queue: %s", err.Error())
+		} else if sent > 0 {
+			log.Infof("sent %d queued messages", sent)
+		}
+
+		select {
+		case <-t.C:
+		case <-n.Context().Done():
+			return
+		}
+	}
+}
+
+// queueBackoff calculates the delay before retrying a message that has failed
+// attempts times
+func queueBackoff(attempts int) time.Duration {
+	d := queueBackoffBase
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= queueBackoffMax {
+			return queueBackoffMax
+		}
+	}
+	return d
+}
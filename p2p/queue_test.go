@@ -0,0 +1,171 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qri-io/dataset/dstest"
+	"github.com/qri-io/ioes"
+	"github.com/qri-io/qri/base"
+	"github.com/qri-io/qri/config"
+	cfgtest "github.com/qri-io/qri/config/test"
+	"github.com/qri-io/qri/p2p/test"
+	"github.com/qri-io/qri/repo"
+	"github.com/qri-io/qri/repo/profile"
+	"github.com/qri-io/qri/repo/test"
+)
+
+func TestSendOrQueueMessageOffline(t *testing.T) {
+	info := cfgtest.GetTestPeerInfo(0)
+	r, err := test.NewTestRepoFromProfileID(profile.ID(info.PeerID), 0, -1)
+	if err != nil {
+		t.Fatalf("error creating test repo: %s", err.Error())
+	}
+
+	node, err := NewQriNode(r, config.DefaultP2PForTesting())
+	if err != nil {
+		t.Fatalf("error creating qri node: %s", err.Error())
+	}
+
+	msg := NewMessage(node.ID, MtConnected, []byte("{}"))
+	if err := node.SendOrQueueMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := node.QueueStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Queued != 1 {
+		t.Errorf("expected 1 queued message, got: %d", status.Queued)
+	}
+
+	if _, err := node.FlushQueue(); err != ErrNotConnected {
+		t.Errorf("expected flushing an offline node to error with ErrNotConnected, got: %v", err)
+	}
+}
+
+func TestFlushQueue(t *testing.T) {
+	ctx := context.Background()
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestDirNetwork(ctx, factory)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	if err := p2ptest.ConnectQriNodes(ctx, testPeers); err != nil {
+		t.Fatalf("error connecting peers: %s", err.Error())
+	}
+
+	peers := asQriNodes(testPeers)
+
+	msg := NewMessage(peers[0].ID, MtConnected, []byte("{}"))
+	if err := peers[0].QueueMessage(msg, peers[1].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := peers[0].FlushQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 1 {
+		t.Errorf("expected 1 sent message, got: %d", sent)
+	}
+
+	status, err := peers[0].QueueStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Queued != 0 {
+		t.Errorf("expected empty queue after flushing, got: %d", status.Queued)
+	}
+}
+
+func TestDatasetRequestsQueued(t *testing.T) {
+	ctx := context.Background()
+	factory := p2ptest.NewTestNodeFactory(NewTestableQriNode)
+	testPeers, err := p2ptest.NewTestDirNetwork(ctx, factory)
+	if err != nil {
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	if err := p2ptest.ConnectQriNodes(ctx, testPeers); err != nil {
+		t.Fatalf("error connecting peers: %s", err.Error())
+	}
+
+	peers := asQriNodes(testPeers)
+	node, owner := peers[0], peers[1]
+
+	tc, err := dstest.NewTestCaseFromDir("testdata/tim/craigslist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, _, err := base.CreateDataset(owner.Repo, ioes.NewDiscardIOStreams(), tc.Name, tc.Input, nil, tc.BodyFile(), nil, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node.Online = false
+	// nodes announce themselves when they come online, offline announcements
+	// aren't queued
+	if err := node.AnnounceConnected(); err != ErrNotConnected {
+		t.Errorf("expected announcing while offline to error with ErrNotConnected, got: %v", err)
+	}
+	if err := node.RequestFollow(ref); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.RequestPin(ref, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	status, err := node.QueueStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Queued != 2 {
+		t.Fatalf("expected follow & pin requests to be queued while offline, got %d queued messages", status.Queued)
+	}
+
+	// coming back online delivers the requests
+	node.Online = true
+	sent, err := node.FlushQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 2 {
+		t.Errorf("expected queued requests to be sent, got: %d sent", sent)
+	}
+
+	expect := map[repo.EventType]bool{repo.ETDsFollowed: true, repo.ETDsPinRequested: true}
+	for i := 0; i < 100 && len(expect) > 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+		events, err := owner.Repo.Events(10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range events {
+			if e.Ref.Name == ref.Name {
+				delete(expect, e.Type)
+			}
+		}
+	}
+	if len(expect) > 0 {
+		t.Errorf("expected the owner to record follow & pin requests, missing: %v", expect)
+	}
+}
+
+func TestQueueBackoff(t *testing.T) {
+	cases := []struct {
+		attempts int
+		expect   time.Duration
+	}{
+		{1, queueBackoffBase},
+		{2, queueBackoffBase * 2},
+		{4, queueBackoffBase * 8},
+		{100, queueBackoffMax},
+	}
+
+	for i, c := range cases {
+		if got := queueBackoff(c.attempts); got != c.expect {
+			t.Errorf("case %d backoff mismatch. expected: %s, got: %s", i, c.expect, got)
+		}
+	}
+}
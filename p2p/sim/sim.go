@@ -0,0 +1,415 @@
+// Package p2psim is an in-process network simulator for qri peer-2-peer
+// integration tests. A simulated Network connects a set of named qri nodes,
+// each backed by an in-memory repo, and controls the links between them:
+// links can be partitioned and healed, slowed down with latency, made lossy,
+// and nodes can be crashed & restarted with their repos intact.
+//
+// Link conditions are applied to every message a node sends, making scripted
+// scenarios like "peer A saves, B syncs, A removes a revision" deterministic
+// for a given random seed. Each direction of a link draws message loss from
+// it's own random source, so loss depends only on the order of messages sent
+// across that link, not on how sends on other links interleave
+package p2psim
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qri-io/qri/config"
+	cfgtest "github.com/qri-io/qri/config/test"
+	"github.com/qri-io/qri/p2p"
+	"github.com/qri-io/qri/repo"
+	"github.com/qri-io/qri/repo/profile"
+	"github.com/qri-io/qri/repo/test"
+
+	ma "gx/ipfs/QmT4U94DnD8FRfqr21obWY32HLM5VExccPKMjQHofeYqr9/go-multiaddr"
+	peer "gx/ipfs/QmTRhk7cgjUf2gfQ3p2M9KPECNZEW9XUrmHcFCgog4cPgB/go-libp2p-peer"
+)
+
+var (
+	// ErrPartitioned is returned when sending a message across a partitioned link
+	ErrPartitioned = fmt.Errorf("link is partitioned")
+	// ErrMessageLost is returned when a lossy link drops a message
+	ErrMessageLost = fmt.Errorf("message lost")
+	// ErrNodeDown is returned when sending a message to a crashed node
+	ErrNodeDown = fmt.Errorf("node is down")
+)
+
+// Link describes network conditions between two nodes. Links are symmetric
+type Link struct {
+	// Partitioned links drop all messages
+	Partitioned bool
+	// Latency delays every message sent across the link
+	Latency time.Duration
+	// Loss is the probability, between 0 and 1, that a message is dropped
+	Loss float64
+}
+
+// Node is a named qri node on a simulated network
+type Node struct {
+	Name string
+	// QriNode is the currently running node. Restarting a node replaces it
+	*p2p.QriNode
+	// Repo persists across crashes & restarts
+	Repo repo.Repo
+
+	p2pconf *config.P2P
+	down    bool
+	// cancel stops the running node's goroutines
+	cancel context.CancelFunc
+}
+
+// Down returns true if the node has crashed and hasn't been restarted
+func (n *Node) Down() bool {
+	return n.down
+}
+
+// Refs lists all references in the node's refstore
+func (n *Node) Refs() ([]repo.DatasetRef, error) {
+	count, err := n.Repo.RefCount()
+	if err != nil {
+		return nil, err
+	}
+	return n.Repo.References(count, 0)
+}
+
+// Events lists the node's event log, oldest first
+func (n *Node) Events() ([]*repo.Event, error) {
+	return n.Repo.EventsSince(time.Time{})
+}
+
+// EventTypes lists the types of events in the node's event log, oldest first
+func (n *Node) EventTypes() ([]repo.EventType, error) {
+	events, err := n.Events()
+	if err != nil {
+		return nil, err
+	}
+	types := make([]repo.EventType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types, nil
+}
+
+// Network is a set of simulated qri nodes & the links between them
+type Network struct {
+	ctx   context.Context
+	lock  sync.Mutex
+	seed  int64
+	rands map[[2]string]*rand.Rand
+	nodes []*Node
+	byID  map[peer.ID]*Node
+	links map[[2]string]Link
+}
+
+// NewNetwork creates a network of named nodes, each with an empty repo. Nodes
+// are online but not connected to each other, call Connect to link them up.
+// seed makes message loss reproducible between runs
+func NewNetwork(ctx context.Context, seed int64, names ...string) (*Network, error) {
+	net := &Network{
+		ctx:   ctx,
+		seed:  seed,
+		rands: map[[2]string]*rand.Rand{},
+		byID:  map[peer.ID]*Node{},
+		links: map[[2]string]Link{},
+	}
+
+	for i, name := range names {
+		if net.Node(name) != nil {
+			return nil, fmt.Errorf("duplicate node name: %s", name)
+		}
+
+		info := cfgtest.GetTestPeerInfo(i)
+		r, err := test.NewTestRepoFromProfileID(profile.ID(info.PeerID), i, -1)
+		if err != nil {
+			return nil, fmt.Errorf("error creating repo for node %s: %s", name, err.Error())
+		}
+		pro, err := r.Profile()
+		if err != nil {
+			return nil, err
+		}
+		pro.Peername = name
+		if err := r.SetProfile(pro); err != nil {
+			return nil, err
+		}
+
+		addr, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
+		p2pconf := config.DefaultP2P()
+		p2pconf.Addrs = []ma.Multiaddr{addr}
+		p2pconf.QriBootstrapAddrs = []string{}
+		p2pconf.PeerID = info.EncodedPeerID
+		p2pconf.PrivKey = info.EncodedPrivKey
+
+		node := &Node{Name: name, Repo: r, p2pconf: p2pconf}
+		if err := net.start(node); err != nil {
+			return nil, err
+		}
+		net.nodes = append(net.nodes, node)
+		net.byID[info.PeerID] = node
+	}
+
+	return net, nil
+}
+
+// Nodes lists all nodes in the network, including crashed ones
+func (net *Network) Nodes() []*Node {
+	return net.nodes
+}
+
+// Node gets a node by name, returning nil if no such node exists
+func (net *Network) Node(name string) *Node {
+	for _, n := range net.nodes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// Connect establishes qri connections between all running nodes
+func (net *Network) Connect() error {
+	for i, a := range net.nodes {
+		for _, b := range net.nodes[i+1:] {
+			if err := net.connect(a, b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetLink sets network conditions between nodes a & b
+func (net *Network) SetLink(a, b string, l Link) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	net.links[linkKey(a, b)] = l
+}
+
+// Link gets network conditions between nodes a & b
+func (net *Network) Link(a, b string) Link {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	return net.links[linkKey(a, b)]
+}
+
+// Partition splits the network into groups of named nodes. Nodes can only
+// exchange messages with other nodes in the same group. Nodes that aren't
+// listed in any group are isolated from everyone
+func (net *Network) Partition(groups ...[]string) {
+	group := map[string]int{}
+	for i, g := range groups {
+		for _, name := range g {
+			group[name] = i
+		}
+	}
+
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	for i, a := range net.nodes {
+		for _, b := range net.nodes[i+1:] {
+			ga, okA := group[a.Name]
+			gb, okB := group[b.Name]
+			key := linkKey(a.Name, b.Name)
+			l := net.links[key]
+			l.Partitioned = !okA || !okB || ga != gb
+			net.links[key] = l
+		}
+	}
+}
+
+// Heal removes all partitions, re-establishing connections between running
+// nodes. Latency & loss settings are left as-is
+func (net *Network) Heal() error {
+	net.lock.Lock()
+	for key, l := range net.links {
+		l.Partitioned = false
+		net.links[key] = l
+	}
+	net.lock.Unlock()
+
+	return net.Connect()
+}
+
+// Crash abruptly stops a node, as if it's process died: open streams are
+// reset & the host is closed without the node shutting down cleanly. The
+// node's repo is left intact, and can be brought back with Restart
+func (net *Network) Crash(name string) error {
+	n := net.Node(name)
+	if n == nil {
+		return fmt.Errorf("unknown node: %s", name)
+	}
+	if n.down {
+		return nil
+	}
+
+	net.lock.Lock()
+	n.down = true
+	net.lock.Unlock()
+
+	h := n.Host()
+	for _, c := range h.Network().Conns() {
+		for _, s := range c.GetStreams() {
+			s.Reset()
+		}
+	}
+	err := h.Close()
+	n.cancel()
+	return err
+}
+
+// Restart brings a crashed node back online with the same identity & repo,
+// reconnecting it to all running nodes
+func (net *Network) Restart(name string) error {
+	n := net.Node(name)
+	if n == nil {
+		return fmt.Errorf("unknown node: %s", name)
+	}
+	if !n.down {
+		return nil
+	}
+
+	if err := net.start(n); err != nil {
+		return err
+	}
+	net.lock.Lock()
+	n.down = false
+	net.lock.Unlock()
+
+	for _, other := range net.nodes {
+		if other != n {
+			if err := net.connect(n, other); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close cleanly shuts down all running nodes
+func (net *Network) Close() (err error) {
+	for _, n := range net.nodes {
+		if n.down {
+			continue
+		}
+		net.lock.Lock()
+		n.down = true
+		net.lock.Unlock()
+
+		if e := n.QriNode.Close(); e != nil {
+			err = e
+		}
+		n.cancel()
+	}
+	return err
+}
+
+// start creates & starts a fresh qri node for n
+func (net *Network) start(n *Node) error {
+	node, err := p2p.NewQriNode(n.Repo, n.p2pconf)
+	if err != nil {
+		return fmt.Errorf("error creating node %s: %s", n.Name, err.Error())
+	}
+	node.SendFilter = net.sendFilter(n)
+	ctx, cancel := context.WithCancel(net.ctx)
+	if err := node.Start(ctx); err != nil {
+		cancel()
+		return fmt.Errorf("error starting node %s: %s", n.Name, err.Error())
+	}
+	n.cancel = cancel
+
+	info := cfgtest.GetTestPeerInfo(net.index(n))
+	node.Host().Peerstore().AddPubKey(info.PeerID, info.PubKey)
+	node.Host().Peerstore().AddPrivKey(info.PeerID, info.PrivKey)
+
+	n.QriNode = node
+	return nil
+}
+
+// connect establishes a qri connection between a & b if both are running and
+// the link between them isn't partitioned
+func (net *Network) connect(a, b *Node) error {
+	if a.down || b.down || net.Link(a.Name, b.Name).Partitioned {
+		return nil
+	}
+
+	if err := a.Host().Connect(net.ctx, b.SimplePeerInfo()); err != nil {
+		return fmt.Errorf("error connecting %s to %s: %s", a.Name, b.Name, err.Error())
+	}
+	if err := a.UpgradeToQriConnection(b.SimplePeerInfo()); err != nil {
+		return fmt.Errorf("error upgrading %s connection to %s: %s", a.Name, b.Name, err.Error())
+	}
+	if err := b.UpgradeToQriConnection(a.SimplePeerInfo()); err != nil {
+		return fmt.Errorf("error upgrading %s connection to %s: %s", b.Name, a.Name, err.Error())
+	}
+	return nil
+}
+
+// sendFilter applies link conditions to messages sent by node from
+func (net *Network) sendFilter(from *Node) p2p.SendFilter {
+	return func(pid peer.ID, msg p2p.Message) error {
+		net.lock.Lock()
+		to, ok := net.byID[pid]
+		if !ok {
+			net.lock.Unlock()
+			return nil
+		}
+		if to.down {
+			net.lock.Unlock()
+			return ErrNodeDown
+		}
+		l := net.links[linkKey(from.Name, to.Name)]
+		lost := l.Loss > 0 && net.linkRand(from.Name, to.Name).Float64() < l.Loss
+		net.lock.Unlock()
+
+		if l.Partitioned {
+			return ErrPartitioned
+		}
+		if l.Latency > 0 {
+			select {
+			case <-time.After(l.Latency):
+			case <-net.ctx.Done():
+				return net.ctx.Err()
+			}
+		}
+		if lost {
+			return ErrMessageLost
+		}
+		return nil
+	}
+}
+
+// linkRand gets the random source for messages sent from node a to node b.
+// net.lock must be held
+func (net *Network) linkRand(a, b string) *rand.Rand {
+	key := [2]string{a, b}
+	r, ok := net.rands[key]
+	if !ok {
+		h := fnv.New64a()
+		h.Write([]byte(a + "\x00" + b))
+		r = rand.New(rand.NewSource(net.seed ^ int64(h.Sum64())))
+		net.rands[key] = r
+	}
+	return r
+}
+
+func (net *Network) index(n *Node) int {
+	for i, node := range net.nodes {
+		if node == n {
+			return i
+		}
+	}
+	// nodes are started before they're added to the list
+	return len(net.nodes)
+}
+
+// linkKey creates an order-independant key for a pair of node names
+func linkKey(a, b string) [2]string {
+	names := []string{a, b}
+	sort.Strings(names)
+	return [2]string{names[0], names[1]}
+}
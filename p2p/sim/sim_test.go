@@ -0,0 +1,231 @@
+package p2psim
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/qri/actions"
+	"github.com/qri-io/qri/lib"
+	"github.com/qri-io/qri/repo"
+	"github.com/qri-io/qri/rev"
+)
+
+func newTestNetwork(t *testing.T, names ...string) (*Network, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	net, err := NewNetwork(ctx, 1, names...)
+	if err != nil {
+		cancel()
+		t.Fatalf("error creating network: %s", err.Error())
+	}
+	if err := net.Connect(); err != nil {
+		cancel()
+		t.Fatalf("error connecting network: %s", err.Error())
+	}
+	return net, func() {
+		if err := net.Close(); err != nil {
+			t.Errorf("error closing network: %s", err.Error())
+		}
+		cancel()
+	}
+}
+
+func saveDataset(t *testing.T, n *Node, name, body string) repo.DatasetRef {
+	pro, err := n.Repo.Profile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsp := &dataset.DatasetPod{
+		Peername:  pro.Peername,
+		Name:      name,
+		Meta:      &dataset.Meta{Title: "cities"},
+		Structure: &dataset.StructurePod{Format: dataset.JSONDataFormat.String(), Schema: map[string]interface{}{"type": "array"}},
+		BodyBytes: []byte(body),
+	}
+	ref, _, err := actions.SaveDataset(context.Background(), n.QriNode, dsp, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("%s error saving dataset: %s", n.Name, err.Error())
+	}
+	return ref
+}
+
+// syncHead fetches the history of ref from peers, storing the latest version
+// in n's refstore
+func syncHead(n *Node, ref repo.DatasetRef) ([]repo.DatasetRef, error) {
+	history, err := n.RequestDatasetLog(repo.DatasetRef{ProfileID: ref.ProfileID, Peername: ref.Peername, Name: ref.Name}, 100, 0)
+	if err != nil {
+		return nil, err
+	}
+	head := history[0]
+	head.Dataset = nil
+	return history, n.Repo.PutRef(head)
+}
+
+func TestSaveSyncRemove(t *testing.T) {
+	net, done := newTestNetwork(t, "a", "b", "c")
+	defer done()
+	a, b := net.Node("a"), net.Node("b")
+
+	v1 := saveDataset(t, a, "cities", `[["toronto",40000000],["new york",8500000]]`)
+	v2 := saveDataset(t, a, "cities", `[["toronto",40000000],["new york",8500000],["chicago",300000]]`)
+
+	history, err := syncHead(b, v2)
+	if err != nil {
+		t.Fatalf("b error syncing: %s", err.Error())
+	}
+	if len(history) != 2 {
+		t.Errorf("expected b to see 2 versions, got: %d", len(history))
+	}
+
+	// a removes the latest revision
+	removed := 0
+	p := &lib.RemoveParams{Ref: &repo.DatasetRef{Peername: "a", Name: "cities"}, Revision: rev.Rev{Field: "ds", Gen: 1}}
+	if err := lib.NewDatasetRequests(a.QriNode, nil).Remove(p, &removed); err != nil {
+		t.Fatalf("a error removing revision: %s", err.Error())
+	}
+
+	if history, err = syncHead(b, v2); err != nil {
+		t.Fatalf("b error re-syncing: %s", err.Error())
+	}
+	if len(history) != 1 {
+		t.Errorf("expected b to see 1 version after removal, got: %d", len(history))
+	}
+
+	refs, err := b.Refs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 {
+		t.Fatalf("expected b to have 1 reference, got: %d", len(refs))
+	}
+	if refs[0].Path != v1.Path {
+		t.Errorf("expected b's reference to point to first version. expected: %s, got: %s", v1.Path, refs[0].Path)
+	}
+
+	types, err := a.EventTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := 0
+	for _, et := range types {
+		if et == repo.ETDsCreated {
+			created++
+		}
+	}
+	if created != 2 {
+		t.Errorf("expected a to log 2 %s events, got: %d", repo.ETDsCreated, created)
+	}
+	if len(types) == 0 || types[len(types)-1] != repo.ETDsRenamed {
+		t.Errorf("expected a's last event to be %s, got: %v", repo.ETDsRenamed, types)
+	}
+}
+
+func TestPartitionHeal(t *testing.T) {
+	net, done := newTestNetwork(t, "a", "b", "c")
+	defer done()
+	a, b, c := net.Node("a"), net.Node("b"), net.Node("c")
+
+	ref := saveDataset(t, a, "cities", `[["toronto",40000000]]`)
+
+	net.Partition([]string{"a"}, []string{"b", "c"})
+	if _, err := syncHead(b, ref); err == nil {
+		t.Error("expected partitioned sync to fail")
+	}
+	if err := net.Heal(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncHead(b, ref); err != nil {
+		t.Errorf("expected sync after heal to succeed, got: %s", err.Error())
+	}
+
+	// isolating a leaves c with only b to ask, which holds a reference but no data
+	net.Partition([]string{"b", "c"})
+	if _, err := syncHead(c, ref); err == nil {
+		t.Error("expected sync to fail while a is isolated")
+	}
+}
+
+func TestCrashRestart(t *testing.T) {
+	net, done := newTestNetwork(t, "a", "b")
+	defer done()
+	a, b := net.Node("a"), net.Node("b")
+
+	ref := saveDataset(t, a, "cities", `[["toronto",40000000]]`)
+
+	if err := net.Crash("a"); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Down() {
+		t.Error("expected crashed node to be down")
+	}
+	if _, err := syncHead(b, ref); err == nil {
+		t.Error("expected sync from crashed node to fail")
+	}
+
+	if err := net.Restart("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncHead(b, ref); err != nil {
+		t.Fatalf("expected sync from restarted node to succeed, got: %s", err.Error())
+	}
+
+	// repo should survive the restart
+	refs, err := a.Refs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0].Path != ref.Path {
+		t.Errorf("expected a's refstore to be intact after restart, got: %v", refs)
+	}
+}
+
+func TestLinkConditions(t *testing.T) {
+	net, done := newTestNetwork(t, "a", "b")
+	defer done()
+	a, b := net.Node("a"), net.Node("b")
+
+	ref := saveDataset(t, a, "cities", `[["toronto",40000000]]`)
+
+	latency := time.Millisecond * 200
+	net.SetLink("a", "b", Link{Latency: latency})
+	start := time.Now()
+	if _, err := syncHead(b, ref); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("expected sync to take at least %s, took: %s", latency, elapsed)
+	}
+
+	net.SetLink("b", "a", Link{Loss: 1})
+	if _, err := syncHead(b, ref); err == nil {
+		t.Error("expected sync over a fully lossy link to fail")
+	}
+
+	net.SetLink("a", "b", Link{})
+	if _, err := syncHead(b, ref); err != nil {
+		t.Errorf("expected sync over a clean link to succeed, got: %s", err.Error())
+	}
+}
+
+func TestLinkRandDeterministic(t *testing.T) {
+	draw := func(interleave bool) []float64 {
+		net := &Network{seed: 1, rands: map[[2]string]*rand.Rand{}}
+		got := []float64{}
+		for i := 0; i < 5; i++ {
+			if interleave {
+				net.linkRand("b", "a").Float64()
+			}
+			got = append(got, net.linkRand("a", "b").Float64())
+		}
+		return got
+	}
+
+	a, b := draw(false), draw(true)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected draws on a link to be independent of other links. draw %d: %f != %f", i, a[i], b[i])
+		}
+	}
+}
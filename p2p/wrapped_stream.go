@@ -4,7 +4,6 @@ import (
 	"bufio"
 
 	multicodec "gx/ipfs/QmU4qokxecGJBZPGmc4D9g2HdTyo8CPqUoZ2gwXKsQxqc9/go-multicodec"
-	json "gx/ipfs/QmU4qokxecGJBZPGmc4D9g2HdTyo8CPqUoZ2gwXKsQxqc9/go-multicodec/json"
 	net "gx/ipfs/QmXuRkCR7BNQa9uqfpTiFWsTQLzmTWYg91Ja1w95gnqb6u/go-libp2p-net"
 )
 
@@ -31,11 +30,9 @@ type WrappedStream struct {
 func WrapStream(s net.Stream) *WrappedStream {
 	reader := bufio.NewReader(s)
 	writer := bufio.NewWriter(s)
-	// This is where we pick our specific multicodec. In order to change the
-	// codec, we only need to change this place.
-	// See https://godoc.org/github.com/multiformats/go-multicodec/json
-	dec := json.Multicodec(false).Decoder(reader)
-	enc := json.Multicodec(false).Encoder(writer)
+	// This is where we pick our specific multicodec. The codec is chosen by
+	// the protocol negotiated when the stream was opened, see codec.go
+	enc, dec := codecForProtocol(s.Protocol(), reader, writer)
 	return &WrappedStream{
 		stream: s,
 		r:      reader,
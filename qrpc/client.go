@@ -0,0 +1,269 @@
+package qrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// Client calls methods on a qrpc server. A client is safe for concurrent use
+type Client struct {
+	conn io.ReadWriteCloser
+
+	// ServerVersion is the qri version of the server
+	ServerVersion string
+
+	wlock sync.Mutex
+	enc   *json.Encoder
+
+	lock    sync.Mutex
+	seq     uint64
+	pending map[uint64]*call
+	closed  bool
+}
+
+// call is the state of a single in-flight call
+type call struct {
+	writers map[string]io.Writer
+	// carrier is set when the reply can take a body
+	carrier bool
+	done    chan frame
+
+	// body is the write end of the attached body, set when a result that
+	// carries a body arrives. bodyDone is closed once the body ends
+	body     *io.PipeWriter
+	bodyr    *io.PipeReader
+	bodyDone chan struct{}
+	bodyOnce sync.Once
+}
+
+// endBody closes the body with err, io.EOF if err is nil
+func (cl *call) endBody(err error) {
+	cl.bodyOnce.Do(func() {
+		if cl.body != nil {
+			cl.body.CloseWithError(err)
+		}
+		close(cl.bodyDone)
+	})
+}
+
+// Dial connects to a server, checking it runs the same version as the client
+func Dial(network, address, version string) (*Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, version)
+}
+
+// NewClient creates a client from an open connection, performing the version
+// handshake. conn is closed if the handshake fails
+func NewClient(conn io.ReadWriteCloser, version string) (*Client, error) {
+	c := &Client{
+		conn:    conn,
+		enc:     json.NewEncoder(conn),
+		pending: map[uint64]*call{},
+	}
+	dec := json.NewDecoder(bufio.NewReader(conn))
+
+	hello := frame{Type: ftHello, Protocol: ProtocolVersion, Version: version}
+	if err := c.send(hello); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reply := frame{}
+	if err := dec.Decode(&reply); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("qrpc: error reading handshake: %s", err.Error())
+	}
+	if reply.Type != ftHello {
+		conn.Close()
+		return nil, fmt.Errorf("qrpc: expected hello, got: %s", reply.Type)
+	}
+	if err := checkVersion(hello, reply); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if reply.Error != "" {
+		conn.Close()
+		return nil, ServerError(reply.Error)
+	}
+
+	c.ServerVersion = reply.Version
+	go c.read(dec)
+	return c, nil
+}
+
+// Call invokes the named method, waiting for it to complete
+func (c *Client) Call(method string, params, reply interface{}) error {
+	return c.CallContext(context.Background(), method, params, reply)
+}
+
+// CallContext invokes the named method, waiting for it to complete or for
+// ctx to be done. Writes to io.Writer fields of params on the server are
+// streamed to the writers set in params. If reply implements BodyCarrier, any
+// body sent by the server is attached to it as a reader of the body stream,
+// which is read as it arrives. Cancelling ctx stops the server streaming,
+// including any attached body, & cancels any context.Context fields of params
+// on the server
+func (c *Client) CallContext(ctx context.Context, method string, params, reply interface{}) error {
+	writers, restore := detachWriters(params)
+	data, err := json.Marshal(params)
+	restore()
+	if err != nil {
+		return fmt.Errorf("qrpc: error encoding params for %s: %s", method, err.Error())
+	}
+
+	_, carrier := reply.(BodyCarrier)
+	cl := &call{writers: writers, carrier: carrier, done: make(chan frame, 1), bodyDone: make(chan struct{})}
+
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return ErrShutdown
+	}
+	c.seq++
+	id := c.seq
+	c.pending[id] = cl
+	c.lock.Unlock()
+
+	f := frame{Type: ftCall, ID: id, Method: method, Params: data}
+	for name := range writers {
+		f.Streams = append(f.Streams, name)
+	}
+	if err := c.send(f); err != nil {
+		c.remove(id)
+		return err
+	}
+
+	select {
+	case res := <-cl.done:
+		if res.Error != "" {
+			return ServerError(res.Error)
+		}
+		if reply != nil && len(res.Result) > 0 {
+			if err := json.Unmarshal(res.Result, reply); err != nil {
+				err = fmt.Errorf("qrpc: error decoding result of %s: %s", method, err.Error())
+				c.stop(id, cl, err)
+				return err
+			}
+		}
+		if cl.bodyr != nil {
+			reply.(BodyCarrier).AttachBody(cl.bodyr)
+			if ctx.Done() != nil {
+				go func() {
+					select {
+					case <-ctx.Done():
+						c.stop(id, cl, ctx.Err())
+					case <-cl.bodyDone:
+					}
+				}()
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		if c.remove(id) {
+			c.send(frame{Type: ftCancel, ID: id})
+		}
+		return ctx.Err()
+	}
+}
+
+// Close hangs up the connection. Pending calls return ErrShutdown
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) send(f frame) error {
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+	return c.enc.Encode(f)
+}
+
+// stop abandons call id, telling the server to stop streaming to it & ending
+// it's body with err
+func (c *Client) stop(id uint64, cl *call, err error) {
+	if c.remove(id) {
+		c.send(frame{Type: ftCancel, ID: id})
+	}
+	cl.endBody(err)
+}
+
+// remove drops a pending call, returning false if it wasn't pending
+func (c *Client) remove(id uint64) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, ok := c.pending[id]
+	delete(c.pending, id)
+	return ok
+}
+
+// read dispatches frames from the server to pending calls until the
+// connection closes
+func (c *Client) read(dec *json.Decoder) {
+	for {
+		f := frame{}
+		if err := dec.Decode(&f); err != nil {
+			break
+		}
+
+		c.lock.Lock()
+		cl, ok := c.pending[f.ID]
+		if ok && (f.Type == ftResult && !f.Body || f.Type == ftEnd) {
+			delete(c.pending, f.ID)
+		}
+		if ok && f.Type == ftResult && f.Body && cl.carrier {
+			cl.bodyr, cl.body = io.Pipe()
+		}
+		c.lock.Unlock()
+		if !ok {
+			// call was cancelled
+			continue
+		}
+
+		switch f.Type {
+		case ftStream:
+			if f.Stream == StreamBody {
+				if cl.body == nil {
+					continue
+				}
+				// blocks until the body is read, a closed body stops the stream
+				if _, err := cl.body.Write(f.Data); err != nil {
+					c.stop(f.ID, cl, err)
+				}
+			} else if w, ok := cl.writers[f.Stream]; ok {
+				if _, err := w.Write(f.Data); err != nil {
+					log.Debugf("qrpc: error writing %s stream: %s", f.Stream, err.Error())
+				}
+			}
+		case ftResult:
+			cl.done <- f
+			if !f.Body {
+				cl.endBody(nil)
+			}
+		case ftEnd:
+			var err error
+			if f.Error != "" {
+				err = ServerError(f.Error)
+			}
+			cl.endBody(err)
+		}
+	}
+
+	c.lock.Lock()
+	c.closed = true
+	for id, cl := range c.pending {
+		select {
+		case cl.done <- frame{Type: ftResult, ID: id, Error: ErrShutdown.Error()}:
+		default:
+			// result was delivered, the body is still streaming
+		}
+		cl.endBody(ErrShutdown)
+		delete(c.pending, id)
+	}
+	c.lock.Unlock()
+}
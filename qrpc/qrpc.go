@@ -0,0 +1,119 @@
+// Package qrpc is the transport qri uses to call lib methods on a running
+// `qri connect` process. It replaces net/rpc with a JSON protocol that can
+// stream script output & dataset bodies back to the caller, cancel calls, and
+// refuses to connect clients & servers running different versions of qri.
+//
+// A connection carries newline-delimited JSON frames. Both sides start by
+// exchanging a "hello" frame that carries the protocol & qri versions, after
+// which the client sends "call" & "cancel" frames, and the server responds
+// with any number of "stream" frames followed by a single "result" frame for
+// each call. Results that carry a body are followed by the body's "stream"
+// frames & an "end" frame, so clients can read bodies as they arrive. Many
+// calls can be in flight on one connection at once.
+package qrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ProtocolVersion is the version of the wire format. Clients & servers only
+// talk if their protocol versions match
+const ProtocolVersion = 2
+
+// StreamBody is the name of the stream used to send body readers
+const StreamBody = "body"
+
+// ErrShutdown is returned by calls on a closed client
+var ErrShutdown = fmt.Errorf("qrpc: connection is shut down")
+
+// ErrCancelled is returned by stream writers once the client has cancelled
+// the call they belong to
+var ErrCancelled = fmt.Errorf("qrpc: call cancelled")
+
+// ServerError is an error returned by a remote method
+type ServerError string
+
+// Error implements the error interface
+func (e ServerError) Error() string {
+	return string(e)
+}
+
+// VersionError is returned when a client connects to a server running a
+// different version
+type VersionError struct {
+	ClientProtocol, ServerProtocol int
+	ClientVersion, ServerVersion   string
+}
+
+// Error implements the error interface
+func (e VersionError) Error() string {
+	if e.ClientProtocol != e.ServerProtocol {
+		return fmt.Sprintf("qrpc: protocol mismatch. client speaks version %d, server speaks version %d", e.ClientProtocol, e.ServerProtocol)
+	}
+	return fmt.Sprintf("qri version mismatch: this is qri %s, but qri connect is running qri %s. restart qri connect with the same version", e.ClientVersion, e.ServerVersion)
+}
+
+// BodyCarrier is implemented by results that can hold a body reader. Readers
+// can't be encoded, so servers detach bodies from results & stream them to
+// the client after the result, which attaches a reader of the stream to the
+// decoded result. Attached bodies must be read to EOF or closed: other calls
+// on the connection wait while received body data is unread
+type BodyCarrier interface {
+	// DetachBody removes & returns the body reader, if any
+	DetachBody() io.Reader
+	// AttachBody sets the body to a reader of the body stream. r is an
+	// io.ReadCloser, closing it before EOF cancels the rest of the stream
+	AttachBody(r io.Reader)
+}
+
+type frameType string
+
+const (
+	ftHello  = frameType("hello")
+	ftCall   = frameType("call")
+	ftCancel = frameType("cancel")
+	ftStream = frameType("stream")
+	ftResult = frameType("result")
+	ftEnd    = frameType("end")
+)
+
+// frame is a single message on the wire
+type frame struct {
+	Type frameType `json:"type"`
+	ID   uint64    `json:"id,omitempty"`
+
+	// hello fields
+	Protocol int    `json:"protocol,omitempty"`
+	Version  string `json:"version,omitempty"`
+
+	// call fields
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	// Streams names the writer fields of params the client is listening to
+	Streams []string `json:"streams,omitempty"`
+
+	// stream fields
+	Stream string `json:"stream,omitempty"`
+	Data   []byte `json:"data,omitempty"`
+
+	// result fields
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	// Body is set when the result's body follows as a stream
+	Body bool `json:"body,omitempty"`
+}
+
+// checkVersion compares the hello frames of both sides of a connection
+func checkVersion(client, server frame) error {
+	if client.Protocol != server.Protocol || client.Version != server.Version {
+		return VersionError{
+			ClientProtocol: client.Protocol,
+			ServerProtocol: server.Protocol,
+			ClientVersion:  client.Version,
+			ServerVersion:  server.Version,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package qrpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type EchoParams struct {
+	Msg    string
+	Output io.Writer
+	Ctx    context.Context `json:"-"`
+}
+
+type BodyResult struct {
+	Name string
+	Body io.Reader `json:"-"`
+}
+
+func (r *BodyResult) DetachBody() io.Reader {
+	body := r.Body
+	r.Body = nil
+	return body
+}
+
+func (r *BodyResult) AttachBody(body io.Reader) {
+	r.Body = body
+}
+
+type Receiver struct {
+	block chan struct{}
+	// ctxDone is closed when Block's context is cancelled
+	ctxDone chan struct{}
+}
+
+func (r *Receiver) Echo(p *EchoParams, res *string) error {
+	if p.Output != nil {
+		fmt.Fprintf(p.Output, "echoing %s", p.Msg)
+	}
+	*res = p.Msg
+	return nil
+}
+
+func (r *Receiver) Fail(p *EchoParams, res *string) error {
+	return fmt.Errorf("failed: %s", p.Msg)
+}
+
+func (r *Receiver) Body(p *EchoParams, res *BodyResult) error {
+	*res = BodyResult{Name: p.Msg, Body: strings.NewReader(strings.Repeat(p.Msg, bodyChunkSize))}
+	return nil
+}
+
+func (r *Receiver) Block(p *EchoParams, res *string) error {
+	select {
+	case <-r.block:
+	case <-p.Ctx.Done():
+		close(r.ctxDone)
+	}
+	return nil
+}
+
+// Unsuitable shouldn't be registered
+func (r *Receiver) Unsuitable(p *EchoParams) error {
+	return nil
+}
+
+func newTestConn(t *testing.T, clientVersion, serverVersion string) (*Client, *Receiver, error) {
+	srv := NewServer(serverVersion)
+	rcvr := &Receiver{block: make(chan struct{}), ctxDone: make(chan struct{})}
+	if err := srv.Register(rcvr); err != nil {
+		t.Fatal(err)
+	}
+	sconn, cconn := net.Pipe()
+	go srv.ServeConn(sconn)
+	cli, err := NewClient(cconn, clientVersion)
+	return cli, rcvr, err
+}
+
+func TestRegister(t *testing.T) {
+	srv := NewServer("0.0.1")
+	if err := srv.Register(&Receiver{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Receiver.Echo", "Receiver.Fail", "Receiver.Body", "Receiver.Block"} {
+		if _, ok := srv.methods[name]; !ok {
+			t.Errorf("expected method %s to be registered", name)
+		}
+	}
+	if _, ok := srv.methods["Receiver.Unsuitable"]; ok {
+		t.Error("expected method with unsuitable signature to be skipped")
+	}
+
+	if err := srv.Register(&struct{}{}); err == nil {
+		t.Error("expected registering an unnamed type to error")
+	}
+}
+
+func TestCall(t *testing.T) {
+	cli, _, err := newTestConn(t, "0.0.1", "0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	if cli.ServerVersion != "0.0.1" {
+		t.Errorf("server version mismatch. expected: %s, got: %s", "0.0.1", cli.ServerVersion)
+	}
+
+	out := &bytes.Buffer{}
+	p := &EchoParams{Msg: "hello", Output: out}
+	res := ""
+	if err := cli.Call("Receiver.Echo", p, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res != "hello" {
+		t.Errorf("result mismatch. expected: %s, got: %s", "hello", res)
+	}
+	if out.String() != "echoing hello" {
+		t.Errorf("streamed output mismatch. expected: %s, got: %s", "echoing hello", out.String())
+	}
+	if p.Output != out {
+		t.Error("expected params writer to be restored after call")
+	}
+
+	if err := cli.Call("Receiver.Echo", &EchoParams{Msg: "no output"}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res != "no output" {
+		t.Errorf("result mismatch. expected: %s, got: %s", "no output", res)
+	}
+
+	err = cli.Call("Receiver.Fail", &EchoParams{Msg: "oh noes"}, &res)
+	if _, ok := err.(ServerError); !ok || err.Error() != "failed: oh noes" {
+		t.Errorf("expected server error 'failed: oh noes', got: %v", err)
+	}
+
+	if err := cli.Call("Receiver.Missing", &EchoParams{}, &res); err == nil {
+		t.Error("expected calling a missing method to error")
+	}
+}
+
+func TestCallBody(t *testing.T) {
+	cli, _, err := newTestConn(t, "0.0.1", "0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	res := &BodyResult{}
+	if err := cli.Call("Receiver.Body", &EchoParams{Msg: "ab"}, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Name != "ab" {
+		t.Errorf("name mismatch. expected: %s, got: %s", "ab", res.Name)
+	}
+	if res.Body == nil {
+		t.Fatal("expected body to be attached")
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := strings.Repeat("ab", bodyChunkSize); string(data) != expect {
+		t.Errorf("body mismatch. expected %d bytes, got %d", len(expect), len(data))
+	}
+
+	// closing a body before it's read stops the stream, leaving the
+	// connection usable
+	if err := cli.Call("Receiver.Body", &EchoParams{Msg: "cd"}, res); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(res.Body, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "cdcdcdcd" {
+		t.Errorf("body mismatch. expected: %s, got: %s", "cdcdcdcd", string(buf))
+	}
+	res.Body.(io.Closer).Close()
+
+	echo := ""
+	if err := cli.Call("Receiver.Echo", &EchoParams{Msg: "still here"}, &echo); err != nil {
+		t.Fatal(err)
+	}
+	if echo != "still here" {
+		t.Errorf("result mismatch. expected: %s, got: %s", "still here", echo)
+	}
+}
+
+func TestCallCancel(t *testing.T) {
+	cli, rcvr, err := newTestConn(t, "0.0.1", "0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	res := ""
+	if err := cli.CallContext(ctx, "Receiver.Block", &EchoParams{}, &res); err != context.DeadlineExceeded {
+		t.Errorf("expected cancelled call to return %s, got: %v", context.DeadlineExceeded, err)
+	}
+	select {
+	case <-rcvr.ctxDone:
+	case <-time.After(time.Second):
+		t.Error("expected cancelling a call to cancel the method's context")
+		close(rcvr.block)
+	}
+
+	// connection should still be usable after a cancel
+	if err := cli.Call("Receiver.Echo", &EchoParams{Msg: "still here"}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res != "still here" {
+		t.Errorf("result mismatch. expected: %s, got: %s", "still here", res)
+	}
+}
+
+func TestVersionMismatch(t *testing.T) {
+	_, _, err := newTestConn(t, "0.0.1", "0.0.2")
+	verr, ok := err.(VersionError)
+	if !ok {
+		t.Fatalf("expected version error, got: %v", err)
+	}
+	if verr.ClientVersion != "0.0.1" || verr.ServerVersion != "0.0.2" {
+		t.Errorf("unexpected versions in error: %#v", verr)
+	}
+}
+
+func TestClose(t *testing.T) {
+	cli, _, err := newTestConn(t, "0.0.1", "0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.Close()
+
+	res := ""
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if err = cli.Call("Receiver.Echo", &EchoParams{}, &res); err == ErrShutdown {
+			return
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	t.Errorf("expected call on closed client to return %s, got: %v", ErrShutdown, err)
+}
@@ -0,0 +1,299 @@
+package qrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	golog "github.com/ipfs/go-log"
+)
+
+var log = golog.Logger("qrpc")
+
+// bodyChunkSize is the largest amount of body data sent in a single frame
+const bodyChunkSize = 32 * 1024
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Server dispatches calls to registered receivers
+type Server struct {
+	version string
+	methods map[string]*method
+}
+
+// method is a registered method of the form:
+//
+//	func (t *T) Method(params P, res *R) error
+type method struct {
+	rcvr      reflect.Value
+	fn        reflect.Value
+	argType   reflect.Type
+	replyType reflect.Type
+}
+
+// NewServer creates a server that accepts clients running version
+func NewServer(version string) *Server {
+	return &Server{
+		version: version,
+		methods: map[string]*method{},
+	}
+}
+
+// Register adds the exported methods of rcvr that have the form:
+//
+//	func (t *T) Method(params P, res *R) error
+//
+// methods are called by "T.Method". Methods of any other form are skipped
+func (s *Server) Register(rcvr interface{}) error {
+	rv := reflect.ValueOf(rcvr)
+	typ := rv.Type()
+	name := reflect.Indirect(rv).Type().Name()
+	if name == "" {
+		return fmt.Errorf("qrpc: can't register unnamed type %s", typ)
+	}
+
+	registered := 0
+	for i := 0; i < typ.NumMethod(); i++ {
+		m := typ.Method(i)
+		mt := m.Type
+		if m.PkgPath != "" || mt.NumIn() != 3 || mt.NumOut() != 1 || mt.Out(0) != errorType || mt.In(2).Kind() != reflect.Ptr {
+			continue
+		}
+		s.methods[name+"."+m.Name] = &method{
+			rcvr:      rv,
+			fn:        m.Func,
+			argType:   mt.In(1),
+			replyType: mt.In(2).Elem(),
+		}
+		registered++
+	}
+
+	if registered == 0 {
+		return fmt.Errorf("qrpc: type %s has no exported methods of suitable type", name)
+	}
+	return nil
+}
+
+// ServeConn serves a single connection, blocking until the client hangs up.
+// Calls in flight when the client hangs up stop streaming, ServeConn waits
+// for them to return before closing conn
+func (s *Server) ServeConn(conn io.ReadWriteCloser) error {
+	defer conn.Close()
+
+	sc := &serverConn{
+		srv:   s,
+		enc:   json.NewEncoder(conn),
+		calls: map[uint64]chan struct{}{},
+	}
+	dec := json.NewDecoder(bufio.NewReader(conn))
+
+	hello := frame{}
+	if err := dec.Decode(&hello); err != nil {
+		return err
+	}
+	if hello.Type != ftHello {
+		return fmt.Errorf("qrpc: expected hello, got: %s", hello.Type)
+	}
+	reply := frame{Type: ftHello, Protocol: ProtocolVersion, Version: s.version}
+	if err := checkVersion(hello, reply); err != nil {
+		reply.Error = err.Error()
+		sc.send(reply)
+		return err
+	}
+	if err := sc.send(reply); err != nil {
+		return err
+	}
+
+	var err error
+	for {
+		f := frame{}
+		if err = dec.Decode(&f); err != nil {
+			break
+		}
+
+		switch f.Type {
+		case ftCall:
+			sc.start(f)
+		case ftCancel:
+			sc.cancel(f.ID)
+		default:
+			log.Debugf("qrpc: ignoring unexpected %s frame", f.Type)
+		}
+	}
+
+	sc.cancelAll()
+	sc.wg.Wait()
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// serverConn is the state of a single connection
+type serverConn struct {
+	srv *Server
+	wg  sync.WaitGroup
+
+	wlock sync.Mutex
+	enc   *json.Encoder
+
+	lock sync.Mutex
+	// calls maps in-flight call IDs to a channel that's closed on cancel
+	calls map[uint64]chan struct{}
+}
+
+func (sc *serverConn) send(f frame) error {
+	sc.wlock.Lock()
+	defer sc.wlock.Unlock()
+	return sc.enc.Encode(f)
+}
+
+func (sc *serverConn) start(f frame) {
+	cancelled := make(chan struct{})
+	sc.lock.Lock()
+	sc.calls[f.ID] = cancelled
+	sc.lock.Unlock()
+
+	sc.wg.Add(1)
+	go func() {
+		defer sc.wg.Done()
+		res, body := sc.call(f, cancelled)
+		if err := sc.send(res); err != nil {
+			log.Debugf("qrpc: error sending result of %s: %s", f.Method, err.Error())
+			if c, ok := body.(io.Closer); ok {
+				c.Close()
+			}
+		} else if body != nil {
+			end := frame{Type: ftEnd, ID: f.ID}
+			if err := sc.sendBody(f.ID, body, cancelled); err != nil {
+				end.Error = fmt.Sprintf("qrpc: error sending body: %s", err.Error())
+			}
+			if err := sc.send(end); err != nil {
+				log.Debugf("qrpc: error ending body of %s: %s", f.Method, err.Error())
+			}
+		}
+
+		sc.lock.Lock()
+		delete(sc.calls, f.ID)
+		sc.lock.Unlock()
+	}()
+}
+
+// sendBody streams body to the client until it's read or the call is
+// cancelled, closing body if it's an io.Closer
+func (sc *serverConn) sendBody(id uint64, body io.Reader, cancelled chan struct{}) error {
+	if c, ok := body.(io.Closer); ok {
+		defer c.Close()
+	}
+	w := &streamWriter{sc: sc, id: id, name: StreamBody, cancelled: cancelled}
+	buf := make([]byte, bodyChunkSize)
+	_, err := io.CopyBuffer(w, body, buf)
+	return err
+}
+
+func (sc *serverConn) cancel(id uint64) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if cancelled, ok := sc.calls[id]; ok {
+		close(cancelled)
+		delete(sc.calls, id)
+	}
+}
+
+func (sc *serverConn) cancelAll() {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	for id, cancelled := range sc.calls {
+		close(cancelled)
+		delete(sc.calls, id)
+	}
+}
+
+// call runs a method, returning it's result frame & any body detached from
+// the result, which is streamed to the client after the result is sent
+func (sc *serverConn) call(f frame, cancelled chan struct{}) (frame, io.Reader) {
+	res := frame{Type: ftResult, ID: f.ID}
+
+	m, ok := sc.srv.methods[f.Method]
+	if !ok {
+		res.Error = fmt.Sprintf("qrpc: can't find method %s", f.Method)
+		return res, nil
+	}
+
+	argv := reflect.New(m.argType)
+	if len(f.Params) > 0 {
+		if err := json.Unmarshal(f.Params, argv.Interface()); err != nil {
+			res.Error = fmt.Sprintf("qrpc: error decoding params for %s: %s", f.Method, err.Error())
+			return res, nil
+		}
+	}
+	if m.argType.Kind() == reflect.Ptr && argv.Elem().IsNil() {
+		argv.Elem().Set(reflect.New(m.argType.Elem()))
+	}
+	attachWriters(argv.Interface(), f.Streams, func(name string) io.Writer {
+		return &streamWriter{sc: sc, id: f.ID, name: name, cancelled: cancelled}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-cancelled:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	attachContext(argv.Interface(), ctx)
+
+	replyv := reflect.New(m.replyType)
+	out := m.fn.Call([]reflect.Value{m.rcvr, argv.Elem(), replyv})
+	if err, _ := out[0].Interface().(error); err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+
+	var body io.Reader
+	if bc, ok := replyv.Interface().(BodyCarrier); ok {
+		body = bc.DetachBody()
+	}
+
+	data, err := json.Marshal(replyv.Interface())
+	if err != nil {
+		if c, ok := body.(io.Closer); ok {
+			c.Close()
+		}
+		res.Error = fmt.Sprintf("qrpc: error encoding result of %s: %s", f.Method, err.Error())
+		return res, nil
+	}
+	res.Result = data
+	res.Body = body != nil
+	return res, body
+}
+
+// streamWriter sends writes as stream frames until it's call is cancelled
+type streamWriter struct {
+	sc        *serverConn
+	id        uint64
+	name      string
+	cancelled chan struct{}
+}
+
+// Write implements the io.Writer interface
+func (w *streamWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.cancelled:
+		return 0, ErrCancelled
+	default:
+	}
+
+	data := make([]byte, len(p))
+	copy(data, p)
+	if err := w.sc.send(frame{Type: ftStream, ID: w.id, Stream: w.name, Data: data}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,87 @@
+package qrpc
+
+import (
+	"context"
+	"io"
+	"reflect"
+)
+
+var (
+	writerType  = reflect.TypeOf((*io.Writer)(nil)).Elem()
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// writerFields finds the settable io.Writer fields of the struct v points
+// to, keyed by field name
+func writerFields(v interface{}) map[string]reflect.Value {
+	return typedFields(v, writerType)
+}
+
+// typedFields finds the settable exported fields of type t of the struct v
+// points to, keyed by field name
+func typedFields(v interface{}, t reflect.Type) map[string]reflect.Value {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := map[string]reflect.Value{}
+	st := rv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if f.PkgPath != "" || f.Type != t {
+			continue
+		}
+		if fv := rv.Field(i); fv.CanSet() {
+			fields[f.Name] = fv
+		}
+	}
+	return fields
+}
+
+// detachWriters removes non-nil writers from params so they aren't encoded,
+// returning the removed writers by field name & a function that puts them
+// back
+func detachWriters(params interface{}) (map[string]io.Writer, func()) {
+	writers := map[string]io.Writer{}
+	fields := writerFields(params)
+	for name, fv := range fields {
+		if fv.IsNil() {
+			continue
+		}
+		writers[name] = fv.Interface().(io.Writer)
+		fv.Set(reflect.Zero(writerType))
+	}
+
+	return writers, func() {
+		for name, w := range writers {
+			fields[name].Set(reflect.ValueOf(w))
+		}
+	}
+}
+
+// attachWriters sets the named writer fields of params, using mk to create
+// each writer
+func attachWriters(params interface{}, names []string, mk func(name string) io.Writer) {
+	fields := writerFields(params)
+	for _, name := range names {
+		if fv, ok := fields[name]; ok {
+			fv.Set(reflect.ValueOf(mk(name)))
+		}
+	}
+}
+
+// attachContext sets the context.Context fields of params to ctx, so methods
+// can stop work when their call is cancelled. context fields aren't encoded,
+// they should be tagged `json:"-"`
+func attachContext(params interface{}, ctx context.Context) {
+	for _, fv := range typedFields(params, contextType) {
+		fv.Set(reflect.ValueOf(ctx))
+	}
+}
@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"sync"
+)
+
+// ComponentStore is an opt-in interface for repos that keep components of
+// dataset versions the dataset package doesn't define, like column stats &
+// data quality rules. Components are stored as encoded bytes to keep the repo
+// package free of base types
+type ComponentStore interface {
+	// PutComponent stores an encoded component of a dataset version by name,
+	// replacing any component of that name already stored for datasetPath
+	PutComponent(datasetPath, name string, data []byte) error
+	// Component gets an encoded component of a dataset version, returning
+	// ErrNotFound if no component of that name is stored for datasetPath
+	Component(datasetPath, name string) ([]byte, error)
+}
+
+// MemComponentStore is an in-memory implementation of the ComponentStore
+// interface
+type MemComponentStore struct {
+	lock       sync.Mutex
+	components map[string]map[string][]byte
+}
+
+// PutComponent stores a component of a dataset version
+func (s *MemComponentStore) PutComponent(datasetPath, name string, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.components == nil {
+		s.components = map[string]map[string][]byte{}
+	}
+	if s.components[datasetPath] == nil {
+		s.components[datasetPath] = map[string][]byte{}
+	}
+	s.components[datasetPath][name] = data
+	return nil
+}
+
+// Component gets a component of a dataset version
+func (s *MemComponentStore) Component(datasetPath, name string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data, ok := s.components[datasetPath][name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"sort"
+	"sync"
 	"time"
 
 	peer "gx/ipfs/QmTRhk7cgjUf2gfQ3p2M9KPECNZEW9XUrmHcFCgog4cPgB/go-libp2p-peer"
@@ -14,6 +15,62 @@ type EventLog interface {
 	EventsSince(time.Time) ([]*Event, error)
 }
 
+// DetailedEventLog is an opt-in interface for event logs that can record who
+// caused an event, along with event-specific parameters
+type DetailedEventLog interface {
+	LogEventDetails(t EventType, when int64, peerID peer.ID, ref DatasetRef, params interface{}) error
+}
+
+// EventFeed is an opt-in interface for repos that notify subscribers of
+// events as they're logged
+type EventFeed interface {
+	// SubscribeEvents registers ch to receive newly logged events. sends to ch
+	// never block, events are dropped if ch isn't ready to receive
+	SubscribeEvents(ch chan<- *Event)
+	// UnsubscribeEvents removes a channel registered with SubscribeEvents
+	UnsubscribeEvents(ch chan<- *Event)
+}
+
+// EventBroadcaster fans logged events out to subscribers. Repos embed an
+// EventBroadcaster to implement EventFeed, calling Broadcast whenever an
+// event is logged
+type EventBroadcaster struct {
+	lock sync.Mutex
+	subs map[chan<- *Event]struct{}
+}
+
+// SubscribeEvents registers a channel to receive events
+func (b *EventBroadcaster) SubscribeEvents(ch chan<- *Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.subs == nil {
+		b.subs = map[chan<- *Event]struct{}{}
+	}
+	b.subs[ch] = struct{}{}
+}
+
+// UnsubscribeEvents removes a channel registered with SubscribeEvents
+func (b *EventBroadcaster) UnsubscribeEvents(ch chan<- *Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.subs, ch)
+}
+
+// Broadcast sends an event to all subscribers without blocking. Broadcast is
+// called once for every logged event, so it also counts events for metrics
+func (b *EventBroadcaster) Broadcast(e *Event) {
+	eventsLogged.With(string(e.Type)).Inc()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
 // Event is a list of details for logging a query
 type Event struct {
 	Time   time.Time
@@ -41,6 +98,17 @@ const (
 	ETDsAdded = EventType("ds_added")
 	// ETTransformExecuted represents running a transformation
 	ETTransformExecuted = EventType("tf_executed")
+	// ETAPIMutation represents an authenticated API request that modified the repo.
+	// Params records the token used to make the request
+	ETAPIMutation = EventType("api_mutation")
+	// ETTransformLimited represents stopping a transformation that exceeded a
+	// limit. Params records the limit
+	ETTransformLimited = EventType("tf_limited")
+	// ETDsFollowed represents a peer following a dataset of this repo
+	ETDsFollowed = EventType("ds_followed")
+	// ETDsPinRequested represents a peer asking this repo to pin one of the
+	// peer's datasets
+	ETDsPinRequested = EventType("ds_pin_requested")
 )
 
 // MemEventLog is an in-memory implementation of the
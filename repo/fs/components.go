@@ -0,0 +1,90 @@
+package fsrepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/qri/repo"
+)
+
+// ComponentStore is a file-based implementation of the repo.ComponentStore
+// interface. Components are written to the content-addressed store, the
+// components file indexes the stored path of each component by dataset path
+// & component name
+type ComponentStore struct {
+	basepath
+	file  File
+	store cafs.Filestore
+	lock  *sync.Mutex
+}
+
+// NewComponentStore allocates a new file-based ComponentStore instance
+func NewComponentStore(base string, file File, store cafs.Filestore) ComponentStore {
+	return ComponentStore{basepath: basepath(base), file: file, store: store, lock: &sync.Mutex{}}
+}
+
+// PutComponent writes a component to the store, indexing it by dataset path
+// & name
+func (s ComponentStore) PutComponent(datasetPath, name string, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	index, err := s.index()
+	if err != nil {
+		return err
+	}
+
+	path, err := s.store.Put(cafs.NewMemfileBytes(name+".json", data), false)
+	if err != nil {
+		return fmt.Errorf("error putting %s: %s", name, err.Error())
+	}
+	if index[datasetPath] == nil {
+		index[datasetPath] = map[string]string{}
+	}
+	index[datasetPath][name] = path
+	return s.saveFile(index, s.file)
+}
+
+// Component reads a component of a dataset version from the store
+func (s ComponentStore) Component(datasetPath, name string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	index, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	path, ok := index[datasetPath][name]
+	if !ok {
+		return nil, repo.ErrNotFound
+	}
+
+	f, err := s.store.Get(path)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s: %s", name, err.Error())
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func (s ComponentStore) index() (map[string]map[string]string, error) {
+	index := map[string]map[string]string{}
+	data, err := ioutil.ReadFile(s.filepath(s.file))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil
+		}
+		log.Debug(err.Error())
+		return index, fmt.Errorf("error loading component index: %s", err.Error())
+	}
+
+	if err := json.Unmarshal(data, &index); err != nil {
+		log.Debug(err.Error())
+		return index, fmt.Errorf("error unmarshaling component index: %s", err.Error())
+	}
+	return index, nil
+}
@@ -6,10 +6,13 @@ import (
 	"io/ioutil"
 	"os"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/qri/repo"
+
+	peer "gx/ipfs/QmTRhk7cgjUf2gfQ3p2M9KPECNZEW9XUrmHcFCgog4cPgB/go-libp2p-peer"
 )
 
 // EventLog is a file-based implementation of the repo.EventLog interface
@@ -17,28 +20,65 @@ type EventLog struct {
 	basepath
 	file  File
 	store cafs.Filestore
+	// writes serializes changes to the log file, it's shared by copies of the
+	// log
+	writes *sync.Mutex
 }
 
 // NewEventLog allocates a new file-based EventLog instance
 func NewEventLog(base string, file File, store cafs.Filestore) EventLog {
-	return EventLog{basepath: basepath(base), file: file, store: store}
+	return EventLog{basepath: basepath(base), file: file, store: store, writes: &sync.Mutex{}}
 }
 
-// LogEvent adds a Event to the store
-func (ql EventLog) LogEvent(t repo.EventType, ref repo.DatasetRef) error {
+// Flush waits for events being written to reach the log file
+func (ql EventLog) Flush() {
+	ql.writes.Lock()
+	ql.writes.Unlock()
+}
+
+// addEvent writes e to the log file. the log is written to a temporary file
+// that replaces the log, so an interrupted write can't truncate it
+func (ql EventLog) addEvent(e *repo.Event) error {
+	ql.writes.Lock()
+	defer ql.writes.Unlock()
+
 	log, err := ql.logs()
 	if err != nil {
 		return err
 	}
+	log = append([]*repo.Event{e}, log...)
+	sort.Slice(log, func(i, j int) bool { return log[i].Time.After(log[j].Time) })
+
+	data, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+	path := ql.filepath(ql.file)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, os.ModePerm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
 
-	e := &repo.Event{
+// LogEvent adds a Event to the store
+func (ql EventLog) LogEvent(t repo.EventType, ref repo.DatasetRef) error {
+	return ql.addEvent(&repo.Event{
 		Time: time.Now(),
 		Type: t,
 		Ref:  ref,
-	}
-	log = append([]*repo.Event{e}, log...)
-	sort.Slice(log, func(i, j int) bool { return log[i].Time.After(log[j].Time) })
-	return ql.saveFile(log, ql.file)
+	})
+}
+
+// LogEventDetails adds an Event with details about who caused the event
+func (ql EventLog) LogEventDetails(t repo.EventType, when int64, peerID peer.ID, ref repo.DatasetRef, params interface{}) error {
+	return ql.addEvent(&repo.Event{
+		Time:   time.Unix(when, 0),
+		Type:   t,
+		Ref:    ref,
+		PeerID: peerID,
+		Params: params,
+	})
 }
 
 // Events fetches a set of Events from the store
@@ -93,3 +133,22 @@ func (ql *EventLog) logs() ([]*repo.Event, error) {
 	}
 	return ds, nil
 }
+
+// LogEvent adds an event to the log, notifying event subscribers
+func (r *Repo) LogEvent(t repo.EventType, ref repo.DatasetRef) error {
+	if err := r.EventLog.LogEvent(t, ref); err != nil {
+		return err
+	}
+	r.Broadcast(&repo.Event{Time: time.Now(), Type: t, Ref: ref})
+	return nil
+}
+
+// LogEventDetails adds a detailed event to the log, notifying event
+// subscribers
+func (r *Repo) LogEventDetails(t repo.EventType, when int64, peerID peer.ID, ref repo.DatasetRef, params interface{}) error {
+	if err := r.EventLog.LogEventDetails(t, when, peerID, ref, params); err != nil {
+		return err
+	}
+	r.Broadcast(&repo.Event{Time: time.Unix(when, 0), Type: t, Ref: ref, PeerID: peerID, Params: params})
+	return nil
+}
@@ -56,6 +56,15 @@ const (
 	FileSelectedRefs
 	// FileChangeRequests is a file of change requests
 	FileChangeRequests
+	// FileMessageQueue holds outbound p2p messages waiting to be sent
+	FileMessageQueue
+	// FileTokens holds API access tokens
+	FileTokens
+	// FileJobs holds background jobs
+	FileJobs
+	// FileComponents indexes stored components of dataset versions, like
+	// column stats & data quality rules
+	FileComponents
 )
 
 var paths = map[File]string{
@@ -71,6 +80,10 @@ var paths = map[File]string{
 	FileSearchIndex:    "/index.bleve",
 	FileSelectedRefs:   "/selected_refs.json",
 	FileChangeRequests: "/change_requests.json",
+	FileMessageQueue:   "/message_queue.json",
+	FileTokens:         "/tokens.json",
+	FileJobs:           "/jobs.json",
+	FileComponents:     "/components.json",
 }
 
 // Filepath gives the relative filepath to a repofiles
@@ -29,6 +29,11 @@ type Repo struct {
 
 	Refstore
 	EventLog
+	MessageQueue
+	TokenStore
+	JobStore
+	ComponentStore
+	*repo.EventBroadcaster
 
 	profile *profile.Profile
 
@@ -62,6 +67,13 @@ func NewRepo(store cafs.Filestore, pro *profile.Profile, rc *regclient.Client, b
 		Refstore: Refstore{basepath: bp, store: store, file: FileRefstore},
 		EventLog: NewEventLog(base, FileEventLogs, store),
 
+		MessageQueue:   NewMessageQueue(base, FileMessageQueue),
+		TokenStore:     NewTokenStore(base, FileTokens),
+		JobStore:       NewJobStore(base, FileJobs),
+		ComponentStore: NewComponentStore(base, FileComponents, store),
+
+		EventBroadcaster: &repo.EventBroadcaster{},
+
 		profiles: NewProfileStore(bp),
 
 		registry: rc,
@@ -175,6 +187,20 @@ func (r *Repo) Registry() *regclient.Client {
 	return r.registry
 }
 
+// Close releases resources held by the repo, waiting for event log writes
+// to finish and flushing & closing the search index if one is open
+func (r *Repo) Close() error {
+	r.EventLog.Flush()
+	if r.index != nil {
+		if err := r.index.Close(); err != nil {
+			return err
+		}
+		r.index = nil
+		r.Refstore.index = nil
+	}
+	return nil
+}
+
 // Destroy destroys this repository
 func (r *Repo) Destroy() error {
 	return os.RemoveAll(string(r.basepath))
@@ -0,0 +1,107 @@
+package fsrepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/qri-io/qri/repo"
+)
+
+// JobStore is a file-based implementation of the repo.JobStore interface
+type JobStore struct {
+	basepath
+	file File
+	lock *sync.Mutex
+}
+
+// NewJobStore allocates a new file-based JobStore instance
+func NewJobStore(base string, file File) JobStore {
+	return JobStore{basepath: basepath(base), file: file, lock: &sync.Mutex{}}
+}
+
+// PutJob adds a job to the store, replacing any job with the same ID
+func (js JobStore) PutJob(j repo.Job) error {
+	js.lock.Lock()
+	defer js.lock.Unlock()
+
+	jobs, err := js.jobs()
+	if err != nil {
+		return err
+	}
+
+	for i, job := range jobs {
+		if job.ID == j.ID {
+			jobs[i] = j
+			return js.saveFile(jobs, js.file)
+		}
+	}
+
+	jobs = append(jobs, j)
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.Before(jobs[j].Created) })
+	return js.saveFile(jobs, js.file)
+}
+
+// Jobs lists all jobs, oldest first
+func (js JobStore) Jobs() ([]repo.Job, error) {
+	js.lock.Lock()
+	defer js.lock.Unlock()
+	return js.jobs()
+}
+
+// Job gets a job by ID
+func (js JobStore) Job(id string) (repo.Job, error) {
+	js.lock.Lock()
+	defer js.lock.Unlock()
+
+	jobs, err := js.jobs()
+	if err != nil {
+		return repo.Job{}, err
+	}
+	for _, j := range jobs {
+		if j.ID == id {
+			return j, nil
+		}
+	}
+	return repo.Job{}, repo.ErrNotFound
+}
+
+// DeleteJob removes a job by ID
+func (js JobStore) DeleteJob(id string) error {
+	js.lock.Lock()
+	defer js.lock.Unlock()
+
+	jobs, err := js.jobs()
+	if err != nil {
+		return err
+	}
+
+	for i, j := range jobs {
+		if j.ID == id {
+			jobs = append(jobs[:i], jobs[i+1:]...)
+			return js.saveFile(jobs, js.file)
+		}
+	}
+	return repo.ErrNotFound
+}
+
+func (js JobStore) jobs() ([]repo.Job, error) {
+	jobs := []repo.Job{}
+	data, err := ioutil.ReadFile(js.filepath(js.file))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return jobs, nil
+		}
+		log.Debug(err.Error())
+		return jobs, fmt.Errorf("error loading jobs: %s", err.Error())
+	}
+
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		log.Debug(err.Error())
+		return jobs, fmt.Errorf("error unmarshaling jobs: %s", err.Error())
+	}
+	return jobs, nil
+}
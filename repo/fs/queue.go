@@ -0,0 +1,98 @@
+package fsrepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/qri-io/qri/repo"
+)
+
+// MessageQueue is a file-based implementation of the repo.MessageQueue interface
+type MessageQueue struct {
+	basepath
+	file File
+	lock *sync.Mutex
+}
+
+// NewMessageQueue allocates a new file-based MessageQueue instance
+func NewMessageQueue(base string, file File) MessageQueue {
+	return MessageQueue{basepath: basepath(base), file: file, lock: &sync.Mutex{}}
+}
+
+// PutQueuedMessage adds a message to the queue, replacing any message with
+// the same ID
+func (q MessageQueue) PutQueuedMessage(m repo.QueuedMessage) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	msgs, err := q.messages()
+	if err != nil {
+		return err
+	}
+
+	for i, qm := range msgs {
+		if qm.ID == m.ID {
+			msgs[i] = m
+			return q.saveFile(msgs, q.file)
+		}
+	}
+
+	msgs = append(msgs, m)
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].Created.Before(msgs[j].Created) })
+	return q.saveFile(msgs, q.file)
+}
+
+// QueuedMessages lists all queued messages, oldest first
+func (q MessageQueue) QueuedMessages() ([]repo.QueuedMessage, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.messages()
+}
+
+// DeleteQueuedMessage removes a message from the queue
+func (q MessageQueue) DeleteQueuedMessage(id string) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	msgs, err := q.messages()
+	if err != nil {
+		return err
+	}
+
+	for i, qm := range msgs {
+		if qm.ID == id {
+			msgs = append(msgs[:i], msgs[i+1:]...)
+			return q.saveFile(msgs, q.file)
+		}
+	}
+	return repo.ErrNotFound
+}
+
+// ClearQueue drops all queued messages
+func (q MessageQueue) ClearQueue() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.saveFile([]repo.QueuedMessage{}, q.file)
+}
+
+func (q MessageQueue) messages() ([]repo.QueuedMessage, error) {
+	msgs := []repo.QueuedMessage{}
+	data, err := ioutil.ReadFile(q.filepath(q.file))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return msgs, nil
+		}
+		log.Debug(err.Error())
+		return msgs, fmt.Errorf("error loading message queue: %s", err.Error())
+	}
+
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		log.Debug(err.Error())
+		return msgs, fmt.Errorf("error unmarshaling message queue: %s", err.Error())
+	}
+	return msgs, nil
+}
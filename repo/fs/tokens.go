@@ -0,0 +1,107 @@
+package fsrepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/qri-io/qri/repo"
+)
+
+// TokenStore is a file-based implementation of the repo.TokenStore interface
+type TokenStore struct {
+	basepath
+	file File
+	lock *sync.Mutex
+}
+
+// NewTokenStore allocates a new file-based TokenStore instance
+func NewTokenStore(base string, file File) TokenStore {
+	return TokenStore{basepath: basepath(base), file: file, lock: &sync.Mutex{}}
+}
+
+// PutToken adds a token to the store, replacing any token with the same ID
+func (ts TokenStore) PutToken(t repo.Token) error {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	toks, err := ts.tokens()
+	if err != nil {
+		return err
+	}
+
+	for i, tok := range toks {
+		if tok.ID == t.ID {
+			toks[i] = t
+			return ts.saveFile(toks, ts.file)
+		}
+	}
+
+	toks = append(toks, t)
+	sort.Slice(toks, func(i, j int) bool { return toks[i].Created.Before(toks[j].Created) })
+	return ts.saveFile(toks, ts.file)
+}
+
+// Tokens lists all tokens, oldest first
+func (ts TokenStore) Tokens() ([]repo.Token, error) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	return ts.tokens()
+}
+
+// TokenBySecret finds the token a secret belongs to
+func (ts TokenStore) TokenBySecret(secret string) (repo.Token, error) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	toks, err := ts.tokens()
+	if err != nil {
+		return repo.Token{}, err
+	}
+	for _, t := range toks {
+		if t.Matches(secret) {
+			return t, nil
+		}
+	}
+	return repo.Token{}, repo.ErrTokenNotFound
+}
+
+// DeleteToken removes a token by ID
+func (ts TokenStore) DeleteToken(id string) error {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	toks, err := ts.tokens()
+	if err != nil {
+		return err
+	}
+
+	for i, t := range toks {
+		if t.ID == id {
+			toks = append(toks[:i], toks[i+1:]...)
+			return ts.saveFile(toks, ts.file)
+		}
+	}
+	return repo.ErrNotFound
+}
+
+func (ts TokenStore) tokens() ([]repo.Token, error) {
+	toks := []repo.Token{}
+	data, err := ioutil.ReadFile(ts.filepath(ts.file))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return toks, nil
+		}
+		log.Debug(err.Error())
+		return toks, fmt.Errorf("error loading tokens: %s", err.Error())
+	}
+
+	if err := json.Unmarshal(data, &toks); err != nil {
+		log.Debug(err.Error())
+		return toks, fmt.Errorf("error unmarshaling tokens: %s", err.Error())
+	}
+	return toks, nil
+}
@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// JobStatus is the state of a background job
+type JobStatus string
+
+const (
+	// JobQueued jobs are waiting for a free slot to run in
+	JobQueued = JobStatus("queued")
+	// JobRunning jobs have started & haven't finished
+	JobRunning = JobStatus("running")
+	// JobSucceeded jobs finished without error
+	JobSucceeded = JobStatus("succeeded")
+	// JobFailed jobs finished with an error, or were interrupted
+	JobFailed = JobStatus("failed")
+	// JobCancelled jobs were cancelled before they started
+	JobCancelled = JobStatus("cancelled")
+)
+
+// Finished returns true if a job with this status will never run again
+func (s JobStatus) Finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// Job is a long-running operation run in the background. Params and Result
+// are stored as encoded JSON to keep the repo package free of lib types
+type Job struct {
+	// ID is a short, random identifier for the job
+	ID string
+	// Type is the operation this job performs, eg: "save"
+	Type string
+	// Status is the current state of the job
+	Status JobStatus
+	// Params are the encoded parameters of the operation
+	Params json.RawMessage
+	// Progress is a log of progress messages, oldest first
+	Progress []string
+	// Output is any script output the operation produced
+	Output string
+	// Result is the encoded result of a successful operation
+	Result json.RawMessage
+	// Error is the error message of a failed operation
+	Error string
+	// Created is when the job was submitted
+	Created time.Time
+	// Started is when the job began running
+	Started time.Time
+	// Finished is when the job stopped running
+	Finished time.Time
+}
+
+// NewJob creates a queued job with a random ID
+func NewJob(typ string, params json.RawMessage) (Job, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return Job{}, err
+	}
+	return Job{
+		ID:      hex.EncodeToString(buf),
+		Type:    typ,
+		Status:  JobQueued,
+		Params:  params,
+		Created: time.Now(),
+	}, nil
+}
+
+// JobStore is an opt-in interface for repos that can persist background jobs,
+// allowing queued jobs to resume after a restart
+type JobStore interface {
+	// PutJob adds a job to the store, replacing any job with the same ID
+	PutJob(j Job) error
+	// Jobs lists all stored jobs, oldest first
+	Jobs() ([]Job, error)
+	// Job gets a job by ID, returning ErrNotFound if no job matches
+	Job(id string) (Job, error)
+	// DeleteJob removes a job by ID
+	DeleteJob(id string) error
+}
+
+// MemJobStore is an in-memory implementation of the JobStore interface
+type MemJobStore []Job
+
+// PutJob adds a job to the store
+func (js *MemJobStore) PutJob(j Job) error {
+	for i, job := range *js {
+		if job.ID == j.ID {
+			(*js)[i] = j
+			return nil
+		}
+	}
+	jobs := append(*js, j)
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.Before(jobs[j].Created) })
+	*js = jobs
+	return nil
+}
+
+// Jobs lists all jobs, oldest first
+func (js MemJobStore) Jobs() ([]Job, error) {
+	jobs := make([]Job, len(js))
+	copy(jobs, js)
+	return jobs, nil
+}
+
+// Job gets a job by ID
+func (js MemJobStore) Job(id string) (Job, error) {
+	for _, j := range js {
+		if j.ID == id {
+			return j, nil
+		}
+	}
+	return Job{}, ErrNotFound
+}
+
+// DeleteJob removes a job by ID
+func (js *MemJobStore) DeleteJob(id string) error {
+	for i, j := range *js {
+		if j.ID == id {
+			*js = append((*js)[:i], (*js)[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
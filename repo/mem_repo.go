@@ -1,17 +1,26 @@
 package repo
 
 import (
+	"time"
+
 	"github.com/libp2p/go-libp2p-crypto"
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/dataset/dsgraph"
 	"github.com/qri-io/qri/repo/profile"
 	"github.com/qri-io/registry/regclient"
+
+	peer "gx/ipfs/QmTRhk7cgjUf2gfQ3p2M9KPECNZEW9XUrmHcFCgog4cPgB/go-libp2p-peer"
 )
 
 // MemRepo is an in-memory implementation of the Repo interface
 type MemRepo struct {
 	*MemRefstore
 	*MemEventLog
+	*MemMessageQueue
+	*MemTokenStore
+	*MemJobStore
+	*MemComponentStore
+	*EventBroadcaster
 
 	store        cafs.Filestore
 	graph        map[string]*dsgraph.Node
@@ -33,9 +42,35 @@ func NewMemRepo(p *profile.Profile, store cafs.Filestore, ps profile.Store, rc *
 		profile:     p,
 		profiles:    ps,
 		registry:    rc,
+
+		MemMessageQueue:   &MemMessageQueue{},
+		MemTokenStore:     &MemTokenStore{},
+		MemJobStore:       &MemJobStore{},
+		MemComponentStore: &MemComponentStore{},
+
+		EventBroadcaster: &EventBroadcaster{},
 	}, nil
 }
 
+// LogEvent adds an event to the log, notifying event subscribers
+func (r *MemRepo) LogEvent(t EventType, ref DatasetRef) error {
+	if err := r.MemEventLog.LogEvent(t, ref); err != nil {
+		return err
+	}
+	r.Broadcast(&Event{Time: time.Now(), Type: t, Ref: ref})
+	return nil
+}
+
+// LogEventDetails adds a detailed event to the log, notifying event
+// subscribers
+func (r *MemRepo) LogEventDetails(t EventType, when int64, peerID peer.ID, ref DatasetRef, params interface{}) error {
+	if err := r.MemEventLog.LogEventDetails(t, when, peerID, ref, params); err != nil {
+		return err
+	}
+	r.Broadcast(&Event{Time: time.Unix(when, 0), Type: t, Ref: ref, PeerID: peerID, Params: params})
+	return nil
+}
+
 // Store returns the underlying cafs.Filestore for this repo
 func (r *MemRepo) Store() cafs.Filestore {
 	return r.store
@@ -0,0 +1,10 @@
+package repo
+
+import "github.com/qri-io/qri/metrics"
+
+var eventsLogged = metrics.NewCounterVec("qri_repo_events_logged_total",
+	"events added to the repo event log, by event type", "type")
+
+func init() {
+	metrics.MustRegister(eventsLogged)
+}
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"sort"
+	"time"
+
+	peer "gx/ipfs/QmTRhk7cgjUf2gfQ3p2M9KPECNZEW9XUrmHcFCgog4cPgB/go-libp2p-peer"
+)
+
+// MessageQueue is an opt-in interface for repos that can persist outbound
+// peer-to-peer messages made while a node is offline, for delivery once the
+// node reconnects
+type MessageQueue interface {
+	// PutQueuedMessage adds a message to the queue, replacing any queued
+	// message with the same ID
+	PutQueuedMessage(m QueuedMessage) error
+	// QueuedMessages lists all queued messages, oldest first
+	QueuedMessages() ([]QueuedMessage, error)
+	// DeleteQueuedMessage removes a message from the queue by ID
+	DeleteQueuedMessage(id string) error
+	// ClearQueue drops all queued messages
+	ClearQueue() error
+}
+
+// QueuedMessage is an outbound message waiting to be sent. Messages are
+// stored as encoded bytes to keep the repo package free of p2p types
+type QueuedMessage struct {
+	// ID of the queued message
+	ID string
+	// Type is the type of message, used for reporting
+	Type string
+	// Message is the encoded message
+	Message []byte
+	// PeerIDs are the peers to send to. an empty list sends the message to all
+	// connected qri peers
+	PeerIDs []peer.ID
+	// Created is when the message was first queued
+	Created time.Time
+	// Attempts is the number of times sending has been tried
+	Attempts int
+	// NextAttempt is the earliest time sending should be retried
+	NextAttempt time.Time
+	// LastError is the error from the most recent attempt, if any
+	LastError string
+}
+
+// MemMessageQueue is an in-memory implementation of the MessageQueue interface
+type MemMessageQueue []QueuedMessage
+
+// PutQueuedMessage adds a message to the queue
+func (q *MemMessageQueue) PutQueuedMessage(m QueuedMessage) error {
+	for i, qm := range *q {
+		if qm.ID == m.ID {
+			(*q)[i] = m
+			return nil
+		}
+	}
+	msgs := append(*q, m)
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].Created.Before(msgs[j].Created) })
+	*q = msgs
+	return nil
+}
+
+// QueuedMessages lists all queued messages
+func (q MemMessageQueue) QueuedMessages() ([]QueuedMessage, error) {
+	msgs := make([]QueuedMessage, len(q))
+	copy(msgs, q)
+	return msgs, nil
+}
+
+// DeleteQueuedMessage removes a message from the queue
+func (q *MemMessageQueue) DeleteQueuedMessage(id string) error {
+	for i, qm := range *q {
+		if qm.ID == id {
+			*q = append((*q)[:i], (*q)[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// ClearQueue drops all queued messages
+func (q *MemMessageQueue) ClearQueue() error {
+	*q = MemMessageQueue{}
+	return nil
+}
@@ -0,0 +1,30 @@
+package repo
+
+import (
+	"io"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+)
+
+// DetachBody removes & returns the dataset body if it's a reader, which lets
+// refs carrying a body be sent over qrpc connections
+func (r *DatasetRef) DetachBody() io.Reader {
+	if r.Dataset == nil {
+		return nil
+	}
+	body, ok := r.Dataset.Body.(io.Reader)
+	if !ok {
+		return nil
+	}
+	r.Dataset.Body = nil
+	return body
+}
+
+// AttachBody sets the dataset body to a file that reads from body
+func (r *DatasetRef) AttachBody(body io.Reader) {
+	if r.Dataset == nil {
+		r.Dataset = &dataset.DatasetPod{}
+	}
+	r.Dataset.Body = cafs.NewMemfileReader("body", body)
+}
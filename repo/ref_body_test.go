@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/dataset"
+)
+
+func TestDatasetRefBody(t *testing.T) {
+	ref := &DatasetRef{}
+	if body := ref.DetachBody(); body != nil {
+		t.Error("expected ref without a dataset to have no body")
+	}
+
+	ref.Dataset = &dataset.DatasetPod{Body: []interface{}{"a"}}
+	if body := ref.DetachBody(); body != nil {
+		t.Error("expected non-reader body not to be detached")
+	}
+	if ref.Dataset.Body == nil {
+		t.Error("expected non-reader body to be left in place")
+	}
+
+	ref.Dataset.Body = strings.NewReader(`["a","b"]`)
+	body := ref.DetachBody()
+	if body == nil {
+		t.Fatal("expected reader body to be detached")
+	}
+	if ref.Dataset.Body != nil {
+		t.Error("expected detaching to remove body from dataset")
+	}
+	ref = &DatasetRef{}
+	ref.AttachBody(body)
+	r, ok := ref.Dataset.Body.(io.Reader)
+	if !ok {
+		t.Fatalf("expected attached body to be a reader, got: %T", ref.Dataset.Body)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `["a","b"]` {
+		t.Errorf("body mismatch. expected: %s, got: %s", `["a","b"]`, string(got))
+	}
+}
@@ -34,6 +34,11 @@ func RunRepoTests(t *testing.T, rmf RepoMakerFunc) {
 		"testRefstoreRefs":        testRefstoreRefs,
 		"testRefstore":            testRefstoreMain,
 		"testProfileStore":        testProfileStore,
+		"testMessageQueue":        testMessageQueue,
+		"testTokenStore":          testTokenStore,
+		"testJobStore":            testJobStore,
+		"testComponentStore":      testComponentStore,
+		"testEventFeed":           testEventFeed,
 	}
 
 	for key, test := range tests {
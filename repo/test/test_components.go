@@ -0,0 +1,57 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/qri-io/qri/repo"
+)
+
+func testComponentStore(t *testing.T, rmf RepoMakerFunc) {
+	r, cleanup := rmf(t)
+	defer cleanup()
+
+	cs, ok := r.(repo.ComponentStore)
+	if !ok {
+		return
+	}
+
+	if _, err := cs.Component("/map/QmMissing", "stats"); err != repo.ErrNotFound {
+		t.Errorf("expected missing component to return ErrNotFound, got: %v", err)
+	}
+
+	puts := []struct {
+		path, name, data string
+	}{
+		{"/map/QmA", "stats", `{"entries":1}`},
+		{"/map/QmA", "rules", `[{"type":"unique","columns":["a"]}]`},
+		{"/map/QmB", "stats", `{"entries":2}`},
+		{"/map/QmA", "stats", `{"entries":3}`},
+	}
+	for i, p := range puts {
+		if err := cs.PutComponent(p.path, p.name, []byte(p.data)); err != nil {
+			t.Fatalf("put %d error: %s", i, err)
+		}
+	}
+
+	cases := []struct {
+		path, name, expect string
+	}{
+		{"/map/QmA", "stats", `{"entries":3}`},
+		{"/map/QmA", "rules", `[{"type":"unique","columns":["a"]}]`},
+		{"/map/QmB", "stats", `{"entries":2}`},
+	}
+	for i, c := range cases {
+		got, err := cs.Component(c.path, c.name)
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err)
+			continue
+		}
+		if string(got) != c.expect {
+			t.Errorf("case %d component mismatch. expected: %s, got: %s", i, c.expect, string(got))
+		}
+	}
+
+	if _, err := cs.Component("/map/QmB", "rules"); err != repo.ErrNotFound {
+		t.Errorf("expected component missing from a stored version to return ErrNotFound, got: %v", err)
+	}
+}
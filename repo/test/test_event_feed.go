@@ -0,0 +1,50 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qri-io/qri/repo"
+)
+
+func testEventFeed(t *testing.T, rmf RepoMakerFunc) {
+	r, cleanup := rmf(t)
+	defer cleanup()
+
+	feed, ok := r.(repo.EventFeed)
+	if !ok {
+		return
+	}
+
+	events := make(chan *repo.Event, 2)
+	feed.SubscribeEvents(events)
+
+	ref := repo.DatasetRef{Peername: "peer", Name: "cities", Path: "/map/QmHash"}
+	if err := r.LogEvent(repo.ETDsCreated, ref); err != nil {
+		t.Fatalf("error logging event: %s", err)
+	}
+	if el, ok := r.(repo.DetailedEventLog); ok {
+		if err := el.LogEventDetails(repo.ETDsRenamed, time.Now().Unix(), "", ref, nil); err != nil {
+			t.Fatalf("error logging detailed event: %s", err)
+		}
+	}
+
+	select {
+	case e := <-events:
+		if e.Type != repo.ETDsCreated || e.Ref.Path != ref.Path {
+			t.Errorf("expected %s event for %s, got: %s %s", repo.ETDsCreated, ref.Path, e.Type, e.Ref.Path)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	feed.UnsubscribeEvents(events)
+	if err := r.LogEvent(repo.ETDsDeleted, ref); err != nil {
+		t.Fatalf("error logging event: %s", err)
+	}
+	for len(events) > 0 {
+		if e := <-events; e.Type == repo.ETDsDeleted {
+			t.Error("expected no events after unsubscribing")
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qri-io/qri/repo"
+)
+
+func testJobStore(t *testing.T, rmf RepoMakerFunc) {
+	r, cleanup := rmf(t)
+	defer cleanup()
+
+	js, ok := r.(repo.JobStore)
+	if !ok {
+		return
+	}
+
+	save, err := repo.NewJob("save", []byte(`{"Ref":"me/a"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish, err := repo.NewJob("publish", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// make sure ordering doesn't depend on insertion
+	save.Created = publish.Created.Add(-time.Second)
+
+	for _, j := range []repo.Job{publish, save} {
+		if err := js.PutJob(j); err != nil {
+			t.Fatalf("error putting job: %s", err)
+		}
+	}
+
+	jobs, err := js.Jobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got: %d", len(jobs))
+	}
+	if jobs[0].ID != save.ID {
+		t.Errorf("expected jobs to be listed oldest first")
+	}
+
+	save.Status = repo.JobSucceeded
+	save.Progress = []string{"started", "done"}
+	save.Result = []byte(`{"Path":"/map/Qm"}`)
+	if err := js.PutJob(save); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := js.Job(save.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != repo.JobSucceeded || len(got.Progress) != 2 || string(got.Result) != `{"Path":"/map/Qm"}` {
+		t.Errorf("updated job mismatch. got: %#v", got)
+	}
+	if string(got.Params) != `{"Ref":"me/a"}` {
+		t.Errorf("job params mismatch. expected: %s, got: %s", `{"Ref":"me/a"}`, string(got.Params))
+	}
+
+	if err := js.DeleteJob(save.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.Job(save.ID); err != repo.ErrNotFound {
+		t.Errorf("expected deleted job to return ErrNotFound, got: %v", err)
+	}
+	if err := js.DeleteJob(save.ID); err != repo.ErrNotFound {
+		t.Errorf("expected deleting a missing job to return ErrNotFound, got: %v", err)
+	}
+}
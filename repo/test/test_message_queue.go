@@ -0,0 +1,73 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qri-io/qri/repo"
+)
+
+func testMessageQueue(t *testing.T, rmf RepoMakerFunc) {
+	r, cleanup := rmf(t)
+	defer cleanup()
+
+	mq, ok := r.(repo.MessageQueue)
+	if !ok {
+		return
+	}
+
+	now := time.Date(2001, 1, 1, 1, 1, 1, 1, time.UTC)
+	msgs := []repo.QueuedMessage{
+		{ID: "b", Type: "connected", Message: []byte("b"), Created: now.Add(time.Second)},
+		{ID: "a", Type: "connected", Message: []byte("a"), Created: now},
+	}
+	for _, m := range msgs {
+		if err := mq.PutQueuedMessage(m); err != nil {
+			t.Fatalf("error queueing message: %s", err)
+		}
+	}
+
+	got, err := mq.QueuedMessages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 queued messages, got: %d", len(got))
+	}
+	if got[0].ID != "a" {
+		t.Errorf("expected queue to be ordered oldest-first. expected first ID: a, got: %s", got[0].ID)
+	}
+
+	update := got[0]
+	update.Attempts = 1
+	update.LastError = "oh noes"
+	if err := mq.PutQueuedMessage(update); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = mq.QueuedMessages(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("expected updating a message to not change queue length. expected: 2, got: %d", len(got))
+	}
+	if got[0].Attempts != 1 || got[0].LastError != "oh noes" {
+		t.Errorf("expected updated message to be stored, got: %v", got[0])
+	}
+
+	if err := mq.DeleteQueuedMessage("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mq.DeleteQueuedMessage("a"); err != repo.ErrNotFound {
+		t.Errorf("expected deleting a missing message to return ErrNotFound, got: %v", err)
+	}
+
+	if err := mq.ClearQueue(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = mq.QueuedMessages(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected empty queue after clearing, got %d messages", len(got))
+	}
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/qri-io/qri/repo"
+)
+
+func testTokenStore(t *testing.T, rmf RepoMakerFunc) {
+	r, cleanup := rmf(t)
+	defer cleanup()
+
+	ts, ok := r.(repo.TokenStore)
+	if !ok {
+		return
+	}
+
+	read, readSecret, err := repo.NewToken("dashboard", repo.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, adminSecret, err := repo.NewToken("ops", repo.ScopeAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tok := range []repo.Token{read, admin} {
+		if err := ts.PutToken(tok); err != nil {
+			t.Fatalf("error putting token: %s", err)
+		}
+	}
+
+	toks, err := ts.Tokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(toks) != 2 {
+		t.Fatalf("expected 2 tokens, got: %d", len(toks))
+	}
+	for _, tok := range toks {
+		if tok.Hash == readSecret || tok.Hash == adminSecret {
+			t.Errorf("token %s is storing it's secret in plain text", tok.ID)
+		}
+	}
+
+	got, err := ts.TokenBySecret(adminSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != admin.ID || got.Scope != repo.ScopeAdmin {
+		t.Errorf("secret matched the wrong token. expected: %s, got: %s", admin.ID, got.ID)
+	}
+	if _, err := ts.TokenBySecret("not-a-secret"); err != repo.ErrTokenNotFound {
+		t.Errorf("expected unknown secret to return ErrTokenNotFound, got: %v", err)
+	}
+
+	if err := ts.DeleteToken(read.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.TokenBySecret(readSecret); err != repo.ErrTokenNotFound {
+		t.Errorf("expected deleted token secret to return ErrTokenNotFound, got: %v", err)
+	}
+	if err := ts.DeleteToken(read.ID); err != repo.ErrNotFound {
+		t.Errorf("expected deleting a missing token to return ErrNotFound, got: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package repo
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TokenScope is the level of access an API token grants
+type TokenScope string
+
+const (
+	// ScopeRead grants access to read-only requests
+	ScopeRead = TokenScope("read")
+	// ScopeWrite grants access to requests that modify datasets
+	ScopeWrite = TokenScope("write")
+	// ScopeAdmin grants access to all requests, including node configuration
+	// & peer management
+	ScopeAdmin = TokenScope("admin")
+)
+
+// scopeRanks orders scopes, each scope includes all scopes of lower rank
+var scopeRanks = map[TokenScope]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+// ParseTokenScope checks a string is a valid scope
+func ParseTokenScope(s string) (TokenScope, error) {
+	scope := TokenScope(s)
+	if _, ok := scopeRanks[scope]; !ok {
+		return scope, fmt.Errorf("invalid token scope '%s'. must be one of: read, write, admin", s)
+	}
+	return scope, nil
+}
+
+// Allows returns true if scope s includes access to required
+func (s TokenScope) Allows(required TokenScope) bool {
+	return scopeRanks[s] > 0 && scopeRanks[s] >= scopeRanks[required]
+}
+
+// ErrTokenNotFound is returned when a token secret doesn't match any stored token
+var ErrTokenNotFound = fmt.Errorf("token not found")
+
+// Token is an API access token. Only a hash of the token's secret is stored,
+// the secret itself is shown once when a token is created
+type Token struct {
+	// ID is a short, public identifier for the token
+	ID string
+	// Name is a human-readable description of what the token is for
+	Name string
+	// Scope is the level of access this token grants
+	Scope TokenScope
+	// Hash is the hex-encoded sha256 hash of the token secret
+	Hash string
+	// Created is when the token was created
+	Created time.Time
+}
+
+// NewToken creates a token with a random secret
+func NewToken(name string, scope TokenScope) (t Token, secret string, err error) {
+	if _, err = ParseTokenScope(string(scope)); err != nil {
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return
+	}
+	secret = base64.RawURLEncoding.EncodeToString(buf)
+	hash := HashTokenSecret(secret)
+
+	t = Token{
+		ID:      hash[:12],
+		Name:    name,
+		Scope:   scope,
+		Hash:    hash,
+		Created: time.Now(),
+	}
+	return
+}
+
+// HashTokenSecret hashes a token secret for storage & comparison
+func HashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Matches checks if secret is this token's secret
+func (t Token) Matches(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(t.Hash), []byte(HashTokenSecret(secret))) == 1
+}
+
+// TokenStore is an opt-in interface for repos that can store API tokens
+type TokenStore interface {
+	// PutToken adds a token to the store, replacing any token with the same ID
+	PutToken(t Token) error
+	// Tokens lists all stored tokens, oldest first
+	Tokens() ([]Token, error)
+	// TokenBySecret finds the token a secret belongs to, returning
+	// ErrTokenNotFound if no token matches
+	TokenBySecret(secret string) (Token, error)
+	// DeleteToken removes a token by ID
+	DeleteToken(id string) error
+}
+
+// MemTokenStore is an in-memory implementation of the TokenStore interface
+type MemTokenStore []Token
+
+// PutToken adds a token to the store
+func (ts *MemTokenStore) PutToken(t Token) error {
+	for i, tok := range *ts {
+		if tok.ID == t.ID {
+			(*ts)[i] = t
+			return nil
+		}
+	}
+	toks := append(*ts, t)
+	sort.Slice(toks, func(i, j int) bool { return toks[i].Created.Before(toks[j].Created) })
+	*ts = toks
+	return nil
+}
+
+// Tokens lists all tokens, oldest first
+func (ts MemTokenStore) Tokens() ([]Token, error) {
+	toks := make([]Token, len(ts))
+	copy(toks, ts)
+	return toks, nil
+}
+
+// TokenBySecret finds the token a secret belongs to
+func (ts MemTokenStore) TokenBySecret(secret string) (Token, error) {
+	for _, t := range ts {
+		if t.Matches(secret) {
+			return t, nil
+		}
+	}
+	return Token{}, ErrTokenNotFound
+}
+
+// DeleteToken removes a token by ID
+func (ts *MemTokenStore) DeleteToken(id string) error {
+	for i, t := range *ts {
+		if t.ID == id {
+			*ts = append((*ts)[:i], (*ts)[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"testing"
+)
+
+func TestTokenScopeAllows(t *testing.T) {
+	cases := []struct {
+		scope, required TokenScope
+		expect          bool
+	}{
+		{ScopeRead, ScopeRead, true},
+		{ScopeRead, ScopeWrite, false},
+		{ScopeRead, ScopeAdmin, false},
+		{ScopeWrite, ScopeRead, true},
+		{ScopeWrite, ScopeWrite, true},
+		{ScopeWrite, ScopeAdmin, false},
+		{ScopeAdmin, ScopeAdmin, true},
+		{TokenScope(""), ScopeRead, false},
+		{TokenScope("superuser"), ScopeRead, false},
+	}
+
+	for i, c := range cases {
+		if got := c.scope.Allows(c.required); got != c.expect {
+			t.Errorf("case %d: expected %s.Allows(%s) == %t", i, c.scope, c.required, c.expect)
+		}
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	if _, _, err := NewToken("bad", TokenScope("root")); err == nil {
+		t.Error("expected invalid scope to error")
+	}
+
+	tok, secret, err := NewToken("good", ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tok.Matches(secret) {
+		t.Error("expected token to match it's own secret")
+	}
+	if tok.Matches(secret + "x") {
+		t.Error("expected token not to match a different secret")
+	}
+	if tok.ID == "" || tok.Hash == secret {
+		t.Errorf("expected token to have an ID & hashed secret, got: %#v", tok)
+	}
+}